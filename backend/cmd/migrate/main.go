@@ -1,14 +1,53 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"url-shortener/internal/config"
 	"url-shortener/internal/infrastructure/database"
+	"url-shortener/internal/infrastructure/database/migrator"
 )
 
+const defaultMigrationsDir = "internal/infrastructure/database/migrations"
+
 func main() {
+	dir := flag.String("dir", defaultMigrationsDir, "directory containing the SQL migration files")
+	flag.Usage = usage
+	flag.Parse()
+
+	command := "up"
+	args := flag.Args()
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "up", "down", "status", "create":
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	// Creating a migration only touches the filesystem
+	if command == "create" {
+		if len(args) == 0 {
+			log.Fatal("Usage: migrate create <name>")
+		}
+		upPath, downPath, err := migrator.Create(*dir, strings.Join(args, "_"))
+		if err != nil {
+			log.Fatalf("Failed to create migration: %v", err)
+		}
+		log.Printf("Created %s", upPath)
+		log.Printf("Created %s", downPath)
+		return
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -22,17 +61,73 @@ func main() {
 	}
 	defer db.Close()
 
-	// Run migrations
-	if err := db.AutoMigrate(); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
-	}
+	m := migrator.New(db.DB, os.DirFS(*dir))
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, migration := range applied {
+			log.Printf("Applied %03d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+		if len(applied) == 0 {
+			log.Println("No pending migrations")
+			return
+		}
+		log.Println("Database migration completed successfully")
 
-	// Create indexes
-	if err := db.CreateIndexes(); err != nil {
-		log.Fatalf("Failed to create indexes: %v", err)
+	case "down":
+		n := 1
+		if len(args) > 0 {
+			n, err = strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				log.Fatalf("Invalid number of migrations to roll back: %s", args[0])
+			}
+		}
+		reverted, err := m.Down(ctx, n)
+		for _, migration := range reverted {
+			log.Printf("Rolled back %03d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("Failed to roll back migrations: %v", err)
+		}
+		if len(reverted) == 0 {
+			log.Println("No applied migrations to roll back")
+		}
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Modified {
+				state += " (MODIFIED)"
+			}
+			fmt.Printf("%03d_%-40s %s\n", status.Version, status.Name, state)
+		}
 	}
+}
 
-	log.Println("Database migration completed successfully")
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: migrate [-dir path] <command>
+
+Commands:
+  up             apply all pending migrations (default)
+  down [N]       roll back the last N applied migrations (default 1)
+  status         list migrations and whether they have been applied
+  create <name>  create a new numbered up/down migration pair
+
+Flags:
+`)
+	flag.PrintDefaults()
 }
 
 func init() {
@@ -40,4 +135,4 @@ func init() {
 	if _, err := os.Stat("go.mod"); os.IsNotExist(err) {
 		log.Fatal("go.mod not found. Please run this command from the backend directory.")
 	}
-}
\ No newline at end of file
+}
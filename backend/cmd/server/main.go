@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,13 +10,27 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // redirect rule time windows name IANA zones
 
+	"url-shortener/internal/api/handlers"
+	"url-shortener/internal/api/middleware"
+	"url-shortener/internal/api/routes"
 	"url-shortener/internal/config"
+	"url-shortener/internal/core/ports"
+	"url-shortener/internal/core/services"
+	"url-shortener/internal/infrastructure/auth"
 	"url-shortener/internal/infrastructure/cache"
 	"url-shortener/internal/infrastructure/database"
+	"url-shortener/internal/infrastructure/database/repositories"
+	"url-shortener/internal/infrastructure/geolocation"
+	"url-shortener/internal/infrastructure/notification"
+	"url-shortener/internal/infrastructure/qrbatch"
+	"url-shortener/internal/infrastructure/qrcode"
+	"url-shortener/internal/infrastructure/queue"
+	"url-shortener/internal/infrastructure/rollup"
+	"url-shortener/internal/infrastructure/useragent"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 )
 
 func main() {
@@ -32,9 +47,16 @@ func main() {
 	}
 	defer db.Close()
 
-	// Ensure database is migrated
-	if err := db.AutoMigrate(); err != nil {
-		log.Printf("Warning: Failed to run auto migrations: %v", err)
+	// The schema is migrated with cmd/migrate; syncing it from the models is a
+	// development shortcut only
+	if cfg.Database.AutoMigrate {
+		if cfg.IsDevelopment() {
+			if err := db.AutoMigrate(); err != nil {
+				log.Printf("Warning: Failed to run auto migrations: %v", err)
+			}
+		} else {
+			log.Printf("Warning: DATABASE_AUTO_MIGRATE is ignored outside development, run cmd/migrate instead")
+		}
 	}
 
 	// Connect to Redis
@@ -48,87 +70,126 @@ func main() {
 	// Create cache service
 	cacheService := cache.NewCacheService(redisClient)
 
-	// Setup router
-	r := chi.NewRouter()
+	// Repositories
+	userRepo := repositories.NewUserRepository(db.DB)
+	urlRepo := repositories.NewURLRepository(db.DB)
+	clickRepo := repositories.NewClickRepository(db.DB)
+	apiKeyRepo := repositories.NewAPIKeyRepository(db.DB)
+	sessionRepo := repositories.NewSessionRepository(db.DB)
+	recoveryCodeRepo := repositories.NewRecoveryCodeRepository(db.DB)
+	identityRepo := repositories.NewUserIdentityRepository(db.DB)
+	workspaceRepo := repositories.NewWorkspaceRepository(db.DB)
+	auditRepo := repositories.NewAuditRepository(db.DB)
+	qrBatchRepo := repositories.NewQRBatchRepository(db.DB)
+
+	// IP geolocation is optional; clicks are recorded without a location when it is off.
+	// Lookups happen in the click batch writers, off the redirect path, except for links
+	// with country rules and in sync mode, which has no batch writers.
+	geoService, err := geolocation.NewGeolocationService(cfg.External)
+	if err != nil {
+		log.Fatalf("Failed to load geolocation database: %v", err)
+	}
+	if geoService == nil {
+		log.Println("IP geolocation disabled: set GEOLOCATION_DB_PATH or GEOLOCATION_API_KEY to enable it")
+	}
+
+	// Click ingestion
+	clickQueue, err := newClickQueue(cfg.Clicks, redisClient, services.NewClickBatchWriter(clickRepo, cacheService, geoService))
+	if err != nil {
+		log.Fatalf("Failed to start click ingestion: %v", err)
+	}
+
+	// Click rollups; with several instances, enable the aggregator on one of them
+	var rollupAggregator ports.ClickRollupAggregator
+	if cfg.Rollups.Enabled {
+		rollupAggregator = rollup.NewAggregator(repositories.NewClickRollupRepository(db.DB), cfg.Rollups)
+		rollupAggregator.Start()
+	}
+
+	// Email is logged instead of sent until SMTP is configured
+	notificationService, err := notification.NewNotificationService(cfg.Mail, cfg.App.FrontendURL)
+	if err != nil {
+		log.Fatalf("Failed to configure email: %v", err)
+	}
+	if cfg.Mail.SMTPHost == "" {
+		log.Println("SMTP not configured: emails such as password resets are written to the log")
+	}
+
+	// Single sign-on is optional; users sign in with a password when it is off
+	oidcProvider := auth.NewOIDCProvider(cfg.OIDC)
+	if oidcProvider == nil {
+		log.Println("Single sign-on disabled: set OIDC_ISSUER_URL and OIDC_CLIENT_ID to enable it")
+	}
+
+	// Services
+	qrProvider := qrcode.NewProvider()
+	jwtService, err := auth.NewJWTService(cfg.JWT)
+	if err != nil {
+		log.Fatalf("Failed to load token signing keys: %v", err)
+	}
+	authService := services.NewAuthService(userRepo, sessionRepo, recoveryCodeRepo, identityRepo, auditRepo, cacheService, jwtService, cfg, notificationService, qrProvider, oidcProvider)
+	urlService := services.NewURLService(urlRepo, clickRepo, cacheService, cfg, clickQueue, workspaceRepo, auditRepo)
+	analyticsService := services.NewAnalyticsService(urlRepo, clickRepo, userRepo, cacheService, cfg, workspaceRepo)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo)
+	adminService := services.NewAdminService(userRepo, urlRepo, sessionRepo, auditRepo)
+	auditService := services.NewAuditService(auditRepo)
+	qrService := services.NewQRService(urlRepo, cfg, qrProvider, workspaceRepo, qrBatchRepo)
+	workspaceService := services.NewWorkspaceService(workspaceRepo, userRepo, urlRepo, notificationService)
+	userAgentParser := useragent.NewParser()
+
+	// Bulk QR code batches are rendered in the background; with several instances,
+	// enabling the worker on more than one only spreads the load
+	var qrBatchWorker ports.QRBatchWorker
+	if cfg.QRBatch.WorkerEnabled {
+		qrBatchWorker = qrbatch.NewWorker(qrService, cfg.QRBatch)
+		qrBatchWorker.Start()
+	}
 
 	// Middleware
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Compress(5))
-	r.Use(middleware.Timeout(60 * time.Second))
-
-	// Health check endpoint
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		// Check database health
-		dbErr := db.Health()
-		
-		// Check Redis health
-		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-		defer cancel()
-		redisErr := cacheService.Ping(ctx)
-		
-		// Determine overall health
-		if dbErr != nil || redisErr != nil {
-			w.WriteHeader(http.StatusServiceUnavailable)
-			status := map[string]string{
-				"status": "unhealthy",
-			}
-			if dbErr != nil {
-				status["database"] = "down"
-			} else {
-				status["database"] = "up"
-			}
-			if redisErr != nil {
-				status["redis"] = "down"
-			} else {
-				status["redis"] = "up"
-			}
-			w.Header().Set("Content-Type", "application/json")
-			fmt.Fprintf(w, `{"status":"%s","database":"%s","redis":"%s"}`, 
-				status["status"], status["database"], status["redis"])
-			return
-		}
-		
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{"status":"healthy","database":"up","redis":"up"}`))
+	corsConfig := middleware.ProductionCORSConfig(cfg.CORS.AllowedOrigins)
+	corsConfig.AllowedMethods = cfg.CORS.AllowedMethods
+	corsConfig.AllowedHeaders = cfg.CORS.AllowedHeaders
+
+	loggingMiddleware := middleware.NewLoggingMiddleware(&middleware.LoggingConfig{
+		SkipPaths: []string{"/health", "/favicon.ico"},
 	})
 
+	// Setup router
+	builder := routes.NewRouterBuilder().
+		WithAuthHandler(handlers.NewAuthHandler(authService, userAgentParser)).
+		WithURLHandler(handlers.NewURLHandler(urlService, analyticsService, userAgentParser, geoService, cfg.Clicks.Mode == "sync")).
+		WithAnalyticsHandler(handlers.NewAnalyticsHandler(analyticsService)).
+		WithQRHandler(handlers.NewQRHandler(qrService)).
+		WithAPIKeyHandler(handlers.NewAPIKeyHandler(apiKeyService)).
+		WithAdminHandler(handlers.NewAdminHandler(adminService)).
+		WithWorkspaceHandler(handlers.NewWorkspaceHandler(workspaceService)).
+		WithAuditHandler(handlers.NewAuditHandler(auditService)).
+		WithJWKSHandler(handlers.NewJWKSHandler(jwtService)).
+		WithAuthMiddleware(middleware.NewAuthMiddleware(jwtService, userRepo, sessionRepo, cacheService, apiKeyService)).
+		WithCORSMiddleware(middleware.NewCORSMiddleware(corsConfig)).
+		WithLoggingMiddleware(loggingMiddleware).
+		WithCORS(true, cfg.CORS.AllowedOrigins...).
+		WithLogging(true).
+		WithEmailVerification(cfg.Security.RequireEmailVerification).
+		WithTwoFactorEnforcement(cfg.Security.RequireTwoFactor).
+		WithHealthHandler(healthHandler(db, cacheService))
+
+	// Rate limiting is backed by the cache service
+	if cfg.Rate.Enabled {
+		builder = builder.WithCacheService(cacheService)
+	}
+
 	// Debug endpoints (development only)
 	if cfg.IsDevelopment() {
-		r.Get("/debug/db-stats", func(w http.ResponseWriter, r *http.Request) {
-			stats := db.GetStats()
-			w.Header().Set("Content-Type", "application/json")
-			fmt.Fprintf(w, "%+v", stats)
-		})
-		
-		r.Get("/debug/redis-info", func(w http.ResponseWriter, r *http.Request) {
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-			
-			info, err := cacheService.Info(ctx)
-			if err != nil {
-				w.WriteHeader(http.StatusServiceUnavailable)
-				w.Write([]byte(`{"error":"failed to get redis info"}`))
-				return
-			}
-			
-			w.Header().Set("Content-Type", "text/plain")
-			w.Write([]byte(info))
-		})
+		builder = builder.WithDebugHandler(debugRouter(db, cacheService, clickQueue))
 	}
 
-	// Default route
-	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{"message":"URL Shortener API","version":"1.0.0"}`))
-	})
+	handler := builder.Build().SetupRoutes()
 
 	// Create server
 	server := &http.Server{
 		Addr:         cfg.GetServerAddress(),
-		Handler:      r,
+		Handler:      handler,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -156,5 +217,103 @@ func main() {
 		log.Printf("Server forced to shutdown: %v", err)
 	}
 
+	// Write out clicks still waiting in the queue
+	if clickQueue != nil {
+		if err := clickQueue.Close(ctx); err != nil {
+			log.Printf("Failed to drain click queue: %v", err)
+		}
+	}
+
+	if rollupAggregator != nil {
+		if err := rollupAggregator.Close(ctx); err != nil {
+			log.Printf("Failed to stop click rollup aggregator: %v", err)
+		}
+	}
+
+	if qrBatchWorker != nil {
+		if err := qrBatchWorker.Close(ctx); err != nil {
+			log.Printf("Failed to stop QR batch worker: %v", err)
+		}
+	}
+
 	log.Println("Server exited")
-}
\ No newline at end of file
+}
+
+// healthHandler reports the status of the database and Redis connections
+func healthHandler(db *database.Database, cacheService ports.CacheService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Check database health
+		dbErr := db.Health()
+
+		// Check Redis health
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+		redisErr := cacheService.Ping(ctx)
+
+		status, dbStatus, redisStatus := "healthy", "up", "up"
+		if dbErr != nil {
+			status, dbStatus = "unhealthy", "down"
+		}
+		if redisErr != nil {
+			status, redisStatus = "unhealthy", "down"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if status != "healthy" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		fmt.Fprintf(w, `{"status":"%s","database":"%s","redis":"%s"}`, status, dbStatus, redisStatus)
+	}
+}
+
+// newClickQueue builds the queue selected by the ingestion mode. A nil queue makes
+// URLService record clicks synchronously.
+func newClickQueue(cfg config.ClickIngestionConfig, redisClient *cache.RedisClient, writer ports.ClickBatchWriter) (ports.ClickQueue, error) {
+	switch cfg.Mode {
+	case "sync":
+		return nil, nil
+	case "memory":
+		return queue.NewMemoryClickQueue(writer, cfg), nil
+	case "redis":
+		return queue.NewRedisStreamClickQueue(redisClient, writer, cfg)
+	default:
+		return nil, fmt.Errorf("unknown click ingestion mode %q", cfg.Mode)
+	}
+}
+
+// debugRouter exposes connection pool, Redis and click queue diagnostics
+func debugRouter(db *database.Database, cacheService ports.CacheService, clickQueue ports.ClickQueue) http.Handler {
+	r := chi.NewRouter()
+
+	r.Get("/click-queue", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if clickQueue == nil {
+			w.Write([]byte(`{"backend":"sync"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(clickQueue.Stats())
+	})
+
+	r.Get("/db-stats", func(w http.ResponseWriter, r *http.Request) {
+		stats := db.GetStats()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, "%+v", stats)
+	})
+
+	r.Get("/redis-info", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		info, err := cacheService.Info(ctx)
+		if err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"failed to get redis info"}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(info))
+	})
+
+	return r
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"url-shortener/internal/api/middleware"
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+// AdminHandler serves the staff API. Routes decide which roles may call each handler.
+type AdminHandler struct {
+	adminService ports.AdminService
+}
+
+func NewAdminHandler(adminService ports.AdminService) *AdminHandler {
+	return &AdminHandler{
+		adminService: adminService,
+	}
+}
+
+// ListUsers lists accounts, optionally filtered by ?q=, ?role= and ?active=
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := domain.UserFilter{
+		Query: query.Get("q"),
+		Role:  query.Get("role"),
+	}
+	if activeStr := query.Get("active"); activeStr != "" {
+		active, err := strconv.ParseBool(activeStr)
+		if err != nil {
+			h.writeErrorResponse(w, "Invalid active filter", http.StatusBadRequest)
+			return
+		}
+		filter.Active = &active
+	}
+
+	offset, limit := h.parsePaginationParams(r)
+
+	users, total, err := h.adminService.ListUsers(r.Context(), filter, offset, limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidInput), err == domain.ErrInvalidRole:
+			h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	responses := make([]*domain.AdminUserResponse, len(users))
+	for i, user := range users {
+		responses[i] = user.ToAdminResponse()
+	}
+
+	h.writeJSONResponse(w, map[string]interface{}{
+		"users":  responses,
+		"total":  total,
+		"offset": offset,
+		"limit":  limit,
+	}, http.StatusOK)
+}
+
+// GetUser returns one account
+func (h *AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.adminService.GetUser(r.Context(), userID)
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, user.ToAdminResponse(), http.StatusOK)
+}
+
+// GetUserURLs lists the links owned by an account
+func (h *AdminHandler) GetUserURLs(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	offset, limit := h.parsePaginationParams(r)
+
+	urls, total, err := h.adminService.GetUserURLs(r.Context(), userID, offset, limit)
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, map[string]interface{}{
+		"urls":   urls,
+		"total":  total,
+		"offset": offset,
+		"limit":  limit,
+	}, http.StatusOK)
+}
+
+// UpdateUser changes an account's role or two-factor requirement
+func (h *AdminHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	var req domain.AdminUpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.adminService.UpdateUser(r.Context(), middleware.GetUserIDFromContext(r.Context()), userID, req)
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, user.ToAdminResponse(), http.StatusOK)
+}
+
+// DeactivateUser blocks an account from signing in
+func (h *AdminHandler) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.adminService.DeactivateUser(r.Context(), middleware.GetUserIDFromContext(r.Context()), userID)
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, user.ToAdminResponse(), http.StatusOK)
+}
+
+// ReactivateUser lets a deactivated account sign in again
+func (h *AdminHandler) ReactivateUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.adminService.ReactivateUser(r.Context(), middleware.GetUserIDFromContext(r.Context()), userID)
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, user.ToAdminResponse(), http.StatusOK)
+}
+
+// Helper methods
+
+func (h *AdminHandler) userIDParam(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	userID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		h.writeErrorResponse(w, "Invalid user ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(userID), true
+}
+
+func (h *AdminHandler) writeServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case err == domain.ErrUserNotFound:
+		h.writeErrorResponse(w, "User not found", http.StatusNotFound)
+	case err == domain.ErrCannotModifySelf:
+		h.writeErrorResponse(w, "You cannot change your own role or status", http.StatusConflict)
+	case errors.Is(err, domain.ErrInvalidInput), err == domain.ErrInvalidRole:
+		h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+	default:
+		h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *AdminHandler) parsePaginationParams(r *http.Request) (offset, limit int) {
+	offset = 0
+	limit = 20 // default limit
+
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 100 {
+			limit = parsedLimit
+		}
+	}
+
+	return offset, limit
+}
+
+func (h *AdminHandler) writeJSONResponse(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "Failed to encode response"}`))
+	}
+}
+
+func (h *AdminHandler) writeErrorResponse(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	response := map[string]string{"error": message}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		w.Write([]byte(`{"error": "Internal server error"}`))
+	}
+}
@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -60,7 +62,7 @@ func (h *AnalyticsHandler) GetURLAnalytics(w http.ResponseWriter, r *http.Reques
 		switch err {
 		case domain.ErrUnauthorized:
 			h.writeErrorResponse(w, "Access denied", http.StatusForbidden)
-		case domain.ErrURLNotFound:
+		case domain.ErrURLNotFound, domain.ErrShortURLNotFound:
 			h.writeErrorResponse(w, "URL not found", http.StatusNotFound)
 		default:
 			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
@@ -142,7 +144,7 @@ func (h *AnalyticsHandler) GetClickTimeline(w http.ResponseWriter, r *http.Reque
 		switch err {
 		case domain.ErrUnauthorized:
 			h.writeErrorResponse(w, "Access denied", http.StatusForbidden)
-		case domain.ErrURLNotFound:
+		case domain.ErrURLNotFound, domain.ErrShortURLNotFound:
 			h.writeErrorResponse(w, "URL not found", http.StatusNotFound)
 		default:
 			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
@@ -174,7 +176,7 @@ func (h *AnalyticsHandler) GetGeographicStats(w http.ResponseWriter, r *http.Req
 		switch err {
 		case domain.ErrUnauthorized:
 			h.writeErrorResponse(w, "Access denied", http.StatusForbidden)
-		case domain.ErrURLNotFound:
+		case domain.ErrURLNotFound, domain.ErrShortURLNotFound:
 			h.writeErrorResponse(w, "URL not found", http.StatusNotFound)
 		default:
 			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
@@ -206,7 +208,7 @@ func (h *AnalyticsHandler) GetDeviceStats(w http.ResponseWriter, r *http.Request
 		switch err {
 		case domain.ErrUnauthorized:
 			h.writeErrorResponse(w, "Access denied", http.StatusForbidden)
-		case domain.ErrURLNotFound:
+		case domain.ErrURLNotFound, domain.ErrShortURLNotFound:
 			h.writeErrorResponse(w, "URL not found", http.StatusNotFound)
 		default:
 			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
@@ -238,7 +240,7 @@ func (h *AnalyticsHandler) GetReferrerStats(w http.ResponseWriter, r *http.Reque
 		switch err {
 		case domain.ErrUnauthorized:
 			h.writeErrorResponse(w, "Access denied", http.StatusForbidden)
-		case domain.ErrURLNotFound:
+		case domain.ErrURLNotFound, domain.ErrShortURLNotFound:
 			h.writeErrorResponse(w, "URL not found", http.StatusNotFound)
 		default:
 			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
@@ -253,10 +255,41 @@ func (h *AnalyticsHandler) GetReferrerStats(w http.ResponseWriter, r *http.Reque
 	h.writeJSONResponse(w, response, http.StatusOK)
 }
 
-// GetGlobalStats handles getting global platform statistics (admin only)
+// GetVariantStats handles getting per-variant statistics for an A/B split URL
+func (h *AnalyticsHandler) GetVariantStats(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse URL ID
+	urlIDStr := chi.URLParam(r, "id")
+	urlID, err := strconv.ParseUint(urlIDStr, 10, 32)
+	if err != nil {
+		h.writeErrorResponse(w, "Invalid URL ID", http.StatusBadRequest)
+		return
+	}
+
+	variantStats, err := h.analyticsService.GetVariantStats(r.Context(), uint(urlID), userID)
+	if err != nil {
+		switch err {
+		case domain.ErrUnauthorized:
+			h.writeErrorResponse(w, "Access denied", http.StatusForbidden)
+		case domain.ErrURLNotFound, domain.ErrShortURLNotFound:
+			h.writeErrorResponse(w, "URL not found", http.StatusNotFound)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeJSONResponse(w, variantStats, http.StatusOK)
+}
+
+// GetGlobalStats handles getting global platform statistics. It is only routed
+// for staff, under /admin/stats.
 func (h *AnalyticsHandler) GetGlobalStats(w http.ResponseWriter, r *http.Request) {
-	// For now, allow any authenticated user to see global stats
-	// In production, you'd want to check for admin privileges
 	userID := middleware.GetUserIDFromContext(r.Context())
 	if userID == 0 {
 		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
@@ -272,7 +305,8 @@ func (h *AnalyticsHandler) GetGlobalStats(w http.ResponseWriter, r *http.Request
 	h.writeJSONResponse(w, stats, http.StatusOK)
 }
 
-// ExportAnalytics handles exporting analytics data
+// ExportAnalytics streams click-level analytics as CSV, a JSON array or JSON Lines.
+// Repeat url_id, or pass a comma-separated list, to export only those URLs.
 func (h *AnalyticsHandler) ExportAnalytics(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserIDFromContext(r.Context())
 	if userID == 0 {
@@ -283,20 +317,12 @@ func (h *AnalyticsHandler) ExportAnalytics(w http.ResponseWriter, r *http.Reques
 	// Parse format parameter
 	format := r.URL.Query().Get("format")
 	if format == "" {
-		format = "json" // default format
+		format = domain.ExportFormatJSON // default format
 	}
 
-	// Validate format
-	validFormats := []string{"json", "csv"}
-	isValidFormat := false
-	for _, validFormat := range validFormats {
-		if format == validFormat {
-			isValidFormat = true
-			break
-		}
-	}
-	if !isValidFormat {
-		h.writeErrorResponse(w, "Invalid format. Valid values: json, csv", http.StatusBadRequest)
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		h.writeErrorResponse(w, "Invalid format. Valid values: csv, json, jsonl", http.StatusBadRequest)
 		return
 	}
 
@@ -307,25 +333,84 @@ func (h *AnalyticsHandler) ExportAnalytics(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Export analytics
-	data, err := h.analyticsService.ExportAnalytics(r.Context(), userID, format, dateRange)
-	if err != nil {
-		h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
-		return
+	// Parse URL filters
+	var urlIDs []uint
+	for _, value := range r.URL.Query()["url_id"] {
+		for _, idStr := range strings.Split(value, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(idStr), 10, 32)
+			if err != nil || id == 0 {
+				h.writeErrorResponse(w, "Invalid URL ID", http.StatusBadRequest)
+				return
+			}
+			urlIDs = append(urlIDs, uint(id))
+		}
+	}
+
+	req := domain.ExportRequest{
+		Format:      format,
+		DateRange:   dateRange,
+		ShortURLIDs: urlIDs,
+	}
+
+	// Large exports outlive the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	out := &exportResponseWriter{
+		w:           w,
+		contentType: contentType,
+		filename:    fmt.Sprintf("analytics-%s-%s.%s", dateRange.StartDate, dateRange.EndDate, format),
+	}
+
+	if err := h.analyticsService.ExportAnalytics(r.Context(), userID, req, out); err != nil {
+		if out.started {
+			// The status line is gone; abort so the client sees a truncated download
+			// instead of a file that looks complete
+			panic(http.ErrAbortHandler)
+		}
+
+		switch err {
+		case domain.ErrInvalidExportFormat, domain.ErrInvalidDateRange:
+			h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		case domain.ErrUnauthorized:
+			h.writeErrorResponse(w, "Access denied", http.StatusForbidden)
+		case domain.ErrURLNotFound, domain.ErrShortURLNotFound:
+			h.writeErrorResponse(w, "URL not found", http.StatusNotFound)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
 	}
+}
+
+var exportContentTypes = map[string]string{
+	domain.ExportFormatCSV:       "text/csv; charset=utf-8",
+	domain.ExportFormatJSON:      "application/json",
+	domain.ExportFormatJSONLines: "application/x-ndjson",
+}
 
-	// Set appropriate content type and headers
-	switch format {
-	case "csv":
-		w.Header().Set("Content-Type", "text/csv")
-		w.Header().Set("Content-Disposition", "attachment; filename=analytics.csv")
-	case "json":
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Content-Disposition", "attachment; filename=analytics.json")
+// exportResponseWriter sends the status line and download headers on the first
+// write, so errors raised before any data is produced can still get a proper
+// status code. Each write is flushed to the client straight away.
+type exportResponseWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (e *exportResponseWriter) Write(p []byte) (int, error) {
+	if !e.started {
+		e.started = true
+		e.w.Header().Set("Content-Type", e.contentType)
+		e.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", e.filename))
+		e.w.WriteHeader(http.StatusOK)
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(data)
+	n, err := e.w.Write(p)
+	if err == nil {
+		// Not every writer can flush; the data still arrives, just later
+		http.NewResponseController(e.w).Flush()
+	}
+	return n, err
 }
 
 // Helper methods
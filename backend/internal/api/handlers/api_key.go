@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"url-shortener/internal/api/middleware"
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+type APIKeyHandler struct {
+	apiKeyService ports.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService ports.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// CreateAPIKey creates a key for the signed-in user. The key is only ever returned
+// in this response.
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req domain.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.apiKeyService.CreateAPIKey(r.Context(), userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidInput), errors.Is(err, domain.ErrInvalidScope):
+			h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		case err == domain.ErrAPIKeyLimitReached:
+			h.writeErrorResponse(w, "API key limit reached, revoke an unused key first", http.StatusConflict)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSONResponse(w, created, http.StatusCreated)
+}
+
+// ListAPIKeys lists the signed-in user's active keys
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := h.apiKeyService.ListAPIKeys(r.Context(), userID)
+	if err != nil {
+		h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSONResponse(w, map[string]interface{}{"api_keys": keys}, http.StatusOK)
+}
+
+// RenameAPIKey changes the name of a key
+func (h *APIKeyHandler) RenameAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	keyID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		h.writeErrorResponse(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	var req domain.UpdateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	apiKey, err := h.apiKeyService.RenameAPIKey(r.Context(), uint(keyID), userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidInput):
+			h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		case err == domain.ErrAPIKeyNotFound:
+			h.writeErrorResponse(w, "API key not found", http.StatusNotFound)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeJSONResponse(w, apiKey, http.StatusOK)
+}
+
+// RevokeAPIKey disables a key immediately
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	keyID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		h.writeErrorResponse(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.apiKeyService.RevokeAPIKey(r.Context(), uint(keyID), userID); err != nil {
+		switch err {
+		case domain.ErrAPIKeyNotFound:
+			h.writeErrorResponse(w, "API key not found", http.StatusNotFound)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeJSONResponse(w, map[string]string{"message": "API key revoked"}, http.StatusOK)
+}
+
+func (h *APIKeyHandler) writeJSONResponse(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "Failed to encode response"}`))
+	}
+}
+
+func (h *APIKeyHandler) writeErrorResponse(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	response := map[string]string{"error": message}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		w.Write([]byte(`{"error": "Internal server error"}`))
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+// AuditHandler serves the audit log to admins
+type AuditHandler struct {
+	auditService ports.AuditService
+}
+
+func NewAuditHandler(auditService ports.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// ListEvents lists audit events, newest first, optionally filtered by
+// ?actor_id=, ?action=, ?target_type=, ?target_id= and an RFC 3339 ?from= and ?to=
+func (h *AuditHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := h.parseFilter(r)
+	if err != nil {
+		h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	offset, limit := h.parsePaginationParams(r)
+
+	events, total, err := h.auditService.ListEvents(r.Context(), filter, offset, limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidInput):
+			h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeJSONResponse(w, map[string]interface{}{
+		"events": events,
+		"total":  total,
+		"offset": offset,
+		"limit":  limit,
+	}, http.StatusOK)
+}
+
+// Helper methods
+
+func (h *AuditHandler) parseFilter(r *http.Request) (domain.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := domain.AuditFilter{
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+	}
+
+	if value := query.Get("actor_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return filter, errors.New("Invalid actor_id filter")
+		}
+		actorID := uint(id)
+		filter.ActorID = &actorID
+	}
+	if value := query.Get("target_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return filter, errors.New("Invalid target_id filter")
+		}
+		targetID := uint(id)
+		filter.TargetID = &targetID
+	}
+	if value := query.Get("from"); value != "" {
+		from, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, errors.New("Invalid from filter, expected RFC 3339")
+		}
+		filter.From = &from
+	}
+	if value := query.Get("to"); value != "" {
+		to, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, errors.New("Invalid to filter, expected RFC 3339")
+		}
+		filter.To = &to
+	}
+
+	return filter, nil
+}
+
+func (h *AuditHandler) parsePaginationParams(r *http.Request) (offset, limit int) {
+	offset = 0
+	limit = 20 // default limit
+
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 100 {
+			limit = parsedLimit
+		}
+	}
+
+	return offset, limit
+}
+
+func (h *AuditHandler) writeJSONResponse(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "Failed to encode response"}`))
+	}
+}
+
+func (h *AuditHandler) writeErrorResponse(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	response := map[string]string{"error": message}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		w.Write([]byte(`{"error": "Internal server error"}`))
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"url-shortener/internal/core/domain"
+)
+
+type MockAuditService struct {
+	mock.Mock
+}
+
+func (m *MockAuditService) ListEvents(ctx context.Context, filter domain.AuditFilter, offset, limit int) ([]*domain.AuditEvent, int64, error) {
+	args := m.Called(ctx, filter, offset, limit)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]*domain.AuditEvent), args.Get(1).(int64), args.Error(2)
+}
+
+func TestAuditHandler_ListEvents(t *testing.T) {
+	service := &MockAuditService{}
+	handler := NewAuditHandler(service)
+
+	actorID, targetID := uint(1), uint(7)
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	expected := domain.AuditFilter{
+		ActorID:    &actorID,
+		Action:     domain.AuditURLUpdated,
+		TargetType: domain.AuditTargetURL,
+		TargetID:   &targetID,
+		From:       &from,
+	}
+	events := []*domain.AuditEvent{{ID: 9, ActorID: &actorID, Action: domain.AuditURLUpdated, TargetType: domain.AuditTargetURL, TargetID: targetID}}
+	service.On("ListEvents", mock.Anything, expected, 40, 20).Return(events, int64(41), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/audit?actor_id=1&action=url.updated&target_type=url&target_id=7&from=2024-05-01T00:00:00Z&offset=40", nil)
+	w := httptest.NewRecorder()
+	handler.ListEvents(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Events []*domain.AuditEvent `json:"events"`
+		Total  int64                `json:"total"`
+	}
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+	assert.Equal(t, int64(41), body.Total)
+	assert.Len(t, body.Events, 1)
+	service.AssertExpectations(t)
+}
+
+func TestAuditHandler_ListEvents_InvalidFilter(t *testing.T) {
+	service := &MockAuditService{}
+	handler := NewAuditHandler(service)
+
+	for _, query := range []string{"actor_id=abc", "target_id=-1", "from=yesterday", "to=2024-05-01"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/audit?"+query, nil)
+		w := httptest.NewRecorder()
+		handler.ListEvents(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	// Filters the service rejects are the caller's fault too
+	service.On("ListEvents", mock.Anything, mock.Anything, 0, 20).Return(nil, int64(0), domain.ErrInvalidInput)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/audit?target_type=workspace", nil)
+	w := httptest.NewRecorder()
+	handler.ListEvents(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -1,8 +1,14 @@
 package handlers
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"io"
 	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
 
 	"url-shortener/internal/api/middleware"
 	"url-shortener/internal/core/domain"
@@ -11,11 +17,13 @@ import (
 
 type AuthHandler struct {
 	authService ports.AuthService
+	uaParser    ports.UserAgentParser
 }
 
-func NewAuthHandler(authService ports.AuthService) *AuthHandler {
+func NewAuthHandler(authService ports.AuthService, uaParser ports.UserAgentParser) *AuthHandler {
 	return &AuthHandler{
 		authService: authService,
+		uaParser:    uaParser,
 	}
 }
 
@@ -34,7 +42,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Register user
-	response, err := h.authService.Register(r.Context(), req)
+	response, err := h.authService.Register(r.Context(), req, h.sessionMetadata(r))
 	if err != nil {
 		switch err {
 		case domain.ErrUserAlreadyExists:
@@ -65,7 +73,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Authenticate user
-	response, err := h.authService.Login(r.Context(), req)
+	response, err := h.authService.Login(r.Context(), req, h.sessionMetadata(r))
 	if err != nil {
 		switch err {
 		case domain.ErrInvalidCredentials:
@@ -78,9 +86,145 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The password was right but the account needs a second factor
+	if response.MFARequired {
+		h.writeJSONResponse(w, map[string]interface{}{
+			"mfa_required": true,
+			"mfa_token":    response.MFAToken,
+			"expires_in":   response.ExpiresIn,
+		}, http.StatusOK)
+		return
+	}
+
+	h.writeJSONResponse(w, response, http.StatusOK)
+}
+
+// LoginTwoFactor completes a sign-in with an authenticator or recovery code
+func (h *AuthHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req domain.MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	if err := req.Validate(); err != nil {
+		h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.authService.CompleteMFALogin(r.Context(), req, h.sessionMetadata(r))
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidToken:
+			h.writeErrorResponse(w, "Invalid or expired two-factor challenge, please sign in again", http.StatusUnauthorized)
+		case domain.ErrInvalidTwoFactorCode:
+			h.writeErrorResponse(w, "Invalid two-factor code", http.StatusUnauthorized)
+		case domain.ErrTooManyRequests:
+			h.writeErrorResponse(w, "Too many invalid codes, please sign in again", http.StatusTooManyRequests)
+		case domain.ErrUserInactive:
+			h.writeErrorResponse(w, "Account is inactive", http.StatusForbidden)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
 	h.writeJSONResponse(w, response, http.StatusOK)
 }
 
+// oidcStateCookie ties a single sign-on to the browser that started it, so a
+// callback carrying someone else's code and state is refused
+const oidcStateCookie = "oidc_state"
+
+// StartOIDCLogin returns the identity provider URL for single sign-on and keeps
+// the state in a cookie for the callback
+func (h *AuthHandler) StartOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	response, err := h.authService.StartOIDCLogin(r.Context())
+	if err != nil {
+		switch err {
+		case domain.ErrOIDCNotConfigured:
+			h.writeErrorResponse(w, "Single sign-on is not configured", http.StatusNotFound)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.setOIDCStateCookie(w, r, response.State, response.ExpiresIn)
+	h.writeJSONResponse(w, response, http.StatusOK)
+}
+
+// CompleteOIDCLogin signs in with the code and state from the identity provider's redirect
+func (h *AuthHandler) CompleteOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	var req domain.OIDCCallbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	if err := req.Validate(); err != nil {
+		h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The state must come back to the browser it was issued to
+	cookie, err := r.Cookie(oidcStateCookie)
+	h.setOIDCStateCookie(w, r, "", -1)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(req.State)) != 1 {
+		h.writeErrorResponse(w, "Invalid or expired sign-in attempt, please try again", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.authService.CompleteOIDCLogin(r.Context(), req, h.sessionMetadata(r))
+	if err != nil {
+		switch err {
+		case domain.ErrOIDCNotConfigured:
+			h.writeErrorResponse(w, "Single sign-on is not configured", http.StatusNotFound)
+		case domain.ErrOIDCStateInvalid:
+			h.writeErrorResponse(w, "Invalid or expired sign-in attempt, please try again", http.StatusBadRequest)
+		case domain.ErrOIDCLoginFailed:
+			h.writeErrorResponse(w, "Single sign-on failed", http.StatusUnauthorized)
+		case domain.ErrOIDCEmailNotVerified:
+			h.writeErrorResponse(w, "Your identity provider has not verified your email address", http.StatusForbidden)
+		case domain.ErrOIDCAccountUnverified:
+			h.writeErrorResponse(w, "An account with this email address exists; verify the address from your welcome email, then sign in again", http.StatusConflict)
+		case domain.ErrUserInactive:
+			h.writeErrorResponse(w, "Account is inactive", http.StatusForbidden)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// The account needs a second factor; finish with LoginTwoFactor
+	if response.MFARequired {
+		h.writeJSONResponse(w, map[string]interface{}{
+			"mfa_required": true,
+			"mfa_token":    response.MFAToken,
+			"expires_in":   response.ExpiresIn,
+		}, http.StatusOK)
+		return
+	}
+
+	h.writeJSONResponse(w, response, http.StatusOK)
+}
+
+// setOIDCStateCookie stores the state for maxAge seconds, or clears it when maxAge
+// is negative. The callback route sits next to the login route.
+func (h *AuthHandler) setOIDCStateCookie(w http.ResponseWriter, r *http.Request, state string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     path.Dir(r.URL.Path),
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   middleware.IsHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
 // RefreshToken handles token refresh
 func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	var req struct {
@@ -97,11 +241,15 @@ func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Refresh token
-	response, err := h.authService.RefreshToken(r.Context(), req.RefreshToken)
+	response, err := h.authService.RefreshToken(r.Context(), req.RefreshToken, h.sessionMetadata(r))
 	if err != nil {
 		switch err {
-		case domain.ErrInvalidCredentials:
+		case domain.ErrInvalidToken, domain.ErrInvalidCredentials:
 			h.writeErrorResponse(w, "Invalid refresh token", http.StatusUnauthorized)
+		case domain.ErrRefreshTokenReused:
+			h.writeErrorResponse(w, "Refresh token has already been used, please sign in again", http.StatusUnauthorized)
+		case domain.ErrUserInactive:
+			h.writeErrorResponse(w, "User account is inactive", http.StatusForbidden)
 		default:
 			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
 		}
@@ -119,8 +267,17 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The refresh token identifies which session to end; the body is optional
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
 	// Logout user
-	if err := h.authService.Logout(r.Context(), userID); err != nil {
+	if err := h.authService.Logout(r.Context(), userID, req.RefreshToken); err != nil {
 		h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -128,6 +285,66 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	h.writeJSONResponse(w, map[string]string{"message": "Logged out successfully"}, http.StatusOK)
 }
 
+// ListSessions lists the devices the user is signed in on
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := h.authService.ListSessions(r.Context(), userID)
+	if err != nil {
+		h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSONResponse(w, map[string]interface{}{"sessions": sessions}, http.StatusOK)
+}
+
+// RevokeSession signs one device out
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	sessionID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		h.writeErrorResponse(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.authService.RevokeSession(r.Context(), userID, uint(sessionID)); err != nil {
+		switch err {
+		case domain.ErrSessionNotFound:
+			h.writeErrorResponse(w, "Session not found", http.StatusNotFound)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeJSONResponse(w, map[string]string{"message": "Session revoked"}, http.StatusOK)
+}
+
+// RevokeAllSessions signs every device out, including the current one
+func (h *AuthHandler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.authService.RevokeAllSessions(r.Context(), userID); err != nil {
+		h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSONResponse(w, map[string]string{"message": "All sessions revoked"}, http.StatusOK)
+}
+
 // GetProfile handles getting user profile
 func (h *AuthHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUserFromContext(r.Context())
@@ -214,7 +431,206 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.writeJSONResponse(w, map[string]string{"message": "Password changed successfully"}, http.StatusOK)
+	h.writeJSONResponse(w, map[string]string{"message": "Password changed successfully, please sign in again"}, http.StatusOK)
+}
+
+// ForgotPassword emails a password reset link. The response is the same whether
+// or not the address belongs to an account.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req domain.PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	if err := req.Validate(); err != nil {
+		h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.authService.RequestPasswordReset(r.Context(), req); err != nil {
+		switch err {
+		case domain.ErrTooManyRequests:
+			h.writeErrorResponse(w, "Too many password reset requests, please try again later", http.StatusTooManyRequests)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeJSONResponse(w, map[string]string{
+		"message": "If an account exists for that email, a password reset link has been sent",
+	}, http.StatusAccepted)
+}
+
+// ResetPassword sets a new password using the token from a reset email
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req domain.PasswordResetConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	if err := req.Validate(); err != nil {
+		h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.authService.ResetPassword(r.Context(), req); err != nil {
+		switch err {
+		case domain.ErrInvalidToken:
+			h.writeErrorResponse(w, "Invalid or expired reset token", http.StatusBadRequest)
+		case domain.ErrUserInactive:
+			h.writeErrorResponse(w, "User account is inactive", http.StatusForbidden)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeJSONResponse(w, map[string]string{"message": "Password has been reset, please sign in again"}, http.StatusOK)
+}
+
+// VerifyEmail confirms an email address using the token from the welcome email
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		h.writeErrorResponse(w, "Verification token is required", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := h.authService.VerifyEmail(r.Context(), token)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidToken:
+			h.writeErrorResponse(w, "Invalid verification token", http.StatusBadRequest)
+		case domain.ErrExpiredToken:
+			h.writeErrorResponse(w, "Verification link has expired, please request a new one", http.StatusGone)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeJSONResponse(w, map[string]interface{}{
+		"message": "Email verified successfully",
+		"user":    profile,
+	}, http.StatusOK)
+}
+
+// ResendVerification emails the signed-in user a new verification link
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.authService.ResendVerificationEmail(r.Context(), userID); err != nil {
+		switch err {
+		case domain.ErrEmailAlreadyVerified:
+			h.writeErrorResponse(w, "Email address is already verified", http.StatusConflict)
+		case domain.ErrTooManyRequests:
+			h.writeErrorResponse(w, "Too many verification emails, please try again later", http.StatusTooManyRequests)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeJSONResponse(w, map[string]string{"message": "Verification email sent"}, http.StatusAccepted)
+}
+
+// SetupTwoFactor generates a new authenticator secret; it takes effect once confirmed
+func (h *AuthHandler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	response, err := h.authService.SetupTwoFactor(r.Context(), userID)
+	if err != nil {
+		switch err {
+		case domain.ErrTwoFactorAlreadyEnabled:
+			h.writeErrorResponse(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeJSONResponse(w, response, http.StatusOK)
+}
+
+// EnableTwoFactor confirms the authenticator and returns the recovery codes
+func (h *AuthHandler) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req domain.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.authService.EnableTwoFactor(r.Context(), userID, req)
+	if err != nil {
+		h.writeTwoFactorError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, response, http.StatusOK)
+}
+
+// DisableTwoFactor turns two-factor off after checking the password and a code
+func (h *AuthHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req domain.DisableTwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.authService.DisableTwoFactor(r.Context(), userID, req); err != nil {
+		h.writeTwoFactorError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, map[string]string{"message": "Two-factor authentication disabled"}, http.StatusOK)
+}
+
+// RegenerateRecoveryCodes replaces every recovery code, used or not
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req domain.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.authService.RegenerateRecoveryCodes(r.Context(), userID, req)
+	if err != nil {
+		h.writeTwoFactorError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, response, http.StatusOK)
 }
 
 // ValidateToken handles token validation for clients
@@ -236,6 +652,44 @@ func (h *AuthHandler) ValidateToken(w http.ResponseWriter, r *http.Request) {
 
 // Helper methods
 
+// sessionMetadata describes the client so users can recognise their sessions
+func (h *AuthHandler) sessionMetadata(r *http.Request) domain.SessionMetadata {
+	userAgent := r.UserAgent()
+	if len(userAgent) > 500 {
+		userAgent = userAgent[:500]
+	}
+
+	meta := domain.SessionMetadata{
+		UserAgent: userAgent,
+		IPAddress: middleware.ClientIPAddress(r),
+	}
+	if h.uaParser != nil && userAgent != "" {
+		info := h.uaParser.Parse(userAgent)
+		meta.Device = info.Browser + " on " + info.OS
+	}
+	return meta
+}
+
+// writeTwoFactorError maps the errors shared by the two-factor management endpoints
+func (h *AuthHandler) writeTwoFactorError(w http.ResponseWriter, err error) {
+	switch err {
+	case domain.ErrInvalidTwoFactorCode:
+		h.writeErrorResponse(w, "Invalid two-factor code", http.StatusBadRequest)
+	case domain.ErrInvalidPassword, domain.ErrInvalidCredentials:
+		h.writeErrorResponse(w, "Password is incorrect", http.StatusBadRequest)
+	case domain.ErrTwoFactorAlreadyEnabled:
+		h.writeErrorResponse(w, "Two-factor authentication is already enabled", http.StatusConflict)
+	case domain.ErrTwoFactorNotEnabled:
+		h.writeErrorResponse(w, "Two-factor authentication is not enabled", http.StatusConflict)
+	case domain.ErrTwoFactorSetupRequired:
+		h.writeErrorResponse(w, "Start two-factor setup first", http.StatusConflict)
+	case domain.ErrTwoFactorRequired:
+		h.writeErrorResponse(w, "Two-factor authentication is required for this account", http.StatusForbidden)
+	default:
+		h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
 func (h *AuthHandler) writeJSONResponse(w http.ResponseWriter, data interface{}, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
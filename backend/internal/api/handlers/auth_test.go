@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -26,7 +27,7 @@ func TestAuthHandlerTestSuite(t *testing.T) {
 
 func (suite *AuthHandlerTestSuite) SetupTest() {
 	suite.mockAuthService = &MockAuthService{}
-	suite.handler = NewAuthHandler(suite.mockAuthService)
+	suite.handler = NewAuthHandler(suite.mockAuthService, nil)
 }
 
 func (suite *AuthHandlerTestSuite) TestRegister_Success() {
@@ -50,7 +51,7 @@ func (suite *AuthHandlerTestSuite) TestRegister_Success() {
 		ExpiresIn:    3600,
 	}
 
-	suite.mockAuthService.On("Register", mock.Anything, req).Return(response, nil)
+	suite.mockAuthService.On("Register", mock.Anything, req, mock.AnythingOfType("domain.SessionMetadata")).Return(response, nil)
 
 	// Create request
 	body, _ := json.Marshal(req)
@@ -81,7 +82,7 @@ func (suite *AuthHandlerTestSuite) TestRegister_UserAlreadyExists() {
 		LastName:  "Doe",
 	}
 
-	suite.mockAuthService.On("Register", mock.Anything, req).Return(nil, domain.ErrUserAlreadyExists)
+	suite.mockAuthService.On("Register", mock.Anything, req, mock.AnythingOfType("domain.SessionMetadata")).Return(nil, domain.ErrUserAlreadyExists)
 
 	// Create request
 	body, _ := json.Marshal(req)
@@ -129,7 +130,7 @@ func (suite *AuthHandlerTestSuite) TestLogin_Success() {
 		ExpiresIn:    3600,
 	}
 
-	suite.mockAuthService.On("Login", mock.Anything, req).Return(response, nil)
+	suite.mockAuthService.On("Login", mock.Anything, req, mock.AnythingOfType("domain.SessionMetadata")).Return(response, nil)
 
 	// Create request
 	body, _ := json.Marshal(req)
@@ -158,7 +159,7 @@ func (suite *AuthHandlerTestSuite) TestLogin_InvalidCredentials() {
 		Password: "wrongpassword",
 	}
 
-	suite.mockAuthService.On("Login", mock.Anything, req).Return(nil, domain.ErrInvalidCredentials)
+	suite.mockAuthService.On("Login", mock.Anything, req, mock.AnythingOfType("domain.SessionMetadata")).Return(nil, domain.ErrInvalidCredentials)
 
 	// Create request
 	body, _ := json.Marshal(req)
@@ -176,6 +177,154 @@ func (suite *AuthHandlerTestSuite) TestLogin_InvalidCredentials() {
 	suite.mockAuthService.AssertExpectations(suite.T())
 }
 
+func (suite *AuthHandlerTestSuite) TestLogin_TwoFactorChallenge() {
+	req := domain.LoginRequest{
+		Email:    "test@example.com",
+		Password: "password123",
+	}
+	suite.mockAuthService.On("Login", mock.Anything, req, mock.AnythingOfType("domain.SessionMetadata")).Return(&domain.AuthResponse{
+		MFARequired: true,
+		MFAToken:    "challenge",
+		ExpiresIn:   300,
+	}, nil)
+
+	body, _ := json.Marshal(req)
+	httpReq := httptest.NewRequest("POST", "/auth/login", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+
+	suite.handler.Login(rr, httpReq)
+
+	assert.Equal(suite.T(), http.StatusOK, rr.Code)
+
+	var result map[string]interface{}
+	assert.NoError(suite.T(), json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.Equal(suite.T(), true, result["mfa_required"])
+	assert.Equal(suite.T(), "challenge", result["mfa_token"])
+	assert.NotContains(suite.T(), result, "access_token")
+	assert.NotContains(suite.T(), result, "user")
+}
+
+func (suite *AuthHandlerTestSuite) TestLoginTwoFactor() {
+	valid := domain.MFALoginRequest{MFAToken: "challenge", Code: "123456"}
+	wrong := domain.MFALoginRequest{MFAToken: "challenge", Code: "654321"}
+	suite.mockAuthService.On("CompleteMFALogin", mock.Anything, valid, mock.Anything).Return(&domain.AuthResponse{
+		AccessToken:  "access_token",
+		RefreshToken: "refresh_token",
+		TokenType:    "Bearer",
+	}, nil)
+	suite.mockAuthService.On("CompleteMFALogin", mock.Anything, wrong, mock.Anything).Return(nil, domain.ErrInvalidTwoFactorCode)
+
+	login := func(req domain.MFALoginRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		httpReq := httptest.NewRequest("POST", "/auth/login/2fa", bytes.NewBuffer(body))
+		rr := httptest.NewRecorder()
+		suite.handler.LoginTwoFactor(rr, httpReq)
+		return rr
+	}
+
+	rr := login(valid)
+	assert.Equal(suite.T(), http.StatusOK, rr.Code)
+	assert.Contains(suite.T(), rr.Body.String(), "access_token")
+
+	assert.Equal(suite.T(), http.StatusUnauthorized, login(wrong).Code)
+	assert.Equal(suite.T(), http.StatusBadRequest, login(domain.MFALoginRequest{MFAToken: "challenge"}).Code)
+}
+
+func (suite *AuthHandlerTestSuite) TestCompleteOIDCLogin() {
+	valid := domain.OIDCCallbackRequest{Code: "code", State: "state"}
+	replayed := domain.OIDCCallbackRequest{Code: "code", State: "used-state"}
+	suite.mockAuthService.On("CompleteOIDCLogin", mock.Anything, valid, mock.Anything).Return(&domain.AuthResponse{
+		AccessToken:  "access_token",
+		RefreshToken: "refresh_token",
+		TokenType:    "Bearer",
+	}, nil)
+	suite.mockAuthService.On("CompleteOIDCLogin", mock.Anything, replayed, mock.Anything).Return(nil, domain.ErrOIDCStateInvalid)
+
+	callback := func(req domain.OIDCCallbackRequest, cookieState string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		httpReq := httptest.NewRequest("POST", "/auth/oidc/callback", bytes.NewBuffer(body))
+		if cookieState != "" {
+			httpReq.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: cookieState})
+		}
+		rr := httptest.NewRecorder()
+		suite.handler.CompleteOIDCLogin(rr, httpReq)
+		return rr
+	}
+
+	rr := callback(valid, "state")
+	assert.Equal(suite.T(), http.StatusOK, rr.Code)
+	assert.Contains(suite.T(), rr.Body.String(), "access_token")
+	// The state cookie is spent
+	assert.Contains(suite.T(), rr.Header().Get("Set-Cookie"), oidcStateCookie+"=;")
+
+	assert.Equal(suite.T(), http.StatusBadRequest, callback(replayed, "used-state").Code)
+	assert.Equal(suite.T(), http.StatusBadRequest, callback(domain.OIDCCallbackRequest{Code: "code"}, "state").Code)
+}
+
+func (suite *AuthHandlerTestSuite) TestCompleteOIDCLogin_StateFromAnotherBrowser() {
+	req := domain.OIDCCallbackRequest{Code: "code", State: "state"}
+
+	for _, cookieState := range []string{"", "other-state"} {
+		body, _ := json.Marshal(req)
+		httpReq := httptest.NewRequest("POST", "/auth/oidc/callback", bytes.NewBuffer(body))
+		if cookieState != "" {
+			httpReq.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: cookieState})
+		}
+		rr := httptest.NewRecorder()
+		suite.handler.CompleteOIDCLogin(rr, httpReq)
+
+		assert.Equal(suite.T(), http.StatusBadRequest, rr.Code)
+	}
+	suite.mockAuthService.AssertNotCalled(suite.T(), "CompleteOIDCLogin", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *AuthHandlerTestSuite) TestStartOIDCLogin() {
+	suite.mockAuthService.On("StartOIDCLogin", mock.Anything).Return(&domain.OIDCLoginResponse{
+		AuthorizationURL: "https://idp.example.com/authorize?state=state",
+		State:            "state",
+		ExpiresIn:        600,
+	}, nil)
+
+	rr := httptest.NewRecorder()
+	suite.handler.StartOIDCLogin(rr, httptest.NewRequest("GET", "/api/v1/auth/oidc/login", nil))
+
+	assert.Equal(suite.T(), http.StatusOK, rr.Code)
+	assert.NotContains(suite.T(), rr.Body.String(), `"state"`)
+
+	cookies := rr.Result().Cookies()
+	if assert.Len(suite.T(), cookies, 1) {
+		assert.Equal(suite.T(), oidcStateCookie, cookies[0].Name)
+		assert.Equal(suite.T(), "state", cookies[0].Value)
+		assert.Equal(suite.T(), "/api/v1/auth/oidc", cookies[0].Path)
+		assert.Equal(suite.T(), 600, cookies[0].MaxAge)
+		assert.True(suite.T(), cookies[0].HttpOnly)
+		assert.Equal(suite.T(), http.SameSiteLaxMode, cookies[0].SameSite)
+	}
+}
+
+func (suite *AuthHandlerTestSuite) TestStartOIDCLogin_NotConfigured() {
+	suite.mockAuthService.On("StartOIDCLogin", mock.Anything).Return(nil, domain.ErrOIDCNotConfigured)
+
+	rr := httptest.NewRecorder()
+	suite.handler.StartOIDCLogin(rr, httptest.NewRequest("GET", "/auth/oidc/login", nil))
+
+	assert.Equal(suite.T(), http.StatusNotFound, rr.Code)
+}
+
+func (suite *AuthHandlerTestSuite) TestDisableTwoFactor_Required() {
+	req := domain.DisableTwoFactorRequest{Password: "password123", Code: "123456"}
+	suite.mockAuthService.On("DisableTwoFactor", mock.Anything, uint(1), req).Return(domain.ErrTwoFactorRequired)
+
+	body, _ := json.Marshal(req)
+	httpReq := httptest.NewRequest("POST", "/auth/2fa/disable", bytes.NewBuffer(body))
+	httpReq = httpReq.WithContext(context.WithValue(httpReq.Context(), "user_id", uint(1)))
+	rr := httptest.NewRecorder()
+
+	suite.handler.DisableTwoFactor(rr, httpReq)
+
+	assert.Equal(suite.T(), http.StatusForbidden, rr.Code)
+}
+
 func (suite *AuthHandlerTestSuite) TestRefreshToken_Success() {
 	reqBody := map[string]string{
 		"refresh_token": "refresh_token_123",
@@ -188,12 +337,17 @@ func (suite *AuthHandlerTestSuite) TestRefreshToken_Success() {
 		ExpiresIn:    3600,
 	}
 
-	suite.mockAuthService.On("RefreshToken", mock.Anything, "refresh_token_123").Return(response, nil)
+	suite.mockAuthService.On("RefreshToken", mock.Anything, "refresh_token_123", domain.SessionMetadata{
+		UserAgent: "test-agent",
+		IPAddress: "203.0.113.9",
+	}).Return(response, nil)
 
 	// Create request
 	body, _ := json.Marshal(reqBody)
 	httpReq := httptest.NewRequest("POST", "/auth/refresh", bytes.NewBuffer(body))
 	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "test-agent")
+	httpReq.RemoteAddr = "203.0.113.9:5000"
 	rr := httptest.NewRecorder()
 
 	// Execute
@@ -227,9 +381,22 @@ func (suite *AuthHandlerTestSuite) TestRefreshToken_MissingToken() {
 	assert.Contains(suite.T(), rr.Body.String(), "Refresh token is required")
 }
 
+func (suite *AuthHandlerTestSuite) TestRefreshToken_Reused() {
+	suite.mockAuthService.On("RefreshToken", mock.Anything, "old_refresh_token", mock.Anything).Return(nil, domain.ErrRefreshTokenReused)
+
+	body, _ := json.Marshal(map[string]string{"refresh_token": "old_refresh_token"})
+	httpReq := httptest.NewRequest("POST", "/auth/refresh", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+
+	suite.handler.RefreshToken(rr, httpReq)
+
+	assert.Equal(suite.T(), http.StatusUnauthorized, rr.Code)
+	assert.Contains(suite.T(), rr.Body.String(), "already been used")
+}
+
 func (suite *AuthHandlerTestSuite) TestLogout_Success() {
 	userID := uint(1)
-	suite.mockAuthService.On("Logout", mock.Anything, userID).Return(nil)
+	suite.mockAuthService.On("Logout", mock.Anything, userID, "").Return(nil)
 
 	// Create request with user context
 	httpReq := httptest.NewRequest("POST", "/auth/logout", nil)
@@ -247,6 +414,56 @@ func (suite *AuthHandlerTestSuite) TestLogout_Success() {
 	suite.mockAuthService.AssertExpectations(suite.T())
 }
 
+func (suite *AuthHandlerTestSuite) TestLogout_EndsSession() {
+	suite.mockAuthService.On("Logout", mock.Anything, uint(1), "refresh_token_123").Return(nil)
+
+	body, _ := json.Marshal(map[string]string{"refresh_token": "refresh_token_123"})
+	httpReq := httptest.NewRequest("POST", "/auth/logout", bytes.NewBuffer(body))
+	httpReq = httpReq.WithContext(context.WithValue(httpReq.Context(), "user_id", uint(1)))
+	rr := httptest.NewRecorder()
+
+	suite.handler.Logout(rr, httpReq)
+
+	assert.Equal(suite.T(), http.StatusOK, rr.Code)
+	suite.mockAuthService.AssertExpectations(suite.T())
+}
+
+func (suite *AuthHandlerTestSuite) TestListSessions() {
+	sessions := []*domain.Session{{ID: 3, UserID: 1, FamilyID: "family-1", TokenHash: "hash", Device: "Firefox on Linux"}}
+	suite.mockAuthService.On("ListSessions", mock.Anything, uint(1)).Return(sessions, nil)
+
+	httpReq := httptest.NewRequest("GET", "/auth/sessions", nil)
+	httpReq = httpReq.WithContext(context.WithValue(httpReq.Context(), "user_id", uint(1)))
+	rr := httptest.NewRecorder()
+
+	suite.handler.ListSessions(rr, httpReq)
+
+	assert.Equal(suite.T(), http.StatusOK, rr.Code)
+	assert.Contains(suite.T(), rr.Body.String(), "Firefox on Linux")
+	// Token material never leaves the server
+	assert.NotContains(suite.T(), rr.Body.String(), "family-1")
+	assert.NotContains(suite.T(), rr.Body.String(), "hash")
+}
+
+func (suite *AuthHandlerTestSuite) TestRevokeSession() {
+	suite.mockAuthService.On("RevokeSession", mock.Anything, uint(1), uint(3)).Return(nil)
+	suite.mockAuthService.On("RevokeSession", mock.Anything, uint(1), uint(4)).Return(domain.ErrSessionNotFound)
+	router := chi.NewRouter()
+	router.Delete("/auth/sessions/{id}", suite.handler.RevokeSession)
+
+	revoke := func(id string) *httptest.ResponseRecorder {
+		httpReq := httptest.NewRequest("DELETE", "/auth/sessions/"+id, nil)
+		httpReq = httpReq.WithContext(context.WithValue(httpReq.Context(), "user_id", uint(1)))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httpReq)
+		return rr
+	}
+
+	assert.Equal(suite.T(), http.StatusOK, revoke("3").Code)
+	assert.Equal(suite.T(), http.StatusNotFound, revoke("4").Code)
+	assert.Equal(suite.T(), http.StatusBadRequest, revoke("abc").Code)
+}
+
 func (suite *AuthHandlerTestSuite) TestLogout_Unauthenticated() {
 	// Create request without user context
 	httpReq := httptest.NewRequest("POST", "/auth/logout", nil)
@@ -260,40 +477,196 @@ func (suite *AuthHandlerTestSuite) TestLogout_Unauthenticated() {
 	assert.Contains(suite.T(), rr.Body.String(), "Authentication required")
 }
 
+func (suite *AuthHandlerTestSuite) TestForgotPassword() {
+	req := domain.PasswordResetRequest{Email: "test@example.com"}
+	suite.mockAuthService.On("RequestPasswordReset", mock.Anything, req).Return(nil)
+
+	body, _ := json.Marshal(req)
+	httpReq := httptest.NewRequest("POST", "/auth/forgot-password", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	suite.handler.ForgotPassword(rr, httpReq)
+
+	assert.Equal(suite.T(), http.StatusAccepted, rr.Code)
+	assert.Contains(suite.T(), rr.Body.String(), "If an account exists")
+	suite.mockAuthService.AssertExpectations(suite.T())
+}
+
+func (suite *AuthHandlerTestSuite) TestForgotPassword_RateLimited() {
+	req := domain.PasswordResetRequest{Email: "test@example.com"}
+	suite.mockAuthService.On("RequestPasswordReset", mock.Anything, req).Return(domain.ErrTooManyRequests)
+
+	body, _ := json.Marshal(req)
+	httpReq := httptest.NewRequest("POST", "/auth/forgot-password", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+
+	suite.handler.ForgotPassword(rr, httpReq)
+
+	assert.Equal(suite.T(), http.StatusTooManyRequests, rr.Code)
+}
+
+func (suite *AuthHandlerTestSuite) TestResetPassword() {
+	valid := domain.PasswordResetConfirmRequest{Token: "good", NewPassword: "newpassword123"}
+	expired := domain.PasswordResetConfirmRequest{Token: "expired", NewPassword: "newpassword123"}
+	suite.mockAuthService.On("ResetPassword", mock.Anything, valid).Return(nil)
+	suite.mockAuthService.On("ResetPassword", mock.Anything, expired).Return(domain.ErrInvalidToken)
+
+	for _, tc := range []struct {
+		req    domain.PasswordResetConfirmRequest
+		status int
+		body   string
+	}{
+		{valid, http.StatusOK, "Password has been reset"},
+		{expired, http.StatusBadRequest, "Invalid or expired reset token"},
+		{domain.PasswordResetConfirmRequest{Token: "good", NewPassword: "short"}, http.StatusBadRequest, "invalid password"},
+	} {
+		body, _ := json.Marshal(tc.req)
+		httpReq := httptest.NewRequest("POST", "/auth/reset-password", bytes.NewBuffer(body))
+		rr := httptest.NewRecorder()
+
+		suite.handler.ResetPassword(rr, httpReq)
+
+		assert.Equal(suite.T(), tc.status, rr.Code, tc.req.Token)
+		assert.Contains(suite.T(), rr.Body.String(), tc.body)
+	}
+	suite.mockAuthService.AssertExpectations(suite.T())
+}
+
+func (suite *AuthHandlerTestSuite) TestVerifyEmail() {
+	profile := &domain.UserResponse{ID: 1, Email: "test@example.com", EmailVerified: true}
+	suite.mockAuthService.On("VerifyEmail", mock.Anything, "good").Return(profile, nil)
+	suite.mockAuthService.On("VerifyEmail", mock.Anything, "old").Return(nil, domain.ErrExpiredToken)
+
+	rr := httptest.NewRecorder()
+	suite.handler.VerifyEmail(rr, httptest.NewRequest("GET", "/auth/verify?token=good", nil))
+	assert.Equal(suite.T(), http.StatusOK, rr.Code)
+	assert.Contains(suite.T(), rr.Body.String(), `"email_verified":true`)
+
+	rr = httptest.NewRecorder()
+	suite.handler.VerifyEmail(rr, httptest.NewRequest("GET", "/auth/verify?token=old", nil))
+	assert.Equal(suite.T(), http.StatusGone, rr.Code)
+
+	rr = httptest.NewRecorder()
+	suite.handler.VerifyEmail(rr, httptest.NewRequest("GET", "/auth/verify", nil))
+	assert.Equal(suite.T(), http.StatusBadRequest, rr.Code)
+}
+
+func (suite *AuthHandlerTestSuite) TestResendVerification() {
+	suite.mockAuthService.On("ResendVerificationEmail", mock.Anything, uint(1)).Return(domain.ErrEmailAlreadyVerified)
+
+	httpReq := httptest.NewRequest("POST", "/auth/verify/resend", nil)
+	httpReq = httpReq.WithContext(context.WithValue(httpReq.Context(), "user_id", uint(1)))
+	rr := httptest.NewRecorder()
+
+	suite.handler.ResendVerification(rr, httpReq)
+
+	assert.Equal(suite.T(), http.StatusConflict, rr.Code)
+	suite.mockAuthService.AssertExpectations(suite.T())
+}
+
 // Mock AuthService
 type MockAuthService struct {
 	mock.Mock
 }
 
-func (m *MockAuthService) Register(ctx context.Context, req domain.RegisterRequest) (*domain.AuthResponse, error) {
-	args := m.Called(ctx, req)
+func (m *MockAuthService) Register(ctx context.Context, req domain.RegisterRequest, meta domain.SessionMetadata) (*domain.AuthResponse, error) {
+	args := m.Called(ctx, req, meta)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*domain.AuthResponse), args.Error(1)
 }
 
-func (m *MockAuthService) Login(ctx context.Context, req domain.LoginRequest) (*domain.AuthResponse, error) {
-	args := m.Called(ctx, req)
+func (m *MockAuthService) Login(ctx context.Context, req domain.LoginRequest, meta domain.SessionMetadata) (*domain.AuthResponse, error) {
+	args := m.Called(ctx, req, meta)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.AuthResponse), args.Error(1)
+}
+
+func (m *MockAuthService) RefreshToken(ctx context.Context, refreshToken string, meta domain.SessionMetadata) (*domain.AuthResponse, error) {
+	args := m.Called(ctx, refreshToken, meta)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.AuthResponse), args.Error(1)
+}
+
+func (m *MockAuthService) Logout(ctx context.Context, userID uint, refreshToken string) error {
+	args := m.Called(ctx, userID, refreshToken)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) ListSessions(ctx context.Context, userID uint) ([]*domain.Session, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*domain.Session), args.Error(1)
+}
+
+func (m *MockAuthService) RevokeSession(ctx context.Context, userID uint, sessionID uint) error {
+	args := m.Called(ctx, userID, sessionID)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) RevokeAllSessions(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) CompleteMFALogin(ctx context.Context, req domain.MFALoginRequest, meta domain.SessionMetadata) (*domain.AuthResponse, error) {
+	args := m.Called(ctx, req, meta)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*domain.AuthResponse), args.Error(1)
 }
 
-func (m *MockAuthService) RefreshToken(ctx context.Context, refreshToken string) (*domain.AuthResponse, error) {
-	args := m.Called(ctx, refreshToken)
+func (m *MockAuthService) StartOIDCLogin(ctx context.Context) (*domain.OIDCLoginResponse, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.OIDCLoginResponse), args.Error(1)
+}
+
+func (m *MockAuthService) CompleteOIDCLogin(ctx context.Context, req domain.OIDCCallbackRequest, meta domain.SessionMetadata) (*domain.AuthResponse, error) {
+	args := m.Called(ctx, req, meta)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*domain.AuthResponse), args.Error(1)
 }
 
-func (m *MockAuthService) Logout(ctx context.Context, userID uint) error {
+func (m *MockAuthService) SetupTwoFactor(ctx context.Context, userID uint) (*domain.TwoFactorSetupResponse, error) {
 	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TwoFactorSetupResponse), args.Error(1)
+}
+
+func (m *MockAuthService) EnableTwoFactor(ctx context.Context, userID uint, req domain.TwoFactorCodeRequest) (*domain.RecoveryCodesResponse, error) {
+	args := m.Called(ctx, userID, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.RecoveryCodesResponse), args.Error(1)
+}
+
+func (m *MockAuthService) DisableTwoFactor(ctx context.Context, userID uint, req domain.DisableTwoFactorRequest) error {
+	args := m.Called(ctx, userID, req)
 	return args.Error(0)
 }
 
+func (m *MockAuthService) RegenerateRecoveryCodes(ctx context.Context, userID uint, req domain.TwoFactorCodeRequest) (*domain.RecoveryCodesResponse, error) {
+	args := m.Called(ctx, userID, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.RecoveryCodesResponse), args.Error(1)
+}
+
 func (m *MockAuthService) ValidateToken(ctx context.Context, token string) (*domain.TokenClaims, error) {
 	args := m.Called(ctx, token)
 	if args.Get(0) == nil {
@@ -321,4 +694,27 @@ func (m *MockAuthService) UpdateProfile(ctx context.Context, userID uint, req do
 func (m *MockAuthService) ChangePassword(ctx context.Context, userID uint, req domain.ChangePasswordRequest) error {
 	args := m.Called(ctx, userID, req)
 	return args.Error(0)
-}
\ No newline at end of file
+}
+
+func (m *MockAuthService) RequestPasswordReset(ctx context.Context, req domain.PasswordResetRequest) error {
+	args := m.Called(ctx, req)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) ResetPassword(ctx context.Context, req domain.PasswordResetConfirmRequest) error {
+	args := m.Called(ctx, req)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) VerifyEmail(ctx context.Context, token string) (*domain.UserResponse, error) {
+	args := m.Called(ctx, token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.UserResponse), args.Error(1)
+}
+
+func (m *MockAuthService) ResendVerificationEmail(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
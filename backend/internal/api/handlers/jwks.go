@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"url-shortener/internal/core/ports"
+)
+
+// JWKSHandler publishes the public keys that verify access and refresh tokens
+type JWKSHandler struct {
+	jwtService ports.JWTService
+}
+
+func NewJWKSHandler(jwtService ports.JWTService) *JWKSHandler {
+	return &JWKSHandler{
+		jwtService: jwtService,
+	}
+}
+
+// GetKeySet serves /.well-known/jwks.json. There is nothing to publish while
+// tokens are signed with a shared secret.
+func (h *JWKSHandler) GetKeySet(w http.ResponseWriter, r *http.Request) {
+	keySet := h.jwtService.PublicKeys()
+	if len(keySet.Keys) == 0 {
+		h.writeErrorResponse(w, "Tokens are not signed with public keys", http.StatusNotFound)
+		return
+	}
+
+	// Short enough that verifiers pick up a new signing key soon after rotation
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	h.writeJSONResponse(w, keySet, http.StatusOK)
+}
+
+// Helper methods
+
+func (h *JWKSHandler) writeJSONResponse(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "Failed to encode response"}`))
+	}
+}
+
+func (h *JWKSHandler) writeErrorResponse(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	response := map[string]string{"error": message}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		w.Write([]byte(`{"error": "Internal server error"}`))
+	}
+}
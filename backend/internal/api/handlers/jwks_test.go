@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+// stubKeySource serves a fixed key set
+type stubKeySource struct {
+	ports.JWTService
+	keySet *domain.JSONWebKeySet
+}
+
+func (s *stubKeySource) PublicKeys() *domain.JSONWebKeySet {
+	return s.keySet
+}
+
+func TestJWKSHandler_GetKeySet(t *testing.T) {
+	keySet := &domain.JSONWebKeySet{Keys: []domain.JSONWebKey{
+		{Kty: "OKP", Kid: "2024-06", Use: "sig", Alg: "EdDSA", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+	}}
+	handler := NewJWKSHandler(&stubKeySource{keySet: keySet})
+
+	w := httptest.NewRecorder()
+	handler.GetKeySet(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "public, max-age=300", w.Header().Get("Cache-Control"))
+	var body domain.JSONWebKeySet
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+	assert.Equal(t, *keySet, body)
+}
+
+func TestJWKSHandler_SharedSecret(t *testing.T) {
+	handler := NewJWKSHandler(&stubKeySource{keySet: &domain.JSONWebKeySet{Keys: []domain.JSONWebKey{}}})
+
+	w := httptest.NewRecorder()
+	handler.GetKeySet(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
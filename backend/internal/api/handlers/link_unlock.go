@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"url-shortener/internal/api/middleware"
+	"url-shortener/internal/core/domain"
+)
+
+// unlockAttemptRetryAfter matches the window failed attempts are counted over
+const unlockAttemptRetryAfter = 15 * time.Minute
+
+var unlockPageTemplate = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+<style>
+body { font-family: system-ui, sans-serif; background: #f5f5f5; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; }
+form { background: #fff; padding: 2rem; border-radius: 8px; box-shadow: 0 1px 4px rgba(0, 0, 0, .1); width: 100%; max-width: 320px; }
+h1 { font-size: 1.25rem; margin: 0 0 1rem; }
+input { box-sizing: border-box; width: 100%; padding: .6rem; margin-bottom: 1rem; border: 1px solid #ccc; border-radius: 4px; }
+button { width: 100%; padding: .6rem; border: 0; border-radius: 4px; background: #2563eb; color: #fff; cursor: pointer; }
+.error { color: #b91c1c; margin: 0 0 1rem; }
+</style>
+</head>
+<body>
+<form method="post" action="/{{.ShortCode}}">
+<h1>This link is password protected</h1>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<label for="password">Password</label>
+<input id="password" name="password" type="password" autocomplete="current-password" required autofocus>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// UnlockURL handles the password form of a protected short URL
+func (h *URLHandler) UnlockURL(w http.ResponseWriter, r *http.Request) {
+	shortCode := chi.URLParam(r, "shortCode")
+	if shortCode == "" {
+		h.writeErrorResponse(w, "Short code is required", http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 4096)
+	if err := r.ParseForm(); err != nil {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	unlock, err := h.urlService.UnlockURL(r.Context(), shortCode, r.PostFormValue("password"), middleware.ClientIPAddress(r))
+	if err != nil {
+		switch err {
+		case domain.ErrURLNotFound, domain.ErrShortURLNotFound:
+			h.writeErrorResponse(w, "URL not found", http.StatusNotFound)
+		case domain.ErrInvalidPassword:
+			h.renderUnlockPage(w, shortCode, "Incorrect password, please try again.", http.StatusUnauthorized)
+		case domain.ErrTooManyRequests:
+			w.Header().Set("Retry-After", strconv.Itoa(int(unlockAttemptRetryAfter.Seconds())))
+			h.renderUnlockPage(w, shortCode, "Too many attempts. Please wait a few minutes and try again.", http.StatusTooManyRequests)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     unlockCookieName(shortCode),
+		Value:    unlock.Token,
+		Path:     "/" + shortCode,
+		Expires:  unlock.ExpiresAt,
+		MaxAge:   int(time.Until(unlock.ExpiresAt).Seconds()),
+		HttpOnly: true,
+		Secure:   middleware.IsHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// Back to the short URL, which now redirects as usual
+	http.Redirect(w, r, "/"+shortCode, http.StatusSeeOther)
+}
+
+// requireUnlock answers a request for a protected link that has no valid unlock
+// cookie, with the unlock page or, for API clients, a JSON error
+func (h *URLHandler) requireUnlock(w http.ResponseWriter, r *http.Request, shortURL *domain.ShortURL) bool {
+	if shortURL.Password == nil {
+		return false
+	}
+	if cookie, err := r.Cookie(unlockCookieName(shortURL.ShortCode)); err == nil {
+		if h.urlService.CheckUnlockToken(r.Context(), shortURL, cookie.Value) {
+			return false
+		}
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		h.writeErrorResponse(w, "Password required", http.StatusUnauthorized)
+		return true
+	}
+	h.renderUnlockPage(w, shortURL.ShortCode, "", http.StatusOK)
+	return true
+}
+
+func (h *URLHandler) renderUnlockPage(w http.ResponseWriter, shortCode, message string, statusCode int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(statusCode)
+
+	data := struct {
+		ShortCode string
+		Error     string
+	}{shortCode, message}
+	if err := unlockPageTemplate.Execute(w, data); err != nil {
+		w.Write([]byte("Password required"))
+	}
+}
+
+func unlockCookieName(shortCode string) string {
+	return "unlock_" + shortCode
+}
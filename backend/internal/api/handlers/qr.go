@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -43,12 +44,18 @@ func (h *QRHandler) GenerateQRCode(w http.ResponseWriter, r *http.Request) {
 	// Generate QR code
 	qrResponse, err := h.qrService.GenerateQRCode(r.Context(), req)
 	if err != nil {
-		switch err {
-		case domain.ErrInvalidRequest:
+		switch {
+		case errors.Is(err, domain.ErrInvalidRequest):
 			h.writeErrorResponse(w, "Invalid QR code request", http.StatusBadRequest)
-		case domain.ErrUnauthorized:
+		case errors.Is(err, domain.ErrInvalidInput):
+			h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, domain.ErrInvalidLogo):
+			h.writeErrorResponse(w, domain.ErrInvalidLogo.Error(), http.StatusBadRequest)
+		case errors.Is(err, domain.ErrLogoTooLarge):
+			h.writeErrorResponse(w, domain.ErrLogoTooLarge.Error(), http.StatusBadRequest)
+		case errors.Is(err, domain.ErrUnauthorized):
 			h.writeErrorResponse(w, "Access denied", http.StatusForbidden)
-		case domain.ErrURLNotFound:
+		case errors.Is(err, domain.ErrURLNotFound):
 			h.writeErrorResponse(w, "Short URL not found", http.StatusNotFound)
 		default:
 			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"url-shortener/internal/api/middleware"
+	"url-shortener/internal/core/domain"
+)
+
+// CreateQRBatch queues QR codes for many links at once. The batch is rendered in
+// the background; poll it with GetQRBatch and fetch the ZIP from its result_url.
+func (h *QRHandler) CreateQRBatch(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req domain.CreateQRBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	batch, err := h.qrService.CreateBatch(r.Context(), userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidInput):
+			h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, domain.ErrInvalidLogo):
+			h.writeErrorResponse(w, domain.ErrInvalidLogo.Error(), http.StatusBadRequest)
+		case errors.Is(err, domain.ErrLogoTooLarge):
+			h.writeErrorResponse(w, domain.ErrLogoTooLarge.Error(), http.StatusBadRequest)
+		case errors.Is(err, domain.ErrShortURLNotFound):
+			h.writeErrorResponse(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, domain.ErrUnauthorized):
+			h.writeErrorResponse(w, "Access denied", http.StatusForbidden)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Location", qrBatchURL(batch.ID))
+	h.writeJSONResponse(w, batch, http.StatusAccepted)
+}
+
+// GetQRBatch reports a batch's status and progress
+func (h *QRHandler) GetQRBatch(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	batchID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		h.writeErrorResponse(w, "Invalid batch ID", http.StatusBadRequest)
+		return
+	}
+
+	batch, err := h.qrService.GetBatch(r.Context(), uint(batchID), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrQRBatchNotFound):
+			h.writeErrorResponse(w, "QR batch not found", http.StatusNotFound)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if batch.Status == domain.QRBatchCompleted {
+		batch.ResultURL = qrBatchURL(batch.ID) + "/download"
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSONResponse(w, batch, http.StatusOK)
+}
+
+// DownloadQRBatch serves a completed batch as a ZIP archive
+func (h *QRHandler) DownloadQRBatch(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	batchID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		h.writeErrorResponse(w, "Invalid batch ID", http.StatusBadRequest)
+		return
+	}
+
+	archive, err := h.qrService.GetBatchArchive(r.Context(), uint(batchID), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrQRBatchNotFound):
+			h.writeErrorResponse(w, "QR batch not found", http.StatusNotFound)
+		case errors.Is(err, domain.ErrQRBatchNotReady):
+			h.writeErrorResponse(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, domain.ErrQRBatchExpired):
+			h.writeErrorResponse(w, "QR batch archive has expired, create the batch again", http.StatusGone)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="qr-batch-%d.zip"`, batchID))
+	w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
+
+func qrBatchURL(id uint) string {
+	return fmt.Sprintf("/api/v1/qr/batches/%d", id)
+}
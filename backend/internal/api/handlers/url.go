@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"url-shortener/internal/api/middleware"
@@ -11,15 +17,37 @@ import (
 	"url-shortener/internal/core/ports"
 )
 
+// redirectMaxAge is how long a browser may reuse a redirect before an edited
+// destination reaches it
+const redirectMaxAge = time.Minute
+
+// redirectLookupTimeout bounds the geolocation lookup a redirect may wait for
+const redirectLookupTimeout = 250 * time.Millisecond
+
 type URLHandler struct {
 	urlService       ports.URLService
 	analyticsService ports.AnalyticsService
+	uaParser         ports.UserAgentParser
+	geoService       ports.GeolocationService
+	locateAll        bool
 }
 
-func NewURLHandler(urlService ports.URLService, analyticsService ports.AnalyticsService) *URLHandler {
+// NewURLHandler creates the URL handler. geoService may be nil. Redirects look up
+// the visitor's location when the link has country rules, and for every click when
+// locateAll is set because no batch writer will locate it later.
+func NewURLHandler(
+	urlService ports.URLService,
+	analyticsService ports.AnalyticsService,
+	uaParser ports.UserAgentParser,
+	geoService ports.GeolocationService,
+	locateAll bool,
+) *URLHandler {
 	return &URLHandler{
 		urlService:       urlService,
 		analyticsService: analyticsService,
+		uaParser:         uaParser,
+		geoService:       geoService,
+		locateAll:        locateAll,
 	}
 }
 
@@ -49,6 +77,10 @@ func (h *URLHandler) CreateShortURL(w http.ResponseWriter, r *http.Request) {
 	// Create short URL
 	shortURL, err := h.urlService.ShortenURL(r.Context(), req)
 	if err != nil {
+		if errors.Is(err, domain.ErrInvalidRedirectRule) {
+			h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		switch err {
 		case domain.ErrInvalidURL:
 			h.writeErrorResponse(w, "Invalid URL format", http.StatusBadRequest)
@@ -56,6 +88,10 @@ func (h *URLHandler) CreateShortURL(w http.ResponseWriter, r *http.Request) {
 			h.writeErrorResponse(w, "Custom alias already exists", http.StatusConflict)
 		case domain.ErrInvalidShortCode:
 			h.writeErrorResponse(w, "Invalid custom alias format", http.StatusBadRequest)
+		case domain.ErrInvalidVariants:
+			h.writeErrorResponse(w, "Invalid variants", http.StatusBadRequest)
+		case domain.ErrUnauthorized:
+			h.writeErrorResponse(w, "You cannot add links to this workspace", http.StatusForbidden)
 		default:
 			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
 		}
@@ -113,7 +149,7 @@ func (h *URLHandler) GetURL(w http.ResponseWriter, r *http.Request) {
 	stats, err := h.urlService.GetURLStats(r.Context(), uint(urlID), userID)
 	if err != nil {
 		switch err {
-		case domain.ErrURLNotFound:
+		case domain.ErrURLNotFound, domain.ErrShortURLNotFound:
 			h.writeErrorResponse(w, "URL not found", http.StatusNotFound)
 		case domain.ErrUnauthorized:
 			h.writeErrorResponse(w, "Access denied", http.StatusForbidden)
@@ -157,11 +193,19 @@ func (h *URLHandler) UpdateURL(w http.ResponseWriter, r *http.Request) {
 	// Update URL
 	updatedURL, err := h.urlService.UpdateURL(r.Context(), uint(urlID), userID, req)
 	if err != nil {
+		if errors.Is(err, domain.ErrInvalidRedirectRule) {
+			h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		switch err {
-		case domain.ErrURLNotFound:
+		case domain.ErrURLNotFound, domain.ErrShortURLNotFound:
 			h.writeErrorResponse(w, "URL not found", http.StatusNotFound)
 		case domain.ErrUnauthorized:
 			h.writeErrorResponse(w, "Access denied", http.StatusForbidden)
+		case domain.ErrInvalidURL:
+			h.writeErrorResponse(w, "Invalid URL format", http.StatusBadRequest)
+		case domain.ErrInvalidVariants:
+			h.writeErrorResponse(w, "Invalid variants", http.StatusBadRequest)
 		default:
 			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
 		}
@@ -190,7 +234,7 @@ func (h *URLHandler) DeleteURL(w http.ResponseWriter, r *http.Request) {
 	// Delete URL
 	if err := h.urlService.DeleteURL(r.Context(), uint(urlID), userID); err != nil {
 		switch err {
-		case domain.ErrURLNotFound:
+		case domain.ErrURLNotFound, domain.ErrShortURLNotFound:
 			h.writeErrorResponse(w, "URL not found", http.StatusNotFound)
 		case domain.ErrUnauthorized:
 			h.writeErrorResponse(w, "Access denied", http.StatusForbidden)
@@ -203,6 +247,83 @@ func (h *URLHandler) DeleteURL(w http.ResponseWriter, r *http.Request) {
 	h.writeJSONResponse(w, map[string]string{"message": "URL deleted successfully"}, http.StatusOK)
 }
 
+// GetURLHistory lists the destinations a link has had, newest first
+func (h *URLHandler) GetURLHistory(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse URL ID
+	urlIDStr := chi.URLParam(r, "id")
+	urlID, err := strconv.ParseUint(urlIDStr, 10, 32)
+	if err != nil {
+		h.writeErrorResponse(w, "Invalid URL ID", http.StatusBadRequest)
+		return
+	}
+
+	offset, limit := h.parsePaginationParams(r)
+
+	revisions, total, err := h.urlService.GetURLHistory(r.Context(), uint(urlID), userID, offset, limit)
+	if err != nil {
+		switch err {
+		case domain.ErrURLNotFound, domain.ErrShortURLNotFound:
+			h.writeErrorResponse(w, "URL not found", http.StatusNotFound)
+		case domain.ErrUnauthorized:
+			h.writeErrorResponse(w, "Access denied", http.StatusForbidden)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeJSONResponse(w, map[string]interface{}{
+		"revisions": revisions,
+		"total":     total,
+		"offset":    offset,
+		"limit":     limit,
+	}, http.StatusOK)
+}
+
+// RollbackURL points a link back at the destination one of its revisions set
+func (h *URLHandler) RollbackURL(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		h.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse URL and revision IDs
+	urlID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		h.writeErrorResponse(w, "Invalid URL ID", http.StatusBadRequest)
+		return
+	}
+	revisionID, err := strconv.ParseUint(chi.URLParam(r, "revisionID"), 10, 32)
+	if err != nil {
+		h.writeErrorResponse(w, "Invalid revision ID", http.StatusBadRequest)
+		return
+	}
+
+	updatedURL, err := h.urlService.RollbackURL(r.Context(), uint(urlID), userID, uint(revisionID))
+	if err != nil {
+		switch err {
+		case domain.ErrURLNotFound, domain.ErrShortURLNotFound:
+			h.writeErrorResponse(w, "URL not found", http.StatusNotFound)
+		case domain.ErrRevisionNotFound:
+			h.writeErrorResponse(w, "Revision not found", http.StatusNotFound)
+		case domain.ErrUnauthorized:
+			h.writeErrorResponse(w, "Access denied", http.StatusForbidden)
+		default:
+			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeJSONResponse(w, updatedURL, http.StatusOK)
+}
+
 // RedirectURL handles short URL redirection
 func (h *URLHandler) RedirectURL(w http.ResponseWriter, r *http.Request) {
 	shortCode := chi.URLParam(r, "shortCode")
@@ -215,7 +336,7 @@ func (h *URLHandler) RedirectURL(w http.ResponseWriter, r *http.Request) {
 	shortURL, err := h.urlService.GetOriginalURL(r.Context(), shortCode)
 	if err != nil {
 		switch err {
-		case domain.ErrURLNotFound:
+		case domain.ErrURLNotFound, domain.ErrShortURLNotFound:
 			h.writeErrorResponse(w, "URL not found", http.StatusNotFound)
 		default:
 			h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
@@ -229,30 +350,46 @@ func (h *URLHandler) RedirectURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check password protection
-	if shortURL.Password != nil {
-		password := r.URL.Query().Get("password")
-		if password == "" {
-			h.writeErrorResponse(w, "Password required", http.StatusUnauthorized)
-			return
-		}
+	// Password protected links ask for the password first
+	if h.requireUnlock(w, r, shortURL) {
+		return
+	}
 
-		valid, err := h.urlService.ValidatePassword(r.Context(), shortCode, password)
-		if err != nil || !valid {
-			h.writeErrorResponse(w, "Invalid password", http.StatusUnauthorized)
-			return
+	clickData := h.extractClickData(r)
+	if h.locateAll || shortURL.HasCountryRules() {
+		h.locateClick(r.Context(), &clickData)
+	}
+	destination := shortURL.OriginalURL
+
+	// Links can be edited or rolled back at any time, so browsers may only hold on
+	// to a redirect briefly. Targeted and split links pick a destination per visitor
+	// and protected links check the unlock cookie, so those are never cached.
+	cacheControl := fmt.Sprintf("private, max-age=%d", int(redirectMaxAge.Seconds()))
+	if shortURL.HasDynamicDestination() || shortURL.Password != nil {
+		cacheControl = "private, no-store"
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+
+	// Targeting rules come first, then the variant split
+	if rule := shortURL.MatchRedirectRule(redirectContext(r, clickData)); rule != nil {
+		destination = rule.DestinationURL
+	} else if shortURL.HasVariants() {
+		variant, err := h.urlService.ChooseVariant(r.Context(), shortURL, assignedVariantID(r, shortCode))
+		if err == nil && variant != nil {
+			destination = variant.DestinationURL
+			clickData.VariantID = &variant.ID
+			setVariantCookie(w, r, shortCode, variant.ID)
 		}
 	}
 
 	// Record click analytics
-	clickData := h.extractClickData(r)
 	if err := h.urlService.RecordClick(r.Context(), shortURL, clickData); err != nil {
 		// Log error but don't fail the redirect
 		// In production, you might want to use a proper logger
 	}
 
 	// Redirect to original URL
-	http.Redirect(w, r, shortURL.OriginalURL, http.StatusMovedPermanently)
+	http.Redirect(w, r, destination, http.StatusFound)
 }
 
 // GetPopularURLs handles getting popular URLs (public endpoint)
@@ -300,14 +437,7 @@ func (h *URLHandler) parsePaginationParams(r *http.Request) (offset, limit int)
 }
 
 func (h *URLHandler) extractClickData(r *http.Request) domain.ClickData {
-	// Get client IP
-	clientIP := r.Header.Get("X-Real-IP")
-	if clientIP == "" {
-		clientIP = r.Header.Get("X-Forwarded-For")
-	}
-	if clientIP == "" {
-		clientIP = r.RemoteAddr
-	}
+	clientIP := middleware.ClientIPAddress(r)
 
 	// Get user agent
 	userAgent := r.Header.Get("User-Agent")
@@ -315,22 +445,136 @@ func (h *URLHandler) extractClickData(r *http.Request) domain.ClickData {
 	// Get referer
 	referer := r.Header.Get("Referer")
 
-	// For a production implementation, you would:
-	// 1. Parse user agent to extract device, browser, OS
-	// 2. Use IP geolocation service to get country, region, city
-	// 3. Implement proper device/browser detection
-
-	return domain.ClickData{
+	// Country, region and city are resolved from the IP by the click batch
+	// writers unless the redirect needs them, see locateClick
+	clickData := domain.ClickData{
 		IPAddress: clientIP,
 		UserAgent: userAgent,
 		Referer:   referer,
-		Country:   "Unknown", // Would be determined by IP geolocation
+		Country:   "", // ISO code, so there is no room for "Unknown"
 		Region:    "Unknown",
 		City:      "Unknown",
-		Device:    "Unknown", // Would be parsed from user agent
-		Browser:   "Unknown", // Would be parsed from user agent
-		OS:        "Unknown", // Would be parsed from user agent
+		Device:    "Unknown",
+		Browser:   "Unknown",
+		OS:        "Unknown",
+	}
+
+	// Classify device, browser and OS from the user agent
+	if h.uaParser != nil {
+		info := h.uaParser.Parse(userAgent)
+		clickData.Device = info.DeviceType
+		clickData.Browser = info.Browser
+		clickData.BrowserVersion = info.BrowserVersion
+		clickData.OS = info.OS
+		clickData.OSVersion = info.OSVersion
+	}
+
+	return clickData
+}
+
+// locateClick fills in the visitor's country, region and city. A failed lookup
+// leaves them unknown rather than failing the redirect.
+func (h *URLHandler) locateClick(ctx context.Context, clickData *domain.ClickData) {
+	if h.geoService == nil || clickData.IPAddress == "" {
+		return
 	}
+
+	ctx, cancel := context.WithTimeout(ctx, redirectLookupTimeout)
+	defer cancel()
+	location, err := h.geoService.GetLocationFromIP(ctx, clickData.IPAddress)
+	if err != nil {
+		return
+	}
+	clickData.Country = location.CountryCode
+	if location.Region != "" {
+		clickData.Region = location.Region
+	}
+	if location.City != "" {
+		clickData.City = location.City
+	}
+}
+
+// redirectContext describes the visitor for targeting rules
+func redirectContext(r *http.Request, clickData domain.ClickData) domain.RedirectContext {
+	return domain.RedirectContext{
+		Country:   clickData.Country,
+		Device:    clickData.Device,
+		OS:        clickData.OS,
+		Languages: acceptedLanguages(r.Header.Get("Accept-Language")),
+		Referrer:  clickData.Referer,
+		Time:      time.Now(),
+	}
+}
+
+// acceptedLanguages returns the language tags of an Accept-Language header, most
+// preferred first, leaving out the wildcard and any the client refused with q=0
+func acceptedLanguages(header string) []string {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+
+	var languages []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		languages = append(languages, weighted{tag: tag, quality: quality})
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].quality > languages[j].quality })
+	tags := make([]string, len(languages))
+	for i, language := range languages {
+		tags[i] = language.tag
+	}
+	return tags
+}
+
+// variantCookieMaxAge is how long a visitor stays on the variant they were first sent to
+const variantCookieMaxAge = 30 * 24 * 60 * 60
+
+func variantCookieName(shortCode string) string {
+	return "ab_" + shortCode
+}
+
+// assignedVariantID reads the visitor's sticky variant for a short code, or 0
+func assignedVariantID(r *http.Request, shortCode string) uint {
+	cookie, err := r.Cookie(variantCookieName(shortCode))
+	if err != nil {
+		return 0
+	}
+	id, err := strconv.ParseUint(cookie.Value, 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
+
+func setVariantCookie(w http.ResponseWriter, r *http.Request, shortCode string, variantID uint) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     variantCookieName(shortCode),
+		Value:    strconv.FormatUint(uint64(variantID), 10),
+		Path:     "/" + shortCode,
+		MaxAge:   variantCookieMaxAge,
+		HttpOnly: true,
+		Secure:   middleware.IsHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
 }
 
 func (h *URLHandler) writeJSONResponse(w http.ResponseWriter, data interface{}, statusCode int) {
@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+// MockURLService mocks the parts of ports.URLService the redirect path uses
+type MockURLService struct {
+	ports.URLService
+	mock.Mock
+}
+
+func (m *MockURLService) GetOriginalURL(ctx context.Context, shortCode string) (*domain.ShortURL, error) {
+	args := m.Called(ctx, shortCode)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ShortURL), args.Error(1)
+}
+
+func (m *MockURLService) ChooseVariant(ctx context.Context, shortURL *domain.ShortURL, assignedVariantID uint) (*domain.URLVariant, error) {
+	args := m.Called(ctx, shortURL, assignedVariantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.URLVariant), args.Error(1)
+}
+
+func (m *MockURLService) RecordClick(ctx context.Context, shortURL *domain.ShortURL, clickData domain.ClickData) error {
+	args := m.Called(ctx, shortURL, clickData)
+	return args.Error(0)
+}
+
+func (m *MockURLService) UnlockURL(ctx context.Context, shortCode, password, clientIP string) (*domain.LinkUnlock, error) {
+	args := m.Called(ctx, shortCode, password, clientIP)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.LinkUnlock), args.Error(1)
+}
+
+func (m *MockURLService) CheckUnlockToken(ctx context.Context, shortURL *domain.ShortURL, token string) bool {
+	args := m.Called(ctx, shortURL, token)
+	return args.Bool(0)
+}
+
+// stubGeolocationService resolves IP addresses from a fixed table of country codes
+type stubGeolocationService struct {
+	ports.GeolocationService
+	countries map[string]string
+}
+
+func (s *stubGeolocationService) GetLocationFromIP(ctx context.Context, ipAddress string) (*domain.GeoLocation, error) {
+	country, ok := s.countries[ipAddress]
+	if !ok {
+		return nil, domain.ErrLocationNotFound
+	}
+	return &domain.GeoLocation{IPAddress: ipAddress, CountryCode: country, City: "Somewhere"}, nil
+}
+
+type URLHandlerTestSuite struct {
+	suite.Suite
+	handler        *URLHandler
+	mockURLService *MockURLService
+	router         chi.Router
+}
+
+func TestURLHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(URLHandlerTestSuite))
+}
+
+func (suite *URLHandlerTestSuite) SetupTest() {
+	suite.mockURLService = &MockURLService{}
+	suite.handler = NewURLHandler(suite.mockURLService, nil, nil, nil, false)
+	suite.router = chi.NewRouter()
+	suite.router.Get("/{shortCode}", suite.handler.RedirectURL)
+	suite.router.Post("/{shortCode}", suite.handler.UnlockURL)
+}
+
+func (suite *URLHandlerTestSuite) redirect(headers map[string]string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/abc123", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rr := httptest.NewRecorder()
+	suite.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func (suite *URLHandlerTestSuite) TestRedirectURL_Plain() {
+	shortURL := &domain.ShortURL{ID: 1, ShortCode: "abc123", OriginalURL: "https://example.com", IsActive: true}
+	suite.mockURLService.On("GetOriginalURL", mock.Anything, "abc123").Return(shortURL, nil)
+	suite.mockURLService.On("RecordClick", mock.Anything, shortURL, mock.Anything).Return(nil)
+
+	rr := suite.redirect(nil)
+
+	// Only cached briefly, so edits reach visitors who have been here before
+	assert.Equal(suite.T(), http.StatusFound, rr.Code)
+	assert.Equal(suite.T(), "private, max-age=60", rr.Header().Get("Cache-Control"))
+	assert.Equal(suite.T(), "https://example.com", rr.Header().Get("Location"))
+}
+
+func (suite *URLHandlerTestSuite) TestRedirectURL_RedirectRules() {
+	shortURL := &domain.ShortURL{
+		ID:          1,
+		ShortCode:   "abc123",
+		OriginalURL: "https://example.com",
+		IsActive:    true,
+		RedirectRules: []domain.RedirectRule{
+			{Conditions: domain.RuleConditions{Languages: []string{"de"}}, DestinationURL: "https://example.de"},
+			{Conditions: domain.RuleConditions{ReferrerDomains: []string{"news.example.org"}}, DestinationURL: "https://example.com/news"},
+			{Conditions: domain.RuleConditions{Languages: []string{"fr"}, ReferrerDomains: []string{"example.org"}}, DestinationURL: "https://example.fr/partner"},
+		},
+	}
+	suite.mockURLService.On("GetOriginalURL", mock.Anything, "abc123").Return(shortURL, nil)
+	suite.mockURLService.On("RecordClick", mock.Anything, shortURL, mock.Anything).Return(nil)
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		location string
+	}{
+		{"no match", map[string]string{"Accept-Language": "en-GB,en;q=0.9"}, "https://example.com"},
+		{"language", map[string]string{"Accept-Language": "de-AT,en;q=0.5"}, "https://example.de"},
+		{"refused language", map[string]string{"Accept-Language": "en, de;q=0"}, "https://example.com"},
+		{"referrer subdomain", map[string]string{"Referer": "https://www.eu.news.example.org/story"}, "https://example.com/news"},
+		{"all conditions", map[string]string{"Accept-Language": "fr-CA", "Referer": "https://example.org/"}, "https://example.fr/partner"},
+		{"first rule wins", map[string]string{"Accept-Language": "de", "Referer": "https://news.example.org/"}, "https://example.de"},
+	}
+
+	for _, tt := range tests {
+		rr := suite.redirect(tt.headers)
+		assert.Equal(suite.T(), http.StatusFound, rr.Code, tt.name)
+		assert.Equal(suite.T(), "private, no-store", rr.Header().Get("Cache-Control"), tt.name)
+		assert.Equal(suite.T(), tt.location, rr.Header().Get("Location"), tt.name)
+	}
+
+	// Rule matches skip the variant split entirely
+	suite.mockURLService.AssertNotCalled(suite.T(), "ChooseVariant", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *URLHandlerTestSuite) TestRedirectURL_CountryRules() {
+	geo := &stubGeolocationService{countries: map[string]string{"203.0.113.7": "DE"}}
+	suite.handler = NewURLHandler(suite.mockURLService, nil, nil, geo, false)
+	suite.router = chi.NewRouter()
+	suite.router.Get("/{shortCode}", suite.handler.RedirectURL)
+
+	shortURL := &domain.ShortURL{
+		ID:          1,
+		ShortCode:   "abc123",
+		OriginalURL: "https://example.com",
+		IsActive:    true,
+		RedirectRules: []domain.RedirectRule{
+			{Conditions: domain.RuleConditions{Countries: []string{"DE", "AT"}}, DestinationURL: "https://example.de"},
+		},
+	}
+	suite.mockURLService.On("GetOriginalURL", mock.Anything, "abc123").Return(shortURL, nil)
+	suite.mockURLService.On("RecordClick", mock.Anything, shortURL, mock.MatchedBy(func(data domain.ClickData) bool {
+		return data.Country == "DE" && data.City == "Somewhere"
+	})).Return(nil).Once()
+	suite.mockURLService.On("RecordClick", mock.Anything, shortURL, mock.MatchedBy(func(data domain.ClickData) bool {
+		return data.Country == ""
+	})).Return(nil).Once()
+
+	rr := suite.redirect(map[string]string{"X-Real-IP": "203.0.113.7"})
+	assert.Equal(suite.T(), "https://example.de", rr.Header().Get("Location"))
+
+	// A visitor who cannot be located gets the default destination
+	rr = suite.redirect(map[string]string{"X-Real-IP": "198.51.100.1"})
+	assert.Equal(suite.T(), "https://example.com", rr.Header().Get("Location"))
+	suite.mockURLService.AssertExpectations(suite.T())
+}
+
+func (suite *URLHandlerTestSuite) TestRedirectURL_StickyVariant() {
+	shortURL := &domain.ShortURL{
+		ID:          1,
+		ShortCode:   "abc123",
+		OriginalURL: "https://example.com",
+		IsActive:    true,
+		Variants: []domain.URLVariant{
+			{ID: 5, Name: "A", DestinationURL: "https://example.com/a", Weight: 1},
+			{ID: 6, Name: "B", DestinationURL: "https://example.com/b", Weight: 1},
+		},
+	}
+	suite.mockURLService.On("GetOriginalURL", mock.Anything, "abc123").Return(shortURL, nil)
+	suite.mockURLService.On("ChooseVariant", mock.Anything, shortURL, uint(6)).Return(&shortURL.Variants[1], nil)
+	suite.mockURLService.On("RecordClick", mock.Anything, shortURL, mock.MatchedBy(func(data domain.ClickData) bool {
+		return data.VariantID != nil && *data.VariantID == 6
+	})).Return(nil)
+
+	rr := suite.redirect(nil, &http.Cookie{Name: "ab_abc123", Value: "6"})
+
+	assert.Equal(suite.T(), http.StatusFound, rr.Code)
+	assert.Equal(suite.T(), "https://example.com/b", rr.Header().Get("Location"))
+	cookies := rr.Result().Cookies()
+	if assert.Len(suite.T(), cookies, 1) {
+		assert.Equal(suite.T(), "ab_abc123", cookies[0].Name)
+		assert.Equal(suite.T(), "6", cookies[0].Value)
+		assert.Equal(suite.T(), "/abc123", cookies[0].Path)
+	}
+	suite.mockURLService.AssertExpectations(suite.T())
+}
+
+func (suite *URLHandlerTestSuite) protectedURL() *domain.ShortURL {
+	password := "$2a$10$hash"
+	return &domain.ShortURL{ID: 1, ShortCode: "abc123", OriginalURL: "https://example.com", IsActive: true, Password: &password}
+}
+
+func (suite *URLHandlerTestSuite) TestRedirectURL_ShowsUnlockPage() {
+	shortURL := suite.protectedURL()
+	suite.mockURLService.On("GetOriginalURL", mock.Anything, "abc123").Return(shortURL, nil)
+	suite.mockURLService.On("CheckUnlockToken", mock.Anything, shortURL, "stale").Return(false)
+
+	rr := suite.redirect(nil, &http.Cookie{Name: "unlock_abc123", Value: "stale"})
+
+	assert.Equal(suite.T(), http.StatusOK, rr.Code)
+	assert.Contains(suite.T(), rr.Header().Get("Content-Type"), "text/html")
+	assert.Equal(suite.T(), "no-store", rr.Header().Get("Cache-Control"))
+	assert.Contains(suite.T(), rr.Body.String(), `action="/abc123"`)
+	assert.Empty(suite.T(), rr.Header().Get("Location"))
+
+	// API clients get JSON instead
+	rr = suite.redirect(map[string]string{"Accept": "application/json"})
+	assert.Equal(suite.T(), http.StatusUnauthorized, rr.Code)
+
+	// The password is no longer accepted in the query string
+	req := httptest.NewRequest("GET", "/abc123?password=s3cret", nil)
+	rr = httptest.NewRecorder()
+	suite.router.ServeHTTP(rr, req)
+	assert.Equal(suite.T(), http.StatusOK, rr.Code)
+	assert.Empty(suite.T(), rr.Header().Get("Location"))
+	suite.mockURLService.AssertNotCalled(suite.T(), "RecordClick", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *URLHandlerTestSuite) TestRedirectURL_UnlockedByCookie() {
+	shortURL := suite.protectedURL()
+	suite.mockURLService.On("GetOriginalURL", mock.Anything, "abc123").Return(shortURL, nil)
+	suite.mockURLService.On("CheckUnlockToken", mock.Anything, shortURL, "good").Return(true)
+	suite.mockURLService.On("RecordClick", mock.Anything, shortURL, mock.Anything).Return(nil)
+
+	rr := suite.redirect(nil, &http.Cookie{Name: "unlock_abc123", Value: "good"})
+
+	// Never cached, so every visit goes through the cookie check again
+	assert.Equal(suite.T(), http.StatusFound, rr.Code)
+	assert.Equal(suite.T(), "private, no-store", rr.Header().Get("Cache-Control"))
+	assert.Equal(suite.T(), "https://example.com", rr.Header().Get("Location"))
+}
+
+func (suite *URLHandlerTestSuite) unlock(password string) *httptest.ResponseRecorder {
+	form := url.Values{"password": {password}}
+	req := httptest.NewRequest("POST", "/abc123", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = "203.0.113.9:4711"
+	rr := httptest.NewRecorder()
+	suite.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func (suite *URLHandlerTestSuite) TestUnlockURL() {
+	expiresAt := time.Now().Add(time.Hour)
+	suite.mockURLService.On("UnlockURL", mock.Anything, "abc123", "s3cret", "203.0.113.9").
+		Return(&domain.LinkUnlock{Token: "signed", ExpiresAt: expiresAt}, nil)
+	suite.mockURLService.On("UnlockURL", mock.Anything, "abc123", "guess", "203.0.113.9").
+		Return(nil, domain.ErrInvalidPassword)
+	suite.mockURLService.On("UnlockURL", mock.Anything, "abc123", "again", "203.0.113.9").
+		Return(nil, domain.ErrTooManyRequests)
+
+	rr := suite.unlock("s3cret")
+	assert.Equal(suite.T(), http.StatusSeeOther, rr.Code)
+	assert.Equal(suite.T(), "/abc123", rr.Header().Get("Location"))
+	cookies := rr.Result().Cookies()
+	if assert.Len(suite.T(), cookies, 1) {
+		assert.Equal(suite.T(), "unlock_abc123", cookies[0].Name)
+		assert.Equal(suite.T(), "signed", cookies[0].Value)
+		assert.Equal(suite.T(), "/abc123", cookies[0].Path)
+		assert.True(suite.T(), cookies[0].HttpOnly)
+	}
+
+	rr = suite.unlock("guess")
+	assert.Equal(suite.T(), http.StatusUnauthorized, rr.Code)
+	assert.Contains(suite.T(), rr.Body.String(), "Incorrect password")
+	assert.Empty(suite.T(), rr.Result().Cookies())
+
+	rr = suite.unlock("again")
+	assert.Equal(suite.T(), http.StatusTooManyRequests, rr.Code)
+	assert.Equal(suite.T(), "900", rr.Header().Get("Retry-After"))
+}
+
+func (suite *URLHandlerTestSuite) TestUnlockURL_SecureCookieBehindProxy() {
+	suite.mockURLService.On("UnlockURL", mock.Anything, "abc123", "s3cret", "203.0.113.9").
+		Return(&domain.LinkUnlock{Token: "signed", ExpiresAt: time.Now().Add(time.Hour)}, nil)
+
+	rr := suite.unlock("s3cret")
+	if cookies := rr.Result().Cookies(); assert.Len(suite.T(), cookies, 1) {
+		assert.False(suite.T(), cookies[0].Secure)
+	}
+
+	// TLS ended at the proxy in front of us
+	form := url.Values{"password": {"s3cret"}}
+	req := httptest.NewRequest("POST", "/abc123", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.RemoteAddr = "203.0.113.9:4711"
+	rr = httptest.NewRecorder()
+	suite.router.ServeHTTP(rr, req)
+	if cookies := rr.Result().Cookies(); assert.Len(suite.T(), cookies, 1) {
+		assert.True(suite.T(), cookies[0].Secure)
+	}
+}
+
+func TestAcceptedLanguages(t *testing.T) {
+	assert.Equal(t, []string{"fr-CH", "fr", "en", "de"}, acceptedLanguages("fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5"))
+	assert.Equal(t, []string{"de", "en"}, acceptedLanguages("en;q=0.5, de, it;q=0"))
+	assert.Empty(t, acceptedLanguages(""))
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"url-shortener/internal/api/middleware"
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+// WorkspaceHandler serves team workspaces. The service checks each caller's role in the workspace.
+type WorkspaceHandler struct {
+	workspaceService ports.WorkspaceService
+}
+
+func NewWorkspaceHandler(workspaceService ports.WorkspaceService) *WorkspaceHandler {
+	return &WorkspaceHandler{
+		workspaceService: workspaceService,
+	}
+}
+
+// CreateWorkspace creates a workspace with the caller as its owner
+func (h *WorkspaceHandler) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	var req domain.CreateWorkspaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	workspace, err := h.workspaceService.CreateWorkspace(r.Context(), middleware.GetUserIDFromContext(r.Context()), req)
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, workspace, http.StatusCreated)
+}
+
+// ListWorkspaces lists the workspaces the caller belongs to, with their role in each
+func (h *WorkspaceHandler) ListWorkspaces(w http.ResponseWriter, r *http.Request) {
+	workspaces, err := h.workspaceService.ListWorkspaces(r.Context(), middleware.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, map[string]interface{}{
+		"workspaces": workspaces,
+	}, http.StatusOK)
+}
+
+func (h *WorkspaceHandler) GetWorkspace(w http.ResponseWriter, r *http.Request) {
+	workspaceID, ok := h.idParam(w, r, "id", "Invalid workspace ID")
+	if !ok {
+		return
+	}
+
+	workspace, err := h.workspaceService.GetWorkspace(r.Context(), workspaceID, middleware.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, workspace, http.StatusOK)
+}
+
+// UpdateWorkspace renames a workspace
+func (h *WorkspaceHandler) UpdateWorkspace(w http.ResponseWriter, r *http.Request) {
+	workspaceID, ok := h.idParam(w, r, "id", "Invalid workspace ID")
+	if !ok {
+		return
+	}
+
+	var req domain.UpdateWorkspaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	workspace, err := h.workspaceService.UpdateWorkspace(r.Context(), workspaceID, middleware.GetUserIDFromContext(r.Context()), req)
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, workspace, http.StatusOK)
+}
+
+// GetWorkspaceURLs lists the links owned by a workspace
+func (h *WorkspaceHandler) GetWorkspaceURLs(w http.ResponseWriter, r *http.Request) {
+	workspaceID, ok := h.idParam(w, r, "id", "Invalid workspace ID")
+	if !ok {
+		return
+	}
+
+	offset, limit := h.parsePaginationParams(r)
+
+	urls, total, err := h.workspaceService.GetWorkspaceURLs(r.Context(), workspaceID, middleware.GetUserIDFromContext(r.Context()), offset, limit)
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, map[string]interface{}{
+		"urls":   urls,
+		"total":  total,
+		"offset": offset,
+		"limit":  limit,
+	}, http.StatusOK)
+}
+
+func (h *WorkspaceHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	workspaceID, ok := h.idParam(w, r, "id", "Invalid workspace ID")
+	if !ok {
+		return
+	}
+
+	members, err := h.workspaceService.ListMembers(r.Context(), workspaceID, middleware.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, map[string]interface{}{
+		"members": members,
+	}, http.StatusOK)
+}
+
+// UpdateMemberRole changes a member's role
+func (h *WorkspaceHandler) UpdateMemberRole(w http.ResponseWriter, r *http.Request) {
+	workspaceID, ok := h.idParam(w, r, "id", "Invalid workspace ID")
+	if !ok {
+		return
+	}
+	memberID, ok := h.idParam(w, r, "userID", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	var req domain.UpdateMemberRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	member, err := h.workspaceService.UpdateMemberRole(r.Context(), workspaceID, middleware.GetUserIDFromContext(r.Context()), memberID, req)
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, member, http.StatusOK)
+}
+
+// RemoveMember removes a member, or lets the caller leave when it is their own ID
+func (h *WorkspaceHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	workspaceID, ok := h.idParam(w, r, "id", "Invalid workspace ID")
+	if !ok {
+		return
+	}
+	memberID, ok := h.idParam(w, r, "userID", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	if err := h.workspaceService.RemoveMember(r.Context(), workspaceID, middleware.GetUserIDFromContext(r.Context()), memberID); err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// InviteMember emails an invitation to join the workspace
+func (h *WorkspaceHandler) InviteMember(w http.ResponseWriter, r *http.Request) {
+	workspaceID, ok := h.idParam(w, r, "id", "Invalid workspace ID")
+	if !ok {
+		return
+	}
+
+	var req domain.InviteMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	invitation, err := h.workspaceService.InviteMember(r.Context(), workspaceID, middleware.GetUserIDFromContext(r.Context()), req)
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, invitation, http.StatusCreated)
+}
+
+// ListInvitations lists invitations that have not been accepted and have not expired
+func (h *WorkspaceHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	workspaceID, ok := h.idParam(w, r, "id", "Invalid workspace ID")
+	if !ok {
+		return
+	}
+
+	invitations, err := h.workspaceService.ListInvitations(r.Context(), workspaceID, middleware.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, map[string]interface{}{
+		"invitations": invitations,
+	}, http.StatusOK)
+}
+
+func (h *WorkspaceHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	workspaceID, ok := h.idParam(w, r, "id", "Invalid workspace ID")
+	if !ok {
+		return
+	}
+	invitationID, ok := h.idParam(w, r, "invitationID", "Invalid invitation ID")
+	if !ok {
+		return
+	}
+
+	if err := h.workspaceService.RevokeInvitation(r.Context(), workspaceID, middleware.GetUserIDFromContext(r.Context()), invitationID); err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptInvitation joins the workspace named in an invitation sent to the caller's email
+func (h *WorkspaceHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var req domain.AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	workspace, err := h.workspaceService.AcceptInvitation(r.Context(), middleware.GetUserIDFromContext(r.Context()), req)
+	if err != nil {
+		h.writeServiceError(w, err)
+		return
+	}
+
+	h.writeJSONResponse(w, workspace, http.StatusOK)
+}
+
+// Helper methods
+
+func (h *WorkspaceHandler) idParam(w http.ResponseWriter, r *http.Request, name, message string) (uint, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, name), 10, 32)
+	if err != nil {
+		h.writeErrorResponse(w, message, http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (h *WorkspaceHandler) writeServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case err == domain.ErrWorkspaceNotFound:
+		h.writeErrorResponse(w, "Workspace not found", http.StatusNotFound)
+	case err == domain.ErrNotWorkspaceMember:
+		h.writeErrorResponse(w, "Member not found", http.StatusNotFound)
+	case err == domain.ErrInvitationNotFound:
+		h.writeErrorResponse(w, "Invitation not found", http.StatusNotFound)
+	case err == domain.ErrForbidden:
+		h.writeErrorResponse(w, "Your workspace role does not allow this", http.StatusForbidden)
+	case err == domain.ErrInvitationEmailMismatch:
+		h.writeErrorResponse(w, "This invitation was sent to a different email address", http.StatusForbidden)
+	case err == domain.ErrInvitationExpired:
+		h.writeErrorResponse(w, "Invitation has expired", http.StatusGone)
+	case err == domain.ErrAlreadyWorkspaceMember:
+		h.writeErrorResponse(w, "User is already a member of this workspace", http.StatusConflict)
+	case err == domain.ErrLastWorkspaceOwner:
+		h.writeErrorResponse(w, "A workspace must keep at least one owner", http.StatusConflict)
+	case errors.Is(err, domain.ErrInvalidInput), err == domain.ErrInvalidWorkspaceRole, err == domain.ErrInvalidEmail:
+		h.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+	default:
+		h.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *WorkspaceHandler) parsePaginationParams(r *http.Request) (offset, limit int) {
+	offset = 0
+	limit = 20 // default limit
+
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 100 {
+			limit = parsedLimit
+		}
+	}
+
+	return offset, limit
+}
+
+func (h *WorkspaceHandler) writeJSONResponse(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "Failed to encode response"}`))
+	}
+}
+
+func (h *WorkspaceHandler) writeErrorResponse(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	response := map[string]string{"error": message}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		w.Write([]byte(`{"error": "Internal server error"}`))
+	}
+}
@@ -4,26 +4,45 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"url-shortener/internal/core/domain"
 	"url-shortener/internal/core/ports"
 )
 
+// sessionCheckTTL is how long a session found active is trusted without looking
+// it up again. Revoking a session clears the check, so this only bounds how long a
+// missed invalidation can last.
+const sessionCheckTTL = time.Minute
+
 type AuthMiddleware struct {
-	jwtService ports.JWTService
-	userRepo   ports.UserRepository
+	jwtService    ports.JWTService
+	userRepo      ports.UserRepository
+	sessionRepo   ports.SessionRepository
+	cache         ports.CacheService
+	apiKeyService ports.APIKeyService
 }
 
-func NewAuthMiddleware(jwtService ports.JWTService, userRepo ports.UserRepository) *AuthMiddleware {
+// NewAuthMiddleware accepts JWTs whose session is still active, and personal API
+// keys when apiKeyService is not nil
+func NewAuthMiddleware(jwtService ports.JWTService, userRepo ports.UserRepository, sessionRepo ports.SessionRepository, cache ports.CacheService, apiKeyService ports.APIKeyService) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtService: jwtService,
-		userRepo:   userRepo,
+		jwtService:    jwtService,
+		userRepo:      userRepo,
+		sessionRepo:   sessionRepo,
+		cache:         cache,
+		apiKeyService: apiKeyService,
 	}
 }
 
-// RequireAuth middleware validates JWT token and sets user context
+// RequireAuth middleware validates a JWT or API key and sets user context
 func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := m.extractAPIKey(r); key != "" {
+			m.requireAPIKey(w, r, key, next)
+			return
+		}
+
 		token := m.extractToken(r)
 		if token == "" {
 			m.writeErrorResponse(w, "Missing authorization token", http.StatusUnauthorized)
@@ -36,6 +55,12 @@ func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 			return
 		}
 
+		// Signing out or revoking the session ends its access tokens too
+		if !m.sessionActive(r.Context(), claims) {
+			m.writeErrorResponse(w, "Session has ended", http.StatusUnauthorized)
+			return
+		}
+
 		// Get user from database to ensure they still exist and are active
 		user, err := m.userRepo.GetByID(r.Context(), claims.UserID)
 		if err != nil {
@@ -59,6 +84,12 @@ func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 // OptionalAuth middleware validates JWT token if present but doesn't require it
 func (m *AuthMiddleware) OptionalAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A bad API key is an error rather than an anonymous request
+		if key := m.extractAPIKey(r); key != "" {
+			m.requireAPIKey(w, r, key, next)
+			return
+		}
+
 		token := m.extractToken(r)
 		if token == "" {
 			next.ServeHTTP(w, r)
@@ -66,7 +97,7 @@ func (m *AuthMiddleware) OptionalAuth(next http.Handler) http.Handler {
 		}
 
 		claims, err := m.jwtService.ValidateAccessToken(token)
-		if err != nil {
+		if err != nil || !m.sessionActive(r.Context(), claims) {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -86,8 +117,36 @@ func (m *AuthMiddleware) OptionalAuth(next http.Handler) http.Handler {
 	})
 }
 
-// AdminOnly middleware ensures the user is an admin
+// AdminOnly middleware ensures the user is an admin. It must run after RequireAuth.
 func (m *AuthMiddleware) AdminOnly(next http.Handler) http.Handler {
+	return m.RequireRole(domain.RoleAdmin)(next)
+}
+
+// RequireRole rejects users who have none of the given roles. The role is read
+// from the stored user rather than the token, so a change applies immediately.
+// It must run after RequireAuth.
+func (m *AuthMiddleware) RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := GetUserFromContext(r.Context())
+			if user == nil {
+				m.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+				return
+			}
+
+			if !user.HasRole(roles...) {
+				m.writeErrorResponse(w, "You do not have permission to access this resource", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireVerifiedEmail rejects users who have not confirmed their email address.
+// It must run after RequireAuth.
+func (m *AuthMiddleware) RequireVerifiedEmail(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := GetUserFromContext(r.Context())
 		if user == nil {
@@ -95,13 +154,142 @@ func (m *AuthMiddleware) AdminOnly(next http.Handler) http.Handler {
 			return
 		}
 
-		// Check if user has admin privileges (assuming we add this field later)
-		// For now, we'll skip this check since admin functionality isn't in the domain model yet
-		
+		if !user.IsVerified() {
+			m.writeErrorResponse(w, "Please verify your email address before creating links", http.StatusForbidden)
+			return
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
 
+// RequireTwoFactor rejects users who must have two-factor authentication but have
+// not enabled it yet; requiredForAll applies the rule to every account. It must run
+// after RequireAuth.
+func (m *AuthMiddleware) RequireTwoFactor(requiredForAll bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := GetUserFromContext(r.Context())
+			if user == nil {
+				m.writeErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+				return
+			}
+
+			if (requiredForAll || user.TwoFactorRequired) && !user.IsTwoFactorEnabled() {
+				m.writeErrorResponse(w, "Two-factor authentication must be enabled for this account", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireScope limits requests made with an API key to keys granted the scope.
+// Signed-in sessions hold every scope. It must run after RequireAuth.
+func (m *AuthMiddleware) RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if apiKey := GetAPIKeyFromContext(r.Context()); apiKey != nil && !apiKey.HasScope(scope) {
+				m.writeErrorResponse(w, "API key lacks the "+scope+" scope", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSession rejects API keys on endpoints that need a signed-in user, such as
+// managing the keys themselves. It must run after RequireAuth.
+func (m *AuthMiddleware) RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if GetAPIKeyFromContext(r.Context()) != nil {
+			m.writeErrorResponse(w, "This endpoint cannot be used with an API key", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sessionActive reports whether the session an access token was issued for is
+// neither revoked nor expired. Active sessions are cached for sessionCheckTTL.
+func (m *AuthMiddleware) sessionActive(ctx context.Context, claims *domain.TokenClaims) bool {
+	if claims.SessionID == "" {
+		return false
+	}
+
+	if userID, err := m.cache.GetSession(ctx, claims.SessionID); err == nil {
+		return userID == claims.UserID
+	}
+
+	session, err := m.sessionRepo.GetByFamilyID(ctx, claims.SessionID)
+	if err != nil || session.UserID != claims.UserID {
+		return false
+	}
+	now := time.Now()
+	if !session.IsActive(now) {
+		return false
+	}
+
+	ttl := sessionCheckTTL
+	if remaining := session.ExpiresAt.Sub(now); remaining < ttl {
+		ttl = remaining
+	}
+	// A failed write only costs another lookup on the next request
+	_ = m.cache.SetSession(ctx, session.FamilyID, session.UserID, ttl)
+	return true
+}
+
+func (m *AuthMiddleware) requireAPIKey(w http.ResponseWriter, r *http.Request, key string, next http.Handler) {
+	if m.apiKeyService == nil {
+		m.writeErrorResponse(w, "API keys are not supported", http.StatusUnauthorized)
+		return
+	}
+
+	apiKey, err := m.apiKeyService.AuthenticateAPIKey(r.Context(), key, ClientIPAddress(r))
+	if err != nil {
+		if err == domain.ErrInvalidAPIKey {
+			m.writeErrorResponse(w, "Invalid API key", http.StatusUnauthorized)
+		} else {
+			m.writeErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	user, err := m.userRepo.GetByID(r.Context(), apiKey.UserID)
+	if err != nil {
+		m.writeErrorResponse(w, "User not found", http.StatusUnauthorized)
+		return
+	}
+
+	if !user.IsActive {
+		m.writeErrorResponse(w, "User account is inactive", http.StatusUnauthorized)
+		return
+	}
+
+	// Add user and key to context
+	ctx := context.WithValue(r.Context(), "user", user)
+	ctx = context.WithValue(ctx, "user_id", user.ID)
+	ctx = context.WithValue(ctx, "api_key", apiKey)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// extractAPIKey reads "Authorization: ApiKey <key>" or the X-API-Key header
+func (m *AuthMiddleware) extractAPIKey(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader != "" {
+		parts := strings.Split(authHeader, " ")
+		if len(parts) == 2 && strings.ToLower(parts[0]) == "apikey" {
+			return parts[1]
+		}
+	}
+
+	return r.Header.Get("X-API-Key")
+}
+
 func (m *AuthMiddleware) extractToken(r *http.Request) string {
 	// Check Authorization header
 	authHeader := r.Header.Get("Authorization")
@@ -137,6 +325,15 @@ func GetUserIDFromContext(ctx context.Context) uint {
 	return 0
 }
 
+// GetAPIKeyFromContext returns the API key the request was authenticated with, or
+// nil for signed-in sessions and anonymous requests
+func GetAPIKeyFromContext(ctx context.Context) *domain.APIKey {
+	if apiKey, ok := ctx.Value("api_key").(*domain.APIKey); ok {
+		return apiKey
+	}
+	return nil
+}
+
 func IsAuthenticated(ctx context.Context) bool {
 	return GetUserFromContext(ctx) != nil
 }
\ No newline at end of file
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -17,6 +18,9 @@ type AuthMiddlewareTestSuite struct {
 	middleware   *AuthMiddleware
 	mockJWT      *MockJWTService
 	mockUserRepo *MockUserRepository
+	mockSessions *MockSessionRepository
+	mockCache    *MockCacheService
+	mockAPIKeys  *MockAPIKeyService
 }
 
 func TestAuthMiddlewareTestSuite(t *testing.T) {
@@ -26,7 +30,15 @@ func TestAuthMiddlewareTestSuite(t *testing.T) {
 func (suite *AuthMiddlewareTestSuite) SetupTest() {
 	suite.mockJWT = &MockJWTService{}
 	suite.mockUserRepo = &MockUserRepository{}
-	suite.middleware = NewAuthMiddleware(suite.mockJWT, suite.mockUserRepo)
+	suite.mockSessions = &MockSessionRepository{}
+	suite.mockCache = &MockCacheService{}
+	suite.mockAPIKeys = &MockAPIKeyService{}
+	suite.middleware = NewAuthMiddleware(suite.mockJWT, suite.mockUserRepo, suite.mockSessions, suite.mockCache, suite.mockAPIKeys)
+}
+
+// expectCachedSession marks the token's session as recently found active
+func (suite *AuthMiddlewareTestSuite) expectCachedSession(userID uint) {
+	suite.mockCache.On("GetSession", mock.Anything, "family-1").Return(userID, nil)
 }
 
 func (suite *AuthMiddlewareTestSuite) TestRequireAuth_Success() {
@@ -40,10 +52,12 @@ func (suite *AuthMiddlewareTestSuite) TestRequireAuth_Success() {
 	}
 	
 	claims := &domain.TokenClaims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: "family-1",
 	}
 
 	suite.mockJWT.On("ValidateAccessToken", token).Return(claims, nil)
+	suite.expectCachedSession(userID)
 	suite.mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
 
 	// Create handler
@@ -122,10 +136,12 @@ func (suite *AuthMiddlewareTestSuite) TestRequireAuth_UserNotFound() {
 	userID := uint(1)
 	
 	claims := &domain.TokenClaims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: "family-1",
 	}
 
 	suite.mockJWT.On("ValidateAccessToken", token).Return(claims, nil)
+	suite.expectCachedSession(userID)
 	suite.mockUserRepo.On("GetByID", mock.Anything, userID).Return(nil, assert.AnError)
 
 	// Create handler
@@ -159,10 +175,12 @@ func (suite *AuthMiddlewareTestSuite) TestRequireAuth_InactiveUser() {
 	}
 	
 	claims := &domain.TokenClaims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: "family-1",
 	}
 
 	suite.mockJWT.On("ValidateAccessToken", token).Return(claims, nil)
+	suite.expectCachedSession(userID)
 	suite.mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
 
 	// Create handler
@@ -185,6 +203,86 @@ func (suite *AuthMiddlewareTestSuite) TestRequireAuth_InactiveUser() {
 	suite.mockUserRepo.AssertExpectations(suite.T())
 }
 
+func (suite *AuthMiddlewareTestSuite) TestRequireAuth_LooksUpUncachedSession() {
+	token := "valid-token"
+	userID := uint(1)
+	user := &domain.User{ID: userID, IsActive: true}
+	session := &domain.Session{ID: 7, UserID: userID, FamilyID: "family-1", ExpiresAt: time.Now().Add(time.Hour)}
+
+	suite.mockJWT.On("ValidateAccessToken", token).Return(&domain.TokenClaims{UserID: userID, SessionID: "family-1"}, nil)
+	suite.mockCache.On("GetSession", mock.Anything, "family-1").Return(uint(0), assert.AnError)
+	suite.mockSessions.On("GetByFamilyID", mock.Anything, "family-1").Return(session, nil)
+	suite.mockCache.On("SetSession", mock.Anything, "family-1", userID, sessionCheckTTL).Return(nil)
+	suite.mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
+
+	handler := suite.middleware.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(suite.T(), http.StatusOK, rr.Code)
+	suite.mockSessions.AssertExpectations(suite.T())
+	suite.mockCache.AssertExpectations(suite.T())
+}
+
+func (suite *AuthMiddlewareTestSuite) TestRequireAuth_EndedSession() {
+	revokedAt := time.Now()
+	tests := []struct {
+		name    string
+		claims  *domain.TokenClaims
+		session *domain.Session
+	}{
+		{
+			name:   "no session in token",
+			claims: &domain.TokenClaims{UserID: 1},
+		},
+		{
+			name:    "revoked",
+			claims:  &domain.TokenClaims{UserID: 1, SessionID: "family-1"},
+			session: &domain.Session{UserID: 1, FamilyID: "family-1", RevokedAt: &revokedAt, ExpiresAt: time.Now().Add(time.Hour)},
+		},
+		{
+			name:    "expired",
+			claims:  &domain.TokenClaims{UserID: 1, SessionID: "family-1"},
+			session: &domain.Session{UserID: 1, FamilyID: "family-1", ExpiresAt: time.Now().Add(-time.Minute)},
+		},
+		{
+			name:    "another user's session",
+			claims:  &domain.TokenClaims{UserID: 1, SessionID: "family-1"},
+			session: &domain.Session{UserID: 2, FamilyID: "family-1", ExpiresAt: time.Now().Add(time.Hour)},
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.SetupTest()
+			suite.mockJWT.On("ValidateAccessToken", "valid-token").Return(tt.claims, nil)
+			suite.mockCache.On("GetSession", mock.Anything, "family-1").Return(uint(0), assert.AnError)
+			if tt.session != nil {
+				suite.mockSessions.On("GetByFamilyID", mock.Anything, "family-1").Return(tt.session, nil)
+			}
+
+			handler := suite.middleware.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				suite.T().Error("Handler should not be called")
+			}))
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer valid-token")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(suite.T(), http.StatusUnauthorized, rr.Code)
+			assert.Contains(suite.T(), rr.Body.String(), "Session has ended")
+			suite.mockCache.AssertNotCalled(suite.T(), "SetSession", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			suite.mockUserRepo.AssertNotCalled(suite.T(), "GetByID", mock.Anything, mock.Anything)
+		})
+	}
+}
+
 func (suite *AuthMiddlewareTestSuite) TestOptionalAuth_WithValidToken() {
 	// Setup
 	token := "valid-token"
@@ -196,10 +294,12 @@ func (suite *AuthMiddlewareTestSuite) TestOptionalAuth_WithValidToken() {
 	}
 	
 	claims := &domain.TokenClaims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: "family-1",
 	}
 
 	suite.mockJWT.On("ValidateAccessToken", token).Return(claims, nil)
+	suite.expectCachedSession(userID)
 	suite.mockUserRepo.On("GetByID", mock.Anything, userID).Return(user, nil)
 
 	// Create handler
@@ -278,6 +378,166 @@ func (suite *AuthMiddlewareTestSuite) TestOptionalAuth_WithInvalidToken() {
 	suite.mockJWT.AssertExpectations(suite.T())
 }
 
+func (suite *AuthMiddlewareTestSuite) TestRequireVerifiedEmail() {
+	handler := suite.middleware.RequireVerifiedEmail(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	serve := func(user *domain.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/urls", nil)
+		if user != nil {
+			req = req.WithContext(context.WithValue(req.Context(), "user", user))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(&domain.User{ID: 1, IsActive: true})
+	assert.Equal(suite.T(), http.StatusForbidden, rr.Code)
+	assert.Contains(suite.T(), rr.Body.String(), "verify your email")
+
+	now := time.Now()
+	rr = serve(&domain.User{ID: 1, IsActive: true, VerifiedAt: &now})
+	assert.Equal(suite.T(), http.StatusCreated, rr.Code)
+
+	rr = serve(nil)
+	assert.Equal(suite.T(), http.StatusUnauthorized, rr.Code)
+}
+
+func (suite *AuthMiddlewareTestSuite) TestRequireAuth_APIKey() {
+	user := &domain.User{ID: 1, Email: "test@example.com", IsActive: true}
+	apiKey := &domain.APIKey{ID: 7, UserID: 1, Scopes: domain.APIScopes{domain.ScopeURLsRead}}
+
+	suite.mockAPIKeys.On("AuthenticateAPIKey", mock.Anything, "usk_valid", "192.0.2.1").Return(apiKey, nil)
+	suite.mockUserRepo.On("GetByID", mock.Anything, uint(1)).Return(user, nil)
+
+	handler := suite.middleware.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(suite.T(), uint(1), GetUserIDFromContext(r.Context()))
+		assert.Equal(suite.T(), apiKey, GetAPIKeyFromContext(r.Context()))
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, setHeader := range []func(*http.Request){
+		func(req *http.Request) { req.Header.Set("X-API-Key", "usk_valid") },
+		func(req *http.Request) { req.Header.Set("Authorization", "ApiKey usk_valid") },
+	} {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		setHeader(req)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(suite.T(), http.StatusOK, rr.Code)
+	}
+	suite.mockJWT.AssertNotCalled(suite.T(), "ValidateAccessToken", mock.Anything)
+}
+
+func (suite *AuthMiddlewareTestSuite) TestRequireAuth_InvalidAPIKey() {
+	suite.mockAPIKeys.On("AuthenticateAPIKey", mock.Anything, "usk_revoked", mock.Anything).Return(nil, domain.ErrInvalidAPIKey)
+
+	handler := suite.middleware.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.T().Error("Handler should not be called")
+	}))
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("X-API-Key", "usk_revoked")
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(suite.T(), http.StatusUnauthorized, rr.Code)
+	assert.Contains(suite.T(), rr.Body.String(), "Invalid API key")
+}
+
+func (suite *AuthMiddlewareTestSuite) TestRequireScope() {
+	handler := suite.middleware.RequireScope(domain.ScopeURLsWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(apiKey *domain.APIKey) *httptest.ResponseRecorder {
+		ctx := context.WithValue(context.Background(), "user_id", uint(1))
+		if apiKey != nil {
+			ctx = context.WithValue(ctx, "api_key", apiKey)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("POST", "/api/v1/urls", nil).WithContext(ctx))
+		return rr
+	}
+
+	rr := serve(&domain.APIKey{Scopes: domain.APIScopes{domain.ScopeURLsRead}})
+	assert.Equal(suite.T(), http.StatusForbidden, rr.Code)
+	assert.Contains(suite.T(), rr.Body.String(), "urls:write")
+
+	rr = serve(&domain.APIKey{Scopes: domain.APIScopes{domain.ScopeURLsRead, domain.ScopeURLsWrite}})
+	assert.Equal(suite.T(), http.StatusOK, rr.Code)
+
+	// Signed-in sessions are not limited by scopes
+	rr = serve(nil)
+	assert.Equal(suite.T(), http.StatusOK, rr.Code)
+}
+
+func (suite *AuthMiddlewareTestSuite) TestRequireSession() {
+	handler := suite.middleware.RequireSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/api/v1/api-keys", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "api_key", &domain.APIKey{ID: 7}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(suite.T(), http.StatusForbidden, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/api-keys", nil))
+	assert.Equal(suite.T(), http.StatusOK, rr.Code)
+}
+
+func (suite *AuthMiddlewareTestSuite) TestRequireRole() {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serve := func(middleware func(http.Handler) http.Handler, user *domain.User) int {
+		req := httptest.NewRequest("GET", "/api/v1/admin/users", nil)
+		if user != nil {
+			req = req.WithContext(context.WithValue(req.Context(), "user", user))
+		}
+		rr := httptest.NewRecorder()
+		middleware(ok).ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	staff := suite.middleware.RequireRole(domain.RoleSupport, domain.RoleAdmin)
+	assert.Equal(suite.T(), http.StatusForbidden, serve(staff, &domain.User{ID: 1}))
+	assert.Equal(suite.T(), http.StatusForbidden, serve(staff, &domain.User{ID: 1, Role: domain.RoleUser}))
+	assert.Equal(suite.T(), http.StatusOK, serve(staff, &domain.User{ID: 1, Role: domain.RoleSupport}))
+	assert.Equal(suite.T(), http.StatusOK, serve(staff, &domain.User{ID: 1, Role: domain.RoleAdmin}))
+	assert.Equal(suite.T(), http.StatusUnauthorized, serve(staff, nil))
+
+	assert.Equal(suite.T(), http.StatusForbidden, serve(suite.middleware.AdminOnly, &domain.User{ID: 1, Role: domain.RoleSupport}))
+	assert.Equal(suite.T(), http.StatusOK, serve(suite.middleware.AdminOnly, &domain.User{ID: 1, Role: domain.RoleAdmin}))
+}
+
+func (suite *AuthMiddlewareTestSuite) TestRequireTwoFactor() {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	enabledAt := time.Now()
+
+	serve := func(requiredForAll bool, user *domain.User) int {
+		req := httptest.NewRequest("GET", "/api/v1/urls", nil)
+		req = req.WithContext(context.WithValue(req.Context(), "user", user))
+		rr := httptest.NewRecorder()
+		suite.middleware.RequireTwoFactor(requiredForAll)(ok).ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(suite.T(), http.StatusOK, serve(false, &domain.User{ID: 1}))
+	assert.Equal(suite.T(), http.StatusForbidden, serve(false, &domain.User{ID: 1, TwoFactorRequired: true}))
+	assert.Equal(suite.T(), http.StatusForbidden, serve(true, &domain.User{ID: 1}))
+	assert.Equal(suite.T(), http.StatusOK, serve(true, &domain.User{ID: 1, TOTPEnabledAt: &enabledAt}))
+}
+
 func (suite *AuthMiddlewareTestSuite) TestExtractToken_FromHeader() {
 	req := httptest.NewRequest("GET", "/test", nil)
 	req.Header.Set("Authorization", "Bearer test-token")
@@ -323,13 +583,13 @@ type MockJWTService struct {
 	mock.Mock
 }
 
-func (m *MockJWTService) GenerateAccessToken(userID uint, email string) (string, error) {
-	args := m.Called(userID, email)
+func (m *MockJWTService) GenerateAccessToken(userID uint, email, role, sessionID string) (string, error) {
+	args := m.Called(userID, email, role, sessionID)
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockJWTService) GenerateRefreshToken(userID uint) (string, error) {
-	args := m.Called(userID)
+func (m *MockJWTService) GenerateRefreshToken(userID uint, sessionID string) (string, error) {
+	args := m.Called(userID, sessionID)
 	return args.String(0), args.Error(1)
 }
 
@@ -349,6 +609,11 @@ func (m *MockJWTService) ValidateRefreshToken(token string) (*domain.TokenClaims
 	return args.Get(0).(*domain.TokenClaims), args.Error(1)
 }
 
+func (m *MockJWTService) PublicKeys() *domain.JSONWebKeySet {
+	args := m.Called()
+	return args.Get(0).(*domain.JSONWebKeySet)
+}
+
 type MockUserRepository struct {
 	mock.Mock
 }
@@ -384,6 +649,11 @@ func (m *MockUserRepository) Delete(ctx context.Context, id uint) error {
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) AdvanceTOTPStep(ctx context.Context, id uint, step int64) (bool, error) {
+	args := m.Called(ctx, id, step)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *MockUserRepository) Exists(ctx context.Context, email string) (bool, error) {
 	args := m.Called(ctx, email)
 	return args.Bool(0), args.Error(1)
@@ -394,10 +664,97 @@ func (m *MockUserRepository) List(ctx context.Context, offset, limit int) ([]*do
 	return args.Get(0).([]*domain.User), args.Get(1).(int64), args.Error(2)
 }
 
+func (m *MockUserRepository) Search(ctx context.Context, filter domain.UserFilter, offset, limit int) ([]*domain.User, int64, error) {
+	args := m.Called(ctx, filter, offset, limit)
+	return args.Get(0).([]*domain.User), args.Get(1).(int64), args.Error(2)
+}
+
 func (m *MockUserRepository) GetUserStats(ctx context.Context, userID uint) (*domain.UserStats, error) {
 	args := m.Called(ctx, userID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*domain.UserStats), args.Error(1)
-}
\ No newline at end of file
+}
+type MockAPIKeyService struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyService) CreateAPIKey(ctx context.Context, userID uint, req domain.CreateAPIKeyRequest) (*domain.CreatedAPIKey, error) {
+	args := m.Called(ctx, userID, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.CreatedAPIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) ListAPIKeys(ctx context.Context, userID uint) ([]*domain.APIKey, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) RenameAPIKey(ctx context.Context, id uint, userID uint, req domain.UpdateAPIKeyRequest) (*domain.APIKey, error) {
+	args := m.Called(ctx, id, userID, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) RevokeAPIKey(ctx context.Context, id uint, userID uint) error {
+	args := m.Called(ctx, id, userID)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyService) AuthenticateAPIKey(ctx context.Context, key, clientIP string) (*domain.APIKey, error) {
+	args := m.Called(ctx, key, clientIP)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+type MockSessionRepository struct {
+	mock.Mock
+}
+
+func (m *MockSessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	args := m.Called(ctx, session)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) GetByID(ctx context.Context, id uint) (*domain.Session, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) GetByFamilyID(ctx context.Context, familyID string) (*domain.Session, error) {
+	args := m.Called(ctx, familyID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) ListActiveByUser(ctx context.Context, userID uint, now time.Time) ([]*domain.Session, error) {
+	args := m.Called(ctx, userID, now)
+	return args.Get(0).([]*domain.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) Rotate(ctx context.Context, session *domain.Session, previousHash string) (bool, error) {
+	args := m.Called(ctx, session, previousHash)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockSessionRepository) Revoke(ctx context.Context, id uint, reason string) error {
+	args := m.Called(ctx, id, reason)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) RevokeAllByUser(ctx context.Context, userID uint, reason string) error {
+	args := m.Called(ctx, userID, reason)
+	return args.Error(0)
+}
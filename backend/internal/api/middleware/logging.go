@@ -8,6 +8,10 @@ import (
 	"log"
 	"net/http"
 	"time"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+
+	"url-shortener/internal/core/domain"
 )
 
 type LoggingConfig struct {
@@ -80,6 +84,12 @@ func (rw *responseWriter) Write(data []byte) (int, error) {
 	return size, err
 }
 
+// Unwrap exposes the underlying writer to http.ResponseController, so handlers
+// can flush and adjust deadlines through the logging middleware
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func (m *LoggingMiddleware) Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Skip logging for certain paths
@@ -198,6 +208,24 @@ func GetRequestIDFromContext(ctx context.Context) string {
 	return ""
 }
 
+// RequestInfo attaches the request ID and client IP to the context, so the
+// audit log can record where an action came from. It runs after the logging
+// middleware so the ID matches the logs and the X-Request-ID header.
+func RequestInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := GetRequestIDFromContext(r.Context())
+		if requestID == "" {
+			requestID = chimiddleware.GetReqID(r.Context())
+		}
+
+		ctx := domain.WithRequestInfo(r.Context(), domain.RequestInfo{
+			RequestID: requestID,
+			IPAddress: ClientIPAddress(r),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 // Convenience function for easy setup
 func Logging(logger Logger) func(http.Handler) http.Handler {
 	config := &LoggingConfig{
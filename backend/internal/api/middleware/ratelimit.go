@@ -2,8 +2,10 @@ package middleware
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"url-shortener/internal/core/ports"
@@ -160,6 +162,38 @@ func getClientIP(r *http.Request) string {
 	return ip
 }
 
+// ClientIPAddress returns the bare client address from X-Real-IP, the first entry of
+// X-Forwarded-For or RemoteAddr, or "" when none of them holds a valid IP
+func ClientIPAddress(r *http.Request) string {
+	candidates := []string{r.Header.Get("X-Real-IP"), r.Header.Get("X-Forwarded-For"), r.RemoteAddr}
+	for _, value := range candidates {
+		if i := strings.Index(value, ","); i >= 0 {
+			value = value[:i]
+		}
+		value = strings.TrimSpace(value)
+		if host, _, err := net.SplitHostPort(value); err == nil {
+			value = host
+		}
+		if ip := net.ParseIP(strings.Trim(value, "[]")); ip != nil {
+			return ip.String()
+		}
+	}
+	return ""
+}
+
+// IsHTTPS reports whether the client reached us over HTTPS, directly or through a
+// proxy that terminated TLS and set X-Forwarded-Proto
+func IsHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	proto := r.Header.Get("X-Forwarded-Proto")
+	if i := strings.Index(proto, ","); i >= 0 {
+		proto = proto[:i]
+	}
+	return strings.EqualFold(strings.TrimSpace(proto), "https")
+}
+
 // Predefined rate limit configurations
 func GlobalRateLimit(cache ports.CacheService) func(http.Handler) http.Handler {
 	config := &RateLimitConfig{
@@ -337,6 +337,19 @@ func (suite *RateLimitMiddlewareTestSuite) TestDefaultRateLimitExceededHandler()
 	assert.Equal(suite.T(), "60", rr.Header().Get("Retry-After"))
 }
 
+func TestIsHTTPS(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	assert.False(t, IsHTTPS(req))
+
+	req.Header.Set("X-Forwarded-Proto", "HTTPS, http")
+	assert.True(t, IsHTTPS(req))
+
+	req.Header.Set("X-Forwarded-Proto", "http")
+	assert.False(t, IsHTTPS(req))
+
+	assert.True(t, IsHTTPS(httptest.NewRequest("GET", "https://example.com/", nil)))
+}
+
 // Mock cache service for testing
 type MockCacheService struct {
 	mock.Mock
@@ -361,6 +374,10 @@ func (m *MockCacheService) Get(ctx context.Context, key string) (string, error)
 	return "", nil
 }
 
+func (m *MockCacheService) GetDel(ctx context.Context, key string) (string, error) {
+	return "", nil
+}
+
 func (m *MockCacheService) Delete(ctx context.Context, key string) error {
 	return nil
 }
@@ -426,17 +443,23 @@ func (m *MockCacheService) InvalidateURL(ctx context.Context, shortCode string)
 }
 
 func (m *MockCacheService) SetSession(ctx context.Context, token string, userID uint, expiration time.Duration) error {
-	return nil
+	args := m.Called(ctx, token, userID, expiration)
+	return args.Error(0)
 }
 
 func (m *MockCacheService) GetSession(ctx context.Context, token string) (uint, error) {
-	return 0, nil
+	args := m.Called(ctx, token)
+	return args.Get(0).(uint), args.Error(1)
 }
 
 func (m *MockCacheService) InvalidateSession(ctx context.Context, token string) error {
 	return nil
 }
 
+func (m *MockCacheService) InvalidateUserSessions(ctx context.Context, userID uint) error {
+	return nil
+}
+
 func (m *MockCacheService) CacheClickCount(ctx context.Context, shortCode string, count int64) error {
 	return nil
 }
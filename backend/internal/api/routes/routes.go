@@ -8,6 +8,7 @@ import (
 	
 	"url-shortener/internal/api/handlers"
 	"url-shortener/internal/api/middleware"
+	"url-shortener/internal/core/domain"
 	"url-shortener/internal/core/ports"
 )
 
@@ -17,6 +18,11 @@ type Config struct {
 	URLHandler       *handlers.URLHandler
 	AnalyticsHandler *handlers.AnalyticsHandler
 	QRHandler        *handlers.QRHandler
+	APIKeyHandler    *handlers.APIKeyHandler
+	AdminHandler     *handlers.AdminHandler
+	WorkspaceHandler *handlers.WorkspaceHandler
+	AuditHandler     *handlers.AuditHandler
+	JWKSHandler      *handlers.JWKSHandler
 	
 	// Middleware
 	AuthMiddleware     *middleware.AuthMiddleware
@@ -26,10 +32,16 @@ type Config struct {
 	// Services (for rate limiting)
 	CacheService ports.CacheService
 	
+	// Optional handlers supplied by the server binary
+	HealthHandler http.HandlerFunc
+	DebugHandler  http.Handler
+	
 	// Configuration
 	EnableCORS   bool
 	EnableLogging bool
 	AllowedOrigins []string
+	RequireVerifiedEmail bool // for creating links
+	RequireTwoFactor bool // for every account, not just those flagged individually
 }
 
 type Router struct {
@@ -70,13 +82,33 @@ func (r *Router) SetupRoutes() http.Handler {
 		}
 	}
 	
+	// Request ID and client IP for the audit log
+	r.chi.Use(middleware.RequestInfo)
+	
 	// Global rate limiting
 	if r.config.CacheService != nil {
 		r.chi.Use(middleware.GlobalRateLimit(r.config.CacheService))
 	}
 	
 	// Health check endpoint
-	r.chi.Get("/health", r.healthCheck)
+	if r.config.HealthHandler != nil {
+		r.chi.Get("/health", r.config.HealthHandler)
+	} else {
+		r.chi.Get("/health", r.healthCheck)
+	}
+	
+	// Debug endpoints (only mounted when provided)
+	if r.config.DebugHandler != nil {
+		r.chi.Mount("/debug", r.config.DebugHandler)
+	}
+	
+	// Service information
+	r.chi.Get("/", r.index)
+	
+	// Public keys for verifying access tokens
+	if r.config.JWKSHandler != nil {
+		r.chi.Get("/.well-known/jwks.json", r.config.JWKSHandler.GetKeySet)
+	}
 	
 	// API versioning
 	r.chi.Route("/api/v1", func(apiRouter chi.Router) {
@@ -86,6 +118,7 @@ func (r *Router) SetupRoutes() http.Handler {
 	// Short URL redirection (no API prefix)
 	if r.config.URLHandler != nil {
 		r.chi.Get("/{shortCode}", r.config.URLHandler.RedirectURL)
+		r.chi.Post("/{shortCode}", r.config.URLHandler.UnlockURL)
 	}
 	
 	return r.chi
@@ -102,17 +135,34 @@ func (r *Router) setupV1Routes(apiRouter chi.Router) {
 			
 			authRouter.Post("/register", r.config.AuthHandler.Register)
 			authRouter.Post("/login", r.config.AuthHandler.Login)
+			authRouter.Post("/login/2fa", r.config.AuthHandler.LoginTwoFactor)
+			authRouter.Get("/oidc/login", r.config.AuthHandler.StartOIDCLogin)
+			authRouter.Post("/oidc/callback", r.config.AuthHandler.CompleteOIDCLogin)
 			authRouter.Post("/refresh", r.config.AuthHandler.RefreshToken)
+			authRouter.Post("/forgot-password", r.config.AuthHandler.ForgotPassword)
+			authRouter.Post("/reset-password", r.config.AuthHandler.ResetPassword)
+			authRouter.Get("/verify", r.config.AuthHandler.VerifyEmail)
 			
 			// Routes requiring authentication
 			if r.config.AuthMiddleware != nil {
 				authRouter.Group(func(protectedRouter chi.Router) {
 					protectedRouter.Use(r.config.AuthMiddleware.RequireAuth)
+					protectedRouter.Use(r.config.AuthMiddleware.RequireSession)
 					protectedRouter.Post("/logout", r.config.AuthHandler.Logout)
+					protectedRouter.Get("/sessions", r.config.AuthHandler.ListSessions)
+					protectedRouter.Delete("/sessions", r.config.AuthHandler.RevokeAllSessions)
+					protectedRouter.Delete("/sessions/{id}", r.config.AuthHandler.RevokeSession)
 					protectedRouter.Get("/profile", r.config.AuthHandler.GetProfile)
 					protectedRouter.Put("/profile", r.config.AuthHandler.UpdateProfile)
 					protectedRouter.Post("/change-password", r.config.AuthHandler.ChangePassword)
 					protectedRouter.Get("/validate", r.config.AuthHandler.ValidateToken)
+					protectedRouter.Post("/verify/resend", r.config.AuthHandler.ResendVerification)
+					
+					// Two-factor management stays reachable when two-factor is enforced
+					protectedRouter.Post("/2fa/setup", r.config.AuthHandler.SetupTwoFactor)
+					protectedRouter.Post("/2fa/enable", r.config.AuthHandler.EnableTwoFactor)
+					protectedRouter.Post("/2fa/disable", r.config.AuthHandler.DisableTwoFactor)
+					protectedRouter.Post("/2fa/recovery-codes", r.config.AuthHandler.RegenerateRecoveryCodes)
 				})
 			}
 		})
@@ -128,9 +178,14 @@ func (r *Router) setupV1Routes(apiRouter chi.Router) {
 			if r.config.AuthMiddleware != nil {
 				urlRouter.Group(func(protectedRouter chi.Router) {
 					protectedRouter.Use(r.config.AuthMiddleware.RequireAuth)
+					protectedRouter.Use(r.config.AuthMiddleware.RequireTwoFactor(r.config.RequireTwoFactor))
 					
 					// URL creation with rate limiting
 					protectedRouter.Group(func(createRouter chi.Router) {
+						createRouter.Use(r.config.AuthMiddleware.RequireScope(domain.ScopeURLsWrite))
+						if r.config.RequireVerifiedEmail {
+							createRouter.Use(r.config.AuthMiddleware.RequireVerifiedEmail)
+						}
 						if r.config.CacheService != nil {
 							createRouter.Use(middleware.URLCreationRateLimit(r.config.CacheService))
 						}
@@ -138,23 +193,71 @@ func (r *Router) setupV1Routes(apiRouter chi.Router) {
 					})
 					
 					// URL management
-					protectedRouter.Get("/", r.config.URLHandler.GetUserURLs)
-					protectedRouter.Get("/{id}", r.config.URLHandler.GetURL)
-					protectedRouter.Put("/{id}", r.config.URLHandler.UpdateURL)
-					protectedRouter.Delete("/{id}", r.config.URLHandler.DeleteURL)
+					protectedRouter.Group(func(readRouter chi.Router) {
+						readRouter.Use(r.config.AuthMiddleware.RequireScope(domain.ScopeURLsRead))
+						readRouter.Get("/", r.config.URLHandler.GetUserURLs)
+						readRouter.Get("/{id}", r.config.URLHandler.GetURL)
+						readRouter.Get("/{id}/history", r.config.URLHandler.GetURLHistory)
+					})
+					protectedRouter.Group(func(writeRouter chi.Router) {
+						writeRouter.Use(r.config.AuthMiddleware.RequireScope(domain.ScopeURLsWrite))
+						writeRouter.Put("/{id}", r.config.URLHandler.UpdateURL)
+						writeRouter.Delete("/{id}", r.config.URLHandler.DeleteURL)
+						writeRouter.Post("/{id}/history/{revisionID}/rollback", r.config.URLHandler.RollbackURL)
+					})
 				})
 			}
 		})
 	}
 	
+	// Personal API keys can only be managed from a signed-in session
+	if r.config.APIKeyHandler != nil && r.config.AuthMiddleware != nil {
+		apiRouter.Route("/api-keys", func(keyRouter chi.Router) {
+			keyRouter.Use(r.config.AuthMiddleware.RequireAuth)
+			keyRouter.Use(r.config.AuthMiddleware.RequireSession)
+			keyRouter.Use(r.config.AuthMiddleware.RequireTwoFactor(r.config.RequireTwoFactor))
+
+			keyRouter.Get("/", r.config.APIKeyHandler.ListAPIKeys)
+			keyRouter.Post("/", r.config.APIKeyHandler.CreateAPIKey)
+			keyRouter.Patch("/{id}", r.config.APIKeyHandler.RenameAPIKey)
+			keyRouter.Delete("/{id}", r.config.APIKeyHandler.RevokeAPIKey)
+		})
+	}
+	
+	// Workspaces are managed from a signed-in session
+	if r.config.WorkspaceHandler != nil && r.config.AuthMiddleware != nil {
+		apiRouter.Route("/workspaces", func(workspaceRouter chi.Router) {
+			workspaceRouter.Use(r.config.AuthMiddleware.RequireAuth)
+			workspaceRouter.Use(r.config.AuthMiddleware.RequireSession)
+			workspaceRouter.Use(r.config.AuthMiddleware.RequireTwoFactor(r.config.RequireTwoFactor))
+
+			workspaceRouter.Get("/", r.config.WorkspaceHandler.ListWorkspaces)
+			workspaceRouter.Post("/", r.config.WorkspaceHandler.CreateWorkspace)
+			workspaceRouter.Post("/invitations/accept", r.config.WorkspaceHandler.AcceptInvitation)
+
+			workspaceRouter.Route("/{id}", func(wsRouter chi.Router) {
+				wsRouter.Get("/", r.config.WorkspaceHandler.GetWorkspace)
+				wsRouter.Patch("/", r.config.WorkspaceHandler.UpdateWorkspace)
+				wsRouter.Get("/urls", r.config.WorkspaceHandler.GetWorkspaceURLs)
+				wsRouter.Get("/members", r.config.WorkspaceHandler.ListMembers)
+				wsRouter.Patch("/members/{userID}", r.config.WorkspaceHandler.UpdateMemberRole)
+				wsRouter.Delete("/members/{userID}", r.config.WorkspaceHandler.RemoveMember)
+				wsRouter.Get("/invitations", r.config.WorkspaceHandler.ListInvitations)
+				wsRouter.Post("/invitations", r.config.WorkspaceHandler.InviteMember)
+				wsRouter.Delete("/invitations/{invitationID}", r.config.WorkspaceHandler.RevokeInvitation)
+			})
+		})
+	}
+	
 	// Analytics routes
 	if r.config.AnalyticsHandler != nil && r.config.AuthMiddleware != nil {
 		apiRouter.Route("/analytics", func(analyticsRouter chi.Router) {
 			analyticsRouter.Use(r.config.AuthMiddleware.RequireAuth)
+			analyticsRouter.Use(r.config.AuthMiddleware.RequireTwoFactor(r.config.RequireTwoFactor))
+			analyticsRouter.Use(r.config.AuthMiddleware.RequireScope(domain.ScopeAnalyticsRead))
 			
 			// Dashboard analytics
 			analyticsRouter.Get("/dashboard", r.config.AnalyticsHandler.GetDashboard)
-			analyticsRouter.Get("/global", r.config.AnalyticsHandler.GetGlobalStats)
 			analyticsRouter.Get("/top-urls", r.config.AnalyticsHandler.GetTopPerformingURLs)
 			analyticsRouter.Get("/export", r.config.AnalyticsHandler.ExportAnalytics)
 			
@@ -165,6 +268,7 @@ func (r *Router) setupV1Routes(apiRouter chi.Router) {
 				urlAnalyticsRouter.Get("/geo", r.config.AnalyticsHandler.GetGeographicStats)
 				urlAnalyticsRouter.Get("/devices", r.config.AnalyticsHandler.GetDeviceStats)
 				urlAnalyticsRouter.Get("/referrers", r.config.AnalyticsHandler.GetReferrerStats)
+				urlAnalyticsRouter.Get("/variants", r.config.AnalyticsHandler.GetVariantStats)
 			})
 		})
 	}
@@ -185,18 +289,57 @@ func (r *Router) setupV1Routes(apiRouter chi.Router) {
 					qrGenRouter.Post("/generate", r.config.QRHandler.GenerateQRCode)
 					qrGenRouter.Get("/{shortCode}", r.config.QRHandler.GenerateQRCodeForURL)
 				})
+
+				// Bulk generation for the signed-in user's links
+				qrRouter.Route("/batches", func(batchRouter chi.Router) {
+					batchRouter.Use(r.config.AuthMiddleware.RequireAuth)
+					batchRouter.Use(r.config.AuthMiddleware.RequireTwoFactor(r.config.RequireTwoFactor))
+					batchRouter.Use(r.config.AuthMiddleware.RequireScope(domain.ScopeURLsRead))
+
+					batchRouter.Post("/", r.config.QRHandler.CreateQRBatch)
+					batchRouter.Get("/{id}", r.config.QRHandler.GetQRBatch)
+					batchRouter.Get("/{id}/download", r.config.QRHandler.DownloadQRBatch)
+				})
 			}
 		})
 	}
 	
-	// Admin routes (future expansion)
-	if r.config.AuthMiddleware != nil && r.config.AnalyticsHandler != nil {
+	// Staff routes: support can look things up, only admins can change accounts
+	if r.config.AuthMiddleware != nil && (r.config.AdminHandler != nil || r.config.AnalyticsHandler != nil) {
 		apiRouter.Route("/admin", func(adminRouter chi.Router) {
 			adminRouter.Use(r.config.AuthMiddleware.RequireAuth)
-			// adminRouter.Use(r.config.AuthMiddleware.AdminOnly) // Enable when admin functionality is added
+			adminRouter.Use(r.config.AuthMiddleware.RequireSession)
+			adminRouter.Use(r.config.AuthMiddleware.RequireTwoFactor(r.config.RequireTwoFactor))
+			adminRouter.Use(r.config.AuthMiddleware.RequireRole(domain.RoleSupport, domain.RoleAdmin))
+			
+			if r.config.AnalyticsHandler != nil {
+				adminRouter.Get("/stats", r.config.AnalyticsHandler.GetGlobalStats)
+			}
+			
+			if r.config.AdminHandler != nil {
+				adminRouter.Get("/users", r.config.AdminHandler.ListUsers)
+				adminRouter.Get("/users/{id}", r.config.AdminHandler.GetUser)
+				adminRouter.Get("/users/{id}/urls", r.config.AdminHandler.GetUserURLs)
+				
+				adminRouter.Group(func(manageRouter chi.Router) {
+					manageRouter.Use(r.config.AuthMiddleware.AdminOnly)
+					manageRouter.Patch("/users/{id}", r.config.AdminHandler.UpdateUser)
+					manageRouter.Post("/users/{id}/deactivate", r.config.AdminHandler.DeactivateUser)
+					manageRouter.Post("/users/{id}/reactivate", r.config.AdminHandler.ReactivateUser)
+				})
+			}
+		})
+	}
+	
+	// The audit log is only for admins
+	if r.config.AuditHandler != nil && r.config.AuthMiddleware != nil {
+		apiRouter.Route("/audit", func(auditRouter chi.Router) {
+			auditRouter.Use(r.config.AuthMiddleware.RequireAuth)
+			auditRouter.Use(r.config.AuthMiddleware.RequireSession)
+			auditRouter.Use(r.config.AuthMiddleware.RequireTwoFactor(r.config.RequireTwoFactor))
+			auditRouter.Use(r.config.AuthMiddleware.AdminOnly)
 			
-			// Placeholder for admin endpoints
-			adminRouter.Get("/stats", r.config.AnalyticsHandler.GetGlobalStats)
+			auditRouter.Get("/", r.config.AuditHandler.ListEvents)
 		})
 	}
 }
@@ -212,6 +355,12 @@ func (r *Router) healthCheck(w http.ResponseWriter, req *http.Request) {
 	}`))
 }
 
+func (r *Router) index(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"URL Shortener API","version":"1.0.0"}`))
+}
+
 // Route helpers for testing and debugging
 
 func (r *Router) PrintRoutes() {
@@ -261,6 +410,31 @@ func (b *RouterBuilder) WithQRHandler(handler *handlers.QRHandler) *RouterBuilde
 	return b
 }
 
+func (b *RouterBuilder) WithAPIKeyHandler(handler *handlers.APIKeyHandler) *RouterBuilder {
+	b.config.APIKeyHandler = handler
+	return b
+}
+
+func (b *RouterBuilder) WithAdminHandler(handler *handlers.AdminHandler) *RouterBuilder {
+	b.config.AdminHandler = handler
+	return b
+}
+
+func (b *RouterBuilder) WithWorkspaceHandler(handler *handlers.WorkspaceHandler) *RouterBuilder {
+	b.config.WorkspaceHandler = handler
+	return b
+}
+
+func (b *RouterBuilder) WithAuditHandler(handler *handlers.AuditHandler) *RouterBuilder {
+	b.config.AuditHandler = handler
+	return b
+}
+
+func (b *RouterBuilder) WithJWKSHandler(handler *handlers.JWKSHandler) *RouterBuilder {
+	b.config.JWKSHandler = handler
+	return b
+}
+
 func (b *RouterBuilder) WithAuthMiddleware(middleware *middleware.AuthMiddleware) *RouterBuilder {
 	b.config.AuthMiddleware = middleware
 	return b
@@ -281,6 +455,16 @@ func (b *RouterBuilder) WithCacheService(service ports.CacheService) *RouterBuil
 	return b
 }
 
+func (b *RouterBuilder) WithHealthHandler(handler http.HandlerFunc) *RouterBuilder {
+	b.config.HealthHandler = handler
+	return b
+}
+
+func (b *RouterBuilder) WithDebugHandler(handler http.Handler) *RouterBuilder {
+	b.config.DebugHandler = handler
+	return b
+}
+
 func (b *RouterBuilder) WithCORS(enabled bool, origins ...string) *RouterBuilder {
 	b.config.EnableCORS = enabled
 	if len(origins) > 0 {
@@ -294,6 +478,18 @@ func (b *RouterBuilder) WithLogging(enabled bool) *RouterBuilder {
 	return b
 }
 
+func (b *RouterBuilder) WithEmailVerification(required bool) *RouterBuilder {
+	b.config.RequireVerifiedEmail = required
+	return b
+}
+
+// WithTwoFactorEnforcement makes every account enable two-factor authentication
+// before using links, analytics or API keys
+func (b *RouterBuilder) WithTwoFactorEnforcement(required bool) *RouterBuilder {
+	b.config.RequireTwoFactor = required
+	return b
+}
+
 func (b *RouterBuilder) Build() *Router {
 	return NewRouter(b.config)
 }
\ No newline at end of file
@@ -5,6 +5,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -100,4 +101,64 @@ func TestAPIVersioning(t *testing.T) {
 	// We expect 405 Method Not Allowed since this should be POST, not GET
 	// or 404 if no handlers are configured
 	assert.True(t, rr.Code == http.StatusMethodNotAllowed || rr.Code == http.StatusNotFound)
-}
\ No newline at end of file
+}
+func TestCustomHealthHandler(t *testing.T) {
+	router := NewRouterBuilder().
+		WithCORS(false).
+		WithLogging(false).
+		WithHealthHandler(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"unhealthy"}`))
+		}).
+		Build()
+
+	handler := router.SetupRoutes()
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(t, rr.Body.String(), "unhealthy")
+}
+
+func TestDebugHandlerMounted(t *testing.T) {
+	debug := chi.NewRouter()
+	debug.Get("/db-stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("stats"))
+	})
+
+	router := NewRouterBuilder().
+		WithCORS(false).
+		WithLogging(false).
+		WithDebugHandler(debug).
+		Build()
+
+	handler := router.SetupRoutes()
+
+	req := httptest.NewRequest("GET", "/debug/db-stats", nil)
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "stats", rr.Body.String())
+}
+
+func TestIndexRoute(t *testing.T) {
+	router := NewRouterBuilder().
+		WithCORS(false).
+		WithLogging(false).
+		Build()
+
+	handler := router.SetupRoutes()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "URL Shortener API")
+}
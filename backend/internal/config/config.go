@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
@@ -21,6 +22,11 @@ type Config struct {
 	Security SecurityConfig
 	Logging  LoggingConfig
 	Cache    CacheConfig
+	Clicks   ClickIngestionConfig
+	Rollups  RollupConfig
+	QRBatch  QRBatchConfig
+	Mail     MailConfig
+	OIDC     OIDCConfig
 }
 
 type ServerConfig struct {
@@ -38,6 +44,9 @@ type DatabaseConfig struct {
 	Password       string
 	MaxConnections int
 	MaxIdle        int
+	// AutoMigrate syncs the schema from the models on boot. Development only;
+	// everywhere else the schema is managed with cmd/migrate.
+	AutoMigrate bool
 }
 
 type RedisConfig struct {
@@ -52,6 +61,20 @@ type JWTConfig struct {
 	Secret        string
 	Expiry        time.Duration
 	RefreshExpiry time.Duration
+
+	// Algorithm signs new tokens: HS256 with Secret, or RS256 or EdDSA with a key from KeysDir
+	Algorithm string
+	// KeysDir holds PEM keys named <kid>.pem. Every key verifies tokens; SigningKeyID
+	// picks the private key that signs them, so keys can be rotated by adding a new
+	// one, switching SigningKeyID and removing the old one once its tokens expire.
+	KeysDir      string
+	SigningKeyID string
+	// PreviousSecrets still verify HS256 tokens after Secret changes, or after moving to a keyset
+	PreviousSecrets []string
+
+	Issuer          string
+	AccessAudience  string
+	RefreshAudience string
 }
 
 type CORSConfig struct {
@@ -67,8 +90,10 @@ type RateLimitConfig struct {
 }
 
 type ExternalConfig struct {
-	GeolocationAPIKey string
-	GeolocationAPIURL string
+	GeolocationAPIKey    string
+	GeolocationAPIURL    string
+	GeolocationDBPath    string // .mmdb or .csv, takes precedence over the API
+	GeolocationCacheSize int
 }
 
 type AppConfig struct {
@@ -83,6 +108,12 @@ type SecurityConfig struct {
 	BcryptCost     int
 	MaxRequestSize string
 	EnableHTTPS    bool
+
+	// Unverified users can still sign in but cannot create links
+	RequireEmailVerification bool
+
+	// Users without two-factor authentication can only manage their account
+	RequireTwoFactor bool
 }
 
 type LoggingConfig struct {
@@ -95,6 +126,53 @@ type CacheConfig struct {
 	URLTTL time.Duration
 }
 
+type ClickIngestionConfig struct {
+	Mode          string // sync, memory or redis
+	QueueSize     int
+	Workers       int
+	BatchSize     int
+	FlushInterval time.Duration
+	StreamName    string
+	ConsumerGroup string
+	ClaimIdle     time.Duration // how long a stream entry stays unacknowledged before another worker takes it over
+	MaxDeliveries int           // deliveries after which a stream entry that still fails is dead-lettered
+}
+
+type RollupConfig struct {
+	Enabled        bool
+	Interval       time.Duration // how often the aggregator looks for closed hours
+	Lag            time.Duration // how long after an hour ends before it is rolled up
+	MaxHoursPerRun int
+}
+
+type QRBatchConfig struct {
+	WorkerEnabled bool
+	PollInterval  time.Duration // how often the worker looks for pending batches
+	ResultTTL     time.Duration // how long finished archives can be downloaded
+}
+
+type MailConfig struct {
+	SMTPHost string // emails are only logged when empty
+	SMTPPort int
+	Username string
+	Password string
+	From     string
+}
+
+// OIDCConfig describes a generic OpenID Connect provider for single sign-on.
+// Sign-in through it is off unless both the issuer and client ID are set.
+type OIDCConfig struct {
+	IssuerURL    string // endpoints are discovered from {issuer}/.well-known/openid-configuration
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // the frontend page that receives the code and state
+	Scopes       []string
+}
+
+func (c OIDCConfig) Enabled() bool {
+	return c.IssuerURL != "" && c.ClientID != ""
+}
+
 func Load() (*Config, error) {
 	if err := godotenv.Load(); err != nil {
 		// It's okay if .env file doesn't exist in production
@@ -115,6 +193,7 @@ func Load() (*Config, error) {
 			Password:       getEnv("DATABASE_PASSWORD", "password"),
 			MaxConnections: getEnvInt("DATABASE_MAX_CONNECTIONS", 25),
 			MaxIdle:        getEnvInt("DATABASE_MAX_IDLE_CONNECTIONS", 5),
+			AutoMigrate:    getEnvBool("DATABASE_AUTO_MIGRATE", false),
 		},
 		Redis: RedisConfig{
 			URL:      getEnv("REDIS_URL", "redis://localhost:6379"),
@@ -127,6 +206,14 @@ func Load() (*Config, error) {
 			Secret:        getEnv("JWT_SECRET", "your-jwt-secret-key-here-change-in-production"),
 			Expiry:        getEnvDuration("JWT_EXPIRY", "24h"),
 			RefreshExpiry: getEnvDuration("JWT_REFRESH_EXPIRY", "7d"),
+
+			Algorithm:       getEnv("JWT_ALGORITHM", "HS256"),
+			KeysDir:         getEnv("JWT_KEYS_DIR", ""),
+			SigningKeyID:    getEnv("JWT_SIGNING_KEY_ID", ""),
+			PreviousSecrets: getEnvStringSlice("JWT_PREVIOUS_SECRETS", nil),
+			Issuer:          getEnv("JWT_ISSUER", "url-shortener"),
+			AccessAudience:  getEnv("JWT_ACCESS_AUDIENCE", "url-shortener:access"),
+			RefreshAudience: getEnv("JWT_REFRESH_AUDIENCE", "url-shortener:refresh"),
 		},
 		CORS: CORSConfig{
 			AllowedOrigins: getEnvStringSlice("CORS_ALLOWED_ORIGINS", []string{"http://localhost:3000", "http://localhost:5173"}),
@@ -139,8 +226,10 @@ func Load() (*Config, error) {
 			Enabled:  getEnvBool("RATE_LIMIT_ENABLED", true),
 		},
 		External: ExternalConfig{
-			GeolocationAPIKey: getEnv("GEOLOCATION_API_KEY", ""),
-			GeolocationAPIURL: getEnv("GEOLOCATION_API_URL", "https://api.ipgeolocation.io/ipgeo"),
+			GeolocationAPIKey:    getEnv("GEOLOCATION_API_KEY", ""),
+			GeolocationAPIURL:    getEnv("GEOLOCATION_API_URL", "https://api.ipgeolocation.io/ipgeo"),
+			GeolocationDBPath:    getEnv("GEOLOCATION_DB_PATH", ""),
+			GeolocationCacheSize: getEnvInt("GEOLOCATION_CACHE_SIZE", 10000),
 		},
 		App: AppConfig{
 			BaseURL:              getEnv("BASE_URL", "http://localhost:8080"),
@@ -153,6 +242,9 @@ func Load() (*Config, error) {
 			BcryptCost:     getEnvInt("BCRYPT_COST", 12),
 			MaxRequestSize: getEnv("MAX_REQUEST_SIZE", "10MB"),
 			EnableHTTPS:    getEnvBool("ENABLE_HTTPS", false),
+
+			RequireEmailVerification: getEnvBool("REQUIRE_EMAIL_VERIFICATION", true),
+			RequireTwoFactor:         getEnvBool("REQUIRE_TWO_FACTOR", false),
 		},
 		Logging: LoggingConfig{
 			Level:  getEnv("LOG_LEVEL", "info"),
@@ -162,6 +254,42 @@ func Load() (*Config, error) {
 			TTL:    getEnvDuration("CACHE_TTL", "1h"),
 			URLTTL: getEnvDuration("URL_CACHE_TTL", "24h"),
 		},
+		Clicks: ClickIngestionConfig{
+			Mode:          getEnv("CLICK_INGESTION_MODE", "memory"),
+			QueueSize:     getEnvInt("CLICK_QUEUE_SIZE", 10000),
+			Workers:       getEnvInt("CLICK_WRITER_WORKERS", 2),
+			BatchSize:     getEnvInt("CLICK_BATCH_SIZE", 500),
+			FlushInterval: getEnvDuration("CLICK_FLUSH_INTERVAL", "1s"),
+			StreamName:    getEnv("CLICK_STREAM_NAME", "clicks"),
+			ConsumerGroup: getEnv("CLICK_CONSUMER_GROUP", "click-writers"),
+			ClaimIdle:     getEnvDuration("CLICK_STREAM_CLAIM_IDLE", "1m"),
+			MaxDeliveries: getEnvInt("CLICK_STREAM_MAX_DELIVERIES", 5),
+		},
+		Rollups: RollupConfig{
+			Enabled:        getEnvBool("ROLLUP_ENABLED", false),
+			Interval:       getEnvDuration("ROLLUP_INTERVAL", "1m"),
+			Lag:            getEnvDuration("ROLLUP_LAG", "5m"),
+			MaxHoursPerRun: getEnvInt("ROLLUP_MAX_HOURS_PER_RUN", 168),
+		},
+		QRBatch: QRBatchConfig{
+			WorkerEnabled: getEnvBool("QR_BATCH_WORKER_ENABLED", true),
+			PollInterval:  getEnvDuration("QR_BATCH_POLL_INTERVAL", "2s"),
+			ResultTTL:     getEnvDuration("QR_BATCH_RESULT_TTL", "24h"),
+		},
+		Mail: MailConfig{
+			SMTPHost: getEnv("SMTP_HOST", ""),
+			SMTPPort: getEnvInt("SMTP_PORT", 587),
+			Username: getEnv("SMTP_USERNAME", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+			From:     getEnv("MAIL_FROM", "URL Shortener <no-reply@localhost>"),
+		},
+		OIDC: OIDCConfig{
+			IssuerURL:    getEnv("OIDC_ISSUER_URL", ""),
+			ClientID:     getEnv("OIDC_CLIENT_ID", ""),
+			ClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+			RedirectURL:  getEnv("OIDC_REDIRECT_URL", "http://localhost:3000/auth/oidc/callback"),
+			Scopes:       getEnvStringSlice("OIDC_SCOPES", []string{"openid", "email", "profile"}),
+		},
 	}
 
 	return config, nil
@@ -194,16 +322,28 @@ func getEnvBool(key string, defaultValue bool) bool {
 
 func getEnvDuration(key string, defaultValue string) time.Duration {
 	if value := os.Getenv(key); value != "" {
-		if duration, err := time.ParseDuration(value); err == nil {
+		if duration, err := parseDuration(value); err == nil {
 			return duration
 		}
 	}
-	if duration, err := time.ParseDuration(defaultValue); err == nil {
+	if duration, err := parseDuration(defaultValue); err == nil {
 		return duration
 	}
 	return time.Hour
 }
 
+// parseDuration accepts whole days ("7d") as well as anything time.ParseDuration does
+func parseDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
 func getEnvStringSlice(key string, defaultValue []string) []string {
 	if value := os.Getenv(key); value != "" {
 		return strings.Split(value, ",")
@@ -221,4 +361,33 @@ func (c *Config) IsProduction() bool {
 
 func (c *Config) GetServerAddress() string {
 	return c.Server.Host + ":" + c.Server.Port
-}
\ No newline at end of file
+}
+// The accessors below let Config satisfy ports.ConfigService.
+
+func (c *Config) GetBaseURL() string {
+	return c.App.BaseURL
+}
+
+func (c *Config) GetJWTSecret() string {
+	return c.JWT.Secret
+}
+
+func (c *Config) GetDatabaseURL() string {
+	return c.Database.URL
+}
+
+func (c *Config) GetRedisURL() string {
+	return c.Redis.URL
+}
+
+func (c *Config) IsTwoFactorRequired() bool {
+	return c.Security.RequireTwoFactor
+}
+
+func (c *Config) GetQRBatchResultTTL() time.Duration {
+	return c.QRBatch.ResultTTL
+}
+
+func (c *Config) GetSessionTTL() time.Duration {
+	return c.JWT.RefreshExpiry
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UserFilter narrows the admin user list. Empty fields match every user.
+type UserFilter struct {
+	// Query matches part of the email address or name, ignoring case
+	Query  string
+	Role   string
+	Active *bool
+}
+
+// AdminUpdateUserRequest changes what an account may do. Omitted fields are left as they are.
+type AdminUpdateUserRequest struct {
+	Role              *string `json:"role,omitempty"`
+	TwoFactorRequired *bool   `json:"two_factor_required,omitempty"`
+}
+
+// AdminUserResponse is the staff view of an account
+type AdminUserResponse struct {
+	*UserResponse
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (f *UserFilter) Validate() error {
+	f.Query = strings.TrimSpace(f.Query)
+	if len(f.Query) > 100 {
+		return fmt.Errorf("%w: search query must be at most 100 characters", ErrInvalidInput)
+	}
+	if f.Role != "" && !IsValidRole(f.Role) {
+		return ErrInvalidRole
+	}
+	return nil
+}
+
+func (r *AdminUpdateUserRequest) Validate() error {
+	if r.Role == nil && r.TwoFactorRequired == nil {
+		return fmt.Errorf("%w: nothing to update", ErrInvalidInput)
+	}
+	if r.Role != nil && !IsValidRole(*r.Role) {
+		return ErrInvalidRole
+	}
+	return nil
+}
+
+func (u *User) ToAdminResponse() *AdminUserResponse {
+	return &AdminUserResponse{
+		UserResponse: u.ToResponse(),
+		LastLoginAt:  u.LastLoginAt,
+		UpdatedAt:    u.UpdatedAt,
+	}
+}
@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Scopes an API key can be granted. Signed-in sessions implicitly hold all of them.
+const (
+	ScopeURLsRead      = "urls:read"
+	ScopeURLsWrite     = "urls:write"
+	ScopeAnalyticsRead = "analytics:read"
+)
+
+// Limits on personal API keys
+const (
+	MaxAPIKeysPerUser   = 25
+	MaxAPIKeyNameLength = 100
+)
+
+// APIKeyPrefix starts every key so leaked keys are easy to spot in code and logs
+const APIKeyPrefix = "usk_"
+
+var validAPIKeyScopes = map[string]bool{
+	ScopeURLsRead:      true,
+	ScopeURLsWrite:     true,
+	ScopeAnalyticsRead: true,
+}
+
+// APIKey is a long-lived credential for scripts and CI. Only a SHA-256 hash of the
+// key is stored; Prefix holds its first characters so users can tell keys apart.
+type APIKey struct {
+	ID         uint       `json:"id" gorm:"primarykey"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	Name       string     `json:"name" gorm:"size:100;not null"`
+	Prefix     string     `json:"prefix" gorm:"size:20;not null"`
+	KeyHash    string     `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	Scopes     APIScopes  `json:"scopes" gorm:"type:text;not null"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty" gorm:"size:45"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" gorm:"index"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// APIScopes is stored as a comma-separated list
+type APIScopes []string
+
+func (s APIScopes) Value() (driver.Value, error) {
+	return strings.Join(s, ","), nil
+}
+
+func (s *APIScopes) Scan(value interface{}) error {
+	var raw string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into API key scopes", value)
+	}
+
+	*s = nil
+	for _, scope := range strings.Split(raw, ",") {
+		if scope != "" {
+			*s = append(*s, scope)
+		}
+	}
+	return nil
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required"`
+}
+
+type UpdateAPIKeyRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// CreatedAPIKey is returned once, when the key is created; the key cannot be
+// retrieved again afterwards.
+type CreatedAPIKey struct {
+	*APIKey
+	Key string `json:"key"`
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *APIKey) IsRevoked() bool {
+	return k.RevokedAt != nil
+}
+
+// Validate trims the name and puts the scopes in canonical order without duplicates
+func (r *CreateAPIKeyRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if err := validateAPIKeyName(r.Name); err != nil {
+		return err
+	}
+
+	scopes, err := normalizeAPIKeyScopes(r.Scopes)
+	if err != nil {
+		return err
+	}
+	r.Scopes = scopes
+	return nil
+}
+
+func (r *UpdateAPIKeyRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	return validateAPIKeyName(r.Name)
+}
+
+func validateAPIKeyName(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+	if len(name) > MaxAPIKeyNameLength {
+		return fmt.Errorf("%w: name must be at most %d characters", ErrInvalidInput, MaxAPIKeyNameLength)
+	}
+	return nil
+}
+
+func normalizeAPIKeyScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+
+	seen := make(map[string]bool, len(scopes))
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !validAPIKeyScopes[scope] {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidScope, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
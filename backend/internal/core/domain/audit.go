@@ -0,0 +1,196 @@
+package domain
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Audited actions, named <target>.<what happened>
+const (
+	AuditURLCreated = "url.created"
+	AuditURLUpdated = "url.updated"
+	AuditURLDeleted = "url.deleted"
+	// AuditURLRolledBack is a link whose destination was restored from its history
+	AuditURLRolledBack = "url.rolled_back"
+
+	AuditUserRegistered        = "user.registered"
+	AuditUserLoggedIn          = "user.logged_in"
+	AuditUserProfileUpdated    = "user.profile_updated"
+	AuditUserPasswordChanged   = "user.password_changed"
+	AuditUserPasswordReset     = "user.password_reset"
+	AuditUserTwoFactorEnabled  = "user.two_factor_enabled"
+	AuditUserTwoFactorDisabled = "user.two_factor_disabled"
+	AuditUserSessionsRevoked   = "user.sessions_revoked"
+
+	AuditAdminUserUpdated     = "admin.user_updated"
+	AuditAdminUserDeactivated = "admin.user_deactivated"
+	AuditAdminUserReactivated = "admin.user_reactivated"
+)
+
+// Kinds of record an audit event can be about
+const (
+	AuditTargetURL  = "url"
+	AuditTargetUser = "user"
+)
+
+// AuditEvent records who did what to which record. Events are only ever added.
+type AuditEvent struct {
+	ID         uint         `json:"id" gorm:"primarykey"`
+	ActorID    *uint        `json:"actor_id,omitempty" gorm:"index"` // nil for actions nobody signed in made
+	Action     string       `json:"action" gorm:"size:64;not null;index"`
+	TargetType string       `json:"target_type" gorm:"size:32;not null;index:idx_audit_events_target"`
+	TargetID   uint         `json:"target_id" gorm:"not null;index:idx_audit_events_target"`
+	Changes    AuditChanges `json:"changes,omitempty" gorm:"type:text"`
+	IPAddress  string       `json:"ip_address,omitempty" gorm:"size:45"`
+	RequestID  string       `json:"request_id,omitempty" gorm:"size:64;index"`
+	CreatedAt  time.Time    `json:"created_at" gorm:"index"`
+}
+
+// AuditChange is a field's value before and after an action. From is nil for
+// created records and To is nil for deleted ones.
+type AuditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// AuditChanges maps field names to how they changed
+type AuditChanges map[string]AuditChange
+
+// AuditFilter narrows the audit log. Empty fields match every event.
+type AuditFilter struct {
+	ActorID    *uint
+	Action     string
+	TargetType string
+	TargetID   *uint
+	From       *time.Time
+	To         *time.Time
+}
+
+// RequestInfo describes the HTTP request an action came from
+type RequestInfo struct {
+	RequestID string
+	IPAddress string
+}
+
+type requestInfoKey struct{}
+
+// WithRequestInfo attaches info to ctx so services can record where an action came from
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFromContext returns the info attached by WithRequestInfo, if any
+func RequestInfoFromContext(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info
+}
+
+// DiffAudit compares two snapshots and returns the fields whose values differ.
+// Either snapshot may be nil, for records that were created or deleted.
+func DiffAudit(before, after map[string]interface{}) AuditChanges {
+	changes := AuditChanges{}
+	for field, from := range before {
+		to, ok := after[field]
+		if !ok || !reflect.DeepEqual(from, to) {
+			changes[field] = AuditChange{From: from, To: to}
+		}
+	}
+	for field, to := range after {
+		if _, ok := before[field]; !ok {
+			changes[field] = AuditChange{To: to}
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+// AuditSnapshot is the part of a link recorded in the audit log. The link
+// password is never included, only whether there is one.
+func (s *ShortURL) AuditSnapshot() map[string]interface{} {
+	return map[string]interface{}{
+		"original_url":       s.OriginalURL,
+		"short_code":         s.ShortCode,
+		"title":              s.Title,
+		"description":        s.Description,
+		"is_active":          s.IsActive,
+		"expires_at":         auditTime(s.ExpiresAt),
+		"password_protected": s.Password != nil && *s.Password != "",
+		"workspace_id":       auditID(s.WorkspaceID),
+		"variants":           len(s.Variants),
+		"redirect_rules":     len(s.RedirectRules),
+	}
+}
+
+// AuditSnapshot is the part of an account recorded in the audit log
+func (u *User) AuditSnapshot() map[string]interface{} {
+	return map[string]interface{}{
+		"email":               u.Email,
+		"first_name":          u.FirstName,
+		"last_name":           u.LastName,
+		"role":                u.RoleOrDefault(),
+		"is_active":           u.IsActive,
+		"two_factor_required": u.TwoFactorRequired,
+	}
+}
+
+// Snapshots hold plain values so they compare the same before and after a round trip through JSON
+func auditTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func auditID(id *uint) interface{} {
+	if id == nil {
+		return nil
+	}
+	return *id
+}
+
+func (f *AuditFilter) Validate() error {
+	f.Action = strings.TrimSpace(f.Action)
+	f.TargetType = strings.TrimSpace(f.TargetType)
+	if len(f.Action) > 64 {
+		return fmt.Errorf("%w: action must be at most 64 characters", ErrInvalidInput)
+	}
+	if f.TargetType != "" && f.TargetType != AuditTargetURL && f.TargetType != AuditTargetUser {
+		return fmt.Errorf("%w: unknown target type", ErrInvalidInput)
+	}
+	if f.From != nil && f.To != nil && f.To.Before(*f.From) {
+		return fmt.Errorf("%w: from must be before to", ErrInvalidInput)
+	}
+	return nil
+}
+
+func (c AuditChanges) Value() (driver.Value, error) {
+	if len(c) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (c *AuditChanges) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	case []byte:
+		return json.Unmarshal(v, c)
+	default:
+		return fmt.Errorf("cannot scan %T into audit changes", value)
+	}
+}
@@ -7,26 +7,52 @@ import (
 )
 
 type Click struct {
-	ID          uint           `json:"id" gorm:"primarykey"`
-	ShortURLID  uint           `json:"short_url_id" gorm:"not null;index"`
-	IPAddress   string         `json:"ip_address" gorm:"type:inet"`
-	UserAgent   string         `json:"user_agent" gorm:"type:text"`
-	Referer     string         `json:"referer" gorm:"type:text"`
-	Country     string         `json:"country" gorm:"size:2"`
-	Region      string         `json:"region" gorm:"size:100"`
-	City        string         `json:"city" gorm:"size:100"`
-	Device      string         `json:"device" gorm:"size:50"`
-	Browser     string         `json:"browser" gorm:"size:50"`
-	OS          string         `json:"os" gorm:"size:50"`
-	ClickedAt   time.Time      `json:"clicked_at" gorm:"index"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+	ID             uint           `json:"id" gorm:"primarykey"`
+	ShortURLID     uint           `json:"short_url_id" gorm:"not null;index"`
+	IPAddress      string         `json:"ip_address" gorm:"type:inet"`
+	UserAgent      string         `json:"user_agent" gorm:"type:text"`
+	Referer        string         `json:"referer" gorm:"type:text"`
+	Country        string         `json:"country" gorm:"size:2"`
+	Region         string         `json:"region" gorm:"size:100"`
+	City           string         `json:"city" gorm:"size:100"`
+	Device         string         `json:"device" gorm:"size:50"`
+	Browser        string         `json:"browser" gorm:"size:50"`
+	BrowserVersion string         `json:"browser_version" gorm:"size:50"`
+	OS             string         `json:"os" gorm:"size:50"`
+	OSVersion      string         `json:"os_version" gorm:"size:50"`
+	VariantID      *uint          `json:"variant_id,omitempty" gorm:"index"`
+	ClickedAt      time.Time      `json:"clicked_at" gorm:"index"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relationships
 	ShortURL ShortURL `json:"short_url,omitempty" gorm:"foreignKey:ShortURLID"`
 }
 
+// Device types reported by the user agent parser
+const (
+	DeviceTypeMobile  = "mobile"
+	DeviceTypeTablet  = "tablet"
+	DeviceTypeDesktop = "desktop"
+	DeviceTypeBot     = "bot"
+	DeviceTypeTV      = "tv"
+	DeviceTypeUnknown = "unknown"
+)
+
+// UserAgentInfo is the result of parsing a User-Agent header
+type UserAgentInfo struct {
+	DeviceType     string `json:"device_type"`
+	Browser        string `json:"browser"`
+	BrowserVersion string `json:"browser_version"`
+	OS             string `json:"os"`
+	OSVersion      string `json:"os_version"`
+}
+
+func (u *UserAgentInfo) IsBot() bool {
+	return u.DeviceType == DeviceTypeBot
+}
+
 type ClickStats struct {
 	TotalClicks  int64                    `json:"total_clicks"`
 	UniqueClicks int64                    `json:"unique_clicks"`
@@ -171,4 +197,84 @@ type RealTimeUpdate struct {
 	Type      string    `json:"type"` // click, view, etc.
 	Data      interface{} `json:"data"`
 	Timestamp time.Time `json:"timestamp"`
-}
\ No newline at end of file
+}
+
+// ClickQueueStats reports the state of the asynchronous click ingestion queue
+type ClickQueueStats struct {
+	Backend  string `json:"backend"`
+	Capacity int64  `json:"capacity"`
+	Depth    int64  `json:"depth"`
+	Enqueued int64  `json:"enqueued"`
+	Dropped  int64  `json:"dropped"`
+	Written  int64  `json:"written"`
+	Failed   int64  `json:"failed"`
+	Batches  int64  `json:"batches"`
+}
+
+// Export formats accepted by AnalyticsService.ExportAnalytics
+const (
+	ExportFormatCSV       = "csv"
+	ExportFormatJSON      = "json"  // a single JSON array
+	ExportFormatJSONLines = "jsonl" // one JSON object per line
+)
+
+// ExportRequest selects the clicks to export. An empty ShortURLIDs exports every
+// URL the user owns.
+type ExportRequest struct {
+	Format      string    `json:"format"`
+	DateRange   DateRange `json:"date_range"`
+	ShortURLIDs []uint    `json:"short_url_ids,omitempty"`
+}
+
+// ExportColumns is the CSV header and the field order of ClickExportRow
+var ExportColumns = []string{
+	"short_code", "original_url", "title", "clicked_at", "ip_address",
+	"country", "region", "city", "device", "browser", "browser_version",
+	"os", "os_version", "referer",
+}
+
+// ClickExportRow is one exported click. Its JSON field order matches ExportColumns.
+type ClickExportRow struct {
+	ShortCode      string `json:"short_code"`
+	OriginalURL    string `json:"original_url"`
+	Title          string `json:"title"`
+	ClickedAt      string `json:"clicked_at"`
+	IPAddress      string `json:"ip_address"`
+	Country        string `json:"country"`
+	Region         string `json:"region"`
+	City           string `json:"city"`
+	Device         string `json:"device"`
+	Browser        string `json:"browser"`
+	BrowserVersion string `json:"browser_version"`
+	OS             string `json:"os"`
+	OSVersion      string `json:"os_version"`
+	Referer        string `json:"referer"`
+}
+
+func NewClickExportRow(url *ShortURL, click *Click) *ClickExportRow {
+	return &ClickExportRow{
+		ShortCode:      url.ShortCode,
+		OriginalURL:    url.OriginalURL,
+		Title:          url.Title,
+		ClickedAt:      click.ClickedAt.UTC().Format(time.RFC3339),
+		IPAddress:      click.IPAddress,
+		Country:        click.Country,
+		Region:         click.Region,
+		City:           click.City,
+		Device:         click.Device,
+		Browser:        click.Browser,
+		BrowserVersion: click.BrowserVersion,
+		OS:             click.OS,
+		OSVersion:      click.OSVersion,
+		Referer:        click.Referer,
+	}
+}
+
+// Values returns the row in ExportColumns order
+func (r *ClickExportRow) Values() []string {
+	return []string{
+		r.ShortCode, r.OriginalURL, r.Title, r.ClickedAt, r.IPAddress,
+		r.Country, r.Region, r.City, r.Device, r.Browser, r.BrowserVersion,
+		r.OS, r.OSVersion, r.Referer,
+	}
+}
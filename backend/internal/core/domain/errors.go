@@ -12,6 +12,8 @@ var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrInvalidEmail      = errors.New("invalid email format")
 	ErrWeakPassword      = errors.New("password does not meet requirements")
+	ErrEmailNotVerified     = errors.New("email address is not verified")
+	ErrEmailAlreadyVerified = errors.New("email address is already verified")
 
 	// URL errors
 	ErrShortURLNotFound    = errors.New("short URL not found")
@@ -22,6 +24,9 @@ var (
 	ErrURLInactive         = errors.New("URL is inactive")
 	ErrCustomAliasInvalid  = errors.New("custom alias is invalid")
 	ErrCustomAliasTooLong  = errors.New("custom alias is too long")
+	ErrInvalidVariants     = errors.New("invalid link variants")
+	ErrInvalidRedirectRule = errors.New("invalid redirect rule")
+	ErrRevisionNotFound    = errors.New("link revision not found")
 
 	// Authentication errors
 	ErrInvalidToken        = errors.New("invalid token")
@@ -30,6 +35,42 @@ var (
 	ErrForbidden          = errors.New("forbidden")
 	ErrUserInactive       = errors.New("user account is inactive")
 	ErrInvalidPassword    = errors.New("invalid password")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrRefreshTokenReused = errors.New("refresh token has already been used")
+	ErrInvalidRole        = errors.New("invalid role")
+	ErrCannotModifySelf   = errors.New("administrators cannot change their own role or status")
+
+	// Two-factor authentication errors
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorSetupRequired  = errors.New("two-factor setup has not been started")
+	ErrTwoFactorRequired       = errors.New("two-factor authentication is required for this account")
+
+	// Single sign-on errors
+	ErrOIDCNotConfigured     = errors.New("single sign-on is not configured")
+	ErrOIDCStateInvalid      = errors.New("single sign-on state is invalid or expired")
+	ErrOIDCLoginFailed       = errors.New("single sign-on failed")
+	ErrOIDCEmailNotVerified  = errors.New("identity provider has not verified the email address")
+	ErrOIDCAccountUnverified = errors.New("an account with an unverified email address already uses this address")
+	ErrIdentityNotFound      = errors.New("linked identity not found")
+
+	// API key errors
+	ErrAPIKeyNotFound      = errors.New("API key not found")
+	ErrInvalidAPIKey       = errors.New("invalid API key")
+	ErrInvalidScope        = errors.New("invalid API key scope")
+	ErrAPIKeyLimitReached  = errors.New("API key limit reached")
+	ErrInsufficientScope   = errors.New("API key lacks the required scope")
+
+	// Workspace errors
+	ErrWorkspaceNotFound       = errors.New("workspace not found")
+	ErrNotWorkspaceMember      = errors.New("user is not a member of the workspace")
+	ErrAlreadyWorkspaceMember  = errors.New("user is already a member of the workspace")
+	ErrLastWorkspaceOwner      = errors.New("a workspace must keep at least one owner")
+	ErrInvalidWorkspaceRole    = errors.New("invalid workspace role")
+	ErrInvitationNotFound      = errors.New("invitation not found")
+	ErrInvitationExpired       = errors.New("invitation has expired")
+	ErrInvitationEmailMismatch = errors.New("invitation was sent to a different email address")
 
 	// Validation errors
 	ErrInvalidInput        = errors.New("invalid input")
@@ -50,6 +91,22 @@ var (
 	// External service errors
 	ErrExternalService     = errors.New("external service error")
 	ErrGeolocationService  = errors.New("geolocation service error")
+	ErrLocationNotFound    = errors.New("location not found")
+
+	// Click ingestion errors
+	ErrClickQueueFull   = errors.New("click queue is full")
+	ErrClickQueueClosed = errors.New("click queue is closed")
+
+	// Analytics errors
+	ErrInvalidExportFormat = errors.New("invalid export format")
+	ErrInvalidDateRange    = errors.New("invalid date range")
+
+	// QR code errors
+	ErrInvalidLogo     = errors.New("logo must be an uploaded PNG or JPEG image")
+	ErrLogoTooLarge    = errors.New("logo is too large for the QR code to stay readable")
+	ErrQRBatchNotFound = errors.New("QR batch not found")
+	ErrQRBatchNotReady = errors.New("QR batch has not finished yet")
+	ErrQRBatchExpired  = errors.New("QR batch archive has expired")
 )
 
 type DomainError struct {
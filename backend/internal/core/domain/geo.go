@@ -18,7 +18,7 @@ type GeoLocation struct {
 	Organization  string  `json:"organization"`
 	ASN           string  `json:"asn"`
 	Accuracy      string  `json:"accuracy"` // city, region, country
-	Source        string  `json:"source"`   // ipapi, maxmind, ipgeolocation
+	Source        string  `json:"source"`   // ipapi, maxmind, ipgeolocation, csv
 	CachedAt      time.Time `json:"cached_at"`
 }
 
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OIDCLoginTTL is how long a single sign-on attempt may take at the identity provider
+const OIDCLoginTTL = 10 * time.Minute
+
+// UserIdentity links a user to their account at an OpenID Connect provider. The
+// provider's subject is stable; the email it reports may change over time.
+type UserIdentity struct {
+	ID          uint       `json:"-" gorm:"primarykey"`
+	UserID      uint       `json:"-" gorm:"not null;index"`
+	Issuer      string     `json:"issuer" gorm:"size:255;not null;uniqueIndex:idx_user_identities_issuer_subject"`
+	Subject     string     `json:"-" gorm:"size:255;not null;uniqueIndex:idx_user_identities_issuer_subject"`
+	Email       string     `json:"email" gorm:"size:255"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// OIDCIdentity holds the claims of a verified ID token
+type OIDCIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+// OIDCLoginResponse starts a single sign-on. State is not sent in the body; it
+// goes to the browser in a cookie and must match the state on the callback.
+type OIDCLoginResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"-"`
+	ExpiresIn        int    `json:"expires_in"`
+}
+
+// OIDCCallbackRequest carries the parameters the provider appended to the redirect URL
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" validate:"required"`
+	State string `json:"state" validate:"required"`
+}
+
+func (r *OIDCCallbackRequest) Validate() error {
+	r.Code = strings.TrimSpace(r.Code)
+	r.State = strings.TrimSpace(r.State)
+	if r.Code == "" || r.State == "" {
+		return fmt.Errorf("%w: code and state are required", ErrInvalidInput)
+	}
+	return nil
+}
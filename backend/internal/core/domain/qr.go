@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"fmt"
 	"image/color"
 	"time"
 )
@@ -12,8 +13,8 @@ type QROptions struct {
 	BorderSize  int    `json:"border_size" validate:"min=0,max=10"`     // Border size in modules
 	Foreground  string `json:"foreground,omitempty"`                    // Foreground color (hex)
 	Background  string `json:"background,omitempty"`                    // Background color (hex)
-	Logo        string `json:"logo,omitempty"`                          // Logo URL for branded QR codes
-	LogoSize    int    `json:"logo_size" validate:"min=10,max=30"`      // Logo size as percentage
+	Logo        string `json:"logo,omitempty"`                          // Base64 PNG or JPEG, optionally as a data URL; never fetched from a remote URL
+	LogoSize    int    `json:"logo_size" validate:"min=10,max=30"`      // Logo width as a percentage of the symbol
 }
 
 type CustomQRRequest struct {
@@ -22,11 +23,76 @@ type CustomQRRequest struct {
 	Customization *QRCustomization `json:"customization,omitempty"`
 }
 
+// QRCustomization styles a QR code. Style shapes both the data modules and the
+// finder eyes; DataPattern and EyeStyle override it for one of them.
 type QRCustomization struct {
 	Style       string `json:"style" validate:"oneof=square circle rounded"`
 	Pattern     string `json:"pattern" validate:"oneof=solid gradient"`
 	EyeStyle    string `json:"eye_style" validate:"oneof=square circle rounded"`
-	DataPattern string `json:"data_pattern" validate:"oneof=square circle diamond"`
+	DataPattern string `json:"data_pattern" validate:"oneof=square circle rounded diamond"`
+
+	// A gradient fades the foreground colour into GradientColor
+	GradientColor string `json:"gradient_color,omitempty"`
+	GradientAngle int    `json:"gradient_angle,omitempty"` // degrees clockwise from left to right
+}
+
+const (
+	QRShapeSquare  = "square"
+	QRShapeRounded = "rounded"
+	QRShapeCircle  = "circle"
+	QRShapeDiamond = "diamond"
+
+	QRPatternSolid    = "solid"
+	QRPatternGradient = "gradient"
+)
+
+// Logos may cover at most this share of the symbol's width
+const (
+	DefaultQRLogoSize = 20
+	MinQRLogoSize     = 10
+	MaxQRLogoSize     = 30
+)
+
+// ModuleShape is the shape of the data modules
+func (c *QRCustomization) ModuleShape() string {
+	return firstNonEmpty(c.DataPattern, c.Style, QRShapeSquare)
+}
+
+// EyeShape is the shape of the three finder eyes
+func (c *QRCustomization) EyeShape() string {
+	return firstNonEmpty(c.EyeStyle, c.Style, QRShapeSquare)
+}
+
+func (c *QRCustomization) Validate() error {
+	switch c.ModuleShape() {
+	case QRShapeSquare, QRShapeRounded, QRShapeCircle, QRShapeDiamond:
+	default:
+		return fmt.Errorf("%w: unknown module shape %q", ErrInvalidInput, c.ModuleShape())
+	}
+	switch c.EyeShape() {
+	case QRShapeSquare, QRShapeRounded, QRShapeCircle:
+	default:
+		return fmt.Errorf("%w: unknown eye shape %q", ErrInvalidInput, c.EyeShape())
+	}
+	switch c.Pattern {
+	case "", QRPatternSolid:
+	case QRPatternGradient:
+		if c.GradientColor == "" {
+			return fmt.Errorf("%w: a gradient needs a gradient color", ErrInvalidInput)
+		}
+	default:
+		return fmt.Errorf("%w: unknown pattern %q", ErrInvalidInput, c.Pattern)
+	}
+	return nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
 }
 
 type QRCodeResponse struct {
@@ -56,19 +122,6 @@ type QRCodeHistory struct {
 	LastDownloaded *time.Time `json:"last_downloaded"`
 }
 
-type QRBatch struct {
-	ID          uint      `json:"id"`
-	UserID      uint      `json:"user_id"`
-	URLs        []string  `json:"urls"`
-	Options     *QROptions `json:"options"`
-	Status      string    `json:"status"` // pending, processing, completed, failed
-	Progress    int       `json:"progress"` // 0-100
-	ResultURL   string    `json:"result_url,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
-	CompletedAt *time.Time `json:"completed_at"`
-	Error       string    `json:"error,omitempty"`
-}
-
 // Additional models for QR service
 type QRCodeRequest struct {
 	URL              string `json:"url" validate:"required"`
@@ -80,6 +133,10 @@ type QRCodeRequest struct {
 	ErrorCorrection  string `json:"error_correction,omitempty"`
 	Border           int    `json:"border,omitempty"`
 	UserID           uint   `json:"user_id,omitempty"`
+
+	Customization *QRCustomization `json:"customization,omitempty"`
+	Logo          string           `json:"logo,omitempty"`      // base64 PNG or JPEG, as in QROptions
+	LogoSize      int              `json:"logo_size,omitempty"` // percent of the symbol's width, 20 by default
 }
 
 type QRCodeOptions struct {
@@ -98,6 +155,14 @@ type QRGenerationOptions struct {
 	BackgroundColor  color.RGBA `json:"background_color"`
 	ErrorCorrection  int        `json:"error_correction"`
 	Border           int        `json:"border"`
+
+	ModuleShape   string      `json:"module_shape,omitempty"`   // square when empty
+	EyeShape      string      `json:"eye_shape,omitempty"`      // square when empty
+	GradientColor *color.RGBA `json:"gradient_color,omitempty"` // solid foreground when nil
+	GradientAngle int         `json:"gradient_angle,omitempty"`
+	// Logo is drawn in the centre and always raises error correction to H
+	Logo     []byte `json:"-"`
+	LogoSize int    `json:"logo_size,omitempty"`
 }
 
 // Validation methods
@@ -0,0 +1,128 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Statuses a QR batch moves through. Completed batches become expired once their
+// archive is deleted.
+const (
+	QRBatchPending    = "pending"
+	QRBatchProcessing = "processing"
+	QRBatchCompleted  = "completed"
+	QRBatchFailed     = "failed"
+	QRBatchExpired    = "expired"
+)
+
+// Most links one batch may hold
+const MaxQRBatchItems = 1000
+
+// QRBatch renders QR codes for many links into one ZIP archive. Links are looked up
+// and access-checked when the batch is created; a background worker renders them
+// and the archive can be downloaded until ExpiresAt.
+type QRBatch struct {
+	ID            uint             `json:"id" gorm:"primarykey"`
+	UserID        uint             `json:"user_id" gorm:"not null;index"`
+	Items         QRBatchItems     `json:"items" gorm:"type:text;not null"`
+	Options       *QROptions       `json:"options" gorm:"type:text"`
+	Customization *QRCustomization `json:"customization,omitempty" gorm:"type:text"`
+	Status        string           `json:"status" gorm:"size:20;not null;index"`
+	Progress      int              `json:"progress"` // 0-100
+	Failures      int              `json:"failures"` // items that could not be rendered; listed in the manifest
+	Attempts      int              `json:"-"`        // times a worker has claimed the batch
+	// ResultURL is where the archive is downloaded, set once the batch has completed
+	ResultURL   string     `json:"result_url,omitempty" gorm:"-"`
+	Archive     []byte     `json:"-"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" gorm:"index"`
+	Error       string     `json:"error,omitempty" gorm:"type:text"`
+}
+
+// QRBatchItem is one link of a batch; its image is named after the short code
+type QRBatchItem struct {
+	ShortCode string `json:"short_code"`
+	URL       string `json:"url"`
+}
+
+// QRBatchItems is stored as JSON
+type QRBatchItems []QRBatchItem
+
+// CreateQRBatchRequest names links by short code, by ID or both; duplicates are
+// rendered once
+type CreateQRBatchRequest struct {
+	ShortCodes    []string         `json:"short_codes,omitempty"`
+	URLIDs        []uint           `json:"url_ids,omitempty"`
+	Options       *QROptions       `json:"options,omitempty"`
+	Customization *QRCustomization `json:"customization,omitempty"`
+}
+
+func (r *CreateQRBatchRequest) Validate() error {
+	for i, code := range r.ShortCodes {
+		r.ShortCodes[i] = strings.TrimSpace(code)
+		if r.ShortCodes[i] == "" {
+			return fmt.Errorf("%w: short codes must not be empty", ErrInvalidInput)
+		}
+	}
+	count := len(r.ShortCodes) + len(r.URLIDs)
+	if count == 0 {
+		return fmt.Errorf("%w: a batch needs at least one short code or URL ID", ErrInvalidInput)
+	}
+	if count > MaxQRBatchItems {
+		return fmt.Errorf("%w: a batch holds at most %d links", ErrInvalidInput, MaxQRBatchItems)
+	}
+	if r.Customization != nil {
+		return r.Customization.Validate()
+	}
+	return nil
+}
+
+func (i QRBatchItems) Value() (driver.Value, error) {
+	return jsonValue(i)
+}
+
+func (i *QRBatchItems) Scan(value interface{}) error {
+	return scanJSON(value, i, "QR batch items")
+}
+
+func (o QROptions) Value() (driver.Value, error) {
+	return jsonValue(o)
+}
+
+func (o *QROptions) Scan(value interface{}) error {
+	return scanJSON(value, o, "QR options")
+}
+
+func (c QRCustomization) Value() (driver.Value, error) {
+	return jsonValue(c)
+}
+
+func (c *QRCustomization) Scan(value interface{}) error {
+	return scanJSON(value, c, "QR customization")
+}
+
+func jsonValue(v interface{}) (driver.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func scanJSON(value interface{}, target interface{}, what string) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), target)
+	case []byte:
+		return json.Unmarshal(v, target)
+	default:
+		return fmt.Errorf("cannot scan %T into %s", value, what)
+	}
+}
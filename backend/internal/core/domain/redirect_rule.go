@@ -0,0 +1,339 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limits on the targeting rules of a link
+const (
+	MaxRedirectRules       = 20
+	MaxRuleConditionValues = 50
+)
+
+// RedirectRule sends visitors matching every one of its conditions to an alternate
+// destination. Rules are tried in Position order and the first match wins; visitors
+// matching none go to the link's default destination.
+type RedirectRule struct {
+	ID             uint           `json:"id" gorm:"primarykey"`
+	ShortURLID     uint           `json:"short_url_id" gorm:"not null;index"`
+	Position       int            `json:"position" gorm:"not null;default:0"`
+	Name           string         `json:"name" gorm:"size:100"`
+	Conditions     RuleConditions `json:"conditions" gorm:"type:text;not null"`
+	DestinationURL string         `json:"destination_url" gorm:"type:text;not null"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// RuleConditions are ANDed together; within a list any value may match. Countries
+// are ISO 3166 codes, devices are DeviceType values, languages are BCP 47 tags where
+// "en" also matches "en-GB", and referrer domains also match their subdomains.
+type RuleConditions struct {
+	Countries        []string    `json:"countries,omitempty"`
+	Devices          []string    `json:"devices,omitempty"`
+	OperatingSystems []string    `json:"operating_systems,omitempty"`
+	Languages        []string    `json:"languages,omitempty"`
+	ReferrerDomains  []string    `json:"referrer_domains,omitempty"`
+	TimeWindow       *TimeWindow `json:"time_window,omitempty"`
+}
+
+// TimeWindow is a daily window from Start up to End, both "HH:MM" in Timezone (UTC if
+// empty). A window whose end is before its start runs past midnight.
+type TimeWindow struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+type RedirectRuleRequest struct {
+	Name           string         `json:"name" validate:"max=100"`
+	Conditions     RuleConditions `json:"conditions"`
+	DestinationURL string         `json:"destination_url" validate:"required,url"`
+}
+
+// RedirectContext is what a redirect knows about the visitor when rules are evaluated
+type RedirectContext struct {
+	Country   string
+	Device    string
+	OS        string
+	Languages []string // from Accept-Language, most preferred first
+	Referrer  string   // the full Referer header
+	Time      time.Time
+}
+
+var (
+	countryCodePattern    = regexp.MustCompile(`^[A-Z]{2}$`)
+	languageTagPattern    = regexp.MustCompile(`^[a-z]{1,8}(-[a-z0-9]{1,8})*$`)
+	domainNamePattern     = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)*[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+	knownRuleDeviceTypes  = []string{DeviceTypeMobile, DeviceTypeTablet, DeviceTypeDesktop, DeviceTypeBot, DeviceTypeTV, DeviceTypeUnknown}
+	timeWindowClockLayout = "15:04"
+
+	// Rules are checked on every redirect, so zones are only loaded once
+	ruleLocations sync.Map
+)
+
+// Value stores the conditions as JSON
+func (c RuleConditions) Value() (driver.Value, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (c *RuleConditions) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*c = RuleConditions{}
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	case []byte:
+		return json.Unmarshal(v, c)
+	default:
+		return fmt.Errorf("cannot scan %T into rule conditions", value)
+	}
+}
+
+// ValidateRedirectRules checks a rule set and returns it with every condition in
+// canonical form. Destination URLs are checked by the URL service.
+func ValidateRedirectRules(reqs []RedirectRuleRequest) ([]RedirectRuleRequest, error) {
+	if len(reqs) > MaxRedirectRules {
+		return nil, fmt.Errorf("%w: at most %d rules are allowed", ErrInvalidRedirectRule, MaxRedirectRules)
+	}
+
+	normalized := make([]RedirectRuleRequest, 0, len(reqs))
+	for i, req := range reqs {
+		conditions, err := req.Conditions.Normalize()
+		if err != nil {
+			return nil, fmt.Errorf("%w: rule %d: %v", ErrInvalidRedirectRule, i+1, err)
+		}
+		if req.DestinationURL == "" {
+			return nil, fmt.Errorf("%w: rule %d: destination_url is required", ErrInvalidRedirectRule, i+1)
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if len(req.Name) > 100 {
+			return nil, fmt.Errorf("%w: rule %d: name is too long", ErrInvalidRedirectRule, i+1)
+		}
+		req.Conditions = conditions
+		normalized = append(normalized, req)
+	}
+	return normalized, nil
+}
+
+// Normalize validates the conditions and returns them trimmed and case-folded
+func (c RuleConditions) Normalize() (RuleConditions, error) {
+	var out RuleConditions
+	var err error
+
+	if out.Countries, err = normalizeValues("country", c.Countries, strings.ToUpper, func(v string) bool {
+		return countryCodePattern.MatchString(v)
+	}); err != nil {
+		return out, err
+	}
+	if out.Devices, err = normalizeValues("device", c.Devices, strings.ToLower, func(v string) bool {
+		for _, known := range knownRuleDeviceTypes {
+			if v == known {
+				return true
+			}
+		}
+		return false
+	}); err != nil {
+		return out, err
+	}
+	if out.OperatingSystems, err = normalizeValues("operating system", c.OperatingSystems, nil, func(v string) bool {
+		return len(v) <= 50
+	}); err != nil {
+		return out, err
+	}
+	if out.Languages, err = normalizeValues("language", c.Languages, strings.ToLower, func(v string) bool {
+		return languageTagPattern.MatchString(v)
+	}); err != nil {
+		return out, err
+	}
+	if out.ReferrerDomains, err = normalizeValues("referrer domain", c.ReferrerDomains, func(v string) string {
+		return strings.TrimPrefix(strings.ToLower(v), "www.")
+	}, func(v string) bool {
+		return len(v) <= 253 && domainNamePattern.MatchString(v)
+	}); err != nil {
+		return out, err
+	}
+
+	if c.TimeWindow != nil {
+		window := *c.TimeWindow
+		start, startErr := time.Parse(timeWindowClockLayout, window.Start)
+		end, endErr := time.Parse(timeWindowClockLayout, window.End)
+		if startErr != nil || endErr != nil {
+			return out, fmt.Errorf("time window must be given as HH:MM")
+		}
+		if start.Equal(end) {
+			return out, fmt.Errorf("time window is empty")
+		}
+		if _, err := ruleLocation(window.Timezone); err != nil {
+			return out, fmt.Errorf("unknown timezone %q", window.Timezone)
+		}
+		out.TimeWindow = &window
+	}
+
+	if out.IsEmpty() {
+		return out, fmt.Errorf("at least one condition is required")
+	}
+	return out, nil
+}
+
+func (c RuleConditions) IsEmpty() bool {
+	return len(c.Countries) == 0 && len(c.Devices) == 0 && len(c.OperatingSystems) == 0 &&
+		len(c.Languages) == 0 && len(c.ReferrerDomains) == 0 && c.TimeWindow == nil
+}
+
+// Matches reports whether a visitor satisfies every condition
+func (c RuleConditions) Matches(rc RedirectContext) bool {
+	if c.IsEmpty() {
+		return false
+	}
+	if len(c.Countries) > 0 && !containsFold(c.Countries, rc.Country) {
+		return false
+	}
+	if len(c.Devices) > 0 && !containsFold(c.Devices, rc.Device) {
+		return false
+	}
+	if len(c.OperatingSystems) > 0 && !containsFold(c.OperatingSystems, rc.OS) {
+		return false
+	}
+	if len(c.Languages) > 0 && !c.matchesLanguage(rc.Languages) {
+		return false
+	}
+	if len(c.ReferrerDomains) > 0 && !c.matchesReferrer(rc.Referrer) {
+		return false
+	}
+	if c.TimeWindow != nil && !c.TimeWindow.Contains(rc.Time) {
+		return false
+	}
+	return true
+}
+
+func (c RuleConditions) matchesLanguage(languages []string) bool {
+	for _, language := range languages {
+		language = strings.ToLower(language)
+		for _, want := range c.Languages {
+			if language == want || strings.HasPrefix(language, want+"-") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c RuleConditions) matchesReferrer(referrer string) bool {
+	u, err := url.Parse(strings.TrimSpace(referrer))
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	for _, want := range c.ReferrerDomains {
+		if host == want || strings.HasSuffix(host, "."+want) {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains reports whether t falls inside the window. A window that can no longer be
+// evaluated, say because its zone vanished from the system, never matches.
+func (w *TimeWindow) Contains(t time.Time) bool {
+	start, err := time.Parse(timeWindowClockLayout, w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(timeWindowClockLayout, w.End)
+	if err != nil {
+		return false
+	}
+	loc, err := ruleLocation(w.Timezone)
+	if err != nil {
+		return false
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+	if from < to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
+
+// MatchRedirectRule returns the first rule the visitor matches, or nil
+func (s *ShortURL) MatchRedirectRule(rc RedirectContext) *RedirectRule {
+	for i := range s.RedirectRules {
+		if s.RedirectRules[i].Conditions.Matches(rc) {
+			return &s.RedirectRules[i]
+		}
+	}
+	return nil
+}
+
+// HasCountryRules reports whether any rule depends on the visitor's country
+func (s *ShortURL) HasCountryRules() bool {
+	for i := range s.RedirectRules {
+		if len(s.RedirectRules[i].Conditions.Countries) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// HasDynamicDestination reports whether visitors can be sent somewhere other than
+// OriginalURL, in which case redirects must not be cached
+func (s *ShortURL) HasDynamicDestination() bool {
+	return len(s.RedirectRules) > 0 || s.HasVariants()
+}
+
+func normalizeValues(field string, values []string, fold func(string) string, valid func(string) bool) ([]string, error) {
+	if len(values) > MaxRuleConditionValues {
+		return nil, fmt.Errorf("too many %s values", field)
+	}
+	var out []string
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if fold != nil {
+			value = fold(value)
+		}
+		if value == "" || !valid(value) {
+			return nil, fmt.Errorf("invalid %s %q", field, value)
+		}
+		out = append(out, value)
+	}
+	return out, nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func ruleLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if loc, ok := ruleLocations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	ruleLocations.Store(name, loc)
+	return loc, nil
+}
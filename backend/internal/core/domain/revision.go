@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// URLRevision records one change of a link's destination. A link's first revision
+// has no OldURL. Revisions are only ever added: rolling back adds one too.
+type URLRevision struct {
+	ID         uint   `json:"id" gorm:"primarykey"`
+	ShortURLID uint   `json:"short_url_id" gorm:"not null;index:idx_url_revisions_short_url"`
+	OldURL     string `json:"old_url" gorm:"type:text"`
+	NewURL     string `json:"new_url" gorm:"type:text;not null"`
+	EditorID   *uint  `json:"editor_id,omitempty" gorm:"index"` // nil once the editor's account is gone
+	// RolledBackFrom is the earlier revision whose destination this one restored
+	RolledBackFrom *uint     `json:"rolled_back_from,omitempty"`
+	CreatedAt      time.Time `json:"created_at" gorm:"index:idx_url_revisions_short_url"`
+}
+
+// NewURLRevision describes a change of destination made by editorID
+func NewURLRevision(shortURLID uint, oldURL, newURL string, editorID uint) *URLRevision {
+	revision := &URLRevision{
+		ShortURLID: shortURLID,
+		OldURL:     oldURL,
+		NewURL:     newURL,
+		CreatedAt:  time.Now(),
+	}
+	if editorID != 0 {
+		revision.EditorID = &editorID
+	}
+	return revision
+}
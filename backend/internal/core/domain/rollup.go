@@ -0,0 +1,67 @@
+package domain
+
+import "time"
+
+// Dimensions a click rollup can be keyed by. Total rows carry an empty value and
+// hour rows carry the hour of day, 0-23.
+const (
+	RollupDimensionTotal    = "total"
+	RollupDimensionHour     = "hour"
+	RollupDimensionCountry  = "country"
+	RollupDimensionRegion   = "region"
+	RollupDimensionCity     = "city"
+	RollupDimensionDevice   = "device"
+	RollupDimensionBrowser  = "browser"
+	RollupDimensionReferrer = "referrer" // referrer domain, not the full URL
+)
+
+// ClickRollupHourly holds the click count of one dimension value for one URL and hour
+type ClickRollupHourly struct {
+	ShortURLID uint      `json:"short_url_id" gorm:"primaryKey;autoIncrement:false"`
+	Bucket     time.Time `json:"bucket" gorm:"primaryKey;index"`
+	Dimension  string    `json:"dimension" gorm:"primaryKey;size:20"`
+	Value      string    `json:"value" gorm:"primaryKey;size:255"`
+	Clicks     int64     `json:"clicks" gorm:"not null;default:0"`
+}
+
+func (ClickRollupHourly) TableName() string {
+	return "click_rollups_hourly"
+}
+
+// ClickRollupDaily is the sum of a UTC day's hourly rollups
+type ClickRollupDaily struct {
+	ShortURLID uint      `json:"short_url_id" gorm:"primaryKey;autoIncrement:false"`
+	Bucket     time.Time `json:"bucket" gorm:"primaryKey;index"`
+	Dimension  string    `json:"dimension" gorm:"primaryKey;size:20"`
+	Value      string    `json:"value" gorm:"primaryKey;size:255"`
+	Clicks     int64     `json:"clicks" gorm:"not null;default:0"`
+}
+
+func (ClickRollupDaily) TableName() string {
+	return "click_rollups_daily"
+}
+
+// ClickRollupState records how far the aggregator has got. Clicks before
+// RolledUpUntil are served from the rollup tables, later ones from clicks.
+type ClickRollupState struct {
+	ID            uint      `json:"id" gorm:"primarykey"`
+	RolledUpUntil time.Time `json:"rolled_up_until"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (ClickRollupState) TableName() string {
+	return "click_rollup_state"
+}
+
+// ClickVisitor records the first click of each IP address on a URL. Distinct
+// visitors cannot be summed across rollup buckets, so they are kept here as the
+// clicks are written and counted without scanning raw clicks.
+type ClickVisitor struct {
+	ShortURLID  uint      `json:"short_url_id" gorm:"primaryKey;autoIncrement:false"`
+	IPAddress   string    `json:"ip_address" gorm:"primaryKey;type:inet"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+}
+
+func (ClickVisitor) TableName() string {
+	return "click_visitors"
+}
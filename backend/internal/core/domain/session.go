@@ -0,0 +1,44 @@
+package domain
+
+import "time"
+
+// Reasons a session was revoked, kept for auditing
+const (
+	SessionRevokedLogout         = "logout"
+	SessionRevokedByUser         = "revoked"
+	SessionRevokedAll            = "revoked_all"
+	SessionRevokedReuse          = "reuse_detected"
+	SessionRevokedPasswordChange = "password_changed"
+	SessionRevokedPasswordReset  = "password_reset"
+	SessionRevokedDeactivated    = "account_deactivated"
+)
+
+// Session is one signed-in device. Its refresh token is rotated on every refresh;
+// all tokens issued for a session share its FamilyID and only the latest one,
+// whose hash is TokenHash, is accepted. Presenting an older one revokes the session.
+type Session struct {
+	ID           uint       `json:"id" gorm:"primarykey"`
+	UserID       uint       `json:"-" gorm:"not null;index"`
+	FamilyID     string     `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	TokenHash    string     `json:"-" gorm:"size:64;not null"`
+	Device       string     `json:"device" gorm:"size:100"`
+	UserAgent    string     `json:"user_agent" gorm:"size:500"`
+	IPAddress    string     `json:"ip_address" gorm:"size:45"`
+	LastSeenAt   time.Time  `json:"last_seen_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"-" gorm:"index"`
+	RevokeReason string     `json:"-" gorm:"size:50"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"-"`
+}
+
+// SessionMetadata describes the client a session is created or refreshed from
+type SessionMetadata struct {
+	UserAgent string
+	Device    string
+	IPAddress string
+}
+
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// RecoveryCodeCount is how many single-use recovery codes are issued at a time
+const RecoveryCodeCount = 10
+
+// RecoveryCode is a single-use fallback for a lost authenticator. Only a SHA-256
+// hash of the normalized code is stored.
+type RecoveryCode struct {
+	ID        uint       `json:"-" gorm:"primarykey"`
+	UserID    uint       `json:"-" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"size:64;not null"`
+	UsedAt    *time.Time `json:"-"`
+	CreatedAt time.Time  `json:"-"`
+}
+
+// TwoFactorSetupResponse carries the secret for the authenticator app, both as
+// an otpauth:// URI and as a QR code of that URI
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+	QRCode     string `json:"qr_code,omitempty"` // data: URL of a PNG image
+}
+
+// RecoveryCodesResponse is the only time recovery codes are shown
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorCodeRequest confirms an action with a second factor. Code is either a
+// 6-digit authenticator code or a recovery code.
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// MFALoginRequest completes a sign-in that returned mfa_required
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+func (r *TwoFactorCodeRequest) Validate() error {
+	r.Code = strings.TrimSpace(r.Code)
+	if r.Code == "" {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func (r *DisableTwoFactorRequest) Validate() error {
+	r.Code = strings.TrimSpace(r.Code)
+	if r.Password == "" {
+		return ErrInvalidPassword
+	}
+	if r.Code == "" {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func (r *MFALoginRequest) Validate() error {
+	r.Code = strings.TrimSpace(r.Code)
+	if r.MFAToken == "" {
+		return ErrInvalidToken
+	}
+	if r.Code == "" {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
@@ -11,6 +11,7 @@ type ShortURL struct {
 	ShortCode   string         `json:"short_code" gorm:"uniqueIndex;not null"`
 	OriginalURL string         `json:"original_url" gorm:"type:text;not null"`
 	UserID      uint           `json:"user_id" gorm:"index;not null"`
+	WorkspaceID *uint          `json:"workspace_id,omitempty" gorm:"index"` // nil for personal links
 	Title       string         `json:"title" gorm:"size:255"`
 	Description string         `json:"description" gorm:"type:text"`
 	Password    *string        `json:"-" gorm:"size:255"`
@@ -23,8 +24,12 @@ type ShortURL struct {
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relationships
-	User   *User   `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	Clicks []Click `json:"clicks,omitempty" gorm:"foreignKey:ShortURLID"`
+	User     *User        `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Clicks   []Click      `json:"clicks,omitempty" gorm:"foreignKey:ShortURLID"`
+	Variants []URLVariant `json:"variants,omitempty" gorm:"foreignKey:ShortURLID"`
+
+	RedirectRules []RedirectRule `json:"redirect_rules,omitempty" gorm:"foreignKey:ShortURLID"`
+	Revisions     []URLRevision  `json:"-" gorm:"foreignKey:ShortURLID"` // set on create to start the history
 }
 
 type CreateShortURLRequest struct {
@@ -40,17 +45,19 @@ type UpdateShortURLRequest struct {
 }
 
 type ShortURLResponse struct {
-	ID          uint       `json:"id"`
-	ShortCode   string     `json:"short_code"`
-	OriginalURL string     `json:"original_url"`
-	ShortURL    string     `json:"short_url"`
-	CustomAlias bool       `json:"custom_alias"`
-	ExpiresAt   *time.Time `json:"expires_at"`
-	IsActive    bool       `json:"is_active"`
-	ClickCount  int64      `json:"click_count"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
-	User        *User      `json:"user,omitempty"`
+	ID            uint           `json:"id"`
+	ShortCode     string         `json:"short_code"`
+	OriginalURL   string         `json:"original_url"`
+	ShortURL      string         `json:"short_url"`
+	CustomAlias   bool           `json:"custom_alias"`
+	ExpiresAt     *time.Time     `json:"expires_at"`
+	IsActive      bool           `json:"is_active"`
+	ClickCount    int64          `json:"click_count"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	User          *User          `json:"user,omitempty"`
+	Variants      []URLVariant   `json:"variants,omitempty"`
+	RedirectRules []RedirectRule `json:"redirect_rules,omitempty"`
 }
 
 type ShortURLListResponse struct {
@@ -63,17 +70,19 @@ type ShortURLListResponse struct {
 
 func (s *ShortURL) ToResponse(baseURL string) *ShortURLResponse {
 	return &ShortURLResponse{
-		ID:          s.ID,
-		ShortCode:   s.ShortCode,
-		OriginalURL: s.OriginalURL,
-		ShortURL:    baseURL + "/" + s.ShortCode,
-		CustomAlias: s.CustomAlias,
-		ExpiresAt:   s.ExpiresAt,
-		IsActive:    s.IsActive,
-		ClickCount:  s.ClickCount,
-		CreatedAt:   s.CreatedAt,
-		UpdatedAt:   s.UpdatedAt,
-		User:        s.User,
+		ID:            s.ID,
+		ShortCode:     s.ShortCode,
+		OriginalURL:   s.OriginalURL,
+		ShortURL:      baseURL + "/" + s.ShortCode,
+		CustomAlias:   s.CustomAlias,
+		ExpiresAt:     s.ExpiresAt,
+		IsActive:      s.IsActive,
+		ClickCount:    s.ClickCount,
+		CreatedAt:     s.CreatedAt,
+		UpdatedAt:     s.UpdatedAt,
+		User:          s.User,
+		Variants:      s.Variants,
+		RedirectRules: s.RedirectRules,
 	}
 }
 
@@ -113,30 +122,60 @@ func (s *ShortURL) IsAccessible() bool {
 type ShortenURLRequest struct {
 	OriginalURL string     `json:"original_url" validate:"required,url"`
 	UserID      uint       `json:"user_id" validate:"required"`
+	// WorkspaceID creates the link in a workspace, which then owns it, instead of
+	// as a personal link of UserID
+	WorkspaceID *uint      `json:"workspace_id,omitempty"`
 	Title       string     `json:"title" validate:"omitempty,max=255"`
 	Description string     `json:"description" validate:"omitempty,max=1000"`
 	CustomAlias string     `json:"custom_alias" validate:"omitempty,alphanum,max=50"`
 	Password    string     `json:"password" validate:"omitempty,min=4"`
 	ExpiresAt   *time.Time `json:"expires_at"`
+
+	// Variants split traffic between several destinations; OriginalURL stays the
+	// fallback when none can be chosen
+	Variants []VariantRequest `json:"variants,omitempty"`
+
+	// RedirectRules send matching visitors elsewhere, ahead of any variant split
+	RedirectRules []RedirectRuleRequest `json:"redirect_rules,omitempty"`
 }
 
 type UpdateURLRequest struct {
+	// OriginalURL changes where the link goes; every change is kept in its history
+	OriginalURL *string    `json:"original_url,omitempty" validate:"omitempty,url"`
 	Title       *string    `json:"title,omitempty" validate:"omitempty,max=255"`
 	Description *string    `json:"description,omitempty" validate:"omitempty,max=1000"`
 	IsActive    *bool      `json:"is_active,omitempty"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Password    *string    `json:"password,omitempty" validate:"omitempty,min=4"` // "" removes the password
+
+	// Variants replaces the link's variant set; an empty list removes them all.
+	// Variants are matched by name, so renaming one starts its stats afresh.
+	Variants *[]VariantRequest `json:"variants,omitempty"`
+
+	// RedirectRules replaces the link's targeting rules, kept in the given order; an
+	// empty list removes them all
+	RedirectRules *[]RedirectRuleRequest `json:"redirect_rules,omitempty"`
+}
+
+// LinkUnlock proves a visitor entered a link's password
+type LinkUnlock struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 type ClickData struct {
-	IPAddress string `json:"ip_address"`
-	UserAgent string `json:"user_agent"`
-	Referer   string `json:"referer"`
-	Country   string `json:"country"`
-	Region    string `json:"region"`
-	City      string `json:"city"`
-	Device    string `json:"device"`
-	Browser   string `json:"browser"`
-	OS        string `json:"os"`
+	IPAddress      string `json:"ip_address"`
+	UserAgent      string `json:"user_agent"`
+	Referer        string `json:"referer"`
+	Country        string `json:"country"`
+	Region         string `json:"region"`
+	City           string `json:"city"`
+	Device         string `json:"device"`
+	Browser        string `json:"browser"`
+	BrowserVersion string `json:"browser_version"`
+	OS             string `json:"os"`
+	OSVersion      string `json:"os_version"`
+	VariantID      *uint  `json:"variant_id,omitempty"`
 }
 
 type URLStats struct {
@@ -204,9 +243,26 @@ func (r *ShortenURLRequest) Validate() error {
 	if r.UserID == 0 {
 		return ErrInvalidRequest
 	}
-	return nil
+	if _, err := ValidateRedirectRules(r.RedirectRules); err != nil {
+		return err
+	}
+	return ValidateVariants(r.Variants)
 }
 
 func (r *UpdateURLRequest) Validate() error {
+	if r.OriginalURL != nil && *r.OriginalURL == "" {
+		return ErrInvalidURL
+	}
+	if r.Password != nil && *r.Password != "" && len(*r.Password) < 4 {
+		return ErrWeakPassword
+	}
+	if r.RedirectRules != nil {
+		if _, err := ValidateRedirectRules(*r.RedirectRules); err != nil {
+			return err
+		}
+	}
+	if r.Variants != nil {
+		return ValidateVariants(*r.Variants)
+	}
 	return nil
 }
\ No newline at end of file
@@ -6,6 +6,14 @@ import (
 	"gorm.io/gorm"
 )
 
+// Roles, from least to most privileged. Support staff can look up accounts and
+// their links; only admins can change them.
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
 type User struct {
 	ID          uint           `json:"id" gorm:"primarykey"`
 	Email       string         `json:"email" gorm:"uniqueIndex;not null"`
@@ -13,7 +21,17 @@ type User struct {
 	FirstName   string         `json:"first_name" gorm:"not null"`
 	LastName    string         `json:"last_name" gorm:"not null"`
 	IsActive    bool           `json:"is_active" gorm:"default:true"`
+	Role        string         `json:"role" gorm:"size:20;not null;default:user;index"`
 	LastLoginAt *time.Time     `json:"last_login_at,omitempty"`
+	VerifiedAt  *time.Time     `json:"verified_at,omitempty"`
+
+	// Two-factor authentication. TOTPSecret is set when setup starts and
+	// TOTPEnabledAt once the first code has been confirmed.
+	TOTPSecret        string     `json:"-" gorm:"column:totp_secret;size:64"`
+	TOTPEnabledAt     *time.Time `json:"-" gorm:"column:totp_enabled_at"`
+	TOTPLastStep      int64      `json:"-" gorm:"column:totp_last_step;default:0"`
+	TwoFactorRequired bool       `json:"two_factor_required" gorm:"default:false"`
+
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
@@ -33,12 +51,17 @@ type LoginRequest struct {
 }
 
 type UserResponse struct {
-	ID        uint      `json:"id"`
-	Email     string    `json:"email"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	IsActive  bool      `json:"is_active"`
-	CreatedAt time.Time `json:"created_at"`
+	ID                uint       `json:"id"`
+	Email             string     `json:"email"`
+	FirstName         string     `json:"first_name"`
+	LastName          string     `json:"last_name"`
+	IsActive          bool       `json:"is_active"`
+	Role              string     `json:"role"`
+	EmailVerified     bool       `json:"email_verified"`
+	VerifiedAt        *time.Time `json:"verified_at,omitempty"`
+	TwoFactorEnabled  bool       `json:"two_factor_enabled"`
+	TwoFactorRequired bool       `json:"two_factor_required"`
+	CreatedAt         time.Time  `json:"created_at"`
 }
 
 type UpdateUserRequest struct {
@@ -80,6 +103,11 @@ type AuthResponse struct {
 	RefreshToken string        `json:"refresh_token"`
 	TokenType    string        `json:"token_type"`
 	ExpiresIn    int           `json:"expires_in"`
+
+	// Set instead of the tokens when the password was right but a second
+	// factor is still needed; MFAToken is exchanged at /auth/login/2fa
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
 }
 
 type LoginResponse struct {
@@ -96,10 +124,29 @@ type TokenResponse struct {
 }
 
 type TokenClaims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
-	Exp    int64  `json:"exp"`
-	Iat    int64  `json:"iat"`
+	UserID    uint   `json:"user_id"`
+	Email     string `json:"email"`
+	SessionID string `json:"sid,omitempty"`
+	Role      string `json:"role,omitempty"`
+	Exp       int64  `json:"exp"`
+	Iat       int64  `json:"iat"`
+}
+
+// JSONWebKey is a public key that verifies tokens, in RFC 7517 form
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JSONWebKeySet is the document served at /.well-known/jwks.json
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
 }
 
 type UserStats struct {
@@ -127,13 +174,61 @@ type TopURLStat struct {
 
 func (u *User) ToResponse() *UserResponse {
 	return &UserResponse{
-		ID:        u.ID,
-		Email:     u.Email,
-		FirstName: u.FirstName,
-		LastName:  u.LastName,
-		IsActive:  u.IsActive,
-		CreatedAt: u.CreatedAt,
+		ID:                u.ID,
+		Email:             u.Email,
+		FirstName:         u.FirstName,
+		LastName:          u.LastName,
+		IsActive:          u.IsActive,
+		Role:              u.RoleOrDefault(),
+		EmailVerified:     u.IsVerified(),
+		VerifiedAt:        u.VerifiedAt,
+		TwoFactorEnabled:  u.IsTwoFactorEnabled(),
+		TwoFactorRequired: u.TwoFactorRequired,
+		CreatedAt:         u.CreatedAt,
+	}
+}
+
+// IsVerified reports whether the user has confirmed ownership of their email address
+func (u *User) IsVerified() bool {
+	return u.VerifiedAt != nil
+}
+
+// RoleOrDefault treats accounts created before roles existed as regular users
+func (u *User) RoleOrDefault() string {
+	if u.Role == "" {
+		return RoleUser
+	}
+	return u.Role
+}
+
+// IsAdmin reports whether the user can manage other accounts
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// HasRole reports whether the user has any of the given roles
+func (u *User) HasRole(roles ...string) bool {
+	role := u.RoleOrDefault()
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
 	}
+	return false
+}
+
+// IsValidRole reports whether role is one of the known roles
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleUser, RoleSupport, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// IsTwoFactorEnabled reports whether sign-in needs a TOTP or recovery code
+func (u *User) IsTwoFactorEnabled() bool {
+	return u.TOTPEnabledAt != nil
 }
 
 // Validation methods
@@ -175,6 +270,23 @@ func (r *ChangePasswordRequest) Validate() error {
 	return nil
 }
 
+func (r *PasswordResetRequest) Validate() error {
+	if r.Email == "" {
+		return ErrInvalidEmail
+	}
+	return nil
+}
+
+func (r *PasswordResetConfirmRequest) Validate() error {
+	if r.Token == "" {
+		return ErrInvalidToken
+	}
+	if len(r.NewPassword) < 8 {
+		return ErrInvalidPassword
+	}
+	return nil
+}
+
 func (r *UpdateProfileRequest) Validate() error {
 	// Basic validation for profile update requests
 	return nil
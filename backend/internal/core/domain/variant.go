@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Limits on the variants of an A/B split link
+const (
+	MinVariants          = 2
+	MaxVariants          = 10
+	MaxVariantWeight     = 1000
+	MaxVariantNameLength = 50
+)
+
+// URLVariant is one weighted destination of an A/B split link. Visitors are sent to
+// a variant with probability Weight / sum of all weights; a weight of 0 pauses it.
+type URLVariant struct {
+	ID             uint           `json:"id" gorm:"primarykey"`
+	ShortURLID     uint           `json:"short_url_id" gorm:"not null;index"`
+	Name           string         `json:"name" gorm:"size:50;not null"`
+	DestinationURL string         `json:"destination_url" gorm:"type:text;not null"`
+	Weight         int            `json:"weight" gorm:"not null;default:1"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+type VariantRequest struct {
+	Name           string `json:"name" validate:"required,max=50"`
+	DestinationURL string `json:"destination_url" validate:"required,url"`
+	Weight         int    `json:"weight" validate:"min=0,max=1000"`
+}
+
+// VariantStats compares the variants of a split link. Variants that were removed
+// from the link still appear while they have clicks.
+type VariantStats struct {
+	ShortURLID  uint          `json:"short_url_id"`
+	ShortCode   string        `json:"short_code"`
+	TotalClicks int64         `json:"total_clicks"`
+	Variants    []VariantStat `json:"variants"`
+}
+
+type VariantStat struct {
+	VariantID      uint    `json:"variant_id"`
+	Name           string  `json:"name"`
+	DestinationURL string  `json:"destination_url"`
+	Weight         int     `json:"weight"`
+	WeightShare    float64 `json:"weight_share"` // configured share of traffic, percent
+	Clicks         int64   `json:"clicks"`
+	UniqueClicks   int64   `json:"unique_clicks"`
+	ClickShare     float64 `json:"click_share"` // observed share of traffic, percent
+	Active         bool    `json:"active"`
+}
+
+// VariantClickCount is the click tally of one variant
+type VariantClickCount struct {
+	VariantID    uint  `json:"variant_id"`
+	Clicks       int64 `json:"clicks"`
+	UniqueClicks int64 `json:"unique_clicks"`
+}
+
+// ValidateVariants checks the shape of a variant set; destination URLs are checked by
+// the URL service. An empty set is valid and turns splitting off.
+func ValidateVariants(variants []VariantRequest) error {
+	if len(variants) == 0 {
+		return nil
+	}
+	if len(variants) < MinVariants || len(variants) > MaxVariants {
+		return ErrInvalidVariants
+	}
+
+	names := make(map[string]bool, len(variants))
+	total := 0
+	for _, v := range variants {
+		name := strings.ToLower(strings.TrimSpace(v.Name))
+		if name == "" || len(name) > MaxVariantNameLength || names[name] {
+			return ErrInvalidVariants
+		}
+		names[name] = true
+
+		if v.DestinationURL == "" || v.Weight < 0 || v.Weight > MaxVariantWeight {
+			return ErrInvalidVariants
+		}
+		total += v.Weight
+	}
+	if total == 0 {
+		return ErrInvalidVariants
+	}
+	return nil
+}
+
+// HasVariants reports whether redirects are split between variants
+func (s *ShortURL) HasVariants() bool {
+	return s.TotalVariantWeight() > 0
+}
+
+func (s *ShortURL) TotalVariantWeight() int {
+	total := 0
+	for _, v := range s.Variants {
+		if v.Weight > 0 {
+			total += v.Weight
+		}
+	}
+	return total
+}
+
+// PickVariant maps n, in [0, TotalVariantWeight()), onto the variant whose share of
+// the weight range contains it
+func (s *ShortURL) PickVariant(n int) *URLVariant {
+	for i := range s.Variants {
+		v := &s.Variants[i]
+		if v.Weight <= 0 {
+			continue
+		}
+		if n < v.Weight {
+			return v
+		}
+		n -= v.Weight
+	}
+	return nil
+}
+
+// ActiveVariant returns the variant with the given ID if it can still receive traffic
+func (s *ShortURL) ActiveVariant(id uint) *URLVariant {
+	for i := range s.Variants {
+		if s.Variants[i].ID == id && s.Variants[i].Weight > 0 {
+			return &s.Variants[i]
+		}
+	}
+	return nil
+}
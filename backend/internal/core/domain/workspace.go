@@ -0,0 +1,211 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Workspace member roles, from most to least privileged
+const (
+	WorkspaceRoleOwner  = "owner"
+	WorkspaceRoleEditor = "editor"
+	WorkspaceRoleViewer = "viewer"
+)
+
+// WorkspacePermission is something a member may do with a workspace or its links
+type WorkspacePermission int
+
+const (
+	// PermissionViewLinks covers reading links and their analytics
+	PermissionViewLinks WorkspacePermission = iota + 1
+	// PermissionEditLinks covers creating, changing and deleting links
+	PermissionEditLinks
+	// PermissionManageWorkspace covers renaming the workspace and managing members
+	PermissionManageWorkspace
+)
+
+const (
+	MaxWorkspaceNameLength = 100
+	WorkspaceInvitationTTL = 7 * 24 * time.Hour
+)
+
+// workspaceRoleRank orders the roles; each role holds every permission up to its rank
+var workspaceRoleRank = map[string]WorkspacePermission{
+	WorkspaceRoleViewer: PermissionViewLinks,
+	WorkspaceRoleEditor: PermissionEditLinks,
+	WorkspaceRoleOwner:  PermissionManageWorkspace,
+}
+
+// Workspace groups users who share ownership of links
+type Workspace struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	Name      string    `json:"name" gorm:"size:100;not null"`
+	CreatedBy uint      `json:"created_by" gorm:"not null;index"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WorkspaceMember gives a user a role in a workspace
+type WorkspaceMember struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	WorkspaceID uint      `json:"workspace_id" gorm:"not null;uniqueIndex:idx_workspace_members_workspace_user"`
+	UserID      uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_workspace_members_workspace_user;index"`
+	Role        string    `json:"role" gorm:"size:20;not null"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Relationships
+	Workspace *Workspace `json:"workspace,omitempty" gorm:"foreignKey:WorkspaceID"`
+	User      *User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// WorkspaceInvitation asks someone to join a workspace by email. Only a SHA-256
+// hash of the token in the invitation link is stored.
+type WorkspaceInvitation struct {
+	ID          uint       `json:"id" gorm:"primarykey"`
+	WorkspaceID uint       `json:"workspace_id" gorm:"not null;index"`
+	Email       string     `json:"email" gorm:"size:255;not null"`
+	Role        string     `json:"role" gorm:"size:20;not null"`
+	TokenHash   string     `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	InvitedBy   uint       `json:"invited_by" gorm:"not null"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+
+	// Relationships
+	Workspace *Workspace `json:"workspace,omitempty" gorm:"foreignKey:WorkspaceID"`
+}
+
+type CreateWorkspaceRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type UpdateWorkspaceRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type InviteMemberRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required"`
+}
+
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// WorkspaceResponse is a workspace as seen by one of its members
+type WorkspaceResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedBy uint      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WorkspaceMemberResponse is a member as listed to the rest of the workspace
+type WorkspaceMemberResponse struct {
+	UserID    uint      `json:"user_id"`
+	Email     string    `json:"email"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Role      string    `json:"role"`
+	JoinedAt  time.Time `json:"joined_at"`
+}
+
+// IsValidWorkspaceRole reports whether role is one of the workspace member roles
+func IsValidWorkspaceRole(role string) bool {
+	_, ok := workspaceRoleRank[role]
+	return ok
+}
+
+// Can reports whether the member's role grants the permission
+func (m *WorkspaceMember) Can(permission WorkspacePermission) bool {
+	return workspaceRoleRank[m.Role] >= permission
+}
+
+func (m *WorkspaceMember) IsOwner() bool {
+	return m.Role == WorkspaceRoleOwner
+}
+
+func (m *WorkspaceMember) ToResponse() *WorkspaceMemberResponse {
+	resp := &WorkspaceMemberResponse{
+		UserID:   m.UserID,
+		Role:     m.Role,
+		JoinedAt: m.CreatedAt,
+	}
+	if m.User != nil {
+		resp.Email = m.User.Email
+		resp.FirstName = m.User.FirstName
+		resp.LastName = m.User.LastName
+	}
+	return resp
+}
+
+func (w *Workspace) ToResponse(role string) *WorkspaceResponse {
+	return &WorkspaceResponse{
+		ID:        w.ID,
+		Name:      w.Name,
+		Role:      role,
+		CreatedBy: w.CreatedBy,
+		CreatedAt: w.CreatedAt,
+	}
+}
+
+func (i *WorkspaceInvitation) IsExpired() bool {
+	return time.Now().After(i.ExpiresAt)
+}
+
+func (i *WorkspaceInvitation) IsAccepted() bool {
+	return i.AcceptedAt != nil
+}
+
+func (r *CreateWorkspaceRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	return validateWorkspaceName(r.Name)
+}
+
+func (r *UpdateWorkspaceRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	return validateWorkspaceName(r.Name)
+}
+
+func (r *InviteMemberRequest) Validate() error {
+	r.Email = strings.ToLower(strings.TrimSpace(r.Email))
+	if r.Email == "" || !strings.Contains(r.Email, "@") || len(r.Email) > 255 {
+		return ErrInvalidEmail
+	}
+	if !IsValidWorkspaceRole(r.Role) {
+		return ErrInvalidWorkspaceRole
+	}
+	return nil
+}
+
+func (r *UpdateMemberRoleRequest) Validate() error {
+	if !IsValidWorkspaceRole(r.Role) {
+		return ErrInvalidWorkspaceRole
+	}
+	return nil
+}
+
+func (r *AcceptInvitationRequest) Validate() error {
+	r.Token = strings.TrimSpace(r.Token)
+	if r.Token == "" {
+		return fmt.Errorf("%w: token is required", ErrInvalidInput)
+	}
+	return nil
+}
+
+func validateWorkspaceName(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+	if len(name) > MaxWorkspaceNameLength {
+		return fmt.Errorf("%w: name must be at most %d characters", ErrInvalidInput, MaxWorkspaceNameLength)
+	}
+	return nil
+}
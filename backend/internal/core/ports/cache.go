@@ -9,6 +9,8 @@ type CacheService interface {
 	// Basic cache operations
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
 	Get(ctx context.Context, key string) (string, error)
+	// GetDel reads and deletes a key in one step, so only one caller gets its value
+	GetDel(ctx context.Context, key string) (string, error)
 	Del(ctx context.Context, keys ...string) error
 	Exists(ctx context.Context, key string) (bool, error)
 	TTL(ctx context.Context, key string) (time.Duration, error)
@@ -41,6 +43,7 @@ type CacheService interface {
 	SetSession(ctx context.Context, token string, userID uint, expiration time.Duration) error
 	GetSession(ctx context.Context, token string) (uint, error)
 	InvalidateSession(ctx context.Context, token string) error
+	InvalidateUserSessions(ctx context.Context, userID uint) error
 
 	// Analytics caching
 	CacheClickCount(ctx context.Context, shortCode string, count int64) error
@@ -2,6 +2,7 @@ package ports
 
 import (
 	"context"
+	"time"
 
 	"url-shortener/internal/core/domain"
 )
@@ -13,15 +14,97 @@ type UserRepository interface {
 	GetByEmail(ctx context.Context, email string) (*domain.User, error)
 	Update(ctx context.Context, user *domain.User) error
 	Delete(ctx context.Context, id uint) error
+	// AdvanceTOTPStep records step as the user's last used authenticator step. It
+	// reports false when a code from this or a later step was already used.
+	AdvanceTOTPStep(ctx context.Context, id uint, step int64) (bool, error)
 	
 	// User queries
 	Exists(ctx context.Context, email string) (bool, error)
 	List(ctx context.Context, offset, limit int) ([]*domain.User, int64, error)
+	// Search lists users matching the filter, newest first, with the total number of matches
+	Search(ctx context.Context, filter domain.UserFilter, offset, limit int) ([]*domain.User, int64, error)
 	
 	// User statistics
 	GetUserStats(ctx context.Context, userID uint) (*domain.UserStats, error)
 }
 
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *domain.APIKey) error
+	GetByID(ctx context.Context, id uint) (*domain.APIKey, error)
+	GetByHash(ctx context.Context, keyHash string) (*domain.APIKey, error)
+	// ListActiveByUser returns the user's keys that have not been revoked, newest first
+	ListActiveByUser(ctx context.Context, userID uint) ([]*domain.APIKey, error)
+	Update(ctx context.Context, key *domain.APIKey) error
+	// TouchLastUsed records when and from where the key was last used
+	TouchLastUsed(ctx context.Context, id uint, at time.Time, ip string) error
+}
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *domain.Session) error
+	GetByID(ctx context.Context, id uint) (*domain.Session, error)
+	GetByFamilyID(ctx context.Context, familyID string) (*domain.Session, error)
+	// ListActiveByUser returns sessions that are neither revoked nor expired, most recently seen first
+	ListActiveByUser(ctx context.Context, userID uint, now time.Time) ([]*domain.Session, error)
+	// Rotate stores the session's new token hash and client details, but only if its
+	// current hash is still previousHash. It reports false when another refresh won.
+	Rotate(ctx context.Context, session *domain.Session, previousHash string) (bool, error)
+	Revoke(ctx context.Context, id uint, reason string) error
+	// RevokeAllByUser revokes every session of the user that is not already revoked
+	RevokeAllByUser(ctx context.Context, userID uint, reason string) error
+}
+
+type RecoveryCodeRepository interface {
+	// ReplaceForUser deletes the user's existing codes and stores the new hashes
+	ReplaceForUser(ctx context.Context, userID uint, codeHashes []string) error
+	// Consume marks an unused code as used and reports whether one matched
+	Consume(ctx context.Context, userID uint, codeHash string) (bool, error)
+	DeleteByUser(ctx context.Context, userID uint) error
+}
+
+// AuditRepository stores the audit log, which is append-only
+type AuditRepository interface {
+	Create(ctx context.Context, event *domain.AuditEvent) error
+	// List returns matching events, newest first
+	List(ctx context.Context, filter domain.AuditFilter, offset, limit int) ([]*domain.AuditEvent, int64, error)
+}
+
+// UserIdentityRepository stores links between users and single sign-on accounts
+type UserIdentityRepository interface {
+	Create(ctx context.Context, identity *domain.UserIdentity) error
+	GetByIssuerSubject(ctx context.Context, issuer, subject string) (*domain.UserIdentity, error)
+	Update(ctx context.Context, identity *domain.UserIdentity) error
+}
+
+type WorkspaceRepository interface {
+	// Create stores the workspace and its first owner together
+	Create(ctx context.Context, workspace *domain.Workspace, owner *domain.WorkspaceMember) error
+	GetByID(ctx context.Context, id uint) (*domain.Workspace, error)
+	Update(ctx context.Context, workspace *domain.Workspace) error
+	// ListByUser returns the user's memberships with their workspaces loaded, by workspace name
+	ListByUser(ctx context.Context, userID uint) ([]*domain.WorkspaceMember, error)
+
+	// Members
+	GetMember(ctx context.Context, workspaceID, userID uint) (*domain.WorkspaceMember, error)
+	// ListMembers returns the members with their users loaded, oldest first
+	ListMembers(ctx context.Context, workspaceID uint) ([]*domain.WorkspaceMember, error)
+	AddMember(ctx context.Context, member *domain.WorkspaceMember) error
+	UpdateMember(ctx context.Context, member *domain.WorkspaceMember) error
+	RemoveMember(ctx context.Context, workspaceID, userID uint) error
+	CountOwners(ctx context.Context, workspaceID uint) (int64, error)
+
+	// Invitations
+	CreateInvitation(ctx context.Context, invitation *domain.WorkspaceInvitation) error
+	GetInvitationByID(ctx context.Context, id uint) (*domain.WorkspaceInvitation, error)
+	// GetInvitationByTokenHash returns the invitation with its workspace loaded
+	GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*domain.WorkspaceInvitation, error)
+	// ListPendingInvitations returns invitations that are neither accepted nor expired, newest first
+	ListPendingInvitations(ctx context.Context, workspaceID uint, now time.Time) ([]*domain.WorkspaceInvitation, error)
+	// AcceptInvitation marks the invitation accepted and adds the member in one
+	// transaction. It returns ErrInvitationNotFound if the invitation was already used.
+	AcceptInvitation(ctx context.Context, invitation *domain.WorkspaceInvitation, member *domain.WorkspaceMember) error
+	DeleteInvitation(ctx context.Context, id uint) error
+}
+
 type URLRepository interface {
 	// URL management
 	Create(ctx context.Context, url *domain.ShortURL) error
@@ -32,11 +115,35 @@ type URLRepository interface {
 	
 	// URL queries
 	ExistsByShortCode(ctx context.Context, shortCode string) (bool, error)
+	// GetByUserID lists the user's personal links; links they created in a workspace
+	// belong to the workspace and are listed by GetByWorkspaceID
 	GetByUserID(ctx context.Context, userID uint, offset, limit int) ([]*domain.ShortURL, int64, error)
+	GetByWorkspaceID(ctx context.Context, workspaceID uint, offset, limit int) ([]*domain.ShortURL, int64, error)
 	GetActiveByShortCode(ctx context.Context, shortCode string) (*domain.ShortURL, error)
+
+	// Variants
+	// ReplaceVariants syncs a URL's variants with the given set by name: matching ones are
+	// updated in place, new ones created and the rest removed
+	ReplaceVariants(ctx context.Context, shortURLID uint, variants []*domain.URLVariant) error
+	// GetVariants returns every variant the URL has had, removed ones included
+	GetVariants(ctx context.Context, shortURLID uint) ([]*domain.URLVariant, error)
+
+	// Targeting rules
+	// ReplaceRedirectRules swaps a URL's rules for the given ones, in order
+	ReplaceRedirectRules(ctx context.Context, shortURLID uint, rules []*domain.RedirectRule) error
+
+	// Destination history
+	// UpdateWithRevision saves the URL and records the revision that changed its
+	// destination together, so neither is kept without the other
+	UpdateWithRevision(ctx context.Context, url *domain.ShortURL, revision *domain.URLRevision) error
+	// GetRevision returns one of the URL's revisions, or ErrRevisionNotFound
+	GetRevision(ctx context.Context, shortURLID, revisionID uint) (*domain.URLRevision, error)
+	// GetRevisions lists the URL's revisions, newest first
+	GetRevisions(ctx context.Context, shortURLID uint, offset, limit int) ([]*domain.URLRevision, int64, error)
 	
 	// URL operations
 	IncrementClickCount(ctx context.Context, id uint) error
+	IncrementClickCounts(ctx context.Context, counts map[uint]int64) error
 	GetExpiredURLs(ctx context.Context, limit int) ([]*domain.ShortURL, error)
 	
 	// URL statistics
@@ -48,6 +155,10 @@ type URLRepository interface {
 type ClickRepository interface {
 	// Click management
 	Create(ctx context.Context, click *domain.Click) error
+	CreateBatch(ctx context.Context, clicks []*domain.Click) error
+	// RecordBatch inserts the clicks and adds them to their URLs' click counts in
+	// one transaction
+	RecordBatch(ctx context.Context, clicks []*domain.Click) error
 	GetByID(ctx context.Context, id uint) (*domain.Click, error)
 	
 	// Click queries
@@ -60,13 +171,51 @@ type ClickRepository interface {
 	GetTotalClicks(ctx context.Context, shortURLID uint) (int64, error)
 	GetUniqueClicks(ctx context.Context, shortURLID uint) (int64, error)
 	GetClicksByDateRange(ctx context.Context, shortURLID uint, startDate, endDate string) ([]*domain.Click, error)
+	GetClicksAfter(ctx context.Context, shortURLID uint, from, to time.Time, afterID uint, limit int) ([]*domain.Click, error)
 	GetTopCountries(ctx context.Context, shortURLID uint, limit int) ([]domain.CountryStat, error)
 	GetTopDevices(ctx context.Context, shortURLID uint, limit int) ([]domain.DeviceStat, error)
 	GetTopBrowsers(ctx context.Context, shortURLID uint, limit int) ([]domain.BrowserStat, error)
 	GetTopReferers(ctx context.Context, shortURLID uint, limit int) ([]domain.RefererStat, error)
 	GetRecentClicks(ctx context.Context, shortURLID uint, limit int) ([]domain.RecentClickStat, error)
+	GetVariantClickCounts(ctx context.Context, shortURLID uint) ([]domain.VariantClickCount, error)
 	
 	// Global analytics
 	GetGlobalStats(ctx context.Context) (*domain.GlobalStats, error)
 	GetUserStats(ctx context.Context, userID uint) (*domain.UserAnalytics, error)
-}
\ No newline at end of file
+}
+
+// ClickRollupRepository maintains the hourly and daily click rollup tables
+type ClickRollupRepository interface {
+	// GetWatermark returns the end of the last hour folded into the rollups, or the zero time
+	GetWatermark(ctx context.Context) (time.Time, error)
+	SetWatermark(ctx context.Context, until time.Time) error
+
+	// GetNextClickTime returns the earliest click in [from, to), if any
+	GetNextClickTime(ctx context.Context, from, to time.Time) (time.Time, bool, error)
+
+	// RollupHour replaces the rollups for the hour starting at hour, and for its day,
+	// then moves the watermark to the end of the hour unless clicks before the hour
+	// are still waiting to be rolled up
+	RollupHour(ctx context.Context, hour time.Time) error
+}
+
+// QRBatchRepository stores bulk QR code jobs. Batches are read without their
+// archive, which only GetArchive loads.
+type QRBatchRepository interface {
+	Create(ctx context.Context, batch *domain.QRBatch) error
+	GetByID(ctx context.Context, id uint) (*domain.QRBatch, error)
+	GetArchive(ctx context.Context, id uint) ([]byte, error)
+
+	// ClaimNext moves the oldest pending batch, or one left processing since before
+	// staleBefore, to processing and counts the attempt. When another worker claims
+	// that batch first it tries the next one, and returns nil once there is nothing to do.
+	ClaimNext(ctx context.Context, staleBefore time.Time) (*domain.QRBatch, error)
+	UpdateProgress(ctx context.Context, id uint, progress int) error
+	// Complete stores the archive of the claim that counted attempts. It reports
+	// false when the batch has been claimed again since, so the result is stale.
+	Complete(ctx context.Context, id uint, attempts int, archive []byte, failures int, completedAt, expiresAt time.Time) (bool, error)
+	Fail(ctx context.Context, id uint, message string, at time.Time) error
+
+	// ExpireArchives deletes the archives of completed batches that expired before now
+	ExpireArchives(ctx context.Context, now time.Time) (int64, error)
+}
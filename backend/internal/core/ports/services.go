@@ -2,16 +2,26 @@ package ports
 
 import (
 	"context"
+	"io"
+	"time"
 
 	"url-shortener/internal/core/domain"
 )
 
 type AuthService interface {
 	// Authentication
-	Register(ctx context.Context, req domain.RegisterRequest) (*domain.AuthResponse, error)
-	Login(ctx context.Context, req domain.LoginRequest) (*domain.AuthResponse, error)
-	RefreshToken(ctx context.Context, refreshToken string) (*domain.AuthResponse, error)
-	Logout(ctx context.Context, userID uint) error
+	Register(ctx context.Context, req domain.RegisterRequest, meta domain.SessionMetadata) (*domain.AuthResponse, error)
+	Login(ctx context.Context, req domain.LoginRequest, meta domain.SessionMetadata) (*domain.AuthResponse, error)
+	RefreshToken(ctx context.Context, refreshToken string, meta domain.SessionMetadata) (*domain.AuthResponse, error)
+	Logout(ctx context.Context, userID uint, refreshToken string) error
+
+	// CompleteMFALogin finishes a sign-in that returned MFARequired
+	CompleteMFALogin(ctx context.Context, req domain.MFALoginRequest, meta domain.SessionMetadata) (*domain.AuthResponse, error)
+
+	// Sessions (signed-in devices)
+	ListSessions(ctx context.Context, userID uint) ([]*domain.Session, error)
+	RevokeSession(ctx context.Context, userID uint, sessionID uint) error
+	RevokeAllSessions(ctx context.Context, userID uint) error
 	
 	// Token management
 	ValidateToken(ctx context.Context, token string) (*domain.TokenClaims, error)
@@ -20,6 +30,80 @@ type AuthService interface {
 	GetProfile(ctx context.Context, userID uint) (*domain.UserResponse, error)
 	UpdateProfile(ctx context.Context, userID uint, req domain.UpdateProfileRequest) (*domain.UserResponse, error)
 	ChangePassword(ctx context.Context, userID uint, req domain.ChangePasswordRequest) error
+
+	// Password reset; RequestPasswordReset succeeds whether or not the email is registered
+	RequestPasswordReset(ctx context.Context, req domain.PasswordResetRequest) error
+	ResetPassword(ctx context.Context, req domain.PasswordResetConfirmRequest) error
+
+	// Email verification
+	VerifyEmail(ctx context.Context, token string) (*domain.UserResponse, error)
+	ResendVerificationEmail(ctx context.Context, userID uint) error
+
+	// Two-factor authentication
+	SetupTwoFactor(ctx context.Context, userID uint) (*domain.TwoFactorSetupResponse, error)
+	EnableTwoFactor(ctx context.Context, userID uint, req domain.TwoFactorCodeRequest) (*domain.RecoveryCodesResponse, error)
+	DisableTwoFactor(ctx context.Context, userID uint, req domain.DisableTwoFactorRequest) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uint, req domain.TwoFactorCodeRequest) (*domain.RecoveryCodesResponse, error)
+
+	// Single sign-on; CompleteOIDCLogin may return MFARequired like Login
+	StartOIDCLogin(ctx context.Context) (*domain.OIDCLoginResponse, error)
+	CompleteOIDCLogin(ctx context.Context, req domain.OIDCCallbackRequest, meta domain.SessionMetadata) (*domain.AuthResponse, error)
+}
+
+// AuditService reads the audit log. Events are recorded by the services that
+// perform the actions; access is limited to admins in the HTTP layer.
+type AuditService interface {
+	ListEvents(ctx context.Context, filter domain.AuditFilter, offset, limit int) ([]*domain.AuditEvent, int64, error)
+}
+
+// AdminService lets staff look up and manage other users' accounts. Role checks
+// happen in the HTTP layer; actorID is the staff member making the change.
+type AdminService interface {
+	ListUsers(ctx context.Context, filter domain.UserFilter, offset, limit int) ([]*domain.User, int64, error)
+	GetUser(ctx context.Context, userID uint) (*domain.User, error)
+	GetUserURLs(ctx context.Context, userID uint, offset, limit int) ([]*domain.ShortURL, int64, error)
+
+	// UpdateUser changes a user's role or two-factor requirement
+	UpdateUser(ctx context.Context, actorID, userID uint, req domain.AdminUpdateUserRequest) (*domain.User, error)
+	// DeactivateUser blocks sign-in and ends the user's sessions
+	DeactivateUser(ctx context.Context, actorID, userID uint) (*domain.User, error)
+	ReactivateUser(ctx context.Context, actorID, userID uint) (*domain.User, error)
+}
+
+type APIKeyService interface {
+	// Key management for the signed-in user
+	CreateAPIKey(ctx context.Context, userID uint, req domain.CreateAPIKeyRequest) (*domain.CreatedAPIKey, error)
+	ListAPIKeys(ctx context.Context, userID uint) ([]*domain.APIKey, error)
+	RenameAPIKey(ctx context.Context, id uint, userID uint, req domain.UpdateAPIKeyRequest) (*domain.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id uint, userID uint) error
+
+	// AuthenticateAPIKey resolves a presented key and records the use
+	AuthenticateAPIKey(ctx context.Context, key, clientIP string) (*domain.APIKey, error)
+}
+
+// WorkspaceService manages workspaces, their members and invitations. userID is
+// always the signed-in user, whose role in the workspace decides what they may do.
+type WorkspaceService interface {
+	CreateWorkspace(ctx context.Context, userID uint, req domain.CreateWorkspaceRequest) (*domain.WorkspaceResponse, error)
+	ListWorkspaces(ctx context.Context, userID uint) ([]*domain.WorkspaceResponse, error)
+	GetWorkspace(ctx context.Context, workspaceID, userID uint) (*domain.WorkspaceResponse, error)
+	UpdateWorkspace(ctx context.Context, workspaceID, userID uint, req domain.UpdateWorkspaceRequest) (*domain.WorkspaceResponse, error)
+
+	// Members
+	ListMembers(ctx context.Context, workspaceID, userID uint) ([]*domain.WorkspaceMemberResponse, error)
+	UpdateMemberRole(ctx context.Context, workspaceID, userID, memberID uint, req domain.UpdateMemberRoleRequest) (*domain.WorkspaceMemberResponse, error)
+	// RemoveMember lets owners remove anyone and other members leave
+	RemoveMember(ctx context.Context, workspaceID, userID, memberID uint) error
+
+	// Invitations
+	InviteMember(ctx context.Context, workspaceID, userID uint, req domain.InviteMemberRequest) (*domain.WorkspaceInvitation, error)
+	ListInvitations(ctx context.Context, workspaceID, userID uint) ([]*domain.WorkspaceInvitation, error)
+	RevokeInvitation(ctx context.Context, workspaceID, userID, invitationID uint) error
+	// AcceptInvitation joins the workspace; the invitation must have been sent to the user's email
+	AcceptInvitation(ctx context.Context, userID uint, req domain.AcceptInvitationRequest) (*domain.WorkspaceResponse, error)
+
+	// Links
+	GetWorkspaceURLs(ctx context.Context, workspaceID, userID uint, offset, limit int) ([]*domain.ShortURL, int64, error)
 }
 
 type URLService interface {
@@ -31,10 +115,21 @@ type URLService interface {
 	GetUserURLs(ctx context.Context, userID uint, offset, limit int) ([]*domain.ShortURL, int64, error)
 	UpdateURL(ctx context.Context, id uint, userID uint, req domain.UpdateURLRequest) (*domain.ShortURL, error)
 	DeleteURL(ctx context.Context, id uint, userID uint) error
+	// GetURLHistory lists the destinations the link has had, newest first
+	GetURLHistory(ctx context.Context, id uint, userID uint, offset, limit int) ([]*domain.URLRevision, int64, error)
+	// RollbackURL points the link back at the destination an earlier revision set
+	RollbackURL(ctx context.Context, id uint, userID uint, revisionID uint) (*domain.ShortURL, error)
 	
 	// URL operations
 	RecordClick(ctx context.Context, shortURL *domain.ShortURL, clickData domain.ClickData) error
 	ValidatePassword(ctx context.Context, shortCode, password string) (bool, error)
+	// UnlockURL checks a link password, throttling repeated failures, and returns a
+	// short-lived token that CheckUnlockToken accepts in place of the password
+	UnlockURL(ctx context.Context, shortCode, password, clientIP string) (*domain.LinkUnlock, error)
+	CheckUnlockToken(ctx context.Context, shortURL *domain.ShortURL, token string) bool
+	// ChooseVariant keeps a visitor on their assigned variant while it is live, otherwise
+	// draws one by weight. It returns nil for links without variants.
+	ChooseVariant(ctx context.Context, shortURL *domain.ShortURL, assignedVariantID uint) (*domain.URLVariant, error)
 	
 	// URL utilities
 	GetURLStats(ctx context.Context, id uint, userID uint) (*domain.URLStats, error)
@@ -56,9 +151,10 @@ type AnalyticsService interface {
 	GetGeographicStats(ctx context.Context, shortURLID uint, userID uint) (*domain.GeoStats, error)
 	GetDeviceStats(ctx context.Context, shortURLID uint, userID uint) (*domain.DeviceStats, error)
 	GetReferrerStats(ctx context.Context, shortURLID uint, userID uint) ([]domain.RefererStat, error)
+	GetVariantStats(ctx context.Context, shortURLID uint, userID uint) (*domain.VariantStats, error)
 	
-	// Export functionality
-	ExportAnalytics(ctx context.Context, userID uint, format string, dateRange domain.DateRange) ([]byte, error)
+	// Export functionality; rows are streamed to w as they are read
+	ExportAnalytics(ctx context.Context, userID uint, req domain.ExportRequest, w io.Writer) error
 }
 
 type QRService interface {
@@ -70,13 +166,36 @@ type QRService interface {
 	GetQRCodeFormats(ctx context.Context) []string
 	GetQRCodeSizes(ctx context.Context) []int
 	ValidateQRCodeOptions(ctx context.Context, options domain.QRCodeOptions) error
+
+	// Bulk generation into a ZIP archive, rendered in the background
+	CreateBatch(ctx context.Context, userID uint, req domain.CreateQRBatchRequest) (*domain.QRBatch, error)
+	GetBatch(ctx context.Context, batchID, userID uint) (*domain.QRBatch, error)
+	GetBatchArchive(ctx context.Context, batchID, userID uint) ([]byte, error)
+	QRBatchProcessor
+}
+
+// QRBatchProcessor is the part of QRService that the batch worker drives
+type QRBatchProcessor interface {
+	// ProcessNextBatch renders one waiting batch and reports whether there was one
+	ProcessNextBatch(ctx context.Context) (bool, error)
+	// ExpireBatches deletes archives past their expiry and returns how many it deleted
+	ExpireBatches(ctx context.Context) (int64, error)
+}
+
+// QRBatchWorker renders QR batches in the background and expires old archives
+type QRBatchWorker interface {
+	Start()
+	Close(ctx context.Context) error
 }
 
 type NotificationService interface {
 	// Email notifications
-	SendWelcomeEmail(ctx context.Context, user *domain.User) error
+	// SendWelcomeEmail includes a verification link when verificationToken is set
+	SendWelcomeEmail(ctx context.Context, user *domain.User, verificationToken string) error
 	SendPasswordResetEmail(ctx context.Context, user *domain.User, resetToken string) error
 	SendPasswordChangedNotification(ctx context.Context, user *domain.User) error
+	// SendWorkspaceInvitation emails someone who may not have an account yet
+	SendWorkspaceInvitation(ctx context.Context, email string, inviter *domain.User, workspace *domain.Workspace, token string) error
 	
 	// Analytics notifications
 	SendAnalyticsDigest(ctx context.Context, user *domain.User, digest *domain.AnalyticsDigest) error
@@ -114,10 +233,16 @@ type HealthService interface {
 // Additional service interfaces needed by the service implementations
 
 type JWTService interface {
-	GenerateAccessToken(userID uint, email string) (string, error)
-	GenerateRefreshToken(userID uint) (string, error)
+	// GenerateAccessToken issues an access token that is only honoured while the
+	// session family sessionID is active
+	GenerateAccessToken(userID uint, email, role, sessionID string) (string, error)
+	// GenerateRefreshToken issues a refresh token bound to the session family sessionID
+	GenerateRefreshToken(userID uint, sessionID string) (string, error)
 	ValidateAccessToken(token string) (*domain.TokenClaims, error)
 	ValidateRefreshToken(token string) (*domain.TokenClaims, error)
+	// PublicKeys lists the asymmetric keys that verify tokens; it is empty when
+	// tokens are signed with a shared secret
+	PublicKeys() *domain.JSONWebKeySet
 }
 
 type ConfigService interface {
@@ -125,8 +250,49 @@ type ConfigService interface {
 	GetJWTSecret() string
 	GetDatabaseURL() string
 	GetRedisURL() string
+	// IsTwoFactorRequired reports whether every account must use two-factor authentication
+	IsTwoFactorRequired() bool
+	// GetQRBatchResultTTL is how long a finished QR batch archive can be downloaded
+	GetQRBatchResultTTL() time.Duration
+	// GetSessionTTL is how long a session stays signed in without being refreshed
+	GetSessionTTL() time.Duration
+}
+
+// OIDCProvider runs the authorization code flow with PKCE against an OpenID
+// Connect provider
+type OIDCProvider interface {
+	// AuthCodeURL is where the browser signs in; codeChallenge is the S256 PKCE challenge
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	// Exchange redeems the code and returns the claims of the verified ID token
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*domain.OIDCIdentity, error)
 }
 
 type QRCodeProvider interface {
 	GenerateQRCode(url string, options domain.QRGenerationOptions) ([]byte, error)
+}
+
+// UserAgentParser classifies a User-Agent header into device, browser and OS
+type UserAgentParser interface {
+	Parse(userAgent string) *domain.UserAgentInfo
+}
+
+// ClickQueue decouples click recording from the redirect path
+type ClickQueue interface {
+	Enqueue(ctx context.Context, click *domain.Click) error
+	Stats() domain.ClickQueueStats
+	// Close stops accepting clicks and waits for queued ones to be written
+	Close(ctx context.Context) error
+}
+
+// ClickBatchWriter persists clicks taken off a ClickQueue
+type ClickBatchWriter interface {
+	WriteBatch(ctx context.Context, clicks []*domain.Click) error
+}
+
+// ClickRollupAggregator folds closed hours of raw clicks into the rollup tables
+type ClickRollupAggregator interface {
+	// RunOnce rolls up every closed hour it can and returns how many it processed
+	RunOnce(ctx context.Context) (int, error)
+	Start()
+	Close(ctx context.Context) error
 }
\ No newline at end of file
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+type adminService struct {
+	userRepo    ports.UserRepository
+	urlRepo     ports.URLRepository
+	sessionRepo ports.SessionRepository
+	auditRepo   ports.AuditRepository
+}
+
+func NewAdminService(userRepo ports.UserRepository, urlRepo ports.URLRepository, sessionRepo ports.SessionRepository, auditRepo ports.AuditRepository) ports.AdminService {
+	return &adminService{
+		userRepo:    userRepo,
+		urlRepo:     urlRepo,
+		sessionRepo: sessionRepo,
+		auditRepo:   auditRepo,
+	}
+}
+
+func (s *adminService) ListUsers(ctx context.Context, filter domain.UserFilter, offset, limit int) ([]*domain.User, int64, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, 0, err
+	}
+
+	users, total, err := s.userRepo.Search(ctx, filter, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search users: %w", err)
+	}
+	return users, total, nil
+}
+
+func (s *adminService) GetUser(ctx context.Context, userID uint) (*domain.User, error) {
+	return s.userRepo.GetByID(ctx, userID)
+}
+
+func (s *adminService) GetUserURLs(ctx context.Context, userID uint, offset, limit int) ([]*domain.ShortURL, int64, error) {
+	// Unknown users get a 404 rather than an empty list
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, 0, err
+	}
+
+	urls, total, err := s.urlRepo.GetByUserID(ctx, userID, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get user URLs: %w", err)
+	}
+	return urls, total, nil
+}
+
+func (s *adminService) UpdateUser(ctx context.Context, actorID, userID uint, req domain.AdminUpdateUserRequest) (*domain.User, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Admins cannot demote themselves, which also keeps at least one admin around
+	if req.Role != nil && actorID == userID {
+		return nil, domain.ErrCannotModifySelf
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	before := user.AuditSnapshot()
+
+	if req.Role != nil {
+		user.Role = *req.Role
+	}
+	if req.TwoFactorRequired != nil {
+		user.TwoFactorRequired = *req.TwoFactorRequired
+	}
+	user.UpdatedAt = time.Now()
+
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	if changes := domain.DiffAudit(before, user.AuditSnapshot()); changes != nil {
+		recordAudit(ctx, s.auditRepo, actorID, domain.AuditAdminUserUpdated, domain.AuditTargetUser, user.ID, changes)
+	}
+	return user, nil
+}
+
+func (s *adminService) DeactivateUser(ctx context.Context, actorID, userID uint) (*domain.User, error) {
+	if actorID == userID {
+		return nil, domain.ErrCannotModifySelf
+	}
+
+	user, err := s.setActive(ctx, actorID, userID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Access tokens stop working at once because RequireAuth checks IsActive;
+	// revoking the sessions stops them being refreshed after reactivation
+	if err := s.sessionRepo.RevokeAllByUser(ctx, userID, domain.SessionRevokedDeactivated); err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return user, nil
+}
+
+func (s *adminService) ReactivateUser(ctx context.Context, actorID, userID uint) (*domain.User, error) {
+	if actorID == userID {
+		return nil, domain.ErrCannotModifySelf
+	}
+	return s.setActive(ctx, actorID, userID, true)
+}
+
+func (s *adminService) setActive(ctx context.Context, actorID, userID uint, active bool) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.IsActive == active {
+		return user, nil
+	}
+
+	before := user.AuditSnapshot()
+	user.IsActive = active
+	user.UpdatedAt = time.Now()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	action := domain.AuditAdminUserDeactivated
+	if active {
+		action = domain.AuditAdminUserReactivated
+	}
+	recordAudit(ctx, s.auditRepo, actorID, action, domain.AuditTargetUser, user.ID, domain.DiffAudit(before, user.AuditSnapshot()))
+	return user, nil
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"url-shortener/internal/core/domain"
+)
+
+type AdminServiceTestSuite struct {
+	suite.Suite
+	service      *adminService
+	mockUserRepo *MockUserRepository
+	mockURLRepo  *MockURLRepository
+	mockSessions *MockSessionRepository
+}
+
+func TestAdminServiceSuite(t *testing.T) {
+	suite.Run(t, new(AdminServiceTestSuite))
+}
+
+func (suite *AdminServiceTestSuite) SetupTest() {
+	suite.mockUserRepo = &MockUserRepository{}
+	suite.mockURLRepo = &MockURLRepository{}
+	suite.mockSessions = &MockSessionRepository{}
+	suite.service = &adminService{
+		userRepo:    suite.mockUserRepo,
+		urlRepo:     suite.mockURLRepo,
+		sessionRepo: suite.mockSessions,
+	}
+}
+
+func (suite *AdminServiceTestSuite) TestListUsers() {
+	ctx := context.Background()
+	active := true
+	filter := domain.UserFilter{Query: "  alice ", Role: domain.RoleSupport, Active: &active}
+	expected := domain.UserFilter{Query: "alice", Role: domain.RoleSupport, Active: &active}
+	users := []*domain.User{{ID: 2, Email: "alice@example.com", Role: domain.RoleSupport}}
+	suite.mockUserRepo.On("Search", ctx, expected, 0, 20).Return(users, int64(1), nil)
+
+	result, total, err := suite.service.ListUsers(ctx, filter, 0, 20)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), users, result)
+	assert.Equal(suite.T(), int64(1), total)
+}
+
+func (suite *AdminServiceTestSuite) TestListUsers_InvalidRole() {
+	_, _, err := suite.service.ListUsers(context.Background(), domain.UserFilter{Role: "owner"}, 0, 20)
+
+	assert.Equal(suite.T(), domain.ErrInvalidRole, err)
+	suite.mockUserRepo.AssertNotCalled(suite.T(), "Search", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *AdminServiceTestSuite) TestGetUserURLs() {
+	ctx := context.Background()
+	urls := []*domain.ShortURL{{ID: 5, UserID: 2, ShortCode: "abc123"}}
+	suite.mockUserRepo.On("GetByID", ctx, uint(2)).Return(&domain.User{ID: 2}, nil)
+	suite.mockUserRepo.On("GetByID", ctx, uint(3)).Return((*domain.User)(nil), domain.ErrUserNotFound)
+	suite.mockURLRepo.On("GetByUserID", ctx, uint(2), 0, 20).Return(urls, int64(1), nil)
+
+	result, total, err := suite.service.GetUserURLs(ctx, 2, 0, 20)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), urls, result)
+	assert.Equal(suite.T(), int64(1), total)
+
+	_, _, err = suite.service.GetUserURLs(ctx, 3, 0, 20)
+	assert.Equal(suite.T(), domain.ErrUserNotFound, err)
+}
+
+func (suite *AdminServiceTestSuite) TestUpdateUser() {
+	ctx := context.Background()
+	user := &domain.User{ID: 2, Role: domain.RoleUser}
+	role := domain.RoleSupport
+	required := true
+	suite.mockUserRepo.On("GetByID", ctx, uint(2)).Return(user, nil)
+	suite.mockUserRepo.On("Update", ctx, user).Return(nil)
+
+	updated, err := suite.service.UpdateUser(ctx, 1, 2, domain.AdminUpdateUserRequest{
+		Role:              &role,
+		TwoFactorRequired: &required,
+	})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), domain.RoleSupport, updated.Role)
+	assert.True(suite.T(), updated.TwoFactorRequired)
+}
+
+func (suite *AdminServiceTestSuite) TestUpdateUser_Rejected() {
+	ctx := context.Background()
+	role := domain.RoleUser
+	invalid := "owner"
+
+	// Admins cannot demote themselves
+	_, err := suite.service.UpdateUser(ctx, 1, 1, domain.AdminUpdateUserRequest{Role: &role})
+	assert.Equal(suite.T(), domain.ErrCannotModifySelf, err)
+
+	_, err = suite.service.UpdateUser(ctx, 1, 2, domain.AdminUpdateUserRequest{Role: &invalid})
+	assert.Equal(suite.T(), domain.ErrInvalidRole, err)
+
+	_, err = suite.service.UpdateUser(ctx, 1, 2, domain.AdminUpdateUserRequest{})
+	assert.True(suite.T(), errors.Is(err, domain.ErrInvalidInput))
+
+	suite.mockUserRepo.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+}
+
+func (suite *AdminServiceTestSuite) TestDeactivateUser() {
+	ctx := context.Background()
+	user := &domain.User{ID: 2, IsActive: true}
+	suite.mockUserRepo.On("GetByID", ctx, uint(2)).Return(user, nil)
+	suite.mockUserRepo.On("Update", ctx, user).Return(nil)
+	suite.mockSessions.On("RevokeAllByUser", ctx, uint(2), domain.SessionRevokedDeactivated).Return(nil)
+
+	updated, err := suite.service.DeactivateUser(ctx, 1, 2)
+
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), updated.IsActive)
+	suite.mockSessions.AssertExpectations(suite.T())
+
+	// Admins cannot lock themselves out
+	_, err = suite.service.DeactivateUser(ctx, 1, 1)
+	assert.Equal(suite.T(), domain.ErrCannotModifySelf, err)
+}
+
+func (suite *AdminServiceTestSuite) TestReactivateUser() {
+	ctx := context.Background()
+	user := &domain.User{ID: 2, IsActive: false}
+	suite.mockUserRepo.On("GetByID", ctx, uint(2)).Return(user, nil)
+	suite.mockUserRepo.On("Update", ctx, user).Return(nil)
+
+	updated, err := suite.service.ReactivateUser(ctx, 1, 2)
+
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), updated.IsActive)
+	suite.mockSessions.AssertNotCalled(suite.T(), "RevokeAllByUser", mock.Anything, mock.Anything, mock.Anything)
+}
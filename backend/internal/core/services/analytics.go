@@ -3,18 +3,27 @@ package services
 import (
 	"context"
 	"fmt"
+	"io"
 	"time"
 
 	"url-shortener/internal/core/domain"
 	"url-shortener/internal/core/ports"
 )
 
+const (
+	exportURLPageSize   = 100
+	exportClickPageSize = 1000
+)
+
 type analyticsService struct {
 	urlRepo     ports.URLRepository
 	clickRepo   ports.ClickRepository
 	userRepo    ports.UserRepository
 	cacheRepo   ports.CacheService
 	configRepo  ports.ConfigService
+
+	// workspaceRepo decides who may see workspace links
+	workspaceRepo ports.WorkspaceRepository
 }
 
 func NewAnalyticsService(
@@ -23,13 +32,15 @@ func NewAnalyticsService(
 	userRepo ports.UserRepository,
 	cacheRepo ports.CacheService,
 	configRepo ports.ConfigService,
+	workspaceRepo ports.WorkspaceRepository,
 ) ports.AnalyticsService {
 	return &analyticsService{
-		urlRepo:    urlRepo,
-		clickRepo:  clickRepo,
-		userRepo:   userRepo,
-		cacheRepo:  cacheRepo,
-		configRepo: configRepo,
+		urlRepo:       urlRepo,
+		clickRepo:     clickRepo,
+		userRepo:      userRepo,
+		cacheRepo:     cacheRepo,
+		configRepo:    configRepo,
+		workspaceRepo: workspaceRepo,
 	}
 }
 
@@ -70,13 +81,13 @@ func (s *analyticsService) GetDashboardStats(ctx context.Context, userID uint) (
 }
 
 func (s *analyticsService) GetURLAnalytics(ctx context.Context, shortURLID uint, userID uint) (*domain.URLAnalytics, error) {
-	// Verify URL ownership
+	// Verify URL access
 	shortURL, err := s.urlRepo.GetByID(ctx, shortURLID)
 	if err != nil {
 		return nil, err
 	}
-	if shortURL.UserID != userID {
-		return nil, domain.ErrUnauthorized
+	if err := authorizeURL(ctx, s.workspaceRepo, shortURL, userID, domain.PermissionViewLinks); err != nil {
+		return nil, err
 	}
 
 	analytics := &domain.URLAnalytics{
@@ -167,39 +178,39 @@ func (s *analyticsService) GetTopPerformingURLs(ctx context.Context, userID uint
 }
 
 func (s *analyticsService) GetClickTimeline(ctx context.Context, shortURLID uint, userID uint, period string) (*domain.TimelineStats, error) {
-	// Verify URL ownership
+	// Verify URL access
 	shortURL, err := s.urlRepo.GetByID(ctx, shortURLID)
 	if err != nil {
 		return nil, err
 	}
-	if shortURL.UserID != userID {
-		return nil, domain.ErrUnauthorized
+	if err := authorizeURL(ctx, s.workspaceRepo, shortURL, userID, domain.PermissionViewLinks); err != nil {
+		return nil, err
 	}
 
 	return s.clickRepo.GetTimelineStats(ctx, shortURLID, period)
 }
 
 func (s *analyticsService) GetGeographicStats(ctx context.Context, shortURLID uint, userID uint) (*domain.GeoStats, error) {
-	// Verify URL ownership
+	// Verify URL access
 	shortURL, err := s.urlRepo.GetByID(ctx, shortURLID)
 	if err != nil {
 		return nil, err
 	}
-	if shortURL.UserID != userID {
-		return nil, domain.ErrUnauthorized
+	if err := authorizeURL(ctx, s.workspaceRepo, shortURL, userID, domain.PermissionViewLinks); err != nil {
+		return nil, err
 	}
 
 	return s.clickRepo.GetGeoStats(ctx, shortURLID)
 }
 
 func (s *analyticsService) GetDeviceStats(ctx context.Context, shortURLID uint, userID uint) (*domain.DeviceStats, error) {
-	// Verify URL ownership
+	// Verify URL access
 	shortURL, err := s.urlRepo.GetByID(ctx, shortURLID)
 	if err != nil {
 		return nil, err
 	}
-	if shortURL.UserID != userID {
-		return nil, domain.ErrUnauthorized
+	if err := authorizeURL(ctx, s.workspaceRepo, shortURL, userID, domain.PermissionViewLinks); err != nil {
+		return nil, err
 	}
 
 	// Get click stats which includes device information
@@ -215,59 +226,189 @@ func (s *analyticsService) GetDeviceStats(ctx context.Context, shortURLID uint,
 }
 
 func (s *analyticsService) GetReferrerStats(ctx context.Context, shortURLID uint, userID uint) ([]domain.RefererStat, error) {
-	// Verify URL ownership
+	// Verify URL access
 	shortURL, err := s.urlRepo.GetByID(ctx, shortURLID)
 	if err != nil {
 		return nil, err
 	}
-	if shortURL.UserID != userID {
-		return nil, domain.ErrUnauthorized
+	if err := authorizeURL(ctx, s.workspaceRepo, shortURL, userID, domain.PermissionViewLinks); err != nil {
+		return nil, err
 	}
 
 	return s.clickRepo.GetTopReferers(ctx, shortURLID, 20)
 }
 
-func (s *analyticsService) ExportAnalytics(ctx context.Context, userID uint, format string, dateRange domain.DateRange) ([]byte, error) {
-	// Get detailed click data for the date range
-	urls, _, err := s.urlRepo.GetByUserID(ctx, userID, 0, 1000) // Get all URLs
+// GetVariantStats compares the traffic each A/B variant was configured for with
+// the traffic it actually received
+func (s *analyticsService) GetVariantStats(ctx context.Context, shortURLID uint, userID uint) (*domain.VariantStats, error) {
+	// Verify URL access
+	shortURL, err := s.urlRepo.GetByID(ctx, shortURLID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user URLs: %w", err)
+		return nil, err
+	}
+	if err := authorizeURL(ctx, s.workspaceRepo, shortURL, userID, domain.PermissionViewLinks); err != nil {
+		return nil, err
 	}
 
-	var exportData []map[string]interface{}
-	for _, url := range urls {
-		clicks, err := s.clickRepo.GetClicksByDateRange(ctx, url.ID, dateRange.StartDate, dateRange.EndDate)
-		if err != nil {
+	variants, err := s.urlRepo.GetVariants(ctx, shortURLID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get variants: %w", err)
+	}
+	counts, err := s.clickRepo.GetVariantClickCounts(ctx, shortURLID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get variant clicks: %w", err)
+	}
+
+	byVariant := make(map[uint]domain.VariantClickCount, len(counts))
+	stats := &domain.VariantStats{
+		ShortURLID: shortURL.ID,
+		ShortCode:  shortURL.ShortCode,
+		Variants:   []domain.VariantStat{},
+	}
+	for _, c := range counts {
+		byVariant[c.VariantID] = c
+		stats.TotalClicks += c.Clicks
+	}
+
+	totalWeight := shortURL.TotalVariantWeight()
+	for _, v := range variants {
+		active := !v.DeletedAt.Valid
+		count := byVariant[v.ID]
+		if !active && count.Clicks == 0 {
 			continue
 		}
 
+		stat := domain.VariantStat{
+			VariantID:      v.ID,
+			Name:           v.Name,
+			DestinationURL: v.DestinationURL,
+			Weight:         v.Weight,
+			Clicks:         count.Clicks,
+			UniqueClicks:   count.UniqueClicks,
+			Active:         active,
+		}
+		if active && totalWeight > 0 {
+			stat.WeightShare = float64(v.Weight) / float64(totalWeight) * 100
+		}
+		if stats.TotalClicks > 0 {
+			stat.ClickShare = float64(count.Clicks) / float64(stats.TotalClicks) * 100
+		}
+		stats.Variants = append(stats.Variants, stat)
+	}
+
+	return stats, nil
+}
+
+// ExportAnalytics streams every click in the date range, for the requested URLs or
+// all of the user's URLs, to w. URLs and clicks are read a page at a time so the
+// export never holds more than one page in memory.
+func (s *analyticsService) ExportAnalytics(ctx context.Context, userID uint, req domain.ExportRequest, w io.Writer) error {
+	from, to, err := exportWindow(req.DateRange)
+	if err != nil {
+		return err
+	}
+
+	// Resolve the URL filter before writing anything, so a bad ID is still reported
+	// as an error rather than a truncated file
+	var urls []*domain.ShortURL
+	if len(req.ShortURLIDs) > 0 {
+		urls, err = s.getOwnedURLs(ctx, userID, req.ShortURLIDs)
+		if err != nil {
+			return err
+		}
+	}
+
+	encoder, err := newExportEncoder(req.Format, w)
+	if err != nil {
+		return err
+	}
+
+	if urls != nil {
+		for _, url := range urls {
+			if err := s.exportURLClicks(ctx, encoder, url, from, to); err != nil {
+				return err
+			}
+		}
+		return encoder.Close()
+	}
+
+	for offset := 0; ; offset += exportURLPageSize {
+		page, _, err := s.urlRepo.GetByUserID(ctx, userID, offset, exportURLPageSize)
+		if err != nil {
+			return fmt.Errorf("failed to get user URLs: %w", err)
+		}
+
+		for _, url := range page {
+			if err := s.exportURLClicks(ctx, encoder, url, from, to); err != nil {
+				return err
+			}
+		}
+
+		if len(page) < exportURLPageSize {
+			break
+		}
+	}
+
+	return encoder.Close()
+}
+
+func (s *analyticsService) exportURLClicks(ctx context.Context, encoder exportEncoder, url *domain.ShortURL, from, to time.Time) error {
+	var afterID uint
+	for {
+		clicks, err := s.clickRepo.GetClicksAfter(ctx, url.ID, from, to, afterID, exportClickPageSize)
+		if err != nil {
+			return fmt.Errorf("failed to get clicks for %s: %w", url.ShortCode, err)
+		}
+
 		for _, click := range clicks {
-			exportData = append(exportData, map[string]interface{}{
-				"short_code":   url.ShortCode,
-				"original_url": url.OriginalURL,
-				"title":        url.Title,
-				"clicked_at":   click.ClickedAt,
-				"ip_address":   click.IPAddress,
-				"country":      click.Country,
-				"region":       click.Region,
-				"city":         click.City,
-				"device":       click.Device,
-				"browser":      click.Browser,
-				"os":           click.OS,
-				"referer":      click.Referer,
-			})
+			if err := encoder.Encode(domain.NewClickExportRow(url, click)); err != nil {
+				return fmt.Errorf("failed to write export: %w", err)
+			}
+		}
+		if err := encoder.Flush(); err != nil {
+			return fmt.Errorf("failed to write export: %w", err)
+		}
+
+		if len(clicks) < exportClickPageSize {
+			return nil
+		}
+		afterID = clicks[len(clicks)-1].ID
+	}
+}
+
+// getOwnedURLs loads each distinct URL, failing if the user may not see any of them
+func (s *analyticsService) getOwnedURLs(ctx context.Context, userID uint, ids []uint) ([]*domain.ShortURL, error) {
+	seen := make(map[uint]bool, len(ids))
+	urls := make([]*domain.ShortURL, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
 		}
+		seen[id] = true
+
+		url, err := s.urlRepo.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := authorizeURL(ctx, s.workspaceRepo, url, userID, domain.PermissionViewLinks); err != nil {
+			return nil, err
+		}
+		urls = append(urls, url)
 	}
+	return urls, nil
+}
 
-	// Format the data based on the requested format
-	switch format {
-	case "json":
-		return s.exportToJSON(exportData)
-	case "csv":
-		return s.exportToCSV(exportData)
-	default:
-		return nil, fmt.Errorf("unsupported export format: %s", format)
+// exportWindow converts an inclusive range of dates to a half-open UTC time range
+func exportWindow(dateRange domain.DateRange) (time.Time, time.Time, error) {
+	from, err := time.Parse("2006-01-02", dateRange.StartDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, domain.ErrInvalidDateRange
+	}
+	to, err := time.Parse("2006-01-02", dateRange.EndDate)
+	if err != nil || to.Before(from) {
+		return time.Time{}, time.Time{}, domain.ErrInvalidDateRange
 	}
+	return from, to.AddDate(0, 0, 1), nil
 }
 
 func (s *analyticsService) getRecentUserActivity(ctx context.Context, userID uint, limit int) ([]domain.ActivityItem, error) {
@@ -359,15 +500,3 @@ func (s *analyticsService) calculateClickRate(totalClicks, uniqueClicks int64) f
 	}
 	return float64(uniqueClicks) / float64(totalClicks) * 100
 }
-
-func (s *analyticsService) exportToJSON(data []map[string]interface{}) ([]byte, error) {
-	// Simple JSON marshaling
-	// In a real implementation, you'd use proper JSON encoding
-	return []byte("{}"), nil // Placeholder
-}
-
-func (s *analyticsService) exportToCSV(data []map[string]interface{}) ([]byte, error) {
-	// Simple CSV generation
-	// In a real implementation, you'd use proper CSV encoding
-	return []byte(""), nil // Placeholder
-}
\ No newline at end of file
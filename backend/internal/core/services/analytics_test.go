@@ -0,0 +1,267 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"url-shortener/internal/core/domain"
+)
+
+type AnalyticsExportTestSuite struct {
+	suite.Suite
+	service       *analyticsService
+	mockURLRepo   *MockURLRepository
+	mockClickRepo *MockClickRepository
+	ctx           context.Context
+	url           *domain.ShortURL
+	from          time.Time
+	to            time.Time
+}
+
+func (suite *AnalyticsExportTestSuite) SetupTest() {
+	suite.mockURLRepo = &MockURLRepository{}
+	suite.mockClickRepo = &MockClickRepository{}
+	suite.service = NewAnalyticsService(suite.mockURLRepo, suite.mockClickRepo, nil, nil, nil, nil).(*analyticsService)
+	suite.ctx = context.Background()
+	suite.url = &domain.ShortURL{ID: 1, ShortCode: "abc123", OriginalURL: "https://example.com/a,b", Title: `Say "hi"`, UserID: 7}
+	suite.from = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	suite.to = time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)
+}
+
+func (suite *AnalyticsExportTestSuite) request(format string, ids ...uint) domain.ExportRequest {
+	return domain.ExportRequest{
+		Format:      format,
+		DateRange:   domain.DateRange{StartDate: "2024-03-01", EndDate: "2024-03-02"},
+		ShortURLIDs: ids,
+	}
+}
+
+func (suite *AnalyticsExportTestSuite) clicks(firstID, n int) []*domain.Click {
+	clicks := make([]*domain.Click, n)
+	for i := range clicks {
+		clicks[i] = &domain.Click{
+			ID:         uint(firstID + i),
+			ShortURLID: suite.url.ID,
+			IPAddress:  "81.2.69.142",
+			Country:    "GB",
+			City:       "London",
+			Device:     domain.DeviceTypeDesktop,
+			Browser:    "Firefox",
+			OS:         "Linux",
+			ClickedAt:  time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+		}
+	}
+	return clicks
+}
+
+func (suite *AnalyticsExportTestSuite) TestExportAnalytics_CSV() {
+	suite.mockURLRepo.On("GetByUserID", suite.ctx, uint(7), 0, exportURLPageSize).Return([]*domain.ShortURL{suite.url}, int64(1), nil)
+	suite.mockClickRepo.On("GetClicksAfter", suite.ctx, uint(1), suite.from, suite.to, uint(0), exportClickPageSize).Return(suite.clicks(1, 2), nil)
+
+	var buf bytes.Buffer
+	err := suite.service.ExportAnalytics(suite.ctx, 7, suite.request(domain.ExportFormatCSV), &buf)
+
+	suite.Require().NoError(err)
+	records, err := csv.NewReader(&buf).ReadAll()
+	suite.Require().NoError(err)
+	suite.Require().Len(records, 3)
+	suite.Equal(domain.ExportColumns, records[0])
+	suite.Equal([]string{
+		"abc123", "https://example.com/a,b", `Say "hi"`, "2024-03-01T12:30:00Z", "81.2.69.142",
+		"GB", "", "London", "desktop", "Firefox", "", "Linux", "", "",
+	}, records[1])
+	suite.mockClickRepo.AssertExpectations(suite.T())
+}
+
+func (suite *AnalyticsExportTestSuite) TestExportAnalytics_CSVEscapesFormulas() {
+	var buf bytes.Buffer
+	encoder, err := newExportEncoder(domain.ExportFormatCSV, &buf)
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(encoder.Encode(&domain.ClickExportRow{
+		ShortCode: "abc123",
+		Title:     `=HYPERLINK("https://evil.example","click")`,
+		City:      "+London",
+		Device:    "-1",
+		Referer:   "@SUM(A1)",
+		Browser:   "Fire=fox",
+	}))
+	suite.Require().NoError(encoder.Close())
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	suite.Require().NoError(err)
+	suite.Require().Len(records, 2)
+	row := records[1]
+	suite.Equal("abc123", row[0])
+	suite.Equal(`'=HYPERLINK("https://evil.example","click")`, row[2])
+	suite.Equal("'+London", row[7])
+	suite.Equal("'-1", row[8])
+	suite.Equal("Fire=fox", row[9])
+	suite.Equal("'@SUM(A1)", row[13])
+}
+
+func (suite *AnalyticsExportTestSuite) TestExportAnalytics_JSONArray() {
+	suite.mockURLRepo.On("GetByUserID", suite.ctx, uint(7), 0, exportURLPageSize).Return([]*domain.ShortURL{suite.url}, int64(1), nil)
+	suite.mockClickRepo.On("GetClicksAfter", suite.ctx, uint(1), suite.from, suite.to, uint(0), exportClickPageSize).Return(suite.clicks(1, 2), nil)
+
+	var buf bytes.Buffer
+	err := suite.service.ExportAnalytics(suite.ctx, 7, suite.request(domain.ExportFormatJSON), &buf)
+
+	suite.Require().NoError(err)
+	var rows []domain.ClickExportRow
+	suite.Require().NoError(json.Unmarshal(buf.Bytes(), &rows))
+	suite.Len(rows, 2)
+	suite.Equal("abc123", rows[0].ShortCode)
+	suite.Equal("2024-03-01T12:30:00Z", rows[1].ClickedAt)
+}
+
+func (suite *AnalyticsExportTestSuite) TestExportAnalytics_JSONArrayEmpty() {
+	suite.mockURLRepo.On("GetByUserID", suite.ctx, uint(7), 0, exportURLPageSize).Return([]*domain.ShortURL{}, int64(0), nil)
+
+	var buf bytes.Buffer
+	err := suite.service.ExportAnalytics(suite.ctx, 7, suite.request(domain.ExportFormatJSON), &buf)
+
+	suite.Require().NoError(err)
+	suite.JSONEq(`[]`, buf.String())
+}
+
+func (suite *AnalyticsExportTestSuite) TestExportAnalytics_JSONLinesPagesThroughClicks() {
+	suite.mockURLRepo.On("GetByUserID", suite.ctx, uint(7), 0, exportURLPageSize).Return([]*domain.ShortURL{suite.url}, int64(1), nil)
+	suite.mockClickRepo.On("GetClicksAfter", suite.ctx, uint(1), suite.from, suite.to, uint(0), exportClickPageSize).Return(suite.clicks(1, exportClickPageSize), nil)
+	suite.mockClickRepo.On("GetClicksAfter", suite.ctx, uint(1), suite.from, suite.to, uint(exportClickPageSize), exportClickPageSize).Return(suite.clicks(exportClickPageSize+1, 3), nil)
+
+	var buf bytes.Buffer
+	err := suite.service.ExportAnalytics(suite.ctx, 7, suite.request(domain.ExportFormatJSONLines), &buf)
+
+	suite.Require().NoError(err)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	suite.Len(lines, exportClickPageSize+3)
+	var row domain.ClickExportRow
+	suite.NoError(json.Unmarshal([]byte(lines[len(lines)-1]), &row))
+	suite.Equal("London", row.City)
+	suite.mockClickRepo.AssertExpectations(suite.T())
+}
+
+func (suite *AnalyticsExportTestSuite) TestExportAnalytics_PagesThroughURLs() {
+	fullPage := make([]*domain.ShortURL, exportURLPageSize)
+	for i := range fullPage {
+		fullPage[i] = &domain.ShortURL{ID: uint(100 + i), ShortCode: "page1", UserID: 7}
+	}
+	suite.mockURLRepo.On("GetByUserID", suite.ctx, uint(7), 0, exportURLPageSize).Return(fullPage, int64(exportURLPageSize+1), nil)
+	suite.mockURLRepo.On("GetByUserID", suite.ctx, uint(7), exportURLPageSize, exportURLPageSize).Return([]*domain.ShortURL{suite.url}, int64(exportURLPageSize+1), nil)
+	suite.mockClickRepo.On("GetClicksAfter", suite.ctx, mock.Anything, suite.from, suite.to, uint(0), exportClickPageSize).Return([]*domain.Click{}, nil)
+
+	var buf bytes.Buffer
+	err := suite.service.ExportAnalytics(suite.ctx, 7, suite.request(domain.ExportFormatCSV), &buf)
+
+	suite.Require().NoError(err)
+	suite.mockClickRepo.AssertNumberOfCalls(suite.T(), "GetClicksAfter", exportURLPageSize+1)
+	suite.mockClickRepo.AssertCalled(suite.T(), "GetClicksAfter", suite.ctx, uint(1), suite.from, suite.to, uint(0), exportClickPageSize)
+}
+
+func (suite *AnalyticsExportTestSuite) TestExportAnalytics_URLFilter() {
+	suite.mockURLRepo.On("GetByID", suite.ctx, uint(1)).Return(suite.url, nil).Once()
+	suite.mockClickRepo.On("GetClicksAfter", suite.ctx, uint(1), suite.from, suite.to, uint(0), exportClickPageSize).Return(suite.clicks(1, 1), nil)
+
+	var buf bytes.Buffer
+	err := suite.service.ExportAnalytics(suite.ctx, 7, suite.request(domain.ExportFormatJSONLines, 1, 1), &buf)
+
+	suite.Require().NoError(err)
+	suite.Equal(1, strings.Count(buf.String(), "\n"))
+	suite.mockURLRepo.AssertNotCalled(suite.T(), "GetByUserID", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	suite.mockURLRepo.AssertExpectations(suite.T())
+}
+
+func (suite *AnalyticsExportTestSuite) TestExportAnalytics_URLFilterNotOwned() {
+	other := &domain.ShortURL{ID: 2, UserID: 8}
+	suite.mockURLRepo.On("GetByID", suite.ctx, uint(1)).Return(suite.url, nil)
+	suite.mockURLRepo.On("GetByID", suite.ctx, uint(2)).Return(other, nil)
+
+	var buf bytes.Buffer
+	err := suite.service.ExportAnalytics(suite.ctx, 7, suite.request(domain.ExportFormatCSV, 1, 2), &buf)
+
+	suite.Equal(domain.ErrUnauthorized, err)
+	suite.Zero(buf.Len())
+	suite.mockClickRepo.AssertNotCalled(suite.T(), "GetClicksAfter", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *AnalyticsExportTestSuite) TestExportAnalytics_InvalidRequest() {
+	var buf bytes.Buffer
+
+	err := suite.service.ExportAnalytics(suite.ctx, 7, suite.request("xml"), &buf)
+	suite.Equal(domain.ErrInvalidExportFormat, err)
+
+	req := suite.request(domain.ExportFormatCSV)
+	req.DateRange = domain.DateRange{StartDate: "2024-03-05", EndDate: "2024-03-01"}
+	err = suite.service.ExportAnalytics(suite.ctx, 7, req, &buf)
+	suite.Equal(domain.ErrInvalidDateRange, err)
+
+	req.DateRange = domain.DateRange{StartDate: "March", EndDate: "2024-03-01"}
+	err = suite.service.ExportAnalytics(suite.ctx, 7, req, &buf)
+	suite.Equal(domain.ErrInvalidDateRange, err)
+
+	suite.Zero(buf.Len())
+}
+
+func TestAnalyticsExportTestSuite(t *testing.T) {
+	suite.Run(t, new(AnalyticsExportTestSuite))
+}
+
+func TestGetVariantStats(t *testing.T) {
+	ctx := context.Background()
+	urlRepo := &MockURLRepository{}
+	clickRepo := &MockClickRepository{}
+	service := NewAnalyticsService(urlRepo, clickRepo, nil, nil, nil, nil)
+
+	shortURL := &domain.ShortURL{
+		ID:        1,
+		UserID:    7,
+		ShortCode: "abc123",
+		Variants: []domain.URLVariant{
+			{ID: 1, Name: "A", DestinationURL: "https://example.com/a", Weight: 3},
+			{ID: 3, Name: "C", DestinationURL: "https://example.com/c", Weight: 1},
+		},
+	}
+	removed := &domain.URLVariant{ID: 2, Name: "B", DestinationURL: "https://example.com/b", Weight: 1}
+	removed.DeletedAt.Valid = true
+	unused := &domain.URLVariant{ID: 4, Name: "D", Weight: 1}
+	unused.DeletedAt.Valid = true
+
+	urlRepo.On("GetByID", ctx, uint(1)).Return(shortURL, nil)
+	urlRepo.On("GetVariants", ctx, uint(1)).Return([]*domain.URLVariant{
+		&shortURL.Variants[0], removed, &shortURL.Variants[1], unused,
+	}, nil)
+	clickRepo.On("GetVariantClickCounts", ctx, uint(1)).Return([]domain.VariantClickCount{
+		{VariantID: 1, Clicks: 60, UniqueClicks: 40},
+		{VariantID: 2, Clicks: 20, UniqueClicks: 20},
+		{VariantID: 3, Clicks: 20, UniqueClicks: 10},
+	}, nil)
+
+	stats, err := service.GetVariantStats(ctx, 1, 7)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), stats.TotalClicks)
+	require.Len(t, stats.Variants, 3)
+
+	assert.Equal(t, "A", stats.Variants[0].Name)
+	assert.InDelta(t, 75, stats.Variants[0].WeightShare, 0.001)
+	assert.InDelta(t, 60, stats.Variants[0].ClickShare, 0.001)
+	assert.True(t, stats.Variants[0].Active)
+
+	// Removed variants keep their clicks but no longer get traffic
+	assert.Equal(t, "B", stats.Variants[1].Name)
+	assert.False(t, stats.Variants[1].Active)
+	assert.Zero(t, stats.Variants[1].WeightShare)
+	assert.InDelta(t, 20, stats.Variants[1].ClickShare, 0.001)
+
+	_, err = service.GetVariantStats(ctx, 1, 8)
+	assert.Equal(t, domain.ErrUnauthorized, err)
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"url-shortener/internal/core/domain"
+)
+
+type APIKeyServiceTestSuite struct {
+	suite.Suite
+	service  *apiKeyService
+	mockRepo *MockAPIKeyRepository
+}
+
+func TestAPIKeyServiceSuite(t *testing.T) {
+	suite.Run(t, new(APIKeyServiceTestSuite))
+}
+
+func (suite *APIKeyServiceTestSuite) SetupTest() {
+	suite.mockRepo = &MockAPIKeyRepository{}
+	suite.service = &apiKeyService{apiKeyRepo: suite.mockRepo}
+}
+
+func (suite *APIKeyServiceTestSuite) TestCreateAPIKey() {
+	ctx := context.Background()
+	suite.mockRepo.On("ListActiveByUser", ctx, uint(1)).Return([]*domain.APIKey{}, nil)
+	suite.mockRepo.On("Create", ctx, mock.AnythingOfType("*domain.APIKey")).Return(nil)
+
+	created, err := suite.service.CreateAPIKey(ctx, 1, domain.CreateAPIKeyRequest{
+		Name:   "  Release pipeline ",
+		Scopes: []string{"urls:write", "URLS:READ", "urls:write"},
+	})
+
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), strings.HasPrefix(created.Key, domain.APIKeyPrefix))
+	assert.Len(suite.T(), created.Key, 44)
+	assert.Equal(suite.T(), created.Key[:12], created.Prefix)
+	assert.Equal(suite.T(), "Release pipeline", created.Name)
+	assert.Equal(suite.T(), domain.APIScopes{"urls:read", "urls:write"}, created.Scopes)
+
+	// Only the hash is persisted
+	stored := suite.mockRepo.Calls[1].Arguments.Get(1).(*domain.APIKey)
+	assert.Equal(suite.T(), hashAPIKey(created.Key), stored.KeyHash)
+	assert.NotContains(suite.T(), stored.KeyHash, created.Key[len(domain.APIKeyPrefix):])
+}
+
+func (suite *APIKeyServiceTestSuite) TestCreateAPIKey_Rejected() {
+	ctx := context.Background()
+
+	_, err := suite.service.CreateAPIKey(ctx, 1, domain.CreateAPIKeyRequest{Name: "CI", Scopes: []string{"admin"}})
+	assert.True(suite.T(), errors.Is(err, domain.ErrInvalidScope))
+
+	_, err = suite.service.CreateAPIKey(ctx, 1, domain.CreateAPIKeyRequest{Name: "CI"})
+	assert.True(suite.T(), errors.Is(err, domain.ErrInvalidScope))
+
+	full := make([]*domain.APIKey, domain.MaxAPIKeysPerUser)
+	suite.mockRepo.On("ListActiveByUser", ctx, uint(1)).Return(full, nil)
+	_, err = suite.service.CreateAPIKey(ctx, 1, domain.CreateAPIKeyRequest{Name: "CI", Scopes: []string{"urls:read"}})
+	assert.Equal(suite.T(), domain.ErrAPIKeyLimitReached, err)
+	suite.mockRepo.AssertNotCalled(suite.T(), "Create", mock.Anything, mock.Anything)
+}
+
+func (suite *APIKeyServiceTestSuite) TestAuthenticateAPIKey() {
+	ctx := context.Background()
+	key := "usk_valid-key-material"
+	apiKey := &domain.APIKey{ID: 7, UserID: 1, Scopes: domain.APIScopes{"urls:read"}}
+	suite.mockRepo.On("GetByHash", ctx, hashAPIKey(key)).Return(apiKey, nil)
+	suite.mockRepo.On("TouchLastUsed", ctx, uint(7), mock.AnythingOfType("time.Time"), "203.0.113.9").Return(nil).Once()
+
+	authenticated, err := suite.service.AuthenticateAPIKey(ctx, key, "203.0.113.9")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), uint(7), authenticated.ID)
+	assert.NotNil(suite.T(), authenticated.LastUsedAt)
+
+	// A second use straight away from the same address is not written again
+	_, err = suite.service.AuthenticateAPIKey(ctx, key, "203.0.113.9")
+	assert.NoError(suite.T(), err)
+	suite.mockRepo.AssertNumberOfCalls(suite.T(), "TouchLastUsed", 1)
+}
+
+func (suite *APIKeyServiceTestSuite) TestAuthenticateAPIKey_Invalid() {
+	ctx := context.Background()
+	revokedAt := time.Now()
+	suite.mockRepo.On("GetByHash", ctx, hashAPIKey("usk_revoked")).Return(&domain.APIKey{ID: 8, RevokedAt: &revokedAt}, nil)
+	suite.mockRepo.On("GetByHash", ctx, hashAPIKey("usk_unknown")).Return(nil, domain.ErrAPIKeyNotFound)
+
+	for _, key := range []string{"usk_revoked", "usk_unknown", "eyJhbGciOiJIUzI1NiJ9"} {
+		_, err := suite.service.AuthenticateAPIKey(ctx, key, "203.0.113.9")
+		assert.Equal(suite.T(), domain.ErrInvalidAPIKey, err, key)
+	}
+	suite.mockRepo.AssertNotCalled(suite.T(), "TouchLastUsed", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *APIKeyServiceTestSuite) TestRevokeAPIKey() {
+	ctx := context.Background()
+	suite.mockRepo.On("GetByID", ctx, uint(7)).Return(&domain.APIKey{ID: 7, UserID: 1}, nil)
+	suite.mockRepo.On("Update", ctx, mock.MatchedBy(func(k *domain.APIKey) bool { return k.IsRevoked() })).Return(nil)
+
+	// Another user's key looks like a missing one
+	assert.Equal(suite.T(), domain.ErrAPIKeyNotFound, suite.service.RevokeAPIKey(ctx, 7, 2))
+	suite.mockRepo.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+
+	assert.NoError(suite.T(), suite.service.RevokeAPIKey(ctx, 7, 1))
+	suite.mockRepo.AssertExpectations(suite.T())
+}
+
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) GetByID(ctx context.Context, id uint) (*domain.APIKey, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	args := m.Called(ctx, keyHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) ListActiveByUser(ctx context.Context, userID uint) ([]*domain.APIKey, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) Update(ctx context.Context, key *domain.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) TouchLastUsed(ctx context.Context, id uint, at time.Time, ip string) error {
+	args := m.Called(ctx, id, at, ip)
+	return args.Error(0)
+}
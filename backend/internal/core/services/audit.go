@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+type auditService struct {
+	auditRepo ports.AuditRepository
+}
+
+func NewAuditService(auditRepo ports.AuditRepository) ports.AuditService {
+	return &auditService{
+		auditRepo: auditRepo,
+	}
+}
+
+func (s *auditService) ListEvents(ctx context.Context, filter domain.AuditFilter, offset, limit int) ([]*domain.AuditEvent, int64, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, 0, err
+	}
+
+	events, total, err := s.auditRepo.List(ctx, filter, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	return events, total, nil
+}
+
+// recordAudit adds an event to the audit log, stamped with the request it came
+// from. actorID is 0 when nobody is signed in. A failure is logged rather than
+// failing the action, which has already happened by the time it is recorded.
+func recordAudit(ctx context.Context, auditRepo ports.AuditRepository, actorID uint, action, targetType string, targetID uint, changes domain.AuditChanges) {
+	if auditRepo == nil {
+		return
+	}
+
+	info := domain.RequestInfoFromContext(ctx)
+	event := &domain.AuditEvent{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Changes:    changes,
+		IPAddress:  info.IPAddress,
+		RequestID:  info.RequestID,
+		CreatedAt:  time.Now(),
+	}
+	if actorID != 0 {
+		event.ActorID = &actorID
+	}
+
+	if err := auditRepo.Create(ctx, event); err != nil {
+		fmt.Printf("Failed to record audit event %s: %v\n", action, err)
+	}
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"url-shortener/internal/core/domain"
+)
+
+type AuditServiceTestSuite struct {
+	suite.Suite
+	service       *auditService
+	mockAuditRepo *MockAuditRepository
+	mockUserRepo  *MockUserRepository
+	mockURLRepo   *MockURLRepository
+	mockCacheRepo *MockCacheService
+	mockSessions  *MockSessionRepository
+	ctx           context.Context
+}
+
+func TestAuditServiceSuite(t *testing.T) {
+	suite.Run(t, new(AuditServiceTestSuite))
+}
+
+func (suite *AuditServiceTestSuite) SetupTest() {
+	suite.mockAuditRepo = &MockAuditRepository{}
+	suite.mockUserRepo = &MockUserRepository{}
+	suite.mockURLRepo = &MockURLRepository{}
+	suite.mockCacheRepo = &MockCacheService{}
+	suite.mockSessions = &MockSessionRepository{}
+	suite.service = &auditService{auditRepo: suite.mockAuditRepo}
+	suite.ctx = domain.WithRequestInfo(context.Background(), domain.RequestInfo{RequestID: "req-1", IPAddress: "203.0.113.7"})
+}
+
+// expectEvent captures the event recorded for action
+func (suite *AuditServiceTestSuite) expectEvent(action string) *domain.AuditEvent {
+	event := &domain.AuditEvent{}
+	suite.mockAuditRepo.On("Create", suite.ctx, mock.MatchedBy(func(e *domain.AuditEvent) bool { return e.Action == action })).
+		Run(func(args mock.Arguments) { *event = *args.Get(1).(*domain.AuditEvent) }).Return(nil)
+	return event
+}
+
+func (suite *AuditServiceTestSuite) TestListEvents() {
+	ctx := context.Background()
+	actorID := uint(1)
+	events := []*domain.AuditEvent{{ID: 3, ActorID: &actorID, Action: domain.AuditURLDeleted}}
+	filter := domain.AuditFilter{ActorID: &actorID, Action: " url.deleted ", TargetType: domain.AuditTargetURL}
+	expected := domain.AuditFilter{ActorID: &actorID, Action: "url.deleted", TargetType: domain.AuditTargetURL}
+	suite.mockAuditRepo.On("List", ctx, expected, 0, 20).Return(events, int64(1), nil)
+
+	result, total, err := suite.service.ListEvents(ctx, filter, 0, 20)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), events, result)
+	assert.Equal(suite.T(), int64(1), total)
+}
+
+func (suite *AuditServiceTestSuite) TestListEvents_InvalidFilter() {
+	from := time.Now()
+	to := from.Add(-time.Hour)
+
+	_, _, err := suite.service.ListEvents(context.Background(), domain.AuditFilter{From: &from, To: &to}, 0, 20)
+	assert.True(suite.T(), errors.Is(err, domain.ErrInvalidInput))
+
+	_, _, err = suite.service.ListEvents(context.Background(), domain.AuditFilter{TargetType: "workspace"}, 0, 20)
+	assert.True(suite.T(), errors.Is(err, domain.ErrInvalidInput))
+
+	suite.mockAuditRepo.AssertNotCalled(suite.T(), "List", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *AuditServiceTestSuite) TestRecordAudit_FailureIsIgnored() {
+	suite.mockAuditRepo.On("Create", suite.ctx, mock.Anything).Return(errors.New("db down"))
+
+	assert.NotPanics(suite.T(), func() {
+		recordAudit(suite.ctx, suite.mockAuditRepo, 0, domain.AuditUserPasswordReset, domain.AuditTargetUser, 2, nil)
+	})
+	event := suite.mockAuditRepo.Calls[0].Arguments.Get(1).(*domain.AuditEvent)
+	assert.Nil(suite.T(), event.ActorID)
+
+	// Services built without an audit log record nothing
+	recordAudit(suite.ctx, nil, 1, domain.AuditUserPasswordReset, domain.AuditTargetUser, 2, nil)
+}
+
+func (suite *AuditServiceTestSuite) TestUpdateURL_RecordsDiff() {
+	existing := &domain.ShortURL{ID: 4, UserID: 1, ShortCode: "abc123", OriginalURL: "https://example.com", Title: "Old", IsActive: true}
+	suite.mockURLRepo.On("GetByID", suite.ctx, uint(4)).Return(existing, nil)
+	suite.mockURLRepo.On("Update", suite.ctx, existing).Return(nil)
+	suite.mockCacheRepo.On("CacheURL", suite.ctx, "abc123", "https://example.com", uint(1), time.Hour*24).Return(nil)
+	event := suite.expectEvent(domain.AuditURLUpdated)
+
+	service := &urlService{urlRepo: suite.mockURLRepo, cacheRepo: suite.mockCacheRepo, auditRepo: suite.mockAuditRepo}
+	title := "New"
+	password := "secret123"
+	_, err := service.UpdateURL(suite.ctx, 4, 1, domain.UpdateURLRequest{Title: &title, Password: &password})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), uint(1), *event.ActorID)
+	assert.Equal(suite.T(), domain.AuditTargetURL, event.TargetType)
+	assert.Equal(suite.T(), uint(4), event.TargetID)
+	assert.Equal(suite.T(), "req-1", event.RequestID)
+	assert.Equal(suite.T(), "203.0.113.7", event.IPAddress)
+	assert.Equal(suite.T(), domain.AuditChanges{
+		"title":              {From: "Old", To: "New"},
+		"password_protected": {From: false, To: true},
+	}, event.Changes)
+}
+
+func (suite *AuditServiceTestSuite) TestUpdateURL_NoChangeRecordsNothing() {
+	existing := &domain.ShortURL{ID: 4, UserID: 1, ShortCode: "abc123", OriginalURL: "https://example.com", Title: "Same", IsActive: true}
+	suite.mockURLRepo.On("GetByID", suite.ctx, uint(4)).Return(existing, nil)
+	suite.mockURLRepo.On("Update", suite.ctx, existing).Return(nil)
+	suite.mockCacheRepo.On("CacheURL", suite.ctx, "abc123", "https://example.com", uint(1), time.Hour*24).Return(nil)
+
+	service := &urlService{urlRepo: suite.mockURLRepo, cacheRepo: suite.mockCacheRepo, auditRepo: suite.mockAuditRepo}
+	title := "Same"
+	_, err := service.UpdateURL(suite.ctx, 4, 1, domain.UpdateURLRequest{Title: &title})
+
+	assert.NoError(suite.T(), err)
+	suite.mockAuditRepo.AssertNotCalled(suite.T(), "Create", mock.Anything, mock.Anything)
+}
+
+func (suite *AuditServiceTestSuite) TestDeactivateUser_RecordsAdmin() {
+	user := &domain.User{ID: 2, Email: "bob@example.com", Role: domain.RoleUser, IsActive: true}
+	suite.mockUserRepo.On("GetByID", suite.ctx, uint(2)).Return(user, nil)
+	suite.mockUserRepo.On("Update", suite.ctx, user).Return(nil)
+	suite.mockSessions.On("RevokeAllByUser", suite.ctx, uint(2), domain.SessionRevokedDeactivated).Return(nil)
+	event := suite.expectEvent(domain.AuditAdminUserDeactivated)
+
+	service := &adminService{userRepo: suite.mockUserRepo, sessionRepo: suite.mockSessions, auditRepo: suite.mockAuditRepo}
+	_, err := service.DeactivateUser(suite.ctx, 1, 2)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), uint(1), *event.ActorID)
+	assert.Equal(suite.T(), uint(2), event.TargetID)
+	assert.Equal(suite.T(), domain.AuditChanges{"is_active": {From: true, To: false}}, event.Changes)
+
+	// Deactivating again changes nothing and records nothing more
+	_, err = service.DeactivateUser(suite.ctx, 1, 2)
+	assert.NoError(suite.T(), err)
+	suite.mockAuditRepo.AssertNumberOfCalls(suite.T(), "Create", 1)
+}
+
+func (suite *AuditServiceTestSuite) TestChangePassword_Recorded() {
+	service := &authService{userRepo: suite.mockUserRepo, sessionRepo: suite.mockSessions, auditRepo: suite.mockAuditRepo, cacheRepo: suite.mockCacheRepo}
+	hashedPassword, _ := service.hashPassword("password123")
+	user := &domain.User{ID: 1, Email: "test@example.com", Password: hashedPassword, IsActive: true}
+	suite.mockUserRepo.On("GetByID", suite.ctx, uint(1)).Return(user, nil)
+	suite.mockUserRepo.On("Update", suite.ctx, user).Return(nil)
+	suite.mockSessions.On("RevokeAllByUser", suite.ctx, uint(1), domain.SessionRevokedPasswordChange).Return(nil)
+	suite.mockCacheRepo.On("InvalidateUserSessions", suite.ctx, uint(1)).Return(nil)
+	event := suite.expectEvent(domain.AuditUserPasswordChanged)
+
+	err := service.ChangePassword(suite.ctx, 1, domain.ChangePasswordRequest{CurrentPassword: "password123", NewPassword: "newpassword123"})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), uint(1), *event.ActorID)
+	assert.Nil(suite.T(), event.Changes) // the password itself never reaches the log
+}
+
+// Mock implementations
+
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepository) Create(ctx context.Context, event *domain.AuditEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *MockAuditRepository) List(ctx context.Context, filter domain.AuditFilter, offset, limit int) ([]*domain.AuditEvent, int64, error) {
+	args := m.Called(ctx, filter, offset, limit)
+	return args.Get(0).([]*domain.AuditEvent), args.Get(1).(int64), args.Error(2)
+}
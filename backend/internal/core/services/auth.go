@@ -11,27 +11,48 @@ import (
 )
 
 type authService struct {
-	userRepo    ports.UserRepository
-	cacheRepo   ports.CacheService
-	jwtService  ports.JWTService
-	configRepo  ports.ConfigService
+	userRepo     ports.UserRepository
+	sessionRepo  ports.SessionRepository
+	recoveryRepo ports.RecoveryCodeRepository
+	identityRepo ports.UserIdentityRepository
+	auditRepo    ports.AuditRepository
+	cacheRepo    ports.CacheService
+	jwtService   ports.JWTService
+	configRepo   ports.ConfigService
+	notifier     ports.NotificationService
+	qrProvider   ports.QRCodeProvider
+	oidcProvider ports.OIDCProvider // nil when single sign-on is off
 }
 
 func NewAuthService(
 	userRepo ports.UserRepository,
+	sessionRepo ports.SessionRepository,
+	recoveryRepo ports.RecoveryCodeRepository,
+	identityRepo ports.UserIdentityRepository,
+	auditRepo ports.AuditRepository,
 	cacheRepo ports.CacheService,
 	jwtService ports.JWTService,
 	configRepo ports.ConfigService,
+	notifier ports.NotificationService,
+	qrProvider ports.QRCodeProvider,
+	oidcProvider ports.OIDCProvider,
 ) ports.AuthService {
 	return &authService{
-		userRepo:   userRepo,
-		cacheRepo:  cacheRepo,
-		jwtService: jwtService,
-		configRepo: configRepo,
+		userRepo:     userRepo,
+		sessionRepo:  sessionRepo,
+		recoveryRepo: recoveryRepo,
+		identityRepo: identityRepo,
+		auditRepo:    auditRepo,
+		cacheRepo:    cacheRepo,
+		jwtService:   jwtService,
+		configRepo:   configRepo,
+		notifier:     notifier,
+		qrProvider:   qrProvider,
+		oidcProvider: oidcProvider,
 	}
 }
 
-func (s *authService) Register(ctx context.Context, req domain.RegisterRequest) (*domain.AuthResponse, error) {
+func (s *authService) Register(ctx context.Context, req domain.RegisterRequest, meta domain.SessionMetadata) (*domain.AuthResponse, error) {
 	// Validate request
 	if err := req.Validate(); err != nil {
 		return nil, err
@@ -59,6 +80,7 @@ func (s *authService) Register(ctx context.Context, req domain.RegisterRequest)
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
 		IsActive:  true,
+		Role:      domain.RoleUser,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -66,32 +88,29 @@ func (s *authService) Register(ctx context.Context, req domain.RegisterRequest)
 	if err := s.userRepo.Create(ctx, user); err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
+	recordAudit(ctx, s.auditRepo, user.ID, domain.AuditUserRegistered, domain.AuditTargetUser, user.ID, domain.DiffAudit(nil, user.AuditSnapshot()))
 
-	// Generate tokens
-	accessToken, err := s.jwtService.GenerateAccessToken(user.ID, user.Email)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	// Send the welcome email with a verification link
+	token := s.signVerificationToken(user, time.Now().Add(emailVerificationTTL))
+	if err := s.notifier.SendWelcomeEmail(ctx, user, token); err != nil {
+		// Log error but don't fail the registration; the link can be resent
+		fmt.Printf("Failed to send welcome email: %v\n", err)
 	}
 
-	refreshToken, err := s.jwtService.GenerateRefreshToken(user.ID)
+	// Start a session for this device
+	session, refreshToken, err := s.startSession(ctx, user, meta)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+		return nil, err
 	}
 
-	// Store refresh token in cache
-	if err := s.cacheRepo.SetSession(ctx, refreshToken, user.ID, time.Hour*24*7); err != nil {
-		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	// Generate tokens
+	accessToken, err := s.jwtService.GenerateAccessToken(user.ID, user.Email, user.RoleOrDefault(), session.FamilyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
 	return &domain.AuthResponse{
-		User: &domain.UserResponse{
-			ID:        user.ID,
-			Email:     user.Email,
-			FirstName: user.FirstName,
-			LastName:  user.LastName,
-			IsActive:  user.IsActive,
-			CreatedAt: user.CreatedAt,
-		},
+		User:         user.ToResponse(),
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		TokenType:    "Bearer",
@@ -99,7 +118,7 @@ func (s *authService) Register(ctx context.Context, req domain.RegisterRequest)
 	}, nil
 }
 
-func (s *authService) Login(ctx context.Context, req domain.LoginRequest) (*domain.AuthResponse, error) {
+func (s *authService) Login(ctx context.Context, req domain.LoginRequest, meta domain.SessionMetadata) (*domain.AuthResponse, error) {
 	// Validate request
 	if err := req.Validate(); err != nil {
 		return nil, err
@@ -124,20 +143,26 @@ func (s *authService) Login(ctx context.Context, req domain.LoginRequest) (*doma
 		return nil, domain.ErrInvalidCredentials
 	}
 
-	// Generate tokens
-	accessToken, err := s.jwtService.GenerateAccessToken(user.ID, user.Email)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	// Accounts with two-factor enabled finish signing in with CompleteMFALogin
+	if user.IsTwoFactorEnabled() {
+		return s.startMFAChallenge(ctx, user)
 	}
 
-	refreshToken, err := s.jwtService.GenerateRefreshToken(user.ID)
+	return s.completeLogin(ctx, user, meta)
+}
+
+// completeLogin issues tokens once every factor has been checked
+func (s *authService) completeLogin(ctx context.Context, user *domain.User, meta domain.SessionMetadata) (*domain.AuthResponse, error) {
+	// Start a session for this device
+	session, refreshToken, err := s.startSession(ctx, user, meta)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+		return nil, err
 	}
 
-	// Store refresh token in cache
-	if err := s.cacheRepo.SetSession(ctx, refreshToken, user.ID, time.Hour*24*7); err != nil {
-		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	// Generate tokens
+	accessToken, err := s.jwtService.GenerateAccessToken(user.ID, user.Email, user.RoleOrDefault(), session.FamilyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
 	// Update last login
@@ -148,16 +173,10 @@ func (s *authService) Login(ctx context.Context, req domain.LoginRequest) (*doma
 		// Log error but don't fail the login
 		fmt.Printf("Failed to update last login: %v", err)
 	}
+	recordAudit(ctx, s.auditRepo, user.ID, domain.AuditUserLoggedIn, domain.AuditTargetUser, user.ID, nil)
 
 	return &domain.AuthResponse{
-		User: &domain.UserResponse{
-			ID:        user.ID,
-			Email:     user.Email,
-			FirstName: user.FirstName,
-			LastName:  user.LastName,
-			IsActive:  user.IsActive,
-			CreatedAt: user.CreatedAt,
-		},
+		User:         user.ToResponse(),
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		TokenType:    "Bearer",
@@ -165,23 +184,15 @@ func (s *authService) Login(ctx context.Context, req domain.LoginRequest) (*doma
 	}, nil
 }
 
-func (s *authService) RefreshToken(ctx context.Context, refreshToken string) (*domain.AuthResponse, error) {
-	// Validate refresh token
-	claims, err := s.jwtService.ValidateRefreshToken(refreshToken)
+// RefreshToken rotates the session's refresh token; each token can be used once
+func (s *authService) RefreshToken(ctx context.Context, refreshToken string, meta domain.SessionMetadata) (*domain.AuthResponse, error) {
+	session, newRefreshToken, err := s.rotateSession(ctx, refreshToken, meta)
 	if err != nil {
-		return nil, domain.ErrInvalidToken
-	}
-
-	userID := claims.UserID
-
-	// Check if refresh token exists in cache
-	storedUserID, err := s.cacheRepo.GetSession(ctx, refreshToken)
-	if err != nil || storedUserID != userID {
-		return nil, domain.ErrInvalidToken
+		return nil, err
 	}
 
 	// Get user
-	user, err := s.userRepo.GetByID(ctx, userID)
+	user, err := s.userRepo.GetByID(ctx, session.UserID)
 	if err != nil {
 		if err == domain.ErrUserNotFound {
 			return nil, domain.ErrInvalidToken
@@ -194,31 +205,14 @@ func (s *authService) RefreshToken(ctx context.Context, refreshToken string) (*d
 		return nil, domain.ErrUserInactive
 	}
 
-	// Generate new tokens
-	accessToken, err := s.jwtService.GenerateAccessToken(user.ID, user.Email)
+	// Generate new access token
+	accessToken, err := s.jwtService.GenerateAccessToken(user.ID, user.Email, user.RoleOrDefault(), session.FamilyID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
-	newRefreshToken, err := s.jwtService.GenerateRefreshToken(user.ID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
-	}
-
-	// Store new refresh token in cache
-	if err := s.cacheRepo.SetSession(ctx, newRefreshToken, user.ID, time.Hour*24*7); err != nil {
-		return nil, fmt.Errorf("failed to store refresh token: %w", err)
-	}
-
 	return &domain.AuthResponse{
-		User: &domain.UserResponse{
-			ID:        user.ID,
-			Email:     user.Email,
-			FirstName: user.FirstName,
-			LastName:  user.LastName,
-			IsActive:  user.IsActive,
-			CreatedAt: user.CreatedAt,
-		},
+		User:         user.ToResponse(),
 		AccessToken:  accessToken,
 		RefreshToken: newRefreshToken,
 		TokenType:    "Bearer",
@@ -226,10 +220,34 @@ func (s *authService) RefreshToken(ctx context.Context, refreshToken string) (*d
 	}, nil
 }
 
-func (s *authService) Logout(ctx context.Context, userID uint) error {
-	// In a real implementation, we would need to track all refresh tokens for a user
-	// For now, we'll implement a simplified version
-	// This would require changing the cache interface to support user-based session invalidation
+// Logout ends the session the refresh token belongs to, along with the access
+// tokens issued for it
+func (s *authService) Logout(ctx context.Context, userID uint, refreshToken string) error {
+	if refreshToken == "" {
+		return nil
+	}
+
+	claims, err := s.jwtService.ValidateRefreshToken(refreshToken)
+	if err != nil || claims.SessionID == "" || claims.UserID != userID {
+		// Nothing to end
+		return nil
+	}
+
+	session, err := s.sessionRepo.GetByFamilyID(ctx, claims.SessionID)
+	if err != nil {
+		if err == domain.ErrSessionNotFound {
+			return nil
+		}
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if session.UserID != userID {
+		return nil
+	}
+
+	if err := s.sessionRepo.Revoke(ctx, session.ID, domain.SessionRevokedLogout); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	s.forgetSession(ctx, session.FamilyID)
 	return nil
 }
 
@@ -239,14 +257,7 @@ func (s *authService) GetProfile(ctx context.Context, userID uint) (*domain.User
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	return &domain.UserResponse{
-		ID:        user.ID,
-		Email:     user.Email,
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		IsActive:  user.IsActive,
-		CreatedAt: user.CreatedAt,
-	}, nil
+	return user.ToResponse(), nil
 }
 
 func (s *authService) UpdateProfile(ctx context.Context, userID uint, req domain.UpdateProfileRequest) (*domain.UserResponse, error) {
@@ -260,6 +271,7 @@ func (s *authService) UpdateProfile(ctx context.Context, userID uint, req domain
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
+	before := user.AuditSnapshot()
 
 	// Update fields
 	if req.FirstName != "" {
@@ -275,14 +287,11 @@ func (s *authService) UpdateProfile(ctx context.Context, userID uint, req domain
 		return nil, fmt.Errorf("failed to update user: %w", err)
 	}
 
-	return &domain.UserResponse{
-		ID:        user.ID,
-		Email:     user.Email,
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		IsActive:  user.IsActive,
-		CreatedAt: user.CreatedAt,
-	}, nil
+	if changes := domain.DiffAudit(before, user.AuditSnapshot()); changes != nil {
+		recordAudit(ctx, s.auditRepo, user.ID, domain.AuditUserProfileUpdated, domain.AuditTargetUser, user.ID, changes)
+	}
+
+	return user.ToResponse(), nil
 }
 
 func (s *authService) ChangePassword(ctx context.Context, userID uint, req domain.ChangePasswordRequest) error {
@@ -315,9 +324,13 @@ func (s *authService) ChangePassword(ctx context.Context, userID uint, req domai
 	if err := s.userRepo.Update(ctx, user); err != nil {
 		return fmt.Errorf("failed to update password: %w", err)
 	}
+	recordAudit(ctx, s.auditRepo, user.ID, domain.AuditUserPasswordChanged, domain.AuditTargetUser, user.ID, nil)
 
-	// In a real implementation, we would logout from all sessions
-	// For now, we'll skip this step as it requires more complex session management
+	// Sign out every device; the client signs in again with the new password
+	if err := s.sessionRepo.RevokeAllByUser(ctx, user.ID, domain.SessionRevokedPasswordChange); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	s.forgetUserSessions(ctx, user.ID)
 
 	return nil
 }
@@ -2,12 +2,14 @@ package services
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
 	"url-shortener/internal/core/domain"
 )
 
@@ -15,9 +17,15 @@ type AuthServiceTestSuite struct {
 	suite.Suite
 	authService   *authService
 	mockUserRepo  *MockUserRepository
+	mockSessions  *MockSessionRepository
+	mockRecovery  *MockRecoveryCodeRepository
+	mockIdentities *MockUserIdentityRepository
 	mockCacheRepo *MockCacheService
 	mockJWTRepo   *MockJWTService
 	mockConfigRepo *MockConfigService
+	mockNotifier   *MockNotificationService
+	mockQR         *MockQRCodeProvider
+	mockOIDC       *MockOIDCProvider
 }
 
 func TestAuthServiceSuite(t *testing.T) {
@@ -26,15 +34,28 @@ func TestAuthServiceSuite(t *testing.T) {
 
 func (suite *AuthServiceTestSuite) SetupTest() {
 	suite.mockUserRepo = &MockUserRepository{}
+	suite.mockSessions = &MockSessionRepository{}
+	suite.mockRecovery = &MockRecoveryCodeRepository{}
+	suite.mockIdentities = &MockUserIdentityRepository{}
 	suite.mockCacheRepo = &MockCacheService{}
 	suite.mockJWTRepo = &MockJWTService{}
 	suite.mockConfigRepo = &MockConfigService{}
+	suite.mockNotifier = &MockNotificationService{}
+	suite.mockQR = &MockQRCodeProvider{}
+	suite.mockOIDC = &MockOIDCProvider{}
+	suite.mockConfigRepo.On("GetSessionTTL").Return(7 * 24 * time.Hour).Maybe()
 	
 	suite.authService = &authService{
-		userRepo:   suite.mockUserRepo,
-		cacheRepo:  suite.mockCacheRepo,
-		jwtService: suite.mockJWTRepo,
-		configRepo: suite.mockConfigRepo,
+		userRepo:     suite.mockUserRepo,
+		sessionRepo:  suite.mockSessions,
+		recoveryRepo: suite.mockRecovery,
+		identityRepo: suite.mockIdentities,
+		cacheRepo:    suite.mockCacheRepo,
+		jwtService:   suite.mockJWTRepo,
+		configRepo:   suite.mockConfigRepo,
+		notifier:     suite.mockNotifier,
+		qrProvider:   suite.mockQR,
+		oidcProvider: suite.mockOIDC,
 	}
 }
 
@@ -50,12 +71,14 @@ func (suite *AuthServiceTestSuite) TestRegister_Success() {
 	// Mock expectations
 	suite.mockUserRepo.On("Exists", ctx, req.Email).Return(false, nil)
 	suite.mockUserRepo.On("Create", ctx, mock.AnythingOfType("*domain.User")).Return(nil)
-	suite.mockJWTRepo.On("GenerateAccessToken", mock.AnythingOfType("uint"), req.Email).Return("access_token", nil)
-	suite.mockJWTRepo.On("GenerateRefreshToken", mock.AnythingOfType("uint")).Return("refresh_token", nil)
-	suite.mockCacheRepo.On("SetSession", ctx, "refresh_token", mock.AnythingOfType("uint"), time.Hour*24*7).Return(nil)
+	suite.mockConfigRepo.On("GetJWTSecret").Return("test-secret")
+	suite.mockNotifier.On("SendWelcomeEmail", ctx, mock.AnythingOfType("*domain.User"), mock.AnythingOfType("string")).Return(nil)
+	suite.mockJWTRepo.On("GenerateAccessToken", mock.AnythingOfType("uint"), req.Email, domain.RoleUser, mock.AnythingOfType("string")).Return("access_token", nil)
+	suite.mockJWTRepo.On("GenerateRefreshToken", mock.AnythingOfType("uint"), mock.AnythingOfType("string")).Return("refresh_token", nil)
+	suite.mockSessions.On("Create", ctx, mock.AnythingOfType("*domain.Session")).Return(nil)
 
 	// Execute
-	response, err := suite.authService.Register(ctx, req)
+	response, err := suite.authService.Register(ctx, req, domain.SessionMetadata{})
 
 	// Assert
 	assert.NoError(suite.T(), err)
@@ -65,10 +88,12 @@ func (suite *AuthServiceTestSuite) TestRegister_Success() {
 	assert.Equal(suite.T(), "Bearer", response.TokenType)
 	assert.Equal(suite.T(), 3600, response.ExpiresIn)
 	assert.Equal(suite.T(), req.Email, response.User.Email)
+	assert.False(suite.T(), response.User.EmailVerified)
 
 	suite.mockUserRepo.AssertExpectations(suite.T())
-	suite.mockCacheRepo.AssertExpectations(suite.T())
+	suite.mockSessions.AssertExpectations(suite.T())
 	suite.mockJWTRepo.AssertExpectations(suite.T())
+	suite.mockNotifier.AssertExpectations(suite.T())
 }
 
 func (suite *AuthServiceTestSuite) TestRegister_UserExists() {
@@ -84,7 +109,7 @@ func (suite *AuthServiceTestSuite) TestRegister_UserExists() {
 	suite.mockUserRepo.On("Exists", ctx, req.Email).Return(true, nil)
 
 	// Execute
-	response, err := suite.authService.Register(ctx, req)
+	response, err := suite.authService.Register(ctx, req, domain.SessionMetadata{})
 
 	// Assert
 	assert.Error(suite.T(), err)
@@ -115,12 +140,16 @@ func (suite *AuthServiceTestSuite) TestLogin_Success() {
 	// Mock expectations
 	suite.mockUserRepo.On("GetByEmail", ctx, req.Email).Return(user, nil)
 	suite.mockUserRepo.On("Update", ctx, mock.AnythingOfType("*domain.User")).Return(nil)
-	suite.mockJWTRepo.On("GenerateAccessToken", user.ID, user.Email).Return("access_token", nil)
-	suite.mockJWTRepo.On("GenerateRefreshToken", user.ID).Return("refresh_token", nil)
-	suite.mockCacheRepo.On("SetSession", ctx, "refresh_token", user.ID, time.Hour*24*7).Return(nil)
+	suite.mockJWTRepo.On("GenerateAccessToken", user.ID, user.Email, domain.RoleUser, mock.AnythingOfType("string")).Return("access_token", nil)
+	suite.mockJWTRepo.On("GenerateRefreshToken", user.ID, mock.AnythingOfType("string")).Return("refresh_token", nil)
+	suite.mockSessions.On("Create", ctx, mock.AnythingOfType("*domain.Session")).Return(nil)
 
 	// Execute
-	response, err := suite.authService.Login(ctx, req)
+	response, err := suite.authService.Login(ctx, req, domain.SessionMetadata{
+		UserAgent: "Mozilla/5.0",
+		Device:    "Firefox on Linux",
+		IPAddress: "203.0.113.9",
+	})
 
 	// Assert
 	assert.NoError(suite.T(), err)
@@ -128,8 +157,18 @@ func (suite *AuthServiceTestSuite) TestLogin_Success() {
 	assert.Equal(suite.T(), "access_token", response.AccessToken)
 	assert.Equal(suite.T(), "refresh_token", response.RefreshToken)
 
+	// The session remembers the device and only a hash of the token
+	session := suite.mockSessions.Calls[0].Arguments.Get(1).(*domain.Session)
+	familyID := suite.mockJWTRepo.Calls[0].Arguments.String(1)
+	assert.Equal(suite.T(), familyID, session.FamilyID)
+	// The access token names the same session, so ending it ends the token
+	assert.Equal(suite.T(), familyID, suite.mockJWTRepo.Calls[1].Arguments.String(3))
+	assert.Equal(suite.T(), hashRefreshToken("refresh_token"), session.TokenHash)
+	assert.Equal(suite.T(), "Firefox on Linux", session.Device)
+	assert.Equal(suite.T(), "203.0.113.9", session.IPAddress)
+
 	suite.mockUserRepo.AssertExpectations(suite.T())
-	suite.mockCacheRepo.AssertExpectations(suite.T())
+	suite.mockSessions.AssertExpectations(suite.T())
 	suite.mockJWTRepo.AssertExpectations(suite.T())
 }
 
@@ -152,7 +191,7 @@ func (suite *AuthServiceTestSuite) TestLogin_InvalidCredentials() {
 	suite.mockUserRepo.On("GetByEmail", ctx, req.Email).Return(user, nil)
 
 	// Execute
-	response, err := suite.authService.Login(ctx, req)
+	response, err := suite.authService.Login(ctx, req, domain.SessionMetadata{})
 
 	// Assert
 	assert.Error(suite.T(), err)
@@ -168,7 +207,16 @@ func (suite *AuthServiceTestSuite) TestRefreshToken_Success() {
 	userID := uint(1)
 
 	claims := &domain.TokenClaims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: "family-1",
+	}
+	session := &domain.Session{
+		ID:        5,
+		UserID:    userID,
+		FamilyID:  "family-1",
+		TokenHash: hashRefreshToken(refreshToken),
+		IPAddress: "198.51.100.1",
+		ExpiresAt: time.Now().Add(time.Hour),
 	}
 
 	user := &domain.User{
@@ -182,14 +230,16 @@ func (suite *AuthServiceTestSuite) TestRefreshToken_Success() {
 
 	// Mock expectations
 	suite.mockJWTRepo.On("ValidateRefreshToken", refreshToken).Return(claims, nil)
-	suite.mockCacheRepo.On("GetSession", ctx, refreshToken).Return(userID, nil)
+	suite.mockSessions.On("GetByFamilyID", ctx, "family-1").Return(session, nil)
+	suite.mockJWTRepo.On("GenerateRefreshToken", userID, "family-1").Return("new_refresh_token", nil)
+	suite.mockSessions.On("Rotate", ctx, mock.MatchedBy(func(s *domain.Session) bool {
+		return s.TokenHash == hashRefreshToken("new_refresh_token") && s.IPAddress == "203.0.113.9"
+	}), hashRefreshToken(refreshToken)).Return(true, nil)
 	suite.mockUserRepo.On("GetByID", ctx, userID).Return(user, nil)
-	suite.mockJWTRepo.On("GenerateAccessToken", userID, user.Email).Return("new_access_token", nil)
-	suite.mockJWTRepo.On("GenerateRefreshToken", userID).Return("new_refresh_token", nil)
-	suite.mockCacheRepo.On("SetSession", ctx, "new_refresh_token", userID, time.Hour*24*7).Return(nil)
+	suite.mockJWTRepo.On("GenerateAccessToken", userID, user.Email, domain.RoleUser, "family-1").Return("new_access_token", nil)
 
 	// Execute
-	response, err := suite.authService.RefreshToken(ctx, refreshToken)
+	response, err := suite.authService.RefreshToken(ctx, refreshToken, domain.SessionMetadata{IPAddress: "203.0.113.9"})
 
 	// Assert
 	assert.NoError(suite.T(), err)
@@ -198,19 +248,294 @@ func (suite *AuthServiceTestSuite) TestRefreshToken_Success() {
 	assert.Equal(suite.T(), "new_refresh_token", response.RefreshToken)
 
 	suite.mockJWTRepo.AssertExpectations(suite.T())
-	suite.mockCacheRepo.AssertExpectations(suite.T())
+	suite.mockSessions.AssertExpectations(suite.T())
 	suite.mockUserRepo.AssertExpectations(suite.T())
 }
 
+func (suite *AuthServiceTestSuite) TestRefreshToken_ReuseRevokesSession() {
+	ctx := context.Background()
+	session := &domain.Session{
+		ID:        5,
+		UserID:    1,
+		FamilyID:  "family-1",
+		TokenHash: hashRefreshToken("current_refresh_token"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	// The old token still has a valid signature but has been rotated away
+	suite.mockJWTRepo.On("ValidateRefreshToken", "old_refresh_token").Return(&domain.TokenClaims{UserID: 1, SessionID: "family-1"}, nil)
+	suite.mockSessions.On("GetByFamilyID", ctx, "family-1").Return(session, nil)
+	suite.mockSessions.On("Revoke", ctx, uint(5), domain.SessionRevokedReuse).Return(nil)
+	suite.mockCacheRepo.On("InvalidateSession", ctx, "family-1").Return(nil)
+
+	response, err := suite.authService.RefreshToken(ctx, "old_refresh_token", domain.SessionMetadata{})
+
+	assert.Nil(suite.T(), response)
+	assert.Equal(suite.T(), domain.ErrRefreshTokenReused, err)
+	suite.mockSessions.AssertExpectations(suite.T())
+	suite.mockJWTRepo.AssertNotCalled(suite.T(), "GenerateRefreshToken", mock.Anything, mock.Anything)
+}
+
+func (suite *AuthServiceTestSuite) TestRefreshToken_ConcurrentRotation() {
+	ctx := context.Background()
+	session := &domain.Session{
+		ID:        5,
+		UserID:    1,
+		FamilyID:  "family-1",
+		TokenHash: hashRefreshToken("refresh_token"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	suite.mockJWTRepo.On("ValidateRefreshToken", "refresh_token").Return(&domain.TokenClaims{UserID: 1, SessionID: "family-1"}, nil)
+	suite.mockSessions.On("GetByFamilyID", ctx, "family-1").Return(session, nil)
+	suite.mockJWTRepo.On("GenerateRefreshToken", uint(1), "family-1").Return("new_refresh_token", nil)
+	// Another request rotated the token between the read and the write
+	suite.mockSessions.On("Rotate", ctx, session, hashRefreshToken("refresh_token")).Return(false, nil)
+	suite.mockSessions.On("Revoke", ctx, uint(5), domain.SessionRevokedReuse).Return(nil)
+	suite.mockCacheRepo.On("InvalidateSession", ctx, "family-1").Return(nil)
+
+	_, err := suite.authService.RefreshToken(ctx, "refresh_token", domain.SessionMetadata{})
+
+	assert.Equal(suite.T(), domain.ErrRefreshTokenReused, err)
+	suite.mockSessions.AssertExpectations(suite.T())
+}
+
+func (suite *AuthServiceTestSuite) TestRefreshToken_RevokedSession() {
+	ctx := context.Background()
+	revokedAt := time.Now()
+	session := &domain.Session{
+		ID:        5,
+		UserID:    1,
+		FamilyID:  "family-1",
+		TokenHash: hashRefreshToken("refresh_token"),
+		ExpiresAt: time.Now().Add(time.Hour),
+		RevokedAt: &revokedAt,
+	}
+
+	suite.mockJWTRepo.On("ValidateRefreshToken", "refresh_token").Return(&domain.TokenClaims{UserID: 1, SessionID: "family-1"}, nil)
+	suite.mockSessions.On("GetByFamilyID", ctx, "family-1").Return(session, nil)
+	// Tokens issued before sessions were tracked carry no session ID
+	suite.mockJWTRepo.On("ValidateRefreshToken", "legacy_token").Return(&domain.TokenClaims{UserID: 1}, nil)
+
+	_, err := suite.authService.RefreshToken(ctx, "refresh_token", domain.SessionMetadata{})
+	assert.Equal(suite.T(), domain.ErrInvalidToken, err)
+
+	_, err = suite.authService.RefreshToken(ctx, "legacy_token", domain.SessionMetadata{})
+	assert.Equal(suite.T(), domain.ErrInvalidToken, err)
+	suite.mockSessions.AssertNotCalled(suite.T(), "Revoke", mock.Anything, mock.Anything, mock.Anything)
+}
+
 func (suite *AuthServiceTestSuite) TestLogout_Success() {
 	ctx := context.Background()
 	userID := uint(1)
 
+	suite.mockJWTRepo.On("ValidateRefreshToken", "refresh_token").Return(&domain.TokenClaims{UserID: userID, SessionID: "family-1"}, nil)
+	suite.mockSessions.On("GetByFamilyID", ctx, "family-1").Return(&domain.Session{ID: 5, UserID: userID, FamilyID: "family-1"}, nil)
+	suite.mockSessions.On("Revoke", ctx, uint(5), domain.SessionRevokedLogout).Return(nil)
+	suite.mockCacheRepo.On("InvalidateSession", ctx, "family-1").Return(nil)
+
 	// Execute
-	err := suite.authService.Logout(ctx, userID)
+	err := suite.authService.Logout(ctx, userID, "refresh_token")
 
 	// Assert
 	assert.NoError(suite.T(), err)
+	suite.mockSessions.AssertExpectations(suite.T())
+	suite.mockCacheRepo.AssertExpectations(suite.T())
+
+	// Without a refresh token there is no session to end
+	assert.NoError(suite.T(), suite.authService.Logout(ctx, userID, ""))
+}
+
+func (suite *AuthServiceTestSuite) TestRevokeSession() {
+	ctx := context.Background()
+	suite.mockSessions.On("GetByID", ctx, uint(5)).Return(&domain.Session{ID: 5, UserID: 1, FamilyID: "family-1", ExpiresAt: time.Now().Add(time.Hour)}, nil)
+	suite.mockSessions.On("Revoke", ctx, uint(5), domain.SessionRevokedByUser).Return(nil)
+	suite.mockCacheRepo.On("InvalidateSession", ctx, "family-1").Return(nil)
+
+	// Another user's session looks like a missing one
+	assert.Equal(suite.T(), domain.ErrSessionNotFound, suite.authService.RevokeSession(ctx, 2, 5))
+	suite.mockSessions.AssertNotCalled(suite.T(), "Revoke", mock.Anything, mock.Anything, mock.Anything)
+
+	assert.NoError(suite.T(), suite.authService.RevokeSession(ctx, 1, 5))
+	suite.mockSessions.AssertExpectations(suite.T())
+	suite.mockCacheRepo.AssertExpectations(suite.T())
+}
+
+func (suite *AuthServiceTestSuite) TestChangePassword_RevokesSessions() {
+	ctx := context.Background()
+	hashedPassword, _ := suite.authService.hashPassword("password123")
+	user := &domain.User{ID: 1, Email: "test@example.com", Password: hashedPassword, IsActive: true}
+
+	suite.mockUserRepo.On("GetByID", ctx, uint(1)).Return(user, nil)
+	suite.mockUserRepo.On("Update", ctx, user).Return(nil)
+	suite.mockSessions.On("RevokeAllByUser", ctx, uint(1), domain.SessionRevokedPasswordChange).Return(nil)
+	suite.mockCacheRepo.On("InvalidateUserSessions", ctx, uint(1)).Return(nil)
+
+	err := suite.authService.ChangePassword(ctx, 1, domain.ChangePasswordRequest{CurrentPassword: "password123", NewPassword: "newpassword123"})
+
+	assert.NoError(suite.T(), err)
+	suite.mockSessions.AssertExpectations(suite.T())
+}
+
+func (suite *AuthServiceTestSuite) TestRequestPasswordReset_SendsToken() {
+	ctx := context.Background()
+	user := &domain.User{ID: 1, Email: "test@example.com", IsActive: true}
+
+	var sentToken string
+	suite.mockCacheRepo.On("IsRateLimited", ctx, "password_reset_rate:test@example.com", int64(maxPasswordResetRequests), passwordResetRequestWindow).Return(false, nil)
+	suite.mockCacheRepo.On("IncrementRateLimit", ctx, "password_reset_rate:test@example.com", passwordResetRequestWindow).Return(int64(1), nil)
+	suite.mockUserRepo.On("GetByEmail", ctx, "test@example.com").Return(user, nil)
+	// An earlier token for the same user is revoked
+	suite.mockCacheRepo.On("Get", ctx, "password_reset_user:1").Return("password_reset:old", nil)
+	suite.mockCacheRepo.On("Del", ctx, []string{"password_reset:old", "password_reset_user:1"}).Return(nil)
+	suite.mockCacheRepo.On("Set", ctx, mock.AnythingOfType("string"), user.ID, passwordResetTTL).Return(nil)
+	suite.mockCacheRepo.On("Set", ctx, "password_reset_user:1", mock.AnythingOfType("string"), passwordResetTTL).Return(nil)
+	suite.mockNotifier.On("SendPasswordResetEmail", ctx, user, mock.AnythingOfType("string")).
+		Run(func(args mock.Arguments) { sentToken = args.String(2) }).Return(nil)
+
+	err := suite.authService.RequestPasswordReset(ctx, domain.PasswordResetRequest{Email: "test@example.com"})
+
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), sentToken, 43)
+	// Only the hash of the emailed token is stored
+	suite.mockCacheRepo.AssertCalled(suite.T(), "Set", ctx, passwordResetKey(sentToken), user.ID, passwordResetTTL)
+	suite.mockCacheRepo.AssertCalled(suite.T(), "Set", ctx, "password_reset_user:1", passwordResetKey(sentToken), passwordResetTTL)
+	assert.NotContains(suite.T(), passwordResetKey(sentToken), sentToken)
+	suite.mockNotifier.AssertExpectations(suite.T())
+}
+
+func (suite *AuthServiceTestSuite) TestRequestPasswordReset_UnknownEmail() {
+	ctx := context.Background()
+
+	suite.mockCacheRepo.On("IsRateLimited", ctx, mock.AnythingOfType("string"), mock.AnythingOfType("int64"), passwordResetRequestWindow).Return(false, nil)
+	suite.mockCacheRepo.On("IncrementRateLimit", ctx, mock.AnythingOfType("string"), passwordResetRequestWindow).Return(int64(1), nil)
+	suite.mockUserRepo.On("GetByEmail", ctx, "nobody@example.com").Return(nil, domain.ErrUserNotFound)
+
+	err := suite.authService.RequestPasswordReset(ctx, domain.PasswordResetRequest{Email: "nobody@example.com"})
+
+	assert.NoError(suite.T(), err)
+	suite.mockNotifier.AssertNotCalled(suite.T(), "SendPasswordResetEmail", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *AuthServiceTestSuite) TestRequestPasswordReset_RateLimited() {
+	ctx := context.Background()
+
+	suite.mockCacheRepo.On("IsRateLimited", ctx, "password_reset_rate:test@example.com", mock.AnythingOfType("int64"), passwordResetRequestWindow).Return(true, nil)
+
+	err := suite.authService.RequestPasswordReset(ctx, domain.PasswordResetRequest{Email: "Test@Example.com"})
+
+	assert.Equal(suite.T(), domain.ErrTooManyRequests, err)
+	suite.mockUserRepo.AssertNotCalled(suite.T(), "GetByEmail", mock.Anything, mock.Anything)
+}
+
+func (suite *AuthServiceTestSuite) TestResetPassword_Success() {
+	ctx := context.Background()
+	user := &domain.User{ID: 1, Email: "test@example.com", Password: "old-hash", IsActive: true}
+	tokenKey := passwordResetKey("reset-token")
+
+	suite.mockCacheRepo.On("GetDel", ctx, tokenKey).Return("1", nil).Once()
+	suite.mockUserRepo.On("GetByID", ctx, uint(1)).Return(user, nil)
+	suite.mockSessions.On("RevokeAllByUser", ctx, uint(1), domain.SessionRevokedPasswordReset).Return(nil)
+	suite.mockCacheRepo.On("InvalidateUserSessions", ctx, uint(1)).Return(nil)
+	suite.mockCacheRepo.On("Del", ctx, []string{"password_reset_user:1"}).Return(nil)
+	suite.mockUserRepo.On("Update", ctx, mock.MatchedBy(func(u *domain.User) bool {
+		return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte("newpassword123")) == nil
+	})).Return(nil)
+	suite.mockNotifier.On("SendPasswordChangedNotification", ctx, user).Return(nil)
+
+	err := suite.authService.ResetPassword(ctx, domain.PasswordResetConfirmRequest{Token: "reset-token", NewPassword: "newpassword123"})
+
+	assert.NoError(suite.T(), err)
+
+	// The first request consumed the token, so a replay finds nothing
+	suite.mockCacheRepo.On("GetDel", ctx, tokenKey).Return("", errors.New("redis: nil"))
+	err = suite.authService.ResetPassword(ctx, domain.PasswordResetConfirmRequest{Token: "reset-token", NewPassword: "otherpassword123"})
+	assert.Equal(suite.T(), domain.ErrInvalidToken, err)
+	suite.mockUserRepo.AssertNumberOfCalls(suite.T(), "Update", 1)
+
+	suite.mockCacheRepo.AssertExpectations(suite.T())
+	suite.mockSessions.AssertExpectations(suite.T())
+	suite.mockUserRepo.AssertExpectations(suite.T())
+	suite.mockNotifier.AssertExpectations(suite.T())
+}
+
+func (suite *AuthServiceTestSuite) TestResetPassword_InvalidToken() {
+	ctx := context.Background()
+
+	// Unknown, expired and already used tokens are all missing from the cache
+	suite.mockCacheRepo.On("GetDel", ctx, passwordResetKey("used-token")).Return("", errors.New("redis: nil"))
+
+	err := suite.authService.ResetPassword(ctx, domain.PasswordResetConfirmRequest{Token: "used-token", NewPassword: "newpassword123"})
+
+	assert.Equal(suite.T(), domain.ErrInvalidToken, err)
+	suite.mockUserRepo.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+
+	// Weak passwords are rejected before the token is looked up
+	err = suite.authService.ResetPassword(ctx, domain.PasswordResetConfirmRequest{Token: "used-token", NewPassword: "short"})
+	assert.Equal(suite.T(), domain.ErrInvalidPassword, err)
+}
+
+func (suite *AuthServiceTestSuite) TestVerifyEmail() {
+	ctx := context.Background()
+	user := &domain.User{ID: 1, Email: "test@example.com", IsActive: true}
+	suite.mockConfigRepo.On("GetJWTSecret").Return("test-secret")
+	suite.mockUserRepo.On("GetByID", ctx, uint(1)).Return(user, nil)
+	suite.mockUserRepo.On("Update", ctx, user).Return(nil).Once()
+
+	token := suite.authService.signVerificationToken(user, time.Now().Add(emailVerificationTTL))
+	response, err := suite.authService.VerifyEmail(ctx, token)
+
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), response.EmailVerified)
+	assert.NotNil(suite.T(), user.VerifiedAt)
+
+	// Following the link again keeps the first timestamp
+	verifiedAt := *user.VerifiedAt
+	_, err = suite.authService.VerifyEmail(ctx, token)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), verifiedAt, *user.VerifiedAt)
+	suite.mockUserRepo.AssertNumberOfCalls(suite.T(), "Update", 1)
+}
+
+func (suite *AuthServiceTestSuite) TestVerifyEmail_RejectsBadTokens() {
+	ctx := context.Background()
+	user := &domain.User{ID: 1, Email: "test@example.com", IsActive: true}
+	suite.mockConfigRepo.On("GetJWTSecret").Return("test-secret")
+	suite.mockUserRepo.On("GetByID", ctx, uint(1)).Return(user, nil)
+
+	expired := suite.authService.signVerificationToken(user, time.Now().Add(-time.Minute))
+	_, err := suite.authService.VerifyEmail(ctx, expired)
+	assert.Equal(suite.T(), domain.ErrExpiredToken, err)
+
+	// Tokens are bound to the address they were sent to
+	valid := suite.authService.signVerificationToken(user, time.Now().Add(time.Hour))
+	user.Email = "changed@example.com"
+	_, err = suite.authService.VerifyEmail(ctx, valid)
+	assert.Equal(suite.T(), domain.ErrInvalidToken, err)
+
+	_, err = suite.authService.VerifyEmail(ctx, "not-a-token")
+	assert.Equal(suite.T(), domain.ErrInvalidToken, err)
+	suite.mockUserRepo.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+}
+
+func (suite *AuthServiceTestSuite) TestResendVerificationEmail() {
+	ctx := context.Background()
+	user := &domain.User{ID: 1, Email: "test@example.com", IsActive: true}
+	suite.mockConfigRepo.On("GetJWTSecret").Return("test-secret")
+	suite.mockUserRepo.On("GetByID", ctx, uint(1)).Return(user, nil)
+	suite.mockCacheRepo.On("IsRateLimited", ctx, "email_verification_rate:1", int64(maxVerificationEmails), verificationEmailWindow).Return(false, nil).Once()
+	suite.mockCacheRepo.On("IncrementRateLimit", ctx, "email_verification_rate:1", verificationEmailWindow).Return(int64(1), nil)
+	suite.mockNotifier.On("SendWelcomeEmail", ctx, user, mock.AnythingOfType("string")).Return(nil)
+
+	assert.NoError(suite.T(), suite.authService.ResendVerificationEmail(ctx, 1))
+
+	suite.mockCacheRepo.On("IsRateLimited", ctx, "email_verification_rate:1", int64(maxVerificationEmails), verificationEmailWindow).Return(true, nil)
+	assert.Equal(suite.T(), domain.ErrTooManyRequests, suite.authService.ResendVerificationEmail(ctx, 1))
+
+	now := time.Now()
+	user.VerifiedAt = &now
+	assert.Equal(suite.T(), domain.ErrEmailAlreadyVerified, suite.authService.ResendVerificationEmail(ctx, 1))
+	suite.mockNotifier.AssertNumberOfCalls(suite.T(), "SendWelcomeEmail", 1)
 }
 
 // Mock implementations
@@ -247,6 +572,11 @@ func (m *MockUserRepository) Delete(ctx context.Context, id uint) error {
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) AdvanceTOTPStep(ctx context.Context, id uint, step int64) (bool, error) {
+	args := m.Called(ctx, id, step)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *MockUserRepository) Exists(ctx context.Context, email string) (bool, error) {
 	args := m.Called(ctx, email)
 	return args.Bool(0), args.Error(1)
@@ -257,11 +587,92 @@ func (m *MockUserRepository) List(ctx context.Context, offset, limit int) ([]*do
 	return args.Get(0).([]*domain.User), args.Get(1).(int64), args.Error(2)
 }
 
+func (m *MockUserRepository) Search(ctx context.Context, filter domain.UserFilter, offset, limit int) ([]*domain.User, int64, error) {
+	args := m.Called(ctx, filter, offset, limit)
+	return args.Get(0).([]*domain.User), args.Get(1).(int64), args.Error(2)
+}
+
 func (m *MockUserRepository) GetUserStats(ctx context.Context, userID uint) (*domain.UserStats, error) {
 	args := m.Called(ctx, userID)
 	return args.Get(0).(*domain.UserStats), args.Error(1)
 }
 
+type MockSessionRepository struct {
+	mock.Mock
+}
+
+func (m *MockSessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	args := m.Called(ctx, session)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) GetByID(ctx context.Context, id uint) (*domain.Session, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) GetByFamilyID(ctx context.Context, familyID string) (*domain.Session, error) {
+	args := m.Called(ctx, familyID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) ListActiveByUser(ctx context.Context, userID uint, now time.Time) ([]*domain.Session, error) {
+	args := m.Called(ctx, userID, now)
+	return args.Get(0).([]*domain.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) Rotate(ctx context.Context, session *domain.Session, previousHash string) (bool, error) {
+	args := m.Called(ctx, session, previousHash)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockSessionRepository) Revoke(ctx context.Context, id uint, reason string) error {
+	args := m.Called(ctx, id, reason)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) RevokeAllByUser(ctx context.Context, userID uint, reason string) error {
+	args := m.Called(ctx, userID, reason)
+	return args.Error(0)
+}
+
+type MockRecoveryCodeRepository struct {
+	mock.Mock
+}
+
+func (m *MockRecoveryCodeRepository) ReplaceForUser(ctx context.Context, userID uint, codeHashes []string) error {
+	args := m.Called(ctx, userID, codeHashes)
+	return args.Error(0)
+}
+
+func (m *MockRecoveryCodeRepository) Consume(ctx context.Context, userID uint, codeHash string) (bool, error) {
+	args := m.Called(ctx, userID, codeHash)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRecoveryCodeRepository) DeleteByUser(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+type MockQRCodeProvider struct {
+	mock.Mock
+}
+
+func (m *MockQRCodeProvider) GenerateQRCode(url string, options domain.QRGenerationOptions) ([]byte, error) {
+	args := m.Called(url, options)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]byte), args.Error(1)
+}
+
 type MockCacheService struct {
 	mock.Mock
 }
@@ -276,6 +687,11 @@ func (m *MockCacheService) Get(ctx context.Context, key string) (string, error)
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockCacheService) GetDel(ctx context.Context, key string) (string, error) {
+	args := m.Called(ctx, key)
+	return args.String(0), args.Error(1)
+}
+
 func (m *MockCacheService) Delete(ctx context.Context, key string) error {
 	args := m.Called(ctx, key)
 	return args.Error(0)
@@ -295,7 +711,8 @@ func (m *MockCacheService) GetAnalyticsData(ctx context.Context, key string) (in
 
 // Additional methods to satisfy the ports.CacheService interface
 func (m *MockCacheService) Del(ctx context.Context, keys ...string) error {
-	return nil
+	args := m.Called(ctx, keys)
+	return args.Error(0)
 }
 
 func (m *MockCacheService) Exists(ctx context.Context, key string) (bool, error) {
@@ -358,11 +775,13 @@ func (m *MockCacheService) InvalidateURL(ctx context.Context, shortCode string)
 }
 
 func (m *MockCacheService) IsRateLimited(ctx context.Context, key string, limit int64, window time.Duration) (bool, error) {
-	return false, nil
+	args := m.Called(ctx, key, limit, window)
+	return args.Bool(0), args.Error(1)
 }
 
 func (m *MockCacheService) IncrementRateLimit(ctx context.Context, key string, window time.Duration) (int64, error) {
-	return 0, nil
+	args := m.Called(ctx, key, window)
+	return args.Get(0).(int64), args.Error(1)
 }
 
 func (m *MockCacheService) SetSession(ctx context.Context, token string, userID uint, expiration time.Duration) error {
@@ -376,7 +795,13 @@ func (m *MockCacheService) GetSession(ctx context.Context, token string) (uint,
 }
 
 func (m *MockCacheService) InvalidateSession(ctx context.Context, token string) error {
-	return nil
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockCacheService) InvalidateUserSessions(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
 }
 
 func (m *MockCacheService) CacheClickCount(ctx context.Context, shortCode string, count int64) error {
@@ -420,13 +845,13 @@ type MockJWTService struct {
 	mock.Mock
 }
 
-func (m *MockJWTService) GenerateAccessToken(userID uint, email string) (string, error) {
-	args := m.Called(userID, email)
+func (m *MockJWTService) GenerateAccessToken(userID uint, email, role, sessionID string) (string, error) {
+	args := m.Called(userID, email, role, sessionID)
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockJWTService) GenerateRefreshToken(userID uint) (string, error) {
-	args := m.Called(userID)
+func (m *MockJWTService) GenerateRefreshToken(userID uint, sessionID string) (string, error) {
+	args := m.Called(userID, sessionID)
 	return args.String(0), args.Error(1)
 }
 
@@ -440,6 +865,55 @@ func (m *MockJWTService) ValidateRefreshToken(token string) (*domain.TokenClaims
 	return args.Get(0).(*domain.TokenClaims), args.Error(1)
 }
 
+func (m *MockJWTService) PublicKeys() *domain.JSONWebKeySet {
+	args := m.Called()
+	return args.Get(0).(*domain.JSONWebKeySet)
+}
+
+type MockNotificationService struct {
+	mock.Mock
+}
+
+func (m *MockNotificationService) SendWelcomeEmail(ctx context.Context, user *domain.User, verificationToken string) error {
+	args := m.Called(ctx, user, verificationToken)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) SendPasswordResetEmail(ctx context.Context, user *domain.User, resetToken string) error {
+	args := m.Called(ctx, user, resetToken)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) SendPasswordChangedNotification(ctx context.Context, user *domain.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) SendWorkspaceInvitation(ctx context.Context, email string, inviter *domain.User, workspace *domain.Workspace, token string) error {
+	args := m.Called(ctx, email, inviter, workspace, token)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) SendAnalyticsDigest(ctx context.Context, user *domain.User, digest *domain.AnalyticsDigest) error {
+	args := m.Called(ctx, user, digest)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) SendClickAlert(ctx context.Context, user *domain.User, alert *domain.ClickAlert) error {
+	args := m.Called(ctx, user, alert)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) SendMaintenanceNotification(ctx context.Context, users []*domain.User, message string) error {
+	args := m.Called(ctx, users, message)
+	return args.Error(0)
+}
+
+func (m *MockNotificationService) SendSecurityAlert(ctx context.Context, user *domain.User, alert *domain.SecurityAlert) error {
+	args := m.Called(ctx, user, alert)
+	return args.Error(0)
+}
+
 type MockConfigService struct {
 	mock.Mock
 }
@@ -462,4 +936,19 @@ func (m *MockConfigService) GetDatabaseURL() string {
 func (m *MockConfigService) GetRedisURL() string {
 	args := m.Called()
 	return args.String(0)
+}
+
+func (m *MockConfigService) IsTwoFactorRequired() bool {
+	args := m.Called()
+	return args.Bool(0)
+}
+
+func (m *MockConfigService) GetQRBatchResultTTL() time.Duration {
+	args := m.Called()
+	return args.Get(0).(time.Duration)
+}
+
+func (m *MockConfigService) GetSessionTTL() time.Duration {
+	args := m.Called()
+	return args.Get(0).(time.Duration)
 }
\ No newline at end of file
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+type clickBatchWriter struct {
+	clickRepo  ports.ClickRepository
+	cacheRepo  ports.CacheService
+	geoService ports.GeolocationService
+}
+
+// NewClickBatchWriter writes queued clicks. geoService may be nil, in which case
+// clicks are stored without a location.
+func NewClickBatchWriter(
+	clickRepo ports.ClickRepository,
+	cacheRepo ports.CacheService,
+	geoService ports.GeolocationService,
+) ports.ClickBatchWriter {
+	return &clickBatchWriter{
+		clickRepo:  clickRepo,
+		cacheRepo:  cacheRepo,
+		geoService: geoService,
+	}
+}
+
+func (w *clickBatchWriter) WriteBatch(ctx context.Context, clicks []*domain.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	w.locate(ctx, clicks)
+
+	// Insert all clicks with multi-row statements, counting them in the same
+	// transaction so a retried batch is never counted twice
+	if err := w.clickRepo.RecordBatch(ctx, clicks); err != nil {
+		return err
+	}
+
+	// Cache unique clicks for analytics, once per URL and IP in the batch
+	seen := make(map[string]bool)
+	for _, click := range clicks {
+		shortCodeStr := fmt.Sprintf("%d", click.ShortURLID)
+		key := shortCodeStr + "|" + click.IPAddress
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if _, err := w.cacheRepo.CacheUniqueClick(ctx, shortCodeStr, click.IPAddress); err != nil {
+			fmt.Printf("Failed to cache unique click: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// locate fills in country, region and city from each click's IP, looking every
+// address up once per batch. Clicks the redirect already located are left alone,
+// and clicks are still written when the lookup fails.
+func (w *clickBatchWriter) locate(ctx context.Context, clicks []*domain.Click) {
+	if w.geoService == nil {
+		return
+	}
+
+	ips := make([]string, 0, len(clicks))
+	for _, click := range clicks {
+		if click.IPAddress != "" && click.Country == "" {
+			ips = append(ips, click.IPAddress)
+		}
+	}
+	if len(ips) == 0 {
+		return
+	}
+
+	locations, err := w.geoService.GetLocationsBatch(ctx, ips)
+	if err != nil {
+		fmt.Printf("Failed to geolocate clicks: %v", err)
+		return
+	}
+	for _, click := range clicks {
+		location, ok := locations[click.IPAddress]
+		if !ok || click.Country != "" {
+			continue
+		}
+		click.Country = location.CountryCode
+		if location.Region != "" {
+			click.Region = location.Region
+		}
+		if location.City != "" {
+			click.City = location.City
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"url-shortener/internal/core/domain"
+)
+
+type ClickBatchWriterTestSuite struct {
+	suite.Suite
+	writer        *clickBatchWriter
+	mockClickRepo *MockClickRepository
+	mockCacheRepo *MockCacheService
+	mockGeo       *MockGeolocationService
+}
+
+type MockGeolocationService struct {
+	mock.Mock
+}
+
+func (m *MockGeolocationService) GetLocationFromIP(ctx context.Context, ipAddress string) (*domain.GeoLocation, error) {
+	args := m.Called(ctx, ipAddress)
+	return args.Get(0).(*domain.GeoLocation), args.Error(1)
+}
+
+func (m *MockGeolocationService) GetLocationsBatch(ctx context.Context, ipAddresses []string) (map[string]*domain.GeoLocation, error) {
+	args := m.Called(ctx, ipAddresses)
+	return args.Get(0).(map[string]*domain.GeoLocation), args.Error(1)
+}
+
+func (m *MockGeolocationService) ValidateLocation(ctx context.Context, location *domain.GeoLocation) error {
+	args := m.Called(ctx, location)
+	return args.Error(0)
+}
+
+func (m *MockGeolocationService) GetCountryCode(ctx context.Context, countryName string) (string, error) {
+	args := m.Called(ctx, countryName)
+	return args.String(0), args.Error(1)
+}
+
+func TestClickBatchWriterSuite(t *testing.T) {
+	suite.Run(t, new(ClickBatchWriterTestSuite))
+}
+
+func (suite *ClickBatchWriterTestSuite) SetupTest() {
+	suite.mockClickRepo = &MockClickRepository{}
+	suite.mockCacheRepo = &MockCacheService{}
+	suite.mockGeo = &MockGeolocationService{}
+
+	suite.writer = &clickBatchWriter{
+		clickRepo:  suite.mockClickRepo,
+		cacheRepo:  suite.mockCacheRepo,
+		geoService: suite.mockGeo,
+	}
+}
+
+func (suite *ClickBatchWriterTestSuite) TestWriteBatch_AggregatesCounts() {
+	ctx := context.Background()
+	clicks := []*domain.Click{
+		{ShortURLID: 1, IPAddress: "10.0.0.1"},
+		{ShortURLID: 1, IPAddress: "10.0.0.1"},
+		{ShortURLID: 1, IPAddress: "10.0.0.2"},
+		{ShortURLID: 2, IPAddress: "10.0.0.1"},
+	}
+
+	// Mock expectations
+	suite.mockGeo.On("GetLocationsBatch", ctx, mock.Anything).Return(map[string]*domain.GeoLocation{}, nil)
+	suite.mockClickRepo.On("RecordBatch", ctx, clicks).Return(nil)
+	suite.mockCacheRepo.On("CacheUniqueClick", ctx, "1", "10.0.0.1").Return(true, nil).Once()
+	suite.mockCacheRepo.On("CacheUniqueClick", ctx, "1", "10.0.0.2").Return(true, nil).Once()
+	suite.mockCacheRepo.On("CacheUniqueClick", ctx, "2", "10.0.0.1").Return(true, nil).Once()
+
+	// Execute
+	err := suite.writer.WriteBatch(ctx, clicks)
+
+	// Assert
+	assert.NoError(suite.T(), err)
+
+	suite.mockClickRepo.AssertExpectations(suite.T())
+	suite.mockCacheRepo.AssertExpectations(suite.T())
+}
+
+func (suite *ClickBatchWriterTestSuite) TestWriteBatch_InsertFails() {
+	ctx := context.Background()
+	clicks := []*domain.Click{{ShortURLID: 1}}
+
+	suite.mockGeo.On("GetLocationsBatch", ctx, mock.Anything).Return(map[string]*domain.GeoLocation{}, nil)
+	suite.mockClickRepo.On("RecordBatch", ctx, clicks).Return(errors.New("connection reset"))
+
+	err := suite.writer.WriteBatch(ctx, clicks)
+
+	assert.Error(suite.T(), err)
+	suite.mockCacheRepo.AssertNotCalled(suite.T(), "CacheUniqueClick", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *ClickBatchWriterTestSuite) TestWriteBatch_Empty() {
+	err := suite.writer.WriteBatch(context.Background(), nil)
+
+	assert.NoError(suite.T(), err)
+	suite.mockClickRepo.AssertNotCalled(suite.T(), "RecordBatch", mock.Anything, mock.Anything)
+}
+
+func (suite *ClickBatchWriterTestSuite) TestWriteBatch_Geolocates() {
+	ctx := context.Background()
+	clicks := []*domain.Click{
+		{ShortURLID: 1, IPAddress: "81.2.69.142", Region: "Unknown", City: "Unknown"},
+		{ShortURLID: 1, IPAddress: "10.0.0.1", Region: "Unknown", City: "Unknown"},
+		{ShortURLID: 2, IPAddress: "81.2.69.142", Region: "Unknown", City: "Unknown"},
+		// Already located on the redirect path
+		{ShortURLID: 2, IPAddress: "5.9.0.1", Country: "DE", Region: "Unknown", City: "Berlin"},
+	}
+
+	suite.mockGeo.On("GetLocationsBatch", ctx, []string{"81.2.69.142", "10.0.0.1", "81.2.69.142"}).Return(map[string]*domain.GeoLocation{
+		"81.2.69.142": {CountryCode: "GB", City: "London"},
+	}, nil)
+	suite.mockClickRepo.On("RecordBatch", ctx, clicks).Return(nil)
+	suite.mockCacheRepo.On("CacheUniqueClick", ctx, mock.Anything, mock.Anything).Return(true, nil)
+
+	err := suite.writer.WriteBatch(ctx, clicks)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "GB", clicks[0].Country)
+	assert.Equal(suite.T(), "London", clicks[0].City)
+	assert.Equal(suite.T(), "Unknown", clicks[0].Region)
+	assert.Equal(suite.T(), "GB", clicks[2].Country)
+	assert.Equal(suite.T(), "", clicks[1].Country)
+	assert.Equal(suite.T(), "Unknown", clicks[1].City)
+	assert.Equal(suite.T(), "DE", clicks[3].Country)
+	assert.Equal(suite.T(), "Berlin", clicks[3].City)
+}
+
+func (suite *ClickBatchWriterTestSuite) TestWriteBatch_GeolocationFails() {
+	ctx := context.Background()
+	clicks := []*domain.Click{{ShortURLID: 1, IPAddress: "81.2.69.142"}}
+
+	// The clicks are still written, just without a location
+	suite.mockGeo.On("GetLocationsBatch", ctx, mock.Anything).Return(map[string]*domain.GeoLocation(nil), errors.New("timeout"))
+	suite.mockClickRepo.On("RecordBatch", ctx, clicks).Return(nil)
+	suite.mockCacheRepo.On("CacheUniqueClick", ctx, "1", "81.2.69.142").Return(true, nil)
+
+	err := suite.writer.WriteBatch(ctx, clicks)
+
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), clicks[0].Country)
+	suite.mockClickRepo.AssertExpectations(suite.T())
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-shortener/internal/core/domain"
+)
+
+const (
+	emailVerificationTTL = 48 * time.Hour
+
+	maxVerificationEmails   = 3
+	verificationEmailWindow = time.Hour
+)
+
+// VerifyEmail marks the account in a verification token as verified. Verifying
+// twice is harmless; the original timestamp is kept.
+func (s *authService) VerifyEmail(ctx context.Context, token string) (*domain.UserResponse, error) {
+	userID, expiresAt, ok := parseVerificationToken(token)
+	if !ok {
+		return nil, domain.ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if err == domain.ErrUserNotFound {
+			return nil, domain.ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// The signature covers the email, so changing it invalidates older links
+	if !hmac.Equal([]byte(token), []byte(s.signVerificationToken(user, expiresAt))) {
+		return nil, domain.ErrInvalidToken
+	}
+	if time.Now().After(expiresAt) {
+		return nil, domain.ErrExpiredToken
+	}
+
+	if user.VerifiedAt == nil {
+		now := time.Now()
+		user.VerifiedAt = &now
+		user.UpdatedAt = now
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return nil, fmt.Errorf("failed to verify email: %w", err)
+		}
+	}
+
+	return user.ToResponse(), nil
+}
+
+// ResendVerificationEmail sends a fresh verification link, a few times per hour at most
+func (s *authService) ResendVerificationEmail(ctx context.Context, userID uint) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.IsVerified() {
+		return domain.ErrEmailAlreadyVerified
+	}
+
+	rateKey := fmt.Sprintf("email_verification_rate:%d", user.ID)
+	limited, err := s.cacheRepo.IsRateLimited(ctx, rateKey, maxVerificationEmails, verificationEmailWindow)
+	if err != nil {
+		fmt.Printf("Failed to check verification email rate limit: %v\n", err)
+	}
+	if limited {
+		return domain.ErrTooManyRequests
+	}
+	if _, err := s.cacheRepo.IncrementRateLimit(ctx, rateKey, verificationEmailWindow); err != nil {
+		fmt.Printf("Failed to count verification email: %v\n", err)
+	}
+
+	token := s.signVerificationToken(user, time.Now().Add(emailVerificationTTL))
+	if err := s.notifier.SendWelcomeEmail(ctx, user, token); err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+	return nil
+}
+
+// signVerificationToken returns "<userID>.<expiryUnix>.<signature>", where the
+// signature is an HMAC over both values and the user's current email address.
+func (s *authService) signVerificationToken(user *domain.User, expiresAt time.Time) string {
+	payload := fmt.Sprintf("%d.%d", user.ID, expiresAt.Unix())
+
+	mac := hmac.New(sha256.New, []byte(s.configRepo.GetJWTSecret()))
+	mac.Write([]byte("email-verification:" + payload + ":" + strings.ToLower(user.Email)))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func parseVerificationToken(token string) (uint, time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, time.Time{}, false
+	}
+	userID, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	return uint(userID), time.Unix(expiry, 0), true
+}
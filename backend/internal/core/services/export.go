@@ -0,0 +1,132 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"url-shortener/internal/core/domain"
+)
+
+// exportEncoder writes export rows to a buffered stream. Flush pushes buffered
+// rows to the underlying writer; Close terminates the document and flushes.
+type exportEncoder interface {
+	Encode(row *domain.ClickExportRow) error
+	Flush() error
+	Close() error
+}
+
+func newExportEncoder(format string, w io.Writer) (exportEncoder, error) {
+	buf := bufio.NewWriter(w)
+	switch format {
+	case domain.ExportFormatCSV:
+		return newCSVExportEncoder(buf)
+	case domain.ExportFormatJSON:
+		return &jsonArrayExportEncoder{buf: buf}, nil
+	case domain.ExportFormatJSONLines:
+		return &jsonLinesExportEncoder{buf: buf, enc: json.NewEncoder(buf)}, nil
+	default:
+		return nil, domain.ErrInvalidExportFormat
+	}
+}
+
+type csvExportEncoder struct {
+	writer *csv.Writer
+	buf    *bufio.Writer
+}
+
+func newCSVExportEncoder(buf *bufio.Writer) (*csvExportEncoder, error) {
+	e := &csvExportEncoder{writer: csv.NewWriter(buf), buf: buf}
+	if err := e.writer.Write(domain.ExportColumns); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *csvExportEncoder) Encode(row *domain.ClickExportRow) error {
+	values := row.Values()
+	for i, value := range values {
+		values[i] = escapeCSVFormula(value)
+	}
+	return e.writer.Write(values)
+}
+
+// escapeCSVFormula stops spreadsheets from running titles, referrers and other
+// visitor-supplied values as formulas by quoting them as text
+func escapeCSVFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func (e *csvExportEncoder) Flush() error {
+	e.writer.Flush()
+	if err := e.writer.Error(); err != nil {
+		return err
+	}
+	return e.buf.Flush()
+}
+
+func (e *csvExportEncoder) Close() error {
+	return e.Flush()
+}
+
+type jsonLinesExportEncoder struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *jsonLinesExportEncoder) Encode(row *domain.ClickExportRow) error {
+	return e.enc.Encode(row)
+}
+
+func (e *jsonLinesExportEncoder) Flush() error {
+	return e.buf.Flush()
+}
+
+func (e *jsonLinesExportEncoder) Close() error {
+	return e.buf.Flush()
+}
+
+// jsonArrayExportEncoder writes rows as elements of one JSON array, one per line
+type jsonArrayExportEncoder struct {
+	buf   *bufio.Writer
+	count int
+}
+
+func (e *jsonArrayExportEncoder) Encode(row *domain.ClickExportRow) error {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	separator := ",\n"
+	if e.count == 0 {
+		separator = "[\n"
+	}
+	e.count++
+
+	if _, err := e.buf.WriteString(separator); err != nil {
+		return err
+	}
+	_, err = e.buf.Write(data)
+	return err
+}
+
+func (e *jsonArrayExportEncoder) Flush() error {
+	return e.buf.Flush()
+}
+
+func (e *jsonArrayExportEncoder) Close() error {
+	closing := "\n]\n"
+	if e.count == 0 {
+		closing = "[]\n"
+	}
+	if _, err := e.buf.WriteString(closing); err != nil {
+		return err
+	}
+	return e.buf.Flush()
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"url-shortener/internal/core/domain"
+)
+
+const (
+	// Wrong guesses allowed per visitor before unlocking is refused. Once a link has
+	// seen maxUnlockFailuresPerLink wrong guesses, a visitor who already guessed
+	// wrong gets no more tries, but everyone still gets a first try.
+	maxUnlockAttemptsPerClient   = 5
+	maxUnlockAttemptsUnderAttack = 1
+	maxUnlockFailuresPerLink     = 100
+	unlockAttemptWindow          = 15 * time.Minute
+
+	linkUnlockTTL = time.Hour
+)
+
+func (s *urlService) UnlockURL(ctx context.Context, shortCode, password, clientIP string) (*domain.LinkUnlock, error) {
+	shortURL, err := s.urlRepo.GetActiveByShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	if shortURL.Password == nil {
+		return s.issueUnlockToken(shortURL), nil
+	}
+
+	// Throttle wrong guesses per visitor, more tightly while the link is being
+	// guessed at from many addresses. Only failures are counted against the link,
+	// so nobody can lock its owner's visitors out of it.
+	clientKey := fmt.Sprintf("link_unlock:%d:%s", shortURL.ID, clientIP)
+	linkKey := fmt.Sprintf("link_unlock:%d", shortURL.ID)
+	clientLimit := int64(maxUnlockAttemptsPerClient)
+	if s.unlockThrottled(ctx, linkKey, maxUnlockFailuresPerLink) {
+		clientLimit = maxUnlockAttemptsUnderAttack
+	}
+	if s.unlockThrottled(ctx, clientKey, clientLimit) {
+		return nil, domain.ErrTooManyRequests
+	}
+
+	if !s.checkPassword(ctx, shortURL, password) {
+		for _, key := range []string{clientKey, linkKey} {
+			if _, err := s.cacheRepo.IncrementRateLimit(ctx, key, unlockAttemptWindow); err != nil {
+				fmt.Printf("Failed to count unlock attempt: %v", err)
+			}
+		}
+		return nil, domain.ErrInvalidPassword
+	}
+
+	if err := s.cacheRepo.Del(ctx, clientKey); err != nil {
+		fmt.Printf("Failed to reset unlock attempts: %v", err)
+	}
+	return s.issueUnlockToken(shortURL), nil
+}
+
+// CheckUnlockToken verifies a token from UnlockURL. Tokens are bound to the link's
+// current password, so changing it locks out everyone who had unlocked it.
+func (s *urlService) CheckUnlockToken(ctx context.Context, shortURL *domain.ShortURL, token string) bool {
+	if shortURL.Password == nil {
+		return true
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil || uint(id) != shortURL.ID {
+		return false
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() >= expires {
+		return false
+	}
+
+	expected := s.signUnlockToken(shortURL, parts[0]+"."+parts[1])
+	return hmac.Equal([]byte(parts[2]), []byte(expected))
+}
+
+func (s *urlService) issueUnlockToken(shortURL *domain.ShortURL) *domain.LinkUnlock {
+	expiresAt := time.Now().Add(linkUnlockTTL)
+	payload := fmt.Sprintf("%d.%d", shortURL.ID, expiresAt.Unix())
+	return &domain.LinkUnlock{
+		Token:     payload + "." + s.signUnlockToken(shortURL, payload),
+		ExpiresAt: expiresAt,
+	}
+}
+
+func (s *urlService) signUnlockToken(shortURL *domain.ShortURL, payload string) string {
+	mac := hmac.New(sha256.New, []byte(s.configRepo.GetJWTSecret()))
+	mac.Write([]byte("link-unlock:" + payload + ":"))
+	if shortURL.Password != nil {
+		mac.Write([]byte(*shortURL.Password))
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// unlockThrottled fails open when the cache is unavailable, like the rate limiter
+func (s *urlService) unlockThrottled(ctx context.Context, key string, limit int64) bool {
+	limited, err := s.cacheRepo.IsRateLimited(ctx, key, limit, unlockAttemptWindow)
+	if err != nil {
+		fmt.Printf("Failed to check unlock attempts: %v", err)
+		return false
+	}
+	return limited
+}
+
+func (s *urlService) hashPassword(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// checkPassword compares against a bcrypt hash, or against the hex encoding older
+// links were stored with. A legacy match is upgraded to bcrypt on the spot.
+func (s *urlService) checkPassword(ctx context.Context, shortURL *domain.ShortURL, password string) bool {
+	stored := *shortURL.Password
+	if isBcryptHash(stored) {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil
+	}
+
+	legacy := fmt.Sprintf("%x", password)
+	if subtle.ConstantTimeCompare([]byte(legacy), []byte(stored)) != 1 {
+		return false
+	}
+
+	if hashed, err := s.hashPassword(password); err == nil {
+		shortURL.Password = &hashed
+		if err := s.urlRepo.Update(ctx, shortURL); err != nil {
+			// Keep the old value so the next attempt upgrades it instead
+			shortURL.Password = &stored
+			fmt.Printf("Failed to upgrade link password: %v", err)
+		}
+	}
+	return true
+}
+
+func isBcryptHash(value string) bool {
+	return strings.HasPrefix(value, "$2a$") || strings.HasPrefix(value, "$2b$") || strings.HasPrefix(value, "$2y$")
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"url-shortener/internal/core/domain"
+)
+
+// oidcLoginState is kept in the cache while the user is at the identity provider
+type oidcLoginState struct {
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// StartOIDCLogin returns the provider URL to send the browser to. The state,
+// nonce and PKCE verifier are remembered until the callback or OIDCLoginTTL.
+func (s *authService) StartOIDCLogin(ctx context.Context) (*domain.OIDCLoginResponse, error) {
+	if s.oidcProvider == nil {
+		return nil, domain.ErrOIDCNotConfigured
+	}
+
+	state, err := generateOIDCSecret()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := generateOIDCSecret()
+	if err != nil {
+		return nil, err
+	}
+	codeVerifier, err := generateOIDCSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := json.Marshal(oidcLoginState{Nonce: nonce, CodeVerifier: codeVerifier})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode sign-in state: %w", err)
+	}
+	if err := s.cacheRepo.Set(ctx, oidcStateKey(state), string(value), domain.OIDCLoginTTL); err != nil {
+		return nil, fmt.Errorf("failed to store sign-in state: %w", err)
+	}
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	authURL, err := s.oidcProvider.AuthCodeURL(ctx, state, nonce, base64.RawURLEncoding.EncodeToString(challenge[:]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build authorization URL: %w", err)
+	}
+
+	return &domain.OIDCLoginResponse{
+		AuthorizationURL: authURL,
+		State:            state,
+		ExpiresIn:        int(domain.OIDCLoginTTL.Seconds()),
+	}, nil
+}
+
+// CompleteOIDCLogin redeems the code from the provider's redirect and signs in
+// the linked user, linking or creating one on their first single sign-on
+func (s *authService) CompleteOIDCLogin(ctx context.Context, req domain.OIDCCallbackRequest, meta domain.SessionMetadata) (*domain.AuthResponse, error) {
+	if s.oidcProvider == nil {
+		return nil, domain.ErrOIDCNotConfigured
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Each state is good for a single callback, even when two arrive at once
+	value, err := s.cacheRepo.GetDel(ctx, oidcStateKey(req.State))
+	if err != nil || value == "" {
+		return nil, domain.ErrOIDCStateInvalid
+	}
+
+	var state oidcLoginState
+	if err := json.Unmarshal([]byte(value), &state); err != nil {
+		return nil, domain.ErrOIDCStateInvalid
+	}
+
+	identity, err := s.oidcProvider.Exchange(ctx, req.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		// The details are only useful to operators
+		fmt.Printf("OIDC code exchange failed: %v\n", err)
+		return nil, domain.ErrOIDCLoginFailed
+	}
+
+	user, err := s.resolveOIDCUser(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsActive {
+		return nil, domain.ErrUserInactive
+	}
+
+	// Two-factor on the account still applies to single sign-on
+	if user.IsTwoFactorEnabled() {
+		return s.startMFAChallenge(ctx, user)
+	}
+
+	return s.completeLogin(ctx, user, meta)
+}
+
+// resolveOIDCUser finds the user linked to the provider account. On the first
+// sign-in the account is linked to the user with the same email, or a user is
+// created; both need an email the provider has verified. A user who never
+// verified their address is not linked, since whoever registered it may not own
+// it and their password would keep working.
+func (s *authService) resolveOIDCUser(ctx context.Context, identity *domain.OIDCIdentity) (*domain.User, error) {
+	now := time.Now()
+
+	link, err := s.identityRepo.GetByIssuerSubject(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		user, err := s.userRepo.GetByID(ctx, link.UserID)
+		if err != nil {
+			if err == domain.ErrUserNotFound {
+				return nil, domain.ErrOIDCLoginFailed
+			}
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+
+		link.Email = identity.Email
+		link.LastLoginAt = &now
+		link.UpdatedAt = now
+		if err := s.identityRepo.Update(ctx, link); err != nil {
+			// Log error but don't fail the login
+			fmt.Printf("Failed to update linked identity: %v\n", err)
+		}
+		return user, nil
+	}
+	if err != domain.ErrIdentityNotFound {
+		return nil, fmt.Errorf("failed to get linked identity: %w", err)
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, domain.ErrOIDCEmailNotVerified
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, identity.Email)
+	switch {
+	case err == nil:
+		if user.VerifiedAt == nil {
+			return nil, domain.ErrOIDCAccountUnverified
+		}
+	case err == domain.ErrUserNotFound:
+		if user, err = s.createOIDCUser(ctx, identity, now); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if err := s.identityRepo.Create(ctx, &domain.UserIdentity{
+		UserID:      user.ID,
+		Issuer:      identity.Issuer,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		LastLoginAt: &now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	return user, nil
+}
+
+// createOIDCUser creates a user without a password; one can be set later
+// through a password reset
+func (s *authService) createOIDCUser(ctx context.Context, identity *domain.OIDCIdentity, now time.Time) (*domain.User, error) {
+	user := &domain.User{
+		Email:      identity.Email,
+		FirstName:  identity.GivenName,
+		LastName:   identity.FamilyName,
+		IsActive:   true,
+		Role:       domain.RoleUser,
+		VerifiedAt: &now,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	recordAudit(ctx, s.auditRepo, user.ID, domain.AuditUserRegistered, domain.AuditTargetUser, user.ID, domain.DiffAudit(nil, user.AuditSnapshot()))
+
+	if err := s.notifier.SendWelcomeEmail(ctx, user, ""); err != nil {
+		// Log error but don't fail the sign-in
+		fmt.Printf("Failed to send welcome email: %v\n", err)
+	}
+	return user, nil
+}
+
+func generateOIDCSecret() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate sign-in state: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func oidcStateKey(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return "oidc_state:" + hex.EncodeToString(sum[:])
+}
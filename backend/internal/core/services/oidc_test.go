@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"url-shortener/internal/core/domain"
+)
+
+const testIssuer = "https://idp.example.com"
+
+func (suite *AuthServiceTestSuite) TestStartOIDCLogin() {
+	ctx := context.Background()
+	suite.mockCacheRepo.On("Set", ctx, mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "oidc_state:")
+	}), mock.AnythingOfType("string"), domain.OIDCLoginTTL).Return(nil)
+	suite.mockOIDC.On("AuthCodeURL", ctx, mock.Anything, mock.Anything, mock.Anything).Return(testIssuer+"/authorize?state=abc", nil)
+
+	response, err := suite.authService.StartOIDCLogin(ctx)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), testIssuer+"/authorize?state=abc", response.AuthorizationURL)
+	assert.NotEmpty(suite.T(), response.State)
+
+	// The state is stored hashed, and the provider gets the S256 challenge of the stored verifier
+	setCall := suite.mockCacheRepo.Calls[0].Arguments
+	assert.Equal(suite.T(), oidcStateKey(response.State), setCall.String(1))
+
+	var state oidcLoginState
+	assert.NoError(suite.T(), json.Unmarshal([]byte(setCall.String(2)), &state))
+	sum := sha256.Sum256([]byte(state.CodeVerifier))
+
+	urlCall := suite.mockOIDC.Calls[0].Arguments
+	assert.Equal(suite.T(), response.State, urlCall.String(1))
+	assert.Equal(suite.T(), state.Nonce, urlCall.String(2))
+	assert.Equal(suite.T(), base64.RawURLEncoding.EncodeToString(sum[:]), urlCall.String(3))
+}
+
+func (suite *AuthServiceTestSuite) TestStartOIDCLogin_NotConfigured() {
+	suite.authService.oidcProvider = nil
+
+	_, err := suite.authService.StartOIDCLogin(context.Background())
+
+	assert.Equal(suite.T(), domain.ErrOIDCNotConfigured, err)
+}
+
+// expectOIDCCallback sets up a pending sign-in whose code exchange returns identity
+func (suite *AuthServiceTestSuite) expectOIDCCallback(ctx context.Context, req domain.OIDCCallbackRequest, identity *domain.OIDCIdentity) {
+	value, _ := json.Marshal(oidcLoginState{Nonce: "nonce", CodeVerifier: "verifier"})
+	suite.mockCacheRepo.On("GetDel", ctx, oidcStateKey(req.State)).Return(string(value), nil).Once()
+	suite.mockOIDC.On("Exchange", ctx, req.Code, "verifier", "nonce").Return(identity, nil)
+}
+
+func (suite *AuthServiceTestSuite) expectTokens(ctx context.Context, user *domain.User) {
+	suite.mockUserRepo.On("Update", ctx, mock.AnythingOfType("*domain.User")).Return(nil)
+	suite.mockJWTRepo.On("GenerateAccessToken", user.ID, user.Email, domain.RoleUser, mock.AnythingOfType("string")).Return("access_token", nil)
+	suite.mockJWTRepo.On("GenerateRefreshToken", user.ID, mock.AnythingOfType("string")).Return("refresh_token", nil)
+	suite.mockSessions.On("Create", ctx, mock.AnythingOfType("*domain.Session")).Return(nil)
+}
+
+func (suite *AuthServiceTestSuite) TestCompleteOIDCLogin_LinkedIdentity() {
+	ctx := context.Background()
+	req := domain.OIDCCallbackRequest{Code: "code", State: "state"}
+	identity := &domain.OIDCIdentity{Issuer: testIssuer, Subject: "sub-1", Email: "new-address@example.com", EmailVerified: true}
+	user := &domain.User{ID: 1, Email: "test@example.com", IsActive: true}
+	link := &domain.UserIdentity{ID: 7, UserID: user.ID, Issuer: testIssuer, Subject: "sub-1", Email: "test@example.com"}
+
+	suite.expectOIDCCallback(ctx, req, identity)
+	suite.mockIdentities.On("GetByIssuerSubject", ctx, testIssuer, "sub-1").Return(link, nil)
+	suite.mockIdentities.On("Update", ctx, link).Return(nil)
+	suite.mockUserRepo.On("GetByID", ctx, user.ID).Return(user, nil)
+	suite.expectTokens(ctx, user)
+
+	response, err := suite.authService.CompleteOIDCLogin(ctx, req, domain.SessionMetadata{})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "access_token", response.AccessToken)
+	assert.Equal(suite.T(), "new-address@example.com", link.Email)
+	assert.NotNil(suite.T(), link.LastLoginAt)
+
+	// A returning user is found by subject, not email
+	suite.mockUserRepo.AssertNotCalled(suite.T(), "GetByEmail", mock.Anything, mock.Anything)
+}
+
+func (suite *AuthServiceTestSuite) TestCompleteOIDCLogin_LinksExistingUser() {
+	ctx := context.Background()
+	req := domain.OIDCCallbackRequest{Code: "code", State: "state"}
+	identity := &domain.OIDCIdentity{Issuer: testIssuer, Subject: "sub-1", Email: "test@example.com", EmailVerified: true}
+	verifiedAt := time.Now().Add(-time.Hour)
+	user := &domain.User{ID: 1, Email: "test@example.com", IsActive: true, VerifiedAt: &verifiedAt}
+
+	suite.expectOIDCCallback(ctx, req, identity)
+	suite.mockIdentities.On("GetByIssuerSubject", ctx, testIssuer, "sub-1").Return(nil, domain.ErrIdentityNotFound)
+	suite.mockUserRepo.On("GetByEmail", ctx, "test@example.com").Return(user, nil)
+	suite.mockIdentities.On("Create", ctx, mock.MatchedBy(func(link *domain.UserIdentity) bool {
+		return link.UserID == user.ID && link.Issuer == testIssuer && link.Subject == "sub-1"
+	})).Return(nil)
+	suite.expectTokens(ctx, user)
+
+	response, err := suite.authService.CompleteOIDCLogin(ctx, req, domain.SessionMetadata{})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "access_token", response.AccessToken)
+	suite.mockUserRepo.AssertNotCalled(suite.T(), "Create", mock.Anything, mock.Anything)
+}
+
+func (suite *AuthServiceTestSuite) TestCompleteOIDCLogin_UnverifiedAccount() {
+	ctx := context.Background()
+	req := domain.OIDCCallbackRequest{Code: "code", State: "state"}
+	identity := &domain.OIDCIdentity{Issuer: testIssuer, Subject: "sub-1", Email: "test@example.com", EmailVerified: true}
+	user := &domain.User{ID: 1, Email: "test@example.com", IsActive: true}
+
+	suite.expectOIDCCallback(ctx, req, identity)
+	suite.mockIdentities.On("GetByIssuerSubject", ctx, testIssuer, "sub-1").Return(nil, domain.ErrIdentityNotFound)
+	suite.mockUserRepo.On("GetByEmail", ctx, "test@example.com").Return(user, nil)
+
+	_, err := suite.authService.CompleteOIDCLogin(ctx, req, domain.SessionMetadata{})
+
+	// Whoever registered the address may not own it, so the account is left alone
+	assert.Equal(suite.T(), domain.ErrOIDCAccountUnverified, err)
+	assert.Nil(suite.T(), user.VerifiedAt)
+	suite.mockIdentities.AssertNotCalled(suite.T(), "Create", mock.Anything, mock.Anything)
+	suite.mockSessions.AssertNotCalled(suite.T(), "Create", mock.Anything, mock.Anything)
+}
+
+func (suite *AuthServiceTestSuite) TestCompleteOIDCLogin_CreatesUser() {
+	ctx := context.Background()
+	req := domain.OIDCCallbackRequest{Code: "code", State: "state"}
+	identity := &domain.OIDCIdentity{
+		Issuer: testIssuer, Subject: "sub-1", Email: "jane@example.com", EmailVerified: true,
+		GivenName: "Jane", FamilyName: "Doe",
+	}
+
+	suite.expectOIDCCallback(ctx, req, identity)
+	suite.mockIdentities.On("GetByIssuerSubject", ctx, testIssuer, "sub-1").Return(nil, domain.ErrIdentityNotFound)
+	suite.mockUserRepo.On("GetByEmail", ctx, "jane@example.com").Return(nil, domain.ErrUserNotFound)
+	suite.mockUserRepo.On("Create", ctx, mock.AnythingOfType("*domain.User")).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(1).(*domain.User).ID = 5
+	})
+	suite.mockNotifier.On("SendWelcomeEmail", ctx, mock.AnythingOfType("*domain.User"), "").Return(nil)
+	suite.mockIdentities.On("Create", ctx, mock.MatchedBy(func(link *domain.UserIdentity) bool {
+		return link.UserID == 5
+	})).Return(nil)
+	suite.expectTokens(ctx, &domain.User{ID: 5, Email: "jane@example.com"})
+
+	response, err := suite.authService.CompleteOIDCLogin(ctx, req, domain.SessionMetadata{})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "access_token", response.AccessToken)
+
+	created := suite.mockUserRepo.Calls[1].Arguments.Get(1).(*domain.User)
+	assert.Equal(suite.T(), "Jane", created.FirstName)
+	assert.Equal(suite.T(), "Doe", created.LastName)
+	assert.Equal(suite.T(), domain.RoleUser, created.Role)
+	assert.True(suite.T(), created.IsActive)
+	assert.NotNil(suite.T(), created.VerifiedAt)
+	// Single sign-on users have no password until they set one
+	assert.Empty(suite.T(), created.Password)
+}
+
+func (suite *AuthServiceTestSuite) TestCompleteOIDCLogin_UnverifiedEmail() {
+	ctx := context.Background()
+	req := domain.OIDCCallbackRequest{Code: "code", State: "state"}
+	identity := &domain.OIDCIdentity{Issuer: testIssuer, Subject: "sub-1", Email: "test@example.com"}
+
+	suite.expectOIDCCallback(ctx, req, identity)
+	suite.mockIdentities.On("GetByIssuerSubject", ctx, testIssuer, "sub-1").Return(nil, domain.ErrIdentityNotFound)
+
+	_, err := suite.authService.CompleteOIDCLogin(ctx, req, domain.SessionMetadata{})
+
+	// An unverified address must not take over the account that owns it
+	assert.Equal(suite.T(), domain.ErrOIDCEmailNotVerified, err)
+	suite.mockUserRepo.AssertNotCalled(suite.T(), "GetByEmail", mock.Anything, mock.Anything)
+	suite.mockIdentities.AssertNotCalled(suite.T(), "Create", mock.Anything, mock.Anything)
+}
+
+func (suite *AuthServiceTestSuite) TestCompleteOIDCLogin_TwoFactorChallenge() {
+	ctx := context.Background()
+	req := domain.OIDCCallbackRequest{Code: "code", State: "state"}
+	user := suite.twoFactorUser()
+	link := &domain.UserIdentity{UserID: user.ID, Issuer: testIssuer, Subject: "sub-1"}
+
+	suite.expectOIDCCallback(ctx, req, &domain.OIDCIdentity{Issuer: testIssuer, Subject: "sub-1", Email: user.Email, EmailVerified: true})
+	suite.mockIdentities.On("GetByIssuerSubject", ctx, testIssuer, "sub-1").Return(link, nil)
+	suite.mockIdentities.On("Update", ctx, link).Return(nil)
+	suite.mockUserRepo.On("GetByID", ctx, user.ID).Return(user, nil)
+	suite.mockCacheRepo.On("Set", ctx, mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "mfa_challenge:")
+	}), user.ID, mfaChallengeTTL).Return(nil)
+
+	response, err := suite.authService.CompleteOIDCLogin(ctx, req, domain.SessionMetadata{})
+
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), response.MFARequired)
+	assert.Empty(suite.T(), response.AccessToken)
+}
+
+func (suite *AuthServiceTestSuite) TestCompleteOIDCLogin_InactiveUser() {
+	ctx := context.Background()
+	req := domain.OIDCCallbackRequest{Code: "code", State: "state"}
+	user := &domain.User{ID: 1, Email: "test@example.com", IsActive: false}
+	link := &domain.UserIdentity{UserID: user.ID, Issuer: testIssuer, Subject: "sub-1"}
+
+	suite.expectOIDCCallback(ctx, req, &domain.OIDCIdentity{Issuer: testIssuer, Subject: "sub-1"})
+	suite.mockIdentities.On("GetByIssuerSubject", ctx, testIssuer, "sub-1").Return(link, nil)
+	suite.mockIdentities.On("Update", ctx, link).Return(nil)
+	suite.mockUserRepo.On("GetByID", ctx, user.ID).Return(user, nil)
+
+	_, err := suite.authService.CompleteOIDCLogin(ctx, req, domain.SessionMetadata{})
+
+	assert.Equal(suite.T(), domain.ErrUserInactive, err)
+	suite.mockSessions.AssertNotCalled(suite.T(), "Create", mock.Anything, mock.Anything)
+}
+
+func (suite *AuthServiceTestSuite) TestCompleteOIDCLogin_UnknownState() {
+	ctx := context.Background()
+	req := domain.OIDCCallbackRequest{Code: "code", State: "forged"}
+	suite.mockCacheRepo.On("GetDel", ctx, oidcStateKey("forged")).Return("", errors.New("redis: nil"))
+
+	_, err := suite.authService.CompleteOIDCLogin(ctx, req, domain.SessionMetadata{})
+
+	assert.Equal(suite.T(), domain.ErrOIDCStateInvalid, err)
+	suite.mockOIDC.AssertNotCalled(suite.T(), "Exchange", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *AuthServiceTestSuite) TestCompleteOIDCLogin_ExchangeFails() {
+	ctx := context.Background()
+	req := domain.OIDCCallbackRequest{Code: "code", State: "state"}
+	value, _ := json.Marshal(oidcLoginState{Nonce: "nonce", CodeVerifier: "verifier"})
+	suite.mockCacheRepo.On("GetDel", ctx, oidcStateKey("state")).Return(string(value), nil).Once()
+	suite.mockOIDC.On("Exchange", ctx, "code", "verifier", "nonce").Return(nil, errors.New("id token nonce does not match")).Once()
+
+	_, err := suite.authService.CompleteOIDCLogin(ctx, req, domain.SessionMetadata{})
+
+	assert.Equal(suite.T(), domain.ErrOIDCLoginFailed, err)
+
+	// The state was used up by the first callback
+	suite.mockCacheRepo.On("GetDel", ctx, oidcStateKey("state")).Return("", errors.New("redis: nil"))
+
+	_, err = suite.authService.CompleteOIDCLogin(ctx, req, domain.SessionMetadata{})
+
+	assert.Equal(suite.T(), domain.ErrOIDCStateInvalid, err)
+	suite.mockOIDC.AssertNumberOfCalls(suite.T(), "Exchange", 1)
+}
+
+// MockUserIdentityRepository is a mock implementation of ports.UserIdentityRepository
+type MockUserIdentityRepository struct {
+	mock.Mock
+}
+
+func (m *MockUserIdentityRepository) Create(ctx context.Context, identity *domain.UserIdentity) error {
+	args := m.Called(ctx, identity)
+	return args.Error(0)
+}
+
+func (m *MockUserIdentityRepository) GetByIssuerSubject(ctx context.Context, issuer, subject string) (*domain.UserIdentity, error) {
+	args := m.Called(ctx, issuer, subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.UserIdentity), args.Error(1)
+}
+
+func (m *MockUserIdentityRepository) Update(ctx context.Context, identity *domain.UserIdentity) error {
+	args := m.Called(ctx, identity)
+	return args.Error(0)
+}
+
+// MockOIDCProvider is a mock implementation of ports.OIDCProvider
+type MockOIDCProvider struct {
+	mock.Mock
+}
+
+func (m *MockOIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	args := m.Called(ctx, state, nonce, codeChallenge)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockOIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*domain.OIDCIdentity, error) {
+	args := m.Called(ctx, code, codeVerifier, nonce)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.OIDCIdentity), args.Error(1)
+}
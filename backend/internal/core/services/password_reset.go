@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-shortener/internal/core/domain"
+)
+
+const (
+	passwordResetTTL = time.Hour
+
+	// Reset emails per address per window, whether or not the address is registered
+	maxPasswordResetRequests   = 3
+	passwordResetRequestWindow = time.Hour
+)
+
+// RequestPasswordReset emails a single-use reset link. Only a hash of the token is
+// stored, and unknown or inactive accounts get the same result as real ones so the
+// endpoint cannot be used to discover registered addresses.
+func (s *authService) RequestPasswordReset(ctx context.Context, req domain.PasswordResetRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	rateKey := fmt.Sprintf("password_reset_rate:%s", strings.ToLower(req.Email))
+	limited, err := s.cacheRepo.IsRateLimited(ctx, rateKey, maxPasswordResetRequests, passwordResetRequestWindow)
+	if err != nil {
+		// Fail open; the token itself still has to be stored below
+		fmt.Printf("Failed to check password reset rate limit: %v\n", err)
+	}
+	if limited {
+		return domain.ErrTooManyRequests
+	}
+	if _, err := s.cacheRepo.IncrementRateLimit(ctx, rateKey, passwordResetRequestWindow); err != nil {
+		fmt.Printf("Failed to count password reset request: %v\n", err)
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, req.Email)
+	if err != nil {
+		if err == domain.ErrUserNotFound {
+			return nil
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.IsActive {
+		return nil
+	}
+
+	token, err := s.issuePasswordResetToken(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.notifier.SendPasswordResetEmail(ctx, user, token); err != nil {
+		s.revokePasswordResetToken(ctx, user.ID)
+		return fmt.Errorf("failed to send password reset email: %w", err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password with a token from RequestPasswordReset and signs
+// the user out of every session. The token is consumed before anything else, so two
+// requests racing with the same token cannot both succeed; if a later step fails the
+// user asks for a new one.
+func (s *authService) ResetPassword(ctx context.Context, req domain.PasswordResetConfirmRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	value, err := s.cacheRepo.GetDel(ctx, passwordResetKey(req.Token))
+	if err != nil || value == "" {
+		return domain.ErrInvalidToken
+	}
+	userID, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return domain.ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, uint(userID))
+	if err != nil {
+		if err == domain.ErrUserNotFound {
+			return domain.ErrInvalidToken
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.IsActive {
+		return domain.ErrUserInactive
+	}
+
+	// The user's pointer to the token is only used to replace it
+	if err := s.cacheRepo.Del(ctx, passwordResetUserKey(user.ID)); err != nil {
+		fmt.Printf("Failed to clear password reset token: %v\n", err)
+	}
+
+	if err := s.sessionRepo.RevokeAllByUser(ctx, user.ID, domain.SessionRevokedPasswordReset); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	s.forgetUserSessions(ctx, user.ID)
+
+	hashedPassword, err := s.hashPassword(req.NewPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	user.Password = hashedPassword
+	user.UpdatedAt = time.Now()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	recordAudit(ctx, s.auditRepo, user.ID, domain.AuditUserPasswordReset, domain.AuditTargetUser, user.ID, nil)
+
+	if err := s.notifier.SendPasswordChangedNotification(ctx, user); err != nil {
+		// Log error but don't fail the reset
+		fmt.Printf("Failed to send password changed notification: %v\n", err)
+	}
+
+	return nil
+}
+
+// issuePasswordResetToken stores a new token for the user, replacing any earlier
+// one so that only the most recent email works.
+func (s *authService) issuePasswordResetToken(ctx context.Context, userID uint) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate reset token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	s.revokePasswordResetToken(ctx, userID)
+
+	tokenKey := passwordResetKey(token)
+	if err := s.cacheRepo.Set(ctx, tokenKey, userID, passwordResetTTL); err != nil {
+		return "", fmt.Errorf("failed to store reset token: %w", err)
+	}
+	if err := s.cacheRepo.Set(ctx, passwordResetUserKey(userID), tokenKey, passwordResetTTL); err != nil {
+		return "", fmt.Errorf("failed to store reset token: %w", err)
+	}
+
+	return token, nil
+}
+
+func (s *authService) revokePasswordResetToken(ctx context.Context, userID uint) {
+	userKey := passwordResetUserKey(userID)
+	previous, err := s.cacheRepo.Get(ctx, userKey)
+	if err != nil || previous == "" {
+		return
+	}
+	if err := s.cacheRepo.Del(ctx, previous, userKey); err != nil {
+		fmt.Printf("Failed to revoke password reset token: %v\n", err)
+	}
+}
+
+func passwordResetKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "password_reset:" + hex.EncodeToString(sum[:])
+}
+
+func passwordResetUserKey(userID uint) string {
+	return fmt.Sprintf("password_reset_user:%d", userID)
+}
@@ -1,30 +1,45 @@
 package services
 
 import (
-	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"image/color"
+	"strings"
+	"time"
 
 	"url-shortener/internal/core/domain"
 	"url-shortener/internal/core/ports"
 )
 
+// Uploaded logos are shrunk to a few hundred pixels at most, so anything bigger is a mistake
+const maxQRLogoBytes = 512 << 10
+
 type qrService struct {
 	urlRepo    ports.URLRepository
 	configRepo ports.ConfigService
 	qrProvider ports.QRCodeProvider
+
+	// workspaceRepo decides who may use workspace links
+	workspaceRepo ports.WorkspaceRepository
+	batchRepo     ports.QRBatchRepository
+	now           func() time.Time
 }
 
 func NewQRService(
 	urlRepo ports.URLRepository,
 	configRepo ports.ConfigService,
 	qrProvider ports.QRCodeProvider,
+	workspaceRepo ports.WorkspaceRepository,
+	batchRepo ports.QRBatchRepository,
 ) ports.QRService {
 	return &qrService{
-		urlRepo:    urlRepo,
-		configRepo: configRepo,
-		qrProvider: qrProvider,
+		urlRepo:       urlRepo,
+		configRepo:    configRepo,
+		qrProvider:    qrProvider,
+		workspaceRepo: workspaceRepo,
+		batchRepo:     batchRepo,
+		now:           time.Now,
 	}
 }
 
@@ -33,6 +48,9 @@ func (s *qrService) GenerateQRCode(ctx context.Context, req domain.QRCodeRequest
 	if err := req.Validate(); err != nil {
 		return nil, err
 	}
+	if err := s.validateStyling(req); err != nil {
+		return nil, err
+	}
 
 	// If short code is provided, verify it exists and get the full URL
 	var targetURL string
@@ -42,9 +60,11 @@ func (s *qrService) GenerateQRCode(ctx context.Context, req domain.QRCodeRequest
 			return nil, fmt.Errorf("failed to get short URL: %w", err)
 		}
 
-		// Check ownership if user ID is provided
-		if req.UserID != 0 && shortURL.UserID != req.UserID {
-			return nil, domain.ErrUnauthorized
+		// Check access if user ID is provided
+		if req.UserID != 0 {
+			if err := authorizeURL(ctx, s.workspaceRepo, shortURL, req.UserID, domain.PermissionViewLinks); err != nil {
+				return nil, err
+			}
 		}
 
 		// Build the full short URL
@@ -169,6 +189,64 @@ func (s *qrService) ValidateQRCodeOptions(ctx context.Context, options domain.QR
 	return nil
 }
 
+// validateStyling rejects bad customization and logo settings before anything is rendered
+func (s *qrService) validateStyling(req domain.QRCodeRequest) error {
+	if c := req.Customization; c != nil {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+		if c.Pattern == domain.QRPatternGradient && !s.isValidHexColor(c.GradientColor) {
+			return fmt.Errorf("%w: invalid gradient color: %s", domain.ErrInvalidInput, c.GradientColor)
+		}
+	}
+
+	if req.LogoSize != 0 {
+		if req.Logo == "" {
+			return fmt.Errorf("%w: logo size without a logo", domain.ErrInvalidInput)
+		}
+		if req.LogoSize < domain.MinQRLogoSize {
+			return fmt.Errorf("%w: logo size must be at least %d%%", domain.ErrInvalidInput, domain.MinQRLogoSize)
+		}
+		if req.LogoSize > domain.MaxQRLogoSize {
+			return domain.ErrLogoTooLarge
+		}
+	}
+	if req.Logo != "" {
+		if _, err := decodeLogoUpload(req.Logo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeLogoUpload turns an uploaded logo, plain base64 or a data URL, into
+// image bytes. Remote URLs are refused rather than fetched.
+func decodeLogoUpload(logo string) ([]byte, error) {
+	encoded := strings.TrimSpace(logo)
+	if strings.HasPrefix(encoded, "data:") {
+		header, data, found := strings.Cut(encoded, ",")
+		switch strings.ToLower(header) {
+		case "data:image/png;base64", "data:image/jpeg;base64", "data:image/jpg;base64":
+		default:
+			return nil, domain.ErrInvalidLogo
+		}
+		if !found {
+			return nil, domain.ErrInvalidLogo
+		}
+		encoded = data
+	}
+
+	if base64.StdEncoding.DecodedLen(len(encoded)) > maxQRLogoBytes+3 {
+		return nil, domain.ErrInvalidLogo
+	}
+	// URLs are not valid base64, so this also turns away http:// and https:// links
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(data) == 0 || len(data) > maxQRLogoBytes {
+		return nil, domain.ErrInvalidLogo
+	}
+	return data, nil
+}
+
 func (s *qrService) generateQRCodeData(url string, req domain.QRCodeRequest) ([]byte, error) {
 	// Use the QR code provider to generate the actual QR code
 	options := domain.QRGenerationOptions{
@@ -180,6 +258,24 @@ func (s *qrService) generateQRCodeData(url string, req domain.QRCodeRequest) ([]
 		Border:           req.Border,
 	}
 
+	if c := req.Customization; c != nil {
+		options.ModuleShape = c.ModuleShape()
+		options.EyeShape = c.EyeShape()
+		if c.Pattern == domain.QRPatternGradient {
+			gradient := s.parseColor(c.GradientColor, options.ForegroundColor)
+			options.GradientColor = &gradient
+			options.GradientAngle = c.GradientAngle
+		}
+	}
+	if req.Logo != "" {
+		logo, err := decodeLogoUpload(req.Logo)
+		if err != nil {
+			return nil, err
+		}
+		options.Logo = logo
+		options.LogoSize = req.LogoSize
+	}
+
 	return s.qrProvider.GenerateQRCode(url, options)
 }
 
@@ -242,34 +338,3 @@ func (s *qrService) getMimeType(format string) string {
 		return "image/png"
 	}
 }
-
-// Simple QR code provider implementation for basic functionality
-type simpleQRProvider struct{}
-
-func NewSimpleQRProvider() ports.QRCodeProvider {
-	return &simpleQRProvider{}
-}
-
-func (p *simpleQRProvider) GenerateQRCode(url string, options domain.QRGenerationOptions) ([]byte, error) {
-	// This is a placeholder implementation
-	// In a real implementation, you would use a proper QR code library like go-qrcode
-	
-	// Create a simple placeholder image
-	img := make([][]bool, options.Size/8)
-	for i := range img {
-		img[i] = make([]bool, options.Size/8)
-		for j := range img[i] {
-			// Simple pattern for demonstration
-			img[i][j] = (i+j)%2 == 0
-		}
-	}
-
-	// Convert to PNG bytes (simplified)
-	var buf bytes.Buffer
-	
-	// This is a very basic placeholder - in reality you'd use proper QR code generation
-	// and image encoding libraries
-	buf.WriteString("QR-CODE-PLACEHOLDER")
-	
-	return buf.Bytes(), nil
-}
\ No newline at end of file
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"path"
+	"time"
+
+	"url-shortener/internal/core/domain"
+)
+
+const (
+	// A batch still processing after this long without progress lost its worker
+	qrBatchStaleAfter = 5 * time.Minute
+
+	// Batches that keep losing their worker are given up on
+	maxQRBatchAttempts = 3
+
+	qrBatchManifestName = "manifest.csv"
+)
+
+var qrBatchManifestColumns = []string{"short_code", "url", "file", "status", "error"}
+
+// CreateBatch looks up and access-checks every link up front, so the worker only
+// renders. Options and customization are checked the same way single QR codes are.
+func (s *qrService) CreateBatch(ctx context.Context, userID uint, req domain.CreateQRBatchRequest) (*domain.QRBatch, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	template := qrBatchRequest(req.Options, req.Customization)
+	if req.Options != nil {
+		if err := s.ValidateQRCodeOptions(ctx, domain.QRCodeOptions{
+			Size:            template.Size,
+			Format:          template.Format,
+			ForegroundColor: template.ForegroundColor,
+			BackgroundColor: template.BackgroundColor,
+			ErrorCorrection: template.ErrorCorrection,
+			Border:          template.Border,
+		}); err != nil {
+			return nil, fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
+		}
+	}
+	if err := s.validateStyling(template); err != nil {
+		return nil, err
+	}
+
+	var links []*domain.ShortURL
+	for _, code := range req.ShortCodes {
+		shortURL, err := s.urlRepo.GetByShortCode(ctx, code)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get short URL %q: %w", code, err)
+		}
+		links = append(links, shortURL)
+	}
+	for _, id := range req.URLIDs {
+		shortURL, err := s.urlRepo.GetByID(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get short URL %d: %w", id, err)
+		}
+		links = append(links, shortURL)
+	}
+
+	seen := make(map[uint]bool, len(links))
+	items := make(domain.QRBatchItems, 0, len(links))
+	for _, shortURL := range links {
+		if seen[shortURL.ID] {
+			continue
+		}
+		seen[shortURL.ID] = true
+		if err := authorizeURL(ctx, s.workspaceRepo, shortURL, userID, domain.PermissionViewLinks); err != nil {
+			return nil, err
+		}
+		items = append(items, domain.QRBatchItem{
+			ShortCode: shortURL.ShortCode,
+			URL:       fmt.Sprintf("%s/%s", s.configRepo.GetBaseURL(), shortURL.ShortCode),
+		})
+	}
+
+	batch := &domain.QRBatch{
+		UserID:        userID,
+		Items:         items,
+		Options:       req.Options,
+		Customization: req.Customization,
+		Status:        domain.QRBatchPending,
+	}
+	if err := s.batchRepo.Create(ctx, batch); err != nil {
+		return nil, fmt.Errorf("failed to create QR batch: %w", err)
+	}
+	return batch, nil
+}
+
+// GetBatch returns one of the user's batches; other users' batches are reported as not found
+func (s *qrService) GetBatch(ctx context.Context, batchID, userID uint) (*domain.QRBatch, error) {
+	batch, err := s.batchRepo.GetByID(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch.UserID != userID {
+		return nil, domain.ErrQRBatchNotFound
+	}
+	return batch, nil
+}
+
+func (s *qrService) GetBatchArchive(ctx context.Context, batchID, userID uint) ([]byte, error) {
+	batch, err := s.GetBatch(ctx, batchID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch batch.Status {
+	case domain.QRBatchCompleted:
+		if batch.ExpiresAt != nil && !s.now().Before(*batch.ExpiresAt) {
+			return nil, domain.ErrQRBatchExpired
+		}
+		return s.batchRepo.GetArchive(ctx, batch.ID)
+	case domain.QRBatchExpired:
+		return nil, domain.ErrQRBatchExpired
+	case domain.QRBatchFailed:
+		return nil, fmt.Errorf("%w: the batch failed: %s", domain.ErrQRBatchNotReady, batch.Error)
+	default:
+		return nil, domain.ErrQRBatchNotReady
+	}
+}
+
+// ProcessNextBatch renders the oldest waiting batch. Links that cannot be rendered
+// are listed as failed in the manifest; the batch only fails when none could be.
+func (s *qrService) ProcessNextBatch(ctx context.Context) (bool, error) {
+	batch, err := s.batchRepo.ClaimNext(ctx, s.now().Add(-qrBatchStaleAfter))
+	if err != nil || batch == nil {
+		return false, err
+	}
+
+	if batch.Attempts > maxQRBatchAttempts {
+		return true, s.batchRepo.Fail(ctx, batch.ID, "the batch was interrupted too many times", s.now())
+	}
+
+	archive, failures, err := s.renderBatch(ctx, batch)
+	if err != nil {
+		// Left processing when shutting down, so it is picked up again once stale
+		if ctx.Err() != nil {
+			return true, err
+		}
+		if failErr := s.batchRepo.Fail(ctx, batch.ID, err.Error(), s.now()); failErr != nil {
+			return true, failErr
+		}
+		return true, fmt.Errorf("failed to render QR batch %d: %w", batch.ID, err)
+	}
+
+	now := s.now()
+	completed, err := s.batchRepo.Complete(ctx, batch.ID, batch.Attempts, archive, failures, now, now.Add(s.configRepo.GetQRBatchResultTTL()))
+	if err != nil {
+		return true, err
+	}
+	if !completed {
+		// This worker was too slow and the batch went stale; the worker that
+		// claimed it again stores its own result
+		fmt.Printf("Dropping result of QR batch %d, which was claimed again\n", batch.ID)
+	}
+	return true, nil
+}
+
+func (s *qrService) ExpireBatches(ctx context.Context) (int64, error) {
+	return s.batchRepo.ExpireArchives(ctx, s.now())
+}
+
+// renderBatch writes one image per link, named by short code, and a manifest
+// recording what happened to each
+func (s *qrService) renderBatch(ctx context.Context, batch *domain.QRBatch) ([]byte, int, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	var manifest bytes.Buffer
+	rows := csv.NewWriter(&manifest)
+	if err := rows.Write(qrBatchManifestColumns); err != nil {
+		return nil, 0, err
+	}
+
+	template := qrBatchRequest(batch.Options, batch.Customization)
+	modified := s.now()
+	failures, progress := 0, batch.Progress
+	for i, item := range batch.Items {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+
+		req := template
+		req.URL = item.URL
+		file, status, message := "", "ok", ""
+		qr, err := s.GenerateQRCode(ctx, req)
+		if err != nil {
+			failures++
+			status, message = "failed", err.Error()
+		} else {
+			file = item.ShortCode + "." + qr.Format
+			if err := writeZipEntry(archive, file, qr.Data, modified); err != nil {
+				return nil, 0, err
+			}
+		}
+		if err := rows.Write([]string{item.ShortCode, item.URL, file, status, message}); err != nil {
+			return nil, 0, err
+		}
+
+		// Completing the batch sets 100
+		if next := (i + 1) * 100 / len(batch.Items); next != progress && next < 100 {
+			progress = next
+			if err := s.batchRepo.UpdateProgress(ctx, batch.ID, progress); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+
+	if failures == len(batch.Items) {
+		return nil, 0, fmt.Errorf("none of the %d QR codes could be rendered", failures)
+	}
+
+	rows.Flush()
+	if err := rows.Error(); err != nil {
+		return nil, 0, fmt.Errorf("failed to write QR batch manifest: %w", err)
+	}
+	if err := writeZipEntry(archive, qrBatchManifestName, manifest.Bytes(), modified); err != nil {
+		return nil, 0, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, 0, fmt.Errorf("failed to write QR batch archive: %w", err)
+	}
+	return buf.Bytes(), failures, nil
+}
+
+func writeZipEntry(archive *zip.Writer, name string, data []byte, modified time.Time) error {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified}
+	// PNG and JPEG are compressed already
+	switch path.Ext(name) {
+	case ".png", ".jpeg", ".jpg":
+		header.Method = zip.Store
+	}
+
+	w, err := archive.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to QR batch archive: %w", name, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to add %s to QR batch archive: %w", name, err)
+	}
+	return nil
+}
+
+// qrBatchRequest is the single QR code request every link of a batch is rendered with
+func qrBatchRequest(options *domain.QROptions, customization *domain.QRCustomization) domain.QRCodeRequest {
+	req := domain.QRCodeRequest{Customization: customization}
+	if options != nil {
+		req.Size = options.Size
+		req.Format = options.Format
+		req.ForegroundColor = options.Foreground
+		req.BackgroundColor = options.Background
+		req.ErrorCorrection = options.ErrorLevel
+		req.Border = options.BorderSize
+		req.Logo = options.Logo
+		req.LogoSize = options.LogoSize
+	}
+	return req
+}
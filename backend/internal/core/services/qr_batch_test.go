@@ -0,0 +1,298 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"url-shortener/internal/core/domain"
+)
+
+type QRBatchServiceTestSuite struct {
+	suite.Suite
+	service      *qrService
+	mockURLRepo  *MockURLRepository
+	mockConfig   *MockConfigService
+	mockProvider *MockQRCodeProvider
+	mockRepo     *MockQRBatchRepository
+	now          time.Time
+}
+
+func TestQRBatchServiceSuite(t *testing.T) {
+	suite.Run(t, new(QRBatchServiceTestSuite))
+}
+
+func (suite *QRBatchServiceTestSuite) SetupTest() {
+	suite.mockURLRepo = &MockURLRepository{}
+	suite.mockConfig = &MockConfigService{}
+	suite.mockProvider = &MockQRCodeProvider{}
+	suite.mockRepo = &MockQRBatchRepository{}
+	suite.now = time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	suite.service = &qrService{
+		urlRepo:    suite.mockURLRepo,
+		configRepo: suite.mockConfig,
+		qrProvider: suite.mockProvider,
+		batchRepo:  suite.mockRepo,
+		now:        func() time.Time { return suite.now },
+	}
+	suite.mockConfig.On("GetBaseURL").Return("https://sho.rt")
+	suite.mockConfig.On("GetQRBatchResultTTL").Return(24 * time.Hour)
+}
+
+func (suite *QRBatchServiceTestSuite) TestCreateBatch() {
+	ctx := context.Background()
+	spring := &domain.ShortURL{ID: 1, ShortCode: "spring", UserID: 7}
+	summer := &domain.ShortURL{ID: 2, ShortCode: "summer", UserID: 7}
+	suite.mockURLRepo.On("GetByShortCode", ctx, "spring").Return(spring, nil)
+	suite.mockURLRepo.On("GetByShortCode", ctx, "summer").Return(summer, nil)
+	suite.mockURLRepo.On("GetByID", ctx, uint(1)).Return(spring, nil)
+	suite.mockRepo.On("Create", ctx, mock.AnythingOfType("*domain.QRBatch")).Return(nil)
+
+	batch, err := suite.service.CreateBatch(ctx, 7, domain.CreateQRBatchRequest{
+		ShortCodes: []string{" spring", "summer"},
+		URLIDs:     []uint{1},
+		Options:    &domain.QROptions{Size: 512, Format: "svg"},
+	})
+
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), domain.QRBatchPending, batch.Status)
+	assert.Equal(suite.T(), uint(7), batch.UserID)
+	// The link named twice is rendered once
+	assert.Equal(suite.T(), domain.QRBatchItems{
+		{ShortCode: "spring", URL: "https://sho.rt/spring"},
+		{ShortCode: "summer", URL: "https://sho.rt/summer"},
+	}, batch.Items)
+}
+
+func (suite *QRBatchServiceTestSuite) TestCreateBatch_Rejected() {
+	ctx := context.Background()
+	theirs := &domain.ShortURL{ID: 3, ShortCode: "theirs", UserID: 8}
+	suite.mockURLRepo.On("GetByShortCode", ctx, "theirs").Return(theirs, nil)
+	suite.mockURLRepo.On("GetByShortCode", ctx, "gone").Return((*domain.ShortURL)(nil), domain.ErrShortURLNotFound)
+
+	tests := []struct {
+		name    string
+		req     domain.CreateQRBatchRequest
+		wantErr error
+	}{
+		{"no links", domain.CreateQRBatchRequest{}, domain.ErrInvalidInput},
+		{"too many links", domain.CreateQRBatchRequest{URLIDs: make([]uint, domain.MaxQRBatchItems+1)}, domain.ErrInvalidInput},
+		{"unsupported size", domain.CreateQRBatchRequest{ShortCodes: []string{"theirs"}, Options: &domain.QROptions{Size: 300}}, domain.ErrInvalidInput},
+		{"remote logo", domain.CreateQRBatchRequest{ShortCodes: []string{"theirs"}, Options: &domain.QROptions{Logo: "https://example.com/logo.png"}}, domain.ErrInvalidLogo},
+		{"unknown link", domain.CreateQRBatchRequest{ShortCodes: []string{"gone"}}, domain.ErrShortURLNotFound},
+		{"someone else's link", domain.CreateQRBatchRequest{ShortCodes: []string{"theirs"}}, domain.ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			_, err := suite.service.CreateBatch(ctx, 7, tt.req)
+			assert.True(suite.T(), errors.Is(err, tt.wantErr), "got %v", err)
+		})
+	}
+	suite.mockRepo.AssertNotCalled(suite.T(), "Create", mock.Anything, mock.Anything)
+}
+
+func (suite *QRBatchServiceTestSuite) TestProcessNextBatch() {
+	ctx := context.Background()
+	batch := &domain.QRBatch{
+		ID:     4,
+		Status: domain.QRBatchProcessing,
+		Items: domain.QRBatchItems{
+			{ShortCode: "spring", URL: "https://sho.rt/spring"},
+			{ShortCode: "summer", URL: "https://sho.rt/summer"},
+			{ShortCode: "autumn", URL: "https://sho.rt/autumn"},
+		},
+		Attempts: 1,
+	}
+	suite.mockRepo.On("ClaimNext", ctx, suite.now.Add(-qrBatchStaleAfter)).Return(batch, nil)
+	suite.mockProvider.On("GenerateQRCode", "https://sho.rt/spring", mock.Anything).Return([]byte("spring png"), nil)
+	suite.mockProvider.On("GenerateQRCode", "https://sho.rt/summer", mock.Anything).Return(nil, errors.New("data too long"))
+	suite.mockProvider.On("GenerateQRCode", "https://sho.rt/autumn", mock.Anything).Return([]byte("autumn png"), nil)
+	suite.mockRepo.On("UpdateProgress", ctx, uint(4), mock.AnythingOfType("int")).Return(nil)
+	suite.mockRepo.On("Complete", ctx, uint(4), 1, mock.Anything, 1, suite.now, suite.now.Add(24*time.Hour)).Return(true, nil)
+
+	processed, err := suite.service.ProcessNextBatch(ctx)
+
+	suite.Require().NoError(err)
+	assert.True(suite.T(), processed)
+	suite.mockRepo.AssertCalled(suite.T(), "UpdateProgress", ctx, uint(4), 33)
+	suite.mockRepo.AssertCalled(suite.T(), "UpdateProgress", ctx, uint(4), 66)
+
+	files := readZip(suite.T(), suite.mockRepo.Calls[len(suite.mockRepo.Calls)-1].Arguments.Get(3).([]byte))
+	assert.Equal(suite.T(), "spring png", files["spring.png"])
+	assert.Equal(suite.T(), "autumn png", files["autumn.png"])
+	assert.NotContains(suite.T(), files, "summer.png")
+
+	manifest, err := csv.NewReader(bytes.NewReader([]byte(files[qrBatchManifestName]))).ReadAll()
+	suite.Require().NoError(err)
+	suite.Require().Len(manifest, 4)
+	assert.Equal(suite.T(), qrBatchManifestColumns, manifest[0])
+	assert.Equal(suite.T(), []string{"spring", "https://sho.rt/spring", "spring.png", "ok", ""}, manifest[1])
+	assert.Equal(suite.T(), "failed", manifest[2][3])
+	assert.Contains(suite.T(), manifest[2][4], "data too long")
+}
+
+func (suite *QRBatchServiceTestSuite) TestProcessNextBatch_NothingRendered() {
+	ctx := context.Background()
+	batch := &domain.QRBatch{ID: 5, Items: domain.QRBatchItems{{ShortCode: "spring", URL: "https://sho.rt/spring"}}, Attempts: 1}
+	suite.mockRepo.On("ClaimNext", ctx, mock.Anything).Return(batch, nil)
+	suite.mockProvider.On("GenerateQRCode", mock.Anything, mock.Anything).Return(nil, errors.New("data too long"))
+	suite.mockRepo.On("Fail", ctx, uint(5), "none of the 1 QR codes could be rendered", suite.now).Return(nil)
+
+	processed, err := suite.service.ProcessNextBatch(ctx)
+
+	assert.True(suite.T(), processed)
+	assert.Error(suite.T(), err)
+	suite.mockRepo.AssertExpectations(suite.T())
+}
+
+func (suite *QRBatchServiceTestSuite) TestProcessNextBatch_GivesUpAfterRepeatedInterruptions() {
+	ctx := context.Background()
+	batch := &domain.QRBatch{ID: 6, Items: domain.QRBatchItems{{ShortCode: "spring"}}, Attempts: maxQRBatchAttempts + 1}
+	suite.mockRepo.On("ClaimNext", ctx, mock.Anything).Return(batch, nil)
+	suite.mockRepo.On("Fail", ctx, uint(6), mock.AnythingOfType("string"), suite.now).Return(nil)
+
+	processed, err := suite.service.ProcessNextBatch(ctx)
+
+	assert.True(suite.T(), processed)
+	assert.NoError(suite.T(), err)
+	suite.mockProvider.AssertNotCalled(suite.T(), "GenerateQRCode", mock.Anything, mock.Anything)
+}
+
+func (suite *QRBatchServiceTestSuite) TestProcessNextBatch_ClaimedAgain() {
+	ctx := context.Background()
+	batch := &domain.QRBatch{ID: 7, Items: domain.QRBatchItems{{ShortCode: "spring", URL: "https://sho.rt/spring"}}, Attempts: 2}
+	suite.mockRepo.On("ClaimNext", ctx, mock.Anything).Return(batch, nil)
+	suite.mockProvider.On("GenerateQRCode", mock.Anything, mock.Anything).Return([]byte("spring png"), nil)
+	suite.mockRepo.On("UpdateProgress", ctx, uint(7), mock.AnythingOfType("int")).Return(nil)
+	// Another worker took the batch over while this one was rendering
+	suite.mockRepo.On("Complete", ctx, uint(7), 2, mock.Anything, 0, suite.now, mock.Anything).Return(false, nil)
+
+	processed, err := suite.service.ProcessNextBatch(ctx)
+
+	assert.True(suite.T(), processed)
+	assert.NoError(suite.T(), err)
+	suite.mockRepo.AssertNotCalled(suite.T(), "Fail", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *QRBatchServiceTestSuite) TestProcessNextBatch_Idle() {
+	ctx := context.Background()
+	suite.mockRepo.On("ClaimNext", ctx, mock.Anything).Return(nil, nil)
+
+	processed, err := suite.service.ProcessNextBatch(ctx)
+
+	assert.False(suite.T(), processed)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *QRBatchServiceTestSuite) TestGetBatchArchive() {
+	ctx := context.Background()
+	later := suite.now.Add(time.Hour)
+	earlier := suite.now.Add(-time.Hour)
+	batches := map[uint]*domain.QRBatch{
+		1: {ID: 1, UserID: 7, Status: domain.QRBatchCompleted, ExpiresAt: &later},
+		2: {ID: 2, UserID: 7, Status: domain.QRBatchProcessing},
+		3: {ID: 3, UserID: 7, Status: domain.QRBatchCompleted, ExpiresAt: &earlier},
+		4: {ID: 4, UserID: 7, Status: domain.QRBatchExpired},
+		5: {ID: 5, UserID: 8, Status: domain.QRBatchCompleted, ExpiresAt: &later},
+		6: {ID: 6, UserID: 7, Status: domain.QRBatchFailed, Error: "none of the 1 QR codes could be rendered"},
+	}
+	for id, batch := range batches {
+		suite.mockRepo.On("GetByID", ctx, id).Return(batch, nil)
+	}
+	suite.mockRepo.On("GetArchive", ctx, uint(1)).Return([]byte("PK"), nil)
+
+	archive, err := suite.service.GetBatchArchive(ctx, 1, 7)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), []byte("PK"), archive)
+
+	_, err = suite.service.GetBatchArchive(ctx, 2, 7)
+	assert.Equal(suite.T(), domain.ErrQRBatchNotReady, err)
+	_, err = suite.service.GetBatchArchive(ctx, 3, 7)
+	assert.Equal(suite.T(), domain.ErrQRBatchExpired, err, "expired before the worker cleared it")
+	_, err = suite.service.GetBatchArchive(ctx, 4, 7)
+	assert.Equal(suite.T(), domain.ErrQRBatchExpired, err)
+	_, err = suite.service.GetBatchArchive(ctx, 5, 7)
+	assert.Equal(suite.T(), domain.ErrQRBatchNotFound, err)
+	_, err = suite.service.GetBatchArchive(ctx, 6, 7)
+	assert.True(suite.T(), errors.Is(err, domain.ErrQRBatchNotReady))
+	assert.Contains(suite.T(), err.Error(), "could be rendered")
+}
+
+func readZip(t *testing.T, data []byte) map[string]string {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := make(map[string]string)
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		files[file.Name] = string(content)
+	}
+	return files
+}
+
+type MockQRBatchRepository struct {
+	mock.Mock
+}
+
+func (m *MockQRBatchRepository) Create(ctx context.Context, batch *domain.QRBatch) error {
+	args := m.Called(ctx, batch)
+	return args.Error(0)
+}
+
+func (m *MockQRBatchRepository) GetByID(ctx context.Context, id uint) (*domain.QRBatch, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.QRBatch), args.Error(1)
+}
+
+func (m *MockQRBatchRepository) GetArchive(ctx context.Context, id uint) ([]byte, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *MockQRBatchRepository) ClaimNext(ctx context.Context, staleBefore time.Time) (*domain.QRBatch, error) {
+	args := m.Called(ctx, staleBefore)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.QRBatch), args.Error(1)
+}
+
+func (m *MockQRBatchRepository) UpdateProgress(ctx context.Context, id uint, progress int) error {
+	args := m.Called(ctx, id, progress)
+	return args.Error(0)
+}
+
+func (m *MockQRBatchRepository) Complete(ctx context.Context, id uint, attempts int, archive []byte, failures int, completedAt, expiresAt time.Time) (bool, error) {
+	args := m.Called(ctx, id, attempts, archive, failures, completedAt, expiresAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockQRBatchRepository) Fail(ctx context.Context, id uint, message string, at time.Time) error {
+	args := m.Called(ctx, id, message, at)
+	return args.Error(0)
+}
+
+func (m *MockQRBatchRepository) ExpireArchives(ctx context.Context, now time.Time) (int64, error) {
+	args := m.Called(ctx, now)
+	return args.Get(0).(int64), args.Error(1)
+}
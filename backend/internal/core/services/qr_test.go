@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"url-shortener/internal/core/domain"
+)
+
+type QRServiceTestSuite struct {
+	suite.Suite
+	service      *qrService
+	mockProvider *MockQRCodeProvider
+}
+
+func TestQRServiceSuite(t *testing.T) {
+	suite.Run(t, new(QRServiceTestSuite))
+}
+
+func (suite *QRServiceTestSuite) SetupTest() {
+	suite.mockProvider = &MockQRCodeProvider{}
+	suite.service = &qrService{qrProvider: suite.mockProvider}
+}
+
+func (suite *QRServiceTestSuite) TestGenerateQRCode_Styled() {
+	logo := []byte("\x89PNG\r\n\x1a\nlogo")
+	suite.mockProvider.On("GenerateQRCode", "https://example.com", mock.AnythingOfType("domain.QRGenerationOptions")).Return([]byte("qr"), nil)
+
+	_, err := suite.service.GenerateQRCode(context.Background(), domain.QRCodeRequest{
+		URL:             "https://example.com",
+		ForegroundColor: "#1a237e",
+		Customization: &domain.QRCustomization{
+			Style:         domain.QRShapeRounded,
+			DataPattern:   domain.QRShapeDiamond,
+			Pattern:       domain.QRPatternGradient,
+			GradientColor: "#b71c1c",
+			GradientAngle: 90,
+		},
+		Logo:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(logo),
+		LogoSize: 25,
+	})
+
+	assert.NoError(suite.T(), err)
+	options := suite.mockProvider.Calls[0].Arguments.Get(1).(domain.QRGenerationOptions)
+	assert.Equal(suite.T(), domain.QRShapeDiamond, options.ModuleShape)
+	assert.Equal(suite.T(), domain.QRShapeRounded, options.EyeShape)
+	assert.Equal(suite.T(), &color.RGBA{0xb7, 0x1c, 0x1c, 255}, options.GradientColor)
+	assert.Equal(suite.T(), 90, options.GradientAngle)
+	assert.Equal(suite.T(), logo, options.Logo)
+	assert.Equal(suite.T(), 25, options.LogoSize)
+}
+
+func (suite *QRServiceTestSuite) TestGenerateQRCode_StylingRejected() {
+	png := base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\nlogo"))
+
+	tests := []struct {
+		name    string
+		req     domain.QRCodeRequest
+		wantErr error
+	}{
+		{"unknown module shape", domain.QRCodeRequest{Customization: &domain.QRCustomization{DataPattern: "star"}}, domain.ErrInvalidInput},
+		{"diamond eyes", domain.QRCodeRequest{Customization: &domain.QRCustomization{EyeStyle: domain.QRShapeDiamond}}, domain.ErrInvalidInput},
+		{"gradient without a colour", domain.QRCodeRequest{Customization: &domain.QRCustomization{Pattern: domain.QRPatternGradient}}, domain.ErrInvalidInput},
+		{"gradient with a bad colour", domain.QRCodeRequest{Customization: &domain.QRCustomization{Pattern: domain.QRPatternGradient, GradientColor: "red"}}, domain.ErrInvalidInput},
+		{"remote logo", domain.QRCodeRequest{Logo: "https://example.com/logo.png"}, domain.ErrInvalidLogo},
+		{"logo of another type", domain.QRCodeRequest{Logo: "data:image/svg+xml;base64," + png}, domain.ErrInvalidLogo},
+		{"logo over the size limit", domain.QRCodeRequest{Logo: base64.StdEncoding.EncodeToString(make([]byte, maxQRLogoBytes+1))}, domain.ErrInvalidLogo},
+		{"logo too small", domain.QRCodeRequest{Logo: png, LogoSize: 5}, domain.ErrInvalidInput},
+		{"logo too big", domain.QRCodeRequest{Logo: png, LogoSize: 40}, domain.ErrLogoTooLarge},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			tt.req.URL = "https://example.com"
+			_, err := suite.service.GenerateQRCode(context.Background(), tt.req)
+			assert.True(suite.T(), errors.Is(err, tt.wantErr), "got %v", err)
+		})
+	}
+	suite.mockProvider.AssertNotCalled(suite.T(), "GenerateQRCode", mock.Anything, mock.Anything)
+}
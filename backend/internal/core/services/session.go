@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"url-shortener/internal/core/domain"
+)
+
+// startSession records a new signed-in device and returns it with its first refresh token
+func (s *authService) startSession(ctx context.Context, user *domain.User, meta domain.SessionMetadata) (*domain.Session, string, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	familyID := base64.RawURLEncoding.EncodeToString(raw)
+
+	refreshToken, err := s.jwtService.GenerateRefreshToken(user.ID, familyID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	now := time.Now()
+	session := &domain.Session{
+		UserID:     user.ID,
+		FamilyID:   familyID,
+		TokenHash:  hashRefreshToken(refreshToken),
+		Device:     meta.Device,
+		UserAgent:  meta.UserAgent,
+		IPAddress:  meta.IPAddress,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.configRepo.GetSessionTTL()),
+	}
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return nil, "", fmt.Errorf("failed to store session: %w", err)
+	}
+
+	return session, refreshToken, nil
+}
+
+// rotateSession exchanges a refresh token for a new one in the same session. A
+// token that is valid but no longer current has been used before, which means it
+// leaked or a client is replaying it, so the whole session is revoked.
+func (s *authService) rotateSession(ctx context.Context, refreshToken string, meta domain.SessionMetadata) (*domain.Session, string, error) {
+	claims, err := s.jwtService.ValidateRefreshToken(refreshToken)
+	if err != nil || claims.SessionID == "" {
+		return nil, "", domain.ErrInvalidToken
+	}
+
+	session, err := s.sessionRepo.GetByFamilyID(ctx, claims.SessionID)
+	if err != nil {
+		if err == domain.ErrSessionNotFound {
+			return nil, "", domain.ErrInvalidToken
+		}
+		return nil, "", fmt.Errorf("failed to get session: %w", err)
+	}
+	if session.UserID != claims.UserID || !session.IsActive(time.Now()) {
+		return nil, "", domain.ErrInvalidToken
+	}
+
+	previousHash := hashRefreshToken(refreshToken)
+	if session.TokenHash != previousHash {
+		s.revokeReusedSession(ctx, session)
+		return nil, "", domain.ErrRefreshTokenReused
+	}
+
+	newRefreshToken, err := s.jwtService.GenerateRefreshToken(session.UserID, session.FamilyID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	now := time.Now()
+	session.TokenHash = hashRefreshToken(newRefreshToken)
+	session.LastSeenAt = now
+	session.ExpiresAt = now.Add(s.configRepo.GetSessionTTL())
+	if meta.UserAgent != "" {
+		session.UserAgent = meta.UserAgent
+		session.Device = meta.Device
+	}
+	if meta.IPAddress != "" {
+		session.IPAddress = meta.IPAddress
+	}
+
+	rotated, err := s.sessionRepo.Rotate(ctx, session, previousHash)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to rotate session: %w", err)
+	}
+	if !rotated {
+		// Another request rotated the same token first
+		s.revokeReusedSession(ctx, session)
+		return nil, "", domain.ErrRefreshTokenReused
+	}
+
+	return session, newRefreshToken, nil
+}
+
+func (s *authService) revokeReusedSession(ctx context.Context, session *domain.Session) {
+	fmt.Printf("Refresh token reuse detected for session %d of user %d, revoking it\n", session.ID, session.UserID)
+	if err := s.sessionRepo.Revoke(ctx, session.ID, domain.SessionRevokedReuse); err != nil {
+		fmt.Printf("Failed to revoke reused session: %v\n", err)
+		return
+	}
+	s.forgetSession(ctx, session.FamilyID)
+}
+
+// forgetSession drops the cached check that a session is active, so access tokens
+// issued for it stop working now rather than when the check expires
+func (s *authService) forgetSession(ctx context.Context, familyID string) {
+	if err := s.cacheRepo.InvalidateSession(ctx, familyID); err != nil {
+		fmt.Printf("Failed to clear cached session: %v\n", err)
+	}
+}
+
+// forgetUserSessions is forgetSession for every session of the user
+func (s *authService) forgetUserSessions(ctx context.Context, userID uint) {
+	if err := s.cacheRepo.InvalidateUserSessions(ctx, userID); err != nil {
+		fmt.Printf("Failed to clear cached sessions: %v\n", err)
+	}
+}
+
+func (s *authService) ListSessions(ctx context.Context, userID uint) ([]*domain.Session, error) {
+	sessions, err := s.sessionRepo.ListActiveByUser(ctx, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+func (s *authService) RevokeSession(ctx context.Context, userID uint, sessionID uint) error {
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		if err == domain.ErrSessionNotFound {
+			return err
+		}
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+
+	// Other users' and already ended sessions look the same as missing ones
+	if session.UserID != userID || !session.IsActive(time.Now()) {
+		return domain.ErrSessionNotFound
+	}
+
+	if err := s.sessionRepo.Revoke(ctx, session.ID, domain.SessionRevokedByUser); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	s.forgetSession(ctx, session.FamilyID)
+	return nil
+}
+
+func (s *authService) RevokeAllSessions(ctx context.Context, userID uint) error {
+	if err := s.sessionRepo.RevokeAllByUser(ctx, userID, domain.SessionRevokedAll); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	s.forgetUserSessions(ctx, userID)
+	recordAudit(ctx, s.auditRepo, userID, domain.AuditUserSessionsRevoked, domain.AuditTargetUser, userID, nil)
+	return nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"url-shortener/internal/core/domain"
+)
+
+const (
+	// Issuer shown next to the account in authenticator apps
+	totpIssuer = "URL Shortener"
+
+	// A password-verified sign-in has this long to supply the second factor
+	mfaChallengeTTL = 5 * time.Minute
+	// Wrong codes allowed per challenge before it is discarded
+	maxMFAAttempts = 5
+
+	// Recovery codes are printed as two groups of five characters
+	recoveryCodeLength   = 10
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// startMFAChallenge is the first half of a sign-in with two-factor enabled: the
+// password was right and the returned token stands in for it in the second half
+func (s *authService) startMFAChallenge(ctx context.Context, user *domain.User) (*domain.AuthResponse, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	if err := s.cacheRepo.Set(ctx, mfaChallengeKey(token), user.ID, mfaChallengeTTL); err != nil {
+		return nil, fmt.Errorf("failed to store challenge: %w", err)
+	}
+
+	return &domain.AuthResponse{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   int(mfaChallengeTTL.Seconds()),
+	}, nil
+}
+
+func (s *authService) CompleteMFALogin(ctx context.Context, req domain.MFALoginRequest, meta domain.SessionMetadata) (*domain.AuthResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	challengeKey := mfaChallengeKey(req.MFAToken)
+	value, err := s.cacheRepo.Get(ctx, challengeKey)
+	if err != nil || value == "" {
+		return nil, domain.ErrInvalidToken
+	}
+	userID, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil, domain.ErrInvalidToken
+	}
+
+	// Guessing codes against one challenge is capped; the password has to be
+	// entered again to get a new one. The attempt is counted before it is judged
+	// so concurrent guesses cannot all pass the check.
+	attemptsKey := challengeKey + ":attempts"
+	attempts, err := s.cacheRepo.IncrementRateLimit(ctx, attemptsKey, mfaChallengeTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record challenge attempt: %w", err)
+	}
+	if attempts > maxMFAAttempts {
+		if err := s.cacheRepo.Del(ctx, challengeKey); err != nil {
+			fmt.Printf("Failed to discard challenge: %v\n", err)
+		}
+		return nil, domain.ErrTooManyRequests
+	}
+
+	user, err := s.userRepo.GetByID(ctx, uint(userID))
+	if err != nil {
+		if err == domain.ErrUserNotFound {
+			return nil, domain.ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.IsActive {
+		return nil, domain.ErrUserInactive
+	}
+	if !user.IsTwoFactorEnabled() {
+		return nil, domain.ErrInvalidToken
+	}
+
+	if err := s.verifySecondFactor(ctx, user, req.Code); err != nil {
+		return nil, err
+	}
+
+	if err := s.cacheRepo.Del(ctx, challengeKey, attemptsKey); err != nil {
+		return nil, fmt.Errorf("failed to consume challenge: %w", err)
+	}
+
+	return s.completeLogin(ctx, user, meta)
+}
+
+func (s *authService) SetupTwoFactor(ctx context.Context, userID uint) (*domain.TwoFactorSetupResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.IsTwoFactorEnabled() {
+		return nil, domain.ErrTwoFactorAlreadyEnabled
+	}
+
+	// Starting again replaces a secret that was never confirmed
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	user.TOTPSecret = secret
+	user.TOTPLastStep = 0
+	user.UpdatedAt = time.Now()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to store TOTP secret: %w", err)
+	}
+
+	uri := totpURI(totpIssuer, user.Email, secret)
+	response := &domain.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURL: uri,
+	}
+
+	if s.qrProvider != nil {
+		png, err := s.qrProvider.GenerateQRCode(uri, domain.QRGenerationOptions{
+			Size:            256,
+			Format:          "png",
+			ForegroundColor: color.RGBA{0, 0, 0, 255},
+			BackgroundColor: color.RGBA{255, 255, 255, 255},
+			ErrorCorrection: 1, // Medium
+			Border:          4,
+		})
+		if err != nil {
+			// Log error but don't fail the setup; the secret can be typed in
+			fmt.Printf("Failed to generate 2FA QR code: %v\n", err)
+		} else {
+			response.QRCode = "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+		}
+	}
+
+	return response, nil
+}
+
+func (s *authService) EnableTwoFactor(ctx context.Context, userID uint, req domain.TwoFactorCodeRequest) (*domain.RecoveryCodesResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.IsTwoFactorEnabled() {
+		return nil, domain.ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, domain.ErrTwoFactorSetupRequired
+	}
+
+	// Only an authenticator code proves the app was set up
+	step, ok := verifyTOTP(user.TOTPSecret, req.Code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return nil, domain.ErrInvalidTwoFactorCode
+	}
+
+	codes, err := s.issueRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user.TOTPEnabledAt = &now
+	user.TOTPLastStep = step
+	user.UpdatedAt = now
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+	recordAudit(ctx, s.auditRepo, user.ID, domain.AuditUserTwoFactorEnabled, domain.AuditTargetUser, user.ID, nil)
+
+	return codes, nil
+}
+
+func (s *authService) DisableTwoFactor(ctx context.Context, userID uint, req domain.DisableTwoFactorRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.IsTwoFactorEnabled() {
+		return domain.ErrTwoFactorNotEnabled
+	}
+	if user.TwoFactorRequired || s.configRepo.IsTwoFactorRequired() {
+		return domain.ErrTwoFactorRequired
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return domain.ErrInvalidCredentials
+	}
+	if err := s.verifySecondFactor(ctx, user, req.Code); err != nil {
+		return err
+	}
+
+	if err := s.recoveryRepo.DeleteByUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	user.TOTPSecret = ""
+	user.TOTPEnabledAt = nil
+	user.TOTPLastStep = 0
+	user.UpdatedAt = time.Now()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+	recordAudit(ctx, s.auditRepo, user.ID, domain.AuditUserTwoFactorDisabled, domain.AuditTargetUser, user.ID, nil)
+
+	return nil
+}
+
+func (s *authService) RegenerateRecoveryCodes(ctx context.Context, userID uint, req domain.TwoFactorCodeRequest) (*domain.RecoveryCodesResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.IsTwoFactorEnabled() {
+		return nil, domain.ErrTwoFactorNotEnabled
+	}
+
+	if err := s.verifySecondFactor(ctx, user, req.Code); err != nil {
+		return nil, err
+	}
+
+	return s.issueRecoveryCodes(ctx, user.ID)
+}
+
+// verifySecondFactor accepts an authenticator code or an unused recovery code.
+// A matched authenticator code is recorded at once, so a concurrent request with
+// the same code fails, and on user for the caller's own save.
+func (s *authService) verifySecondFactor(ctx context.Context, user *domain.User, code string) error {
+	if isTOTPCode(code) {
+		step, ok := verifyTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+		if !ok {
+			return domain.ErrInvalidTwoFactorCode
+		}
+		advanced, err := s.userRepo.AdvanceTOTPStep(ctx, user.ID, step)
+		if err != nil {
+			return fmt.Errorf("failed to record authenticator code: %w", err)
+		}
+		if !advanced {
+			return domain.ErrInvalidTwoFactorCode
+		}
+		user.TOTPLastStep = step
+		return nil
+	}
+
+	used, err := s.recoveryRepo.Consume(ctx, user.ID, hashRecoveryCode(code))
+	if err != nil {
+		return fmt.Errorf("failed to check recovery code: %w", err)
+	}
+	if !used {
+		return domain.ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// issueRecoveryCodes replaces the user's recovery codes and returns the new ones
+func (s *authService) issueRecoveryCodes(ctx context.Context, userID uint) (*domain.RecoveryCodesResponse, error) {
+	codes := make([]string, domain.RecoveryCodeCount)
+	hashes := make([]string, domain.RecoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = hashRecoveryCode(code)
+	}
+
+	if err := s.recoveryRepo.ReplaceForUser(ctx, userID, hashes); err != nil {
+		return nil, fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+	return &domain.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func generateRecoveryCode() (string, error) {
+	raw := make([]byte, recoveryCodeLength)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+
+	var b strings.Builder
+	for i, v := range raw {
+		if i == recoveryCodeLength/2 {
+			b.WriteByte('-')
+		}
+		b.WriteByte(recoveryCodeAlphabet[int(v)%len(recoveryCodeAlphabet)])
+	}
+	return b.String(), nil
+}
+
+// hashRecoveryCode ignores case, spaces and dashes so codes can be typed loosely
+func hashRecoveryCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func mfaChallengeKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "mfa_challenge:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,356 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"url-shortener/internal/core/domain"
+)
+
+func TestTOTPCode_RFC6238Vectors(t *testing.T) {
+	// Appendix B of RFC 6238 uses the ASCII secret "12345678901234567890" with
+	// 8-digit codes; the 6-digit codes are their last six digits
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := totpCode(secret, totpStep(time.Unix(tt.unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, code, "time %d", tt.unix)
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret, err := generateTOTPSecret()
+	assert.NoError(t, err)
+
+	now := time.Now()
+	step := totpStep(now)
+	code, _ := totpCode(secret, step)
+	previous, _ := totpCode(secret, step-1)
+	stale, _ := totpCode(secret, step-3)
+
+	matched, ok := verifyTOTP(secret, code, now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, step, matched)
+
+	// One step of clock drift is tolerated, more is not
+	_, ok = verifyTOTP(secret, previous, now, 0)
+	assert.True(t, ok)
+	_, ok = verifyTOTP(secret, stale, now, 0)
+	assert.False(t, ok)
+
+	// A code cannot be used twice
+	_, ok = verifyTOTP(secret, code, now, step)
+	assert.False(t, ok)
+
+	_, ok = verifyTOTP(secret, "12345", now, 0)
+	assert.False(t, ok)
+}
+
+func TestHashRecoveryCode_Normalizes(t *testing.T) {
+	code, err := generateRecoveryCode()
+	assert.NoError(t, err)
+	assert.Len(t, code, recoveryCodeLength+1)
+
+	assert.Equal(t, hashRecoveryCode(code), hashRecoveryCode(strings.ToUpper(strings.ReplaceAll(code, "-", " "))))
+}
+
+func (suite *AuthServiceTestSuite) twoFactorUser() *domain.User {
+	secret, _ := generateTOTPSecret()
+	hashedPassword, _ := suite.authService.hashPassword("password123")
+	enabledAt := time.Now().Add(-time.Hour)
+	return &domain.User{
+		ID:            1,
+		Email:         "test@example.com",
+		Password:      hashedPassword,
+		IsActive:      true,
+		TOTPSecret:    secret,
+		TOTPEnabledAt: &enabledAt,
+	}
+}
+
+func (suite *AuthServiceTestSuite) TestLogin_TwoFactorChallenge() {
+	ctx := context.Background()
+	user := suite.twoFactorUser()
+
+	suite.mockUserRepo.On("GetByEmail", ctx, user.Email).Return(user, nil)
+	suite.mockCacheRepo.On("Set", ctx, mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "mfa_challenge:")
+	}), user.ID, mfaChallengeTTL).Return(nil)
+
+	response, err := suite.authService.Login(ctx, domain.LoginRequest{
+		Email:    user.Email,
+		Password: "password123",
+	}, domain.SessionMetadata{})
+
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), response.MFARequired)
+	assert.NotEmpty(suite.T(), response.MFAToken)
+	assert.Empty(suite.T(), response.AccessToken)
+	assert.Empty(suite.T(), response.RefreshToken)
+
+	// Only a hash of the challenge token is stored
+	key := suite.mockCacheRepo.Calls[0].Arguments.String(1)
+	assert.Equal(suite.T(), mfaChallengeKey(response.MFAToken), key)
+	assert.NotContains(suite.T(), key, response.MFAToken)
+
+	// No tokens or session until the second factor is checked
+	suite.mockJWTRepo.AssertNotCalled(suite.T(), "GenerateAccessToken", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	suite.mockSessions.AssertNotCalled(suite.T(), "Create", mock.Anything, mock.Anything)
+}
+
+// expectChallenge sets up a pending challenge for user that has not been rate limited
+func (suite *AuthServiceTestSuite) expectChallenge(ctx context.Context, token string, user *domain.User) string {
+	key := mfaChallengeKey(token)
+	suite.mockCacheRepo.On("Get", ctx, key).Return("1", nil)
+	suite.mockCacheRepo.On("IncrementRateLimit", ctx, key+":attempts", mfaChallengeTTL).Return(int64(1), nil)
+	suite.mockUserRepo.On("GetByID", ctx, user.ID).Return(user, nil)
+	return key
+}
+
+func (suite *AuthServiceTestSuite) TestCompleteMFALogin_TOTP() {
+	ctx := context.Background()
+	user := suite.twoFactorUser()
+	key := suite.expectChallenge(ctx, "challenge", user)
+
+	step := totpStep(time.Now())
+	code, _ := totpCode(user.TOTPSecret, step)
+
+	suite.mockUserRepo.On("AdvanceTOTPStep", ctx, user.ID, step).Return(true, nil)
+	suite.mockCacheRepo.On("Del", ctx, []string{key, key + ":attempts"}).Return(nil)
+	suite.mockJWTRepo.On("GenerateAccessToken", user.ID, user.Email, domain.RoleUser, mock.AnythingOfType("string")).Return("access_token", nil)
+	suite.mockJWTRepo.On("GenerateRefreshToken", user.ID, mock.AnythingOfType("string")).Return("refresh_token", nil)
+	suite.mockSessions.On("Create", ctx, mock.AnythingOfType("*domain.Session")).Return(nil)
+	suite.mockUserRepo.On("Update", ctx, user).Return(nil)
+
+	response, err := suite.authService.CompleteMFALogin(ctx, domain.MFALoginRequest{
+		MFAToken: "challenge",
+		Code:     code,
+	}, domain.SessionMetadata{})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "access_token", response.AccessToken)
+	assert.Equal(suite.T(), "refresh_token", response.RefreshToken)
+	assert.False(suite.T(), response.MFARequired)
+
+	// The matched step is saved so the same code cannot be used again
+	assert.Equal(suite.T(), step, user.TOTPLastStep)
+	suite.mockUserRepo.AssertExpectations(suite.T())
+	suite.mockCacheRepo.AssertExpectations(suite.T())
+}
+
+func (suite *AuthServiceTestSuite) TestCompleteMFALogin_ReplayedTOTP() {
+	ctx := context.Background()
+	user := suite.twoFactorUser()
+	suite.expectChallenge(ctx, "challenge", user)
+
+	step := totpStep(time.Now())
+	code, _ := totpCode(user.TOTPSecret, step)
+
+	// A concurrent request with the same code recorded its step first
+	suite.mockUserRepo.On("AdvanceTOTPStep", ctx, user.ID, step).Return(false, nil)
+
+	_, err := suite.authService.CompleteMFALogin(ctx, domain.MFALoginRequest{
+		MFAToken: "challenge",
+		Code:     code,
+	}, domain.SessionMetadata{})
+
+	assert.Equal(suite.T(), domain.ErrInvalidTwoFactorCode, err)
+	suite.mockSessions.AssertNotCalled(suite.T(), "Create", mock.Anything, mock.Anything)
+}
+
+func (suite *AuthServiceTestSuite) TestCompleteMFALogin_RecoveryCode() {
+	ctx := context.Background()
+	user := suite.twoFactorUser()
+	key := suite.expectChallenge(ctx, "challenge", user)
+
+	suite.mockRecovery.On("Consume", ctx, user.ID, hashRecoveryCode("abcde-fghjk")).Return(true, nil)
+	suite.mockCacheRepo.On("Del", ctx, []string{key, key + ":attempts"}).Return(nil)
+	suite.mockJWTRepo.On("GenerateAccessToken", user.ID, user.Email, domain.RoleUser, mock.AnythingOfType("string")).Return("access_token", nil)
+	suite.mockJWTRepo.On("GenerateRefreshToken", user.ID, mock.AnythingOfType("string")).Return("refresh_token", nil)
+	suite.mockSessions.On("Create", ctx, mock.AnythingOfType("*domain.Session")).Return(nil)
+	suite.mockUserRepo.On("Update", ctx, user).Return(nil)
+
+	response, err := suite.authService.CompleteMFALogin(ctx, domain.MFALoginRequest{
+		MFAToken: "challenge",
+		Code:     "ABCDE FGHJK",
+	}, domain.SessionMetadata{})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "access_token", response.AccessToken)
+	suite.mockRecovery.AssertExpectations(suite.T())
+}
+
+func (suite *AuthServiceTestSuite) TestCompleteMFALogin_InvalidCode() {
+	ctx := context.Background()
+	user := suite.twoFactorUser()
+	suite.expectChallenge(ctx, "challenge", user)
+
+	suite.mockRecovery.On("Consume", ctx, user.ID, mock.AnythingOfType("string")).Return(false, nil)
+
+	_, err := suite.authService.CompleteMFALogin(ctx, domain.MFALoginRequest{
+		MFAToken: "challenge",
+		Code:     "used1-code2",
+	}, domain.SessionMetadata{})
+
+	assert.Equal(suite.T(), domain.ErrInvalidTwoFactorCode, err)
+
+	// The challenge stays valid for another attempt
+	suite.mockCacheRepo.AssertNotCalled(suite.T(), "Del", mock.Anything, mock.Anything)
+	suite.mockSessions.AssertNotCalled(suite.T(), "Create", mock.Anything, mock.Anything)
+}
+
+func (suite *AuthServiceTestSuite) TestCompleteMFALogin_TooManyAttempts() {
+	ctx := context.Background()
+	key := mfaChallengeKey("challenge")
+
+	suite.mockCacheRepo.On("Get", ctx, key).Return("1", nil)
+	suite.mockCacheRepo.On("IncrementRateLimit", ctx, key+":attempts", mfaChallengeTTL).Return(int64(maxMFAAttempts+1), nil)
+	suite.mockCacheRepo.On("Del", ctx, []string{key}).Return(nil)
+
+	_, err := suite.authService.CompleteMFALogin(ctx, domain.MFALoginRequest{
+		MFAToken: "challenge",
+		Code:     "123456",
+	}, domain.SessionMetadata{})
+
+	assert.Equal(suite.T(), domain.ErrTooManyRequests, err)
+	suite.mockCacheRepo.AssertExpectations(suite.T())
+}
+
+func (suite *AuthServiceTestSuite) TestCompleteMFALogin_ExpiredChallenge() {
+	ctx := context.Background()
+	suite.mockCacheRepo.On("Get", ctx, mfaChallengeKey("expired")).Return("", nil)
+
+	_, err := suite.authService.CompleteMFALogin(ctx, domain.MFALoginRequest{
+		MFAToken: "expired",
+		Code:     "123456",
+	}, domain.SessionMetadata{})
+
+	assert.Equal(suite.T(), domain.ErrInvalidToken, err)
+}
+
+func (suite *AuthServiceTestSuite) TestSetupTwoFactor() {
+	ctx := context.Background()
+	user := &domain.User{ID: 1, Email: "test@example.com", IsActive: true}
+
+	suite.mockUserRepo.On("GetByID", ctx, user.ID).Return(user, nil)
+	suite.mockUserRepo.On("Update", ctx, user).Return(nil)
+	suite.mockQR.On("GenerateQRCode", mock.AnythingOfType("string"), mock.AnythingOfType("domain.QRGenerationOptions")).Return([]byte("png"), nil)
+
+	response, err := suite.authService.SetupTwoFactor(ctx, user.ID)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), user.TOTPSecret, response.Secret)
+	assert.True(suite.T(), strings.HasPrefix(response.OTPAuthURL, "otpauth://totp/URL%20Shortener:test@example.com?"))
+	assert.Contains(suite.T(), response.OTPAuthURL, "secret="+response.Secret)
+	assert.Equal(suite.T(), "data:image/png;base64,cG5n", response.QRCode)
+
+	// The QR code encodes the otpauth URI
+	assert.Equal(suite.T(), response.OTPAuthURL, suite.mockQR.Calls[0].Arguments.String(0))
+
+	// Not enabled until a code is confirmed
+	assert.False(suite.T(), user.IsTwoFactorEnabled())
+}
+
+func (suite *AuthServiceTestSuite) TestEnableTwoFactor() {
+	ctx := context.Background()
+	secret, _ := generateTOTPSecret()
+	user := &domain.User{ID: 1, Email: "test@example.com", IsActive: true, TOTPSecret: secret}
+	code, _ := totpCode(secret, totpStep(time.Now()))
+
+	suite.mockUserRepo.On("GetByID", ctx, user.ID).Return(user, nil)
+	suite.mockUserRepo.On("Update", ctx, user).Return(nil)
+	suite.mockRecovery.On("ReplaceForUser", ctx, user.ID, mock.AnythingOfType("[]string")).Return(nil)
+
+	response, err := suite.authService.EnableTwoFactor(ctx, user.ID, domain.TwoFactorCodeRequest{Code: code})
+
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), user.IsTwoFactorEnabled())
+	assert.Len(suite.T(), response.RecoveryCodes, domain.RecoveryCodeCount)
+
+	// Only hashes of the recovery codes are stored
+	hashes := suite.mockRecovery.Calls[0].Arguments.Get(2).([]string)
+	for i, code := range response.RecoveryCodes {
+		assert.Equal(suite.T(), hashRecoveryCode(code), hashes[i])
+	}
+}
+
+func (suite *AuthServiceTestSuite) TestEnableTwoFactor_RequiresSetup() {
+	ctx := context.Background()
+	user := &domain.User{ID: 1, Email: "test@example.com", IsActive: true}
+	suite.mockUserRepo.On("GetByID", ctx, user.ID).Return(user, nil)
+
+	_, err := suite.authService.EnableTwoFactor(ctx, user.ID, domain.TwoFactorCodeRequest{Code: "123456"})
+
+	assert.Equal(suite.T(), domain.ErrTwoFactorSetupRequired, err)
+}
+
+func (suite *AuthServiceTestSuite) TestDisableTwoFactor() {
+	ctx := context.Background()
+	user := suite.twoFactorUser()
+	step := totpStep(time.Now())
+	code, _ := totpCode(user.TOTPSecret, step)
+
+	suite.mockUserRepo.On("GetByID", ctx, user.ID).Return(user, nil)
+	suite.mockUserRepo.On("AdvanceTOTPStep", ctx, user.ID, step).Return(true, nil)
+	suite.mockUserRepo.On("Update", ctx, user).Return(nil)
+	suite.mockConfigRepo.On("IsTwoFactorRequired").Return(false)
+	suite.mockRecovery.On("DeleteByUser", ctx, user.ID).Return(nil)
+
+	err := suite.authService.DisableTwoFactor(ctx, user.ID, domain.DisableTwoFactorRequest{
+		Password: "password123",
+		Code:     code,
+	})
+
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), user.IsTwoFactorEnabled())
+	assert.Empty(suite.T(), user.TOTPSecret)
+	suite.mockRecovery.AssertExpectations(suite.T())
+}
+
+func (suite *AuthServiceTestSuite) TestRegenerateRecoveryCodes() {
+	ctx := context.Background()
+	user := suite.twoFactorUser()
+	step := totpStep(time.Now())
+	code, _ := totpCode(user.TOTPSecret, step)
+
+	suite.mockUserRepo.On("GetByID", ctx, user.ID).Return(user, nil)
+	suite.mockUserRepo.On("AdvanceTOTPStep", ctx, user.ID, step).Return(true, nil)
+	suite.mockRecovery.On("ReplaceForUser", ctx, user.ID, mock.AnythingOfType("[]string")).Return(nil)
+
+	response, err := suite.authService.RegenerateRecoveryCodes(ctx, user.ID, domain.TwoFactorCodeRequest{Code: code})
+
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), response.RecoveryCodes, domain.RecoveryCodeCount)
+	// The code's step was already saved, so nothing else is written to the user
+	suite.mockUserRepo.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+}
+
+func (suite *AuthServiceTestSuite) TestDisableTwoFactor_Required() {
+	ctx := context.Background()
+	user := suite.twoFactorUser()
+	user.TwoFactorRequired = true
+	suite.mockUserRepo.On("GetByID", ctx, user.ID).Return(user, nil)
+
+	err := suite.authService.DisableTwoFactor(ctx, user.ID, domain.DisableTwoFactorRequest{
+		Password: "password123",
+		Code:     "123456",
+	})
+
+	assert.Equal(suite.T(), domain.ErrTwoFactorRequired, err)
+	suite.mockUserRepo.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+}
@@ -18,6 +18,11 @@ type urlService struct {
 	clickRepo   ports.ClickRepository
 	cacheRepo   ports.CacheService
 	configRepo  ports.ConfigService
+	clickQueue  ports.ClickQueue
+
+	// workspaceRepo decides who may manage workspace links
+	workspaceRepo ports.WorkspaceRepository
+	auditRepo     ports.AuditRepository
 }
 
 const (
@@ -31,12 +36,18 @@ func NewURLService(
 	clickRepo ports.ClickRepository,
 	cacheRepo ports.CacheService,
 	configRepo ports.ConfigService,
+	clickQueue ports.ClickQueue,
+	workspaceRepo ports.WorkspaceRepository,
+	auditRepo ports.AuditRepository,
 ) ports.URLService {
 	return &urlService{
-		urlRepo:    urlRepo,
-		clickRepo:  clickRepo,
-		cacheRepo:  cacheRepo,
-		configRepo: configRepo,
+		urlRepo:       urlRepo,
+		clickRepo:     clickRepo,
+		cacheRepo:     cacheRepo,
+		configRepo:    configRepo,
+		clickQueue:    clickQueue,
+		workspaceRepo: workspaceRepo,
+		auditRepo:     auditRepo,
 	}
 }
 
@@ -51,6 +62,24 @@ func (s *urlService) ShortenURL(ctx context.Context, req domain.ShortenURLReques
 		return nil, domain.ErrInvalidURL
 	}
 
+	// Only editors and owners may add links to a workspace
+	if req.WorkspaceID != nil {
+		target := &domain.ShortURL{WorkspaceID: req.WorkspaceID}
+		if err := authorizeURL(ctx, s.workspaceRepo, target, req.UserID, domain.PermissionEditLinks); err != nil {
+			return nil, err
+		}
+	}
+
+	// Check the variant and rule destinations too
+	variants, err := s.buildVariants(req.Variants)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := s.buildRedirectRules(req.RedirectRules)
+	if err != nil {
+		return nil, err
+	}
+
 	// Generate unique short code
 	shortCode, err := s.generateUniqueShortCode(ctx, req.CustomAlias)
 	if err != nil {
@@ -62,12 +91,15 @@ func (s *urlService) ShortenURL(ctx context.Context, req domain.ShortenURLReques
 		ShortCode:   shortCode,
 		OriginalURL: req.OriginalURL,
 		UserID:      req.UserID,
+		WorkspaceID: req.WorkspaceID,
 		Title:       req.Title,
 		Description: req.Description,
 		IsActive:    true,
 		ClickCount:  0,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
+		// The first revision starts the link's history
+		Revisions: []domain.URLRevision{*domain.NewURLRevision(0, "", req.OriginalURL, req.UserID)},
 	}
 
 	// Set expiration if provided
@@ -75,6 +107,13 @@ func (s *urlService) ShortenURL(ctx context.Context, req domain.ShortenURLReques
 		shortURL.ExpiresAt = req.ExpiresAt
 	}
 
+	for _, v := range variants {
+		shortURL.Variants = append(shortURL.Variants, *v)
+	}
+	for _, rule := range rules {
+		shortURL.RedirectRules = append(shortURL.RedirectRules, *rule)
+	}
+
 	// Set password if provided
 	if req.Password != "" {
 		hashedPassword, err := s.hashPassword(req.Password)
@@ -95,6 +134,8 @@ func (s *urlService) ShortenURL(ctx context.Context, req domain.ShortenURLReques
 		fmt.Printf("Failed to cache URL: %v", err)
 	}
 
+	recordAudit(ctx, s.auditRepo, req.UserID, domain.AuditURLCreated, domain.AuditTargetURL, shortURL.ID, domain.DiffAudit(nil, shortURL.AuditSnapshot()))
+
 	return shortURL, nil
 }
 
@@ -140,12 +181,38 @@ func (s *urlService) UpdateURL(ctx context.Context, id uint, userID uint, req do
 		return nil, err
 	}
 
-	// Check ownership
-	if shortURL.UserID != userID {
-		return nil, domain.ErrUnauthorized
+	// Check the user may edit the link
+	if err := authorizeURL(ctx, s.workspaceRepo, shortURL, userID, domain.PermissionEditLinks); err != nil {
+		return nil, err
+	}
+	before := shortURL.AuditSnapshot()
+
+	// A new destination is checked like a new link's
+	if req.OriginalURL != nil && !s.isValidURL(*req.OriginalURL) {
+		return nil, domain.ErrInvalidURL
+	}
+
+	// Check the new variants and rules before changing anything, so a broken
+	// rule is rejected rather than saved
+	var variants []*domain.URLVariant
+	if req.Variants != nil {
+		if variants, err = s.buildVariants(*req.Variants); err != nil {
+			return nil, err
+		}
+	}
+	var rules []*domain.RedirectRule
+	if req.RedirectRules != nil {
+		if rules, err = s.buildRedirectRules(*req.RedirectRules); err != nil {
+			return nil, err
+		}
 	}
 
 	// Update fields
+	var revision *domain.URLRevision
+	if req.OriginalURL != nil && *req.OriginalURL != shortURL.OriginalURL {
+		revision = domain.NewURLRevision(shortURL.ID, shortURL.OriginalURL, *req.OriginalURL, userID)
+		shortURL.OriginalURL = *req.OriginalURL
+	}
 	if req.Title != nil {
 		shortURL.Title = *req.Title
 	}
@@ -158,26 +225,66 @@ func (s *urlService) UpdateURL(ctx context.Context, id uint, userID uint, req do
 	if req.ExpiresAt != nil {
 		shortURL.ExpiresAt = req.ExpiresAt
 	}
+	if req.Password != nil {
+		if *req.Password == "" {
+			shortURL.Password = nil
+		} else {
+			hashedPassword, err := s.hashPassword(*req.Password)
+			if err != nil {
+				return nil, fmt.Errorf("failed to hash password: %w", err)
+			}
+			shortURL.Password = &hashedPassword
+		}
+	}
 
 	shortURL.UpdatedAt = time.Now()
 
-	// Update in database
-	if err := s.urlRepo.Update(ctx, shortURL); err != nil {
+	// Update in database, with the revision when the destination changed
+	if revision != nil {
+		err = s.urlRepo.UpdateWithRevision(ctx, shortURL, revision)
+	} else {
+		err = s.urlRepo.Update(ctx, shortURL)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to update URL: %w", err)
 	}
 
+	if req.Variants != nil {
+		if err := s.urlRepo.ReplaceVariants(ctx, shortURL.ID, variants); err != nil {
+			return nil, fmt.Errorf("failed to update variants: %w", err)
+		}
+		shortURL.Variants = make([]domain.URLVariant, 0, len(variants))
+		for _, v := range variants {
+			shortURL.Variants = append(shortURL.Variants, *v)
+		}
+	}
+
+	if req.RedirectRules != nil {
+		if err := s.urlRepo.ReplaceRedirectRules(ctx, shortURL.ID, rules); err != nil {
+			return nil, fmt.Errorf("failed to update redirect rules: %w", err)
+		}
+		shortURL.RedirectRules = make([]domain.RedirectRule, 0, len(rules))
+		for _, rule := range rules {
+			shortURL.RedirectRules = append(shortURL.RedirectRules, *rule)
+		}
+	}
+
 	// Update cache
-	if shortURL.IsActive {
+	if shortURL.IsActive && revision == nil {
 		if err := s.cacheRepo.CacheURL(ctx, shortURL.ShortCode, shortURL.OriginalURL, shortURL.UserID, time.Hour*24); err != nil {
 			fmt.Printf("Failed to update cache: %v", err)
 		}
 	} else {
-		// Remove from cache if deactivated
+		// Remove from cache if deactivated or sent somewhere else
 		if err := s.cacheRepo.InvalidateURL(ctx, shortURL.ShortCode); err != nil {
 			fmt.Printf("Failed to remove from cache: %v", err)
 		}
 	}
 
+	if changes := domain.DiffAudit(before, shortURL.AuditSnapshot()); changes != nil {
+		recordAudit(ctx, s.auditRepo, userID, domain.AuditURLUpdated, domain.AuditTargetURL, shortURL.ID, changes)
+	}
+
 	return shortURL, nil
 }
 
@@ -188,9 +295,9 @@ func (s *urlService) DeleteURL(ctx context.Context, id uint, userID uint) error
 		return err
 	}
 
-	// Check ownership
-	if shortURL.UserID != userID {
-		return domain.ErrUnauthorized
+	// Check the user may edit the link
+	if err := authorizeURL(ctx, s.workspaceRepo, shortURL, userID, domain.PermissionEditLinks); err != nil {
+		return err
 	}
 
 	// Delete from database
@@ -203,23 +310,95 @@ func (s *urlService) DeleteURL(ctx context.Context, id uint, userID uint) error
 		fmt.Printf("Failed to remove from cache: %v", err)
 	}
 
+	recordAudit(ctx, s.auditRepo, userID, domain.AuditURLDeleted, domain.AuditTargetURL, shortURL.ID, domain.DiffAudit(shortURL.AuditSnapshot(), nil))
+
 	return nil
 }
 
+func (s *urlService) GetURLHistory(ctx context.Context, id uint, userID uint, offset, limit int) ([]*domain.URLRevision, int64, error) {
+	shortURL, err := s.urlRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Check the user may see the link
+	if err := authorizeURL(ctx, s.workspaceRepo, shortURL, userID, domain.PermissionViewLinks); err != nil {
+		return nil, 0, err
+	}
+
+	revisions, total, err := s.urlRepo.GetRevisions(ctx, shortURL.ID, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get URL history: %w", err)
+	}
+	return revisions, total, nil
+}
+
+func (s *urlService) RollbackURL(ctx context.Context, id uint, userID uint, revisionID uint) (*domain.ShortURL, error) {
+	shortURL, err := s.urlRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the user may edit the link
+	if err := authorizeURL(ctx, s.workspaceRepo, shortURL, userID, domain.PermissionEditLinks); err != nil {
+		return nil, err
+	}
+
+	target, err := s.urlRepo.GetRevision(ctx, shortURL.ID, revisionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing to do when the link already goes there
+	if target.NewURL == shortURL.OriginalURL {
+		return shortURL, nil
+	}
+
+	before := shortURL.AuditSnapshot()
+	revision := domain.NewURLRevision(shortURL.ID, shortURL.OriginalURL, target.NewURL, userID)
+	revision.RolledBackFrom = &target.ID
+	shortURL.OriginalURL = target.NewURL
+	shortURL.UpdatedAt = time.Now()
+
+	if err := s.urlRepo.UpdateWithRevision(ctx, shortURL, revision); err != nil {
+		return nil, fmt.Errorf("failed to update URL: %w", err)
+	}
+
+	// Drop the old destination from the cache
+	if err := s.cacheRepo.InvalidateURL(ctx, shortURL.ShortCode); err != nil {
+		fmt.Printf("Failed to remove from cache: %v", err)
+	}
+
+	recordAudit(ctx, s.auditRepo, userID, domain.AuditURLRolledBack, domain.AuditTargetURL, shortURL.ID, domain.DiffAudit(before, shortURL.AuditSnapshot()))
+
+	return shortURL, nil
+}
+
 func (s *urlService) RecordClick(ctx context.Context, shortURL *domain.ShortURL, clickData domain.ClickData) error {
 	// Create click record
 	click := &domain.Click{
-		ShortURLID: shortURL.ID,
-		IPAddress:  clickData.IPAddress,
-		UserAgent:  clickData.UserAgent,
-		Referer:    clickData.Referer,
-		Country:    clickData.Country,
-		Region:     clickData.Region,
-		City:       clickData.City,
-		Device:     clickData.Device,
-		Browser:    clickData.Browser,
-		OS:         clickData.OS,
-		ClickedAt:  time.Now(),
+		ShortURLID:     shortURL.ID,
+		IPAddress:      clickData.IPAddress,
+		UserAgent:      clickData.UserAgent,
+		Referer:        clickData.Referer,
+		Country:        clickData.Country,
+		Region:         clickData.Region,
+		City:           clickData.City,
+		Device:         clickData.Device,
+		Browser:        clickData.Browser,
+		BrowserVersion: clickData.BrowserVersion,
+		OS:             clickData.OS,
+		OSVersion:      clickData.OSVersion,
+		VariantID:      clickData.VariantID,
+		ClickedAt:      time.Now(),
+	}
+
+	// Hand off to the batch writers when a queue is configured
+	if s.clickQueue != nil {
+		if err := s.clickQueue.Enqueue(ctx, click); err != nil {
+			return fmt.Errorf("failed to enqueue click: %w", err)
+		}
+		return nil
 	}
 
 	// Save click record
@@ -248,9 +427,9 @@ func (s *urlService) GetURLStats(ctx context.Context, id uint, userID uint) (*do
 		return nil, err
 	}
 
-	// Check ownership
-	if shortURL.UserID != userID {
-		return nil, domain.ErrUnauthorized
+	// Check the user may see the link
+	if err := authorizeURL(ctx, s.workspaceRepo, shortURL, userID, domain.PermissionViewLinks); err != nil {
+		return nil, err
 	}
 
 	// Get click stats
@@ -293,7 +472,26 @@ func (s *urlService) ValidatePassword(ctx context.Context, shortCode, password s
 		return true, nil // No password required
 	}
 
-	return s.checkPassword(password, *shortURL.Password), nil
+	return s.checkPassword(ctx, shortURL, password), nil
+}
+
+func (s *urlService) ChooseVariant(ctx context.Context, shortURL *domain.ShortURL, assignedVariantID uint) (*domain.URLVariant, error) {
+	if !shortURL.HasVariants() {
+		return nil, nil
+	}
+
+	// Stick with the earlier assignment unless that variant was removed or paused
+	if assignedVariantID != 0 {
+		if variant := shortURL.ActiveVariant(assignedVariantID); variant != nil {
+			return variant, nil
+		}
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(shortURL.TotalVariantWeight())))
+	if err != nil {
+		return nil, fmt.Errorf("failed to choose variant: %w", err)
+	}
+	return shortURL.PickVariant(int(n.Int64())), nil
 }
 
 func (s *urlService) CleanupExpiredURLs(ctx context.Context) error {
@@ -379,6 +577,48 @@ func (s *urlService) isValidURL(urlStr string) bool {
 	return u.Scheme == "http" || u.Scheme == "https"
 }
 
+// buildVariants validates a variant set and turns it into models
+func (s *urlService) buildVariants(reqs []domain.VariantRequest) ([]*domain.URLVariant, error) {
+	if err := domain.ValidateVariants(reqs); err != nil {
+		return nil, err
+	}
+
+	variants := make([]*domain.URLVariant, 0, len(reqs))
+	for _, req := range reqs {
+		if !s.isValidURL(req.DestinationURL) {
+			return nil, domain.ErrInvalidURL
+		}
+		variants = append(variants, &domain.URLVariant{
+			Name:           strings.TrimSpace(req.Name),
+			DestinationURL: req.DestinationURL,
+			Weight:         req.Weight,
+		})
+	}
+	return variants, nil
+}
+
+// buildRedirectRules validates a rule set and turns it into models, in order
+func (s *urlService) buildRedirectRules(reqs []domain.RedirectRuleRequest) ([]*domain.RedirectRule, error) {
+	normalized, err := domain.ValidateRedirectRules(reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]*domain.RedirectRule, 0, len(normalized))
+	for i, req := range normalized {
+		if !s.isValidURL(req.DestinationURL) {
+			return nil, fmt.Errorf("%w: rule %d: destination_url must be an http or https URL", domain.ErrInvalidRedirectRule, i+1)
+		}
+		rules = append(rules, &domain.RedirectRule{
+			Position:       i,
+			Name:           req.Name,
+			Conditions:     req.Conditions,
+			DestinationURL: req.DestinationURL,
+		})
+	}
+	return rules, nil
+}
+
 func (s *urlService) isValidShortCode(shortCode string) bool {
 	if len(shortCode) < 3 || len(shortCode) > 20 {
 		return false
@@ -392,14 +632,3 @@ func (s *urlService) isValidShortCode(shortCode string) bool {
 
 	return true
 }
-
-func (s *urlService) hashPassword(password string) (string, error) {
-	// Simple hash for URL passwords (not as secure as bcrypt for user passwords)
-	// In production, you might want to use bcrypt here too
-	return fmt.Sprintf("%x", password), nil
-}
-
-func (s *urlService) checkPassword(password, hashedPassword string) bool {
-	expectedHash := fmt.Sprintf("%x", password)
-	return expectedHash == hashedPassword
-}
\ No newline at end of file
@@ -2,12 +2,14 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
 	"url-shortener/internal/core/domain"
 )
 
@@ -18,6 +20,7 @@ type URLServiceTestSuite struct {
 	mockClickRepo *MockClickRepository
 	mockCacheRepo *MockCacheService
 	mockConfigRepo *MockConfigService
+	mockWorkspaceRepo *MockWorkspaceRepository
 }
 
 func TestURLServiceSuite(t *testing.T) {
@@ -29,12 +32,14 @@ func (suite *URLServiceTestSuite) SetupTest() {
 	suite.mockClickRepo = &MockClickRepository{}
 	suite.mockCacheRepo = &MockCacheService{}
 	suite.mockConfigRepo = &MockConfigService{}
+	suite.mockWorkspaceRepo = &MockWorkspaceRepository{}
 	
 	suite.urlService = &urlService{
-		urlRepo:    suite.mockURLRepo,
-		clickRepo:  suite.mockClickRepo,
-		cacheRepo:  suite.mockCacheRepo,
-		configRepo: suite.mockConfigRepo,
+		urlRepo:       suite.mockURLRepo,
+		clickRepo:     suite.mockClickRepo,
+		cacheRepo:     suite.mockCacheRepo,
+		configRepo:    suite.mockConfigRepo,
+		workspaceRepo: suite.mockWorkspaceRepo,
 	}
 }
 
@@ -62,6 +67,9 @@ func (suite *URLServiceTestSuite) TestShortenURL_Success() {
 	assert.Equal(suite.T(), req.Title, result.Title)
 	assert.True(suite.T(), result.IsActive)
 	assert.NotEmpty(suite.T(), result.ShortCode)
+	// The link's history starts with its first destination
+	assert.Len(suite.T(), result.Revisions, 1)
+	assert.Equal(suite.T(), req.OriginalURL, result.Revisions[0].NewURL)
 
 	suite.mockURLRepo.AssertExpectations(suite.T())
 	suite.mockCacheRepo.AssertExpectations(suite.T())
@@ -194,6 +202,48 @@ func (suite *URLServiceTestSuite) TestRecordClick_Success() {
 	suite.mockCacheRepo.AssertExpectations(suite.T())
 }
 
+func (suite *URLServiceTestSuite) TestRecordClick_Enqueued() {
+	ctx := context.Background()
+	shortURL := &domain.ShortURL{
+		ID:        1,
+		ShortCode: "abc123",
+	}
+	clickData := domain.ClickData{
+		IPAddress: "192.168.1.1",
+		Country:   "US",
+	}
+	mockQueue := &MockClickQueue{}
+	suite.urlService.clickQueue = mockQueue
+
+	// Mock expectations: nothing is written synchronously
+	mockQueue.On("Enqueue", ctx, mock.MatchedBy(func(click *domain.Click) bool {
+		return click.ShortURLID == shortURL.ID && click.Country == "US" && !click.ClickedAt.IsZero()
+	})).Return(nil)
+
+	// Execute
+	err := suite.urlService.RecordClick(ctx, shortURL, clickData)
+
+	// Assert
+	assert.NoError(suite.T(), err)
+
+	mockQueue.AssertExpectations(suite.T())
+	suite.mockClickRepo.AssertNotCalled(suite.T(), "Create", mock.Anything, mock.Anything)
+	suite.mockURLRepo.AssertNotCalled(suite.T(), "IncrementClickCount", mock.Anything, mock.Anything)
+}
+
+func (suite *URLServiceTestSuite) TestRecordClick_QueueFull() {
+	ctx := context.Background()
+	shortURL := &domain.ShortURL{ID: 1, ShortCode: "abc123"}
+	mockQueue := &MockClickQueue{}
+	suite.urlService.clickQueue = mockQueue
+
+	mockQueue.On("Enqueue", ctx, mock.AnythingOfType("*domain.Click")).Return(domain.ErrClickQueueFull)
+
+	err := suite.urlService.RecordClick(ctx, shortURL, domain.ClickData{})
+
+	assert.ErrorIs(suite.T(), err, domain.ErrClickQueueFull)
+}
+
 func (suite *URLServiceTestSuite) TestUpdateURL_Success() {
 	ctx := context.Background()
 	urlID := uint(1)
@@ -261,6 +311,458 @@ func (suite *URLServiceTestSuite) TestUpdateURL_Unauthorized() {
 	suite.mockURLRepo.AssertExpectations(suite.T())
 }
 
+func (suite *URLServiceTestSuite) TestShortenURL_WithVariants() {
+	ctx := context.Background()
+	req := domain.ShortenURLRequest{
+		OriginalURL: "https://example.com",
+		UserID:      1,
+		Variants: []domain.VariantRequest{
+			{Name: "A", DestinationURL: "https://example.com/a", Weight: 70},
+			{Name: "B", DestinationURL: "https://example.com/b", Weight: 30},
+		},
+	}
+
+	suite.mockURLRepo.On("ExistsByShortCode", ctx, mock.AnythingOfType("string")).Return(false, nil)
+	suite.mockURLRepo.On("Create", ctx, mock.AnythingOfType("*domain.ShortURL")).Return(nil)
+	suite.mockCacheRepo.On("CacheURL", ctx, mock.AnythingOfType("string"), req.OriginalURL, req.UserID, time.Hour*24).Return(nil)
+
+	result, err := suite.urlService.ShortenURL(ctx, req)
+
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), result.Variants, 2)
+	assert.Equal(suite.T(), "https://example.com/a", result.Variants[0].DestinationURL)
+	assert.Equal(suite.T(), 100, result.TotalVariantWeight())
+}
+
+func (suite *URLServiceTestSuite) TestShortenURL_InvalidVariants() {
+	ctx := context.Background()
+	tests := map[string][]domain.VariantRequest{
+		"single variant": {
+			{Name: "A", DestinationURL: "https://example.com/a", Weight: 1},
+		},
+		"duplicate names": {
+			{Name: "A", DestinationURL: "https://example.com/a", Weight: 1},
+			{Name: "a", DestinationURL: "https://example.com/b", Weight: 1},
+		},
+		"no weight": {
+			{Name: "A", DestinationURL: "https://example.com/a", Weight: 0},
+			{Name: "B", DestinationURL: "https://example.com/b", Weight: 0},
+		},
+		"negative weight": {
+			{Name: "A", DestinationURL: "https://example.com/a", Weight: 2},
+			{Name: "B", DestinationURL: "https://example.com/b", Weight: -1},
+		},
+	}
+
+	for name, variants := range tests {
+		req := domain.ShortenURLRequest{OriginalURL: "https://example.com", UserID: 1, Variants: variants}
+		_, err := suite.urlService.ShortenURL(ctx, req)
+		assert.Equal(suite.T(), domain.ErrInvalidVariants, err, name)
+	}
+
+	req := domain.ShortenURLRequest{
+		OriginalURL: "https://example.com",
+		UserID:      1,
+		Variants: []domain.VariantRequest{
+			{Name: "A", DestinationURL: "https://example.com/a", Weight: 1},
+			{Name: "B", DestinationURL: "ftp://example.com/b", Weight: 1},
+		},
+	}
+	_, err := suite.urlService.ShortenURL(ctx, req)
+	assert.Equal(suite.T(), domain.ErrInvalidURL, err)
+}
+
+func (suite *URLServiceTestSuite) TestUpdateURL_ReplacesVariants() {
+	ctx := context.Background()
+	existingURL := &domain.ShortURL{
+		ID:        1,
+		UserID:    1,
+		ShortCode: "abc123",
+		IsActive:  true,
+		Variants:  []domain.URLVariant{{ID: 7, Name: "A", DestinationURL: "https://example.com/a", Weight: 1}},
+	}
+	variants := []domain.VariantRequest{
+		{Name: "A", DestinationURL: "https://example.com/a2", Weight: 1},
+		{Name: "B", DestinationURL: "https://example.com/b", Weight: 3},
+	}
+
+	suite.mockURLRepo.On("GetByID", ctx, uint(1)).Return(existingURL, nil)
+	suite.mockURLRepo.On("Update", ctx, existingURL).Return(nil)
+	suite.mockURLRepo.On("ReplaceVariants", ctx, uint(1), mock.MatchedBy(func(v []*domain.URLVariant) bool {
+		return len(v) == 2 && v[0].DestinationURL == "https://example.com/a2" && v[1].Weight == 3
+	})).Return(nil)
+	suite.mockCacheRepo.On("CacheURL", ctx, "abc123", existingURL.OriginalURL, uint(1), time.Hour*24).Return(nil)
+
+	result, err := suite.urlService.UpdateURL(ctx, 1, 1, domain.UpdateURLRequest{Variants: &variants})
+
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), result.Variants, 2)
+	suite.mockURLRepo.AssertExpectations(suite.T())
+}
+
+func (suite *URLServiceTestSuite) TestUpdateURL_ClearsVariants() {
+	ctx := context.Background()
+	existingURL := &domain.ShortURL{
+		ID:        1,
+		UserID:    1,
+		ShortCode: "abc123",
+		IsActive:  true,
+		Variants:  []domain.URLVariant{{ID: 7, Name: "A", Weight: 1}, {ID: 8, Name: "B", Weight: 1}},
+	}
+	variants := []domain.VariantRequest{}
+
+	suite.mockURLRepo.On("GetByID", ctx, uint(1)).Return(existingURL, nil)
+	suite.mockURLRepo.On("Update", ctx, existingURL).Return(nil)
+	suite.mockURLRepo.On("ReplaceVariants", ctx, uint(1), []*domain.URLVariant{}).Return(nil)
+	suite.mockCacheRepo.On("CacheURL", ctx, "abc123", existingURL.OriginalURL, uint(1), time.Hour*24).Return(nil)
+
+	result, err := suite.urlService.UpdateURL(ctx, 1, 1, domain.UpdateURLRequest{Variants: &variants})
+
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), result.HasVariants())
+}
+
+func (suite *URLServiceTestSuite) TestChooseVariant() {
+	ctx := context.Background()
+	shortURL := &domain.ShortURL{
+		ID: 1,
+		Variants: []domain.URLVariant{
+			{ID: 1, Name: "A", Weight: 3},
+			{ID: 2, Name: "paused", Weight: 0},
+			{ID: 3, Name: "B", Weight: 1},
+		},
+	}
+
+	// Sticky assignments are kept while the variant is live
+	variant, err := suite.urlService.ChooseVariant(ctx, shortURL, 3)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), uint(3), variant.ID)
+
+	// Paused and unknown variants are redrawn, never landing on a paused one
+	for _, assigned := range []uint{0, 2, 99} {
+		for i := 0; i < 50; i++ {
+			variant, err := suite.urlService.ChooseVariant(ctx, shortURL, assigned)
+			assert.NoError(suite.T(), err)
+			assert.NotEqual(suite.T(), uint(2), variant.ID)
+		}
+	}
+
+	// Links without variants have nothing to choose
+	variant, err = suite.urlService.ChooseVariant(ctx, &domain.ShortURL{ID: 2}, 0)
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), variant)
+}
+
+func (suite *URLServiceTestSuite) TestPickVariant_FollowsWeights() {
+	shortURL := &domain.ShortURL{
+		Variants: []domain.URLVariant{
+			{ID: 1, Weight: 3},
+			{ID: 2, Weight: 0},
+			{ID: 3, Weight: 1},
+		},
+	}
+
+	picked := map[uint]int{}
+	for n := 0; n < shortURL.TotalVariantWeight(); n++ {
+		picked[shortURL.PickVariant(n).ID]++
+	}
+	assert.Equal(suite.T(), map[uint]int{1: 3, 3: 1}, picked)
+	assert.Nil(suite.T(), shortURL.PickVariant(4))
+}
+
+func (suite *URLServiceTestSuite) TestUpdateURL_ReplacesRedirectRules() {
+	ctx := context.Background()
+	existingURL := &domain.ShortURL{ID: 1, UserID: 1, ShortCode: "abc123", IsActive: true}
+	rules := []domain.RedirectRuleRequest{
+		{
+			Name:           " UK mobile ",
+			Conditions:     domain.RuleConditions{Countries: []string{"gb"}, Devices: []string{"Mobile"}},
+			DestinationURL: "https://example.co.uk/m",
+		},
+		{
+			Conditions: domain.RuleConditions{
+				ReferrerDomains: []string{"www.Example.org"},
+				TimeWindow:      &domain.TimeWindow{Start: "22:00", End: "06:00", Timezone: "Europe/Berlin"},
+			},
+			DestinationURL: "https://example.com/night",
+		},
+	}
+
+	suite.mockURLRepo.On("GetByID", ctx, uint(1)).Return(existingURL, nil)
+	suite.mockURLRepo.On("Update", ctx, existingURL).Return(nil)
+	suite.mockURLRepo.On("ReplaceRedirectRules", ctx, uint(1), mock.MatchedBy(func(r []*domain.RedirectRule) bool {
+		return len(r) == 2 &&
+			r[0].Name == "UK mobile" && r[0].Position == 0 &&
+			r[0].Conditions.Countries[0] == "GB" && r[0].Conditions.Devices[0] == "mobile" &&
+			r[1].Position == 1 && r[1].Conditions.ReferrerDomains[0] == "example.org"
+	})).Return(nil)
+	suite.mockCacheRepo.On("CacheURL", ctx, "abc123", existingURL.OriginalURL, uint(1), time.Hour*24).Return(nil)
+
+	result, err := suite.urlService.UpdateURL(ctx, 1, 1, domain.UpdateURLRequest{RedirectRules: &rules})
+
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), result.RedirectRules, 2)
+	suite.mockURLRepo.AssertExpectations(suite.T())
+}
+
+func (suite *URLServiceTestSuite) TestUpdateURL_RejectsBrokenRedirectRules() {
+	ctx := context.Background()
+	existingURL := &domain.ShortURL{ID: 1, UserID: 1, ShortCode: "abc123", IsActive: true}
+	suite.mockURLRepo.On("GetByID", ctx, uint(1)).Return(existingURL, nil)
+
+	tests := map[string]domain.RedirectRuleRequest{
+		"no conditions":  {DestinationURL: "https://example.com"},
+		"country":        {Conditions: domain.RuleConditions{Countries: []string{"GBR"}}, DestinationURL: "https://example.com"},
+		"device":         {Conditions: domain.RuleConditions{Devices: []string{"phone"}}, DestinationURL: "https://example.com"},
+		"language":       {Conditions: domain.RuleConditions{Languages: []string{"en_US"}}, DestinationURL: "https://example.com"},
+		"referrer":       {Conditions: domain.RuleConditions{ReferrerDomains: []string{"https://example.org/"}}, DestinationURL: "https://example.com"},
+		"clock":          {Conditions: domain.RuleConditions{TimeWindow: &domain.TimeWindow{Start: "9am", End: "17:00"}}, DestinationURL: "https://example.com"},
+		"empty window":   {Conditions: domain.RuleConditions{TimeWindow: &domain.TimeWindow{Start: "09:00", End: "09:00"}}, DestinationURL: "https://example.com"},
+		"timezone":       {Conditions: domain.RuleConditions{TimeWindow: &domain.TimeWindow{Start: "09:00", End: "17:00", Timezone: "Mars/Olympus"}}, DestinationURL: "https://example.com"},
+		"destination":    {Conditions: domain.RuleConditions{Countries: []string{"US"}}, DestinationURL: "javascript:alert(1)"},
+		"no destination": {Conditions: domain.RuleConditions{Countries: []string{"US"}}},
+	}
+
+	for name, rule := range tests {
+		rules := []domain.RedirectRuleRequest{rule}
+		_, err := suite.urlService.UpdateURL(ctx, 1, 1, domain.UpdateURLRequest{RedirectRules: &rules})
+		assert.ErrorIs(suite.T(), err, domain.ErrInvalidRedirectRule, name)
+	}
+
+	// Nothing was saved
+	suite.mockURLRepo.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+	suite.mockURLRepo.AssertNotCalled(suite.T(), "ReplaceRedirectRules", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *URLServiceTestSuite) TestRedirectRule_TimeWindow() {
+	overnight := &domain.TimeWindow{Start: "22:00", End: "06:00", Timezone: "Asia/Tokyo"}
+	office := &domain.TimeWindow{Start: "09:00", End: "17:30"}
+
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 3, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	// 22:00-06:00 in Tokyo is 13:00-21:00 UTC
+	assert.True(suite.T(), overnight.Contains(at(13, 0)))
+	assert.True(suite.T(), overnight.Contains(at(20, 59)))
+	assert.False(suite.T(), overnight.Contains(at(21, 0)))
+	assert.False(suite.T(), overnight.Contains(at(12, 59)))
+
+	assert.True(suite.T(), office.Contains(at(9, 0)))
+	assert.True(suite.T(), office.Contains(at(17, 29)))
+	assert.False(suite.T(), office.Contains(at(17, 30)))
+	assert.False(suite.T(), office.Contains(at(8, 59)))
+
+	// A zone that can no longer be loaded makes the rule inert rather than failing
+	broken := &domain.TimeWindow{Start: "00:00", End: "23:59", Timezone: "Nowhere/Gone"}
+	assert.False(suite.T(), broken.Contains(at(12, 0)))
+}
+
+func (suite *URLServiceTestSuite) TestRedirectRule_Matches() {
+	rule := domain.RuleConditions{
+		Countries:        []string{"US", "CA"},
+		Devices:          []string{"mobile"},
+		OperatingSystems: []string{"iOS"},
+	}
+
+	assert.True(suite.T(), rule.Matches(domain.RedirectContext{Country: "CA", Device: "mobile", OS: "iOS"}))
+	assert.True(suite.T(), rule.Matches(domain.RedirectContext{Country: "US", Device: "Mobile", OS: "ios"}))
+	assert.False(suite.T(), rule.Matches(domain.RedirectContext{Country: "GB", Device: "mobile", OS: "iOS"}))
+	assert.False(suite.T(), rule.Matches(domain.RedirectContext{Country: "US", Device: "desktop", OS: "iOS"}))
+	assert.False(suite.T(), rule.Matches(domain.RedirectContext{Device: "mobile", OS: "iOS"}))
+	assert.False(suite.T(), domain.RuleConditions{}.Matches(domain.RedirectContext{Country: "US"}))
+}
+
+func (suite *URLServiceTestSuite) TestShortenURL_HashesPassword() {
+	ctx := context.Background()
+	req := domain.ShortenURLRequest{OriginalURL: "https://example.com", UserID: 1, Password: "s3cret"}
+
+	suite.mockURLRepo.On("ExistsByShortCode", ctx, mock.AnythingOfType("string")).Return(false, nil)
+	suite.mockURLRepo.On("Create", ctx, mock.AnythingOfType("*domain.ShortURL")).Return(nil)
+	suite.mockCacheRepo.On("CacheURL", ctx, mock.AnythingOfType("string"), req.OriginalURL, req.UserID, time.Hour*24).Return(nil)
+
+	result, err := suite.urlService.ShortenURL(ctx, req)
+
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), result.Password)
+	assert.NotContains(suite.T(), *result.Password, "s3cret")
+	assert.NoError(suite.T(), bcrypt.CompareHashAndPassword([]byte(*result.Password), []byte("s3cret")))
+}
+
+func (suite *URLServiceTestSuite) protectedURL(stored string) *domain.ShortURL {
+	return &domain.ShortURL{ID: 1, UserID: 1, ShortCode: "abc123", OriginalURL: "https://example.com", IsActive: true, Password: &stored}
+}
+
+func (suite *URLServiceTestSuite) TestUnlockURL_UpgradesLegacyPassword() {
+	ctx := context.Background()
+	shortURL := suite.protectedURL(fmt.Sprintf("%x", "s3cret"))
+
+	suite.mockURLRepo.On("GetActiveByShortCode", ctx, "abc123").Return(shortURL, nil)
+	suite.mockCacheRepo.On("IsRateLimited", ctx, mock.AnythingOfType("string"), mock.AnythingOfType("int64"), unlockAttemptWindow).Return(false, nil)
+	suite.mockURLRepo.On("Update", ctx, mock.MatchedBy(func(u *domain.ShortURL) bool {
+		return bcrypt.CompareHashAndPassword([]byte(*u.Password), []byte("s3cret")) == nil
+	})).Return(nil)
+	suite.mockConfigRepo.On("GetJWTSecret").Return("test-secret")
+	// A successful unlock forgets the client's earlier failures
+	suite.mockCacheRepo.On("Del", ctx, []string{"link_unlock:1:203.0.113.9"}).Return(nil)
+
+	unlock, err := suite.urlService.UnlockURL(ctx, "abc123", "s3cret", "203.0.113.9")
+
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), suite.urlService.CheckUnlockToken(ctx, shortURL, unlock.Token))
+	assert.WithinDuration(suite.T(), time.Now().Add(linkUnlockTTL), unlock.ExpiresAt, time.Minute)
+	suite.mockURLRepo.AssertExpectations(suite.T())
+	suite.mockCacheRepo.AssertExpectations(suite.T())
+}
+
+func (suite *URLServiceTestSuite) TestUnlockURL_WrongPasswordCountsAttempt() {
+	ctx := context.Background()
+	hashed, _ := suite.urlService.hashPassword("s3cret")
+	shortURL := suite.protectedURL(hashed)
+
+	suite.mockURLRepo.On("GetActiveByShortCode", ctx, "abc123").Return(shortURL, nil)
+	suite.mockCacheRepo.On("IsRateLimited", ctx, mock.AnythingOfType("string"), mock.AnythingOfType("int64"), unlockAttemptWindow).Return(false, nil)
+	suite.mockCacheRepo.On("IncrementRateLimit", ctx, "link_unlock:1:203.0.113.9", unlockAttemptWindow).Return(int64(1), nil).Once()
+	suite.mockCacheRepo.On("IncrementRateLimit", ctx, "link_unlock:1", unlockAttemptWindow).Return(int64(1), nil).Once()
+
+	unlock, err := suite.urlService.UnlockURL(ctx, "abc123", "guess", "203.0.113.9")
+
+	assert.Equal(suite.T(), domain.ErrInvalidPassword, err)
+	assert.Nil(suite.T(), unlock)
+	suite.mockCacheRepo.AssertExpectations(suite.T())
+	suite.mockURLRepo.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+}
+
+func (suite *URLServiceTestSuite) TestUnlockURL_Throttled() {
+	ctx := context.Background()
+	hashed, _ := suite.urlService.hashPassword("s3cret")
+	shortURL := suite.protectedURL(hashed)
+
+	suite.mockURLRepo.On("GetActiveByShortCode", ctx, "abc123").Return(shortURL, nil)
+	suite.mockCacheRepo.On("IsRateLimited", ctx, "link_unlock:1", int64(maxUnlockFailuresPerLink), unlockAttemptWindow).Return(false, nil)
+	suite.mockCacheRepo.On("IsRateLimited", ctx, "link_unlock:1:203.0.113.9", int64(maxUnlockAttemptsPerClient), unlockAttemptWindow).Return(true, nil)
+
+	// Even the right password is refused once the visitor has used up their guesses
+	_, err := suite.urlService.UnlockURL(ctx, "abc123", "s3cret", "203.0.113.9")
+
+	assert.Equal(suite.T(), domain.ErrTooManyRequests, err)
+}
+
+func (suite *URLServiceTestSuite) TestUnlockURL_LinkUnderAttack() {
+	ctx := context.Background()
+	hashed, _ := suite.urlService.hashPassword("s3cret")
+	shortURL := suite.protectedURL(hashed)
+
+	suite.mockURLRepo.On("GetActiveByShortCode", ctx, "abc123").Return(shortURL, nil)
+	suite.mockCacheRepo.On("IsRateLimited", ctx, "link_unlock:1", int64(maxUnlockFailuresPerLink), unlockAttemptWindow).Return(true, nil)
+	suite.mockCacheRepo.On("IsRateLimited", ctx, "link_unlock:1:203.0.113.9", int64(maxUnlockAttemptsUnderAttack), unlockAttemptWindow).Return(false, nil)
+	suite.mockCacheRepo.On("IsRateLimited", ctx, "link_unlock:1:198.51.100.4", int64(maxUnlockAttemptsUnderAttack), unlockAttemptWindow).Return(true, nil)
+	suite.mockConfigRepo.On("GetJWTSecret").Return("test-secret")
+	suite.mockCacheRepo.On("Del", ctx, []string{"link_unlock:1:203.0.113.9"}).Return(nil)
+
+	// A visitor's first try is still checked, so the right password gets through
+	unlock, err := suite.urlService.UnlockURL(ctx, "abc123", "s3cret", "203.0.113.9")
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), unlock)
+
+	// A visitor who already guessed wrong has to wait
+	_, err = suite.urlService.UnlockURL(ctx, "abc123", "s3cret", "198.51.100.4")
+	assert.Equal(suite.T(), domain.ErrTooManyRequests, err)
+}
+
+func (suite *URLServiceTestSuite) TestCheckUnlockToken() {
+	ctx := context.Background()
+	hashed, _ := suite.urlService.hashPassword("s3cret")
+	shortURL := suite.protectedURL(hashed)
+	suite.mockConfigRepo.On("GetJWTSecret").Return("test-secret")
+
+	token := suite.urlService.issueUnlockToken(shortURL).Token
+	assert.True(suite.T(), suite.urlService.CheckUnlockToken(ctx, shortURL, token))
+
+	// Tampered, foreign and malformed tokens are rejected
+	assert.False(suite.T(), suite.urlService.CheckUnlockToken(ctx, shortURL, token+"x"))
+	other := suite.protectedURL(hashed)
+	other.ID = 2
+	assert.False(suite.T(), suite.urlService.CheckUnlockToken(ctx, other, token))
+	assert.False(suite.T(), suite.urlService.CheckUnlockToken(ctx, shortURL, "not-a-token"))
+
+	// Expired tokens are rejected even with a valid signature
+	payload := fmt.Sprintf("1.%d", time.Now().Add(-time.Minute).Unix())
+	expired := payload + "." + suite.urlService.signUnlockToken(shortURL, payload)
+	assert.False(suite.T(), suite.urlService.CheckUnlockToken(ctx, shortURL, expired))
+
+	// Changing the password invalidates earlier tokens
+	rehashed, _ := suite.urlService.hashPassword("n3w")
+	shortURL.Password = &rehashed
+	assert.False(suite.T(), suite.urlService.CheckUnlockToken(ctx, shortURL, token))
+}
+
+func (suite *URLServiceTestSuite) TestUpdateURL_WorkspaceRoles() {
+	ctx := context.Background()
+	workspaceID := uint(7)
+	title := "Spring campaign"
+	req := domain.UpdateURLRequest{Title: &title}
+
+	existingURL := &domain.ShortURL{
+		ID:          1,
+		UserID:      1,
+		WorkspaceID: &workspaceID,
+		ShortCode:   "abc123",
+		IsActive:    true,
+	}
+
+	suite.mockURLRepo.On("GetByID", ctx, uint(1)).Return(existingURL, nil)
+	suite.mockURLRepo.On("Update", ctx, existingURL).Return(nil)
+	suite.mockCacheRepo.On("CacheURL", ctx, "abc123", existingURL.OriginalURL, uint(1), time.Hour*24).Return(nil)
+	suite.mockWorkspaceRepo.On("GetMember", ctx, workspaceID, uint(2)).
+		Return(&domain.WorkspaceMember{WorkspaceID: workspaceID, UserID: 2, Role: domain.WorkspaceRoleEditor}, nil)
+	suite.mockWorkspaceRepo.On("GetMember", ctx, workspaceID, uint(3)).
+		Return(&domain.WorkspaceMember{WorkspaceID: workspaceID, UserID: 3, Role: domain.WorkspaceRoleViewer}, nil)
+	suite.mockWorkspaceRepo.On("GetMember", ctx, workspaceID, uint(4)).
+		Return((*domain.WorkspaceMember)(nil), domain.ErrNotWorkspaceMember)
+
+	// Editors can change a link another member created
+	result, err := suite.urlService.UpdateURL(ctx, 1, 2, req)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), title, result.Title)
+
+	// Viewers and outsiders cannot
+	_, err = suite.urlService.UpdateURL(ctx, 1, 3, req)
+	assert.Equal(suite.T(), domain.ErrUnauthorized, err)
+	_, err = suite.urlService.UpdateURL(ctx, 1, 4, req)
+	assert.Equal(suite.T(), domain.ErrUnauthorized, err)
+
+	suite.mockURLRepo.AssertNumberOfCalls(suite.T(), "Update", 1)
+}
+
+func (suite *URLServiceTestSuite) TestShortenURL_InWorkspace() {
+	ctx := context.Background()
+	workspaceID := uint(7)
+	req := domain.ShortenURLRequest{
+		OriginalURL: "https://example.com",
+		UserID:      3,
+		WorkspaceID: &workspaceID,
+	}
+
+	suite.mockWorkspaceRepo.On("GetMember", ctx, workspaceID, uint(3)).
+		Return(&domain.WorkspaceMember{WorkspaceID: workspaceID, UserID: 3, Role: domain.WorkspaceRoleViewer}, nil).Once()
+
+	// Viewers cannot add links
+	_, err := suite.urlService.ShortenURL(ctx, req)
+	assert.Equal(suite.T(), domain.ErrUnauthorized, err)
+	suite.mockURLRepo.AssertNotCalled(suite.T(), "Create", mock.Anything, mock.Anything)
+
+	suite.mockWorkspaceRepo.On("GetMember", ctx, workspaceID, uint(3)).
+		Return(&domain.WorkspaceMember{WorkspaceID: workspaceID, UserID: 3, Role: domain.WorkspaceRoleEditor}, nil).Once()
+	suite.mockURLRepo.On("ExistsByShortCode", ctx, mock.AnythingOfType("string")).Return(false, nil)
+	suite.mockURLRepo.On("Create", ctx, mock.AnythingOfType("*domain.ShortURL")).Return(nil)
+	suite.mockCacheRepo.On("CacheURL", ctx, mock.AnythingOfType("string"), req.OriginalURL, req.UserID, time.Hour*24).Return(nil)
+
+	result, err := suite.urlService.ShortenURL(ctx, req)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), &workspaceID, result.WorkspaceID)
+}
+
 func (suite *URLServiceTestSuite) TestDeleteURL_Success() {
 	ctx := context.Background()
 	urlID := uint(1)
@@ -287,6 +789,72 @@ func (suite *URLServiceTestSuite) TestDeleteURL_Success() {
 	suite.mockCacheRepo.AssertExpectations(suite.T())
 }
 
+func (suite *URLServiceTestSuite) TestUpdateURL_ChangesDestination() {
+	ctx := context.Background()
+	destination := "https://example.com/new"
+	existingURL := &domain.ShortURL{ID: 1, UserID: 1, ShortCode: "abc123", OriginalURL: "https://example.com/old", IsActive: true}
+
+	suite.mockURLRepo.On("GetByID", ctx, uint(1)).Return(existingURL, nil)
+	suite.mockURLRepo.On("UpdateWithRevision", ctx, existingURL, mock.MatchedBy(func(rev *domain.URLRevision) bool {
+		return rev.ShortURLID == 1 && rev.OldURL == "https://example.com/old" && rev.NewURL == destination && *rev.EditorID == 1
+	})).Return(nil)
+	// The old destination is dropped rather than re-cached
+	suite.mockCacheRepo.On("InvalidateURL", ctx, "abc123").Return(nil)
+
+	result, err := suite.urlService.UpdateURL(ctx, 1, 1, domain.UpdateURLRequest{OriginalURL: &destination})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), destination, result.OriginalURL)
+	suite.mockURLRepo.AssertExpectations(suite.T())
+	suite.mockCacheRepo.AssertExpectations(suite.T())
+	suite.mockCacheRepo.AssertNotCalled(suite.T(), "CacheURL", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *URLServiceTestSuite) TestUpdateURL_InvalidDestination() {
+	ctx := context.Background()
+	destination := "javascript:alert(1)"
+	suite.mockURLRepo.On("GetByID", ctx, uint(1)).Return(&domain.ShortURL{ID: 1, UserID: 1, OriginalURL: "https://example.com"}, nil)
+
+	_, err := suite.urlService.UpdateURL(ctx, 1, 1, domain.UpdateURLRequest{OriginalURL: &destination})
+
+	assert.Equal(suite.T(), domain.ErrInvalidURL, err)
+	suite.mockURLRepo.AssertNotCalled(suite.T(), "Update", mock.Anything, mock.Anything)
+}
+
+func (suite *URLServiceTestSuite) TestRollbackURL() {
+	ctx := context.Background()
+	existingURL := &domain.ShortURL{ID: 1, UserID: 1, ShortCode: "abc123", OriginalURL: "https://example.com/v2", IsActive: true}
+	target := &domain.URLRevision{ID: 4, ShortURLID: 1, NewURL: "https://example.com/v1"}
+
+	suite.mockURLRepo.On("GetByID", ctx, uint(1)).Return(existingURL, nil)
+	suite.mockURLRepo.On("GetRevision", ctx, uint(1), uint(4)).Return(target, nil)
+	suite.mockURLRepo.On("UpdateWithRevision", ctx, existingURL, mock.MatchedBy(func(rev *domain.URLRevision) bool {
+		return rev.OldURL == "https://example.com/v2" && rev.NewURL == "https://example.com/v1" && *rev.RolledBackFrom == 4
+	})).Return(nil)
+	suite.mockCacheRepo.On("InvalidateURL", ctx, "abc123").Return(nil)
+
+	result, err := suite.urlService.RollbackURL(ctx, 1, 1, 4)
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "https://example.com/v1", result.OriginalURL)
+	suite.mockURLRepo.AssertExpectations(suite.T())
+
+	// Rolling back to where the link already goes changes nothing
+	_, err = suite.urlService.RollbackURL(ctx, 1, 1, 4)
+	assert.NoError(suite.T(), err)
+	suite.mockURLRepo.AssertNumberOfCalls(suite.T(), "UpdateWithRevision", 1)
+}
+
+func (suite *URLServiceTestSuite) TestRollbackURL_Unauthorized() {
+	ctx := context.Background()
+	suite.mockURLRepo.On("GetByID", ctx, uint(1)).Return(&domain.ShortURL{ID: 1, UserID: 1}, nil)
+
+	_, err := suite.urlService.RollbackURL(ctx, 1, 2, 4)
+
+	assert.Equal(suite.T(), domain.ErrUnauthorized, err)
+	suite.mockURLRepo.AssertNotCalled(suite.T(), "GetRevision", mock.Anything, mock.Anything, mock.Anything)
+}
+
 func (suite *URLServiceTestSuite) TestValidatePassword_NoPassword() {
 	ctx := context.Background()
 	shortCode := "abc123"
@@ -351,16 +919,56 @@ func (m *MockURLRepository) GetByUserID(ctx context.Context, userID uint, offset
 	return args.Get(0).([]*domain.ShortURL), args.Get(1).(int64), args.Error(2)
 }
 
+func (m *MockURLRepository) GetByWorkspaceID(ctx context.Context, workspaceID uint, offset, limit int) ([]*domain.ShortURL, int64, error) {
+	args := m.Called(ctx, workspaceID, offset, limit)
+	return args.Get(0).([]*domain.ShortURL), args.Get(1).(int64), args.Error(2)
+}
+
 func (m *MockURLRepository) GetActiveByShortCode(ctx context.Context, shortCode string) (*domain.ShortURL, error) {
 	args := m.Called(ctx, shortCode)
 	return args.Get(0).(*domain.ShortURL), args.Error(1)
 }
 
+func (m *MockURLRepository) ReplaceVariants(ctx context.Context, shortURLID uint, variants []*domain.URLVariant) error {
+	args := m.Called(ctx, shortURLID, variants)
+	return args.Error(0)
+}
+
+func (m *MockURLRepository) GetVariants(ctx context.Context, shortURLID uint) ([]*domain.URLVariant, error) {
+	args := m.Called(ctx, shortURLID)
+	return args.Get(0).([]*domain.URLVariant), args.Error(1)
+}
+
+func (m *MockURLRepository) ReplaceRedirectRules(ctx context.Context, shortURLID uint, rules []*domain.RedirectRule) error {
+	args := m.Called(ctx, shortURLID, rules)
+	return args.Error(0)
+}
+
+func (m *MockURLRepository) UpdateWithRevision(ctx context.Context, url *domain.ShortURL, revision *domain.URLRevision) error {
+	args := m.Called(ctx, url, revision)
+	return args.Error(0)
+}
+
+func (m *MockURLRepository) GetRevision(ctx context.Context, shortURLID, revisionID uint) (*domain.URLRevision, error) {
+	args := m.Called(ctx, shortURLID, revisionID)
+	return args.Get(0).(*domain.URLRevision), args.Error(1)
+}
+
+func (m *MockURLRepository) GetRevisions(ctx context.Context, shortURLID uint, offset, limit int) ([]*domain.URLRevision, int64, error) {
+	args := m.Called(ctx, shortURLID, offset, limit)
+	return args.Get(0).([]*domain.URLRevision), args.Get(1).(int64), args.Error(2)
+}
+
 func (m *MockURLRepository) IncrementClickCount(ctx context.Context, id uint) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
+func (m *MockURLRepository) IncrementClickCounts(ctx context.Context, counts map[uint]int64) error {
+	args := m.Called(ctx, counts)
+	return args.Error(0)
+}
+
 func (m *MockURLRepository) GetExpiredURLs(ctx context.Context, limit int) ([]*domain.ShortURL, error) {
 	args := m.Called(ctx, limit)
 	return args.Get(0).([]*domain.ShortURL), args.Error(1)
@@ -390,6 +998,16 @@ func (m *MockClickRepository) Create(ctx context.Context, click *domain.Click) e
 	return args.Error(0)
 }
 
+func (m *MockClickRepository) CreateBatch(ctx context.Context, clicks []*domain.Click) error {
+	args := m.Called(ctx, clicks)
+	return args.Error(0)
+}
+
+func (m *MockClickRepository) RecordBatch(ctx context.Context, clicks []*domain.Click) error {
+	args := m.Called(ctx, clicks)
+	return args.Error(0)
+}
+
 func (m *MockClickRepository) GetByID(ctx context.Context, id uint) (*domain.Click, error) {
 	args := m.Called(ctx, id)
 	return args.Get(0).(*domain.Click), args.Error(1)
@@ -430,6 +1048,11 @@ func (m *MockClickRepository) GetClicksByDateRange(ctx context.Context, shortURL
 	return args.Get(0).([]*domain.Click), args.Error(1)
 }
 
+func (m *MockClickRepository) GetClicksAfter(ctx context.Context, shortURLID uint, from, to time.Time, afterID uint, limit int) ([]*domain.Click, error) {
+	args := m.Called(ctx, shortURLID, from, to, afterID, limit)
+	return args.Get(0).([]*domain.Click), args.Error(1)
+}
+
 func (m *MockClickRepository) GetTopCountries(ctx context.Context, shortURLID uint, limit int) ([]domain.CountryStat, error) {
 	args := m.Called(ctx, shortURLID, limit)
 	return args.Get(0).([]domain.CountryStat), args.Error(1)
@@ -455,6 +1078,11 @@ func (m *MockClickRepository) GetRecentClicks(ctx context.Context, shortURLID ui
 	return args.Get(0).([]domain.RecentClickStat), args.Error(1)
 }
 
+func (m *MockClickRepository) GetVariantClickCounts(ctx context.Context, shortURLID uint) ([]domain.VariantClickCount, error) {
+	args := m.Called(ctx, shortURLID)
+	return args.Get(0).([]domain.VariantClickCount), args.Error(1)
+}
+
 func (m *MockClickRepository) GetGlobalStats(ctx context.Context) (*domain.GlobalStats, error) {
 	args := m.Called(ctx)
 	return args.Get(0).(*domain.GlobalStats), args.Error(1)
@@ -465,3 +1093,21 @@ func (m *MockClickRepository) GetUserStats(ctx context.Context, userID uint) (*d
 	return args.Get(0).(*domain.UserAnalytics), args.Error(1)
 }
 
+type MockClickQueue struct {
+	mock.Mock
+}
+
+func (m *MockClickQueue) Enqueue(ctx context.Context, click *domain.Click) error {
+	args := m.Called(ctx, click)
+	return args.Error(0)
+}
+
+func (m *MockClickQueue) Stats() domain.ClickQueueStats {
+	args := m.Called()
+	return args.Get(0).(domain.ClickQueueStats)
+}
+
+func (m *MockClickQueue) Close(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
@@ -0,0 +1,369 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+type workspaceService struct {
+	workspaceRepo ports.WorkspaceRepository
+	userRepo      ports.UserRepository
+	urlRepo       ports.URLRepository
+	notifier      ports.NotificationService
+}
+
+func NewWorkspaceService(
+	workspaceRepo ports.WorkspaceRepository,
+	userRepo ports.UserRepository,
+	urlRepo ports.URLRepository,
+	notifier ports.NotificationService,
+) ports.WorkspaceService {
+	return &workspaceService{
+		workspaceRepo: workspaceRepo,
+		userRepo:      userRepo,
+		urlRepo:       urlRepo,
+		notifier:      notifier,
+	}
+}
+
+func (s *workspaceService) CreateWorkspace(ctx context.Context, userID uint, req domain.CreateWorkspaceRequest) (*domain.WorkspaceResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	workspace := &domain.Workspace{Name: req.Name, CreatedBy: userID}
+	owner := &domain.WorkspaceMember{UserID: userID, Role: domain.WorkspaceRoleOwner}
+	if err := s.workspaceRepo.Create(ctx, workspace, owner); err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+
+	return workspace.ToResponse(owner.Role), nil
+}
+
+func (s *workspaceService) ListWorkspaces(ctx context.Context, userID uint) ([]*domain.WorkspaceResponse, error) {
+	memberships, err := s.workspaceRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+
+	workspaces := make([]*domain.WorkspaceResponse, 0, len(memberships))
+	for _, m := range memberships {
+		if m.Workspace != nil {
+			workspaces = append(workspaces, m.Workspace.ToResponse(m.Role))
+		}
+	}
+	return workspaces, nil
+}
+
+func (s *workspaceService) GetWorkspace(ctx context.Context, workspaceID, userID uint) (*domain.WorkspaceResponse, error) {
+	member, err := s.requireMember(ctx, workspaceID, userID, domain.PermissionViewLinks)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace, err := s.workspaceRepo.GetByID(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	return workspace.ToResponse(member.Role), nil
+}
+
+func (s *workspaceService) UpdateWorkspace(ctx context.Context, workspaceID, userID uint, req domain.UpdateWorkspaceRequest) (*domain.WorkspaceResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	member, err := s.requireMember(ctx, workspaceID, userID, domain.PermissionManageWorkspace)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace, err := s.workspaceRepo.GetByID(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace.Name = req.Name
+	workspace.UpdatedAt = time.Now()
+	if err := s.workspaceRepo.Update(ctx, workspace); err != nil {
+		return nil, fmt.Errorf("failed to update workspace: %w", err)
+	}
+	return workspace.ToResponse(member.Role), nil
+}
+
+func (s *workspaceService) ListMembers(ctx context.Context, workspaceID, userID uint) ([]*domain.WorkspaceMemberResponse, error) {
+	if _, err := s.requireMember(ctx, workspaceID, userID, domain.PermissionViewLinks); err != nil {
+		return nil, err
+	}
+
+	members, err := s.workspaceRepo.ListMembers(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+
+	responses := make([]*domain.WorkspaceMemberResponse, len(members))
+	for i, m := range members {
+		responses[i] = m.ToResponse()
+	}
+	return responses, nil
+}
+
+func (s *workspaceService) UpdateMemberRole(ctx context.Context, workspaceID, userID, memberID uint, req domain.UpdateMemberRoleRequest) (*domain.WorkspaceMemberResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.requireMember(ctx, workspaceID, userID, domain.PermissionManageWorkspace); err != nil {
+		return nil, err
+	}
+
+	member, err := s.workspaceRepo.GetMember(ctx, workspaceID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	if member.Role == req.Role {
+		return member.ToResponse(), nil
+	}
+
+	if member.IsOwner() {
+		if err := s.ensureAnotherOwner(ctx, workspaceID); err != nil {
+			return nil, err
+		}
+	}
+
+	member.Role = req.Role
+	member.UpdatedAt = time.Now()
+	if err := s.workspaceRepo.UpdateMember(ctx, member); err != nil {
+		return nil, fmt.Errorf("failed to update member: %w", err)
+	}
+	return member.ToResponse(), nil
+}
+
+func (s *workspaceService) RemoveMember(ctx context.Context, workspaceID, userID, memberID uint) error {
+	// Anyone may leave; only owners may remove someone else
+	required := domain.PermissionManageWorkspace
+	if memberID == userID {
+		required = domain.PermissionViewLinks
+	}
+	if _, err := s.requireMember(ctx, workspaceID, userID, required); err != nil {
+		return err
+	}
+
+	member, err := s.workspaceRepo.GetMember(ctx, workspaceID, memberID)
+	if err != nil {
+		return err
+	}
+	if member.IsOwner() {
+		if err := s.ensureAnotherOwner(ctx, workspaceID); err != nil {
+			return err
+		}
+	}
+
+	return s.workspaceRepo.RemoveMember(ctx, workspaceID, memberID)
+}
+
+func (s *workspaceService) InviteMember(ctx context.Context, workspaceID, userID uint, req domain.InviteMemberRequest) (*domain.WorkspaceInvitation, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.requireMember(ctx, workspaceID, userID, domain.PermissionManageWorkspace); err != nil {
+		return nil, err
+	}
+
+	workspace, err := s.workspaceRepo.GetByID(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	inviter, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Existing members need a role change, not an invitation
+	if invitee, err := s.userRepo.GetByEmail(ctx, req.Email); err == nil {
+		if _, err := s.workspaceRepo.GetMember(ctx, workspaceID, invitee.ID); err == nil {
+			return nil, domain.ErrAlreadyWorkspaceMember
+		}
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate invitation token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	invitation := &domain.WorkspaceInvitation{
+		WorkspaceID: workspaceID,
+		Email:       req.Email,
+		Role:        req.Role,
+		TokenHash:   hashInvitationToken(token),
+		InvitedBy:   userID,
+		ExpiresAt:   time.Now().Add(domain.WorkspaceInvitationTTL),
+	}
+	if err := s.workspaceRepo.CreateInvitation(ctx, invitation); err != nil {
+		return nil, fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	if err := s.notifier.SendWorkspaceInvitation(ctx, req.Email, inviter, workspace, token); err != nil {
+		return nil, fmt.Errorf("failed to send invitation: %w", err)
+	}
+	return invitation, nil
+}
+
+func (s *workspaceService) ListInvitations(ctx context.Context, workspaceID, userID uint) ([]*domain.WorkspaceInvitation, error) {
+	if _, err := s.requireMember(ctx, workspaceID, userID, domain.PermissionManageWorkspace); err != nil {
+		return nil, err
+	}
+
+	invitations, err := s.workspaceRepo.ListPendingInvitations(ctx, workspaceID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	return invitations, nil
+}
+
+func (s *workspaceService) RevokeInvitation(ctx context.Context, workspaceID, userID, invitationID uint) error {
+	if _, err := s.requireMember(ctx, workspaceID, userID, domain.PermissionManageWorkspace); err != nil {
+		return err
+	}
+
+	invitation, err := s.workspaceRepo.GetInvitationByID(ctx, invitationID)
+	if err != nil {
+		return err
+	}
+	if invitation.WorkspaceID != workspaceID {
+		return domain.ErrInvitationNotFound
+	}
+
+	if err := s.workspaceRepo.DeleteInvitation(ctx, invitationID); err != nil {
+		return fmt.Errorf("failed to revoke invitation: %w", err)
+	}
+	return nil
+}
+
+func (s *workspaceService) AcceptInvitation(ctx context.Context, userID uint, req domain.AcceptInvitationRequest) (*domain.WorkspaceResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	invitation, err := s.workspaceRepo.GetInvitationByTokenHash(ctx, hashInvitationToken(req.Token))
+	if err != nil {
+		return nil, err
+	}
+	if invitation.IsAccepted() {
+		return nil, domain.ErrInvitationNotFound
+	}
+	if invitation.IsExpired() {
+		return nil, domain.ErrInvitationExpired
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// The token alone is not enough: a forwarded invitation must not let someone
+	// else join
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return nil, domain.ErrInvitationEmailMismatch
+	}
+
+	member := &domain.WorkspaceMember{
+		WorkspaceID: invitation.WorkspaceID,
+		UserID:      userID,
+		Role:        invitation.Role,
+	}
+	if err := s.workspaceRepo.AcceptInvitation(ctx, invitation, member); err != nil {
+		return nil, err
+	}
+
+	workspace := invitation.Workspace
+	if workspace == nil {
+		if workspace, err = s.workspaceRepo.GetByID(ctx, invitation.WorkspaceID); err != nil {
+			return nil, err
+		}
+	}
+	return workspace.ToResponse(member.Role), nil
+}
+
+func (s *workspaceService) GetWorkspaceURLs(ctx context.Context, workspaceID, userID uint, offset, limit int) ([]*domain.ShortURL, int64, error) {
+	if _, err := s.requireMember(ctx, workspaceID, userID, domain.PermissionViewLinks); err != nil {
+		return nil, 0, err
+	}
+
+	urls, total, err := s.urlRepo.GetByWorkspaceID(ctx, workspaceID, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get workspace URLs: %w", err)
+	}
+	return urls, total, nil
+}
+
+// requireMember returns the user's membership. Non-members get ErrWorkspaceNotFound
+// so they cannot probe for workspaces; members whose role lacks the permission get
+// ErrForbidden.
+func (s *workspaceService) requireMember(ctx context.Context, workspaceID, userID uint, permission domain.WorkspacePermission) (*domain.WorkspaceMember, error) {
+	member, err := s.workspaceRepo.GetMember(ctx, workspaceID, userID)
+	if err != nil {
+		if err == domain.ErrNotWorkspaceMember {
+			return nil, domain.ErrWorkspaceNotFound
+		}
+		return nil, fmt.Errorf("failed to get membership: %w", err)
+	}
+	if !member.Can(permission) {
+		return nil, domain.ErrForbidden
+	}
+	return member, nil
+}
+
+func (s *workspaceService) ensureAnotherOwner(ctx context.Context, workspaceID uint) error {
+	owners, err := s.workspaceRepo.CountOwners(ctx, workspaceID)
+	if err != nil {
+		return fmt.Errorf("failed to count owners: %w", err)
+	}
+	if owners <= 1 {
+		return domain.ErrLastWorkspaceOwner
+	}
+	return nil
+}
+
+// authorizeURL checks that userID may act on shortURL. Personal links are open
+// only to the user who created them, workspace links to members whose role grants
+// the permission. Anything else is ErrUnauthorized.
+func authorizeURL(ctx context.Context, workspaceRepo ports.WorkspaceRepository, shortURL *domain.ShortURL, userID uint, permission domain.WorkspacePermission) error {
+	if shortURL.WorkspaceID == nil {
+		if shortURL.UserID != userID {
+			return domain.ErrUnauthorized
+		}
+		return nil
+	}
+
+	if workspaceRepo == nil {
+		return domain.ErrUnauthorized
+	}
+	member, err := workspaceRepo.GetMember(ctx, *shortURL.WorkspaceID, userID)
+	if err != nil {
+		if err == domain.ErrNotWorkspaceMember {
+			return domain.ErrUnauthorized
+		}
+		return fmt.Errorf("failed to check workspace membership: %w", err)
+	}
+	if !member.Can(permission) {
+		return domain.ErrUnauthorized
+	}
+	return nil
+}
+
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
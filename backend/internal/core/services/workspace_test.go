@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"url-shortener/internal/core/domain"
+)
+
+type WorkspaceServiceTestSuite struct {
+	suite.Suite
+	service           *workspaceService
+	mockWorkspaceRepo *MockWorkspaceRepository
+	mockUserRepo      *MockUserRepository
+	mockURLRepo       *MockURLRepository
+	mockNotifier      *MockNotificationService
+}
+
+func TestWorkspaceServiceSuite(t *testing.T) {
+	suite.Run(t, new(WorkspaceServiceTestSuite))
+}
+
+func (suite *WorkspaceServiceTestSuite) SetupTest() {
+	suite.mockWorkspaceRepo = &MockWorkspaceRepository{}
+	suite.mockUserRepo = &MockUserRepository{}
+	suite.mockURLRepo = &MockURLRepository{}
+	suite.mockNotifier = &MockNotificationService{}
+	suite.service = &workspaceService{
+		workspaceRepo: suite.mockWorkspaceRepo,
+		userRepo:      suite.mockUserRepo,
+		urlRepo:       suite.mockURLRepo,
+		notifier:      suite.mockNotifier,
+	}
+}
+
+func (suite *WorkspaceServiceTestSuite) member(userID uint, role string) *domain.WorkspaceMember {
+	return &domain.WorkspaceMember{WorkspaceID: 1, UserID: userID, Role: role}
+}
+
+func (suite *WorkspaceServiceTestSuite) TestCreateWorkspace() {
+	ctx := context.Background()
+	suite.mockWorkspaceRepo.On("Create", ctx, mock.AnythingOfType("*domain.Workspace"), mock.AnythingOfType("*domain.WorkspaceMember")).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.Workspace).ID = 1
+		})
+
+	workspace, err := suite.service.CreateWorkspace(ctx, 5, domain.CreateWorkspaceRequest{Name: "  Marketing "})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Marketing", workspace.Name)
+	assert.Equal(suite.T(), domain.WorkspaceRoleOwner, workspace.Role)
+
+	owner := suite.mockWorkspaceRepo.Calls[0].Arguments.Get(2).(*domain.WorkspaceMember)
+	assert.Equal(suite.T(), uint(5), owner.UserID)
+}
+
+func (suite *WorkspaceServiceTestSuite) TestGetWorkspace_NonMember() {
+	ctx := context.Background()
+	suite.mockWorkspaceRepo.On("GetMember", ctx, uint(1), uint(9)).Return((*domain.WorkspaceMember)(nil), domain.ErrNotWorkspaceMember)
+
+	// Outsiders cannot tell the workspace exists
+	_, err := suite.service.GetWorkspace(ctx, 1, 9)
+	assert.Equal(suite.T(), domain.ErrWorkspaceNotFound, err)
+}
+
+func (suite *WorkspaceServiceTestSuite) TestUpdateMemberRole() {
+	ctx := context.Background()
+	editor := suite.member(2, domain.WorkspaceRoleEditor)
+	suite.mockWorkspaceRepo.On("GetMember", ctx, uint(1), uint(1)).Return(suite.member(1, domain.WorkspaceRoleOwner), nil)
+	suite.mockWorkspaceRepo.On("GetMember", ctx, uint(1), uint(2)).Return(editor, nil)
+	suite.mockWorkspaceRepo.On("UpdateMember", ctx, editor).Return(nil)
+
+	updated, err := suite.service.UpdateMemberRole(ctx, 1, 1, 2, domain.UpdateMemberRoleRequest{Role: domain.WorkspaceRoleViewer})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), domain.WorkspaceRoleViewer, updated.Role)
+
+	// Only owners manage members
+	_, err = suite.service.UpdateMemberRole(ctx, 1, 2, 1, domain.UpdateMemberRoleRequest{Role: domain.WorkspaceRoleViewer})
+	assert.Equal(suite.T(), domain.ErrForbidden, err)
+}
+
+func (suite *WorkspaceServiceTestSuite) TestUpdateMemberRole_LastOwner() {
+	ctx := context.Background()
+	suite.mockWorkspaceRepo.On("GetMember", ctx, uint(1), uint(1)).Return(suite.member(1, domain.WorkspaceRoleOwner), nil)
+	suite.mockWorkspaceRepo.On("CountOwners", ctx, uint(1)).Return(int64(1), nil)
+
+	_, err := suite.service.UpdateMemberRole(ctx, 1, 1, 1, domain.UpdateMemberRoleRequest{Role: domain.WorkspaceRoleEditor})
+
+	assert.Equal(suite.T(), domain.ErrLastWorkspaceOwner, err)
+	suite.mockWorkspaceRepo.AssertNotCalled(suite.T(), "UpdateMember", mock.Anything, mock.Anything)
+}
+
+func (suite *WorkspaceServiceTestSuite) TestRemoveMember() {
+	ctx := context.Background()
+	suite.mockWorkspaceRepo.On("GetMember", ctx, uint(1), uint(1)).Return(suite.member(1, domain.WorkspaceRoleOwner), nil)
+	suite.mockWorkspaceRepo.On("GetMember", ctx, uint(1), uint(2)).Return(suite.member(2, domain.WorkspaceRoleViewer), nil)
+	suite.mockWorkspaceRepo.On("GetMember", ctx, uint(1), uint(3)).Return(suite.member(3, domain.WorkspaceRoleEditor), nil)
+	suite.mockWorkspaceRepo.On("RemoveMember", ctx, uint(1), uint(2)).Return(nil)
+	suite.mockWorkspaceRepo.On("RemoveMember", ctx, uint(1), uint(3)).Return(nil)
+
+	// Members can leave, but not remove each other
+	assert.NoError(suite.T(), suite.service.RemoveMember(ctx, 1, 2, 2))
+	assert.Equal(suite.T(), domain.ErrForbidden, suite.service.RemoveMember(ctx, 1, 2, 3))
+
+	// Owners can remove anyone
+	assert.NoError(suite.T(), suite.service.RemoveMember(ctx, 1, 1, 3))
+}
+
+func (suite *WorkspaceServiceTestSuite) TestInviteMember() {
+	ctx := context.Background()
+	workspace := &domain.Workspace{ID: 1, Name: "Marketing"}
+	inviter := &domain.User{ID: 1, Email: "owner@example.com"}
+	suite.mockWorkspaceRepo.On("GetMember", ctx, uint(1), uint(1)).Return(suite.member(1, domain.WorkspaceRoleOwner), nil)
+	suite.mockWorkspaceRepo.On("GetByID", ctx, uint(1)).Return(workspace, nil)
+	suite.mockUserRepo.On("GetByID", ctx, uint(1)).Return(inviter, nil)
+	suite.mockUserRepo.On("GetByEmail", ctx, "new@example.com").Return(nil, domain.ErrUserNotFound)
+	suite.mockWorkspaceRepo.On("CreateInvitation", ctx, mock.AnythingOfType("*domain.WorkspaceInvitation")).Return(nil)
+	suite.mockNotifier.On("SendWorkspaceInvitation", ctx, "new@example.com", inviter, workspace, mock.AnythingOfType("string")).Return(nil)
+
+	invitation, err := suite.service.InviteMember(ctx, 1, 1, domain.InviteMemberRequest{Email: " New@Example.com ", Role: domain.WorkspaceRoleEditor})
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "new@example.com", invitation.Email)
+	assert.Equal(suite.T(), domain.WorkspaceRoleEditor, invitation.Role)
+
+	// Only the hash of the emailed token is stored
+	token := suite.mockNotifier.Calls[0].Arguments.String(4)
+	assert.Equal(suite.T(), hashInvitationToken(token), invitation.TokenHash)
+	assert.NotEqual(suite.T(), token, invitation.TokenHash)
+}
+
+func (suite *WorkspaceServiceTestSuite) TestInviteMember_ExistingMember() {
+	ctx := context.Background()
+	suite.mockWorkspaceRepo.On("GetMember", ctx, uint(1), uint(1)).Return(suite.member(1, domain.WorkspaceRoleOwner), nil)
+	suite.mockWorkspaceRepo.On("GetMember", ctx, uint(1), uint(2)).Return(suite.member(2, domain.WorkspaceRoleViewer), nil)
+	suite.mockWorkspaceRepo.On("GetByID", ctx, uint(1)).Return(&domain.Workspace{ID: 1}, nil)
+	suite.mockUserRepo.On("GetByID", ctx, uint(1)).Return(&domain.User{ID: 1}, nil)
+	suite.mockUserRepo.On("GetByEmail", ctx, "member@example.com").Return(&domain.User{ID: 2}, nil)
+
+	_, err := suite.service.InviteMember(ctx, 1, 1, domain.InviteMemberRequest{Email: "member@example.com", Role: domain.WorkspaceRoleEditor})
+
+	assert.Equal(suite.T(), domain.ErrAlreadyWorkspaceMember, err)
+	suite.mockWorkspaceRepo.AssertNotCalled(suite.T(), "CreateInvitation", mock.Anything, mock.Anything)
+}
+
+func (suite *WorkspaceServiceTestSuite) TestAcceptInvitation() {
+	ctx := context.Background()
+	invitation := &domain.WorkspaceInvitation{
+		ID:          4,
+		WorkspaceID: 1,
+		Email:       "sam@example.com",
+		Role:        domain.WorkspaceRoleEditor,
+		ExpiresAt:   time.Now().Add(time.Hour),
+		Workspace:   &domain.Workspace{ID: 1, Name: "Marketing"},
+	}
+	suite.mockWorkspaceRepo.On("GetInvitationByTokenHash", ctx, hashInvitationToken("token")).Return(invitation, nil)
+	suite.mockUserRepo.On("GetByID", ctx, uint(3)).Return(&domain.User{ID: 3, Email: "Sam@Example.com"}, nil)
+	suite.mockUserRepo.On("GetByID", ctx, uint(4)).Return(&domain.User{ID: 4, Email: "other@example.com"}, nil)
+	suite.mockWorkspaceRepo.On("AcceptInvitation", ctx, invitation, mock.AnythingOfType("*domain.WorkspaceMember")).Return(nil)
+
+	// A forwarded invitation is no use to someone else
+	_, err := suite.service.AcceptInvitation(ctx, 4, domain.AcceptInvitationRequest{Token: "token"})
+	assert.Equal(suite.T(), domain.ErrInvitationEmailMismatch, err)
+
+	workspace, err := suite.service.AcceptInvitation(ctx, 3, domain.AcceptInvitationRequest{Token: "token"})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Marketing", workspace.Name)
+	assert.Equal(suite.T(), domain.WorkspaceRoleEditor, workspace.Role)
+
+	member := suite.mockWorkspaceRepo.Calls[len(suite.mockWorkspaceRepo.Calls)-1].Arguments.Get(2).(*domain.WorkspaceMember)
+	assert.Equal(suite.T(), uint(3), member.UserID)
+}
+
+func (suite *WorkspaceServiceTestSuite) TestAcceptInvitation_Expired() {
+	ctx := context.Background()
+	invitation := &domain.WorkspaceInvitation{ID: 4, WorkspaceID: 1, Email: "sam@example.com", ExpiresAt: time.Now().Add(-time.Minute)}
+	suite.mockWorkspaceRepo.On("GetInvitationByTokenHash", ctx, hashInvitationToken("token")).Return(invitation, nil)
+
+	_, err := suite.service.AcceptInvitation(ctx, 3, domain.AcceptInvitationRequest{Token: "token"})
+
+	assert.Equal(suite.T(), domain.ErrInvitationExpired, err)
+}
+
+func TestAuthorizeURL(t *testing.T) {
+	ctx := context.Background()
+	workspaceID := uint(1)
+	personal := &domain.ShortURL{ID: 1, UserID: 1}
+	shared := &domain.ShortURL{ID: 2, UserID: 1, WorkspaceID: &workspaceID}
+
+	repo := &MockWorkspaceRepository{}
+	repo.On("GetMember", ctx, workspaceID, uint(2)).Return(&domain.WorkspaceMember{Role: domain.WorkspaceRoleViewer}, nil)
+	repo.On("GetMember", ctx, workspaceID, uint(1)).Return((*domain.WorkspaceMember)(nil), domain.ErrNotWorkspaceMember)
+
+	assert.NoError(t, authorizeURL(ctx, repo, personal, 1, domain.PermissionEditLinks))
+	assert.Equal(t, domain.ErrUnauthorized, authorizeURL(ctx, repo, personal, 2, domain.PermissionViewLinks))
+
+	assert.NoError(t, authorizeURL(ctx, repo, shared, 2, domain.PermissionViewLinks))
+	assert.Equal(t, domain.ErrUnauthorized, authorizeURL(ctx, repo, shared, 2, domain.PermissionEditLinks))
+
+	// Creating a workspace link does not keep access after leaving the workspace
+	assert.Equal(t, domain.ErrUnauthorized, authorizeURL(ctx, repo, shared, 1, domain.PermissionViewLinks))
+}
+
+// MockWorkspaceRepository is a mock implementation of ports.WorkspaceRepository
+type MockWorkspaceRepository struct {
+	mock.Mock
+}
+
+func (m *MockWorkspaceRepository) Create(ctx context.Context, workspace *domain.Workspace, owner *domain.WorkspaceMember) error {
+	args := m.Called(ctx, workspace, owner)
+	return args.Error(0)
+}
+
+func (m *MockWorkspaceRepository) GetByID(ctx context.Context, id uint) (*domain.Workspace, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*domain.Workspace), args.Error(1)
+}
+
+func (m *MockWorkspaceRepository) Update(ctx context.Context, workspace *domain.Workspace) error {
+	args := m.Called(ctx, workspace)
+	return args.Error(0)
+}
+
+func (m *MockWorkspaceRepository) ListByUser(ctx context.Context, userID uint) ([]*domain.WorkspaceMember, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*domain.WorkspaceMember), args.Error(1)
+}
+
+func (m *MockWorkspaceRepository) GetMember(ctx context.Context, workspaceID, userID uint) (*domain.WorkspaceMember, error) {
+	args := m.Called(ctx, workspaceID, userID)
+	return args.Get(0).(*domain.WorkspaceMember), args.Error(1)
+}
+
+func (m *MockWorkspaceRepository) ListMembers(ctx context.Context, workspaceID uint) ([]*domain.WorkspaceMember, error) {
+	args := m.Called(ctx, workspaceID)
+	return args.Get(0).([]*domain.WorkspaceMember), args.Error(1)
+}
+
+func (m *MockWorkspaceRepository) AddMember(ctx context.Context, member *domain.WorkspaceMember) error {
+	args := m.Called(ctx, member)
+	return args.Error(0)
+}
+
+func (m *MockWorkspaceRepository) UpdateMember(ctx context.Context, member *domain.WorkspaceMember) error {
+	args := m.Called(ctx, member)
+	return args.Error(0)
+}
+
+func (m *MockWorkspaceRepository) RemoveMember(ctx context.Context, workspaceID, userID uint) error {
+	args := m.Called(ctx, workspaceID, userID)
+	return args.Error(0)
+}
+
+func (m *MockWorkspaceRepository) CountOwners(ctx context.Context, workspaceID uint) (int64, error) {
+	args := m.Called(ctx, workspaceID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockWorkspaceRepository) CreateInvitation(ctx context.Context, invitation *domain.WorkspaceInvitation) error {
+	args := m.Called(ctx, invitation)
+	return args.Error(0)
+}
+
+func (m *MockWorkspaceRepository) GetInvitationByID(ctx context.Context, id uint) (*domain.WorkspaceInvitation, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*domain.WorkspaceInvitation), args.Error(1)
+}
+
+func (m *MockWorkspaceRepository) GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*domain.WorkspaceInvitation, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Get(0).(*domain.WorkspaceInvitation), args.Error(1)
+}
+
+func (m *MockWorkspaceRepository) ListPendingInvitations(ctx context.Context, workspaceID uint, now time.Time) ([]*domain.WorkspaceInvitation, error) {
+	args := m.Called(ctx, workspaceID, now)
+	return args.Get(0).([]*domain.WorkspaceInvitation), args.Error(1)
+}
+
+func (m *MockWorkspaceRepository) AcceptInvitation(ctx context.Context, invitation *domain.WorkspaceInvitation, member *domain.WorkspaceMember) error {
+	args := m.Called(ctx, invitation, member)
+	return args.Error(0)
+}
+
+func (m *MockWorkspaceRepository) DeleteInvitation(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
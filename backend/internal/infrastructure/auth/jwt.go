@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"url-shortener/internal/config"
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+const (
+	accessTokenType  = "access"
+	refreshTokenType = "refresh"
+)
+
+// jwtKey is one key of the keyset. Keys without a private half only verify.
+type jwtKey struct {
+	kid    string
+	method jwt.SigningMethod
+	sign   interface{} // []byte, *rsa.PrivateKey or ed25519.PrivateKey; nil for verify-only keys
+	verify interface{} // []byte, *rsa.PublicKey or ed25519.PublicKey
+}
+
+// jwtService signs with one key and verifies with any key of the keyset, found by
+// the token's kid header. Retired keys keep verifying until they are removed, so
+// rotating the signing key does not sign anybody out.
+type jwtService struct {
+	signer  *jwtKey
+	keys    map[string]*jwtKey
+	methods []string
+
+	issuer          string
+	accessAudience  string
+	refreshAudience string
+	expiry          time.Duration
+	refreshExpiry   time.Duration
+}
+
+type tokenClaims struct {
+	UserID    uint   `json:"user_id"`
+	Email     string `json:"email,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	Role      string `json:"role,omitempty"`
+	TokenType string `json:"token_type"`
+	jwt.RegisteredClaims
+}
+
+func NewJWTService(cfg config.JWTConfig) (ports.JWTService, error) {
+	s := &jwtService{
+		keys:            make(map[string]*jwtKey),
+		issuer:          cfg.Issuer,
+		accessAudience:  cfg.AccessAudience,
+		refreshAudience: cfg.RefreshAudience,
+		expiry:          cfg.Expiry,
+		refreshExpiry:   cfg.RefreshExpiry,
+	}
+
+	// Old secrets keep verifying after a rotation, or after moving to a keyset
+	for _, secret := range cfg.PreviousSecrets {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			s.addKey(secretKey(secret))
+		}
+	}
+
+	switch cfg.Algorithm {
+	case "", jwt.SigningMethodHS256.Alg():
+		if cfg.Secret == "" {
+			return nil, errors.New("a JWT secret is required for HS256")
+		}
+		s.signer = secretKey(cfg.Secret)
+		s.addKey(s.signer)
+
+	case jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg():
+		keys, err := loadKeys(cfg.KeysDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if _, exists := s.keys[key.kid]; exists {
+				return nil, fmt.Errorf("duplicate JWT key ID %q", key.kid)
+			}
+			s.addKey(key)
+		}
+		if s.signer, err = pickSigner(keys, cfg.SigningKeyID, cfg.Algorithm); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", cfg.Algorithm)
+	}
+
+	return s, nil
+}
+
+func (s *jwtService) GenerateAccessToken(userID uint, email, role, sessionID string) (string, error) {
+	return s.generateToken(userID, email, sessionID, role, accessTokenType, s.accessAudience, s.expiry)
+}
+
+func (s *jwtService) GenerateRefreshToken(userID uint, sessionID string) (string, error) {
+	return s.generateToken(userID, "", sessionID, "", refreshTokenType, s.refreshAudience, s.refreshExpiry)
+}
+
+func (s *jwtService) ValidateAccessToken(token string) (*domain.TokenClaims, error) {
+	return s.validateToken(token, accessTokenType, s.accessAudience)
+}
+
+func (s *jwtService) ValidateRefreshToken(token string) (*domain.TokenClaims, error) {
+	return s.validateToken(token, refreshTokenType, s.refreshAudience)
+}
+
+// PublicKeys lists the RSA and Ed25519 keys of the keyset, in key ID order
+func (s *jwtService) PublicKeys() *domain.JSONWebKeySet {
+	set := &domain.JSONWebKeySet{Keys: []domain.JSONWebKey{}}
+	for _, key := range s.keys {
+		if jwk, ok := publicJWK(key); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+func (s *jwtService) generateToken(userID uint, email, sessionID, role, tokenType, audience string, expiry time.Duration) (string, error) {
+	// A random ID keeps tokens issued in the same second distinct, which rotation relies on
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
+
+	now := time.Now()
+	claims := tokenClaims{
+		UserID:    userID,
+		Email:     email,
+		SessionID: sessionID,
+		Role:      role,
+		TokenType: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(jti),
+			Issuer:    s.issuer,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
+		},
+	}
+
+	token := jwt.NewWithClaims(s.signer.method, claims)
+	token.Header["kid"] = s.signer.kid
+	signed, err := token.SignedString(s.signer.sign)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed, nil
+}
+
+func (s *jwtService) validateToken(tokenString, tokenType, audience string) (*domain.TokenClaims, error) {
+	var claims tokenClaims
+	token, err := jwt.ParseWithClaims(tokenString, &claims, s.verificationKey, jwt.WithValidMethods(s.methods))
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, domain.ErrExpiredToken
+		}
+		return nil, domain.ErrInvalidToken
+	}
+
+	// Refuse refresh tokens where access tokens are expected and vice versa
+	if claims.TokenType != tokenType {
+		return nil, domain.ErrInvalidToken
+	}
+
+	// Tokens issued before key IDs carry no issuer or audience; they expire on their own
+	if _, hasKeyID := token.Header["kid"]; hasKeyID {
+		if claims.Issuer != s.issuer || !slices.Contains(claims.Audience, audience) {
+			return nil, domain.ErrInvalidToken
+		}
+	}
+
+	result := &domain.TokenClaims{
+		UserID:    claims.UserID,
+		Email:     claims.Email,
+		SessionID: claims.SessionID,
+		Role:      claims.Role,
+	}
+	if claims.ExpiresAt != nil {
+		result.Exp = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		result.Iat = claims.IssuedAt.Unix()
+	}
+	return result, nil
+}
+
+// verificationKey finds the key named by the token's kid. The key decides the
+// algorithm, so a token cannot pick HMAC to be checked against a public key.
+func (s *jwtService) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"]
+	if !ok {
+		// Tokens issued before key IDs were signed with a shared secret
+		var set jwt.VerificationKeySet
+		for _, key := range s.keys {
+			if key.method.Alg() == jwt.SigningMethodHS256.Alg() {
+				set.Keys = append(set.Keys, key.verify)
+			}
+		}
+		if token.Method.Alg() != jwt.SigningMethodHS256.Alg() || len(set.Keys) == 0 {
+			return nil, errors.New("token has no key ID")
+		}
+		return set, nil
+	}
+
+	kidString, _ := kid.(string)
+	key, ok := s.keys[kidString]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kidString)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("key %q does not sign %s tokens", kidString, token.Method.Alg())
+	}
+	return key.verify, nil
+}
+
+func (s *jwtService) addKey(key *jwtKey) {
+	s.keys[key.kid] = key
+	if !slices.Contains(s.methods, key.method.Alg()) {
+		s.methods = append(s.methods, key.method.Alg())
+	}
+}
+
+// secretKey names a shared secret after its hash, so tokens say which secret
+// signed them without revealing it
+func secretKey(secret string) *jwtKey {
+	sum := sha256.Sum256([]byte(secret))
+	return &jwtKey{
+		kid:    "hs256-" + hex.EncodeToString(sum[:6]),
+		method: jwt.SigningMethodHS256,
+		sign:   []byte(secret),
+		verify: []byte(secret),
+	}
+}
+
+// pickSigner returns the key named by kid, or the only private key when kid is empty
+func pickSigner(keys []*jwtKey, kid, algorithm string) (*jwtKey, error) {
+	var signer *jwtKey
+	for _, key := range keys {
+		if kid != "" && key.kid != kid {
+			continue
+		}
+		if kid == "" && key.sign == nil {
+			continue
+		}
+		if signer != nil {
+			return nil, errors.New("several private keys found: set the JWT signing key ID")
+		}
+		signer = key
+	}
+
+	switch {
+	case signer == nil && kid == "":
+		return nil, errors.New("no private key found to sign tokens with")
+	case signer == nil:
+		return nil, fmt.Errorf("JWT signing key %q not found", kid)
+	case signer.sign == nil:
+		return nil, fmt.Errorf("JWT signing key %q is a public key", signer.kid)
+	case signer.method.Alg() != algorithm:
+		return nil, fmt.Errorf("JWT signing key %q is not an %s key", signer.kid, algorithm)
+	}
+	return signer, nil
+}
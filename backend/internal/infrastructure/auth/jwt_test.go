@@ -0,0 +1,330 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"url-shortener/internal/config"
+	"url-shortener/internal/core/domain"
+)
+
+func testJWTConfig() config.JWTConfig {
+	return config.JWTConfig{
+		Secret:          "test-secret",
+		Expiry:          time.Hour,
+		RefreshExpiry:   24 * time.Hour,
+		Algorithm:       "HS256",
+		Issuer:          "url-shortener",
+		AccessAudience:  "url-shortener:access",
+		RefreshAudience: "url-shortener:refresh",
+	}
+}
+
+func newTestJWTService(t *testing.T, expiry time.Duration) *jwtService {
+	cfg := testJWTConfig()
+	cfg.Expiry = expiry
+	return mustJWTService(t, cfg)
+}
+
+func mustJWTService(t *testing.T, cfg config.JWTConfig) *jwtService {
+	service, err := NewJWTService(cfg)
+	require.NoError(t, err)
+	return service.(*jwtService)
+}
+
+func TestAccessTokenRoundTrip(t *testing.T) {
+	service := newTestJWTService(t, time.Hour)
+
+	token, err := service.GenerateAccessToken(42, "user@example.com", domain.RoleSupport, "family-1")
+	require.NoError(t, err)
+
+	claims, err := service.ValidateAccessToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, uint(42), claims.UserID)
+	assert.Equal(t, "user@example.com", claims.Email)
+	assert.Equal(t, domain.RoleSupport, claims.Role)
+	assert.Equal(t, "family-1", claims.SessionID)
+	assert.Greater(t, claims.Exp, claims.Iat)
+}
+
+func TestRefreshTokenRoundTrip(t *testing.T) {
+	service := newTestJWTService(t, time.Hour)
+
+	token, err := service.GenerateRefreshToken(7, "family-1")
+	require.NoError(t, err)
+
+	claims, err := service.ValidateRefreshToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, uint(7), claims.UserID)
+	assert.Equal(t, "family-1", claims.SessionID)
+
+	// Rotation issues a new token even within the same second
+	next, err := service.GenerateRefreshToken(7, "family-1")
+	require.NoError(t, err)
+	assert.NotEqual(t, token, next)
+}
+
+func TestTokenTypesAreNotInterchangeable(t *testing.T) {
+	service := newTestJWTService(t, time.Hour)
+
+	refreshToken, err := service.GenerateRefreshToken(1, "family-1")
+	require.NoError(t, err)
+	_, err = service.ValidateAccessToken(refreshToken)
+	assert.Equal(t, domain.ErrInvalidToken, err)
+
+	accessToken, err := service.GenerateAccessToken(1, "user@example.com", domain.RoleUser, "family-1")
+	require.NoError(t, err)
+	_, err = service.ValidateRefreshToken(accessToken)
+	assert.Equal(t, domain.ErrInvalidToken, err)
+}
+
+func TestExpiredToken(t *testing.T) {
+	service := newTestJWTService(t, -time.Minute)
+
+	token, err := service.GenerateAccessToken(1, "user@example.com", domain.RoleUser, "family-1")
+	require.NoError(t, err)
+
+	_, err = service.ValidateAccessToken(token)
+	assert.Equal(t, domain.ErrExpiredToken, err)
+}
+
+func TestTokenSignedWithDifferentSecret(t *testing.T) {
+	service := newTestJWTService(t, time.Hour)
+	cfg := testJWTConfig()
+	cfg.Secret = "other-secret"
+	other := mustJWTService(t, cfg)
+
+	token, err := other.GenerateAccessToken(1, "user@example.com", domain.RoleUser, "family-1")
+	require.NoError(t, err)
+
+	_, err = service.ValidateAccessToken(token)
+	assert.Equal(t, domain.ErrInvalidToken, err)
+}
+
+func TestSecretRotation(t *testing.T) {
+	old := newTestJWTService(t, time.Hour)
+	token, err := old.GenerateAccessToken(1, "user@example.com", domain.RoleUser, "family-1")
+	require.NoError(t, err)
+
+	// The old secret keeps verifying while it is listed
+	cfg := testJWTConfig()
+	cfg.Secret = "new-secret"
+	cfg.PreviousSecrets = []string{"test-secret"}
+	claims, err := mustJWTService(t, cfg).ValidateAccessToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), claims.UserID)
+
+	cfg.PreviousSecrets = nil
+	_, err = mustJWTService(t, cfg).ValidateAccessToken(token)
+	assert.Equal(t, domain.ErrInvalidToken, err)
+}
+
+func TestTokensWithoutKeyID(t *testing.T) {
+	service := newTestJWTService(t, time.Hour)
+
+	// Tokens issued before key IDs and audiences still work until they expire
+	legacy := func(tokenType string) string {
+		claims := tokenClaims{
+			UserID:    3,
+			TokenType: tokenType,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		}
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+		require.NoError(t, err)
+		return signed
+	}
+
+	claims, err := service.ValidateAccessToken(legacy(accessTokenType))
+	require.NoError(t, err)
+	assert.Equal(t, uint(3), claims.UserID)
+
+	_, err = service.ValidateAccessToken(legacy(refreshTokenType))
+	assert.Equal(t, domain.ErrInvalidToken, err)
+}
+
+func TestAudienceIsChecked(t *testing.T) {
+	cfg := testJWTConfig()
+	cfg.AccessAudience = "another-service"
+	token, err := mustJWTService(t, cfg).GenerateAccessToken(1, "user@example.com", domain.RoleUser, "family-1")
+	require.NoError(t, err)
+
+	_, err = newTestJWTService(t, time.Hour).ValidateAccessToken(token)
+	assert.Equal(t, domain.ErrInvalidToken, err)
+}
+
+func TestKeysetRotation(t *testing.T) {
+	dir := t.TempDir()
+	writeTestKey(t, dir, "2024-01", newRSAKey(t, 2048))
+	writeTestKey(t, dir, "2024-06", newRSAKey(t, 2048))
+
+	cfg := testJWTConfig()
+	cfg.Algorithm = "RS256"
+	cfg.KeysDir = dir
+	cfg.SigningKeyID = "2024-01"
+	old := mustJWTService(t, cfg)
+
+	refreshToken, err := old.GenerateRefreshToken(5, "family-1")
+	require.NoError(t, err)
+
+	// Switching the signing key keeps earlier tokens valid
+	cfg.SigningKeyID = "2024-06"
+	rotated := mustJWTService(t, cfg)
+	claims, err := rotated.ValidateRefreshToken(refreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, "family-1", claims.SessionID)
+
+	accessToken, err := rotated.GenerateAccessToken(5, "user@example.com", domain.RoleUser, "family-1")
+	require.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(accessToken, &tokenClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, "2024-06", parsed.Header["kid"])
+	assert.Equal(t, "RS256", parsed.Method.Alg())
+
+	// Removing the retired key ends its tokens
+	require.NoError(t, os.Remove(filepath.Join(dir, "2024-01.pem")))
+	_, err = mustJWTService(t, cfg).ValidateRefreshToken(refreshToken)
+	assert.Equal(t, domain.ErrInvalidToken, err)
+}
+
+func TestEdDSAKeysetAndPublicKeys(t *testing.T) {
+	dir := t.TempDir()
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaKey := newRSAKey(t, 2048)
+	writeTestKey(t, dir, "ed", edKey)
+	// A public key verifies tokens signed elsewhere but cannot sign
+	writeTestKey(t, dir, "rsa-verify", &rsaKey.PublicKey)
+
+	cfg := testJWTConfig()
+	cfg.Algorithm = "EdDSA"
+	cfg.KeysDir = dir
+	cfg.PreviousSecrets = []string{"test-secret"}
+	service := mustJWTService(t, cfg)
+	assert.Equal(t, "ed", service.signer.kid)
+
+	token, err := service.GenerateAccessToken(9, "user@example.com", domain.RoleAdmin, "family-1")
+	require.NoError(t, err)
+	claims, err := service.ValidateAccessToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RoleAdmin, claims.Role)
+
+	// Shared secrets are never published
+	keys := service.PublicKeys().Keys
+	require.Len(t, keys, 2)
+	assert.Equal(t, "ed", keys[0].Kid)
+	assert.Equal(t, "OKP", keys[0].Kty)
+	assert.Equal(t, "EdDSA", keys[0].Alg)
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(edKey.Public().(ed25519.PublicKey)), keys[0].X)
+	assert.Equal(t, "rsa-verify", keys[1].Kid)
+	assert.Equal(t, "RSA", keys[1].Kty)
+	assert.Equal(t, "AQAB", keys[1].E)
+
+	// Every published key round-trips through the JWK reader
+	for _, key := range keys {
+		_, err := jsonWebKey{Kty: key.Kty, Kid: key.Kid, N: key.N, E: key.E, Crv: key.Crv, X: key.X}.publicKey()
+		assert.NoError(t, err, key.Kid)
+	}
+
+	assert.Empty(t, newTestJWTService(t, time.Hour).PublicKeys().Keys)
+}
+
+func TestKeyDecidesAlgorithm(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey := newRSAKey(t, 2048)
+	writeTestKey(t, dir, "main", rsaKey)
+
+	cfg := testJWTConfig()
+	cfg.Algorithm = "RS256"
+	cfg.KeysDir = dir
+	cfg.PreviousSecrets = []string{"test-secret"}
+	service := mustJWTService(t, cfg)
+
+	// An HMAC token keyed with the public key, naming the RSA key, is refused
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	claims := tokenClaims{
+		UserID:    1,
+		TokenType: accessTokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    cfg.Issuer,
+			Audience:  jwt.ClaimStrings{cfg.AccessAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = "main"
+	signed, err := forged.SignedString(publicPEM)
+	require.NoError(t, err)
+
+	_, err = service.ValidateAccessToken(signed)
+	assert.Equal(t, domain.ErrInvalidToken, err)
+}
+
+func TestInvalidKeyConfig(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey := newRSAKey(t, 2048)
+	writeTestKey(t, dir, "signing", rsaKey)
+	writeTestKey(t, dir, "public", &rsaKey.PublicKey)
+
+	weakDir := t.TempDir()
+	writeTestKey(t, weakDir, "weak", newRSAKey(t, 1024))
+
+	tests := []struct {
+		name   string
+		modify func(cfg *config.JWTConfig)
+	}{
+		{"unknown algorithm", func(cfg *config.JWTConfig) { cfg.Algorithm = "none" }},
+		{"missing secret", func(cfg *config.JWTConfig) { cfg.Secret = "" }},
+		{"missing keys directory", func(cfg *config.JWTConfig) { cfg.Algorithm = "RS256" }},
+		{"empty keys directory", func(cfg *config.JWTConfig) { cfg.Algorithm = "RS256"; cfg.KeysDir = t.TempDir() }},
+		{"unknown signing key", func(cfg *config.JWTConfig) { cfg.Algorithm = "RS256"; cfg.KeysDir = dir; cfg.SigningKeyID = "missing" }},
+		{"public signing key", func(cfg *config.JWTConfig) { cfg.Algorithm = "RS256"; cfg.KeysDir = dir; cfg.SigningKeyID = "public" }},
+		{"wrong key type", func(cfg *config.JWTConfig) { cfg.Algorithm = "EdDSA"; cfg.KeysDir = dir; cfg.SigningKeyID = "signing" }},
+		{"weak RSA key", func(cfg *config.JWTConfig) { cfg.Algorithm = "RS256"; cfg.KeysDir = weakDir }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testJWTConfig()
+			tt.modify(&cfg)
+			_, err := NewJWTService(cfg)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func newRSAKey(t *testing.T, bits int) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	require.NoError(t, err)
+	return key
+}
+
+// writeTestKey saves a private key as PKCS#8 or a public key as PKIX
+func writeTestKey(t *testing.T, dir, kid string, key interface{}) {
+	var block *pem.Block
+	switch key.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(key)
+		require.NoError(t, err)
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	default:
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		require.NoError(t, err)
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, kid+".pem"), pem.EncodeToMemory(block), 0o600))
+}
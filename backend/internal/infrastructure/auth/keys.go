@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"url-shortener/internal/core/domain"
+)
+
+// Shorter RSA keys are refused
+const minRSAKeyBits = 2048
+
+// loadKeys reads every <kid>.pem in dir. Private keys can sign and verify,
+// public keys only verify.
+func loadKeys(dir string) ([]*jwtKey, error) {
+	if dir == "" {
+		return nil, errors.New("a JWT keys directory is required for RS256 and EdDSA")
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list JWT keys: %w", err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no JWT keys found in %s", dir)
+	}
+
+	keys := make([]*jwtKey, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT key: %w", err)
+		}
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := parsePEMKey(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT key %s: %w", filepath.Base(path), err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// parsePEMKey reads an RSA or Ed25519 key in PKCS#8, PKCS#1 or PKIX form
+func parsePEMKey(kid string, data []byte) (*jwtKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA keys must be at least %d bits", minRSAKeyBits)
+		}
+		return &jwtKey{kid: kid, method: jwt.SigningMethodRS256, sign: k, verify: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA keys must be at least %d bits", minRSAKeyBits)
+		}
+		return &jwtKey{kid: kid, method: jwt.SigningMethodRS256, verify: k}, nil
+	case ed25519.PrivateKey:
+		return &jwtKey{kid: kid, method: jwt.SigningMethodEdDSA, sign: k, verify: k.Public().(ed25519.PublicKey)}, nil
+	case ed25519.PublicKey:
+		return &jwtKey{kid: kid, method: jwt.SigningMethodEdDSA, verify: k}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", parsed)
+}
+
+// publicJWK describes the public half of an asymmetric key; shared secrets are never published
+func publicJWK(key *jwtKey) (domain.JSONWebKey, bool) {
+	jwk := domain.JSONWebKey{Kid: key.kid, Use: "sig", Alg: key.method.Alg()}
+	switch k := key.verify.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+		return jwk, true
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(k)
+		return jwk, true
+	}
+	return domain.JSONWebKey{}, false
+}
@@ -0,0 +1,406 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"url-shortener/internal/config"
+)
+
+const (
+	testClientID     = "url-shortener"
+	testClientSecret = "client-secret"
+	testRedirectURL  = "http://localhost:3000/auth/oidc/callback"
+	testVerifier     = "a-long-random-code-verifier-for-the-pkce-exchange"
+)
+
+// fakeOIDCProvider is an in-process identity provider: discovery, JWKS and a token
+// endpoint that checks the client and PKCE verifier before issuing a signed ID token
+type fakeOIDCProvider struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu         sync.Mutex
+	key        *rsa.PrivateKey
+	kid        string
+	codes      map[string]fakeAuthorization
+	jwksHits   int
+	claimsHook func(claims jwt.MapClaims)
+	signHook   func(claims jwt.MapClaims) string
+}
+
+type fakeAuthorization struct {
+	nonce         string
+	codeChallenge string
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	f := &fakeOIDCProvider{t: t, codes: map[string]fakeAuthorization{}}
+	f.rotateKey("key-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                f.server.URL,
+			"authorization_endpoint":                f.server.URL + "/authorize",
+			"token_endpoint":                        f.server.URL + "/token",
+			"jwks_uri":                              f.server.URL + "/jwks",
+			"token_endpoint_auth_methods_supported": []string{"client_secret_basic"},
+		})
+	})
+	mux.HandleFunc("/jwks", f.handleJWKS)
+	mux.HandleFunc("/token", f.handleToken)
+
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeOIDCProvider) rotateKey(kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(f.t, err)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.key = key
+	f.kid = kid
+}
+
+// authorize stands in for the browser visiting the authorization URL and signing in
+func (f *fakeOIDCProvider) authorize(authURL string) string {
+	parsed, err := url.Parse(authURL)
+	require.NoError(f.t, err)
+	query := parsed.Query()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	code := "code-" + query.Get("state")
+	f.codes[code] = fakeAuthorization{
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+	}
+	return code
+}
+
+func (f *fakeOIDCProvider) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.jwksHits++
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": f.kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(f.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(f.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (f *fakeOIDCProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	tokenError := func(code string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": code})
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok || clientID != testClientID || clientSecret != testClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("redirect_uri") != testRedirectURL {
+		tokenError("invalid_request")
+		return
+	}
+
+	f.mu.Lock()
+	auth, ok := f.codes[r.PostFormValue("code")]
+	delete(f.codes, r.PostFormValue("code"))
+	f.mu.Unlock()
+	if !ok {
+		tokenError("invalid_grant")
+		return
+	}
+
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != auth.codeChallenge {
+		tokenError("invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            f.server.URL,
+		"sub":            "idp-user-1",
+		"aud":            testClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          auth.nonce,
+		"email":          "Jane.Doe@Example.com",
+		"email_verified": true,
+		"given_name":     "Jane",
+		"family_name":    "Doe",
+	}
+	if f.claimsHook != nil {
+		f.claimsHook(claims)
+	}
+
+	var idToken string
+	if f.signHook != nil {
+		idToken = f.signHook(claims)
+	} else {
+		idToken = f.sign(claims)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (f *fakeOIDCProvider) sign(claims jwt.MapClaims) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = f.kid
+	signed, err := token.SignedString(f.key)
+	require.NoError(f.t, err)
+	return signed
+}
+
+func (f *fakeOIDCProvider) config() config.OIDCConfig {
+	return config.OIDCConfig{
+		IssuerURL:    f.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+		Scopes:       []string{"email", "profile"},
+	}
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// signIn runs the whole code flow against the fake provider
+func signIn(t *testing.T, f *fakeOIDCProvider, provider *oidcProvider, state string) error {
+	ctx := context.Background()
+	authURL, err := provider.AuthCodeURL(ctx, state, "nonce-"+state, pkceChallenge(testVerifier))
+	require.NoError(t, err)
+
+	_, err = provider.Exchange(ctx, f.authorize(authURL), testVerifier, "nonce-"+state)
+	return err
+}
+
+func TestNewOIDCProvider_DisabledWithoutConfig(t *testing.T) {
+	assert.Nil(t, NewOIDCProvider(config.OIDCConfig{IssuerURL: "https://idp.example.com"}))
+	assert.NotNil(t, NewOIDCProvider(config.OIDCConfig{IssuerURL: "https://idp.example.com", ClientID: "client"}))
+}
+
+func TestOIDCProvider_CodeFlow(t *testing.T) {
+	f := newFakeOIDCProvider(t)
+	provider := newOIDCProvider(f.config(), f.server.Client())
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", pkceChallenge(testVerifier))
+	require.NoError(t, err)
+
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, f.server.URL+"/authorize", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+	query := parsed.Query()
+	assert.Equal(t, "code", query.Get("response_type"))
+	assert.Equal(t, testClientID, query.Get("client_id"))
+	assert.Equal(t, testRedirectURL, query.Get("redirect_uri"))
+	assert.Equal(t, "openid email profile", query.Get("scope"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+
+	identity, err := provider.Exchange(ctx, f.authorize(authURL), testVerifier, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, f.server.URL, identity.Issuer)
+	assert.Equal(t, "idp-user-1", identity.Subject)
+	assert.Equal(t, "jane.doe@example.com", identity.Email)
+	assert.True(t, identity.EmailVerified)
+	assert.Equal(t, "Jane", identity.GivenName)
+	assert.Equal(t, "Doe", identity.FamilyName)
+}
+
+func TestOIDCProvider_RejectsWrongCodeVerifier(t *testing.T) {
+	f := newFakeOIDCProvider(t)
+	provider := newOIDCProvider(f.config(), f.server.Client())
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", pkceChallenge(testVerifier))
+	require.NoError(t, err)
+
+	_, err = provider.Exchange(ctx, f.authorize(authURL), "some-other-verifier", "nonce-1")
+	assert.ErrorContains(t, err, "invalid_grant")
+}
+
+func TestOIDCProvider_RejectsWrongClientSecret(t *testing.T) {
+	f := newFakeOIDCProvider(t)
+	cfg := f.config()
+	cfg.ClientSecret = "wrong"
+	provider := newOIDCProvider(cfg, f.server.Client())
+
+	assert.ErrorContains(t, signIn(t, f, provider, "state-1"), "invalid_client")
+}
+
+func TestOIDCProvider_RejectsNonceMismatch(t *testing.T) {
+	f := newFakeOIDCProvider(t)
+	provider := newOIDCProvider(f.config(), f.server.Client())
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", pkceChallenge(testVerifier))
+	require.NoError(t, err)
+
+	_, err = provider.Exchange(ctx, f.authorize(authURL), testVerifier, "nonce-from-another-login")
+	assert.ErrorContains(t, err, "nonce")
+}
+
+func TestOIDCProvider_RejectsInvalidIDTokens(t *testing.T) {
+	forgedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		claimsHook func(claims jwt.MapClaims)
+		signHook   func(f *fakeOIDCProvider) func(claims jwt.MapClaims) string
+	}{
+		{
+			name:       "wrong audience",
+			claimsHook: func(claims jwt.MapClaims) { claims["aud"] = "another-client" },
+		},
+		{
+			name:       "wrong issuer",
+			claimsHook: func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com" },
+		},
+		{
+			name:       "expired",
+			claimsHook: func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Hour).Unix() },
+		},
+		{
+			name:       "no expiry",
+			claimsHook: func(claims jwt.MapClaims) { delete(claims, "exp") },
+		},
+		{
+			name:       "issued to another party",
+			claimsHook: func(claims jwt.MapClaims) { claims["aud"] = []string{testClientID, "other"}; claims["azp"] = "other" },
+		},
+		{
+			name: "signed with an unknown key",
+			signHook: func(f *fakeOIDCProvider) func(claims jwt.MapClaims) string {
+				return func(claims jwt.MapClaims) string {
+					token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+					token.Header["kid"] = f.kid
+					signed, err := token.SignedString(forgedKey)
+					require.NoError(t, err)
+					return signed
+				}
+			},
+		},
+		{
+			name: "HMAC with the client secret",
+			signHook: func(f *fakeOIDCProvider) func(claims jwt.MapClaims) string {
+				return func(claims jwt.MapClaims) string {
+					signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testClientSecret))
+					require.NoError(t, err)
+					return signed
+				}
+			},
+		},
+		{
+			name: "unsigned",
+			signHook: func(f *fakeOIDCProvider) func(claims jwt.MapClaims) string {
+				return func(claims jwt.MapClaims) string {
+					signed, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+					require.NoError(t, err)
+					return signed
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeOIDCProvider(t)
+			f.claimsHook = tt.claimsHook
+			if tt.signHook != nil {
+				f.signHook = tt.signHook(f)
+			}
+			provider := newOIDCProvider(f.config(), f.server.Client())
+
+			assert.Error(t, signIn(t, f, provider, "state-1"))
+		})
+	}
+}
+
+func TestOIDCProvider_RefetchesKeysAfterRotation(t *testing.T) {
+	f := newFakeOIDCProvider(t)
+	provider := newOIDCProvider(f.config(), f.server.Client())
+
+	require.NoError(t, signIn(t, f, provider, "state-1"))
+	require.NoError(t, signIn(t, f, provider, "state-2"))
+	assert.Equal(t, 1, f.jwksHits, "keys are cached between sign-ins")
+
+	// Pretend the last fetch was a while ago, then rotate the provider's key
+	provider.keysFetchedAt = time.Now().Add(-2 * jwksRefetchInterval)
+	f.rotateKey("key-2")
+
+	require.NoError(t, signIn(t, f, provider, "state-3"))
+	assert.Equal(t, 2, f.jwksHits)
+}
+
+func TestOIDCProvider_LimitsKeyRefetches(t *testing.T) {
+	f := newFakeOIDCProvider(t)
+	provider := newOIDCProvider(f.config(), f.server.Client())
+
+	require.NoError(t, signIn(t, f, provider, "state-1"))
+
+	// A token naming an unknown key right after a fetch is rejected without calling the provider
+	f.rotateKey("key-2")
+	assert.Error(t, signIn(t, f, provider, "state-2"))
+	assert.Equal(t, 1, f.jwksHits)
+}
+
+func TestOIDCProvider_RejectsMismatchedIssuer(t *testing.T) {
+	// Discovery for the configured issuer describes a different one
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tenant/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 "https://evil.example.com",
+			"authorization_endpoint": "https://evil.example.com/authorize",
+			"token_endpoint":         "https://evil.example.com/token",
+			"jwks_uri":               "https://evil.example.com/jwks",
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	provider := newOIDCProvider(config.OIDCConfig{
+		IssuerURL: server.URL + "/tenant",
+		ClientID:  testClientID,
+	}, server.Client())
+	_, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+	assert.ErrorContains(t, err, "does not match")
+}
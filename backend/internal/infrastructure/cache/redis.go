@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -49,6 +50,14 @@ func (r *RedisClient) Get(ctx context.Context, key string) (string, error) {
 	return val, err
 }
 
+func (r *RedisClient) GetDel(ctx context.Context, key string) (string, error) {
+	val, err := r.client.GetDel(ctx, key).Result()
+	if err == redis.Nil {
+		return "", fmt.Errorf("key %s not found", key)
+	}
+	return val, err
+}
+
 func (r *RedisClient) Del(ctx context.Context, keys ...string) error {
 	return r.client.Del(ctx, keys...).Err()
 }
@@ -78,6 +87,10 @@ func (r *RedisClient) SIsMember(ctx context.Context, key string, member interfac
 	return r.client.SIsMember(ctx, key, member).Result()
 }
 
+func (r *RedisClient) SMembers(ctx context.Context, key string) ([]string, error) {
+	return r.client.SMembers(ctx, key).Result()
+}
+
 func (r *RedisClient) SCard(ctx context.Context, key string) (int64, error) {
 	return r.client.SCard(ctx, key).Result()
 }
@@ -106,6 +119,107 @@ func (r *RedisClient) HDel(ctx context.Context, key string, fields ...string) er
 	return r.client.HDel(ctx, key, fields...).Err()
 }
 
+// StreamMessage is a single entry read from a Redis stream
+type StreamMessage struct {
+	ID     string
+	Values map[string]interface{}
+	// Deliveries counts how often the entry has been delivered, including this
+	// time. It is only known for claimed entries and is 0 otherwise.
+	Deliveries int64
+}
+
+func (r *RedisClient) XAdd(ctx context.Context, stream string, values map[string]interface{}) (string, error) {
+	return r.client.XAdd(ctx, &redis.XAddArgs{Stream: stream, Values: values}).Result()
+}
+
+// XGroupCreate creates a consumer group (and the stream) unless it already exists
+func (r *RedisClient) XGroupCreate(ctx context.Context, stream, group string) error {
+	err := r.client.XGroupCreateMkStream(ctx, stream, group, "0").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// XReadGroup reads up to count entries for a consumer. Passing ">" as start reads
+// new entries, "0" re-reads the consumer's pending ones. A timeout yields no messages.
+func (r *RedisClient) XReadGroup(ctx context.Context, stream, group, consumer, start string, count int64, block time.Duration) ([]StreamMessage, error) {
+	streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, start},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []StreamMessage
+	for _, s := range streams {
+		for _, msg := range s.Messages {
+			messages = append(messages, StreamMessage{ID: msg.ID, Values: msg.Values})
+		}
+	}
+	return messages, nil
+}
+
+// XClaimStale takes over up to count entries that other consumers read but have
+// not acknowledged for at least minIdle. XCLAIM checks the idle time again, so an
+// entry is only handed to one of several consumers claiming at once.
+func (r *RedisClient) XClaimStale(ctx context.Context, stream, group, consumer string, minIdle time.Duration, count int64) ([]StreamMessage, error) {
+	pending, err := r.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  group,
+		Idle:   minIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  count,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(pending))
+	deliveries := make(map[string]int64, len(pending))
+	for _, p := range pending {
+		ids = append(ids, p.ID)
+		deliveries[p.ID] = p.RetryCount + 1 // XCLAIM counts as another delivery
+	}
+	claimed, err := r.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]StreamMessage, 0, len(claimed))
+	for _, msg := range claimed {
+		messages = append(messages, StreamMessage{ID: msg.ID, Values: msg.Values, Deliveries: deliveries[msg.ID]})
+	}
+	return messages, nil
+}
+
+// XAckDel acknowledges entries and removes them so XLEN reflects the backlog
+func (r *RedisClient) XAckDel(ctx context.Context, stream, group string, ids ...string) error {
+	pipe := r.client.TxPipeline()
+	pipe.XAck(ctx, stream, group, ids...)
+	pipe.XDel(ctx, stream, ids...)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *RedisClient) XLen(ctx context.Context, stream string) (int64, error) {
+	return r.client.XLen(ctx, stream).Result()
+}
+
 func (r *RedisClient) Close() error {
 	return r.client.Close()
 }
@@ -193,6 +193,41 @@ func (suite *RedisTestSuite) TestExpiration() {
 	suite.False(exists, "Key should not exist after expiration")
 }
 
+func (suite *RedisTestSuite) TestStreamOperations() {
+	if suite.redis == nil {
+		suite.T().Skip("Redis not available")
+		return
+	}
+	
+	// Creating the group twice is not an error
+	err := suite.redis.XGroupCreate(suite.ctx, "test:stream", "test-group")
+	suite.NoError(err)
+	err = suite.redis.XGroupCreate(suite.ctx, "test:stream", "test-group")
+	suite.NoError(err)
+	
+	_, err = suite.redis.XAdd(suite.ctx, "test:stream", map[string]interface{}{"data": "one"})
+	suite.NoError(err)
+	_, err = suite.redis.XAdd(suite.ctx, "test:stream", map[string]interface{}{"data": "two"})
+	suite.NoError(err)
+	
+	messages, err := suite.redis.XReadGroup(suite.ctx, "test:stream", "test-group", "consumer", ">", 10, 100*time.Millisecond)
+	suite.NoError(err)
+	suite.Len(messages, 2)
+	suite.Equal("one", messages[0].Values["data"])
+	
+	// Nothing new to read
+	empty, err := suite.redis.XReadGroup(suite.ctx, "test:stream", "test-group", "consumer", ">", 10, 100*time.Millisecond)
+	suite.NoError(err)
+	suite.Empty(empty)
+	
+	err = suite.redis.XAckDel(suite.ctx, "test:stream", "test-group", messages[0].ID, messages[1].ID)
+	suite.NoError(err)
+	
+	length, err := suite.redis.XLen(suite.ctx, "test:stream")
+	suite.NoError(err)
+	suite.Equal(int64(0), length)
+}
+
 func TestRedisTestSuite(t *testing.T) {
 	suite.Run(t, new(RedisTestSuite))
 }
\ No newline at end of file
@@ -29,6 +29,10 @@ func (c *CacheServiceImpl) Get(ctx context.Context, key string) (string, error)
 	return c.redis.Get(ctx, key)
 }
 
+func (c *CacheServiceImpl) GetDel(ctx context.Context, key string) (string, error) {
+	return c.redis.GetDel(ctx, key)
+}
+
 func (c *CacheServiceImpl) Del(ctx context.Context, keys ...string) error {
 	return c.redis.Del(ctx, keys...)
 }
@@ -162,7 +166,17 @@ func (c *CacheServiceImpl) IncrementRateLimit(ctx context.Context, key string, w
 // Session management
 func (c *CacheServiceImpl) SetSession(ctx context.Context, token string, userID uint, expiration time.Duration) error {
 	key := fmt.Sprintf("session:%s", token)
-	return c.redis.Set(ctx, key, userID, expiration)
+	if err := c.redis.Set(ctx, key, userID, expiration); err != nil {
+		return err
+	}
+
+	// Index the token by user so every session can be revoked at once. The index
+	// lives as long as the newest session; tokens that expired earlier are harmless.
+	userKey := fmt.Sprintf("user_sessions:%d", userID)
+	if err := c.redis.SAdd(ctx, userKey, token); err != nil {
+		return err
+	}
+	return c.redis.Expire(ctx, userKey, expiration)
 }
 
 func (c *CacheServiceImpl) GetSession(ctx context.Context, token string) (uint, error) {
@@ -185,6 +199,21 @@ func (c *CacheServiceImpl) InvalidateSession(ctx context.Context, token string)
 	return c.redis.Del(ctx, key)
 }
 
+func (c *CacheServiceImpl) InvalidateUserSessions(ctx context.Context, userID uint) error {
+	userKey := fmt.Sprintf("user_sessions:%d", userID)
+	tokens, err := c.redis.SMembers(ctx, userKey)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(tokens)+1)
+	for _, token := range tokens {
+		keys = append(keys, fmt.Sprintf("session:%s", token))
+	}
+	keys = append(keys, userKey)
+	return c.redis.Del(ctx, keys...)
+}
+
 // Analytics caching
 func (c *CacheServiceImpl) CacheClickCount(ctx context.Context, shortCode string, count int64) error {
 	key := fmt.Sprintf("clicks:%s", shortCode)
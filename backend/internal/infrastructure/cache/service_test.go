@@ -148,6 +148,29 @@ func (suite *CacheServiceTestSuite) TestSessionManagement() {
 	suite.Error(err)
 }
 
+func (suite *CacheServiceTestSuite) TestInvalidateUserSessions() {
+	if suite.cache == nil {
+		suite.T().Skip("Redis not available")
+		return
+	}
+
+	suite.NoError(suite.cache.SetSession(suite.ctx, "token_a", 7, time.Hour))
+	suite.NoError(suite.cache.SetSession(suite.ctx, "token_b", 7, time.Hour))
+	suite.NoError(suite.cache.SetSession(suite.ctx, "token_c", 8, time.Hour))
+
+	suite.NoError(suite.cache.InvalidateUserSessions(suite.ctx, 7))
+
+	_, err := suite.cache.GetSession(suite.ctx, "token_a")
+	suite.Error(err)
+	_, err = suite.cache.GetSession(suite.ctx, "token_b")
+	suite.Error(err)
+
+	// Other users keep their sessions
+	userID, err := suite.cache.GetSession(suite.ctx, "token_c")
+	suite.NoError(err)
+	suite.Equal(uint(8), userID)
+}
+
 func (suite *CacheServiceTestSuite) TestClickAnalytics() {
 	if suite.cache == nil {
 		suite.T().Skip("Redis not available")
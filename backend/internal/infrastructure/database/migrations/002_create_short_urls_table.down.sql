@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_short_urls_updated_at ON short_urls;
+DROP TABLE IF EXISTS short_urls;
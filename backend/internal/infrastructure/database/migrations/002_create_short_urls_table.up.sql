@@ -22,7 +22,8 @@ CREATE INDEX IF NOT EXISTS idx_short_urls_expires_at ON short_urls(expires_at) W
 CREATE INDEX IF NOT EXISTS idx_short_urls_deleted_at ON short_urls(deleted_at);
 
 -- Add trigger to update updated_at timestamp
+DROP TRIGGER IF EXISTS update_short_urls_updated_at ON short_urls;
 CREATE TRIGGER update_short_urls_updated_at 
     BEFORE UPDATE ON short_urls 
     FOR EACH ROW 
-    EXECUTE FUNCTION update_updated_at_column();
\ No newline at end of file
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS increment_url_click_count ON clicks;
+DROP TRIGGER IF EXISTS update_clicks_updated_at ON clicks;
+DROP TABLE IF EXISTS clicks;
+DROP FUNCTION IF EXISTS increment_click_count();
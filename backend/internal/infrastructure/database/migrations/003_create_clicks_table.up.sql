@@ -26,6 +26,7 @@ CREATE INDEX IF NOT EXISTS idx_clicks_clicked_at ON clicks(clicked_at DESC) WHER
 CREATE INDEX IF NOT EXISTS idx_clicks_deleted_at ON clicks(deleted_at);
 
 -- Add trigger to update updated_at timestamp
+DROP TRIGGER IF EXISTS update_clicks_updated_at ON clicks;
 CREATE TRIGGER update_clicks_updated_at 
     BEFORE UPDATE ON clicks 
     FOR EACH ROW 
@@ -42,7 +43,8 @@ BEGIN
 END;
 $$ language 'plpgsql';
 
+DROP TRIGGER IF EXISTS increment_url_click_count ON clicks;
 CREATE TRIGGER increment_url_click_count 
     AFTER INSERT ON clicks 
     FOR EACH ROW 
-    EXECUTE FUNCTION increment_click_count();
\ No newline at end of file
+    EXECUTE FUNCTION increment_click_count();
@@ -0,0 +1,14 @@
+DROP TRIGGER IF EXISTS increment_url_click_count ON clicks;
+CREATE TRIGGER increment_url_click_count 
+    AFTER INSERT ON clicks 
+    FOR EACH ROW 
+    EXECUTE FUNCTION increment_click_count();
+
+ALTER TABLE short_urls DROP COLUMN IF EXISTS password;
+ALTER TABLE short_urls DROP COLUMN IF EXISTS description;
+ALTER TABLE short_urls DROP COLUMN IF EXISTS title;
+
+ALTER TABLE users DROP COLUMN IF EXISTS last_login_at;
+ALTER TABLE users DROP COLUMN IF EXISTS is_active;
+ALTER TABLE users DROP COLUMN IF EXISTS last_name;
+ALTER TABLE users DROP COLUMN IF EXISTS first_name;
@@ -0,0 +1,13 @@
+-- Add the columns the domain models expect but the original tables lacked
+ALTER TABLE users ADD COLUMN IF NOT EXISTS first_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_active BOOLEAN DEFAULT TRUE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_login_at TIMESTAMP;
+
+ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS title VARCHAR(255);
+ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS password VARCHAR(255);
+
+-- URLService already increments click_count when it records a click,
+-- so the trigger from 003 counted every click twice
+DROP TRIGGER IF EXISTS increment_url_click_count ON clicks;
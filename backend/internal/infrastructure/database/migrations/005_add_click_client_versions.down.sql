@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_clicks_short_url_id_device;
+
+ALTER TABLE clicks DROP COLUMN IF EXISTS os_version;
+ALTER TABLE clicks DROP COLUMN IF EXISTS browser_version;
@@ -0,0 +1,5 @@
+-- Browser and OS versions parsed from the user agent
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS browser_version VARCHAR(50);
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS os_version VARCHAR(50);
+
+CREATE INDEX IF NOT EXISTS idx_clicks_short_url_id_device ON clicks(short_url_id, device) WHERE deleted_at IS NULL;
@@ -0,0 +1,30 @@
+-- Hourly and daily click counts per URL and dimension, maintained by the rollup
+-- aggregator so analytics queries do not scan raw clicks
+CREATE TABLE IF NOT EXISTS click_rollups_hourly (
+    short_url_id INTEGER NOT NULL REFERENCES short_urls(id) ON DELETE CASCADE,
+    bucket TIMESTAMP NOT NULL,
+    dimension VARCHAR(20) NOT NULL,
+    value VARCHAR(255) NOT NULL DEFAULT '',
+    clicks BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (short_url_id, bucket, dimension, value)
+);
+
+CREATE INDEX IF NOT EXISTS idx_click_rollups_hourly_bucket ON click_rollups_hourly(bucket);
+
+CREATE TABLE IF NOT EXISTS click_rollups_daily (
+    short_url_id INTEGER NOT NULL REFERENCES short_urls(id) ON DELETE CASCADE,
+    bucket TIMESTAMP NOT NULL,
+    dimension VARCHAR(20) NOT NULL,
+    value VARCHAR(255) NOT NULL DEFAULT '',
+    clicks BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (short_url_id, bucket, dimension, value)
+);
+
+CREATE INDEX IF NOT EXISTS idx_click_rollups_daily_bucket ON click_rollups_daily(bucket);
+
+-- Single row: clicks before rolled_up_until are covered by the rollups
+CREATE TABLE IF NOT EXISTS click_rollup_state (
+    id INTEGER PRIMARY KEY,
+    rolled_up_until TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_clicks_short_url_id_variant_id;
+DROP INDEX IF EXISTS idx_clicks_variant_id;
+ALTER TABLE clicks DROP COLUMN IF EXISTS variant_id;
+
+DROP TRIGGER IF EXISTS update_url_variants_updated_at ON url_variants;
+DROP TABLE IF EXISTS url_variants;
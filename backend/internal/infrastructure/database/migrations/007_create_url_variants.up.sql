@@ -0,0 +1,26 @@
+-- Weighted destinations of A/B split links
+CREATE TABLE IF NOT EXISTS url_variants (
+    id SERIAL PRIMARY KEY,
+    short_url_id INTEGER NOT NULL REFERENCES short_urls(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    destination_url TEXT NOT NULL,
+    weight INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_url_variants_short_url_id ON url_variants(short_url_id);
+CREATE INDEX IF NOT EXISTS idx_url_variants_deleted_at ON url_variants(deleted_at);
+
+DROP TRIGGER IF EXISTS update_url_variants_updated_at ON url_variants;
+CREATE TRIGGER update_url_variants_updated_at
+    BEFORE UPDATE ON url_variants
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- The variant a click was sent to, if the link was split
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS variant_id INTEGER REFERENCES url_variants(id);
+
+CREATE INDEX IF NOT EXISTS idx_clicks_variant_id ON clicks(variant_id);
+CREATE INDEX IF NOT EXISTS idx_clicks_short_url_id_variant_id ON clicks(short_url_id, variant_id) WHERE variant_id IS NOT NULL AND deleted_at IS NULL;
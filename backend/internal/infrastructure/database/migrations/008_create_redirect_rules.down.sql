@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_redirect_rules_updated_at ON redirect_rules;
+DROP TABLE IF EXISTS redirect_rules;
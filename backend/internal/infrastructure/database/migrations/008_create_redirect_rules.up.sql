@@ -0,0 +1,19 @@
+-- Ordered targeting rules that send matching visitors to an alternate destination
+CREATE TABLE IF NOT EXISTS redirect_rules (
+    id SERIAL PRIMARY KEY,
+    short_url_id INTEGER NOT NULL REFERENCES short_urls(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL DEFAULT 0,
+    name VARCHAR(100),
+    conditions TEXT NOT NULL,
+    destination_url TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_redirect_rules_short_url_id ON redirect_rules(short_url_id, position);
+
+DROP TRIGGER IF EXISTS update_redirect_rules_updated_at ON redirect_rules;
+CREATE TRIGGER update_redirect_rules_updated_at
+    BEFORE UPDATE ON redirect_rules
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
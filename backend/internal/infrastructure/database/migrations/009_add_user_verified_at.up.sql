@@ -0,0 +1,5 @@
+-- When the user confirmed their email address; NULL until they follow the emailed link
+ALTER TABLE users ADD COLUMN IF NOT EXISTS verified_at TIMESTAMP;
+
+-- Accounts created before verification existed are treated as verified
+UPDATE users SET verified_at = created_at WHERE verified_at IS NULL;
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS update_sessions_updated_at ON sessions;
+DROP TABLE IF EXISTS sessions;
@@ -0,0 +1,6 @@
+-- Roles for role-based access control: user, support or admin.
+-- Promote the first administrator by hand, for example:
+--   UPDATE users SET role = 'admin' WHERE email = 'you@example.com';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user';
+
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_short_urls_workspace_id;
+ALTER TABLE short_urls DROP COLUMN IF EXISTS workspace_id;
+
+DROP TABLE IF EXISTS workspace_invitations;
+DROP TRIGGER IF EXISTS update_workspace_members_updated_at ON workspace_members;
+DROP TABLE IF EXISTS workspace_members;
+DROP TRIGGER IF EXISTS update_workspaces_updated_at ON workspaces;
+DROP TABLE IF EXISTS workspaces;
@@ -0,0 +1,17 @@
+-- Every destination a link has had, so edits can be reviewed and rolled back
+CREATE TABLE IF NOT EXISTS url_revisions (
+    id SERIAL PRIMARY KEY,
+    short_url_id INTEGER NOT NULL REFERENCES short_urls(id) ON DELETE CASCADE,
+    old_url TEXT,
+    new_url TEXT NOT NULL,
+    editor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    rolled_back_from INTEGER REFERENCES url_revisions(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_url_revisions_short_url ON url_revisions(short_url_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_url_revisions_editor_id ON url_revisions(editor_id);
+
+-- Existing links start their history at their current destination
+INSERT INTO url_revisions (short_url_id, old_url, new_url, editor_id, created_at)
+SELECT id, '', original_url, user_id, created_at FROM short_urls;
@@ -0,0 +1,22 @@
+-- Bulk QR code jobs; the finished ZIP archive is kept until expires_at
+CREATE TABLE IF NOT EXISTS qr_batches (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    items TEXT NOT NULL,
+    options TEXT,
+    customization TEXT,
+    status VARCHAR(20) NOT NULL,
+    progress INTEGER NOT NULL DEFAULT 0,
+    failures INTEGER NOT NULL DEFAULT 0,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    archive BYTEA,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_qr_batches_user_id ON qr_batches(user_id);
+CREATE INDEX IF NOT EXISTS idx_qr_batches_status ON qr_batches(status);
+CREATE INDEX IF NOT EXISTS idx_qr_batches_expires_at ON qr_batches(expires_at);
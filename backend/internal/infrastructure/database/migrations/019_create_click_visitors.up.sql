@@ -0,0 +1,15 @@
+-- One row per URL and visitor IP, written with the clicks, so unique visitor
+-- counts do not scan raw clicks
+CREATE TABLE IF NOT EXISTS click_visitors (
+    short_url_id INTEGER NOT NULL REFERENCES short_urls(id) ON DELETE CASCADE,
+    ip_address INET NOT NULL,
+    first_seen_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (short_url_id, ip_address)
+);
+
+INSERT INTO click_visitors (short_url_id, ip_address, first_seen_at)
+SELECT short_url_id, ip_address, MIN(clicked_at)
+FROM clicks
+WHERE ip_address IS NOT NULL AND deleted_at IS NULL
+GROUP BY short_url_id, ip_address
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,311 @@
+package migrator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration files are named <version>_<name>.up.sql and <version>_<name>.down.sql
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+var nonAlphanumeric = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+var (
+	ErrChecksumMismatch = errors.New("checksum of applied migration has changed")
+	ErrMissingMigration = errors.New("applied migration file not found")
+	ErrMissingDown      = errors.New("migration has no down file")
+	ErrInvalidName      = errors.New("invalid migration name")
+)
+
+// Migration is a numbered pair of SQL files
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// AppliedMigration is a row of the schema_migrations table
+type AppliedMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	Checksum  string    `gorm:"size:64;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (AppliedMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status describes a migration file together with its applied state
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+	Modified  bool
+}
+
+// Migrator applies numbered SQL migrations and records them in schema_migrations
+type Migrator struct {
+	db   *gorm.DB
+	fsys fs.FS
+}
+
+func New(db *gorm.DB, fsys fs.FS) *Migrator {
+	return &Migrator{db: db, fsys: fsys}
+}
+
+// Load reads and pairs all migration files, ordered by version
+func (m *Migrator) Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(m.fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(m.fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("conflicting names for migration %d: %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+			migration.Checksum = checksum(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Up applies all pending migrations in order, each in its own transaction
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	migrations, applied, err := m.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(migration.Up).Error; err != nil {
+				return err
+			}
+			return tx.Create(&AppliedMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				Checksum:  migration.Checksum,
+				AppliedAt: time.Now().UTC(),
+			}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Down rolls back the last n applied migrations, newest first
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	migrations, applied, err := m.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []Migration
+	for i := len(migrations) - 1; i >= 0 && len(targets) < n; i-- {
+		if _, ok := applied[migrations[i].Version]; ok {
+			targets = append(targets, migrations[i])
+		}
+	}
+
+	// Check every target up front so we never stop halfway for a missing file
+	for _, migration := range targets {
+		if strings.TrimSpace(migration.Down) == "" {
+			return nil, fmt.Errorf("%w: %d_%s", ErrMissingDown, migration.Version, migration.Name)
+		}
+	}
+
+	var done []Migration
+	for _, migration := range targets {
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(migration.Down).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&AppliedMigration{}, "version = ?", migration.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("failed to roll back migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Status lists every migration file with its applied time and checksum state
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, migration := range migrations {
+		status := Status{Migration: migration}
+		if record, ok := applied[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.AppliedAt = &appliedAt
+			status.Modified = record.Checksum != migration.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// prepare loads the migration files and verifies that applied ones are unchanged
+func (m *Migrator) prepare(ctx context.Context) ([]Migration, map[int64]AppliedMigration, error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := make(map[int64]Migration, len(migrations))
+	for _, migration := range migrations {
+		files[migration.Version] = migration
+	}
+
+	for version, record := range applied {
+		migration, ok := files[version]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %d_%s", ErrMissingMigration, version, record.Name)
+		}
+		if migration.Checksum != record.Checksum {
+			return nil, nil, fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, version, record.Name)
+		}
+	}
+
+	return migrations, applied, nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]AppliedMigration, error) {
+	db := m.db.WithContext(ctx)
+	if err := db.AutoMigrate(&AppliedMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var records []AppliedMigration
+	if err := db.Order("version").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	applied := make(map[int64]AppliedMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// Create writes an empty up/down pair numbered after the newest migration in dir
+func Create(dir, name string) (string, string, error) {
+	name = strings.Trim(strings.ToLower(nonAlphanumeric.ReplaceAllString(name, "_")), "_")
+	if name == "" {
+		return "", "", ErrInvalidName
+	}
+
+	migrations, err := New(nil, os.DirFS(dir)).Load()
+	if err != nil {
+		return "", "", err
+	}
+
+	var version int64 = 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	base := fmt.Sprintf("%03d_%s", version, name)
+	upPath := filepath.Join(dir, base+".up.sql")
+	downPath := filepath.Join(dir, base+".down.sql")
+
+	if err := os.WriteFile(upPath, []byte(fmt.Sprintf("-- %s\n", name)), 0o644); err != nil {
+		return "", "", fmt.Errorf("failed to write %s: %w", upPath, err)
+	}
+	if err := os.WriteFile(downPath, []byte(fmt.Sprintf("-- Revert %s\n", name)), 0o644); err != nil {
+		return "", "", fmt.Errorf("failed to write %s: %w", downPath, err)
+	}
+
+	return upPath, downPath, nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,208 @@
+package migrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type MigratorTestSuite struct {
+	suite.Suite
+	db   *gorm.DB
+	fsys fstest.MapFS
+	ctx  context.Context
+}
+
+func (suite *MigratorTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	suite.Require().NoError(err)
+
+	// Every connection to :memory: is a new database, so pin the pool to one
+	sqlDB, err := db.DB()
+	suite.Require().NoError(err)
+	sqlDB.SetMaxOpenConns(1)
+
+	suite.db = db
+	suite.fsys = fstest.MapFS{
+		"001_create_widgets.up.sql":    {Data: []byte("CREATE TABLE widgets (id INTEGER PRIMARY KEY);")},
+		"001_create_widgets.down.sql":  {Data: []byte("DROP TABLE widgets;")},
+		"002_add_widget_name.up.sql":   {Data: []byte("ALTER TABLE widgets ADD COLUMN name TEXT;")},
+		"002_add_widget_name.down.sql": {Data: []byte("ALTER TABLE widgets DROP COLUMN name;")},
+		"003_create_gadgets.up.sql":    {Data: []byte("CREATE TABLE gadgets (id INTEGER PRIMARY KEY);\nCREATE INDEX idx_gadgets_id ON gadgets(id);")},
+		"003_create_gadgets.down.sql":  {Data: []byte("DROP TABLE gadgets;")},
+		"README.md":                    {Data: []byte("not a migration")},
+	}
+}
+
+func (suite *MigratorTestSuite) migrator() *Migrator {
+	return New(suite.db, suite.fsys)
+}
+
+func (suite *MigratorTestSuite) hasTable(name string) bool {
+	return suite.db.Migrator().HasTable(name)
+}
+
+func (suite *MigratorTestSuite) TestLoad_OrdersAndPairsFiles() {
+	migrations, err := suite.migrator().Load()
+	suite.Require().NoError(err)
+	suite.Require().Len(migrations, 3)
+
+	suite.Equal(int64(1), migrations[0].Version)
+	suite.Equal("create_widgets", migrations[0].Name)
+	suite.Equal("DROP TABLE widgets;", migrations[0].Down)
+	suite.Len(migrations[0].Checksum, 64)
+	suite.Equal(int64(3), migrations[2].Version)
+}
+
+func (suite *MigratorTestSuite) TestLoad_MissingUpFile() {
+	suite.fsys["004_orphan.down.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+
+	_, err := suite.migrator().Load()
+	suite.Error(err)
+}
+
+func (suite *MigratorTestSuite) TestUp_AppliesPendingInOrder() {
+	applied, err := suite.migrator().Up(suite.ctx)
+	suite.Require().NoError(err)
+	suite.Len(applied, 3)
+	suite.True(suite.hasTable("widgets"))
+	suite.True(suite.hasTable("gadgets"))
+	suite.True(suite.db.Migrator().HasColumn("widgets", "name"))
+
+	// A second run has nothing to do
+	applied, err = suite.migrator().Up(suite.ctx)
+	suite.Require().NoError(err)
+	suite.Empty(applied)
+
+	var count int64
+	suite.db.Model(&AppliedMigration{}).Count(&count)
+	suite.Equal(int64(3), count)
+}
+
+func (suite *MigratorTestSuite) TestUp_FailedMigrationIsNotRecorded() {
+	suite.fsys["004_broken.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE widgets (id INTEGER PRIMARY KEY);")}
+
+	applied, err := suite.migrator().Up(suite.ctx)
+	suite.Error(err)
+	suite.Len(applied, 3)
+
+	statuses, err := suite.migrator().Status(suite.ctx)
+	suite.Require().NoError(err)
+	suite.Nil(statuses[3].AppliedAt)
+}
+
+func (suite *MigratorTestSuite) TestUp_RefusesModifiedMigration() {
+	_, err := suite.migrator().Up(suite.ctx)
+	suite.Require().NoError(err)
+
+	suite.fsys["002_add_widget_name.up.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE widgets ADD COLUMN title TEXT;")}
+	suite.fsys["004_create_gizmos.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE gizmos (id INTEGER PRIMARY KEY);")}
+
+	_, err = suite.migrator().Up(suite.ctx)
+	suite.ErrorIs(err, ErrChecksumMismatch)
+	suite.False(suite.hasTable("gizmos"))
+
+	_, err = suite.migrator().Down(suite.ctx, 1)
+	suite.ErrorIs(err, ErrChecksumMismatch)
+
+	statuses, err := suite.migrator().Status(suite.ctx)
+	suite.Require().NoError(err)
+	suite.True(statuses[1].Modified)
+	suite.False(statuses[0].Modified)
+}
+
+func (suite *MigratorTestSuite) TestUp_RefusesMissingAppliedFile() {
+	_, err := suite.migrator().Up(suite.ctx)
+	suite.Require().NoError(err)
+
+	delete(suite.fsys, "003_create_gadgets.up.sql")
+	delete(suite.fsys, "003_create_gadgets.down.sql")
+
+	_, err = suite.migrator().Up(suite.ctx)
+	suite.ErrorIs(err, ErrMissingMigration)
+}
+
+func (suite *MigratorTestSuite) TestDown_RollsBackNewestFirst() {
+	_, err := suite.migrator().Up(suite.ctx)
+	suite.Require().NoError(err)
+
+	reverted, err := suite.migrator().Down(suite.ctx, 2)
+	suite.Require().NoError(err)
+	suite.Require().Len(reverted, 2)
+	suite.Equal(int64(3), reverted[0].Version)
+	suite.Equal(int64(2), reverted[1].Version)
+	suite.False(suite.hasTable("gadgets"))
+	suite.False(suite.db.Migrator().HasColumn("widgets", "name"))
+	suite.True(suite.hasTable("widgets"))
+
+	statuses, err := suite.migrator().Status(suite.ctx)
+	suite.Require().NoError(err)
+	suite.NotNil(statuses[0].AppliedAt)
+	suite.Nil(statuses[1].AppliedAt)
+	suite.Nil(statuses[2].AppliedAt)
+}
+
+func (suite *MigratorTestSuite) TestDown_MissingDownFile() {
+	delete(suite.fsys, "003_create_gadgets.down.sql")
+	_, err := suite.migrator().Up(suite.ctx)
+	suite.Require().NoError(err)
+
+	reverted, err := suite.migrator().Down(suite.ctx, 2)
+	suite.ErrorIs(err, ErrMissingDown)
+	suite.Empty(reverted)
+	suite.True(suite.hasTable("gadgets"))
+}
+
+func (suite *MigratorTestSuite) TestStatus_ReportsPending() {
+	statuses, err := suite.migrator().Status(suite.ctx)
+	suite.Require().NoError(err)
+	suite.Require().Len(statuses, 3)
+	for _, status := range statuses {
+		suite.Nil(status.AppliedAt)
+		suite.False(status.Modified)
+	}
+}
+
+func TestMigratorTestSuite(t *testing.T) {
+	suite.Run(t, new(MigratorTestSuite))
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+
+	upPath, downPath, err := Create(dir, "Add Widgets")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "001_add_widgets.up.sql"), upPath)
+	assert.Equal(t, filepath.Join(dir, "001_add_widgets.down.sql"), downPath)
+
+	upPath, _, err = Create(dir, "add-gadgets")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "002_add_gadgets.up.sql"), upPath)
+
+	_, err = os.Stat(upPath)
+	assert.NoError(t, err)
+
+	_, _, err = Create(dir, "  --  ")
+	assert.ErrorIs(t, err, ErrInvalidName)
+}
+
+func TestRepositoryMigrationsAreReversible(t *testing.T) {
+	migrations, err := New(nil, os.DirFS("../migrations")).Load()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, migration := range migrations {
+		assert.Equal(t, int64(i+1), migration.Version, "migration versions must be contiguous")
+		assert.NotEmpty(t, migration.Down, "migration %03d_%s has no down file", migration.Version, migration.Name)
+	}
+}
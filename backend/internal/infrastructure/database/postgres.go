@@ -80,8 +80,24 @@ func (d *Database) AutoMigrate() error {
 
 	err := d.DB.AutoMigrate(
 		&domain.User{},
+		&domain.APIKey{},
+		&domain.Session{},
+		&domain.RecoveryCode{},
+		&domain.UserIdentity{},
+		&domain.AuditEvent{},
+		&domain.Workspace{},
+		&domain.WorkspaceMember{},
+		&domain.WorkspaceInvitation{},
 		&domain.ShortURL{},
+		&domain.URLVariant{},
+		&domain.RedirectRule{},
+		&domain.URLRevision{},
 		&domain.Click{},
+		&domain.ClickRollupHourly{},
+		&domain.ClickRollupDaily{},
+		&domain.ClickRollupState{},
+		&domain.ClickVisitor{},
+		&domain.QRBatch{},
 	)
 
 	if err != nil {
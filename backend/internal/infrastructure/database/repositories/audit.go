@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) ports.AuditRepository {
+	return &auditRepository{
+		db: db,
+	}
+}
+
+func (r *auditRepository) Create(ctx context.Context, event *domain.AuditEvent) error {
+	if err := r.db.WithContext(ctx).Create(event).Error; err != nil {
+		return fmt.Errorf("failed to create audit event: %w", err)
+	}
+	return nil
+}
+
+func (r *auditRepository) List(ctx context.Context, filter domain.AuditFilter, offset, limit int) ([]*domain.AuditEvent, int64, error) {
+	var events []*domain.AuditEvent
+	var total int64
+
+	if err := r.db.WithContext(ctx).Model(&domain.AuditEvent{}).Scopes(auditFilterScope(filter)).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit events: %w", err)
+	}
+
+	if err := r.db.WithContext(ctx).
+		Scopes(auditFilterScope(filter)).
+		Offset(offset).
+		Limit(limit).
+		Order("created_at DESC, id DESC").
+		Find(&events).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit events: %w", err)
+	}
+
+	return events, total, nil
+}
+
+func auditFilterScope(filter domain.AuditFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.ActorID != nil {
+			db = db.Where("actor_id = ?", *filter.ActorID)
+		}
+		if filter.Action != "" {
+			db = db.Where("action = ?", filter.Action)
+		}
+		if filter.TargetType != "" {
+			db = db.Where("target_type = ?", filter.TargetType)
+		}
+		if filter.TargetID != nil {
+			db = db.Where("target_id = ?", *filter.TargetID)
+		}
+		if filter.From != nil {
+			db = db.Where("created_at >= ?", *filter.From)
+		}
+		if filter.To != nil {
+			db = db.Where("created_at < ?", *filter.To)
+		}
+		return db
+	}
+}
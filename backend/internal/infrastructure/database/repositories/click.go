@@ -3,13 +3,22 @@ package repositories
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"url-shortener/internal/core/domain"
 	"url-shortener/internal/core/ports"
 )
 
+// Keeps multi-row inserts well below PostgreSQL's 65535 bind parameter limit
+const clickInsertBatchSize = 1000
+
+func dateKey(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
 type clickRepository struct {
 	db *gorm.DB
 }
@@ -21,12 +30,157 @@ func NewClickRepository(db *gorm.DB) ports.ClickRepository {
 }
 
 func (r *clickRepository) Create(ctx context.Context, click *domain.Click) error {
-	if err := r.db.WithContext(ctx).Create(click).Error; err != nil {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := insertClicks(tx, []*domain.Click{click}); err != nil {
+			return err
+		}
+		if err := rewindRollups(tx, []*domain.Click{click}); err != nil {
+			return err
+		}
+		return recordVisitors(tx, []*domain.Click{click})
+	})
+	if err != nil {
 		return fmt.Errorf("failed to create click: %w", err)
 	}
 	return nil
 }
 
+func (r *clickRepository) CreateBatch(ctx context.Context, clicks []*domain.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := insertClicks(tx, clicks); err != nil {
+			return err
+		}
+		if err := rewindRollups(tx, clicks); err != nil {
+			return err
+		}
+		return recordVisitors(tx, clicks)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create clicks: %w", err)
+	}
+	return nil
+}
+
+func (r *clickRepository) RecordBatch(ctx context.Context, clicks []*domain.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	// One click_count update per URL rather than per click
+	counts := make(map[uint]int64)
+	for _, click := range clicks {
+		counts[click.ShortURLID]++
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := insertClicks(tx, clicks); err != nil {
+			return err
+		}
+		if err := rewindRollups(tx, clicks); err != nil {
+			return err
+		}
+		if err := recordVisitors(tx, clicks); err != nil {
+			return err
+		}
+		return incrementClickCounts(tx, counts)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record clicks: %w", err)
+	}
+	return nil
+}
+
+// insertClicks inserts clicks within tx, leaving ip_address NULL for clicks
+// whose IP address is unknown since "" is not a valid inet value
+func insertClicks(tx *gorm.DB, clicks []*domain.Click) error {
+	known := make([]*domain.Click, 0, len(clicks))
+	var unknown []*domain.Click
+	for _, click := range clicks {
+		if click.IPAddress == "" {
+			unknown = append(unknown, click)
+		} else {
+			known = append(known, click)
+		}
+	}
+	if len(known) > 0 {
+		if err := tx.CreateInBatches(known, clickInsertBatchSize).Error; err != nil {
+			return err
+		}
+	}
+	if len(unknown) > 0 {
+		if err := tx.Omit("IPAddress").CreateInBatches(unknown, clickInsertBatchSize).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewindRollups pulls the rollup watermark back to the hour of the earliest click
+// when that hour was already rolled up, so the aggregator rebuilds it and the hours
+// and days after it. Until then reads count those hours from the raw clicks.
+func rewindRollups(tx *gorm.DB, clicks []*domain.Click) error {
+	var earliest time.Time
+	for _, click := range clicks {
+		if !click.ClickedAt.IsZero() && (earliest.IsZero() || click.ClickedAt.Before(earliest)) {
+			earliest = click.ClickedAt
+		}
+	}
+	if earliest.IsZero() {
+		return nil
+	}
+
+	hour := earliest.UTC().Truncate(time.Hour)
+	if err := tx.Model(&domain.ClickRollupState{}).
+		Where("id = ? AND rolled_up_until > ?", rollupStateID, hour).
+		Update("rolled_up_until", hour).Error; err != nil {
+		return fmt.Errorf("failed to rewind rollup watermark: %w", err)
+	}
+	return nil
+}
+
+// recordVisitors adds the clicks' IP addresses to their URLs' visitors within tx,
+// keeping the first time each was seen
+func recordVisitors(tx *gorm.DB, clicks []*domain.Click) error {
+	type urlIP struct {
+		shortURLID uint
+		ip         string
+	}
+	seen := make(map[urlIP]bool, len(clicks))
+	visitors := make([]*domain.ClickVisitor, 0, len(clicks))
+	for _, click := range clicks {
+		key := urlIP{click.ShortURLID, click.IPAddress}
+		if click.IPAddress == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		visitors = append(visitors, &domain.ClickVisitor{
+			ShortURLID:  click.ShortURLID,
+			IPAddress:   click.IPAddress,
+			FirstSeenAt: click.ClickedAt,
+		})
+	}
+	if len(visitors) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		CreateInBatches(visitors, clickInsertBatchSize).Error
+}
+
+// countVisitors counts the distinct IP addresses that have clicked a URL
+func countVisitors(ctx context.Context, db *gorm.DB, shortURLID uint) (int64, error) {
+	var count int64
+	if err := db.WithContext(ctx).
+		Model(&domain.ClickVisitor{}).
+		Where("short_url_id = ?", shortURLID).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count unique clicks: %w", err)
+	}
+	return count, nil
+}
+
 func (r *clickRepository) GetByID(ctx context.Context, id uint) (*domain.Click, error) {
 	var click domain.Click
 	if err := r.db.WithContext(ctx).
@@ -65,65 +219,50 @@ func (r *clickRepository) GetByShortURLID(ctx context.Context, shortURLID uint,
 	return clicks, total, nil
 }
 
+// GetClickStats reads totals, the daily series and the hour-of-day profile from the
+// rollups, topping them up from raw clicks newer than the rollup watermark
 func (r *clickRepository) GetClickStats(ctx context.Context, shortURLID uint, period string) (*domain.ClickStats, error) {
 	stats := &domain.ClickStats{
 		ClicksByDate: make(map[string]int64),
 		ClicksByTime: make(map[int]int64),
 	}
 
+	watermark, err := rollupWatermark(r.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	scope := urlRollupScope(shortURLID)
+
 	// Get total clicks
-	if err := r.db.WithContext(ctx).
-		Model(&domain.Click{}).
-		Where("short_url_id = ?", shortURLID).
-		Count(&stats.TotalClicks).Error; err != nil {
+	totals, err := countByDimension(ctx, r.db, scope, domain.RollupDimensionTotal, time.Time{}, watermark)
+	if err != nil {
 		return nil, fmt.Errorf("failed to count total clicks: %w", err)
 	}
+	stats.TotalClicks = totals[""]
 
-	// Get unique clicks (distinct IP addresses)
-	if err := r.db.WithContext(ctx).
-		Model(&domain.Click{}).
-		Select("COUNT(DISTINCT ip_address)").
-		Where("short_url_id = ?", shortURLID).
-		Scan(&stats.UniqueClicks).Error; err != nil {
-		return nil, fmt.Errorf("failed to count unique clicks: %w", err)
+	// Get unique clicks (distinct IP addresses) from the URL's visitors
+	stats.UniqueClicks, err = countVisitors(ctx, r.db, shortURLID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get clicks by date (last 30 days)
-	var dateStats []struct {
-		Date  string `json:"date"`
-		Count int64  `json:"count"`
-	}
-	if err := r.db.WithContext(ctx).
-		Model(&domain.Click{}).
-		Select("DATE(clicked_at) as date, COUNT(*) as count").
-		Where("short_url_id = ? AND clicked_at >= ?", shortURLID, time.Now().AddDate(0, 0, -30)).
-		Group("DATE(clicked_at)").
-		Order("date").
-		Scan(&dateStats).Error; err != nil {
+	stats.ClicksByDate, err = clickSeries(ctx, r.db, scope, time.Now().AddDate(0, 0, -30), watermark, false, dateKey)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get clicks by date: %w", err)
 	}
 
-	for _, stat := range dateStats {
-		stats.ClicksByDate[stat.Date] = stat.Count
-	}
-
 	// Get clicks by hour
-	var timeStats []struct {
-		Hour  int   `json:"hour"`
-		Count int64 `json:"count"`
-	}
-	if err := r.db.WithContext(ctx).
-		Model(&domain.Click{}).
-		Select("EXTRACT(HOUR FROM clicked_at) as hour, COUNT(*) as count").
-		Where("short_url_id = ?", shortURLID).
-		Group("EXTRACT(HOUR FROM clicked_at)").
-		Order("hour").
-		Scan(&timeStats).Error; err != nil {
+	hours, err := countByDimension(ctx, r.db, scope, domain.RollupDimensionHour, time.Time{}, watermark)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get clicks by time: %w", err)
 	}
-
-	for _, stat := range timeStats {
-		stats.ClicksByTime[stat.Hour] = stat.Count
+	for hourStr, count := range hours {
+		hour, err := strconv.Atoi(hourStr)
+		if err != nil {
+			continue
+		}
+		stats.ClicksByTime[hour] = count
 	}
 
 	// Get top countries
@@ -171,142 +310,95 @@ func (r *clickRepository) GetGeoStats(ctx context.Context, shortURLID uint) (*do
 		CityStats:    make(map[string]int64),
 	}
 
-	// Get country stats
-	var countryStats []struct {
-		Country string `json:"country"`
-		Count   int64  `json:"count"`
-	}
-	if err := r.db.WithContext(ctx).
-		Model(&domain.Click{}).
-		Select("country, COUNT(*) as count").
-		Where("short_url_id = ? AND country != ''", shortURLID).
-		Group("country").
-		Order("count DESC").
-		Scan(&countryStats).Error; err != nil {
-		return nil, fmt.Errorf("failed to get country stats: %w", err)
+	watermark, err := rollupWatermark(r.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
 	}
+	scope := urlRollupScope(shortURLID)
 
-	for _, stat := range countryStats {
-		stats.CountryStats[stat.Country] = stat.Count
+	// Get country stats
+	countries, err := countByDimension(ctx, r.db, scope, domain.RollupDimensionCountry, time.Time{}, watermark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get country stats: %w", err)
 	}
+	stats.CountryStats = countries
 
 	// Get region stats
-	var regionStats []struct {
-		Region string `json:"region"`
-		Count  int64  `json:"count"`
-	}
-	if err := r.db.WithContext(ctx).
-		Model(&domain.Click{}).
-		Select("region, COUNT(*) as count").
-		Where("short_url_id = ? AND region != ''", shortURLID).
-		Group("region").
-		Order("count DESC").
-		Limit(20).
-		Scan(&regionStats).Error; err != nil {
+	regions, err := countByDimension(ctx, r.db, scope, domain.RollupDimensionRegion, time.Time{}, watermark)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get region stats: %w", err)
 	}
-
-	for _, stat := range regionStats {
-		stats.RegionStats[stat.Region] = stat.Count
+	for _, stat := range topValues(regions, 20) {
+		stats.RegionStats[stat.value] = stat.count
 	}
 
 	// Get city stats
-	var cityStats []struct {
-		City  string `json:"city"`
-		Count int64  `json:"count"`
-	}
-	if err := r.db.WithContext(ctx).
-		Model(&domain.Click{}).
-		Select("city, COUNT(*) as count").
-		Where("short_url_id = ? AND city != ''", shortURLID).
-		Group("city").
-		Order("count DESC").
-		Limit(20).
-		Scan(&cityStats).Error; err != nil {
+	cities, err := countByDimension(ctx, r.db, scope, domain.RollupDimensionCity, time.Time{}, watermark)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get city stats: %w", err)
 	}
-
-	for _, stat := range cityStats {
-		stats.CityStats[stat.City] = stat.Count
+	for _, stat := range topValues(cities, 20) {
+		stats.CityStats[stat.value] = stat.count
 	}
 
 	return stats, nil
 }
 
 func (r *clickRepository) GetTimelineStats(ctx context.Context, shortURLID uint, period string) (*domain.TimelineStats, error) {
-	stats := &domain.TimelineStats{
-		Period: period,
-		Data:   make(map[string]int64),
-	}
-
-	var dateFormat string
+	var key func(time.Time) string
 	var startDate time.Time
+	hourly := false
 
 	switch period {
 	case "day":
-		dateFormat = "DATE_FORMAT(clicked_at, '%Y-%m-%d %H:00:00')"
+		key = func(t time.Time) string { return t.Format("2006-01-02 15:00:00") }
 		startDate = time.Now().AddDate(0, 0, -1)
+		hourly = true
 	case "week":
-		dateFormat = "DATE(clicked_at)"
+		key = dateKey
 		startDate = time.Now().AddDate(0, 0, -7)
 	case "month":
-		dateFormat = "DATE(clicked_at)"
+		key = dateKey
 		startDate = time.Now().AddDate(0, -1, 0)
 	case "year":
-		dateFormat = "DATE_FORMAT(clicked_at, '%Y-%m')"
+		key = func(t time.Time) string { return t.Format("2006-01") }
 		startDate = time.Now().AddDate(-1, 0, 0)
 	default:
-		dateFormat = "DATE(clicked_at)"
+		key = dateKey
 		startDate = time.Now().AddDate(0, 0, -30)
 	}
 
-	var timelineData []struct {
-		Period string `json:"period"`
-		Count  int64  `json:"count"`
+	watermark, err := rollupWatermark(r.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
 	}
 
-	query := fmt.Sprintf(`
-		SELECT %s as period, COUNT(*) as count 
-		FROM clicks 
-		WHERE short_url_id = ? AND clicked_at >= ? 
-		GROUP BY %s 
-		ORDER BY period`,
-		dateFormat, dateFormat)
-
-	if err := r.db.WithContext(ctx).
-		Raw(query, shortURLID, startDate).
-		Scan(&timelineData).Error; err != nil {
+	data, err := clickSeries(ctx, r.db, urlRollupScope(shortURLID), startDate, watermark, hourly, key)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get timeline stats: %w", err)
 	}
 
-	for _, data := range timelineData {
-		stats.Data[data.Period] = data.Count
-	}
-
-	return stats, nil
+	return &domain.TimelineStats{
+		Period: period,
+		Data:   data,
+	}, nil
 }
 
 func (r *clickRepository) GetTotalClicks(ctx context.Context, shortURLID uint) (int64, error) {
-	var count int64
-	if err := r.db.WithContext(ctx).
-		Model(&domain.Click{}).
-		Where("short_url_id = ?", shortURLID).
-		Count(&count).Error; err != nil {
+	watermark, err := rollupWatermark(r.db.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+
+	totals, err := countByDimension(ctx, r.db, urlRollupScope(shortURLID), domain.RollupDimensionTotal, time.Time{}, watermark)
+	if err != nil {
 		return 0, fmt.Errorf("failed to count total clicks: %w", err)
 	}
-	return count, nil
+	return totals[""], nil
 }
 
 func (r *clickRepository) GetUniqueClicks(ctx context.Context, shortURLID uint) (int64, error) {
-	var count int64
-	if err := r.db.WithContext(ctx).
-		Model(&domain.Click{}).
-		Select("COUNT(DISTINCT ip_address)").
-		Where("short_url_id = ?", shortURLID).
-		Scan(&count).Error; err != nil {
-		return 0, fmt.Errorf("failed to count unique clicks: %w", err)
-	}
-	return count, nil
+	return countVisitors(ctx, r.db, shortURLID)
 }
 
 func (r *clickRepository) GetClicksByDateRange(ctx context.Context, shortURLID uint, startDate, endDate string) ([]*domain.Click, error) {
@@ -320,66 +412,82 @@ func (r *clickRepository) GetClicksByDateRange(ctx context.Context, shortURLID u
 	return clicks, nil
 }
 
-func (r *clickRepository) GetTopCountries(ctx context.Context, shortURLID uint, limit int) ([]domain.CountryStat, error) {
-	var stats []domain.CountryStat
+// GetClicksAfter returns up to limit clicks in [from, to) with an ID above afterID,
+// in ID order, so callers can page through large ranges without OFFSET scans
+func (r *clickRepository) GetClicksAfter(ctx context.Context, shortURLID uint, from, to time.Time, afterID uint, limit int) ([]*domain.Click, error) {
+	var clicks []*domain.Click
 	if err := r.db.WithContext(ctx).
-		Model(&domain.Click{}).
-		Select("country, COUNT(*) as count").
-		Where("short_url_id = ? AND country != ''", shortURLID).
-		Group("country").
-		Order("count DESC").
+		Where("short_url_id = ? AND clicked_at >= ? AND clicked_at < ? AND id > ?", shortURLID, from, to, afterID).
+		Order("id ASC").
 		Limit(limit).
-		Scan(&stats).Error; err != nil {
+		Find(&clicks).Error; err != nil {
+		return nil, fmt.Errorf("failed to get clicks: %w", err)
+	}
+	return clicks, nil
+}
+
+func (r *clickRepository) GetTopCountries(ctx context.Context, shortURLID uint, limit int) ([]domain.CountryStat, error) {
+	counts, err := r.topDimension(ctx, shortURLID, domain.RollupDimensionCountry)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get top countries: %w", err)
 	}
+
+	stats := make([]domain.CountryStat, 0, len(counts))
+	for _, stat := range topValues(counts, limit) {
+		stats = append(stats, domain.CountryStat{Country: stat.value, Count: stat.count})
+	}
 	return stats, nil
 }
 
 func (r *clickRepository) GetTopDevices(ctx context.Context, shortURLID uint, limit int) ([]domain.DeviceStat, error) {
-	var stats []domain.DeviceStat
-	if err := r.db.WithContext(ctx).
-		Model(&domain.Click{}).
-		Select("device, COUNT(*) as count").
-		Where("short_url_id = ? AND device != ''", shortURLID).
-		Group("device").
-		Order("count DESC").
-		Limit(limit).
-		Scan(&stats).Error; err != nil {
+	counts, err := r.topDimension(ctx, shortURLID, domain.RollupDimensionDevice)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get top devices: %w", err)
 	}
+
+	stats := make([]domain.DeviceStat, 0, len(counts))
+	for _, stat := range topValues(counts, limit) {
+		stats = append(stats, domain.DeviceStat{Device: stat.value, Count: stat.count})
+	}
 	return stats, nil
 }
 
 func (r *clickRepository) GetTopBrowsers(ctx context.Context, shortURLID uint, limit int) ([]domain.BrowserStat, error) {
-	var stats []domain.BrowserStat
-	if err := r.db.WithContext(ctx).
-		Model(&domain.Click{}).
-		Select("browser, COUNT(*) as count").
-		Where("short_url_id = ? AND browser != ''", shortURLID).
-		Group("browser").
-		Order("count DESC").
-		Limit(limit).
-		Scan(&stats).Error; err != nil {
+	counts, err := r.topDimension(ctx, shortURLID, domain.RollupDimensionBrowser)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get top browsers: %w", err)
 	}
+
+	stats := make([]domain.BrowserStat, 0, len(counts))
+	for _, stat := range topValues(counts, limit) {
+		stats = append(stats, domain.BrowserStat{Browser: stat.value, Count: stat.count})
+	}
 	return stats, nil
 }
 
+// GetTopReferers ranks referrer domains rather than full referrer URLs
 func (r *clickRepository) GetTopReferers(ctx context.Context, shortURLID uint, limit int) ([]domain.RefererStat, error) {
-	var stats []domain.RefererStat
-	if err := r.db.WithContext(ctx).
-		Model(&domain.Click{}).
-		Select("referer, COUNT(*) as count").
-		Where("short_url_id = ? AND referer != ''", shortURLID).
-		Group("referer").
-		Order("count DESC").
-		Limit(limit).
-		Scan(&stats).Error; err != nil {
+	counts, err := r.topDimension(ctx, shortURLID, domain.RollupDimensionReferrer)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get top referers: %w", err)
 	}
+
+	stats := make([]domain.RefererStat, 0, len(counts))
+	for _, stat := range topValues(counts, limit) {
+		stats = append(stats, domain.RefererStat{Referer: stat.value, Count: stat.count})
+	}
 	return stats, nil
 }
 
+// topDimension counts all-time clicks per value of a dimension
+func (r *clickRepository) topDimension(ctx context.Context, shortURLID uint, dimension string) (map[string]int64, error) {
+	watermark, err := rollupWatermark(r.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return countByDimension(ctx, r.db, urlRollupScope(shortURLID), dimension, time.Time{}, watermark)
+}
+
 func (r *clickRepository) GetRecentClicks(ctx context.Context, shortURLID uint, limit int) ([]domain.RecentClickStat, error) {
 	var stats []domain.RecentClickStat
 	if err := r.db.WithContext(ctx).
@@ -394,6 +502,22 @@ func (r *clickRepository) GetRecentClicks(ctx context.Context, shortURLID uint,
 	return stats, nil
 }
 
+// GetVariantClickCounts tallies clicks per A/B variant. Variant assignment isn't
+// rolled up, so this reads the raw clicks.
+func (r *clickRepository) GetVariantClickCounts(ctx context.Context, shortURLID uint) ([]domain.VariantClickCount, error) {
+	var counts []domain.VariantClickCount
+	if err := r.db.WithContext(ctx).
+		Model(&domain.Click{}).
+		Select("variant_id, COUNT(*) AS clicks, COUNT(DISTINCT ip_address) AS unique_clicks").
+		Where("short_url_id = ? AND variant_id IS NOT NULL", shortURLID).
+		Group("variant_id").
+		Order("variant_id").
+		Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("failed to get variant click counts: %w", err)
+	}
+	return counts, nil
+}
+
 func (r *clickRepository) GetGlobalStats(ctx context.Context) (*domain.GlobalStats, error) {
 	stats := &domain.GlobalStats{}
 
@@ -469,35 +593,25 @@ func (r *clickRepository) GetUserStats(ctx context.Context, userID uint) (*domai
 		return nil, fmt.Errorf("failed to count user URLs: %w", err)
 	}
 
+	watermark, err := rollupWatermark(r.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	scope := userRollupScope(userID)
+
 	// Get total clicks
-	if err := r.db.WithContext(ctx).
-		Table("clicks").
-		Joins("JOIN short_urls ON clicks.short_url_id = short_urls.id").
-		Where("short_urls.user_id = ?", userID).
-		Count(&analytics.TotalClicks).Error; err != nil {
+	totals, err := countByDimension(ctx, r.db, scope, domain.RollupDimensionTotal, time.Time{}, watermark)
+	if err != nil {
 		return nil, fmt.Errorf("failed to count user clicks: %w", err)
 	}
+	analytics.TotalClicks = totals[""]
 
 	// Get clicks by date (last 30 days)
-	var dateStats []struct {
-		Date  string `json:"date"`
-		Count int64  `json:"count"`
-	}
-	if err := r.db.WithContext(ctx).
-		Table("clicks").
-		Select("DATE(clicks.clicked_at) as date, COUNT(*) as count").
-		Joins("JOIN short_urls ON clicks.short_url_id = short_urls.id").
-		Where("short_urls.user_id = ? AND clicks.clicked_at >= ?", userID, time.Now().AddDate(0, 0, -30)).
-		Group("DATE(clicks.clicked_at)").
-		Order("date").
-		Scan(&dateStats).Error; err != nil {
+	analytics.ClicksByDate, err = clickSeries(ctx, r.db, scope, time.Now().AddDate(0, 0, -30), watermark, false, dateKey)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get clicks by date: %w", err)
 	}
 
-	for _, stat := range dateStats {
-		analytics.ClicksByDate[stat.Date] = stat.Count
-	}
-
 	// Get top URLs
 	if err := r.db.WithContext(ctx).
 		Model(&domain.ShortURL{}).
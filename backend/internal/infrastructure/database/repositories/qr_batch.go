@@ -0,0 +1,159 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+type qrBatchRepository struct {
+	db *gorm.DB
+}
+
+func NewQRBatchRepository(db *gorm.DB) ports.QRBatchRepository {
+	return &qrBatchRepository{
+		db: db,
+	}
+}
+
+func (r *qrBatchRepository) Create(ctx context.Context, batch *domain.QRBatch) error {
+	if err := r.db.WithContext(ctx).Create(batch).Error; err != nil {
+		return fmt.Errorf("failed to create QR batch: %w", err)
+	}
+	return nil
+}
+
+func (r *qrBatchRepository) GetByID(ctx context.Context, id uint) (*domain.QRBatch, error) {
+	var batch domain.QRBatch
+	if err := r.db.WithContext(ctx).Omit("archive").First(&batch, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, domain.ErrQRBatchNotFound
+		}
+		return nil, fmt.Errorf("failed to get QR batch by id: %w", err)
+	}
+	return &batch, nil
+}
+
+func (r *qrBatchRepository) GetArchive(ctx context.Context, id uint) ([]byte, error) {
+	var batch domain.QRBatch
+	if err := r.db.WithContext(ctx).Select("id", "archive").First(&batch, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, domain.ErrQRBatchNotFound
+		}
+		return nil, fmt.Errorf("failed to get QR batch archive: %w", err)
+	}
+	return batch.Archive, nil
+}
+
+func (r *qrBatchRepository) ClaimNext(ctx context.Context, staleBefore time.Time) (*domain.QRBatch, error) {
+	// A lost race means the batch is no longer waiting, so each retry finds
+	// another one or none at all
+	for {
+		batch, claimed, err := r.claimOldest(ctx, staleBefore)
+		if err != nil || batch == nil || claimed {
+			return batch, err
+		}
+	}
+}
+
+// claimOldest tries to claim the oldest waiting batch. It returns nil when none is
+// waiting, and the batch with claimed false when another worker took it first.
+func (r *qrBatchRepository) claimOldest(ctx context.Context, staleBefore time.Time) (*domain.QRBatch, bool, error) {
+	var batch domain.QRBatch
+	err := r.db.WithContext(ctx).
+		Omit("archive").
+		Where("status = ? OR (status = ? AND updated_at < ?)", domain.QRBatchPending, domain.QRBatchProcessing, staleBefore).
+		Order("created_at, id").
+		First(&batch).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to find waiting QR batch: %w", err)
+	}
+
+	// The attempt count doubles as a version: only one worker can move it on
+	now := time.Now()
+	result := r.db.WithContext(ctx).
+		Model(&domain.QRBatch{}).
+		Where("id = ? AND attempts = ?", batch.ID, batch.Attempts).
+		UpdateColumns(map[string]interface{}{
+			"status":     domain.QRBatchProcessing,
+			"attempts":   batch.Attempts + 1,
+			"updated_at": now,
+		})
+	if result.Error != nil {
+		return nil, false, fmt.Errorf("failed to claim QR batch: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return &batch, false, nil
+	}
+
+	batch.Status = domain.QRBatchProcessing
+	batch.Attempts++
+	batch.UpdatedAt = now
+	return &batch, true, nil
+}
+
+func (r *qrBatchRepository) UpdateProgress(ctx context.Context, id uint, progress int) error {
+	if err := r.db.WithContext(ctx).
+		Model(&domain.QRBatch{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"progress": progress, "updated_at": time.Now()}).Error; err != nil {
+		return fmt.Errorf("failed to update QR batch progress: %w", err)
+	}
+	return nil
+}
+
+func (r *qrBatchRepository) Complete(ctx context.Context, id uint, attempts int, archive []byte, failures int, completedAt, expiresAt time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&domain.QRBatch{}).
+		Where("id = ? AND attempts = ?", id, attempts).
+		UpdateColumns(map[string]interface{}{
+			"status":       domain.QRBatchCompleted,
+			"progress":     100,
+			"failures":     failures,
+			"archive":      archive,
+			"completed_at": completedAt,
+			"expires_at":   expiresAt,
+			"updated_at":   completedAt,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to complete QR batch: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *qrBatchRepository) Fail(ctx context.Context, id uint, message string, at time.Time) error {
+	if err := r.db.WithContext(ctx).
+		Model(&domain.QRBatch{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{
+			"status":       domain.QRBatchFailed,
+			"error":        message,
+			"completed_at": at,
+			"updated_at":   at,
+		}).Error; err != nil {
+		return fmt.Errorf("failed to mark QR batch as failed: %w", err)
+	}
+	return nil
+}
+
+func (r *qrBatchRepository) ExpireArchives(ctx context.Context, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Model(&domain.QRBatch{}).
+		Where("status = ? AND expires_at < ?", domain.QRBatchCompleted, now).
+		UpdateColumns(map[string]interface{}{
+			"status":     domain.QRBatchExpired,
+			"archive":    nil,
+			"updated_at": now,
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to expire QR batch archives: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
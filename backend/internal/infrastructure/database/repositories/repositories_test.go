@@ -19,6 +19,14 @@ type RepositoryTestSuite struct {
 	userRepo        ports.UserRepository
 	urlRepo         ports.URLRepository
 	clickRepo       ports.ClickRepository
+	rollupRepo      ports.ClickRollupRepository
+	apiKeyRepo      ports.APIKeyRepository
+	sessionRepo     ports.SessionRepository
+	recoveryRepo    ports.RecoveryCodeRepository
+	identityRepo    ports.UserIdentityRepository
+	auditRepo       ports.AuditRepository
+	workspaceRepo   ports.WorkspaceRepository
+	qrBatchRepo     ports.QRBatchRepository
 	ctx             context.Context
 	testUser        *domain.User
 	testURL         *domain.ShortURL
@@ -32,7 +40,7 @@ func (suite *RepositoryTestSuite) SetupSuite() {
 	suite.Require().NoError(err)
 
 	// Auto-migrate the schema
-	err = db.AutoMigrate(&domain.User{}, &domain.ShortURL{}, &domain.Click{})
+	err = db.AutoMigrate(&domain.User{}, &domain.APIKey{}, &domain.Session{}, &domain.RecoveryCode{}, &domain.UserIdentity{}, &domain.AuditEvent{}, &domain.Workspace{}, &domain.WorkspaceMember{}, &domain.WorkspaceInvitation{}, &domain.ShortURL{}, &domain.URLVariant{}, &domain.RedirectRule{}, &domain.URLRevision{}, &domain.Click{}, &domain.ClickRollupHourly{}, &domain.ClickRollupDaily{}, &domain.ClickRollupState{}, &domain.ClickVisitor{}, &domain.QRBatch{})
 	suite.Require().NoError(err)
 
 	suite.db = db
@@ -41,12 +49,36 @@ func (suite *RepositoryTestSuite) SetupSuite() {
 	suite.userRepo = NewUserRepository(db)
 	suite.urlRepo = NewURLRepository(db)
 	suite.clickRepo = NewClickRepository(db)
+	suite.rollupRepo = NewClickRollupRepository(db)
+	suite.apiKeyRepo = NewAPIKeyRepository(db)
+	suite.sessionRepo = NewSessionRepository(db)
+	suite.recoveryRepo = NewRecoveryCodeRepository(db)
+	suite.identityRepo = NewUserIdentityRepository(db)
+	suite.auditRepo = NewAuditRepository(db)
+	suite.workspaceRepo = NewWorkspaceRepository(db)
+	suite.qrBatchRepo = NewQRBatchRepository(db)
 }
 
 func (suite *RepositoryTestSuite) SetupTest() {
 	// Clean up tables before each test
+	suite.db.Exec("DELETE FROM qr_batches")
+	suite.db.Exec("DELETE FROM click_rollup_state")
+	suite.db.Exec("DELETE FROM click_visitors")
+	suite.db.Exec("DELETE FROM click_rollups_daily")
+	suite.db.Exec("DELETE FROM click_rollups_hourly")
 	suite.db.Exec("DELETE FROM clicks")
+	suite.db.Exec("DELETE FROM url_variants")
+	suite.db.Exec("DELETE FROM redirect_rules")
+	suite.db.Exec("DELETE FROM url_revisions")
 	suite.db.Exec("DELETE FROM short_urls")
+	suite.db.Exec("DELETE FROM api_keys")
+	suite.db.Exec("DELETE FROM sessions")
+	suite.db.Exec("DELETE FROM recovery_codes")
+	suite.db.Exec("DELETE FROM user_identities")
+	suite.db.Exec("DELETE FROM audit_events")
+	suite.db.Exec("DELETE FROM workspace_invitations")
+	suite.db.Exec("DELETE FROM workspace_members")
+	suite.db.Exec("DELETE FROM workspaces")
 	suite.db.Exec("DELETE FROM users")
 
 	// Create test user
@@ -61,7 +93,7 @@ func (suite *RepositoryTestSuite) SetupTest() {
 	suite.testURL = &domain.ShortURL{
 		ShortCode:   "test123",
 		OriginalURL: "https://example.com",
-		UserID:      &suite.testUser.ID,
+		UserID:      suite.testUser.ID,
 		IsActive:    true,
 	}
 	err = suite.urlRepo.Create(suite.ctx, suite.testURL)
@@ -130,6 +162,23 @@ func (suite *RepositoryTestSuite) TestUserRepository_Update() {
 	suite.Equal("updated@example.com", user.Email)
 }
 
+func (suite *RepositoryTestSuite) TestUserRepository_AdvanceTOTPStep() {
+	advanced, err := suite.userRepo.AdvanceTOTPStep(suite.ctx, suite.testUser.ID, 100)
+	suite.NoError(err)
+	suite.True(advanced)
+
+	// The same step, or an older one, has already been used
+	for _, step := range []int64{100, 99} {
+		advanced, err = suite.userRepo.AdvanceTOTPStep(suite.ctx, suite.testUser.ID, step)
+		suite.NoError(err)
+		suite.False(advanced)
+	}
+
+	user, err := suite.userRepo.GetByID(suite.ctx, suite.testUser.ID)
+	suite.NoError(err)
+	suite.Equal(int64(100), user.TOTPLastStep)
+}
+
 func (suite *RepositoryTestSuite) TestUserRepository_Delete() {
 	err := suite.userRepo.Delete(suite.ctx, suite.testUser.ID)
 	suite.NoError(err)
@@ -178,12 +227,57 @@ func (suite *RepositoryTestSuite) TestUserRepository_List() {
 	suite.Len(users, 2)
 }
 
+func (suite *RepositoryTestSuite) TestUserRepository_Search() {
+	alice := &domain.User{Email: "alice@example.com", Password: "password", FirstName: "Alice", Role: domain.RoleSupport}
+	bob := &domain.User{Email: "bob@example.com", Password: "password", LastName: "Alison"}
+	carol := &domain.User{Email: "carol_x@example.com", Password: "password"}
+	for _, user := range []*domain.User{alice, bob, carol} {
+		suite.Require().NoError(suite.userRepo.Create(suite.ctx, user))
+	}
+	carol.IsActive = false
+	suite.Require().NoError(suite.userRepo.Update(suite.ctx, carol))
+
+	// Matches email or name, ignoring case
+	users, total, err := suite.userRepo.Search(suite.ctx, domain.UserFilter{Query: "ALI"}, 0, 10)
+	suite.NoError(err)
+	suite.Equal(int64(2), total)
+	suite.Len(users, 2)
+
+	// LIKE wildcards in the query are matched literally
+	users, _, err = suite.userRepo.Search(suite.ctx, domain.UserFilter{Query: "_x"}, 0, 10)
+	suite.NoError(err)
+	suite.Require().Len(users, 1)
+	suite.Equal(carol.ID, users[0].ID)
+
+	users, _, err = suite.userRepo.Search(suite.ctx, domain.UserFilter{Role: domain.RoleSupport}, 0, 10)
+	suite.NoError(err)
+	suite.Require().Len(users, 1)
+	suite.Equal(alice.ID, users[0].ID)
+
+	// New accounts default to the user role
+	_, total, err = suite.userRepo.Search(suite.ctx, domain.UserFilter{Role: domain.RoleUser}, 0, 10)
+	suite.NoError(err)
+	suite.Equal(int64(3), total) // bob, carol and the test user
+
+	inactive := false
+	users, _, err = suite.userRepo.Search(suite.ctx, domain.UserFilter{Active: &inactive}, 0, 10)
+	suite.NoError(err)
+	suite.Require().Len(users, 1)
+	suite.Equal(carol.ID, users[0].ID)
+
+	// Pagination applies after filtering
+	users, total, err = suite.userRepo.Search(suite.ctx, domain.UserFilter{Query: "example.com"}, 1, 2)
+	suite.NoError(err)
+	suite.Equal(int64(4), total)
+	suite.Len(users, 2)
+}
+
 // URL Repository Tests
 func (suite *RepositoryTestSuite) TestURLRepository_Create() {
 	url := &domain.ShortURL{
 		ShortCode:   "new123",
 		OriginalURL: "https://newexample.com",
-		UserID:      &suite.testUser.ID,
+		UserID:      suite.testUser.ID,
 		IsActive:    true,
 	}
 
@@ -195,7 +289,7 @@ func (suite *RepositoryTestSuite) TestURLRepository_Create() {
 	duplicateURL := &domain.ShortURL{
 		ShortCode:   "new123",
 		OriginalURL: "https://another.com",
-		UserID:      &suite.testUser.ID,
+		UserID:      suite.testUser.ID,
 	}
 	err = suite.urlRepo.Create(suite.ctx, duplicateURL)
 	suite.Error(err)
@@ -242,13 +336,37 @@ func (suite *RepositoryTestSuite) TestURLRepository_IncrementClickCount() {
 	suite.Equal(originalCount+1, url.ClickCount)
 }
 
+func (suite *RepositoryTestSuite) TestURLRepository_IncrementClickCounts() {
+	other := &domain.ShortURL{
+		ShortCode:   "counts1",
+		OriginalURL: "https://example.com/other",
+		UserID:      suite.testUser.ID,
+		IsActive:    true,
+	}
+	suite.Require().NoError(suite.urlRepo.Create(suite.ctx, other))
+
+	err := suite.urlRepo.IncrementClickCounts(suite.ctx, map[uint]int64{
+		suite.testURL.ID: 5,
+		other.ID:         2,
+	})
+	suite.NoError(err)
+
+	url, err := suite.urlRepo.GetByID(suite.ctx, suite.testURL.ID)
+	suite.NoError(err)
+	suite.Equal(suite.testURL.ClickCount+5, url.ClickCount)
+
+	url, err = suite.urlRepo.GetByID(suite.ctx, other.ID)
+	suite.NoError(err)
+	suite.Equal(int64(2), url.ClickCount)
+}
+
 func (suite *RepositoryTestSuite) TestURLRepository_GetExpiredURLs() {
 	// Create expired URL
 	pastTime := time.Now().Add(-time.Hour)
 	expiredURL := &domain.ShortURL{
 		ShortCode:   "expired",
 		OriginalURL: "https://expired.com",
-		UserID:      &suite.testUser.ID,
+		UserID:      suite.testUser.ID,
 		ExpiresAt:   &pastTime,
 		IsActive:    true,
 	}
@@ -262,6 +380,145 @@ func (suite *RepositoryTestSuite) TestURLRepository_GetExpiredURLs() {
 }
 
 // Click Repository Tests
+func (suite *RepositoryTestSuite) TestURLRepository_ReplaceVariants() {
+	err := suite.urlRepo.ReplaceVariants(suite.ctx, suite.testURL.ID, []*domain.URLVariant{
+		{Name: "A", DestinationURL: "https://example.com/a", Weight: 1},
+		{Name: "B", DestinationURL: "https://example.com/b", Weight: 1},
+	})
+	suite.Require().NoError(err)
+
+	url, err := suite.urlRepo.GetActiveByShortCode(suite.ctx, suite.testURL.ShortCode)
+	suite.Require().NoError(err)
+	suite.Require().Len(url.Variants, 2)
+	variantA := url.Variants[0]
+
+	// A is kept and updated by name, B is removed and C is added
+	err = suite.urlRepo.ReplaceVariants(suite.ctx, suite.testURL.ID, []*domain.URLVariant{
+		{Name: "a", DestinationURL: "https://example.com/a2", Weight: 3},
+		{Name: "C", DestinationURL: "https://example.com/c", Weight: 1},
+	})
+	suite.Require().NoError(err)
+
+	url, err = suite.urlRepo.GetByID(suite.ctx, suite.testURL.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(url.Variants, 2)
+	suite.Equal(variantA.ID, url.Variants[0].ID)
+	suite.Equal("https://example.com/a2", url.Variants[0].DestinationURL)
+	suite.Equal(3, url.Variants[0].Weight)
+	suite.Equal("C", url.Variants[1].Name)
+
+	// Removed variants are still listed for reporting
+	all, err := suite.urlRepo.GetVariants(suite.ctx, suite.testURL.ID)
+	suite.Require().NoError(err)
+	suite.Len(all, 3)
+	suite.Equal("B", all[1].Name)
+	suite.True(all[1].DeletedAt.Valid)
+
+	// Saving the URL leaves its variants alone
+	url.Title = "Renamed"
+	url.Variants[0].Weight = 100
+	suite.Require().NoError(suite.urlRepo.Update(suite.ctx, url))
+	url, err = suite.urlRepo.GetByID(suite.ctx, suite.testURL.ID)
+	suite.Require().NoError(err)
+	suite.Equal(3, url.Variants[0].Weight)
+}
+
+func (suite *RepositoryTestSuite) TestURLRepository_ReplaceRedirectRules() {
+	window := &domain.TimeWindow{Start: "22:00", End: "06:00", Timezone: "Europe/Berlin"}
+	err := suite.urlRepo.ReplaceRedirectRules(suite.ctx, suite.testURL.ID, []*domain.RedirectRule{
+		{Name: "night", Conditions: domain.RuleConditions{TimeWindow: window}, DestinationURL: "https://example.com/night"},
+		{Name: "uk", Conditions: domain.RuleConditions{Countries: []string{"GB"}, Devices: []string{"mobile"}}, DestinationURL: "https://example.co.uk"},
+	})
+	suite.Require().NoError(err)
+
+	url, err := suite.urlRepo.GetActiveByShortCode(suite.ctx, suite.testURL.ShortCode)
+	suite.Require().NoError(err)
+	suite.Require().Len(url.RedirectRules, 2)
+	suite.Equal("night", url.RedirectRules[0].Name)
+	suite.Equal(window, url.RedirectRules[0].Conditions.TimeWindow)
+	suite.Equal([]string{"GB"}, url.RedirectRules[1].Conditions.Countries)
+	suite.Equal(1, url.RedirectRules[1].Position)
+
+	// Reordering replaces the whole list
+	err = suite.urlRepo.ReplaceRedirectRules(suite.ctx, suite.testURL.ID, []*domain.RedirectRule{
+		&url.RedirectRules[1],
+	})
+	suite.Require().NoError(err)
+
+	url, err = suite.urlRepo.GetByID(suite.ctx, suite.testURL.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(url.RedirectRules, 1)
+	suite.Equal("uk", url.RedirectRules[0].Name)
+	suite.Equal(0, url.RedirectRules[0].Position)
+
+	suite.Require().NoError(suite.urlRepo.ReplaceRedirectRules(suite.ctx, suite.testURL.ID, nil))
+	url, err = suite.urlRepo.GetByID(suite.ctx, suite.testURL.ID)
+	suite.Require().NoError(err)
+	suite.Empty(url.RedirectRules)
+}
+
+func (suite *RepositoryTestSuite) TestURLRepository_Revisions() {
+	// A link created with a revision starts its history with it
+	url := &domain.ShortURL{
+		ShortCode:   "hist01",
+		OriginalURL: "https://example.com/v1",
+		UserID:      suite.testUser.ID,
+		IsActive:    true,
+		Revisions:   []domain.URLRevision{*domain.NewURLRevision(0, "", "https://example.com/v1", suite.testUser.ID)},
+	}
+	suite.Require().NoError(suite.urlRepo.Create(suite.ctx, url))
+
+	second := domain.NewURLRevision(url.ID, "https://example.com/v1", "https://example.com/v2", suite.testUser.ID)
+	second.CreatedAt = second.CreatedAt.Add(time.Second)
+	url.OriginalURL = "https://example.com/v2"
+	suite.Require().NoError(suite.urlRepo.UpdateWithRevision(suite.ctx, url, second))
+
+	updated, err := suite.urlRepo.GetByID(suite.ctx, url.ID)
+	suite.Require().NoError(err)
+	suite.Equal("https://example.com/v2", updated.OriginalURL)
+
+	revisions, total, err := suite.urlRepo.GetRevisions(suite.ctx, url.ID, 0, 10)
+	suite.Require().NoError(err)
+	suite.Equal(int64(2), total)
+	suite.Require().Len(revisions, 2)
+	suite.Equal("https://example.com/v2", revisions[0].NewURL)
+	suite.Equal("", revisions[1].OldURL)
+	suite.Equal(suite.testUser.ID, *revisions[1].EditorID)
+
+	revision, err := suite.urlRepo.GetRevision(suite.ctx, url.ID, second.ID)
+	suite.Require().NoError(err)
+	suite.Equal("https://example.com/v1", revision.OldURL)
+
+	// Revisions are only found through their own link
+	_, err = suite.urlRepo.GetRevision(suite.ctx, suite.testURL.ID, second.ID)
+	suite.Equal(domain.ErrRevisionNotFound, err)
+}
+
+func (suite *RepositoryTestSuite) TestClickRepository_GetVariantClickCounts() {
+	variants := []*domain.URLVariant{
+		{Name: "A", DestinationURL: "https://example.com/a", Weight: 1},
+		{Name: "B", DestinationURL: "https://example.com/b", Weight: 1},
+	}
+	suite.Require().NoError(suite.urlRepo.ReplaceVariants(suite.ctx, suite.testURL.ID, variants))
+
+	now := time.Now()
+	clicks := []*domain.Click{
+		{ShortURLID: suite.testURL.ID, IPAddress: "192.168.1.1", VariantID: &variants[0].ID, ClickedAt: now},
+		{ShortURLID: suite.testURL.ID, IPAddress: "192.168.1.1", VariantID: &variants[0].ID, ClickedAt: now},
+		{ShortURLID: suite.testURL.ID, IPAddress: "192.168.1.2", VariantID: &variants[0].ID, ClickedAt: now},
+		{ShortURLID: suite.testURL.ID, IPAddress: "192.168.1.3", VariantID: &variants[1].ID, ClickedAt: now},
+		{ShortURLID: suite.testURL.ID, IPAddress: "192.168.1.4", ClickedAt: now},
+	}
+	suite.Require().NoError(suite.clickRepo.CreateBatch(suite.ctx, clicks))
+
+	counts, err := suite.clickRepo.GetVariantClickCounts(suite.ctx, suite.testURL.ID)
+	suite.Require().NoError(err)
+	suite.Equal([]domain.VariantClickCount{
+		{VariantID: variants[0].ID, Clicks: 3, UniqueClicks: 2},
+		{VariantID: variants[1].ID, Clicks: 1, UniqueClicks: 1},
+	}, counts)
+}
+
 func (suite *RepositoryTestSuite) TestClickRepository_Create() {
 	click := &domain.Click{
 		ShortURLID: suite.testURL.ID,
@@ -279,6 +536,122 @@ func (suite *RepositoryTestSuite) TestClickRepository_Create() {
 	suite.NotZero(click.ID)
 }
 
+func (suite *RepositoryTestSuite) TestClickRepository_CreateBatch() {
+	clicks := make([]*domain.Click, 0, 3)
+	for i := 0; i < 3; i++ {
+		clicks = append(clicks, &domain.Click{
+			ShortURLID: suite.testURL.ID,
+			IPAddress:  fmt.Sprintf("10.0.0.%d", i+1),
+			ClickedAt:  time.Now(),
+		})
+	}
+
+	err := suite.clickRepo.CreateBatch(suite.ctx, clicks)
+	suite.NoError(err)
+	for _, click := range clicks {
+		suite.NotZero(click.ID)
+	}
+
+	count, err := suite.clickRepo.GetTotalClicks(suite.ctx, suite.testURL.ID)
+	suite.NoError(err)
+	suite.Equal(int64(3), count)
+}
+
+func (suite *RepositoryTestSuite) TestClickRepository_RecordBatch() {
+	other := &domain.ShortURL{
+		ShortCode:   "record1",
+		OriginalURL: "https://example.com/other",
+		UserID:      suite.testUser.ID,
+		IsActive:    true,
+	}
+	suite.Require().NoError(suite.urlRepo.Create(suite.ctx, other))
+
+	clicks := []*domain.Click{
+		{ShortURLID: suite.testURL.ID, IPAddress: "10.0.0.1", ClickedAt: time.Now()},
+		{ShortURLID: suite.testURL.ID, IPAddress: "10.0.0.2", ClickedAt: time.Now()},
+		{ShortURLID: other.ID, IPAddress: "10.0.0.1", ClickedAt: time.Now()},
+	}
+	suite.Require().NoError(suite.clickRepo.RecordBatch(suite.ctx, clicks))
+
+	url, err := suite.urlRepo.GetByID(suite.ctx, suite.testURL.ID)
+	suite.Require().NoError(err)
+	suite.Equal(suite.testURL.ClickCount+2, url.ClickCount)
+	visitors, err := suite.clickRepo.GetUniqueClicks(suite.ctx, suite.testURL.ID)
+	suite.Require().NoError(err)
+	suite.Equal(int64(2), visitors)
+	url, err = suite.urlRepo.GetByID(suite.ctx, other.ID)
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), url.ClickCount)
+
+	// A click that cannot be stored leaves every count untouched
+	err = suite.clickRepo.RecordBatch(suite.ctx, []*domain.Click{
+		{ShortURLID: other.ID, IPAddress: "10.0.0.3", ClickedAt: time.Now()},
+		{ID: clicks[0].ID, ShortURLID: other.ID, IPAddress: "10.0.0.4", ClickedAt: time.Now()},
+	})
+	suite.Error(err)
+	url, err = suite.urlRepo.GetByID(suite.ctx, other.ID)
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), url.ClickCount)
+	total, err := suite.clickRepo.GetTotalClicks(suite.ctx, other.ID)
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), total)
+	visitors, err = suite.clickRepo.GetUniqueClicks(suite.ctx, other.ID)
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), visitors)
+}
+
+func (suite *RepositoryTestSuite) TestClickRepository_RecordBatch_UnknownIP() {
+	clicks := []*domain.Click{
+		{ShortURLID: suite.testURL.ID, IPAddress: "10.0.0.1", ClickedAt: time.Now()},
+		{ShortURLID: suite.testURL.ID, ClickedAt: time.Now()},
+	}
+	suite.Require().NoError(suite.clickRepo.RecordBatch(suite.ctx, clicks))
+
+	var nulls int64
+	suite.Require().NoError(suite.db.Model(&domain.Click{}).
+		Where("short_url_id = ? AND ip_address IS NULL", suite.testURL.ID).
+		Count(&nulls).Error)
+	suite.Equal(int64(1), nulls)
+
+	stored, err := suite.clickRepo.GetByID(suite.ctx, clicks[1].ID)
+	suite.Require().NoError(err)
+	suite.Empty(stored.IPAddress)
+	visitors, err := suite.clickRepo.GetUniqueClicks(suite.ctx, suite.testURL.ID)
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), visitors)
+}
+
+func (suite *RepositoryTestSuite) TestClickRepository_GetClicksAfter() {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	clickedAt := []time.Time{
+		day.Add(-time.Minute), // before the range
+		day,
+		day.Add(6 * time.Hour),
+		day.Add(30 * time.Hour),
+		day.Add(47 * time.Hour),
+		day.Add(48 * time.Hour), // end is exclusive
+	}
+	for i, at := range clickedAt {
+		click := &domain.Click{
+			ShortURLID: suite.testURL.ID,
+			IPAddress:  fmt.Sprintf("10.0.1.%d", i+1),
+			ClickedAt:  at,
+		}
+		suite.Require().NoError(suite.clickRepo.Create(suite.ctx, click))
+	}
+
+	from, to := day, day.AddDate(0, 0, 2)
+	first, err := suite.clickRepo.GetClicksAfter(suite.ctx, suite.testURL.ID, from, to, 0, 3)
+	suite.NoError(err)
+	suite.Len(first, 3)
+
+	rest, err := suite.clickRepo.GetClicksAfter(suite.ctx, suite.testURL.ID, from, to, first[2].ID, 3)
+	suite.NoError(err)
+	suite.Require().Len(rest, 1)
+	suite.Greater(rest[0].ID, first[2].ID)
+	suite.Equal("10.0.1.5", rest[0].IPAddress)
+}
+
 func (suite *RepositoryTestSuite) TestClickRepository_GetTotalClicks() {
 	// Create test clicks
 	for i := 0; i < 5; i++ {
@@ -363,6 +736,582 @@ func (suite *RepositoryTestSuite) TestClickRepository_GetGlobalStats() {
 	suite.Equal(int64(1), stats.ActiveURLs)
 }
 
+// Click Rollup Tests
+func (suite *RepositoryTestSuite) createRollupClicks(base time.Time) {
+	clicks := []*domain.Click{
+		{Country: "GB", Device: "desktop", Browser: "Firefox", Referer: "https://www.google.com/search?q=a", ClickedAt: base.Add(10 * time.Minute)},
+		{Country: "GB", Device: "mobile", Browser: "Safari", Referer: "https://google.com/", ClickedAt: base.Add(20 * time.Minute)},
+		{Country: "US", Device: "mobile", Browser: "Chrome", Referer: "https://t.co/xyz", ClickedAt: base.Add(70 * time.Minute)},
+		{Country: "US", Device: "desktop", Browser: "Chrome", ClickedAt: base.Add(26 * time.Hour)},
+		{Country: "SE", Device: "tablet", Browser: "Safari", Referer: "not a url", ClickedAt: base.Add(50 * time.Hour)},
+	}
+	for i, click := range clicks {
+		click.ShortURLID = suite.testURL.ID
+		click.IPAddress = fmt.Sprintf("10.0.2.%d", i+1)
+		suite.Require().NoError(suite.clickRepo.Create(suite.ctx, click))
+	}
+}
+
+func (suite *RepositoryTestSuite) TestClickRollupRepository_RollupHour() {
+	base := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	suite.createRollupClicks(base)
+
+	suite.Require().NoError(suite.rollupRepo.RollupHour(suite.ctx, base))
+
+	var hourly []domain.ClickRollupHourly
+	suite.db.Where("bucket = ?", base).Order("dimension, value").Find(&hourly)
+	counts := make(map[string]int64)
+	for _, row := range hourly {
+		counts[row.Dimension+":"+row.Value] = row.Clicks
+	}
+	suite.Equal(int64(2), counts["total:"])
+	suite.Equal(int64(2), counts["hour:9"])
+	suite.Equal(int64(2), counts["country:GB"])
+	suite.Equal(int64(1), counts["device:mobile"])
+	suite.Equal(int64(2), counts["referrer:google.com"])
+
+	watermark, err := suite.rollupRepo.GetWatermark(suite.ctx)
+	suite.NoError(err)
+	suite.True(watermark.Equal(base.Add(time.Hour)))
+
+	// Rolling up the same hour again replaces rather than adds
+	suite.Require().NoError(suite.rollupRepo.RollupHour(suite.ctx, base))
+	var daily domain.ClickRollupDaily
+	suite.Require().NoError(suite.db.Where("dimension = ? AND value = ?", domain.RollupDimensionTotal, "").First(&daily).Error)
+	suite.Equal(int64(2), daily.Clicks)
+	suite.True(daily.Bucket.UTC().Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func (suite *RepositoryTestSuite) TestClickRollupRepository_LateClicks() {
+	base := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	suite.createRollupClicks(base)
+	for _, hour := range []time.Duration{0, time.Hour, 26 * time.Hour} {
+		suite.Require().NoError(suite.rollupRepo.RollupHour(suite.ctx, base.Add(hour)))
+	}
+	watermark, err := suite.rollupRepo.GetWatermark(suite.ctx)
+	suite.Require().NoError(err)
+	suite.True(watermark.Equal(base.Add(27 * time.Hour)))
+
+	// A click stored after its hour was rolled up sends the watermark back to it
+	late := []*domain.Click{
+		{ShortURLID: suite.testURL.ID, IPAddress: "10.0.3.1", ClickedAt: base.Add(28 * time.Hour)},
+		{ShortURLID: suite.testURL.ID, IPAddress: "10.0.3.2", ClickedAt: base.Add(75 * time.Minute)},
+	}
+	suite.Require().NoError(suite.clickRepo.RecordBatch(suite.ctx, late))
+	watermark, err = suite.rollupRepo.GetWatermark(suite.ctx)
+	suite.Require().NoError(err)
+	suite.True(watermark.Equal(base.Add(time.Hour)))
+
+	// Its clicks are counted from the raw clicks until the hour is rebuilt
+	total, err := suite.clickRepo.GetTotalClicks(suite.ctx, suite.testURL.ID)
+	suite.Require().NoError(err)
+	suite.Equal(int64(7), total)
+
+	// Rolling up a later hour does not skip over the rewound one
+	suite.Require().NoError(suite.rollupRepo.RollupHour(suite.ctx, base.Add(26*time.Hour)))
+	watermark, err = suite.rollupRepo.GetWatermark(suite.ctx)
+	suite.Require().NoError(err)
+	suite.True(watermark.Equal(base.Add(time.Hour)))
+
+	suite.Require().NoError(suite.rollupRepo.RollupHour(suite.ctx, base.Add(time.Hour)))
+	var hourly domain.ClickRollupHourly
+	suite.Require().NoError(suite.db.Where("bucket = ? AND dimension = ?", base.Add(time.Hour), domain.RollupDimensionTotal).First(&hourly).Error)
+	suite.Equal(int64(2), hourly.Clicks)
+	watermark, err = suite.rollupRepo.GetWatermark(suite.ctx)
+	suite.Require().NoError(err)
+	suite.True(watermark.Equal(base.Add(2 * time.Hour)))
+
+	// Clicks after the watermark leave it alone
+	suite.Require().NoError(suite.clickRepo.Create(suite.ctx, &domain.Click{
+		ShortURLID: suite.testURL.ID, IPAddress: "10.0.3.3", ClickedAt: base.Add(2 * time.Hour),
+	}))
+	watermark, err = suite.rollupRepo.GetWatermark(suite.ctx)
+	suite.Require().NoError(err)
+	suite.True(watermark.Equal(base.Add(2 * time.Hour)))
+}
+
+func (suite *RepositoryTestSuite) TestClickRollupRepository_GetNextClickTime() {
+	base := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	suite.createRollupClicks(base)
+
+	next, ok, err := suite.rollupRepo.GetNextClickTime(suite.ctx, base.Add(time.Hour), base.Add(72*time.Hour))
+	suite.NoError(err)
+	suite.True(ok)
+	suite.True(next.Equal(base.Add(70 * time.Minute)))
+
+	_, ok, err = suite.rollupRepo.GetNextClickTime(suite.ctx, base.Add(51*time.Hour), base.Add(72*time.Hour))
+	suite.NoError(err)
+	suite.False(ok)
+}
+
+// Reading through the rollups must give the same answers as reading raw clicks
+func (suite *RepositoryTestSuite) TestClickRepository_ReadsRollupsAndLiveTail() {
+	base := time.Now().UTC().Truncate(time.Hour).Add(-50 * time.Hour)
+	suite.createRollupClicks(base)
+
+	type snapshot struct {
+		total     int64
+		countries []domain.CountryStat
+		referers  []domain.RefererStat
+		stats     *domain.ClickStats
+		geo       *domain.GeoStats
+		timeline  *domain.TimelineStats
+		user      *domain.UserAnalytics
+	}
+	take := func() snapshot {
+		var snap snapshot
+		var err error
+		snap.total, err = suite.clickRepo.GetTotalClicks(suite.ctx, suite.testURL.ID)
+		suite.Require().NoError(err)
+		snap.countries, err = suite.clickRepo.GetTopCountries(suite.ctx, suite.testURL.ID, 10)
+		suite.Require().NoError(err)
+		snap.referers, err = suite.clickRepo.GetTopReferers(suite.ctx, suite.testURL.ID, 10)
+		suite.Require().NoError(err)
+		snap.stats, err = suite.clickRepo.GetClickStats(suite.ctx, suite.testURL.ID, "month")
+		suite.Require().NoError(err)
+		snap.stats.RecentClicks = nil
+		snap.geo, err = suite.clickRepo.GetGeoStats(suite.ctx, suite.testURL.ID)
+		suite.Require().NoError(err)
+		snap.timeline, err = suite.clickRepo.GetTimelineStats(suite.ctx, suite.testURL.ID, "week")
+		suite.Require().NoError(err)
+		snap.user, err = suite.clickRepo.GetUserStats(suite.ctx, suite.testUser.ID)
+		suite.Require().NoError(err)
+		return snap
+	}
+
+	// Without rollups everything is the live tail
+	raw := take()
+	suite.Equal(int64(5), raw.total)
+	suite.Equal([]domain.CountryStat{{Country: "GB", Count: 2}, {Country: "US", Count: 2}, {Country: "SE", Count: 1}}, raw.countries)
+	suite.Equal([]domain.RefererStat{{Referer: "google.com", Count: 2}, {Referer: "t.co", Count: 1}}, raw.referers)
+	suite.Equal(int64(5), raw.user.TotalClicks)
+	suite.Equal(int64(5), raw.stats.UniqueClicks)
+	hours, days := make(map[int]int64), make(map[string]int64)
+	for _, at := range []time.Time{base, base, base.Add(70 * time.Minute), base.Add(26 * time.Hour), base.Add(50 * time.Hour)} {
+		hours[at.Hour()]++
+		days[at.Format("2006-01-02")]++
+	}
+	suite.Equal(hours, raw.stats.ClicksByTime)
+	suite.Equal(days, raw.timeline.Data)
+
+	// Roll up everything but the last click, which stays in the tail
+	for _, hour := range []time.Duration{0, time.Hour, 26 * time.Hour} {
+		suite.Require().NoError(suite.rollupRepo.RollupHour(suite.ctx, base.Add(hour)))
+	}
+	suite.Require().NoError(suite.rollupRepo.SetWatermark(suite.ctx, base.Add(48*time.Hour)))
+
+	// Raw clicks are no longer consulted for the rolled up range
+	suite.db.Exec("UPDATE clicks SET country = 'FR' WHERE clicked_at < ?", base.Add(48*time.Hour))
+
+	rolled := take()
+	suite.Equal(raw, rolled)
+}
+
+func (suite *RepositoryTestSuite) TestAPIKeyRepository() {
+	key := &domain.APIKey{
+		UserID:  suite.testUser.ID,
+		Name:    "CI",
+		Prefix:  "usk_abcd1234",
+		KeyHash: "hash-1",
+		Scopes:  domain.APIScopes{domain.ScopeURLsRead, domain.ScopeURLsWrite},
+	}
+	suite.Require().NoError(suite.apiKeyRepo.Create(suite.ctx, key))
+
+	found, err := suite.apiKeyRepo.GetByHash(suite.ctx, "hash-1")
+	suite.Require().NoError(err)
+	suite.Equal(key.ID, found.ID)
+	suite.Equal(domain.APIScopes{domain.ScopeURLsRead, domain.ScopeURLsWrite}, found.Scopes)
+
+	_, err = suite.apiKeyRepo.GetByHash(suite.ctx, "missing")
+	suite.Equal(domain.ErrAPIKeyNotFound, err)
+
+	usedAt := time.Now().Truncate(time.Second)
+	suite.Require().NoError(suite.apiKeyRepo.TouchLastUsed(suite.ctx, key.ID, usedAt, "203.0.113.9"))
+	found, err = suite.apiKeyRepo.GetByID(suite.ctx, key.ID)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(found.LastUsedAt)
+	suite.True(usedAt.Equal(*found.LastUsedAt))
+	suite.Equal("203.0.113.9", found.LastUsedIP)
+
+	// Revoked keys drop out of the list
+	revoked := &domain.APIKey{UserID: suite.testUser.ID, Name: "old", Prefix: "usk_efgh5678", KeyHash: "hash-2", Scopes: domain.APIScopes{domain.ScopeAnalyticsRead}}
+	suite.Require().NoError(suite.apiKeyRepo.Create(suite.ctx, revoked))
+	revoked.RevokedAt = &usedAt
+	suite.Require().NoError(suite.apiKeyRepo.Update(suite.ctx, revoked))
+
+	keys, err := suite.apiKeyRepo.ListActiveByUser(suite.ctx, suite.testUser.ID)
+	suite.Require().NoError(err)
+	suite.Len(keys, 1)
+	suite.Equal("CI", keys[0].Name)
+}
+
+func (suite *RepositoryTestSuite) TestSessionRepository() {
+	now := time.Now()
+	session := &domain.Session{
+		UserID:     suite.testUser.ID,
+		FamilyID:   "family-1",
+		TokenHash:  "hash-1",
+		Device:     "Firefox on Linux",
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(time.Hour),
+	}
+	suite.Require().NoError(suite.sessionRepo.Create(suite.ctx, session))
+
+	found, err := suite.sessionRepo.GetByFamilyID(suite.ctx, "family-1")
+	suite.Require().NoError(err)
+	suite.Equal(session.ID, found.ID)
+
+	// Only the holder of the current hash can rotate
+	found.TokenHash = "hash-2"
+	found.IPAddress = "203.0.113.9"
+	rotated, err := suite.sessionRepo.Rotate(suite.ctx, found, "hash-1")
+	suite.Require().NoError(err)
+	suite.True(rotated)
+
+	found.TokenHash = "hash-3"
+	rotated, err = suite.sessionRepo.Rotate(suite.ctx, found, "hash-1")
+	suite.Require().NoError(err)
+	suite.False(rotated)
+
+	found, err = suite.sessionRepo.GetByID(suite.ctx, session.ID)
+	suite.Require().NoError(err)
+	suite.Equal("hash-2", found.TokenHash)
+	suite.Equal("203.0.113.9", found.IPAddress)
+
+	expired := &domain.Session{UserID: suite.testUser.ID, FamilyID: "family-2", TokenHash: "hash-4", LastSeenAt: now, ExpiresAt: now.Add(-time.Minute)}
+	suite.Require().NoError(suite.sessionRepo.Create(suite.ctx, expired))
+	other := &domain.Session{UserID: suite.testUser.ID, FamilyID: "family-3", TokenHash: "hash-5", LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}
+	suite.Require().NoError(suite.sessionRepo.Create(suite.ctx, other))
+
+	sessions, err := suite.sessionRepo.ListActiveByUser(suite.ctx, suite.testUser.ID, now)
+	suite.Require().NoError(err)
+	suite.Len(sessions, 2)
+
+	suite.Require().NoError(suite.sessionRepo.Revoke(suite.ctx, other.ID, domain.SessionRevokedByUser))
+	sessions, err = suite.sessionRepo.ListActiveByUser(suite.ctx, suite.testUser.ID, now)
+	suite.Require().NoError(err)
+	suite.Len(sessions, 1)
+
+	// Already revoked sessions keep their original reason
+	suite.Require().NoError(suite.sessionRepo.RevokeAllByUser(suite.ctx, suite.testUser.ID, domain.SessionRevokedAll))
+	sessions, err = suite.sessionRepo.ListActiveByUser(suite.ctx, suite.testUser.ID, now)
+	suite.Require().NoError(err)
+	suite.Empty(sessions)
+	found, err = suite.sessionRepo.GetByID(suite.ctx, other.ID)
+	suite.Require().NoError(err)
+	suite.Equal(domain.SessionRevokedByUser, found.RevokeReason)
+
+	_, err = suite.sessionRepo.GetByFamilyID(suite.ctx, "missing")
+	suite.Equal(domain.ErrSessionNotFound, err)
+}
+
+func (suite *RepositoryTestSuite) TestRecoveryCodeRepository() {
+	suite.Require().NoError(suite.recoveryRepo.ReplaceForUser(suite.ctx, suite.testUser.ID, []string{"hash-1", "hash-2"}))
+
+	used, err := suite.recoveryRepo.Consume(suite.ctx, suite.testUser.ID, "hash-1")
+	suite.Require().NoError(err)
+	suite.True(used)
+
+	// Each code works once
+	used, err = suite.recoveryRepo.Consume(suite.ctx, suite.testUser.ID, "hash-1")
+	suite.Require().NoError(err)
+	suite.False(used)
+
+	// Replacing the codes invalidates the old ones
+	suite.Require().NoError(suite.recoveryRepo.ReplaceForUser(suite.ctx, suite.testUser.ID, []string{"hash-3"}))
+	used, err = suite.recoveryRepo.Consume(suite.ctx, suite.testUser.ID, "hash-2")
+	suite.Require().NoError(err)
+	suite.False(used)
+
+	suite.Require().NoError(suite.recoveryRepo.DeleteByUser(suite.ctx, suite.testUser.ID))
+	used, err = suite.recoveryRepo.Consume(suite.ctx, suite.testUser.ID, "hash-3")
+	suite.Require().NoError(err)
+	suite.False(used)
+}
+
+func (suite *RepositoryTestSuite) TestUserIdentityRepository() {
+	identity := &domain.UserIdentity{
+		UserID:  suite.testUser.ID,
+		Issuer:  "https://idp.example.com",
+		Subject: "user-123",
+		Email:   suite.testUser.Email,
+	}
+	suite.Require().NoError(suite.identityRepo.Create(suite.ctx, identity))
+
+	found, err := suite.identityRepo.GetByIssuerSubject(suite.ctx, "https://idp.example.com", "user-123")
+	suite.Require().NoError(err)
+	suite.Equal(suite.testUser.ID, found.UserID)
+
+	// The same subject at another issuer is a different account
+	_, err = suite.identityRepo.GetByIssuerSubject(suite.ctx, "https://other.example.com", "user-123")
+	suite.Equal(domain.ErrIdentityNotFound, err)
+
+	// An issuer and subject can only be linked once
+	err = suite.identityRepo.Create(suite.ctx, &domain.UserIdentity{
+		UserID:  suite.testUser.ID,
+		Issuer:  "https://idp.example.com",
+		Subject: "user-123",
+	})
+	suite.Error(err)
+
+	now := time.Now()
+	found.Email = "renamed@example.com"
+	found.LastLoginAt = &now
+	suite.Require().NoError(suite.identityRepo.Update(suite.ctx, found))
+
+	found, err = suite.identityRepo.GetByIssuerSubject(suite.ctx, "https://idp.example.com", "user-123")
+	suite.Require().NoError(err)
+	suite.Equal("renamed@example.com", found.Email)
+	suite.NotNil(found.LastLoginAt)
+}
+
+func (suite *RepositoryTestSuite) TestAuditRepository() {
+	actorID := suite.testUser.ID
+	now := time.Now()
+	events := []*domain.AuditEvent{
+		{ActorID: &actorID, Action: domain.AuditURLCreated, TargetType: domain.AuditTargetURL, TargetID: suite.testURL.ID, CreatedAt: now.Add(-2 * time.Hour)},
+		{
+			ActorID: &actorID, Action: domain.AuditURLUpdated, TargetType: domain.AuditTargetURL, TargetID: suite.testURL.ID,
+			Changes:   domain.AuditChanges{"title": {From: "Old", To: "New"}},
+			IPAddress: "203.0.113.9", RequestID: "req-1", CreatedAt: now.Add(-time.Hour),
+		},
+		{Action: domain.AuditUserPasswordReset, TargetType: domain.AuditTargetUser, TargetID: suite.testUser.ID, CreatedAt: now},
+	}
+	for _, event := range events {
+		suite.Require().NoError(suite.auditRepo.Create(suite.ctx, event))
+	}
+
+	// Newest first
+	listed, total, err := suite.auditRepo.List(suite.ctx, domain.AuditFilter{}, 0, 10)
+	suite.Require().NoError(err)
+	suite.Equal(int64(3), total)
+	suite.Require().Len(listed, 3)
+	suite.Equal(domain.AuditUserPasswordReset, listed[0].Action)
+	suite.Nil(listed[0].ActorID)
+
+	// The diff survives the round trip
+	suite.Equal("New", listed[1].Changes["title"].To)
+	suite.Equal("req-1", listed[1].RequestID)
+
+	targetID := suite.testURL.ID
+	listed, total, err = suite.auditRepo.List(suite.ctx, domain.AuditFilter{
+		ActorID: &actorID, TargetType: domain.AuditTargetURL, TargetID: &targetID,
+	}, 0, 10)
+	suite.Require().NoError(err)
+	suite.Equal(int64(2), total)
+	suite.Len(listed, 2)
+
+	listed, _, err = suite.auditRepo.List(suite.ctx, domain.AuditFilter{Action: domain.AuditURLUpdated}, 0, 10)
+	suite.Require().NoError(err)
+	suite.Len(listed, 1)
+
+	from := now.Add(-90 * time.Minute)
+	to := now.Add(-30 * time.Minute)
+	listed, _, err = suite.auditRepo.List(suite.ctx, domain.AuditFilter{From: &from, To: &to}, 0, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(listed, 1)
+	suite.Equal(domain.AuditURLUpdated, listed[0].Action)
+
+	// Pagination
+	listed, total, err = suite.auditRepo.List(suite.ctx, domain.AuditFilter{}, 2, 2)
+	suite.Require().NoError(err)
+	suite.Equal(int64(3), total)
+	suite.Len(listed, 1)
+}
+
+func (suite *RepositoryTestSuite) TestWorkspaceRepository() {
+	workspace := &domain.Workspace{Name: "Marketing", CreatedBy: suite.testUser.ID}
+	owner := &domain.WorkspaceMember{UserID: suite.testUser.ID, Role: domain.WorkspaceRoleOwner}
+	suite.Require().NoError(suite.workspaceRepo.Create(suite.ctx, workspace, owner))
+	suite.Equal(workspace.ID, owner.WorkspaceID)
+
+	member := &domain.User{Email: "member@example.com", Password: "hashedpassword"}
+	suite.Require().NoError(suite.userRepo.Create(suite.ctx, member))
+	suite.Require().NoError(suite.workspaceRepo.AddMember(suite.ctx, &domain.WorkspaceMember{
+		WorkspaceID: workspace.ID, UserID: member.ID, Role: domain.WorkspaceRoleViewer,
+	}))
+	err := suite.workspaceRepo.AddMember(suite.ctx, &domain.WorkspaceMember{
+		WorkspaceID: workspace.ID, UserID: member.ID, Role: domain.WorkspaceRoleEditor,
+	})
+	suite.Equal(domain.ErrAlreadyWorkspaceMember, err)
+
+	memberships, err := suite.workspaceRepo.ListByUser(suite.ctx, member.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(memberships, 1)
+	suite.Equal("Marketing", memberships[0].Workspace.Name)
+
+	members, err := suite.workspaceRepo.ListMembers(suite.ctx, workspace.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(members, 2)
+	suite.Equal("member@example.com", members[1].User.Email)
+
+	owners, err := suite.workspaceRepo.CountOwners(suite.ctx, workspace.ID)
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), owners)
+
+	suite.Require().NoError(suite.workspaceRepo.RemoveMember(suite.ctx, workspace.ID, member.ID))
+	_, err = suite.workspaceRepo.GetMember(suite.ctx, workspace.ID, member.ID)
+	suite.Equal(domain.ErrNotWorkspaceMember, err)
+
+	// Workspace links are kept out of the creator's personal list
+	workspaceURL := &domain.ShortURL{
+		ShortCode:   "team123",
+		OriginalURL: "https://example.com/campaign",
+		UserID:      suite.testUser.ID,
+		WorkspaceID: &workspace.ID,
+		IsActive:    true,
+	}
+	suite.Require().NoError(suite.urlRepo.Create(suite.ctx, workspaceURL))
+
+	personal, total, err := suite.urlRepo.GetByUserID(suite.ctx, suite.testUser.ID, 0, 10)
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), total)
+	suite.Equal(suite.testURL.ID, personal[0].ID)
+
+	shared, total, err := suite.urlRepo.GetByWorkspaceID(suite.ctx, workspace.ID, 0, 10)
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), total)
+	suite.Equal(workspaceURL.ID, shared[0].ID)
+}
+
+func (suite *RepositoryTestSuite) TestWorkspaceRepository_Invitations() {
+	workspace := &domain.Workspace{Name: "Marketing", CreatedBy: suite.testUser.ID}
+	owner := &domain.WorkspaceMember{UserID: suite.testUser.ID, Role: domain.WorkspaceRoleOwner}
+	suite.Require().NoError(suite.workspaceRepo.Create(suite.ctx, workspace, owner))
+
+	now := time.Now()
+	pending := &domain.WorkspaceInvitation{
+		WorkspaceID: workspace.ID, Email: "new@example.com", Role: domain.WorkspaceRoleEditor,
+		TokenHash: "hash-pending", InvitedBy: suite.testUser.ID, ExpiresAt: now.Add(time.Hour),
+	}
+	expired := &domain.WorkspaceInvitation{
+		WorkspaceID: workspace.ID, Email: "old@example.com", Role: domain.WorkspaceRoleViewer,
+		TokenHash: "hash-expired", InvitedBy: suite.testUser.ID, ExpiresAt: now.Add(-time.Hour),
+	}
+	suite.Require().NoError(suite.workspaceRepo.CreateInvitation(suite.ctx, pending))
+	suite.Require().NoError(suite.workspaceRepo.CreateInvitation(suite.ctx, expired))
+
+	invitations, err := suite.workspaceRepo.ListPendingInvitations(suite.ctx, workspace.ID, now)
+	suite.Require().NoError(err)
+	suite.Require().Len(invitations, 1)
+	suite.Equal(pending.ID, invitations[0].ID)
+
+	found, err := suite.workspaceRepo.GetInvitationByTokenHash(suite.ctx, "hash-pending")
+	suite.Require().NoError(err)
+	suite.Equal("Marketing", found.Workspace.Name)
+
+	invitee := &domain.User{Email: "new@example.com", Password: "hashedpassword"}
+	suite.Require().NoError(suite.userRepo.Create(suite.ctx, invitee))
+	member := &domain.WorkspaceMember{WorkspaceID: workspace.ID, UserID: invitee.ID, Role: pending.Role}
+	suite.Require().NoError(suite.workspaceRepo.AcceptInvitation(suite.ctx, found, member))
+	suite.NotNil(found.AcceptedAt)
+
+	// An invitation can only be used once
+	again := &domain.WorkspaceMember{WorkspaceID: workspace.ID, UserID: invitee.ID, Role: pending.Role}
+	suite.Equal(domain.ErrInvitationNotFound, suite.workspaceRepo.AcceptInvitation(suite.ctx, found, again))
+
+	joined, err := suite.workspaceRepo.GetMember(suite.ctx, workspace.ID, invitee.ID)
+	suite.Require().NoError(err)
+	suite.Equal(domain.WorkspaceRoleEditor, joined.Role)
+
+	suite.Require().NoError(suite.workspaceRepo.DeleteInvitation(suite.ctx, expired.ID))
+	_, err = suite.workspaceRepo.GetInvitationByID(suite.ctx, expired.ID)
+	suite.Equal(domain.ErrInvitationNotFound, err)
+}
+
+func (suite *RepositoryTestSuite) TestQRBatchRepository() {
+	batch := &domain.QRBatch{
+		UserID:  suite.testUser.ID,
+		Items:   domain.QRBatchItems{{ShortCode: "test123", URL: "http://localhost:8080/test123"}},
+		Options: &domain.QROptions{Size: 512, Format: "svg"},
+		Status:  domain.QRBatchPending,
+	}
+	suite.Require().NoError(suite.qrBatchRepo.Create(suite.ctx, batch))
+
+	// Items and options survive the round trip
+	stored, err := suite.qrBatchRepo.GetByID(suite.ctx, batch.ID)
+	suite.Require().NoError(err)
+	suite.Equal(batch.Items, stored.Items)
+	suite.Equal("svg", stored.Options.Format)
+	suite.Nil(stored.Customization)
+
+	claimed, err := suite.qrBatchRepo.ClaimNext(suite.ctx, time.Now().Add(-time.Minute))
+	suite.Require().NoError(err)
+	suite.Require().NotNil(claimed)
+	suite.Equal(batch.ID, claimed.ID)
+	suite.Equal(domain.QRBatchProcessing, claimed.Status)
+	suite.Equal(1, claimed.Attempts)
+
+	// Nothing else is waiting until the claim goes stale
+	next, err := suite.qrBatchRepo.ClaimNext(suite.ctx, time.Now().Add(-time.Minute))
+	suite.Require().NoError(err)
+	suite.Nil(next)
+	next, err = suite.qrBatchRepo.ClaimNext(suite.ctx, time.Now().Add(time.Minute))
+	suite.Require().NoError(err)
+	suite.Require().NotNil(next)
+	suite.Equal(2, next.Attempts)
+
+	suite.Require().NoError(suite.qrBatchRepo.UpdateProgress(suite.ctx, batch.ID, 40))
+	stored, err = suite.qrBatchRepo.GetByID(suite.ctx, batch.ID)
+	suite.Require().NoError(err)
+	suite.Equal(40, stored.Progress)
+
+	// The first claim went stale, so its result is dropped
+	now := time.Now()
+	completed, err := suite.qrBatchRepo.Complete(suite.ctx, batch.ID, claimed.Attempts, []byte("stale archive"), 0, now, now.Add(time.Hour))
+	suite.Require().NoError(err)
+	suite.False(completed)
+	stored, err = suite.qrBatchRepo.GetByID(suite.ctx, batch.ID)
+	suite.Require().NoError(err)
+	suite.Equal(domain.QRBatchProcessing, stored.Status)
+
+	completed, err = suite.qrBatchRepo.Complete(suite.ctx, batch.ID, next.Attempts, []byte("PK archive"), 1, now, now.Add(time.Hour))
+	suite.Require().NoError(err)
+	suite.True(completed)
+	stored, err = suite.qrBatchRepo.GetByID(suite.ctx, batch.ID)
+	suite.Require().NoError(err)
+	suite.Equal(domain.QRBatchCompleted, stored.Status)
+	suite.Equal(100, stored.Progress)
+	suite.Equal(1, stored.Failures)
+	suite.Nil(stored.Archive, "status reads leave the archive out")
+	archive, err := suite.qrBatchRepo.GetArchive(suite.ctx, batch.ID)
+	suite.Require().NoError(err)
+	suite.Equal([]byte("PK archive"), archive)
+
+	expired, err := suite.qrBatchRepo.ExpireArchives(suite.ctx, now.Add(30*time.Minute))
+	suite.Require().NoError(err)
+	suite.Equal(int64(0), expired)
+	expired, err = suite.qrBatchRepo.ExpireArchives(suite.ctx, now.Add(2*time.Hour))
+	suite.Require().NoError(err)
+	suite.Equal(int64(1), expired)
+	stored, err = suite.qrBatchRepo.GetByID(suite.ctx, batch.ID)
+	suite.Require().NoError(err)
+	suite.Equal(domain.QRBatchExpired, stored.Status)
+	archive, err = suite.qrBatchRepo.GetArchive(suite.ctx, batch.ID)
+	suite.Require().NoError(err)
+	suite.Empty(archive)
+
+	_, err = suite.qrBatchRepo.GetByID(suite.ctx, batch.ID+1)
+	suite.Equal(domain.ErrQRBatchNotFound, err)
+}
+
+func (suite *RepositoryTestSuite) TestQRBatchRepository_Fail() {
+	batch := &domain.QRBatch{UserID: suite.testUser.ID, Items: domain.QRBatchItems{{ShortCode: "test123"}}, Status: domain.QRBatchPending}
+	suite.Require().NoError(suite.qrBatchRepo.Create(suite.ctx, batch))
+
+	suite.Require().NoError(suite.qrBatchRepo.Fail(suite.ctx, batch.ID, "none of the 1 QR codes could be rendered", time.Now()))
+	stored, err := suite.qrBatchRepo.GetByID(suite.ctx, batch.ID)
+	suite.Require().NoError(err)
+	suite.Equal(domain.QRBatchFailed, stored.Status)
+	suite.Equal("none of the 1 QR codes could be rendered", stored.Error)
+	suite.NotNil(stored.CompletedAt)
+
+	// Failed batches are not picked up again
+	next, err := suite.qrBatchRepo.ClaimNext(suite.ctx, time.Now().Add(time.Hour))
+	suite.Require().NoError(err)
+	suite.Nil(next)
+}
+
 func TestRepositoryTestSuite(t *testing.T) {
 	suite.Run(t, new(RepositoryTestSuite))
 }
@@ -404,4 +1353,5 @@ func TestIsDuplicateKeyError(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
@@ -0,0 +1,483 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+const (
+	rollupStateID         = 1
+	rollupInsertBatchSize = 1000
+
+	hourlyRollupTable = "click_rollups_hourly"
+	dailyRollupTable  = "click_rollups_daily"
+)
+
+// Click columns behind each rolled up dimension. Total and hour are derived from
+// the row count and clicked_at instead.
+var rollupColumns = map[string]string{
+	domain.RollupDimensionCountry:  "country",
+	domain.RollupDimensionRegion:   "region",
+	domain.RollupDimensionCity:     "city",
+	domain.RollupDimensionDevice:   "device",
+	domain.RollupDimensionBrowser:  "browser",
+	domain.RollupDimensionReferrer: "referer",
+}
+
+type clickRollupRepository struct {
+	db *gorm.DB
+}
+
+func NewClickRollupRepository(db *gorm.DB) ports.ClickRollupRepository {
+	return &clickRollupRepository{
+		db: db,
+	}
+}
+
+func (r *clickRollupRepository) GetWatermark(ctx context.Context) (time.Time, error) {
+	return rollupWatermark(r.db.WithContext(ctx))
+}
+
+func (r *clickRollupRepository) SetWatermark(ctx context.Context, until time.Time) error {
+	return setRollupWatermark(r.db.WithContext(ctx), until)
+}
+
+func (r *clickRollupRepository) GetNextClickTime(ctx context.Context, from, to time.Time) (time.Time, bool, error) {
+	var clicks []domain.Click
+	if err := r.db.WithContext(ctx).
+		Select("clicked_at").
+		Where("clicked_at >= ? AND clicked_at < ?", from, to).
+		Order("clicked_at ASC").
+		Limit(1).
+		Find(&clicks).Error; err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to find next click: %w", err)
+	}
+	if len(clicks) == 0 {
+		return time.Time{}, false, nil
+	}
+	return clicks[0].ClickedAt.UTC(), true, nil
+}
+
+func (r *clickRollupRepository) RollupHour(ctx context.Context, hour time.Time) error {
+	hour = hour.UTC().Truncate(time.Hour)
+	end := hour.Add(time.Hour)
+	day := startOfDay(hour)
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		rows, err := hourlyRollupRows(tx, hour, end)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("bucket = ?", hour).Delete(&domain.ClickRollupHourly{}).Error; err != nil {
+			return fmt.Errorf("failed to clear hourly rollups: %w", err)
+		}
+		if len(rows) > 0 {
+			if err := tx.CreateInBatches(rows, rollupInsertBatchSize).Error; err != nil {
+				return fmt.Errorf("failed to write hourly rollups: %w", err)
+			}
+		}
+
+		// Rebuild the day from its hours so late hours and re-runs stay consistent
+		if err := tx.Where("bucket = ?", day).Delete(&domain.ClickRollupDaily{}).Error; err != nil {
+			return fmt.Errorf("failed to clear daily rollups: %w", err)
+		}
+		if err := tx.Exec(`
+			INSERT INTO click_rollups_daily (short_url_id, bucket, dimension, value, clicks)
+			SELECT short_url_id, ?, dimension, value, SUM(clicks)
+			FROM click_rollups_hourly
+			WHERE bucket >= ? AND bucket < ?
+			GROUP BY short_url_id, dimension, value`,
+			day, day, day.AddDate(0, 0, 1)).Error; err != nil {
+			return fmt.Errorf("failed to write daily rollups: %w", err)
+		}
+
+		watermark, err := rollupWatermark(tx)
+		if err != nil {
+			return err
+		}
+		if !end.After(watermark) {
+			return nil
+		}
+
+		// A late click may have pulled the watermark back behind this hour; leave it
+		// there so the hours in between are rebuilt first
+		if watermark.Before(hour) {
+			var behind []domain.Click
+			if err := tx.Select("id").
+				Where("clicked_at >= ? AND clicked_at < ?", watermark, hour).
+				Limit(1).
+				Find(&behind).Error; err != nil {
+				return fmt.Errorf("failed to check for late clicks: %w", err)
+			}
+			if len(behind) > 0 {
+				return nil
+			}
+		}
+		return setRollupWatermark(tx, end)
+	})
+}
+
+// hourlyRollupRows counts the clicks in [hour, end) for every URL and dimension
+func hourlyRollupRows(tx *gorm.DB, hour, end time.Time) ([]*domain.ClickRollupHourly, error) {
+	type urlValueCount struct {
+		ShortURLID uint
+		Value      string
+		Count      int64
+	}
+
+	var totals []urlValueCount
+	if err := tx.Model(&domain.Click{}).
+		Select("short_url_id, COUNT(*) AS count").
+		Where("clicked_at >= ? AND clicked_at < ?", hour, end).
+		Group("short_url_id").
+		Scan(&totals).Error; err != nil {
+		return nil, fmt.Errorf("failed to count clicks: %w", err)
+	}
+
+	var rows []*domain.ClickRollupHourly
+	add := func(shortURLID uint, dimension, value string, count int64) {
+		rows = append(rows, &domain.ClickRollupHourly{
+			ShortURLID: shortURLID,
+			Bucket:     hour,
+			Dimension:  dimension,
+			Value:      value,
+			Clicks:     count,
+		})
+	}
+
+	hourOfDay := strconv.Itoa(hour.Hour())
+	for _, total := range totals {
+		add(total.ShortURLID, domain.RollupDimensionTotal, "", total.Count)
+		add(total.ShortURLID, domain.RollupDimensionHour, hourOfDay, total.Count)
+	}
+
+	for _, dimension := range sortedRollupDimensions() {
+		column := rollupColumns[dimension]
+
+		var counts []urlValueCount
+		if err := tx.Model(&domain.Click{}).
+			Select(fmt.Sprintf("short_url_id, %s AS value, COUNT(*) AS count", column)).
+			Where(fmt.Sprintf("clicked_at >= ? AND clicked_at < ? AND %s != ''", column), hour, end).
+			Group("short_url_id, " + column).
+			Scan(&counts).Error; err != nil {
+			return nil, fmt.Errorf("failed to count clicks by %s: %w", dimension, err)
+		}
+
+		if dimension != domain.RollupDimensionReferrer {
+			for _, c := range counts {
+				add(c.ShortURLID, dimension, c.Value, c.Count)
+			}
+			continue
+		}
+
+		// Several referrer URLs share a domain, so merge before writing
+		type urlDomain struct {
+			shortURLID uint
+			domain     string
+		}
+		merged := make(map[urlDomain]int64)
+		var order []urlDomain
+		for _, c := range counts {
+			key := urlDomain{c.ShortURLID, referrerDomain(c.Value)}
+			if key.domain == "" {
+				continue
+			}
+			if _, ok := merged[key]; !ok {
+				order = append(order, key)
+			}
+			merged[key] += c.Count
+		}
+		for _, key := range order {
+			add(key.shortURLID, dimension, key.domain, merged[key])
+		}
+	}
+
+	return rows, nil
+}
+
+func sortedRollupDimensions() []string {
+	dimensions := make([]string, 0, len(rollupColumns))
+	for dimension := range rollupColumns {
+		dimensions = append(dimensions, dimension)
+	}
+	sort.Strings(dimensions)
+	return dimensions
+}
+
+func rollupWatermark(db *gorm.DB) (time.Time, error) {
+	var states []domain.ClickRollupState
+	if err := db.Where("id = ?", rollupStateID).Limit(1).Find(&states).Error; err != nil {
+		return time.Time{}, fmt.Errorf("failed to get rollup watermark: %w", err)
+	}
+	if len(states) == 0 {
+		return time.Time{}, nil
+	}
+	return states[0].RolledUpUntil.UTC(), nil
+}
+
+func setRollupWatermark(db *gorm.DB, until time.Time) error {
+	state := &domain.ClickRollupState{ID: rollupStateID, RolledUpUntil: until.UTC()}
+	if err := db.Save(state).Error; err != nil {
+		return fmt.Errorf("failed to set rollup watermark: %w", err)
+	}
+	return nil
+}
+
+// referrerDomain reduces a referrer URL to its host, without a leading "www."
+func referrerDomain(referer string) string {
+	u, err := url.Parse(strings.TrimSpace(referer))
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if len(host) > 255 {
+		host = host[:255]
+	}
+	return host
+}
+
+// rollupScope restricts rollup and raw click queries to one URL or one user's URLs
+type rollupScope struct {
+	clause string
+	arg    interface{}
+}
+
+func urlRollupScope(shortURLID uint) rollupScope {
+	return rollupScope{clause: "short_url_id = ?", arg: shortURLID}
+}
+
+func userRollupScope(userID uint) rollupScope {
+	return rollupScope{clause: "short_url_id IN (SELECT id FROM short_urls WHERE user_id = ?)", arg: userID}
+}
+
+type rollupSegment struct {
+	table    string
+	from, to time.Time
+}
+
+// rollupSegments covers [from, watermark) with daily rollups for whole UTC days and
+// hourly rollups for the partial days at either end. from is rounded down to the
+// hour, the rollups' granularity.
+func rollupSegments(from, watermark time.Time, hourlyOnly bool) []rollupSegment {
+	from = from.UTC().Truncate(time.Hour)
+	if !from.Before(watermark) {
+		return nil
+	}
+
+	firstDay := startOfDay(from)
+	if firstDay.Before(from) {
+		firstDay = firstDay.AddDate(0, 0, 1)
+	}
+	lastDay := startOfDay(watermark)
+
+	if hourlyOnly || !firstDay.Before(lastDay) {
+		return []rollupSegment{{hourlyRollupTable, from, watermark}}
+	}
+
+	var segments []rollupSegment
+	if from.Before(firstDay) {
+		segments = append(segments, rollupSegment{hourlyRollupTable, from, firstDay})
+	}
+	segments = append(segments, rollupSegment{dailyRollupTable, firstDay, lastDay})
+	if lastDay.Before(watermark) {
+		segments = append(segments, rollupSegment{hourlyRollupTable, lastDay, watermark})
+	}
+	return segments
+}
+
+// countByDimension sums clicks since from per value of a dimension: rollups up to the
+// watermark, raw clicks after it. Pass the zero time for all time.
+func countByDimension(ctx context.Context, db *gorm.DB, scope rollupScope, dimension string, from, watermark time.Time) (map[string]int64, error) {
+	counts := make(map[string]int64)
+
+	for _, segment := range rollupSegments(from, watermark, false) {
+		var rows []struct {
+			Value string
+			Count int64
+		}
+		if err := db.WithContext(ctx).
+			Table(segment.table).
+			Select("value, SUM(clicks) AS count").
+			Where(scope.clause, scope.arg).
+			Where("dimension = ? AND bucket >= ? AND bucket < ?", dimension, segment.from, segment.to).
+			Group("value").
+			Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to read %s rollups: %w", dimension, err)
+		}
+		for _, row := range rows {
+			counts[row.Value] += row.Count
+		}
+	}
+
+	// The live tail since the watermark comes from raw clicks
+	tailFrom := from
+	if watermark.After(tailFrom) {
+		tailFrom = watermark
+	}
+	tail := db.WithContext(ctx).
+		Model(&domain.Click{}).
+		Where(scope.clause, scope.arg).
+		Where("clicked_at >= ?", tailFrom)
+
+	switch dimension {
+	case domain.RollupDimensionTotal:
+		var count int64
+		if err := tail.Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to count clicks: %w", err)
+		}
+		if count > 0 {
+			counts[""] += count
+		}
+
+	case domain.RollupDimensionHour:
+		hours, err := tailHourCounts(ctx, db, scope, tailFrom)
+		if err != nil {
+			return nil, err
+		}
+		for hour, count := range hours {
+			counts[strconv.Itoa(hour.Hour())] += count
+		}
+
+	default:
+		column, ok := rollupColumns[dimension]
+		if !ok {
+			return nil, fmt.Errorf("unknown rollup dimension %q", dimension)
+		}
+
+		var rows []struct {
+			Value string
+			Count int64
+		}
+		if err := tail.
+			Select(fmt.Sprintf("%s AS value, COUNT(*) AS count", column)).
+			Where(column + " != ''").
+			Group(column).
+			Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to count clicks by %s: %w", dimension, err)
+		}
+		for _, row := range rows {
+			value := row.Value
+			if dimension == domain.RollupDimensionReferrer {
+				if value = referrerDomain(value); value == "" {
+					continue
+				}
+			}
+			counts[value] += row.Count
+		}
+	}
+
+	return counts, nil
+}
+
+// clickSeries counts clicks since from, keyed by key(bucket), from total rollups up to
+// the watermark and raw clicks after it. hourlyOnly keeps hour resolution for keys
+// finer than a day.
+func clickSeries(ctx context.Context, db *gorm.DB, scope rollupScope, from, watermark time.Time, hourlyOnly bool, key func(time.Time) string) (map[string]int64, error) {
+	series := make(map[string]int64)
+
+	for _, segment := range rollupSegments(from, watermark, hourlyOnly) {
+		var rows []struct {
+			Bucket time.Time
+			Count  int64
+		}
+		if err := db.WithContext(ctx).
+			Table(segment.table).
+			Select("bucket, SUM(clicks) AS count").
+			Where(scope.clause, scope.arg).
+			Where("dimension = ? AND bucket >= ? AND bucket < ?", domain.RollupDimensionTotal, segment.from, segment.to).
+			Group("bucket").
+			Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to read click rollups: %w", err)
+		}
+		for _, row := range rows {
+			series[key(row.Bucket.UTC())] += row.Count
+		}
+	}
+
+	tailFrom := from
+	if watermark.After(tailFrom) {
+		tailFrom = watermark
+	}
+	hours, err := tailHourCounts(ctx, db, scope, tailFrom)
+	if err != nil {
+		return nil, err
+	}
+	for hour, count := range hours {
+		series[key(hour)] += count
+	}
+
+	return series, nil
+}
+
+// tailHourCounts counts raw clicks since from per UTC hour. Every key used for
+// series and hour-of-day counts is at least an hour wide, so the database sends
+// back one row per hour rather than one per click.
+func tailHourCounts(ctx context.Context, db *gorm.DB, scope rollupScope, from time.Time) (map[time.Time]int64, error) {
+	var rows []struct {
+		Bucket int64
+		Count  int64
+	}
+	if err := db.WithContext(ctx).
+		Model(&domain.Click{}).
+		Select(hourBucketExpr(db)+" AS bucket, COUNT(*) AS count").
+		Where(scope.clause, scope.arg).
+		Where("clicked_at >= ?", from).
+		Group("bucket").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to count clicks by hour: %w", err)
+	}
+
+	counts := make(map[time.Time]int64, len(rows))
+	for _, row := range rows {
+		counts[time.Unix(row.Bucket, 0).UTC()] += row.Count
+	}
+	return counts, nil
+}
+
+// hourBucketExpr is the Unix time of the start of a click's hour, in the SQL of
+// the database in use (SQLite in tests)
+func hourBucketExpr(db *gorm.DB) string {
+	if db.Dialector.Name() == "sqlite" {
+		return "CAST(strftime('%s', clicked_at) AS INTEGER) / 3600 * 3600"
+	}
+	return "CAST(FLOOR(EXTRACT(EPOCH FROM clicked_at) / 3600) * 3600 AS BIGINT)"
+}
+
+// valueCount is a dimension value and its clicks, for ranking
+type valueCount struct {
+	value string
+	count int64
+}
+
+// topValues ranks counts by clicks, then value, and keeps at most limit entries
+func topValues(counts map[string]int64, limit int) []valueCount {
+	ranked := make([]valueCount, 0, len(counts))
+	for value, count := range counts {
+		ranked = append(ranked, valueCount{value, count})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].count != ranked[j].count {
+			return ranked[i].count > ranked[j].count
+		}
+		return ranked[i].value < ranked[j].value
+	})
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) ports.SessionRepository {
+	return &sessionRepository{
+		db: db,
+	}
+}
+
+func (r *sessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	if err := r.db.WithContext(ctx).Create(session).Error; err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) GetByID(ctx context.Context, id uint) (*domain.Session, error) {
+	var session domain.Session
+	if err := r.db.WithContext(ctx).First(&session, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, domain.ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to get session by id: %w", err)
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) GetByFamilyID(ctx context.Context, familyID string) (*domain.Session, error) {
+	var session domain.Session
+	if err := r.db.WithContext(ctx).Where("family_id = ?", familyID).First(&session).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, domain.ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to get session by family: %w", err)
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) ListActiveByUser(ctx context.Context, userID uint, now time.Time) ([]*domain.Session, error) {
+	var sessions []*domain.Session
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_seen_at DESC, id DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+func (r *sessionRepository) Rotate(ctx context.Context, session *domain.Session, previousHash string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&domain.Session{}).
+		Where("id = ? AND token_hash = ? AND revoked_at IS NULL", session.ID, previousHash).
+		Updates(map[string]interface{}{
+			"token_hash":   session.TokenHash,
+			"device":       session.Device,
+			"user_agent":   session.UserAgent,
+			"ip_address":   session.IPAddress,
+			"last_seen_at": session.LastSeenAt,
+			"expires_at":   session.ExpiresAt,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to rotate session: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *sessionRepository) Revoke(ctx context.Context, id uint, reason string) error {
+	if err := r.db.WithContext(ctx).
+		Model(&domain.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoke_reason": reason}).Error; err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) RevokeAllByUser(ctx context.Context, userID uint, reason string) error {
+	if err := r.db.WithContext(ctx).
+		Model(&domain.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoke_reason": reason}).Error; err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
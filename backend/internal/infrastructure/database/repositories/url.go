@@ -3,9 +3,12 @@ package repositories
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"url-shortener/internal/core/domain"
 	"url-shortener/internal/core/ports"
 )
@@ -34,6 +37,8 @@ func (r *urlRepository) GetByID(ctx context.Context, id uint) (*domain.ShortURL,
 	var url domain.ShortURL
 	if err := r.db.WithContext(ctx).
 		Preload("User").
+		Preload("Variants", orderVariants).
+		Preload("RedirectRules", orderRedirectRules).
 		First(&url, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, domain.ErrShortURLNotFound
@@ -47,6 +52,8 @@ func (r *urlRepository) GetByShortCode(ctx context.Context, shortCode string) (*
 	var url domain.ShortURL
 	if err := r.db.WithContext(ctx).
 		Preload("User").
+		Preload("Variants", orderVariants).
+		Preload("RedirectRules", orderRedirectRules).
 		Where("short_code = ?", shortCode).
 		First(&url).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -58,7 +65,8 @@ func (r *urlRepository) GetByShortCode(ctx context.Context, shortCode string) (*
 }
 
 func (r *urlRepository) Update(ctx context.Context, url *domain.ShortURL) error {
-	if err := r.db.WithContext(ctx).Save(url).Error; err != nil {
+	// Preloaded users and variants are saved through their own repositories
+	if err := r.db.WithContext(ctx).Omit(clause.Associations).Save(url).Error; err != nil {
 		if isDuplicateKeyError(err) {
 			return domain.ErrShortCodeExists
 		}
@@ -96,17 +104,17 @@ func (r *urlRepository) GetByUserID(ctx context.Context, userID uint, offset, li
 	// Get total count
 	if err := r.db.WithContext(ctx).
 		Model(&domain.ShortURL{}).
-		Where("user_id = ?", userID).
+		Where("user_id = ? AND workspace_id IS NULL", userID).
 		Count(&total).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count user URLs: %w", err)
 	}
 
 	// Get URLs with pagination
 	if err := r.db.WithContext(ctx).
-		Where("user_id = ?", userID).
+		Where("user_id = ? AND workspace_id IS NULL", userID).
 		Offset(offset).
 		Limit(limit).
-		Order("created_at DESC").
+		Order("created_at DESC, id DESC").
 		Find(&urls).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to list user URLs: %w", err)
 	}
@@ -114,10 +122,35 @@ func (r *urlRepository) GetByUserID(ctx context.Context, userID uint, offset, li
 	return urls, total, nil
 }
 
+func (r *urlRepository) GetByWorkspaceID(ctx context.Context, workspaceID uint, offset, limit int) ([]*domain.ShortURL, int64, error) {
+	var urls []*domain.ShortURL
+	var total int64
+
+	if err := r.db.WithContext(ctx).
+		Model(&domain.ShortURL{}).
+		Where("workspace_id = ?", workspaceID).
+		Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count workspace URLs: %w", err)
+	}
+
+	if err := r.db.WithContext(ctx).
+		Where("workspace_id = ?", workspaceID).
+		Offset(offset).
+		Limit(limit).
+		Order("created_at DESC, id DESC").
+		Find(&urls).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list workspace URLs: %w", err)
+	}
+
+	return urls, total, nil
+}
+
 func (r *urlRepository) GetActiveByShortCode(ctx context.Context, shortCode string) (*domain.ShortURL, error) {
 	var url domain.ShortURL
 	now := time.Now()
 	if err := r.db.WithContext(ctx).
+		Preload("Variants", orderVariants).
+		Preload("RedirectRules", orderRedirectRules).
 		Where("short_code = ? AND is_active = ? AND (expires_at IS NULL OR expires_at > ?)", shortCode, true, now).
 		First(&url).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -128,6 +161,132 @@ func (r *urlRepository) GetActiveByShortCode(ctx context.Context, shortCode stri
 	return &url, nil
 }
 
+func (r *urlRepository) ReplaceVariants(ctx context.Context, shortURLID uint, variants []*domain.URLVariant) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing []*domain.URLVariant
+		if err := tx.Where("short_url_id = ?", shortURLID).Find(&existing).Error; err != nil {
+			return err
+		}
+		byName := make(map[string]*domain.URLVariant, len(existing))
+		for _, v := range existing {
+			byName[strings.ToLower(v.Name)] = v
+		}
+
+		kept := make(map[uint]bool, len(variants))
+		for _, v := range variants {
+			v.ShortURLID = shortURLID
+			if current, ok := byName[strings.ToLower(v.Name)]; ok {
+				v.ID = current.ID
+				v.CreatedAt = current.CreatedAt
+			}
+			if err := tx.Save(v).Error; err != nil {
+				return err
+			}
+			kept[v.ID] = true
+		}
+
+		// Soft delete the rest so their clicks can still be reported
+		for _, v := range existing {
+			if kept[v.ID] {
+				continue
+			}
+			if err := tx.Delete(v).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replace URL variants: %w", err)
+	}
+	return nil
+}
+
+func (r *urlRepository) GetVariants(ctx context.Context, shortURLID uint) ([]*domain.URLVariant, error) {
+	var variants []*domain.URLVariant
+	if err := r.db.WithContext(ctx).
+		Unscoped().
+		Where("short_url_id = ?", shortURLID).
+		Order("id").
+		Find(&variants).Error; err != nil {
+		return nil, fmt.Errorf("failed to get URL variants: %w", err)
+	}
+	return variants, nil
+}
+
+func (r *urlRepository) ReplaceRedirectRules(ctx context.Context, shortURLID uint, rules []*domain.RedirectRule) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("short_url_id = ?", shortURLID).Delete(&domain.RedirectRule{}).Error; err != nil {
+			return err
+		}
+		for i, rule := range rules {
+			rule.ID = 0
+			rule.ShortURLID = shortURLID
+			rule.Position = i
+		}
+		if len(rules) == 0 {
+			return nil
+		}
+		return tx.Create(rules).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replace redirect rules: %w", err)
+	}
+	return nil
+}
+
+func (r *urlRepository) UpdateWithRevision(ctx context.Context, url *domain.ShortURL, revision *domain.URLRevision) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(url).Error; err != nil {
+			return err
+		}
+		return tx.Create(revision).Error
+	})
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			return domain.ErrShortCodeExists
+		}
+		return fmt.Errorf("failed to update short URL with revision: %w", err)
+	}
+	return nil
+}
+
+func (r *urlRepository) GetRevision(ctx context.Context, shortURLID, revisionID uint) (*domain.URLRevision, error) {
+	var revision domain.URLRevision
+	if err := r.db.WithContext(ctx).
+		Where("id = ? AND short_url_id = ?", revisionID, shortURLID).
+		First(&revision).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, domain.ErrRevisionNotFound
+		}
+		return nil, fmt.Errorf("failed to get URL revision: %w", err)
+	}
+	return &revision, nil
+}
+
+func (r *urlRepository) GetRevisions(ctx context.Context, shortURLID uint, offset, limit int) ([]*domain.URLRevision, int64, error) {
+	var revisions []*domain.URLRevision
+	var total int64
+
+	if err := r.db.WithContext(ctx).
+		Model(&domain.URLRevision{}).
+		Where("short_url_id = ?", shortURLID).
+		Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count URL revisions: %w", err)
+	}
+
+	if err := r.db.WithContext(ctx).
+		Where("short_url_id = ?", shortURLID).
+		Offset(offset).
+		Limit(limit).
+		Order("created_at DESC, id DESC").
+		Find(&revisions).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list URL revisions: %w", err)
+	}
+
+	return revisions, total, nil
+}
+
 func (r *urlRepository) IncrementClickCount(ctx context.Context, id uint) error {
 	if err := r.db.WithContext(ctx).
 		Model(&domain.ShortURL{}).
@@ -138,6 +297,39 @@ func (r *urlRepository) IncrementClickCount(ctx context.Context, id uint) error
 	return nil
 }
 
+func (r *urlRepository) IncrementClickCounts(ctx context.Context, counts map[uint]int64) error {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return incrementClickCounts(tx, counts)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to increment click counts: %w", err)
+	}
+	return nil
+}
+
+// incrementClickCounts adds to the click counts of several URLs within tx
+func incrementClickCounts(tx *gorm.DB, counts map[uint]int64) error {
+	// Update rows in a fixed order so concurrent writers can't deadlock
+	ids := make([]uint, 0, len(counts))
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		if err := tx.Model(&domain.ShortURL{}).
+			Where("id = ?", id).
+			Update("click_count", gorm.Expr("click_count + ?", counts[id])).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *urlRepository) GetExpiredURLs(ctx context.Context, limit int) ([]*domain.ShortURL, error) {
 	var urls []*domain.ShortURL
 	now := time.Now()
@@ -182,4 +374,14 @@ func (r *urlRepository) GetPopularURLs(ctx context.Context, limit int) ([]*domai
 		return nil, fmt.Errorf("failed to get popular URLs: %w", err)
 	}
 	return urls, nil
-}
\ No newline at end of file
+}
+
+// orderVariants keeps preloaded variants in creation order, which fixes how the
+// weight range is split between them
+func orderVariants(db *gorm.DB) *gorm.DB {
+	return db.Order("url_variants.id")
+}
+
+func orderRedirectRules(db *gorm.DB) *gorm.DB {
+	return db.Order("redirect_rules.position, redirect_rules.id")
+}
@@ -3,6 +3,7 @@ package repositories
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -62,6 +63,17 @@ func (r *userRepository) Update(ctx context.Context, user *domain.User) error {
 	return nil
 }
 
+func (r *userRepository) AdvanceTOTPStep(ctx context.Context, id uint, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&domain.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to record TOTP step: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
 func (r *userRepository) Delete(ctx context.Context, id uint) error {
 	result := r.db.WithContext(ctx).Delete(&domain.User{}, id)
 	if result.Error != nil {
@@ -102,6 +114,49 @@ func (r *userRepository) List(ctx context.Context, offset, limit int) ([]*domain
 	return users, total, nil
 }
 
+func (r *userRepository) Search(ctx context.Context, filter domain.UserFilter, offset, limit int) ([]*domain.User, int64, error) {
+	var users []*domain.User
+	var total int64
+
+	if err := r.db.WithContext(ctx).Model(&domain.User{}).Scopes(userFilterScope(filter)).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	if err := r.db.WithContext(ctx).
+		Scopes(userFilterScope(filter)).
+		Offset(offset).
+		Limit(limit).
+		Order("created_at DESC").
+		Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to search users: %w", err)
+	}
+
+	return users, total, nil
+}
+
+func userFilterScope(filter domain.UserFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.Query != "" {
+			// LOWER rather than ILIKE so the query also runs on SQLite in tests
+			pattern := "%" + likeEscaper.Replace(strings.ToLower(filter.Query)) + "%"
+			db = db.Where(
+				"(LOWER(email) LIKE ? ESCAPE '\\' OR LOWER(first_name) LIKE ? ESCAPE '\\' OR LOWER(last_name) LIKE ? ESCAPE '\\')",
+				pattern, pattern, pattern,
+			)
+		}
+		if filter.Role != "" {
+			db = db.Where("role = ?", filter.Role)
+		}
+		if filter.Active != nil {
+			db = db.Where("is_active = ?", *filter.Active)
+		}
+		return db
+	}
+}
+
+// likeEscaper stops user input from being read as LIKE wildcards
+var likeEscaper = strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+
 func (r *userRepository) GetUserStats(ctx context.Context, userID uint) (*domain.UserStats, error) {
 	var stats domain.UserStats
 	
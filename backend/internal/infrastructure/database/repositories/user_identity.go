@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+type userIdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewUserIdentityRepository(db *gorm.DB) ports.UserIdentityRepository {
+	return &userIdentityRepository{
+		db: db,
+	}
+}
+
+func (r *userIdentityRepository) Create(ctx context.Context, identity *domain.UserIdentity) error {
+	if err := r.db.WithContext(ctx).Create(identity).Error; err != nil {
+		return fmt.Errorf("failed to create user identity: %w", err)
+	}
+	return nil
+}
+
+func (r *userIdentityRepository) GetByIssuerSubject(ctx context.Context, issuer, subject string) (*domain.UserIdentity, error) {
+	var identity domain.UserIdentity
+	if err := r.db.WithContext(ctx).
+		Where("issuer = ? AND subject = ?", issuer, subject).
+		First(&identity).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, domain.ErrIdentityNotFound
+		}
+		return nil, fmt.Errorf("failed to get user identity: %w", err)
+	}
+	return &identity, nil
+}
+
+func (r *userIdentityRepository) Update(ctx context.Context, identity *domain.UserIdentity) error {
+	if err := r.db.WithContext(ctx).Save(identity).Error; err != nil {
+		return fmt.Errorf("failed to update user identity: %w", err)
+	}
+	return nil
+}
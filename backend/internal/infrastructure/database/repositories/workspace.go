@@ -0,0 +1,209 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+type workspaceRepository struct {
+	db *gorm.DB
+}
+
+func NewWorkspaceRepository(db *gorm.DB) ports.WorkspaceRepository {
+	return &workspaceRepository{
+		db: db,
+	}
+}
+
+func (r *workspaceRepository) Create(ctx context.Context, workspace *domain.Workspace, owner *domain.WorkspaceMember) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(workspace).Error; err != nil {
+			return err
+		}
+		owner.WorkspaceID = workspace.ID
+		return tx.Create(owner).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create workspace: %w", err)
+	}
+	return nil
+}
+
+func (r *workspaceRepository) GetByID(ctx context.Context, id uint) (*domain.Workspace, error) {
+	var workspace domain.Workspace
+	if err := r.db.WithContext(ctx).First(&workspace, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, domain.ErrWorkspaceNotFound
+		}
+		return nil, fmt.Errorf("failed to get workspace by id: %w", err)
+	}
+	return &workspace, nil
+}
+
+func (r *workspaceRepository) Update(ctx context.Context, workspace *domain.Workspace) error {
+	if err := r.db.WithContext(ctx).Save(workspace).Error; err != nil {
+		return fmt.Errorf("failed to update workspace: %w", err)
+	}
+	return nil
+}
+
+func (r *workspaceRepository) ListByUser(ctx context.Context, userID uint) ([]*domain.WorkspaceMember, error) {
+	var members []*domain.WorkspaceMember
+	if err := r.db.WithContext(ctx).
+		Joins("Workspace").
+		Where("workspace_members.user_id = ?", userID).
+		Order("Workspace.name ASC, workspace_members.workspace_id ASC").
+		Find(&members).Error; err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+	return members, nil
+}
+
+func (r *workspaceRepository) GetMember(ctx context.Context, workspaceID, userID uint) (*domain.WorkspaceMember, error) {
+	var member domain.WorkspaceMember
+	if err := r.db.WithContext(ctx).
+		Where("workspace_id = ? AND user_id = ?", workspaceID, userID).
+		First(&member).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, domain.ErrNotWorkspaceMember
+		}
+		return nil, fmt.Errorf("failed to get workspace member: %w", err)
+	}
+	return &member, nil
+}
+
+func (r *workspaceRepository) ListMembers(ctx context.Context, workspaceID uint) ([]*domain.WorkspaceMember, error) {
+	var members []*domain.WorkspaceMember
+	if err := r.db.WithContext(ctx).
+		Preload("User").
+		Where("workspace_id = ?", workspaceID).
+		Order("created_at ASC, id ASC").
+		Find(&members).Error; err != nil {
+		return nil, fmt.Errorf("failed to list workspace members: %w", err)
+	}
+	return members, nil
+}
+
+func (r *workspaceRepository) AddMember(ctx context.Context, member *domain.WorkspaceMember) error {
+	if err := r.db.WithContext(ctx).Create(member).Error; err != nil {
+		if isDuplicateKeyError(err) {
+			return domain.ErrAlreadyWorkspaceMember
+		}
+		return fmt.Errorf("failed to add workspace member: %w", err)
+	}
+	return nil
+}
+
+func (r *workspaceRepository) UpdateMember(ctx context.Context, member *domain.WorkspaceMember) error {
+	if err := r.db.WithContext(ctx).Save(member).Error; err != nil {
+		return fmt.Errorf("failed to update workspace member: %w", err)
+	}
+	return nil
+}
+
+func (r *workspaceRepository) RemoveMember(ctx context.Context, workspaceID, userID uint) error {
+	result := r.db.WithContext(ctx).
+		Where("workspace_id = ? AND user_id = ?", workspaceID, userID).
+		Delete(&domain.WorkspaceMember{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove workspace member: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrNotWorkspaceMember
+	}
+	return nil
+}
+
+func (r *workspaceRepository) CountOwners(ctx context.Context, workspaceID uint) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&domain.WorkspaceMember{}).
+		Where("workspace_id = ? AND role = ?", workspaceID, domain.WorkspaceRoleOwner).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count workspace owners: %w", err)
+	}
+	return count, nil
+}
+
+func (r *workspaceRepository) CreateInvitation(ctx context.Context, invitation *domain.WorkspaceInvitation) error {
+	if err := r.db.WithContext(ctx).Create(invitation).Error; err != nil {
+		return fmt.Errorf("failed to create invitation: %w", err)
+	}
+	return nil
+}
+
+func (r *workspaceRepository) GetInvitationByID(ctx context.Context, id uint) (*domain.WorkspaceInvitation, error) {
+	var invitation domain.WorkspaceInvitation
+	if err := r.db.WithContext(ctx).First(&invitation, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, domain.ErrInvitationNotFound
+		}
+		return nil, fmt.Errorf("failed to get invitation by id: %w", err)
+	}
+	return &invitation, nil
+}
+
+func (r *workspaceRepository) GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*domain.WorkspaceInvitation, error) {
+	var invitation domain.WorkspaceInvitation
+	if err := r.db.WithContext(ctx).
+		Preload("Workspace").
+		Where("token_hash = ?", tokenHash).
+		First(&invitation).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, domain.ErrInvitationNotFound
+		}
+		return nil, fmt.Errorf("failed to get invitation by token: %w", err)
+	}
+	return &invitation, nil
+}
+
+func (r *workspaceRepository) ListPendingInvitations(ctx context.Context, workspaceID uint, now time.Time) ([]*domain.WorkspaceInvitation, error) {
+	var invitations []*domain.WorkspaceInvitation
+	if err := r.db.WithContext(ctx).
+		Where("workspace_id = ? AND accepted_at IS NULL AND expires_at > ?", workspaceID, now).
+		Order("created_at DESC, id DESC").
+		Find(&invitations).Error; err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	return invitations, nil
+}
+
+func (r *workspaceRepository) AcceptInvitation(ctx context.Context, invitation *domain.WorkspaceInvitation, member *domain.WorkspaceMember) error {
+	now := time.Now()
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// A conditional update, so the same invitation cannot be accepted twice
+		result := tx.Model(&domain.WorkspaceInvitation{}).
+			Where("id = ? AND accepted_at IS NULL", invitation.ID).
+			Update("accepted_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return domain.ErrInvitationNotFound
+		}
+		return tx.Create(member).Error
+	})
+	if err != nil {
+		if err == domain.ErrInvitationNotFound {
+			return err
+		}
+		if isDuplicateKeyError(err) {
+			return domain.ErrAlreadyWorkspaceMember
+		}
+		return fmt.Errorf("failed to accept invitation: %w", err)
+	}
+	invitation.AcceptedAt = &now
+	return nil
+}
+
+func (r *workspaceRepository) DeleteInvitation(ctx context.Context, id uint) error {
+	if err := r.db.WithContext(ctx).Delete(&domain.WorkspaceInvitation{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete invitation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package geolocation
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"url-shortener/internal/core/domain"
+)
+
+// csvColumns is the header a CSV database must start with
+var csvColumns = []string{"network", "country_code", "country", "region_code", "region", "city", "latitude", "longitude", "timezone"}
+
+// CSVProvider holds a CSV database of networks in memory and resolves an address
+// to its most specific enclosing network
+type CSVProvider struct {
+	networks map[netip.Prefix]*domain.GeoLocation
+	// prefix lengths present in the database, longest first
+	bits []int
+}
+
+func OpenCSV(path string) (*CSVProvider, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geolocation database: %w", err)
+	}
+	defer file.Close()
+
+	return LoadCSV(file)
+}
+
+// LoadCSV parses a database in the csvColumns layout
+func LoadCSV(r io.Reader) (*CSVProvider, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(csvColumns)
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read geolocation database header: %w", err)
+	}
+	for i, column := range csvColumns {
+		if strings.TrimSpace(header[i]) != column {
+			return nil, fmt.Errorf("unexpected geolocation database column %q, expected %q", header[i], column)
+		}
+	}
+
+	provider := &CSVProvider{networks: make(map[netip.Prefix]*domain.GeoLocation)}
+	seen := make(map[int]bool)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read geolocation database: %w", err)
+		}
+
+		prefix, location, err := parseCSVRecord(record)
+		if err != nil {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("invalid geolocation record on line %d: %w", line, err)
+		}
+
+		provider.networks[prefix] = location
+		if !seen[prefix.Bits()] {
+			seen[prefix.Bits()] = true
+			provider.bits = append(provider.bits, prefix.Bits())
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(provider.bits)))
+	return provider, nil
+}
+
+func parseCSVRecord(record []string) (netip.Prefix, *domain.GeoLocation, error) {
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(record[0]))
+	if err != nil {
+		return netip.Prefix{}, nil, err
+	}
+
+	location := &domain.GeoLocation{
+		CountryCode: strings.ToUpper(record[1]),
+		Country:     record[2],
+		RegionCode:  record[3],
+		Region:      record[4],
+		City:        record[5],
+		Timezone:    record[8],
+		Source:      "csv",
+	}
+	if location.Latitude, err = parseCoordinate(record[6]); err != nil {
+		return netip.Prefix{}, nil, fmt.Errorf("latitude: %w", err)
+	}
+	if location.Longitude, err = parseCoordinate(record[7]); err != nil {
+		return netip.Prefix{}, nil, fmt.Errorf("longitude: %w", err)
+	}
+	location.Accuracy = accuracy(location)
+
+	return prefix.Masked(), location, nil
+}
+
+func parseCoordinate(value string) (float64, error) {
+	if value = strings.TrimSpace(value); value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+func (p *CSVProvider) Lookup(ctx context.Context, ip net.IP) (*domain.GeoLocation, error) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return nil, domain.ErrLocationNotFound
+	}
+	addr = addr.Unmap()
+
+	for _, bits := range p.bits {
+		if bits > addr.BitLen() {
+			continue
+		}
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if location, ok := p.networks[prefix]; ok {
+			return copyLocation(location), nil
+		}
+	}
+	return nil, domain.ErrLocationNotFound
+}
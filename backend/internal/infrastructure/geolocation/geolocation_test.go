@@ -0,0 +1,323 @@
+package geolocation
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"url-shortener/internal/config"
+	"url-shortener/internal/core/domain"
+)
+
+// Expectations shared by the CSV fixture and the MMDB generated from it
+var fixtureLookups = []struct {
+	ip          string
+	countryCode string
+	region      string
+	city        string
+	latitude    float64
+	timezone    string
+	accuracy    string
+}{
+	{"81.2.69.142", "GB", "England", "London", 51.5142, "Europe/London", "city"},
+	{"81.2.69.200", "GB", "England", "Boxford", 51.75, "Europe/London", "city"},
+	{"216.160.83.58", "US", "Washington", "Milton", 47.2513, "America/Los_Angeles", "city"},
+	{"89.160.20.120", "SE", "Östergötland County", "Linköping", 58.4167, "Europe/Stockholm", "city"},
+	{"175.16.199.1", "CN", "Jilin Sheng", "Changchun", 43.88, "Asia/Harbin", "city"},
+	{"2001:218:abcd::1", "JP", "", "", 35.68536, "Asia/Tokyo", "country"},
+}
+
+var fixtureMisses = []string{"81.2.70.1", "8.8.8.8", "2001:db8::1"}
+
+func assertFixtureLookups(t *testing.T, provider Provider) {
+	for _, tt := range fixtureLookups {
+		t.Run(tt.ip, func(t *testing.T) {
+			location, err := provider.Lookup(context.Background(), net.ParseIP(tt.ip))
+			require.NoError(t, err)
+			assert.Equal(t, tt.countryCode, location.CountryCode)
+			assert.Equal(t, tt.region, location.Region)
+			assert.Equal(t, tt.city, location.City)
+			assert.InDelta(t, tt.latitude, location.Latitude, 0.0001)
+			assert.Equal(t, tt.timezone, location.Timezone)
+			assert.Equal(t, tt.accuracy, location.Accuracy)
+		})
+	}
+
+	for _, ip := range fixtureMisses {
+		_, err := provider.Lookup(context.Background(), net.ParseIP(ip))
+		assert.Equal(t, domain.ErrLocationNotFound, err, ip)
+	}
+}
+
+func TestCSVProvider(t *testing.T) {
+	provider, err := OpenCSV("testdata/geo-test.csv")
+	require.NoError(t, err)
+	assertFixtureLookups(t, provider)
+}
+
+func TestCSVProvider_InvalidFile(t *testing.T) {
+	_, err := LoadCSV(strings.NewReader("cidr,country\n"))
+	assert.Error(t, err)
+
+	header := strings.Join(csvColumns, ",") + "\n"
+	_, err = LoadCSV(strings.NewReader(header + "not-a-network,GB,United Kingdom,,,,,,\n"))
+	assert.Error(t, err)
+
+	_, err = LoadCSV(strings.NewReader(header + "81.2.69.0/24,GB,United Kingdom,,,,north,0,\n"))
+	assert.Error(t, err)
+}
+
+func TestMMDBProvider(t *testing.T) {
+	provider, err := OpenMMDB("testdata/geo-test.mmdb")
+	require.NoError(t, err)
+	defer provider.Close()
+
+	require.NoError(t, provider.reader.Verify())
+	assert.Equal(t, "GeoIP2-City-Test", provider.reader.Metadata.DatabaseType)
+
+	assertFixtureLookups(t, provider)
+
+	location, err := provider.Lookup(context.Background(), net.ParseIP("216.160.83.58"))
+	require.NoError(t, err)
+	assert.Equal(t, "United States", location.Country)
+	assert.Equal(t, "WA", location.RegionCode)
+	assert.Equal(t, "maxmind", location.Source)
+}
+
+func TestOpenDatabase(t *testing.T) {
+	provider, err := OpenDatabase("testdata/geo-test.csv")
+	require.NoError(t, err)
+	assert.IsType(t, &CSVProvider{}, provider)
+
+	provider, err = OpenDatabase("testdata/geo-test.mmdb")
+	require.NoError(t, err)
+	assert.IsType(t, &MMDBProvider{}, provider)
+
+	_, err = OpenDatabase("testdata/geo-test.json")
+	assert.Error(t, err)
+
+	_, err = OpenDatabase("testdata/missing.mmdb")
+	assert.Error(t, err)
+}
+
+func TestHTTPProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "test-key", r.URL.Query().Get("apiKey"))
+
+		switch r.URL.Query().Get("ip") {
+		case "8.8.8.8":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{
+				"ip": "8.8.8.8",
+				"country_code2": "US",
+				"country_name": "United States",
+				"state_prov": "California",
+				"state_code": "US-CA",
+				"city": "Mountain View",
+				"latitude": "37.42240",
+				"longitude": -122.08421,
+				"isp": "Google LLC",
+				"organization": "Google LLC",
+				"time_zone": {"name": "America/Los_Angeles", "offset": -8}
+			}`))
+		case "1.1.1.1":
+			w.WriteHeader(http.StatusLocked)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewHTTPProvider(server.URL, "test-key", server.Client())
+
+	location, err := provider.Lookup(context.Background(), net.ParseIP("8.8.8.8"))
+	require.NoError(t, err)
+	assert.Equal(t, "US", location.CountryCode)
+	assert.Equal(t, "California", location.Region)
+	assert.Equal(t, "Mountain View", location.City)
+	assert.InDelta(t, 37.4224, location.Latitude, 0.0001)
+	assert.InDelta(t, -122.08421, location.Longitude, 0.0001)
+	assert.Equal(t, "America/Los_Angeles", location.Timezone)
+	assert.Equal(t, "Google LLC", location.ISP)
+	assert.Equal(t, "ipgeolocation", location.Source)
+
+	_, err = provider.Lookup(context.Background(), net.ParseIP("1.1.1.1"))
+	assert.Equal(t, domain.ErrLocationNotFound, err)
+
+	_, err = provider.Lookup(context.Background(), net.ParseIP("9.9.9.9"))
+	assert.Error(t, err)
+	assert.NotEqual(t, domain.ErrLocationNotFound, err)
+}
+
+// countingProvider records how often the service reaches the underlying provider
+type countingProvider struct {
+	Provider
+	calls int32
+	err   error
+}
+
+func (p *countingProvider) Lookup(ctx context.Context, ip net.IP) (*domain.GeoLocation, error) {
+	atomic.AddInt32(&p.calls, 1)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.Provider.Lookup(ctx, ip)
+}
+
+type GeolocationServiceTestSuite struct {
+	suite.Suite
+	provider *countingProvider
+	service  *geolocationService
+	ctx      context.Context
+}
+
+func (suite *GeolocationServiceTestSuite) SetupTest() {
+	csvProvider, err := OpenCSV("testdata/geo-test.csv")
+	suite.Require().NoError(err)
+
+	suite.provider = &countingProvider{Provider: csvProvider}
+	suite.service = NewService(suite.provider, 2).(*geolocationService)
+	suite.ctx = context.Background()
+}
+
+func (suite *GeolocationServiceTestSuite) TestGetLocationFromIP_NormalizesAddress() {
+	for _, input := range []string{"81.2.69.142", "81.2.69.142:53211", " 81.2.69.142, 10.0.0.1", "[::ffff:81.2.69.142]:443"} {
+		location, err := suite.service.GetLocationFromIP(suite.ctx, input)
+		suite.Require().NoError(err, input)
+		suite.Equal("81.2.69.142", location.IPAddress)
+		suite.Equal("London", location.City)
+		suite.False(location.CachedAt.IsZero())
+	}
+	suite.Equal(int32(1), suite.provider.calls)
+}
+
+func (suite *GeolocationServiceTestSuite) TestGetLocationFromIP_CachesMisses() {
+	_, err := suite.service.GetLocationFromIP(suite.ctx, "8.8.8.8")
+	suite.Equal(domain.ErrLocationNotFound, err)
+	_, err = suite.service.GetLocationFromIP(suite.ctx, "8.8.8.8")
+	suite.Equal(domain.ErrLocationNotFound, err)
+
+	suite.Equal(int32(1), suite.provider.calls)
+}
+
+func (suite *GeolocationServiceTestSuite) TestGetLocationFromIP_PrivateAndInvalid() {
+	for _, ip := range []string{"127.0.0.1", "10.1.2.3", "192.168.0.10", "::1", "fe80::1"} {
+		_, err := suite.service.GetLocationFromIP(suite.ctx, ip)
+		suite.Equal(domain.ErrLocationNotFound, err, ip)
+	}
+
+	_, err := suite.service.GetLocationFromIP(suite.ctx, "not-an-ip")
+	suite.Equal(domain.ErrInvalidInput, err)
+
+	suite.Equal(int32(0), suite.provider.calls)
+}
+
+func (suite *GeolocationServiceTestSuite) TestGetLocationFromIP_ProviderError() {
+	suite.provider.err = errors.New("connection refused")
+
+	_, err := suite.service.GetLocationFromIP(suite.ctx, "81.2.69.142")
+	suite.ErrorIs(err, domain.ErrGeolocationService)
+	suite.Equal(0, suite.service.cache.len())
+}
+
+func (suite *GeolocationServiceTestSuite) TestGetLocationFromIP_ReturnsCopies() {
+	location, err := suite.service.GetLocationFromIP(suite.ctx, "81.2.69.142")
+	suite.Require().NoError(err)
+	location.City = "Modified"
+
+	location, err = suite.service.GetLocationFromIP(suite.ctx, "81.2.69.142")
+	suite.Require().NoError(err)
+	suite.Equal("London", location.City)
+}
+
+func (suite *GeolocationServiceTestSuite) TestCacheEvictsLeastRecentlyUsed() {
+	suite.service.GetLocationFromIP(suite.ctx, "81.2.69.142")
+	suite.service.GetLocationFromIP(suite.ctx, "216.160.83.58")
+	suite.service.GetLocationFromIP(suite.ctx, "81.2.69.142")
+	suite.service.GetLocationFromIP(suite.ctx, "89.160.20.120")
+	suite.Equal(2, suite.service.cache.len())
+	suite.Equal(int32(3), suite.provider.calls)
+
+	// 216.160.83.58 was least recently used and has been evicted
+	suite.service.GetLocationFromIP(suite.ctx, "81.2.69.142")
+	suite.Equal(int32(3), suite.provider.calls)
+	suite.service.GetLocationFromIP(suite.ctx, "216.160.83.58")
+	suite.Equal(int32(4), suite.provider.calls)
+}
+
+func (suite *GeolocationServiceTestSuite) TestGetLocationsBatch() {
+	locations, err := suite.service.GetLocationsBatch(suite.ctx, []string{"81.2.69.142", "8.8.8.8", "garbage", "175.16.199.1", "81.2.69.142"})
+
+	suite.Require().NoError(err)
+	suite.Len(locations, 2)
+	suite.Equal("London", locations["81.2.69.142"].City)
+	suite.Equal("Changchun", locations["175.16.199.1"].City)
+}
+
+func (suite *GeolocationServiceTestSuite) TestValidateLocation() {
+	valid := &domain.GeoLocation{CountryCode: "GB", Latitude: 51.5, Longitude: -0.1, Timezone: "Europe/London"}
+	suite.NoError(suite.service.ValidateLocation(suite.ctx, valid))
+
+	invalid := []*domain.GeoLocation{
+		nil,
+		{Latitude: 91},
+		{Longitude: -181},
+		{CountryCode: "XX"},
+		{CountryCode: "GBR"},
+		{Timezone: "Mars/Olympus_Mons"},
+	}
+	for _, location := range invalid {
+		suite.Error(suite.service.ValidateLocation(suite.ctx, location))
+	}
+}
+
+func (suite *GeolocationServiceTestSuite) TestGetCountryCode() {
+	cases := map[string]string{
+		"United Kingdom":           "GB",
+		"united states":            "US",
+		"United States of America": "US",
+		"Sweden":                   "SE",
+		" japan ":                  "JP",
+		"Russian Federation":       "RU",
+		"de":                       "DE",
+	}
+	for name, expected := range cases {
+		code, err := suite.service.GetCountryCode(suite.ctx, name)
+		suite.NoError(err, name)
+		suite.Equal(expected, code, name)
+	}
+
+	_, err := suite.service.GetCountryCode(suite.ctx, "Atlantis")
+	suite.Equal(domain.ErrLocationNotFound, err)
+}
+
+func TestGeolocationServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(GeolocationServiceTestSuite))
+}
+
+func TestNewGeolocationService(t *testing.T) {
+	service, err := NewGeolocationService(config.ExternalConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, service)
+
+	service, err = NewGeolocationService(config.ExternalConfig{GeolocationDBPath: "testdata/geo-test.mmdb"})
+	require.NoError(t, err)
+	location, err := service.GetLocationFromIP(context.Background(), "89.160.20.120")
+	require.NoError(t, err)
+	assert.Equal(t, "Linköping", location.City)
+
+	service, err = NewGeolocationService(config.ExternalConfig{GeolocationAPIKey: "key", GeolocationAPIURL: "http://localhost"})
+	require.NoError(t, err)
+	assert.NotNil(t, service)
+
+	_, err = NewGeolocationService(config.ExternalConfig{GeolocationDBPath: "testdata/missing.csv"})
+	assert.Error(t, err)
+}
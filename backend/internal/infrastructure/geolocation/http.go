@@ -0,0 +1,109 @@
+package geolocation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"url-shortener/internal/core/domain"
+)
+
+// HTTPProvider queries an ipgeolocation.io compatible API
+type HTTPProvider struct {
+	apiURL string
+	apiKey string
+	client *http.Client
+}
+
+func NewHTTPProvider(apiURL, apiKey string, client *http.Client) *HTTPProvider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPProvider{apiURL: apiURL, apiKey: apiKey, client: client}
+}
+
+// coordinate accepts latitude and longitude sent either as JSON numbers or strings
+type coordinate float64
+
+func (c *coordinate) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	if value == "" || value == "null" {
+		*c = 0
+		return nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return err
+	}
+	*c = coordinate(f)
+	return nil
+}
+
+type apiResponse struct {
+	CountryCode  string     `json:"country_code2"`
+	CountryName  string     `json:"country_name"`
+	StateProv    string     `json:"state_prov"`
+	StateCode    string     `json:"state_code"`
+	City         string     `json:"city"`
+	Latitude     coordinate `json:"latitude"`
+	Longitude    coordinate `json:"longitude"`
+	ISP          string     `json:"isp"`
+	Organization string     `json:"organization"`
+	TimeZone     struct {
+		Name string `json:"name"`
+	} `json:"time_zone"`
+}
+
+func (p *HTTPProvider) Lookup(ctx context.Context, ip net.IP) (*domain.GeoLocation, error) {
+	query := url.Values{}
+	query.Set("apiKey", p.apiKey)
+	query.Set("ip", ip.String())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.apiURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build geolocation request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query geolocation API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusLocked:
+		// ipgeolocation.io answers 423 for bogon and reserved addresses
+		return nil, domain.ErrLocationNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("geolocation API returned status %d", resp.StatusCode)
+	}
+
+	var body apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode geolocation response: %w", err)
+	}
+	if body.CountryCode == "" {
+		return nil, domain.ErrLocationNotFound
+	}
+
+	location := &domain.GeoLocation{
+		Country:      body.CountryName,
+		CountryCode:  strings.ToUpper(body.CountryCode),
+		Region:       body.StateProv,
+		RegionCode:   body.StateCode,
+		City:         body.City,
+		Latitude:     float64(body.Latitude),
+		Longitude:    float64(body.Longitude),
+		Timezone:     body.TimeZone.Name,
+		ISP:          body.ISP,
+		Organization: body.Organization,
+		Source:       "ipgeolocation",
+	}
+	location.Accuracy = accuracy(location)
+	return location, nil
+}
@@ -0,0 +1,71 @@
+package geolocation
+
+import (
+	"container/list"
+	"sync"
+
+	"url-shortener/internal/core/domain"
+)
+
+const defaultCacheSize = 10000
+
+// lruCache is a fixed-size, concurrency-safe cache of lookups. A nil location
+// records that an address has no entry.
+type lruCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key      string
+	location *domain.GeoLocation
+}
+
+func newLRUCache(size int) *lruCache {
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+	return &lruCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+func (c *lruCache) get(key string) (*domain.GeoLocation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*lruEntry).location, true
+}
+
+func (c *lruCache) add(key string, location *domain.GeoLocation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*lruEntry).location = location
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, location: location})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (c *lruCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,74 @@
+package geolocation
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+	"url-shortener/internal/core/domain"
+)
+
+// cityRecord is the subset of the GeoIP2/GeoLite2 City schema we read
+type cityRecord struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+		TimeZone  string  `maxminddb:"time_zone"`
+	} `maxminddb:"location"`
+	Subdivisions []struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+}
+
+// MMDBProvider looks addresses up in a MaxMind City database
+type MMDBProvider struct {
+	reader *maxminddb.Reader
+}
+
+func OpenMMDB(path string) (*MMDBProvider, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geolocation database: %w", err)
+	}
+	return &MMDBProvider{reader: reader}, nil
+}
+
+func (p *MMDBProvider) Lookup(ctx context.Context, ip net.IP) (*domain.GeoLocation, error) {
+	var record cityRecord
+	_, ok, err := p.reader.LookupNetwork(ip, &record)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrLocationNotFound
+	}
+
+	location := &domain.GeoLocation{
+		Country:     record.Country.Names["en"],
+		CountryCode: record.Country.ISOCode,
+		City:        record.City.Names["en"],
+		Latitude:    record.Location.Latitude,
+		Longitude:   record.Location.Longitude,
+		Timezone:    record.Location.TimeZone,
+		Source:      "maxmind",
+	}
+	if len(record.Subdivisions) > 0 {
+		location.Region = record.Subdivisions[0].Names["en"]
+		location.RegionCode = record.Subdivisions[0].ISOCode
+	}
+	location.Accuracy = accuracy(location)
+	return location, nil
+}
+
+func (p *MMDBProvider) Close() error {
+	return p.reader.Close()
+}
@@ -0,0 +1,191 @@
+package geolocation
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"url-shortener/internal/config"
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+// Provider resolves a single IP address against one data source. It returns
+// domain.ErrLocationNotFound when the source has no entry for the address.
+type Provider interface {
+	Lookup(ctx context.Context, ip net.IP) (*domain.GeoLocation, error)
+}
+
+type geolocationService struct {
+	provider Provider
+	cache    *lruCache
+}
+
+// NewGeolocationService picks a provider from the configuration: a local database
+// file when GeolocationDBPath is set, otherwise the HTTP API when an API key is set.
+// It returns a nil service when neither is configured.
+func NewGeolocationService(cfg config.ExternalConfig) (ports.GeolocationService, error) {
+	var provider Provider
+	switch {
+	case cfg.GeolocationDBPath != "":
+		p, err := OpenDatabase(cfg.GeolocationDBPath)
+		if err != nil {
+			return nil, err
+		}
+		provider = p
+	case cfg.GeolocationAPIKey != "" && cfg.GeolocationAPIURL != "":
+		provider = NewHTTPProvider(cfg.GeolocationAPIURL, cfg.GeolocationAPIKey, &http.Client{Timeout: 2 * time.Second})
+	default:
+		return nil, nil
+	}
+
+	return NewService(provider, cfg.GeolocationCacheSize), nil
+}
+
+// OpenDatabase opens a MaxMind .mmdb file or a CSV file of networks, chosen by extension
+func OpenDatabase(path string) (Provider, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mmdb":
+		return OpenMMDB(path)
+	case ".csv":
+		return OpenCSV(path)
+	default:
+		return nil, fmt.Errorf("unsupported geolocation database %q: expected .mmdb or .csv", path)
+	}
+}
+
+// NewService wraps a provider with an LRU cache of lookups, including misses
+func NewService(provider Provider, cacheSize int) ports.GeolocationService {
+	return &geolocationService{
+		provider: provider,
+		cache:    newLRUCache(cacheSize),
+	}
+}
+
+func (s *geolocationService) GetLocationFromIP(ctx context.Context, ipAddress string) (*domain.GeoLocation, error) {
+	ip := ParseIP(ipAddress)
+	if ip == nil {
+		return nil, domain.ErrInvalidInput
+	}
+
+	// Private and loopback addresses never resolve to a location
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+		return nil, domain.ErrLocationNotFound
+	}
+
+	key := ip.String()
+	if location, ok := s.cache.get(key); ok {
+		if location == nil {
+			return nil, domain.ErrLocationNotFound
+		}
+		return copyLocation(location), nil
+	}
+
+	location, err := s.provider.Lookup(ctx, ip)
+	if err == domain.ErrLocationNotFound {
+		s.cache.add(key, nil)
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrGeolocationService, err)
+	}
+
+	location.IPAddress = key
+	location.CachedAt = time.Now()
+	s.cache.add(key, location)
+	return copyLocation(location), nil
+}
+
+// GetLocationsBatch resolves each distinct address, leaving unknown ones out of the result
+func (s *geolocationService) GetLocationsBatch(ctx context.Context, ipAddresses []string) (map[string]*domain.GeoLocation, error) {
+	locations := make(map[string]*domain.GeoLocation, len(ipAddresses))
+	for _, ipAddress := range ipAddresses {
+		if _, done := locations[ipAddress]; done {
+			continue
+		}
+
+		location, err := s.GetLocationFromIP(ctx, ipAddress)
+		switch err {
+		case nil:
+			locations[ipAddress] = location
+		case domain.ErrLocationNotFound, domain.ErrInvalidInput:
+			continue
+		default:
+			return nil, err
+		}
+	}
+	return locations, nil
+}
+
+func (s *geolocationService) ValidateLocation(ctx context.Context, location *domain.GeoLocation) error {
+	if location == nil {
+		return domain.NewValidationError("location", "is required")
+	}
+	if location.Latitude < -90 || location.Latitude > 90 {
+		return domain.NewValidationError("latitude", "must be between -90 and 90")
+	}
+	if location.Longitude < -180 || location.Longitude > 180 {
+		return domain.NewValidationError("longitude", "must be between -180 and 180")
+	}
+	if location.CountryCode != "" {
+		if _, ok := countryNames[strings.ToUpper(location.CountryCode)]; !ok {
+			return domain.NewValidationError("country_code", "is not an ISO 3166-1 alpha-2 code")
+		}
+	}
+	if location.Timezone != "" {
+		if _, err := time.LoadLocation(location.Timezone); err != nil {
+			return domain.NewValidationError("timezone", "is not a known IANA time zone")
+		}
+	}
+	return nil
+}
+
+// GetCountryCode maps an English country name, or an existing code, to its ISO code
+func (s *geolocationService) GetCountryCode(ctx context.Context, countryName string) (string, error) {
+	name := strings.TrimSpace(countryName)
+	if code := strings.ToUpper(name); len(code) == 2 {
+		if _, ok := countryNames[code]; ok {
+			return code, nil
+		}
+	}
+
+	if code, ok := countryCodes[strings.ToLower(name)]; ok {
+		return code, nil
+	}
+	return "", domain.ErrLocationNotFound
+}
+
+// ParseIP extracts an address from a header or RemoteAddr value, which may carry a
+// port or, for X-Forwarded-For, a list of addresses of which the first is the client
+func ParseIP(value string) net.IP {
+	value = strings.TrimSpace(value)
+	if i := strings.Index(value, ","); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	value = strings.Trim(value, "[]")
+	return net.ParseIP(value)
+}
+
+// accuracy reports the most specific level a location was resolved to
+func accuracy(location *domain.GeoLocation) string {
+	switch {
+	case location.City != "":
+		return "city"
+	case location.Region != "":
+		return "region"
+	default:
+		return "country"
+	}
+}
+
+func copyLocation(location *domain.GeoLocation) *domain.GeoLocation {
+	c := *location
+	return &c
+}
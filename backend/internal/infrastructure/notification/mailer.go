@@ -0,0 +1,105 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-shortener/internal/config"
+)
+
+// Message is a plain-text email to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers a message; implementations must not alter the body
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type smtpMailer struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from *mail.Address
+}
+
+// NewSMTPMailer sends mail through an SMTP relay, upgrading to TLS when the server
+// offers STARTTLS and authenticating when a username is configured.
+func NewSMTPMailer(cfg config.MailConfig) (Mailer, error) {
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address %q: %w", cfg.From, err)
+	}
+
+	m := &smtpMailer{
+		addr: net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+		host: cfg.SMTPHost,
+		from: from,
+	}
+	if cfg.Username != "" {
+		m.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.SMTPHost)
+	}
+	return m, nil
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg Message) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	data, err := buildMessage(m.from, to, msg.Subject, msg.Body, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := smtp.SendMail(m.addr, m.auth, m.from.Address, []string{to.Address}, data); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+type logMailer struct{}
+
+// NewLogMailer writes messages to the standard logger instead of sending them.
+// It is meant for development, where reset links can be copied from the log.
+func NewLogMailer() Mailer {
+	return logMailer{}
+}
+
+func (logMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("Email to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// buildMessage renders RFC 5322 headers followed by the body with CRLF line endings
+func buildMessage(from, to *mail.Address, subject, body string, date time.Time) ([]byte, error) {
+	if strings.ContainsAny(subject, "\r\n") {
+		return nil, fmt.Errorf("email subject must be a single line")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,98 @@
+package notification
+
+import (
+	"context"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"url-shortener/internal/config"
+	"url-shortener/internal/core/domain"
+)
+
+type recordingMailer struct {
+	messages []Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg Message) error {
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func TestSendPasswordResetEmail(t *testing.T) {
+	mailer := &recordingMailer{}
+	service := NewService(mailer, "https://app.example.com/")
+	user := &domain.User{Email: "jane@example.com", FirstName: "Jane"}
+
+	err := service.SendPasswordResetEmail(context.Background(), user, "abc+/=def")
+	require.NoError(t, err)
+
+	require.Len(t, mailer.messages, 1)
+	msg := mailer.messages[0]
+	assert.Equal(t, "jane@example.com", msg.To)
+	assert.Equal(t, "Reset your password", msg.Subject)
+	assert.Contains(t, msg.Body, "Hi Jane,")
+	assert.Contains(t, msg.Body, "https://app.example.com/reset-password?token=abc%2B%2F%3Ddef\n")
+}
+
+func TestSendWelcomeEmail(t *testing.T) {
+	mailer := &recordingMailer{}
+	service := NewService(mailer, "https://app.example.com")
+	user := &domain.User{Email: "jane@example.com"}
+
+	require.NoError(t, service.SendWelcomeEmail(context.Background(), user, "1.1700000000.sig"))
+	require.NoError(t, service.SendWelcomeEmail(context.Background(), user, ""))
+
+	require.Len(t, mailer.messages, 2)
+	assert.Contains(t, mailer.messages[0].Body, "Hi jane@example.com,")
+	assert.Contains(t, mailer.messages[0].Body, "https://app.example.com/verify-email?token=1.1700000000.sig\n")
+	assert.NotContains(t, mailer.messages[1].Body, "verify-email")
+}
+
+func TestSendWorkspaceInvitation(t *testing.T) {
+	mailer := &recordingMailer{}
+	service := NewService(mailer, "https://app.example.com")
+	inviter := &domain.User{Email: "jane@example.com", FirstName: "Jane"}
+	workspace := &domain.Workspace{ID: 3, Name: "Marketing"}
+
+	require.NoError(t, service.SendWorkspaceInvitation(context.Background(), "sam@example.com", inviter, workspace, "tok+en"))
+
+	require.Len(t, mailer.messages, 1)
+	msg := mailer.messages[0]
+	assert.Equal(t, "sam@example.com", msg.To)
+	assert.Equal(t, "Join Marketing on URL Shortener", msg.Subject)
+	assert.Contains(t, msg.Body, "Jane (jane@example.com) invited you")
+	assert.Contains(t, msg.Body, "https://app.example.com/workspaces/accept?token=tok%2Ben\n")
+}
+
+func TestBuildMessage(t *testing.T) {
+	from := &mail.Address{Name: "URL Shortener", Address: "no-reply@example.com"}
+	to := &mail.Address{Address: "jane@example.com"}
+	date := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	data, err := buildMessage(from, to, "Grüße", "line one\nline two\n", date)
+	require.NoError(t, err)
+
+	text := string(data)
+	assert.True(t, strings.HasPrefix(text, "From: \"URL Shortener\" <no-reply@example.com>\r\n"))
+	assert.Contains(t, text, "To: <jane@example.com>\r\n")
+	assert.Contains(t, text, "Subject: =?utf-8?q?Gr=C3=BC=C3=9Fe?=\r\n")
+	assert.Contains(t, text, "Date: Fri, 01 Mar 2024 12:00:00 +0000\r\n")
+	assert.True(t, strings.HasSuffix(text, "\r\n\r\nline one\r\nline two\r\n"))
+
+	// Header injection through the subject is refused
+	_, err = buildMessage(from, to, "Hello\r\nBcc: victim@example.com", "body", date)
+	assert.Error(t, err)
+}
+
+func TestNewNotificationService(t *testing.T) {
+	_, err := NewNotificationService(config.MailConfig{SMTPHost: "smtp.example.com", SMTPPort: 587, From: "not an address"}, "")
+	assert.Error(t, err)
+
+	service, err := NewNotificationService(config.MailConfig{}, "http://localhost:3000")
+	require.NoError(t, err)
+	assert.IsType(t, logMailer{}, service.(*notificationService).mailer)
+}
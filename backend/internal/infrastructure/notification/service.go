@@ -0,0 +1,136 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"url-shortener/internal/config"
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+type notificationService struct {
+	mailer      Mailer
+	frontendURL string
+}
+
+// NewNotificationService sends email over SMTP when a host is configured and logs
+// it otherwise. Links in the messages point at the frontend.
+func NewNotificationService(cfg config.MailConfig, frontendURL string) (ports.NotificationService, error) {
+	if cfg.SMTPHost == "" {
+		return NewService(NewLogMailer(), frontendURL), nil
+	}
+
+	mailer, err := NewSMTPMailer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewService(mailer, frontendURL), nil
+}
+
+// NewService builds the notification service on top of any mailer
+func NewService(mailer Mailer, frontendURL string) ports.NotificationService {
+	return &notificationService{
+		mailer:      mailer,
+		frontendURL: strings.TrimRight(frontendURL, "/"),
+	}
+}
+
+func (s *notificationService) SendWelcomeEmail(ctx context.Context, user *domain.User, verificationToken string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hi %s,\n\nWelcome to URL Shortener! Your account for %s is ready.\n", displayName(user), user.Email)
+	if verificationToken != "" {
+		link := fmt.Sprintf("%s/verify-email?token=%s", s.frontendURL, url.QueryEscape(verificationToken))
+		fmt.Fprintf(&b, "\nPlease confirm your email address so you can start creating links:\n\n%s\n", link)
+	} else {
+		fmt.Fprintf(&b, "\nSign in at %s/login\n", s.frontendURL)
+	}
+	return s.send(ctx, user, "Welcome to URL Shortener", b.String())
+}
+
+func (s *notificationService) SendPasswordResetEmail(ctx context.Context, user *domain.User, resetToken string) error {
+	link := fmt.Sprintf("%s/reset-password?token=%s", s.frontendURL, url.QueryEscape(resetToken))
+	body := fmt.Sprintf("Hi %s,\n\nWe received a request to reset the password for %s. "+
+		"Open the link below to choose a new one. It can be used once and expires soon.\n\n%s\n\n"+
+		"If you did not ask for this, you can ignore this email; your password has not changed.\n",
+		displayName(user), user.Email, link)
+	return s.send(ctx, user, "Reset your password", body)
+}
+
+func (s *notificationService) SendPasswordChangedNotification(ctx context.Context, user *domain.User) error {
+	body := fmt.Sprintf("Hi %s,\n\nThe password for %s was just changed and every signed-in session was signed out.\n\n"+
+		"If this wasn't you, reset your password at %s/forgot-password and contact support.\n",
+		displayName(user), user.Email, s.frontendURL)
+	return s.send(ctx, user, "Your password was changed", body)
+}
+
+func (s *notificationService) SendWorkspaceInvitation(ctx context.Context, email string, inviter *domain.User, workspace *domain.Workspace, token string) error {
+	link := fmt.Sprintf("%s/workspaces/accept?token=%s", s.frontendURL, url.QueryEscape(token))
+	body := fmt.Sprintf("Hi,\n\n%s (%s) invited you to the %q workspace on URL Shortener, where members share links.\n\n"+
+		"Sign in or create an account with this email address, then open the link below to join. "+
+		"It expires in %d days.\n\n%s\n",
+		displayName(inviter), inviter.Email, workspace.Name, int(domain.WorkspaceInvitationTTL.Hours()/24), link)
+	return s.mailer.Send(ctx, Message{To: email, Subject: fmt.Sprintf("Join %s on URL Shortener", workspace.Name), Body: body})
+}
+
+func (s *notificationService) SendAnalyticsDigest(ctx context.Context, user *domain.User, digest *domain.AnalyticsDigest) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hi %s,\n\nHere is your %s summary: %d clicks across %d links.\n",
+		displayName(user), digest.Period, digest.TotalClicks, digest.TotalURLs)
+	if digest.Summary != "" {
+		fmt.Fprintf(&b, "\n%s\n", digest.Summary)
+	}
+	if len(digest.TopURLs) > 0 {
+		b.WriteString("\nTop links:\n")
+		for _, top := range digest.TopURLs {
+			fmt.Fprintf(&b, "  %s  %d clicks  %s\n", top.ShortCode, top.ClickCount, top.OriginalURL)
+		}
+	}
+	return s.send(ctx, user, fmt.Sprintf("Your %s link summary", digest.Period), b.String())
+}
+
+func (s *notificationService) SendClickAlert(ctx context.Context, user *domain.User, alert *domain.ClickAlert) error {
+	body := fmt.Sprintf("Hi %s,\n\nYour link %s (%s) has reached %d clicks (alert threshold %d).\n",
+		displayName(user), alert.ShortCode, alert.OriginalURL, alert.CurrentCount, alert.Threshold)
+	return s.send(ctx, user, fmt.Sprintf("Click alert for %s", alert.ShortCode), body)
+}
+
+func (s *notificationService) SendMaintenanceNotification(ctx context.Context, users []*domain.User, message string) error {
+	var failed int
+	for _, user := range users {
+		body := fmt.Sprintf("Hi %s,\n\n%s\n", displayName(user), message)
+		if err := s.send(ctx, user, "Scheduled maintenance", body); err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to send maintenance notification to %d of %d users", failed, len(users))
+	}
+	return nil
+}
+
+func (s *notificationService) SendSecurityAlert(ctx context.Context, user *domain.User, alert *domain.SecurityAlert) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hi %s,\n\n%s\n", displayName(user), alert.Description)
+	if alert.IPAddress != "" {
+		fmt.Fprintf(&b, "\nIP address: %s\n", alert.IPAddress)
+	}
+	if alert.Location != "" {
+		fmt.Fprintf(&b, "Location: %s\n", alert.Location)
+	}
+	fmt.Fprintf(&b, "Time: %s\n", alert.TriggeredAt.UTC().Format("2006-01-02 15:04 MST"))
+	return s.send(ctx, user, "Security alert", b.String())
+}
+
+func (s *notificationService) send(ctx context.Context, user *domain.User, subject, body string) error {
+	return s.mailer.Send(ctx, Message{To: user.Email, Subject: subject, Body: body})
+}
+
+func displayName(user *domain.User) string {
+	if user.FirstName != "" {
+		return user.FirstName
+	}
+	return user.Email
+}
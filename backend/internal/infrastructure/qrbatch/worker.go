@@ -0,0 +1,115 @@
+package qrbatch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"url-shortener/internal/config"
+	"url-shortener/internal/core/ports"
+)
+
+const defaultPollInterval = 2 * time.Second
+
+// worker renders QR batches one at a time, oldest first, and deletes archives once
+// they expire. Several workers may run against one database; each batch is claimed
+// by only one of them.
+type worker struct {
+	processor ports.QRBatchProcessor
+	cfg       config.QRBatchConfig
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func NewWorker(processor ports.QRBatchProcessor, cfg config.QRBatchConfig) ports.QRBatchWorker {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &worker{
+		processor: processor,
+		cfg:       cfg,
+		ctx:       ctx,
+		cancel:    cancel,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start runs the worker in the background until Close is called
+func (w *worker) Start() {
+	w.startOnce.Do(func() {
+		go w.loop()
+	})
+}
+
+// Close lets the batch in progress finish, cancelling it if ctx expires first
+func (w *worker) Close(ctx context.Context) error {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+
+	started := true
+	w.startOnce.Do(func() {
+		started = false
+		close(w.done)
+	})
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-w.done:
+		w.cancel()
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		<-w.done
+		return fmt.Errorf("QR batch worker did not stop in time: %w", ctx.Err())
+	}
+}
+
+func (w *worker) loop() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if expired, err := w.processor.ExpireBatches(w.ctx); err != nil && w.ctx.Err() == nil {
+			log.Printf("Failed to expire QR batch archives: %v", err)
+		} else if expired > 0 {
+			log.Printf("Expired %d QR batch archives", expired)
+		}
+
+		// Work through the queue before waiting again
+		for {
+			processed, err := w.processor.ProcessNextBatch(w.ctx)
+			if err != nil && w.ctx.Err() == nil {
+				log.Printf("QR batch processing failed: %v", err)
+			}
+			if !processed {
+				break
+			}
+			select {
+			case <-w.stop:
+				return
+			default:
+			}
+		}
+
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package qrbatch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"url-shortener/internal/config"
+)
+
+// fakeProcessor counts down a queue of waiting batches
+type fakeProcessor struct {
+	mu        sync.Mutex
+	waiting   int
+	processed int
+	expiries  int
+	failNext  bool
+	block     chan struct{}
+}
+
+func (p *fakeProcessor) ProcessNextBatch(ctx context.Context) (bool, error) {
+	if p.block != nil {
+		select {
+		case <-p.block:
+		case <-ctx.Done():
+			return true, ctx.Err()
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.waiting == 0 {
+		return false, nil
+	}
+	p.waiting--
+	p.processed++
+	if p.failNext {
+		p.failNext = false
+		return true, errors.New("none of the 3 QR codes could be rendered")
+	}
+	return true, nil
+}
+
+func (p *fakeProcessor) ExpireBatches(ctx context.Context) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.expiries++
+	return 0, nil
+}
+
+func (p *fakeProcessor) counts() (int, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.processed, p.expiries
+}
+
+func TestWorker_DrainsQueueWithoutWaiting(t *testing.T) {
+	processor := &fakeProcessor{waiting: 3, failNext: true}
+	w := NewWorker(processor, config.QRBatchConfig{PollInterval: time.Hour})
+
+	// A failed batch does not hold up the rest
+	w.Start()
+	require.Eventually(t, func() bool {
+		processed, expiries := processor.counts()
+		return processed == 3 && expiries == 1
+	}, time.Second, 5*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, w.Close(ctx))
+	assert.NoError(t, w.Close(ctx))
+}
+
+func TestWorker_PollsForNewBatches(t *testing.T) {
+	processor := &fakeProcessor{}
+	w := NewWorker(processor, config.QRBatchConfig{PollInterval: 10 * time.Millisecond})
+
+	w.Start()
+	defer w.Close(context.Background())
+	require.Eventually(t, func() bool {
+		_, expiries := processor.counts()
+		return expiries > 1
+	}, time.Second, 5*time.Millisecond)
+
+	processor.mu.Lock()
+	processor.waiting = 1
+	processor.mu.Unlock()
+	require.Eventually(t, func() bool {
+		processed, _ := processor.counts()
+		return processed == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestWorker_CloseCancelsBatchAfterTimeout(t *testing.T) {
+	processor := &fakeProcessor{waiting: 1, block: make(chan struct{})}
+	w := NewWorker(processor, config.QRBatchConfig{PollInterval: time.Hour})
+	w.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.Close(ctx), context.DeadlineExceeded)
+}
+
+func TestWorker_CloseWithoutStart(t *testing.T) {
+	w := NewWorker(&fakeProcessor{}, config.QRBatchConfig{})
+	assert.NoError(t, w.Close(context.Background()))
+}
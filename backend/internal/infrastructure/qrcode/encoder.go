@@ -0,0 +1,583 @@
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Level is the error correction level. Higher levels survive more damage at
+// the cost of a larger symbol.
+type Level int
+
+const (
+	LevelL Level = iota // recovers about 7% of codewords
+	LevelM              // about 15%
+	LevelQ              // about 25%
+	LevelH              // about 30%
+)
+
+const (
+	minVersion = 1
+	maxVersion = 40
+)
+
+// ErrDataTooLong is returned when the text does not fit a version 40 symbol
+var ErrDataTooLong = errors.New("data too long for a QR code")
+
+// Format information encodes the levels in this order rather than L, M, Q, H
+var levelFormatBits = [4]int{1, 0, 3, 2}
+
+// Characters of the alphanumeric mode, in code value order
+const alphanumericCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+type mode struct {
+	indicator int
+	// Character count bits for versions 1-9, 10-26 and 27-40
+	countBits [3]int
+}
+
+var (
+	modeAlphanumeric = mode{indicator: 0x2, countBits: [3]int{9, 11, 13}}
+	modeByte         = mode{indicator: 0x4, countBits: [3]int{8, 16, 16}}
+)
+
+func (m mode) characterCountBits(version int) int {
+	switch {
+	case version <= 9:
+		return m.countBits[0]
+	case version <= 26:
+		return m.countBits[1]
+	}
+	return m.countBits[2]
+}
+
+// Code is an encoded QR symbol: a square of dark and light modules without the
+// quiet zone around it
+type Code struct {
+	Version int
+	Level   Level
+	Mask    int
+
+	size     int
+	modules  [][]bool
+	function [][]bool // finder, timing, alignment, format and version modules
+}
+
+// Encode picks the smallest version that holds text at the given level and the
+// mask with the lowest penalty. Text made only of digits, upper case letters
+// and " $%*+-./:" uses the denser alphanumeric mode, anything else is encoded
+// as bytes.
+func Encode(text string, level Level) (*Code, error) {
+	return encode(text, level, minVersion)
+}
+
+// encode is Encode with a lower bound on the version
+func encode(text string, level Level, smallestVersion int) (*Code, error) {
+	if level < LevelL || level > LevelH {
+		return nil, fmt.Errorf("invalid error correction level %d", level)
+	}
+
+	m, dataBits := encodeSegment(text)
+	version, err := chooseVersion(m, len(text), len(dataBits), level, smallestVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mode, character count, data, then terminator and padding up to capacity
+	var bb bitBuffer
+	bb.append(m.indicator, 4)
+	bb.append(len(text), m.characterCountBits(version))
+	bb = append(bb, dataBits...)
+	capacity := dataCodewords(version, level) * 8
+	bb.append(0, min(4, capacity-len(bb)))
+	bb.append(0, (8-len(bb)%8)%8)
+	for pad := 0xec; len(bb) < capacity; pad ^= 0xec ^ 0x11 {
+		bb.append(pad, 8)
+	}
+
+	code := newCode(version, level)
+	code.drawCodewords(addErrorCorrection(bb.bytes(), version, level))
+	code.applyBestMask()
+	return code, nil
+}
+
+// Size is the width of the symbol in modules
+func (c *Code) Size() int {
+	return c.size
+}
+
+// Dark reports whether the module at column x, row y is dark
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+func encodeSegment(text string) (mode, bitBuffer) {
+	var bb bitBuffer
+	if text != "" && strings.Trim(text, alphanumericCharset) == "" {
+		// Pairs of characters take 11 bits, a trailing single one 6
+		for i := 0; i+1 < len(text); i += 2 {
+			value := strings.IndexByte(alphanumericCharset, text[i])*45 + strings.IndexByte(alphanumericCharset, text[i+1])
+			bb.append(value, 11)
+		}
+		if len(text)%2 == 1 {
+			bb.append(strings.IndexByte(alphanumericCharset, text[len(text)-1]), 6)
+		}
+		return modeAlphanumeric, bb
+	}
+
+	for i := 0; i < len(text); i++ {
+		bb.append(int(text[i]), 8)
+	}
+	return modeByte, bb
+}
+
+func chooseVersion(m mode, characters, dataBits int, level Level, smallestVersion int) (int, error) {
+	for version := smallestVersion; version <= maxVersion; version++ {
+		countBits := m.characterCountBits(version)
+		if characters >= 1<<countBits {
+			continue
+		}
+		if 4+countBits+dataBits <= dataCodewords(version, level)*8 {
+			return version, nil
+		}
+	}
+	return 0, ErrDataTooLong
+}
+
+// addErrorCorrection splits data into blocks, appends each block's error
+// correction codewords and interleaves the blocks
+func addErrorCorrection(data []byte, version int, level Level) []byte {
+	numBlocks := eccBlocks[level][version]
+	eccLen := eccCodewordsPerBlock[level][version]
+	rawCodewords := rawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := rsDivisor(eccLen)
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		dataLen := shortBlockLen - eccLen
+		if i >= numShortBlocks {
+			dataLen++
+		}
+		block := make([]byte, 0, shortBlockLen+1)
+		block = append(block, data[k:k+dataLen]...)
+		k += dataLen
+		ecc := rsRemainder(block, divisor)
+		if i < numShortBlocks {
+			// Placeholder so every block has the same length; skipped below
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortBlockLen-eccLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// newCode draws the function patterns of a version and marks them so data
+// placement and masking leave them alone
+func newCode(version int, level Level) *Code {
+	size := version*4 + 17
+	c := &Code{Version: version, Level: level, size: size}
+	c.modules = make([][]bool, size)
+	c.function = make([][]bool, size)
+	for i := range c.modules {
+		c.modules[i] = make([]bool, size)
+		c.function[i] = make([]bool, size)
+	}
+
+	for i := 0; i < size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	c.drawFinder(3, 3)
+	c.drawFinder(size-4, 3)
+	c.drawFinder(3, size-4)
+
+	positions := alignmentPositions(version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// The corners already hold finder patterns
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			c.drawAlignment(x, y)
+		}
+	}
+
+	// Reserve the format modules; the real bits go in once the mask is chosen
+	c.drawFormat(0)
+	c.drawVersion()
+	return c
+}
+
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+// drawFinder draws a finder pattern centred on x, y together with its light separator
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= c.size || yy < 0 || yy >= c.size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (c *Code) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// formatBits is the 15-bit BCH-protected level and mask, masked with 0x5412
+func formatBits(level Level, mask int) int {
+	data := levelFormatBits[level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// versionBits is the 18-bit BCH-protected version number carried by versions 7 and up
+func versionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+	}
+	return version<<12 | rem
+}
+
+// drawFormat writes both copies of the format information
+func (c *Code) drawFormat(mask int) {
+	bits := formatBits(c.Level, mask)
+	bit := func(i int) bool { return (bits>>uint(i))&1 != 0 }
+
+	// Around the top left finder
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(i))
+	}
+	c.setFunction(8, 7, bit(6))
+	c.setFunction(8, 8, bit(7))
+	c.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(i))
+	}
+
+	// Split between the other two finders
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.size-15+i, bit(i))
+	}
+	c.setFunction(8, c.size-8, true) // always dark
+}
+
+func (c *Code) drawVersion() {
+	if c.Version < 7 {
+		return
+	}
+	bits := versionBits(c.Version)
+	for i := 0; i < 18; i++ {
+		dark := (bits>>uint(i))&1 != 0
+		a, b := c.size-11+i%3, i/3
+		c.setFunction(a, b, dark)
+		c.setFunction(b, a, dark)
+	}
+}
+
+// forEachDataModule visits the non-function modules in placement order: the
+// standard zigzag, two columns at a time from the bottom right, skipping the
+// vertical timing pattern
+func (c *Code) forEachDataModule(visit func(x, y int)) {
+	for right := c.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.size; vert++ {
+			y := vert
+			if upward {
+				y = c.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				if x := right - j; !c.function[y][x] {
+					visit(x, y)
+				}
+			}
+		}
+	}
+}
+
+func (c *Code) drawCodewords(codewords []byte) {
+	i := 0
+	c.forEachDataModule(func(x, y int) {
+		// Remainder bits past the last codeword stay light
+		if i < len(codewords)*8 {
+			c.modules[y][x] = (codewords[i>>3]>>uint(7-i&7))&1 != 0
+		}
+		i++
+	})
+}
+
+// interleavedBlocks names the block each codeword of the interleaved sequence
+// belongs to, mirroring addErrorCorrection
+func interleavedBlocks(version int, level Level) []int {
+	numBlocks := eccBlocks[level][version]
+	eccLen := eccCodewordsPerBlock[level][version]
+	rawCodewords := rawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	result := make([]int, 0, rawCodewords)
+	for i := 0; i <= shortBlockLen; i++ {
+		for j := 0; j < numBlocks; j++ {
+			if i != shortBlockLen-eccLen || j >= numShortBlocks {
+				result = append(result, j)
+			}
+		}
+	}
+	return result
+}
+
+// Share of each block's correction capacity a logo may use up; the rest is
+// left for smudges, creases and glare
+const (
+	logoDamageNumerator   = 4
+	logoDamageDenominator = 5
+)
+
+// CanCover reports whether the modules in columns x0 to x1 and rows y0 to y1,
+// exclusive, can be hidden under a logo and the symbol still decode. The area
+// must leave finder, timing, format and version modules alone, and every
+// error correction block must be able to correct the codewords it destroys.
+func (c *Code) CanCover(x0, y0, x1, y1 int) bool {
+	if x0 < 0 || y0 < 0 || x1 > c.size || y1 > c.size {
+		return false
+	}
+
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			if c.function[y][x] && !c.inAlignment(x, y) {
+				return false
+			}
+		}
+	}
+
+	blocks := interleavedBlocks(c.Version, c.Level)
+	damaged := make(map[int]bool)
+	i := 0
+	c.forEachDataModule(func(x, y int) {
+		if x >= x0 && x < x1 && y >= y0 && y < y1 && i/8 < len(blocks) {
+			damaged[i/8] = true
+		}
+		i++
+	})
+
+	perBlock := make([]int, eccBlocks[c.Level][c.Version])
+	for codeword := range damaged {
+		perBlock[blocks[codeword]]++
+	}
+	capacity := eccCodewordsPerBlock[c.Level][c.Version] / 2
+	for _, count := range perBlock {
+		if count*logoDamageDenominator > capacity*logoDamageNumerator {
+			return false
+		}
+	}
+	return true
+}
+
+// inAlignment reports whether a module belongs to an alignment pattern. A logo
+// may hide the one in the middle; scanners locate the symbol by its finders.
+func (c *Code) inAlignment(x, y int) bool {
+	positions := alignmentPositions(c.Version)
+	last := len(positions) - 1
+	for i, cx := range positions {
+		for j, cy := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			if abs(x-cx) <= 2 && abs(y-cy) <= 2 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func maskApplies(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// applyMask flips the data modules the mask selects; applying it twice undoes it
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if !c.function[y][x] && maskApplies(mask, x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+func (c *Code) applyBestMask() {
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormat(mask)
+		if penalty := c.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		c.applyMask(mask)
+	}
+	c.Mask = best
+	c.applyMask(best)
+	c.drawFormat(best)
+}
+
+// penalty scores the symbol by the four rules of the standard: long runs of one
+// colour, 2x2 blocks, finder-like patterns and an unbalanced dark ratio
+func (c *Code) penalty() int {
+	const (
+		runPenalty     = 3
+		blockPenalty   = 3
+		finderPenalty  = 40
+		balancePenalty = 10
+	)
+
+	result := 0
+	line := make([]bool, c.size)
+	for _, vertical := range []bool{false, true} {
+		for i := 0; i < c.size; i++ {
+			for j := 0; j < c.size; j++ {
+				if vertical {
+					line[j] = c.modules[j][i]
+				} else {
+					line[j] = c.modules[i][j]
+				}
+			}
+			result += linePenalty(line, runPenalty, finderPenalty)
+		}
+	}
+
+	dark := 0
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x+1 < c.size && y+1 < c.size {
+				m := c.modules[y][x]
+				if m == c.modules[y][x+1] && m == c.modules[y+1][x] && m == c.modules[y+1][x+1] {
+					result += blockPenalty
+				}
+			}
+		}
+	}
+
+	// Every 5% away from half dark costs another 10 points
+	total := c.size * c.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return result + k*balancePenalty
+}
+
+// Dark-light-dark-dark-dark-light-dark with four light modules on one side
+var finderLikePatterns = [2][11]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+func linePenalty(line []bool, runPenalty, finderPenalty int) int {
+	result := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			result += runPenalty + run - 5
+		}
+		run = 1
+	}
+
+	for i := 0; i+11 <= len(line); i++ {
+		for _, pattern := range finderLikePatterns {
+			matched := true
+			for j, dark := range pattern {
+				if line[i+j] != dark {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				result += finderPenalty
+			}
+		}
+	}
+	return result
+}
+
+// bitBuffer collects bits most significant first
+type bitBuffer []bool
+
+func (bb *bitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*bb = append(*bb, (value>>uint(i))&1 != 0)
+	}
+}
+
+func (bb bitBuffer) bytes() []byte {
+	result := make([]byte, (len(bb)+7)/8)
+	for i, bit := range bb {
+		if bit {
+			result[i>>3] |= 1 << uint(7-i&7)
+		}
+	}
+	return result
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
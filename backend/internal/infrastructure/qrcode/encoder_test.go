@@ -0,0 +1,494 @@
+package qrcode
+
+import (
+	"math/bits"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReedSolomon_KnownVectors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		ecc  []byte
+	}{
+		{
+			// ISO/IEC 18004 annex I, "01234567" at 1-M
+			name: "numeric example",
+			data: []byte{0x10, 0x20, 0x0c, 0x56, 0x61, 0x80, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11},
+			ecc:  []byte{0xa5, 0x24, 0xd4, 0xc1, 0xed, 0x36, 0xc7, 0x87, 0x2c, 0x55},
+		},
+		{
+			name: "HELLO WORLD at 1-M",
+			data: []byte{0x20, 0x5b, 0x0b, 0x78, 0xd1, 0x72, 0xdc, 0x4d, 0x43, 0x40, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11},
+			ecc:  []byte{0xc4, 0x23, 0x27, 0x77, 0xeb, 0xd7, 0xe7, 0xe2, 0x5d, 0x17},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.ecc, rsRemainder(tt.data, rsDivisor(len(tt.ecc))))
+		})
+	}
+}
+
+func TestTables(t *testing.T) {
+	// Data codewords from the capacity table of the standard
+	capacities := map[int][4]int{
+		1:  {19, 16, 13, 9},
+		10: {274, 216, 154, 122},
+		20: {861, 669, 485, 385},
+		30: {1735, 1373, 985, 745},
+		40: {2956, 2334, 1666, 1276},
+	}
+	for version, want := range capacities {
+		for level := LevelL; level <= LevelH; level++ {
+			assert.Equal(t, want[level], dataCodewords(version, level), "version %d level %d", version, level)
+		}
+	}
+
+	assert.Nil(t, alignmentPositions(1))
+	assert.Equal(t, []int{6, 18}, alignmentPositions(2))
+	assert.Equal(t, []int{6, 22, 38}, alignmentPositions(7))
+	assert.Equal(t, []int{6, 34, 60, 86, 112, 138}, alignmentPositions(32))
+	assert.Equal(t, []int{6, 24, 50, 76, 102, 128, 154}, alignmentPositions(36))
+	assert.Equal(t, []int{6, 30, 58, 86, 114, 142, 170}, alignmentPositions(40))
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	assert.Equal(t, 0b111011111000100, formatBits(LevelL, 0))
+	assert.Equal(t, 0b101010000010010, formatBits(LevelM, 0))
+	assert.Equal(t, 0b011010101011111, formatBits(LevelQ, 0))
+	assert.Equal(t, 0b001011010001001, formatBits(LevelH, 0))
+	assert.Equal(t, 0b000111110010010100, versionBits(7))
+	assert.Equal(t, 0b101000110001101001, versionBits(40))
+}
+
+func TestEncode_HelloWorld(t *testing.T) {
+	code, err := Encode("HELLO WORLD", LevelM)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, code.Version)
+	assert.Equal(t, 21, code.Size())
+	decoded := decodeGrid(t, moduleGrid(code))
+	assert.Equal(t, "HELLO WORLD", decoded.text)
+	assert.Equal(t, LevelM, decoded.level)
+	assert.Equal(t, code.Mask, decoded.mask)
+	assert.Zero(t, decoded.corrected)
+	assert.Equal(t, []byte{
+		0x20, 0x5b, 0x0b, 0x78, 0xd1, 0x72, 0xdc, 0x4d, 0x43, 0x40, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11,
+		0xc4, 0x23, 0x27, 0x77, 0xeb, 0xd7, 0xe7, 0xe2, 0x5d, 0x17,
+	}, decoded.codewords)
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		level   Level
+		version int
+		mode    int
+	}{
+		{name: "short link", text: "https://sho.rt/abc123", level: LevelM, version: 2, mode: 0x4},
+		{name: "upper case link", text: "HTTPS://SHO.RT/ABC123", level: LevelQ, version: 2, mode: 0x2},
+		{name: "odd alphanumeric length", text: "ABC", level: LevelH, version: 1, mode: 0x2},
+		{name: "UTF-8 bytes", text: "https://sho.rt/café", level: LevelL, version: 2, mode: 0x4},
+		{name: "version 7 carries version bits", text: strings.Repeat("x", 120), level: LevelM, version: 7, mode: 0x4},
+		{name: "two block sizes", text: strings.Repeat("y", 300), level: LevelQ, version: 16, mode: 0x4},
+		{name: "16-bit character count", text: strings.Repeat("z", 400), level: LevelL, version: 13, mode: 0x4},
+		{name: "largest byte symbol", text: strings.Repeat("a", 2953), level: LevelL, version: 40, mode: 0x4},
+		{name: "largest alphanumeric symbol at H", text: strings.Repeat("A", 1852), level: LevelH, version: 40, mode: 0x2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Encode(tt.text, tt.level)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.version, code.Version)
+			decoded := decodeGrid(t, moduleGrid(code))
+			assert.Equal(t, tt.text, decoded.text)
+			assert.Equal(t, tt.level, decoded.level)
+			assert.Equal(t, tt.mode, decoded.mode)
+			assert.Zero(t, decoded.corrected)
+		})
+	}
+}
+
+func TestEncode_EveryVersion(t *testing.T) {
+	for version := minVersion; version <= maxVersion; version++ {
+		level := Level(version % 4)
+		// Exactly fills the version in byte mode
+		length := (dataCodewords(version, level)*8 - 4 - modeByte.characterCountBits(version)) / 8
+		text := strings.Repeat("q", length)
+
+		code, err := Encode(text, level)
+		require.NoError(t, err)
+		require.Equal(t, version, code.Version)
+		assert.Equal(t, text, decodeGrid(t, moduleGrid(code)).text, "version %d", version)
+	}
+}
+
+func TestEncode_Errors(t *testing.T) {
+	_, err := Encode(strings.Repeat("a", 2954), LevelL)
+	assert.ErrorIs(t, err, ErrDataTooLong)
+
+	_, err = Encode("https://sho.rt/abc", Level(4))
+	assert.Error(t, err)
+}
+
+func TestEncode_PicksLowestPenaltyMask(t *testing.T) {
+	code, err := Encode("https://sho.rt/abc123", LevelM)
+	require.NoError(t, err)
+
+	chosen := code.penalty()
+	for mask := 0; mask < 8; mask++ {
+		if mask == code.Mask {
+			continue
+		}
+		// Switch to the other mask, score it, then switch back
+		code.applyMask(code.Mask)
+		code.applyMask(mask)
+		code.drawFormat(mask)
+		assert.GreaterOrEqual(t, code.penalty(), chosen, "mask %d", mask)
+		code.applyMask(mask)
+		code.applyMask(code.Mask)
+		code.drawFormat(code.Mask)
+	}
+}
+
+func TestCorrectErrors(t *testing.T) {
+	data := []byte{0x20, 0x5b, 0x0b, 0x78, 0xd1, 0x72, 0xdc, 0x4d, 0x43, 0x40, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11}
+	block := append(append([]byte(nil), data...), rsRemainder(data, rsDivisor(10))...)
+	original := append([]byte(nil), block...)
+
+	// Five errors is all ten error correction codewords can fix
+	for _, i := range []int{0, 3, 9, 17, 25} {
+		block[i] ^= 0x5a
+	}
+	corrected, ok := correctErrors(block, 10)
+	require.True(t, ok)
+	assert.Equal(t, 5, corrected)
+	assert.Equal(t, original, block)
+
+	for _, i := range []int{0, 3, 9, 12, 17, 25} {
+		block[i] ^= 0x33
+	}
+	_, ok = correctErrors(block, 10)
+	assert.False(t, ok)
+}
+
+func moduleGrid(code *Code) [][]bool {
+	grid := make([][]bool, code.Size())
+	for y := range grid {
+		grid[y] = make([]bool, code.Size())
+		for x := range grid[y] {
+			grid[y][x] = code.Dark(x, y)
+		}
+	}
+	return grid
+}
+
+type decodedSymbol struct {
+	text      string
+	level     Level
+	mask      int
+	mode      int
+	codewords []byte // interleaved, as placed in the symbol
+	corrected int    // codewords Reed-Solomon had to fix
+}
+
+// decodeGrid reads a symbol the way a scanner does once it has the modules:
+// format information, unmasking, the zigzag, de-interleaving, Reed-Solomon
+// error correction of every block and the segment header
+func decodeGrid(t *testing.T, grid [][]bool) decodedSymbol {
+	t.Helper()
+	size := len(grid)
+	require.Equal(t, 1, size%4, "symbol width %d", size)
+	version := (size - 17) / 4
+
+	// Format information around the top left finder, bit 14 first
+	var first, second int
+	for _, p := range [][2]int{{0, 8}, {1, 8}, {2, 8}, {3, 8}, {4, 8}, {5, 8}, {7, 8}, {8, 8}, {8, 7}, {8, 5}, {8, 4}, {8, 3}, {8, 2}, {8, 1}, {8, 0}} {
+		first <<= 1
+		if grid[p[1]][p[0]] {
+			first |= 1
+		}
+	}
+	// and its copy beside the other two
+	for i := 0; i < 7; i++ {
+		second <<= 1
+		if grid[size-1-i][8] {
+			second |= 1
+		}
+	}
+	for i := 0; i < 8; i++ {
+		second <<= 1
+		if grid[8][size-8+i] {
+			second |= 1
+		}
+	}
+	require.Equal(t, first, second, "format information copies differ")
+	require.True(t, grid[size-8][8], "dark module missing")
+
+	var level Level
+	var mask int
+	found := false
+	for l := LevelL; l <= LevelH && !found; l++ {
+		for m := 0; m < 8; m++ {
+			if bits.OnesCount(uint(formatBits(l, m)^first)) == 0 {
+				level, mask, found = l, m, true
+				break
+			}
+		}
+	}
+	require.True(t, found, "format information %015b is not a valid codeword", first)
+
+	if version >= 7 {
+		var topRight, bottomLeft int
+		for i := 17; i >= 0; i-- {
+			topRight <<= 1
+			bottomLeft <<= 1
+			if grid[i/3][size-11+i%3] {
+				topRight |= 1
+			}
+			if grid[size-11+i%3][i/3] {
+				bottomLeft |= 1
+			}
+		}
+		require.Equal(t, versionBits(version), topRight)
+		require.Equal(t, versionBits(version), bottomLeft)
+	}
+
+	// Undo the mask; i is the row and j the column, as in the standard
+	masks := []func(i, j int) bool{
+		func(i, j int) bool { return (i+j)%2 == 0 },
+		func(i, j int) bool { return i%2 == 0 },
+		func(i, j int) bool { return j%3 == 0 },
+		func(i, j int) bool { return (i+j)%3 == 0 },
+		func(i, j int) bool { return (i/2+j/3)%2 == 0 },
+		func(i, j int) bool { return (i*j)%2+(i*j)%3 == 0 },
+		func(i, j int) bool { return ((i*j)%2+(i*j)%3)%2 == 0 },
+		func(i, j int) bool { return ((i+j)%2+(i*j)%3)%2 == 0 },
+	}
+	reserved := newCode(version, level).function
+	var stream []bool
+	upward := true
+	for col := size - 1; col > 0; col -= 2 {
+		if col == 6 {
+			col--
+		}
+		for k := 0; k < size; k++ {
+			row := k
+			if upward {
+				row = size - 1 - k
+			}
+			for _, c := range []int{col, col - 1} {
+				if !reserved[row][c] {
+					stream = append(stream, grid[row][c] != masks[mask](row, c))
+				}
+			}
+		}
+		upward = !upward
+	}
+	rawCodewords := rawDataModules(version) / 8
+	require.GreaterOrEqual(t, len(stream), rawCodewords*8)
+	codewords := bitBuffer(stream[:rawCodewords*8]).bytes()
+
+	// De-interleave: data codewords round robin, the short blocks ending one early, then the error correction
+	numBlocks := eccBlocks[level][version]
+	eccLen := eccCodewordsPerBlock[level][version]
+	numShort := numBlocks - rawCodewords%numBlocks
+	shortData := rawCodewords/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	next := 0
+	for i := 0; i <= shortData; i++ {
+		for b := range blocks {
+			if i < shortData || b >= numShort {
+				blocks[b] = append(blocks[b], codewords[next])
+				next++
+			}
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], codewords[next])
+			next++
+		}
+	}
+
+	decoded := decodedSymbol{level: level, mask: mask, codewords: append([]byte(nil), codewords...)}
+	var data []byte
+	for b, block := range blocks {
+		corrected, ok := correctErrors(block, eccLen)
+		require.True(t, ok, "block %d has more errors than it can correct", b)
+		decoded.corrected += corrected
+		data = append(data, block[:len(block)-eccLen]...)
+	}
+
+	reader := bitReader{data: data}
+	decoded.mode = reader.read(4)
+	switch decoded.mode {
+	case 0x2:
+		count := reader.read(modeAlphanumeric.characterCountBits(version))
+		var text []byte
+		for ; count >= 2; count -= 2 {
+			pair := reader.read(11)
+			text = append(text, alphanumericCharset[pair/45], alphanumericCharset[pair%45])
+		}
+		if count == 1 {
+			text = append(text, alphanumericCharset[reader.read(6)])
+		}
+		decoded.text = string(text)
+	case 0x4:
+		count := reader.read(modeByte.characterCountBits(version))
+		text := make([]byte, count)
+		for i := range text {
+			text[i] = byte(reader.read(8))
+		}
+		decoded.text = string(text)
+	default:
+		t.Fatalf("unexpected mode indicator %x", decoded.mode)
+	}
+	return decoded
+}
+
+// correctErrors fixes up to eccLen/2 wrong codewords in place with
+// Berlekamp-Massey, a Chien search and Forney's formula, and returns how many
+// it fixed
+func correctErrors(block []byte, eccLen int) (int, bool) {
+	syndromes := make([]byte, eccLen)
+	clean := true
+	for i := range syndromes {
+		syndromes[i] = evaluate(block, gfPow(i))
+		clean = clean && syndromes[i] == 0
+	}
+	if clean {
+		return 0, true
+	}
+
+	// Error locator polynomial, lowest degree first
+	locator, previous := []byte{1}, []byte{1}
+	length, shift := 0, 1
+	var lastDiscrepancy byte = 1
+	for n := 0; n < eccLen; n++ {
+		d := syndromes[n]
+		for i := 1; i <= length && i < len(locator); i++ {
+			d ^= gfMultiply(locator[i], syndromes[n-i])
+		}
+		if d == 0 {
+			shift++
+			continue
+		}
+		factor := gfMultiply(d, gfInverse(lastDiscrepancy))
+		next := make([]byte, max(len(locator), len(previous)+shift))
+		copy(next, locator)
+		for i, c := range previous {
+			next[i+shift] ^= gfMultiply(factor, c)
+		}
+		if 2*length <= n {
+			previous, locator = locator, next
+			length = n + 1 - length
+			lastDiscrepancy = d
+			shift = 1
+		} else {
+			locator = next
+			shift++
+		}
+	}
+	if 2*length > eccLen {
+		return 0, false
+	}
+
+	// Error evaluator: syndromes times locator, modulo x^eccLen
+	evaluator := make([]byte, eccLen)
+	for i, syndrome := range syndromes {
+		for j, coefficient := range locator {
+			if i+j < eccLen {
+				evaluator[i+j] ^= gfMultiply(syndrome, coefficient)
+			}
+		}
+	}
+
+	found := 0
+	for k := range block {
+		// Codeword k is the coefficient of x^(n-1-k)
+		position := gfPow(len(block) - 1 - k)
+		inverse := gfInverse(position)
+		if evaluateLow(locator, inverse) != 0 {
+			continue
+		}
+		// The formal derivative keeps the odd terms
+		var derivative byte
+		for i := 1; i < len(locator); i += 2 {
+			derivative ^= gfMultiply(locator[i], gfPowOf(inverse, i-1))
+		}
+		block[k] ^= gfMultiply(position, gfMultiply(evaluateLow(evaluator, inverse), gfInverse(derivative)))
+		found++
+	}
+	if found != length {
+		return 0, false
+	}
+	for i := 0; i < eccLen; i++ {
+		if evaluate(block, gfPow(i)) != 0 {
+			return 0, false
+		}
+	}
+	return found, true
+}
+
+// evaluateLow computes a polynomial, lowest degree first, at x
+func evaluateLow(poly []byte, x byte) byte {
+	var result byte
+	for i := len(poly) - 1; i >= 0; i-- {
+		result = gfMultiply(result, x) ^ poly[i]
+	}
+	return result
+}
+
+func gfPowOf(x byte, n int) byte {
+	var result byte = 1
+	for i := 0; i < n; i++ {
+		result = gfMultiply(result, x)
+	}
+	return result
+}
+
+// gfInverse uses x^255 = 1
+func gfInverse(x byte) byte {
+	return gfPowOf(x, 254)
+}
+
+// evaluate computes the codeword polynomial, highest degree first, at x
+func evaluate(poly []byte, x byte) byte {
+	var result byte
+	for _, coefficient := range poly {
+		result = gfMultiply(result, x) ^ coefficient
+	}
+	return result
+}
+
+func gfPow(n int) byte {
+	var result byte = 1
+	for i := 0; i < n; i++ {
+		result = gfMultiply(result, 0x02)
+	}
+	return result
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) read(n int) int {
+	value := 0
+	for i := 0; i < n; i++ {
+		value = value<<1 | int(r.data[r.pos>>3]>>uint(7-r.pos&7)&1)
+		r.pos++
+	}
+	return value
+}
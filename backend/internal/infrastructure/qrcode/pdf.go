@@ -0,0 +1,184 @@
+package qrcode
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+)
+
+// renderPDF writes a one-page PDF, Size points square, that draws the symbol
+// with filled rectangles
+func renderPDF(code *Code, size, border int, foreground, background color.RGBA) []byte {
+	modules := code.Size() + 2*border
+
+	var content bytes.Buffer
+	writePDFBackground(&content, size, modules, background)
+	fmt.Fprintf(&content, "%s rg\n", pdfColor(foreground))
+	for _, r := range darkRuns(code, border) {
+		fmt.Fprintf(&content, "%d %d %d 1 re\n", r.x, r.y, r.width)
+	}
+	content.WriteString("f\n")
+
+	return writePDF(size, content.Bytes(), "", nil)
+}
+
+// writePDFBackground fills the page, then flips the y axis and scales so one
+// unit is one module, counted from the top left
+func writePDFBackground(content *bytes.Buffer, size, modules int, background color.RGBA) {
+	unit := strconv.FormatFloat(float64(size)/float64(modules), 'f', -1, 64)
+	fmt.Fprintf(content, "%s rg\n0 0 %d %d re f\n", pdfColor(background), size, size)
+	fmt.Fprintf(content, "%s 0 0 -%s 0 %d cm\n", unit, unit, size)
+}
+
+// writePDF assembles the document: the catalog, the page tree, the page and its
+// content stream as objects 1 to 4, any extra objects from 5 on, and the
+// cross-reference table the format requires
+func writePDF(size int, content []byte, resources string, extra [][]byte) []byte {
+	objects := [][]byte{
+		[]byte("<< /Type /Catalog /Pages 2 0 R >>"),
+		[]byte("<< /Type /Pages /Kids [3 0 R] /Count 1 >>"),
+		[]byte(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << %s>> /Contents 4 0 R >>", size, size, resources)),
+		pdfStream("", content),
+	}
+	objects = append(objects, extra...)
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n", i+1)
+		buf.Write(object)
+		buf.WriteString("\nendobj\n")
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// pdfStream wraps data in a stream object; dict holds any entries besides the length
+func pdfStream(dict string, data []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<< %s/Length %d >>\nstream\n", dict, len(data))
+	buf.Write(data)
+	buf.WriteString("\nendstream")
+	return buf.Bytes()
+}
+
+func pdfColor(c color.RGBA) string {
+	component := func(v uint8) string {
+		return strconv.FormatFloat(float64(v)/255, 'f', 3, 64)
+	}
+	return component(c.R) + " " + component(c.G) + " " + component(c.B)
+}
+
+// pdfPath collects outlines as PDF path operators
+type pdfPath struct {
+	bytes.Buffer
+}
+
+func (p *pdfPath) moveTo(x, y float64) {
+	fmt.Fprintf(p, "%s %s m\n", formatNumber(x), formatNumber(y))
+}
+
+func (p *pdfPath) lineTo(x, y float64) {
+	fmt.Fprintf(p, "%s %s l\n", formatNumber(x), formatNumber(y))
+}
+
+func (p *pdfPath) curveTo(x1, y1, x2, y2, x, y float64) {
+	fmt.Fprintf(p, "%s %s %s %s %s %s c\n", formatNumber(x1), formatNumber(y1), formatNumber(x2), formatNumber(y2), formatNumber(x), formatNumber(y))
+}
+
+func (p *pdfPath) closePath() {
+	p.WriteString("h\n")
+}
+
+// renderPDF fills the shaped modules with the even-odd rule. A gradient is an
+// axial shading clipped to the modules; the logo is an image with its alpha
+// channel as a soft mask.
+func (s *styledSymbol) renderPDF(size int) ([]byte, error) {
+	modules := s.code.Size() + 2*s.border
+
+	var content bytes.Buffer
+	writePDFBackground(&content, size, modules, s.background)
+
+	var path pdfPath
+	s.traceShapes(&path)
+	var resources string
+	var extra [][]byte
+	if g := s.gradient; g != nil {
+		content.WriteString("q\n")
+		content.Write(path.Bytes())
+		content.WriteString("W* n\n/Sh1 sh\nQ\n")
+		resources += "/Shading << /Sh1 5 0 R >> "
+		extra = append(extra, []byte(fmt.Sprintf(
+			"<< /ShadingType 2 /ColorSpace /DeviceRGB /Coords [%s %s %s %s] /Function << /FunctionType 2 /Domain [0 1] /C0 [%s] /C1 [%s] /N 1 >> /Extend [true true] >>",
+			formatNumber(g.x0), formatNumber(g.y0), formatNumber(g.x1), formatNumber(g.y1), pdfColor(g.from), pdfColor(g.to))))
+	} else {
+		fmt.Fprintf(&content, "%s rg\n", pdfColor(s.foreground))
+		content.Write(path.Bytes())
+		content.WriteString("f*\n")
+	}
+
+	if s.logo != nil {
+		samples, mask, err := pdfImage(s.logo)
+		if err != nil {
+			return nil, err
+		}
+		imageID := len(extra) + 5
+		resources += fmt.Sprintf("/XObject << /Im1 %d 0 R >> ", imageID)
+		bounds := s.logo.Bounds()
+		extra = append(extra,
+			pdfStream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /SMask %d 0 R ", bounds.Dx(), bounds.Dy(), imageID+1), samples),
+			pdfStream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode ", bounds.Dx(), bounds.Dy()), mask),
+		)
+		// Images fill the unit square bottom up, so flip them back
+		fmt.Fprintf(&content, "q\n%s 0 0 -%s %s %s cm\n/Im1 Do\nQ\n",
+			formatNumber(s.logoW), formatNumber(s.logoH), formatNumber(s.logoX), formatNumber(s.logoY+s.logoH))
+	}
+
+	return writePDF(size, content.Bytes(), resources, extra), nil
+}
+
+// pdfImage compresses the logo's colour samples and its alpha channel separately
+func pdfImage(img image.Image) ([]byte, []byte, error) {
+	bounds := img.Bounds()
+	samples := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	alpha := make([]byte, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			samples = append(samples, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+		}
+	}
+
+	compress := func(data []byte) ([]byte, error) {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	compressedSamples, err := compress(samples)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compress logo: %w", err)
+	}
+	compressedAlpha, err := compress(alpha)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compress logo: %w", err)
+	}
+	return compressedSamples, compressedAlpha, nil
+}
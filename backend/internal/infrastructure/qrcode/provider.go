@@ -0,0 +1,136 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+// High enough that module edges stay sharp for scanners
+const jpegQuality = 95
+
+type provider struct{}
+
+// NewProvider encodes QR codes and renders them as PNG, JPEG, SVG or PDF
+func NewProvider() ports.QRCodeProvider {
+	return &provider{}
+}
+
+func (p *provider) GenerateQRCode(url string, options domain.QRGenerationOptions) ([]byte, error) {
+	level := Level(options.ErrorCorrection)
+	if level < LevelL || level > LevelH {
+		return nil, fmt.Errorf("invalid error correction level %d", options.ErrorCorrection)
+	}
+	if options.Size <= 0 {
+		return nil, fmt.Errorf("invalid size %d", options.Size)
+	}
+	if options.Border < 0 {
+		return nil, fmt.Errorf("invalid border %d", options.Border)
+	}
+
+	var logo image.Image
+	if len(options.Logo) > 0 {
+		var err error
+		if logo, err = decodeLogo(options.Logo); err != nil {
+			return nil, err
+		}
+		// The logo hides modules; only the highest level has codewords to spare
+		level = LevelH
+	}
+
+	code, err := Encode(url, level)
+	if err != nil {
+		return nil, err
+	}
+
+	if isStyled(options) {
+		symbol, err := newStyledSymbol(code, logo, options)
+		// Small versions have few codewords per block; a larger one spreads the logo over more of them
+		for err == errLogoHidesTooMuch && code.Version < maxVersion {
+			if code, err = encode(url, level, code.Version+1); err != nil {
+				return nil, err
+			}
+			symbol, err = newStyledSymbol(code, logo, options)
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch options.Format {
+		case "svg":
+			return symbol.renderSVG(options.Size)
+		case "pdf":
+			return symbol.renderPDF(options.Size)
+		}
+		img, err := symbol.renderImage(options.Size)
+		if err != nil {
+			return nil, err
+		}
+		return encodeImage(img, options.Format)
+	}
+
+	// Vector formats scale freely; only raster images need whole pixels per module
+	switch options.Format {
+	case "svg":
+		return renderSVG(code, options.Size, options.Border, options.ForegroundColor, options.BackgroundColor), nil
+	case "pdf":
+		return renderPDF(code, options.Size, options.Border, options.ForegroundColor, options.BackgroundColor), nil
+	}
+
+	img, err := renderImage(code, options)
+	if err != nil {
+		return nil, err
+	}
+	return encodeImage(img, options.Format)
+}
+
+func encodeImage(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "", "png":
+		err = png.Encode(&buf, img)
+	case "jpeg", "jpg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	default:
+		return nil, fmt.Errorf("unsupported QR code format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// renderImage draws the symbol Size pixels square with Border light modules around
+// it. Modules are whole pixels; whatever does not divide evenly is split
+// between the margins.
+func renderImage(code *Code, options domain.QRGenerationOptions) (*image.Paletted, error) {
+	modules := code.Size() + 2*options.Border
+	scale := options.Size / modules
+	if scale < 1 {
+		return nil, fmt.Errorf("size %d is too small for a %d module QR code", options.Size, modules)
+	}
+	offset := (options.Size-scale*modules)/2 + options.Border*scale
+
+	palette := color.Palette{options.BackgroundColor, options.ForegroundColor}
+	img := image.NewPaletted(image.Rect(0, 0, options.Size, options.Size), palette)
+	for y := 0; y < code.Size(); y++ {
+		for x := 0; x < code.Size(); x++ {
+			if !code.Dark(x, y) {
+				continue
+			}
+			for py := 0; py < scale; py++ {
+				row := img.Pix[(offset+y*scale+py)*img.Stride:]
+				for px := 0; px < scale; px++ {
+					row[offset+x*scale+px] = 1
+				}
+			}
+		}
+	}
+	return img, nil
+}
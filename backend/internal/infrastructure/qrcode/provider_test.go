@@ -0,0 +1,172 @@
+package qrcode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"url-shortener/internal/core/domain"
+)
+
+func testOptions() domain.QRGenerationOptions {
+	return domain.QRGenerationOptions{
+		Size:            256,
+		Format:          "png",
+		ForegroundColor: color.RGBA{0, 0, 0, 255},
+		BackgroundColor: color.RGBA{255, 255, 255, 255},
+		ErrorCorrection: 1,
+		Border:          4,
+	}
+}
+
+func TestProvider_PNG(t *testing.T) {
+	options := testOptions()
+	options.ForegroundColor = color.RGBA{0x1a, 0x23, 0x7e, 255}
+	options.BackgroundColor = color.RGBA{0xff, 0xf8, 0xe1, 255}
+
+	data, err := NewProvider().GenerateQRCode("https://sho.rt/abc123", options)
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 256, 256), img.Bounds())
+
+	// A 25 module symbol plus the border is 33 modules of 7 pixels, with the
+	// 25 pixels left over split between the margins
+	assert.Equal(t, options.BackgroundColor, color.RGBAModel.Convert(img.At(0, 0)))
+	assert.Equal(t, options.BackgroundColor, color.RGBAModel.Convert(img.At(12+4*7-1, 12+4*7-1)))
+	assert.Equal(t, options.ForegroundColor, color.RGBAModel.Convert(img.At(12+4*7, 12+4*7)))
+
+	decoded := decodeGrid(t, readGrid(t, img, options))
+	assert.Equal(t, "https://sho.rt/abc123", decoded.text)
+	assert.Equal(t, LevelM, decoded.level)
+}
+
+func TestProvider_JPEG(t *testing.T) {
+	options := testOptions()
+	options.Format = "jpeg"
+	options.Size = 512
+	options.ErrorCorrection = 3
+
+	data, err := NewProvider().GenerateQRCode("https://sho.rt/summer-sale-2024", options)
+	require.NoError(t, err)
+
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 512, 512), img.Bounds())
+
+	decoded := decodeGrid(t, readGrid(t, img, options))
+	assert.Equal(t, "https://sho.rt/summer-sale-2024", decoded.text)
+	assert.Equal(t, LevelH, decoded.level)
+}
+
+func TestProvider_ErrorCorrectionLevels(t *testing.T) {
+	for level := LevelL; level <= LevelH; level++ {
+		options := testOptions()
+		options.ErrorCorrection = int(level)
+
+		data, err := NewProvider().GenerateQRCode("https://sho.rt/abc123", options)
+		require.NoError(t, err)
+		img, err := png.Decode(bytes.NewReader(data))
+		require.NoError(t, err)
+
+		assert.Equal(t, level, decodeGrid(t, readGrid(t, img, options)).level)
+	}
+}
+
+func TestProvider_Border(t *testing.T) {
+	for _, border := range []int{0, 2, 10} {
+		options := testOptions()
+		options.Border = border
+
+		data, err := NewProvider().GenerateQRCode("https://sho.rt/abc123", options)
+		require.NoError(t, err)
+		img, err := png.Decode(bytes.NewReader(data))
+		require.NoError(t, err)
+
+		scale := 256 / (25 + 2*border)
+		left, top, _ := findSymbol(t, img, options)
+		assert.Equal(t, (256-scale*(25+2*border))/2+border*scale, left, "border %d", border)
+		assert.Equal(t, left, top, "border %d", border)
+	}
+}
+
+func TestProvider_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*domain.QRGenerationOptions)
+	}{
+		{name: "unknown level", modify: func(o *domain.QRGenerationOptions) { o.ErrorCorrection = 4 }},
+		{name: "negative border", modify: func(o *domain.QRGenerationOptions) { o.Border = -1 }},
+		{name: "smaller than one pixel per module", modify: func(o *domain.QRGenerationOptions) { o.Size = 32 }},
+		{name: "unsupported format", modify: func(o *domain.QRGenerationOptions) { o.Format = "gif" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := testOptions()
+			tt.modify(&options)
+
+			_, err := NewProvider().GenerateQRCode("https://sho.rt/abc123", options)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// isDark tells foreground from background by whichever colour is nearer
+func isDark(c color.Color, options domain.QRGenerationOptions) bool {
+	distance := func(a color.Color, b color.RGBA) int {
+		r, g, bl, _ := a.RGBA()
+		dr, dg, db := int(r>>8)-int(b.R), int(g>>8)-int(b.G), int(bl>>8)-int(b.B)
+		return dr*dr + dg*dg + db*db
+	}
+	return distance(c, options.ForegroundColor) < distance(c, options.BackgroundColor)
+}
+
+// findSymbol locates the top left finder pattern: its corner and the width of one module
+func findSymbol(t *testing.T, img image.Image, options domain.QRGenerationOptions) (left, top, scale int) {
+	t.Helper()
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if !isDark(img.At(x, y), options) {
+				continue
+			}
+			run := 0
+			for x+run < bounds.Max.X && isDark(img.At(x+run, y), options) {
+				run++
+			}
+			// The finder's top edge is seven modules wide
+			return x, y, (run + 3) / 7
+		}
+	}
+	t.Fatal("no dark pixels found")
+	return 0, 0, 0
+}
+
+// readGrid samples the centre of every module
+func readGrid(t *testing.T, img image.Image, options domain.QRGenerationOptions) [][]bool {
+	t.Helper()
+	left, top, scale := findSymbol(t, img, options)
+	right := left
+	for x := left; x < img.Bounds().Max.X; x++ {
+		if isDark(img.At(x, top+scale/2), options) {
+			right = x
+		}
+	}
+	size := (right - left + scale/2) / scale
+
+	grid := make([][]bool, size)
+	for y := range grid {
+		grid[y] = make([]bool, size)
+		for x := range grid[y] {
+			grid[y][x] = isDark(img.At(left+x*scale+scale/2, top+y*scale+scale/2), options)
+		}
+	}
+	return grid
+}
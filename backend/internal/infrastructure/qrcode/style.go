@@ -0,0 +1,441 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"url-shortener/internal/core/domain"
+)
+
+// Logos larger than this are refused before decoding, so a small upload cannot
+// expand into a huge bitmap
+const maxLogoPixels = 2048 * 2048
+
+// errLogoHidesTooMuch means the logo fits the size limit but not this version
+var errLogoHidesTooMuch = fmt.Errorf("%w: it hides too many codewords", domain.ErrLogoTooLarge)
+
+// Light modules kept around the logo so its edge does not read as modules
+const logoPadding = 0.5
+
+// Bezier control point distance that approximates a quarter circle
+const kappa = 0.5522847498
+
+// pathWriter receives outlines in module coordinates, y pointing down
+type pathWriter interface {
+	moveTo(x, y float64)
+	lineTo(x, y float64)
+	curveTo(x1, y1, x2, y2, x, y float64)
+	closePath()
+}
+
+type shape interface {
+	contains(x, y float64) bool
+	trace(w pathWriter)
+}
+
+// roundedRect has its own radius in each corner: top left, top right, bottom
+// right, bottom left. Zero radii give a square, half the side a circle.
+type roundedRect struct {
+	x, y, w, h float64
+	radius     [4]float64
+}
+
+func (r roundedRect) contains(px, py float64) bool {
+	if px < r.x || px > r.x+r.w || py < r.y || py > r.y+r.h {
+		return false
+	}
+	corners := [4][2]float64{
+		{r.x + r.radius[0], r.y + r.radius[0]},
+		{r.x + r.w - r.radius[1], r.y + r.radius[1]},
+		{r.x + r.w - r.radius[2], r.y + r.h - r.radius[2]},
+		{r.x + r.radius[3], r.y + r.h - r.radius[3]},
+	}
+	for i, c := range corners {
+		rad := r.radius[i]
+		if rad == 0 {
+			continue
+		}
+		// Only the square outside the quarter circle is cut away
+		outsideX := (i == 0 || i == 3) && px < c[0] || (i == 1 || i == 2) && px > c[0]
+		outsideY := (i == 0 || i == 1) && py < c[1] || (i == 2 || i == 3) && py > c[1]
+		if outsideX && outsideY && math.Hypot(px-c[0], py-c[1]) > rad {
+			return false
+		}
+	}
+	return true
+}
+
+func (r roundedRect) trace(w pathWriter) {
+	tl, tr, br, bl := r.radius[0], r.radius[1], r.radius[2], r.radius[3]
+	right, bottom := r.x+r.w, r.y+r.h
+
+	w.moveTo(r.x+tl, r.y)
+	w.lineTo(right-tr, r.y)
+	if tr > 0 {
+		w.curveTo(right-tr+kappa*tr, r.y, right, r.y+tr-kappa*tr, right, r.y+tr)
+	}
+	w.lineTo(right, bottom-br)
+	if br > 0 {
+		w.curveTo(right, bottom-br+kappa*br, right-br+kappa*br, bottom, right-br, bottom)
+	}
+	w.lineTo(r.x+bl, bottom)
+	if bl > 0 {
+		w.curveTo(r.x+bl-kappa*bl, bottom, r.x, bottom-bl+kappa*bl, r.x, bottom-bl)
+	}
+	w.lineTo(r.x, r.y+tl)
+	if tl > 0 {
+		w.curveTo(r.x, r.y+tl-kappa*tl, r.x+tl-kappa*tl, r.y, r.x+tl, r.y)
+	}
+	w.closePath()
+}
+
+// diamond is a square turned 45 degrees, touching the middle of each side of its cell
+type diamond struct {
+	x, y, size float64
+}
+
+func (d diamond) contains(px, py float64) bool {
+	half := d.size / 2
+	return math.Abs(px-d.x-half)+math.Abs(py-d.y-half) <= half
+}
+
+func (d diamond) trace(w pathWriter) {
+	half := d.size / 2
+	w.moveTo(d.x+half, d.y)
+	w.lineTo(d.x+d.size, d.y+half)
+	w.lineTo(d.x+half, d.y+d.size)
+	w.lineTo(d.x, d.y+half)
+	w.closePath()
+}
+
+// Corner radii of a finder eye's outer ring, the ring's inner edge and the
+// centre ball, in modules
+var eyeRadii = map[string][3]float64{
+	domain.QRShapeSquare:  {0, 0, 0},
+	domain.QRShapeRounded: {2, 1, 0.75},
+	domain.QRShapeCircle:  {3.5, 2.5, 1.5},
+}
+
+type gradient struct {
+	from, to   color.RGBA
+	x0, y0     float64
+	x1, y1     float64
+	dx, dy, ln float64
+}
+
+// newGradient runs along angle degrees clockwise from left to right, across
+// the whole symbol whatever the angle
+func newGradient(from, to color.RGBA, angle int, x, y, size float64) *gradient {
+	rad := float64(angle) * math.Pi / 180
+	dx, dy := math.Cos(rad), math.Sin(rad)
+	half := (math.Abs(dx)*size + math.Abs(dy)*size) / 2
+	cx, cy := x+size/2, y+size/2
+	return &gradient{
+		from: from, to: to,
+		x0: cx - dx*half, y0: cy - dy*half,
+		x1: cx + dx*half, y1: cy + dy*half,
+		dx: dx, dy: dy, ln: 2 * half,
+	}
+}
+
+func (g *gradient) at(x, y float64) color.RGBA {
+	t := ((x-g.x0)*g.dx + (y-g.y0)*g.dy) / g.ln
+	t = math.Max(0, math.Min(1, t))
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*t))
+	}
+	return color.RGBA{mix(g.from.R, g.to.R), mix(g.from.G, g.to.G), mix(g.from.B, g.to.B), mix(g.from.A, g.to.A)}
+}
+
+// styledSymbol draws a symbol with shaped modules and eyes, an optional
+// gradient and an optional logo. Coordinates are in modules, border included.
+type styledSymbol struct {
+	code        *Code
+	border      int
+	moduleShape string
+	eyeShape    string
+	foreground  color.RGBA
+	background  color.RGBA
+	gradient    *gradient
+
+	logo image.Image
+	// Modules hidden under the logo, in symbol coordinates, exclusive
+	clearX0, clearY0, clearX1, clearY1 int
+	// Where the logo is drawn, in module coordinates
+	logoX, logoY, logoW, logoH float64
+}
+
+// isStyled reports whether options ask for more than plain square modules
+func isStyled(options domain.QRGenerationOptions) bool {
+	return options.ModuleShape != "" && options.ModuleShape != domain.QRShapeSquare ||
+		options.EyeShape != "" && options.EyeShape != domain.QRShapeSquare ||
+		options.GradientColor != nil || len(options.Logo) > 0
+}
+
+func newStyledSymbol(code *Code, logo image.Image, options domain.QRGenerationOptions) (*styledSymbol, error) {
+	s := &styledSymbol{
+		code:        code,
+		border:      options.Border,
+		moduleShape: options.ModuleShape,
+		eyeShape:    options.EyeShape,
+		foreground:  options.ForegroundColor,
+		background:  options.BackgroundColor,
+	}
+	if s.moduleShape == "" {
+		s.moduleShape = domain.QRShapeSquare
+	}
+	if s.eyeShape == "" {
+		s.eyeShape = domain.QRShapeSquare
+	}
+	switch s.moduleShape {
+	case domain.QRShapeSquare, domain.QRShapeRounded, domain.QRShapeCircle, domain.QRShapeDiamond:
+	default:
+		return nil, fmt.Errorf("%w: unknown module shape %q", domain.ErrInvalidInput, s.moduleShape)
+	}
+	if _, ok := eyeRadii[s.eyeShape]; !ok {
+		return nil, fmt.Errorf("%w: unknown eye shape %q", domain.ErrInvalidInput, s.eyeShape)
+	}
+
+	n := float64(code.Size())
+	if options.GradientColor != nil {
+		s.gradient = newGradient(options.ForegroundColor, *options.GradientColor, options.GradientAngle, float64(s.border), float64(s.border), n)
+	}
+
+	if logo != nil {
+		logoSize := options.LogoSize
+		if logoSize == 0 {
+			logoSize = domain.DefaultQRLogoSize
+		}
+		if logoSize < domain.MinQRLogoSize {
+			return nil, fmt.Errorf("%w: logo size must be at least %d%%", domain.ErrInvalidInput, domain.MinQRLogoSize)
+		}
+		if logoSize > domain.MaxQRLogoSize {
+			return nil, domain.ErrLogoTooLarge
+		}
+
+		// Fit the logo in a centred square, keeping its aspect ratio
+		side := n * float64(logoSize) / 100
+		bounds := logo.Bounds()
+		w, h := side, side
+		if bounds.Dx() > bounds.Dy() {
+			h = side * float64(bounds.Dy()) / float64(bounds.Dx())
+		} else {
+			w = side * float64(bounds.Dx()) / float64(bounds.Dy())
+		}
+		s.logo = logo
+		s.logoX, s.logoY = float64(s.border)+(n-w)/2, float64(s.border)+(n-h)/2
+		s.logoW, s.logoH = w, h
+
+		// Clear whole modules, the same number on each side of the centre
+		clearW := int(math.Ceil((w/2 + logoPadding) * 2))
+		clearH := int(math.Ceil((h/2 + logoPadding) * 2))
+		if clearW%2 != code.Size()%2 {
+			clearW++
+		}
+		if clearH%2 != code.Size()%2 {
+			clearH++
+		}
+		s.clearX0, s.clearY0 = (code.Size()-clearW)/2, (code.Size()-clearH)/2
+		s.clearX1, s.clearY1 = s.clearX0+clearW, s.clearY0+clearH
+		if !code.CanCover(s.clearX0, s.clearY0, s.clearX1, s.clearY1) {
+			return nil, errLogoHidesTooMuch
+		}
+	}
+	return s, nil
+}
+
+// eyeAt returns the top left corner of the finder eye holding a module
+func (s *styledSymbol) eyeAt(x, y int) (int, int, bool) {
+	n := s.code.Size()
+	switch {
+	case x < 7 && y < 7:
+		return 0, 0, true
+	case x >= n-7 && y < 7:
+		return n - 7, 0, true
+	case x < 7 && y >= n-7:
+		return 0, n - 7, true
+	}
+	return 0, 0, false
+}
+
+func (s *styledSymbol) cleared(x, y int) bool {
+	return s.logo != nil && x >= s.clearX0 && x < s.clearX1 && y >= s.clearY0 && y < s.clearY1
+}
+
+// visible reports whether a dark module outside the eyes is drawn
+func (s *styledSymbol) visible(x, y int) bool {
+	n := s.code.Size()
+	if x < 0 || y < 0 || x >= n || y >= n || !s.code.Dark(x, y) || s.cleared(x, y) {
+		return false
+	}
+	_, _, eye := s.eyeAt(x, y)
+	return !eye
+}
+
+// eyeShapes returns the outer ring, the hole in it and the centre ball
+func (s *styledSymbol) eyeShapes(ex, ey int) (outer, hole, ball roundedRect) {
+	radii := eyeRadii[s.eyeShape]
+	x, y := float64(ex+s.border), float64(ey+s.border)
+	outer = roundedRect{x: x, y: y, w: 7, h: 7, radius: [4]float64{radii[0], radii[0], radii[0], radii[0]}}
+	hole = roundedRect{x: x + 1, y: y + 1, w: 5, h: 5, radius: [4]float64{radii[1], radii[1], radii[1], radii[1]}}
+	ball = roundedRect{x: x + 2, y: y + 2, w: 3, h: 3, radius: [4]float64{radii[2], radii[2], radii[2], radii[2]}}
+	return outer, hole, ball
+}
+
+func (s *styledSymbol) moduleShapeAt(x, y int) shape {
+	px, py := float64(x+s.border), float64(y+s.border)
+	switch s.moduleShape {
+	case domain.QRShapeCircle:
+		return roundedRect{x: px, y: py, w: 1, h: 1, radius: [4]float64{0.5, 0.5, 0.5, 0.5}}
+	case domain.QRShapeDiamond:
+		return diamond{x: px, y: py, size: 1}
+	case domain.QRShapeRounded:
+		// Round only the outside corners, so neighbouring modules join up
+		round := func(dx, dy int) float64 {
+			if s.visible(x+dx, y) || s.visible(x, y+dy) {
+				return 0
+			}
+			return 0.5
+		}
+		return roundedRect{x: px, y: py, w: 1, h: 1, radius: [4]float64{round(-1, -1), round(1, -1), round(1, 1), round(-1, 1)}}
+	}
+	return roundedRect{x: px, y: py, w: 1, h: 1}
+}
+
+// traceShapes writes every dark shape; the eye holes make the path need the
+// even-odd fill rule
+func (s *styledSymbol) traceShapes(w pathWriter) {
+	n := s.code.Size()
+	for _, corner := range [][2]int{{0, 0}, {n - 7, 0}, {0, n - 7}} {
+		outer, hole, ball := s.eyeShapes(corner[0], corner[1])
+		outer.trace(w)
+		hole.trace(w)
+		ball.trace(w)
+	}
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if s.visible(x, y) {
+				s.moduleShapeAt(x, y).trace(w)
+			}
+		}
+	}
+}
+
+// covers reports whether a point, in module coordinates, is dark
+func (s *styledSymbol) covers(px, py float64) bool {
+	x, y := int(math.Floor(px))-s.border, int(math.Floor(py))-s.border
+	if x < 0 || y < 0 || x >= s.code.Size() || y >= s.code.Size() {
+		return false
+	}
+	if ex, ey, eye := s.eyeAt(x, y); eye {
+		outer, hole, ball := s.eyeShapes(ex, ey)
+		return outer.contains(px, py) && !hole.contains(px, py) || ball.contains(px, py)
+	}
+	return s.visible(x, y) && s.moduleShapeAt(x, y).contains(px, py)
+}
+
+func (s *styledSymbol) foregroundAt(px, py float64) color.RGBA {
+	if s.gradient != nil {
+		return s.gradient.at(px, py)
+	}
+	return s.foreground
+}
+
+// Samples per pixel side when anti-aliasing curved edges and the logo
+const supersample = 4
+
+// renderImage draws Size pixels square like the plain renderer, with whole
+// pixels per module, anti-aliasing the curved shapes
+func (s *styledSymbol) renderImage(size int) (*image.RGBA, error) {
+	modules := s.code.Size() + 2*s.border
+	scale := size / modules
+	if scale < 1 {
+		return nil, fmt.Errorf("size %d is too small for a %d module QR code", size, modules)
+	}
+	margin := float64(size-scale*modules) / 2
+
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	toModules := func(p float64) float64 { return (p - margin) / float64(scale) }
+	for py := 0; py < size; py++ {
+		for px := 0; px < size; px++ {
+			covered := 0
+			for sy := 0; sy < supersample; sy++ {
+				for sx := 0; sx < supersample; sx++ {
+					mx := toModules(float64(px) + (float64(sx)+0.5)/supersample)
+					my := toModules(float64(py) + (float64(sy)+0.5)/supersample)
+					if s.covers(mx, my) {
+						covered++
+					}
+				}
+			}
+			c := blend(s.background, s.foregroundAt(toModules(float64(px)+0.5), toModules(float64(py)+0.5)), covered, supersample*supersample)
+			img.SetRGBA(px, py, c)
+		}
+	}
+
+	if s.logo != nil {
+		s.drawLogo(img, margin, float64(scale))
+	}
+	return img, nil
+}
+
+// drawLogo scales the logo into its box and composites it over the symbol
+func (s *styledSymbol) drawLogo(img *image.RGBA, margin, scale float64) {
+	x0, y0 := margin+s.logoX*scale, margin+s.logoY*scale
+	w, h := s.logoW*scale, s.logoH*scale
+	bounds := s.logo.Bounds()
+	for py := int(math.Floor(y0)); py < int(math.Ceil(y0+h)); py++ {
+		for px := int(math.Floor(x0)); px < int(math.Ceil(x0+w)); px++ {
+			// Samples outside the box count as transparent
+			var r, g, b, a uint32
+			for sy := 0; sy < supersample; sy++ {
+				for sx := 0; sx < supersample; sx++ {
+					u := (float64(px) + (float64(sx)+0.5)/supersample - x0) / w
+					v := (float64(py) + (float64(sy)+0.5)/supersample - y0) / h
+					if u < 0 || u >= 1 || v < 0 || v >= 1 {
+						continue
+					}
+					sr, sg, sb, sa := s.logo.At(bounds.Min.X+int(u*float64(bounds.Dx())), bounds.Min.Y+int(v*float64(bounds.Dy()))).RGBA()
+					r, g, b, a = r+sr, g+sg, b+sb, a+sa
+				}
+			}
+			if a == 0 {
+				continue
+			}
+			// Premultiplied source over the destination
+			n := uint32(supersample * supersample)
+			dst := img.RGBAAt(px, py)
+			inv := 0xffff - a/n
+			img.SetRGBA(px, py, color.RGBA{
+				R: uint8((r/n + uint32(dst.R)*0x101*inv/0xffff) >> 8),
+				G: uint8((g/n + uint32(dst.G)*0x101*inv/0xffff) >> 8),
+				B: uint8((b/n + uint32(dst.B)*0x101*inv/0xffff) >> 8),
+				A: uint8((a/n + uint32(dst.A)*0x101*inv/0xffff) >> 8),
+			})
+		}
+	}
+}
+
+// blend mixes the foreground into the background by covered out of total samples
+func blend(background, foreground color.RGBA, covered, total int) color.RGBA {
+	mix := func(b, f uint8) uint8 {
+		return uint8((int(b)*(total-covered) + int(f)*covered + total/2) / total)
+	}
+	return color.RGBA{mix(background.R, foreground.R), mix(background.G, foreground.G), mix(background.B, foreground.B), mix(background.A, foreground.A)}
+}
+
+// decodeLogo reads a PNG or JPEG upload
+func decodeLogo(data []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width == 0 || config.Height == 0 || config.Width*config.Height > maxLogoPixels {
+		return nil, domain.ErrInvalidLogo
+	}
+	logo, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, domain.ErrInvalidLogo
+	}
+	return logo, nil
+}
@@ -0,0 +1,376 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"url-shortener/internal/core/domain"
+)
+
+const styledURL = "https://sho.rt/summer-sale-2024"
+
+func TestStyled_ShapesDecode(t *testing.T) {
+	for _, moduleShape := range []string{domain.QRShapeSquare, domain.QRShapeRounded, domain.QRShapeCircle, domain.QRShapeDiamond} {
+		for _, eyeShape := range []string{domain.QRShapeSquare, domain.QRShapeRounded, domain.QRShapeCircle} {
+			t.Run(moduleShape+" modules, "+eyeShape+" eyes", func(t *testing.T) {
+				options := testOptions()
+				options.ModuleShape = moduleShape
+				options.EyeShape = eyeShape
+
+				data, err := NewProvider().GenerateQRCode(styledURL, options)
+				require.NoError(t, err)
+				img, err := png.Decode(bytes.NewReader(data))
+				require.NoError(t, err)
+
+				decoded := decodeGrid(t, sampleImage(img, options, symbolSize(t, styledURL, LevelM)))
+				assert.Equal(t, styledURL, decoded.text)
+				assert.Zero(t, decoded.corrected)
+			})
+		}
+	}
+}
+
+func TestStyled_CircleModulesAreRound(t *testing.T) {
+	options := testOptions()
+	options.ModuleShape = domain.QRShapeCircle
+	size := symbolSize(t, styledURL, LevelM)
+	options.Size = (size + 8) * 10 // 10 pixels per module
+
+	data, err := NewProvider().GenerateQRCode(styledURL, options)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	// Find a dark data module and check its corner is light but its centre dark
+	code, err := Encode(styledURL, LevelM)
+	require.NoError(t, err)
+	for y := 9; y < size-9; y++ {
+		for x := 9; x < size-9; x++ {
+			if code.Dark(x, y) && !code.function[y][x] {
+				px, py := (x+4)*10, (y+4)*10
+				assert.True(t, luminanceDark(img.At(px+5, py+5)))
+				assert.False(t, luminanceDark(img.At(px, py)))
+				return
+			}
+		}
+	}
+	t.Fatal("no dark data module found")
+}
+
+func TestStyled_Gradient(t *testing.T) {
+	options := testOptions()
+	options.ForegroundColor = color.RGBA{0x1a, 0x23, 0x7e, 255}
+	options.GradientColor = &color.RGBA{0xb7, 0x1c, 0x1c, 255}
+
+	data, err := NewProvider().GenerateQRCode(styledURL, options)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	// Left to right: the top left finder is mostly the first colour, the top right mostly the second
+	size := symbolSize(t, styledURL, LevelM)
+	scale := 256 / (size + 8)
+	margin := (256 - scale*(size+8)) / 2
+	left := color.RGBAModel.Convert(img.At(margin+4*scale+scale/2, margin+4*scale+scale/2)).(color.RGBA)
+	right := color.RGBAModel.Convert(img.At(margin+(size+3)*scale+scale/2, margin+4*scale+scale/2)).(color.RGBA)
+	assert.Greater(t, left.B, left.R)
+	assert.Greater(t, right.R, right.B)
+
+	decoded := decodeGrid(t, sampleImage(img, options, size))
+	assert.Equal(t, styledURL, decoded.text)
+}
+
+func TestStyled_LogoForcesLevelH(t *testing.T) {
+	options := testOptions()
+	options.Size = 512
+	options.ErrorCorrection = 0
+	options.ModuleShape = domain.QRShapeRounded
+	options.Logo = testLogo(t, 60, 40)
+	options.LogoSize = 25
+
+	data, err := NewProvider().GenerateQRCode(styledURL, options)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	size := symbolSize(t, styledURL, LevelH)
+	scale := 512 / (size + 8)
+	margin := (512 - scale*(size+8)) / 2
+	centre := margin + (4*2+size)*scale/2
+	assert.Equal(t, color.RGBA{0xe5, 0x39, 0x35, 255}, color.RGBAModel.Convert(img.At(centre, centre)))
+
+	decoded := decodeGrid(t, sampleImage(img, options, size))
+	assert.Equal(t, styledURL, decoded.text)
+	assert.Equal(t, LevelH, decoded.level)
+	// The logo did hide codewords, and error correction got them back
+	assert.Greater(t, decoded.corrected, 0)
+}
+
+func TestStyled_LogoRefusals(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		logo    []byte
+		size    int
+		wantErr error
+	}{
+		{name: "not an image", url: styledURL, logo: []byte("https://example.com/logo.png"), size: 20, wantErr: domain.ErrInvalidLogo},
+		{name: "above the maximum", url: styledURL, logo: testLogo(t, 10, 10), size: 35, wantErr: domain.ErrLogoTooLarge},
+		{name: "below the minimum", url: styledURL, logo: testLogo(t, 10, 10), size: 5, wantErr: domain.ErrInvalidInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := testOptions()
+			options.Logo = tt.logo
+			options.LogoSize = tt.size
+
+			_, err := NewProvider().GenerateQRCode(tt.url, options)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestStyled_LogoGrowsSmallSymbols(t *testing.T) {
+	options := testOptions()
+	options.Logo = testLogo(t, 10, 10)
+	options.LogoSize = 30
+
+	// Fits version 1 at H without the logo
+	data, err := NewProvider().GenerateQRCode("HTTPS://SHO.RT/A", options)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	size := 0
+	for version := 2; version <= maxVersion && size == 0; version++ {
+		code := newCode(version, LevelH)
+		clear := int(math.Ceil(float64(code.Size())*0.3 + 2*logoPadding))
+		if clear%2 != code.Size()%2 {
+			clear++
+		}
+		start := (code.Size() - clear) / 2
+		if code.CanCover(start, start, start+clear, start+clear) {
+			size = code.Size()
+		}
+	}
+	decoded := decodeGrid(t, sampleImage(img, options, size))
+	assert.Equal(t, "HTTPS://SHO.RT/A", decoded.text)
+}
+
+func TestCanCover(t *testing.T) {
+	code, err := Encode(strings.Repeat("a", 200), LevelH)
+	require.NoError(t, err)
+	n := code.Size()
+
+	assert.True(t, code.CanCover(n/2-3, n/2-3, n/2+4, n/2+4))
+	assert.False(t, code.CanCover(0, 0, 9, 9), "finder pattern")
+	assert.False(t, code.CanCover(n/2-2, 4, n/2+3, 9), "timing pattern")
+	assert.False(t, code.CanCover(n/4, n/4, n*3/4, n*3/4), "half the symbol")
+
+	// A 7x7 hole is 8 or more codewords, too many for version 1's single block at H
+	small := newCode(1, LevelH)
+	assert.False(t, small.CanCover(7, 7, 14, 14))
+	assert.True(t, small.CanCover(9, 9, 12, 12))
+}
+
+func TestStyled_SVG(t *testing.T) {
+	options := testOptions()
+	options.Format = "svg"
+	options.ModuleShape = domain.QRShapeCircle
+	options.EyeShape = domain.QRShapeRounded
+	options.GradientColor = &color.RGBA{0xb7, 0x1c, 0x1c, 255}
+	options.GradientAngle = 45
+	options.Logo = testLogo(t, 40, 40)
+
+	data, err := NewProvider().GenerateQRCode(styledURL, options)
+	require.NoError(t, err)
+
+	var svg struct {
+		Gradient struct {
+			ID    string `xml:"id,attr"`
+			Stops []struct {
+				Color string `xml:"stop-color,attr"`
+			} `xml:"stop"`
+		} `xml:"defs>linearGradient"`
+		Path struct {
+			D        string `xml:"d,attr"`
+			Fill     string `xml:"fill,attr"`
+			FillRule string `xml:"fill-rule,attr"`
+		} `xml:"path"`
+		Image struct {
+			Href string `xml:"http://www.w3.org/1999/xlink href,attr"`
+		} `xml:"image"`
+	}
+	require.NoError(t, xml.Unmarshal(data, &svg))
+	assert.Equal(t, "qr-gradient", svg.Gradient.ID)
+	require.Len(t, svg.Gradient.Stops, 2)
+	assert.Equal(t, "#000000", svg.Gradient.Stops[0].Color)
+	assert.Equal(t, "#b71c1c", svg.Gradient.Stops[1].Color)
+	assert.Equal(t, "url(#qr-gradient)", svg.Path.Fill)
+	assert.Equal(t, "evenodd", svg.Path.FillRule)
+	require.True(t, strings.HasPrefix(svg.Image.Href, "data:image/png;base64,"))
+
+	var outline outline
+	for _, command := range regexp.MustCompile(`[MLCZ][^MLCZ]*`).FindAllString(svg.Path.D, -1) {
+		outline.apply(t, command[:1], strings.Fields(command[1:]))
+	}
+
+	size := symbolSize(t, styledURL, LevelH)
+	decoded := decodeGrid(t, outline.sample(size, options.Border))
+	assert.Equal(t, styledURL, decoded.text)
+	assert.Greater(t, decoded.corrected, 0)
+}
+
+func TestStyled_PDF(t *testing.T) {
+	options := testOptions()
+	options.Format = "pdf"
+	options.ModuleShape = domain.QRShapeDiamond
+	options.EyeShape = domain.QRShapeCircle
+	options.GradientColor = &color.RGBA{0xb7, 0x1c, 0x1c, 255}
+	options.Logo = testLogo(t, 40, 20)
+
+	data, err := NewProvider().GenerateQRCode(styledURL, options)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(data), "/Resources << /Shading << /Sh1 5 0 R >> /XObject << /Im1 6 0 R >> >>")
+	assert.Contains(t, string(data), "/ShadingType 2")
+	assert.Contains(t, string(data), "/Width 40 /Height 20 /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /SMask 7 0 R")
+	assert.Contains(t, string(data), "/Im1 Do")
+
+	stream := regexp.MustCompile(`(?s)<< /Length \d+ >>\nstream\n(.*?)\nendstream`).FindSubmatch(data)
+	require.NotNil(t, stream)
+	content := string(stream[1])
+	assert.Contains(t, content, "W* n\n/Sh1 sh\n")
+
+	var outline outline
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		operator := fields[len(fields)-1]
+		if commands := map[string]string{"m": "M", "l": "L", "c": "C", "h": "Z"}; commands[operator] != "" {
+			outline.apply(t, commands[operator], fields[:len(fields)-1])
+		}
+	}
+
+	size := symbolSize(t, styledURL, LevelH)
+	decoded := decodeGrid(t, outline.sample(size, options.Border))
+	assert.Equal(t, styledURL, decoded.text)
+	assert.Greater(t, decoded.corrected, 0)
+}
+
+func symbolSize(t *testing.T, text string, level Level) int {
+	t.Helper()
+	code, err := Encode(text, level)
+	require.NoError(t, err)
+	return code.Size()
+}
+
+// testLogo is a red rectangle with transparent corners
+func testLogo(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if (x < 3 || x >= width-3) && (y < 3 || y >= height-3) {
+				continue
+			}
+			img.Set(x, y, color.NRGBA{0xe5, 0x39, 0x35, 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func luminanceDark(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return (299*r+587*g+114*b)/1000 < 0x8000
+}
+
+// sampleImage reads the centre of every module, knowing where the renderer put them
+func sampleImage(img image.Image, options domain.QRGenerationOptions, size int) [][]bool {
+	modules := size + 2*options.Border
+	scale := options.Size / modules
+	offset := (options.Size-scale*modules)/2 + options.Border*scale
+	grid := newGrid(size)
+	for y := range grid {
+		for x := range grid[y] {
+			grid[y][x] = luminanceDark(img.At(offset+x*scale+scale/2, offset+y*scale+scale/2))
+		}
+	}
+	return grid
+}
+
+// outline flattens path commands into polygons for even-odd hit testing
+type outline struct {
+	polygons [][][2]float64
+	x, y     float64
+}
+
+func (o *outline) apply(t *testing.T, command string, args []string) {
+	t.Helper()
+	numbers := make([]float64, len(args))
+	for i, arg := range args {
+		var err error
+		numbers[i], err = strconv.ParseFloat(arg, 64)
+		require.NoError(t, err, arg)
+	}
+
+	switch command {
+	case "M":
+		o.polygons = append(o.polygons, [][2]float64{{numbers[0], numbers[1]}})
+		o.x, o.y = numbers[0], numbers[1]
+	case "L":
+		o.lineTo(numbers[0], numbers[1])
+	case "C":
+		x0, y0 := o.x, o.y
+		for i := 1; i <= 8; i++ {
+			s := float64(i) / 8
+			a, b, c, d := (1-s)*(1-s)*(1-s), 3*(1-s)*(1-s)*s, 3*(1-s)*s*s, s*s*s
+			o.lineTo(a*x0+b*numbers[0]+c*numbers[2]+d*numbers[4], a*y0+b*numbers[1]+c*numbers[3]+d*numbers[5])
+		}
+	case "Z":
+	}
+}
+
+func (o *outline) lineTo(x, y float64) {
+	last := len(o.polygons) - 1
+	o.polygons[last] = append(o.polygons[last], [2]float64{x, y})
+	o.x, o.y = x, y
+}
+
+func (o *outline) inside(px, py float64) bool {
+	inside := false
+	for _, polygon := range o.polygons {
+		for i := range polygon {
+			a, b := polygon[i], polygon[(i+1)%len(polygon)]
+			if (a[1] > py) != (b[1] > py) && px < a[0]+(py-a[1])*(b[0]-a[0])/(b[1]-a[1]) {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+func (o *outline) sample(size, border int) [][]bool {
+	grid := newGrid(size)
+	for y := range grid {
+		for x := range grid[y] {
+			grid[y][x] = o.inside(float64(x+border)+0.5, float64(y+border)+0.5)
+		}
+	}
+	return grid
+}
@@ -0,0 +1,104 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// renderSVG draws the symbol as a single path in a viewBox one unit per module,
+// so it stays sharp at any scale. Size only sets the default display size.
+func renderSVG(code *Code, size, border int, foreground, background color.RGBA) []byte {
+	modules := code.Size() + 2*border
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		size, size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d"%s/>`+"\n", modules, modules, svgFill(background))
+
+	buf.WriteString(`<path d="`)
+	for _, r := range darkRuns(code, border) {
+		fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", r.x, r.y, r.width, r.width)
+	}
+	fmt.Fprintf(&buf, `"%s/>`+"\n", svgFill(foreground))
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+// svgFill writes an opaque colour, like the PDF renderer
+func svgFill(c color.RGBA) string {
+	return fmt.Sprintf(` fill="#%02x%02x%02x"`, c.R, c.G, c.B)
+}
+
+// svgPath collects outlines as SVG path data
+type svgPath struct {
+	bytes.Buffer
+}
+
+func (p *svgPath) moveTo(x, y float64) {
+	fmt.Fprintf(p, "M%s %s", formatNumber(x), formatNumber(y))
+}
+
+func (p *svgPath) lineTo(x, y float64) {
+	fmt.Fprintf(p, "L%s %s", formatNumber(x), formatNumber(y))
+}
+
+func (p *svgPath) curveTo(x1, y1, x2, y2, x, y float64) {
+	fmt.Fprintf(p, "C%s %s %s %s %s %s", formatNumber(x1), formatNumber(y1), formatNumber(x2), formatNumber(y2), formatNumber(x), formatNumber(y))
+}
+
+func (p *svgPath) closePath() {
+	p.WriteString("Z")
+}
+
+// renderSVG draws shaped modules as one even-odd path, filled with a
+// userSpaceOnUse gradient when there is one, and embeds the logo as a PNG
+func (s *styledSymbol) renderSVG(size int) ([]byte, error) {
+	modules := s.code.Size() + 2*s.border
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" version="1.1" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		size, size, modules, modules)
+
+	fill := svgFill(s.foreground)
+	if g := s.gradient; g != nil {
+		fmt.Fprintf(&buf, `<defs><linearGradient id="qr-gradient" gradientUnits="userSpaceOnUse" x1="%s" y1="%s" x2="%s" y2="%s">`,
+			formatNumber(g.x0), formatNumber(g.y0), formatNumber(g.x1), formatNumber(g.y1))
+		fmt.Fprintf(&buf, `<stop offset="0" stop-color="#%02x%02x%02x"/><stop offset="1" stop-color="#%02x%02x%02x"/>`,
+			g.from.R, g.from.G, g.from.B, g.to.R, g.to.G, g.to.B)
+		buf.WriteString("</linearGradient></defs>\n")
+		fill = ` fill="url(#qr-gradient)"`
+	}
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d"%s/>`+"\n", modules, modules, svgFill(s.background))
+
+	var path svgPath
+	s.traceShapes(&path)
+	fmt.Fprintf(&buf, `<path fill-rule="evenodd" d="%s"%s/>`+"\n", path.String(), fill)
+
+	if s.logo != nil {
+		var logo bytes.Buffer
+		if err := png.Encode(&logo, s.logo); err != nil {
+			return nil, fmt.Errorf("failed to encode logo: %w", err)
+		}
+		fmt.Fprintf(&buf, `<image x="%s" y="%s" width="%s" height="%s" preserveAspectRatio="none" xlink:href="data:image/png;base64,%s"/>`+"\n",
+			formatNumber(s.logoX), formatNumber(s.logoY), formatNumber(s.logoW), formatNumber(s.logoH), base64.StdEncoding.EncodeToString(logo.Bytes()))
+	}
+	buf.WriteString("</svg>\n")
+	return buf.Bytes(), nil
+}
+
+// formatNumber keeps three decimals, plenty at any print size, and drops trailing zeros
+func formatNumber(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 3, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
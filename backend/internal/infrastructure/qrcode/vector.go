@@ -0,0 +1,27 @@
+package qrcode
+
+// run is a horizontal stretch of dark modules, in module coordinates with the
+// border included
+type run struct {
+	x, y, width int
+}
+
+// darkRuns merges each row's adjacent dark modules, so vector output draws one
+// rectangle per run instead of one per module
+func darkRuns(code *Code, border int) []run {
+	var runs []run
+	for y := 0; y < code.Size(); y++ {
+		for x := 0; x < code.Size(); {
+			if !code.Dark(x, y) {
+				x++
+				continue
+			}
+			start := x
+			for x < code.Size() && code.Dark(x, y) {
+				x++
+			}
+			runs = append(runs, run{x: start + border, y: y + border, width: x - start})
+		}
+	}
+	return runs
+}
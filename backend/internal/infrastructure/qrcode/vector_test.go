@@ -0,0 +1,152 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider_SVG(t *testing.T) {
+	options := testOptions()
+	options.Format = "svg"
+	options.Size = 300
+	options.ForegroundColor = color.RGBA{0x1a, 0x23, 0x7e, 255}
+
+	data, err := NewProvider().GenerateQRCode("https://sho.rt/abc123", options)
+	require.NoError(t, err)
+
+	var svg struct {
+		Width   string `xml:"width,attr"`
+		Height  string `xml:"height,attr"`
+		ViewBox string `xml:"viewBox,attr"`
+		Rect    struct {
+			Fill string `xml:"fill,attr"`
+		} `xml:"rect"`
+		Paths []struct {
+			D    string `xml:"d,attr"`
+			Fill string `xml:"fill,attr"`
+		} `xml:"path"`
+	}
+	require.NoError(t, xml.Unmarshal(data, &svg))
+	assert.Equal(t, "300", svg.Width)
+	assert.Equal(t, "300", svg.Height)
+	assert.Equal(t, "0 0 33 33", svg.ViewBox)
+	assert.Equal(t, "#ffffff", svg.Rect.Fill)
+	// Every dark module is in one path
+	require.Len(t, svg.Paths, 1)
+	assert.Equal(t, "#1a237e", svg.Paths[0].Fill)
+
+	grid := newGrid(33)
+	for _, segment := range strings.Split(strings.TrimSuffix(svg.Paths[0].D, "z"), "z") {
+		var x, y, width, back int
+		_, err := fmt.Sscanf(segment, "M%d %dh%dv1h-%d", &x, &y, &width, &back)
+		require.NoError(t, err, segment)
+		require.Equal(t, width, back)
+		fillRun(grid, x, y, width)
+	}
+
+	decoded := decodeGrid(t, stripBorder(grid, options.Border))
+	assert.Equal(t, "https://sho.rt/abc123", decoded.text)
+}
+
+func TestProvider_PDF(t *testing.T) {
+	options := testOptions()
+	options.Format = "pdf"
+	options.Size = 145
+	options.Border = 2
+
+	data, err := NewProvider().GenerateQRCode("https://sho.rt/abc123", options)
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(data, []byte("%PDF-1.4\n")))
+	require.True(t, bytes.HasSuffix(data, []byte("%%EOF\n")))
+
+	// startxref points at the table, and every entry at its object
+	match := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+	require.NotNil(t, match)
+	xref, _ := strconv.Atoi(string(match[1]))
+	require.True(t, bytes.HasPrefix(data[xref:], []byte("xref\n0 5\n")))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllSubmatch(data[xref:], -1)
+	require.Len(t, entries, 4)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		assert.True(t, bytes.HasPrefix(data[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+	}
+
+	assert.Contains(t, string(data), "/MediaBox [0 0 145 145]")
+	stream := regexp.MustCompile(`(?s)<< /Length (\d+) >>\nstream\n(.*)\nendstream`).FindSubmatch(data)
+	require.NotNil(t, stream)
+	length, _ := strconv.Atoi(string(stream[1]))
+	assert.Equal(t, length, len(stream[2]))
+
+	content := string(stream[2])
+	assert.Contains(t, content, "1.000 1.000 1.000 rg\n0 0 145 145 re f\n")
+	assert.Contains(t, content, "5 0 0 -5 0 145 cm\n")
+
+	grid := newGrid(29)
+	for _, line := range strings.Split(content, "\n") {
+		if !strings.HasSuffix(line, " 1 re") {
+			continue
+		}
+		var x, y, width int
+		_, err := fmt.Sscanf(line, "%d %d %d 1 re", &x, &y, &width)
+		require.NoError(t, err, line)
+		fillRun(grid, x, y, width)
+	}
+
+	decoded := decodeGrid(t, stripBorder(grid, options.Border))
+	assert.Equal(t, "https://sho.rt/abc123", decoded.text)
+}
+
+func TestDarkRuns_MergeAdjacentModules(t *testing.T) {
+	code, err := Encode("HELLO WORLD", LevelQ)
+	require.NoError(t, err)
+
+	// The top row opens with the seven module edge of the top left finder
+	runs := darkRuns(code, 4)
+	assert.Equal(t, run{x: 4, y: 4, width: 7}, runs[0])
+
+	dark := 0
+	for y := 0; y < code.Size(); y++ {
+		for x := 0; x < code.Size(); x++ {
+			if code.Dark(x, y) {
+				dark++
+			}
+		}
+	}
+	total := 0
+	for _, r := range runs {
+		total += r.width
+	}
+	assert.Equal(t, dark, total)
+	assert.Less(t, len(runs), dark)
+}
+
+func newGrid(size int) [][]bool {
+	grid := make([][]bool, size)
+	for y := range grid {
+		grid[y] = make([]bool, size)
+	}
+	return grid
+}
+
+func fillRun(grid [][]bool, x, y, width int) {
+	for i := 0; i < width; i++ {
+		grid[y][x+i] = true
+	}
+}
+
+func stripBorder(grid [][]bool, border int) [][]bool {
+	inner := grid[border : len(grid)-border]
+	for y := range inner {
+		inner[y] = inner[y][border : len(grid)-border]
+	}
+	return inner
+}
@@ -0,0 +1,110 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"url-shortener/internal/config"
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+// memoryClickQueue buffers clicks in a bounded channel drained by a pool of batch writers.
+// Clicks still queued when the process dies are lost.
+type memoryClickQueue struct {
+	clicks  chan *domain.Click
+	writer  ports.ClickBatchWriter
+	cfg     config.ClickIngestionConfig
+	metrics metrics
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+func NewMemoryClickQueue(writer ports.ClickBatchWriter, cfg config.ClickIngestionConfig) ports.ClickQueue {
+	cfg = withDefaults(cfg)
+	q := &memoryClickQueue{
+		clicks: make(chan *domain.Click, cfg.QueueSize),
+		writer: writer,
+		cfg:    cfg,
+		done:   make(chan struct{}),
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(q.done)
+	}()
+
+	return q
+}
+
+// Enqueue never blocks: when the buffer is full the click is dropped and counted
+func (q *memoryClickQueue) Enqueue(ctx context.Context, click *domain.Click) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return domain.ErrClickQueueClosed
+	}
+
+	select {
+	case q.clicks <- click:
+		q.metrics.enqueued.Add(1)
+		return nil
+	default:
+		q.metrics.dropped.Add(1)
+		return domain.ErrClickQueueFull
+	}
+}
+
+func (q *memoryClickQueue) Stats() domain.ClickQueueStats {
+	return q.metrics.stats("memory", int64(cap(q.clicks)), int64(len(q.clicks)))
+}
+
+func (q *memoryClickQueue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.clicks)
+	}
+	q.mu.Unlock()
+
+	return waitDone(ctx, q.done)
+}
+
+func (q *memoryClickQueue) work() {
+	ticker := time.NewTicker(q.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*domain.Click, 0, q.cfg.BatchSize)
+	for {
+		select {
+		case click, ok := <-q.clicks:
+			if !ok {
+				// Channel closed and drained
+				q.metrics.flush(q.writer, batch)
+				return
+			}
+			batch = append(batch, click)
+			if len(batch) >= q.cfg.BatchSize {
+				q.metrics.flush(q.writer, batch)
+				batch = make([]*domain.Click, 0, q.cfg.BatchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				q.metrics.flush(q.writer, batch)
+				batch = make([]*domain.Click, 0, q.cfg.BatchSize)
+			}
+		}
+	}
+}
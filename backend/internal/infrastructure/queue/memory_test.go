@@ -0,0 +1,153 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"url-shortener/internal/config"
+	"url-shortener/internal/core/domain"
+)
+
+// recordingWriter collects every batch it is given
+type recordingWriter struct {
+	mu      sync.Mutex
+	batches [][]*domain.Click
+	err     error
+	block   chan struct{}
+}
+
+func (w *recordingWriter) WriteBatch(ctx context.Context, clicks []*domain.Click) error {
+	if w.block != nil {
+		<-w.block
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.batches = append(w.batches, clicks)
+	return nil
+}
+
+func (w *recordingWriter) total() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := 0
+	for _, batch := range w.batches {
+		n += len(batch)
+	}
+	return n
+}
+
+func testIngestionConfig() config.ClickIngestionConfig {
+	return config.ClickIngestionConfig{
+		QueueSize:     100,
+		Workers:       2,
+		BatchSize:     10,
+		FlushInterval: 20 * time.Millisecond,
+		StreamName:    "clicks",
+		ConsumerGroup: "click-writers",
+	}
+}
+
+func TestMemoryClickQueue_WritesInBatches(t *testing.T) {
+	writer := &recordingWriter{}
+	q := NewMemoryClickQueue(writer, testIngestionConfig())
+
+	for i := 0; i < 45; i++ {
+		require.NoError(t, q.Enqueue(context.Background(), &domain.Click{ShortURLID: uint(i%3 + 1)}))
+	}
+
+	require.NoError(t, q.Close(context.Background()))
+
+	assert.Equal(t, 45, writer.total())
+	for _, batch := range writer.batches {
+		assert.LessOrEqual(t, len(batch), 10)
+	}
+
+	stats := q.Stats()
+	assert.Equal(t, "memory", stats.Backend)
+	assert.Equal(t, int64(45), stats.Enqueued)
+	assert.Equal(t, int64(45), stats.Written)
+	assert.Equal(t, int64(len(writer.batches)), stats.Batches)
+	assert.Equal(t, int64(0), stats.Depth)
+}
+
+func TestMemoryClickQueue_FlushesOnInterval(t *testing.T) {
+	writer := &recordingWriter{}
+	q := NewMemoryClickQueue(writer, testIngestionConfig())
+	defer q.Close(context.Background())
+
+	require.NoError(t, q.Enqueue(context.Background(), &domain.Click{ShortURLID: 1}))
+
+	assert.Eventually(t, func() bool { return writer.total() == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestMemoryClickQueue_DropsWhenFull(t *testing.T) {
+	writer := &recordingWriter{block: make(chan struct{})}
+	cfg := testIngestionConfig()
+	cfg.QueueSize = 2
+	cfg.Workers = 1
+	cfg.BatchSize = 1
+	q := NewMemoryClickQueue(writer, cfg)
+
+	// The first click is picked up by the blocked worker, the next two fill the buffer
+	require.NoError(t, q.Enqueue(context.Background(), &domain.Click{ShortURLID: 1}))
+	require.Eventually(t, func() bool { return q.Stats().Depth == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, q.Enqueue(context.Background(), &domain.Click{ShortURLID: 1}))
+	require.NoError(t, q.Enqueue(context.Background(), &domain.Click{ShortURLID: 1}))
+
+	err := q.Enqueue(context.Background(), &domain.Click{ShortURLID: 1})
+	assert.Equal(t, domain.ErrClickQueueFull, err)
+
+	stats := q.Stats()
+	assert.Equal(t, int64(1), stats.Dropped)
+	assert.Equal(t, int64(2), stats.Depth)
+	assert.Equal(t, int64(2), stats.Capacity)
+
+	close(writer.block)
+	require.NoError(t, q.Close(context.Background()))
+	assert.Equal(t, 3, writer.total())
+}
+
+func TestMemoryClickQueue_RejectsAfterClose(t *testing.T) {
+	q := NewMemoryClickQueue(&recordingWriter{}, testIngestionConfig())
+	require.NoError(t, q.Close(context.Background()))
+
+	err := q.Enqueue(context.Background(), &domain.Click{ShortURLID: 1})
+	assert.Equal(t, domain.ErrClickQueueClosed, err)
+
+	// Closing twice is harmless
+	assert.NoError(t, q.Close(context.Background()))
+}
+
+func TestMemoryClickQueue_CloseTimesOut(t *testing.T) {
+	writer := &recordingWriter{block: make(chan struct{})}
+	defer close(writer.block)
+	q := NewMemoryClickQueue(writer, testIngestionConfig())
+	require.NoError(t, q.Enqueue(context.Background(), &domain.Click{ShortURLID: 1}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := q.Close(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestMemoryClickQueue_CountsFailedWrites(t *testing.T) {
+	writer := &recordingWriter{err: errors.New("database down")}
+	q := NewMemoryClickQueue(writer, testIngestionConfig())
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, q.Enqueue(context.Background(), &domain.Click{ShortURLID: 1}))
+	}
+	require.NoError(t, q.Close(context.Background()))
+
+	stats := q.Stats()
+	assert.Equal(t, int64(5), stats.Failed)
+	assert.Equal(t, int64(0), stats.Written)
+}
@@ -0,0 +1,100 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"url-shortener/internal/config"
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+const (
+	defaultQueueSize     = 10000
+	defaultWorkers       = 2
+	defaultBatchSize     = 500
+	defaultFlushInterval = time.Second
+	defaultClaimIdle     = time.Minute
+	defaultMaxDeliveries = 5
+
+	// Upper bound for a single WriteBatch call
+	writeTimeout = 30 * time.Second
+)
+
+// metrics holds the counters shared by the queue implementations
+type metrics struct {
+	enqueued atomic.Int64
+	dropped  atomic.Int64
+	written  atomic.Int64
+	failed   atomic.Int64
+	batches  atomic.Int64
+}
+
+func (m *metrics) stats(backend string, capacity, depth int64) domain.ClickQueueStats {
+	return domain.ClickQueueStats{
+		Backend:  backend,
+		Capacity: capacity,
+		Depth:    depth,
+		Enqueued: m.enqueued.Load(),
+		Dropped:  m.dropped.Load(),
+		Written:  m.written.Load(),
+		Failed:   m.failed.Load(),
+		Batches:  m.batches.Load(),
+	}
+}
+
+// flush hands a batch to the writer and records the outcome
+func (m *metrics) flush(writer ports.ClickBatchWriter, batch []*domain.Click) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+
+	if err := writer.WriteBatch(ctx, batch); err != nil {
+		m.failed.Add(int64(len(batch)))
+		log.Printf("Failed to write batch of %d clicks: %v", len(batch), err)
+		return err
+	}
+
+	m.written.Add(int64(len(batch)))
+	m.batches.Add(1)
+	return nil
+}
+
+// withDefaults fills in zero values so a partially set config still works
+func withDefaults(cfg config.ClickIngestionConfig) config.ClickIngestionConfig {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	if cfg.ClaimIdle <= 0 {
+		cfg.ClaimIdle = defaultClaimIdle
+	}
+	if cfg.MaxDeliveries <= 0 {
+		cfg.MaxDeliveries = defaultMaxDeliveries
+	}
+	return cfg
+}
+
+// waitDone waits for done to close or ctx to expire, whichever comes first
+func waitDone(ctx context.Context, done <-chan struct{}) error {
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("click queue did not drain in time: %w", ctx.Err())
+	}
+}
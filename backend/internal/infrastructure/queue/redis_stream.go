@@ -0,0 +1,306 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"url-shortener/internal/config"
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+	"url-shortener/internal/infrastructure/cache"
+)
+
+// StreamClient is the subset of cache.RedisClient used by the stream queue
+type StreamClient interface {
+	XAdd(ctx context.Context, stream string, values map[string]interface{}) (string, error)
+	XGroupCreate(ctx context.Context, stream, group string) error
+	XReadGroup(ctx context.Context, stream, group, consumer, start string, count int64, block time.Duration) ([]cache.StreamMessage, error)
+	XClaimStale(ctx context.Context, stream, group, consumer string, minIdle time.Duration, count int64) ([]cache.StreamMessage, error)
+	XAckDel(ctx context.Context, stream, group string, ids ...string) error
+	XLen(ctx context.Context, stream string) (int64, error)
+}
+
+const (
+	streamPayloadField = "click"
+
+	// Appended to the stream name to get the stream holding entries that could
+	// not be written after MaxDeliveries attempts
+	deadLetterSuffix = ":dead"
+)
+
+// redisStreamClickQueue appends clicks to a Redis stream consumed by a group of batch
+// writers. Entries survive restarts: a batch is only acknowledged once it is written,
+// each worker re-reads its own unacknowledged entries when it starts, and entries left
+// unacknowledged for ClaimIdle, by a failed write or a worker that never came back,
+// are taken over by whichever worker notices first. Entries that still fail after
+// MaxDeliveries deliveries are moved to a dead-letter stream.
+type redisStreamClickQueue struct {
+	client  StreamClient
+	writer  ports.ClickBatchWriter
+	cfg     config.ClickIngestionConfig
+	metrics metrics
+
+	// Last observed stream length, refreshed by the workers
+	depth atomic.Int64
+
+	closed atomic.Bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func NewRedisStreamClickQueue(client StreamClient, writer ports.ClickBatchWriter, cfg config.ClickIngestionConfig) (ports.ClickQueue, error) {
+	cfg = withDefaults(cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.XGroupCreate(ctx, cfg.StreamName, cfg.ConsumerGroup); err != nil {
+		return nil, fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	q := &redisStreamClickQueue{
+		client: client,
+		writer: writer,
+		cfg:    cfg,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	q.refreshDepth()
+
+	// Stable consumer names let a restarted worker pick up its pending entries
+	hostname, _ := os.Hostname()
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		consumer := fmt.Sprintf("%s-%d", hostname, i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(consumer)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(q.done)
+	}()
+
+	return q, nil
+}
+
+// Enqueue rejects clicks once the stream backlog reaches the configured size
+func (q *redisStreamClickQueue) Enqueue(ctx context.Context, click *domain.Click) error {
+	if q.closed.Load() {
+		return domain.ErrClickQueueClosed
+	}
+
+	if q.depth.Load() >= int64(q.cfg.QueueSize) {
+		q.metrics.dropped.Add(1)
+		return domain.ErrClickQueueFull
+	}
+
+	payload, err := json.Marshal(click)
+	if err != nil {
+		return fmt.Errorf("failed to encode click: %w", err)
+	}
+
+	if _, err := q.client.XAdd(ctx, q.cfg.StreamName, map[string]interface{}{streamPayloadField: payload}); err != nil {
+		q.metrics.dropped.Add(1)
+		return fmt.Errorf("failed to add click to stream: %w", err)
+	}
+
+	q.metrics.enqueued.Add(1)
+	q.depth.Add(1)
+	return nil
+}
+
+func (q *redisStreamClickQueue) Stats() domain.ClickQueueStats {
+	return q.metrics.stats("redis", int64(q.cfg.QueueSize), q.depth.Load())
+}
+
+// Close lets every worker finish the batch it is writing. Entries not yet read stay
+// in the stream for the next process to consume.
+func (q *redisStreamClickQueue) Close(ctx context.Context) error {
+	if q.closed.CompareAndSwap(false, true) {
+		close(q.stop)
+	}
+	return waitDone(ctx, q.done)
+}
+
+func (q *redisStreamClickQueue) work(consumer string) {
+	// Start with entries delivered to this consumer but never acknowledged
+	start := "0"
+	nextClaim := time.Now().Add(q.cfg.ClaimIdle)
+	for {
+		select {
+		case <-q.stop:
+			return
+		default:
+		}
+
+		if start == ">" && !time.Now().Before(nextClaim) {
+			if q.claimStale(consumer) {
+				continue
+			}
+			nextClaim = time.Now().Add(q.cfg.ClaimIdle)
+		}
+
+		messages, err := q.client.XReadGroup(context.Background(), q.cfg.StreamName, q.cfg.ConsumerGroup,
+			consumer, start, int64(q.cfg.BatchSize), q.cfg.FlushInterval)
+		if err != nil {
+			log.Printf("Failed to read click stream: %v", err)
+			q.sleep(q.cfg.FlushInterval)
+			continue
+		}
+
+		// Pending entries are exhausted once a "0" read comes back empty
+		if len(messages) == 0 {
+			start = ">"
+			q.refreshDepth()
+			continue
+		}
+
+		// Failed pending entries are left to claimStale, which knows how often they
+		// were delivered, instead of being re-read here forever
+		if !q.process(messages) {
+			start = ">"
+		}
+		q.refreshDepth()
+	}
+}
+
+// claimStale writes a batch of entries other consumers have held for too long,
+// reporting whether there was one
+func (q *redisStreamClickQueue) claimStale(consumer string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	messages, err := q.client.XClaimStale(ctx, q.cfg.StreamName, q.cfg.ConsumerGroup, consumer, q.cfg.ClaimIdle, int64(q.cfg.BatchSize))
+	if err != nil {
+		log.Printf("Failed to claim stale click stream entries: %v", err)
+		return false
+	}
+	if len(messages) == 0 {
+		return false
+	}
+
+	q.process(messages)
+	q.refreshDepth()
+	return true
+}
+
+// process writes a batch of entries and acknowledges the ones that were handled,
+// reporting whether all of them were
+func (q *redisStreamClickQueue) process(messages []cache.StreamMessage) bool {
+	ids := make([]string, 0, len(messages))
+	batch := make([]*domain.Click, 0, len(messages))
+	entries := make([]cache.StreamMessage, 0, len(messages))
+	for _, msg := range messages {
+		click, err := decodeStreamClick(msg)
+		if err != nil {
+			// Undecodable entries would otherwise be retried forever
+			q.metrics.failed.Add(1)
+			log.Printf("Dropping malformed click stream entry %s: %v", msg.ID, err)
+			ids = append(ids, msg.ID)
+			continue
+		}
+		batch = append(batch, click)
+		entries = append(entries, msg)
+	}
+
+	ok := true
+	if err := q.metrics.flush(q.writer, batch); err == nil {
+		for _, msg := range entries {
+			ids = append(ids, msg.ID)
+		}
+	} else {
+		// Entries delivered too often are written one at a time so a single bad
+		// click cannot hold back the others; the rest stay pending to be retried
+		handled := q.isolateExhausted(entries, batch)
+		ids = append(ids, handled...)
+		ok = false
+	}
+
+	if len(ids) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := q.client.XAckDel(ctx, q.cfg.StreamName, q.cfg.ConsumerGroup, ids...); err != nil {
+			log.Printf("Failed to acknowledge click stream entries: %v", err)
+		}
+	}
+	if !ok {
+		q.sleep(q.cfg.FlushInterval)
+	}
+	return ok
+}
+
+// isolateExhausted writes each entry that has reached MaxDeliveries on its own,
+// moving the ones that still fail to the dead-letter stream. It returns the IDs
+// of the entries that no longer need to stay pending.
+func (q *redisStreamClickQueue) isolateExhausted(entries []cache.StreamMessage, batch []*domain.Click) []string {
+	var handled []string
+	for i, msg := range entries {
+		if msg.Deliveries < int64(q.cfg.MaxDeliveries) {
+			continue
+		}
+		err := q.metrics.flush(q.writer, batch[i:i+1])
+		if err != nil && !q.deadLetter(msg, err) {
+			continue
+		}
+		handled = append(handled, msg.ID)
+	}
+	return handled
+}
+
+// deadLetter copies an entry to the dead-letter stream along with the reason it
+// could not be written, reporting whether it was stored
+func (q *redisStreamClickQueue) deadLetter(msg cache.StreamMessage, cause error) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	values := map[string]interface{}{
+		streamPayloadField: msg.Values[streamPayloadField],
+		"source_id":        msg.ID,
+		"error":            cause.Error(),
+	}
+	if _, err := q.client.XAdd(ctx, q.deadLetterStream(), values); err != nil {
+		log.Printf("Failed to dead-letter click stream entry %s: %v", msg.ID, err)
+		return false
+	}
+	log.Printf("Moved click stream entry %s to %s after %d deliveries: %v", msg.ID, q.deadLetterStream(), msg.Deliveries, cause)
+	return true
+}
+
+func (q *redisStreamClickQueue) deadLetterStream() string {
+	return q.cfg.StreamName + deadLetterSuffix
+}
+
+func (q *redisStreamClickQueue) refreshDepth() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if depth, err := q.client.XLen(ctx, q.cfg.StreamName); err == nil {
+		q.depth.Store(depth)
+	}
+}
+
+// sleep waits for d unless the queue is being closed
+func (q *redisStreamClickQueue) sleep(d time.Duration) {
+	select {
+	case <-q.stop:
+	case <-time.After(d):
+	}
+}
+
+func decodeStreamClick(msg cache.StreamMessage) (*domain.Click, error) {
+	raw, ok := msg.Values[streamPayloadField].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing %q field", streamPayloadField)
+	}
+
+	var click domain.Click
+	if err := json.Unmarshal([]byte(raw), &click); err != nil {
+		return nil, err
+	}
+	return &click, nil
+}
@@ -0,0 +1,270 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/infrastructure/cache"
+)
+
+// fakeStream is an in-memory stand-in for a Redis stream with one consumer group
+type fakeStream struct {
+	mu         sync.Mutex
+	seq        int
+	entries    []cache.StreamMessage
+	delivered  map[string]string // entry ID -> consumer, for unacknowledged entries
+	idleSince  map[string]time.Time
+	deliveries map[string]int64
+	next       int
+	dead       []cache.StreamMessage // entries added to the dead-letter stream
+}
+
+func newFakeStream() *fakeStream {
+	return &fakeStream{
+		delivered:  make(map[string]string),
+		idleSince:  make(map[string]time.Time),
+		deliveries: make(map[string]int64),
+	}
+}
+
+func (s *fakeStream) XAdd(ctx context.Context, stream string, values map[string]interface{}) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	id := fmt.Sprintf("%d-0", s.seq)
+
+	// Redis hands values back as strings
+	stored := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		stored[k] = v
+	}
+	if strings.HasSuffix(stream, deadLetterSuffix) {
+		s.dead = append(s.dead, cache.StreamMessage{ID: id, Values: stored})
+		return id, nil
+	}
+	s.entries = append(s.entries, cache.StreamMessage{ID: id, Values: stored})
+	return id, nil
+}
+
+func (s *fakeStream) XGroupCreate(ctx context.Context, stream, group string) error {
+	return nil
+}
+
+func (s *fakeStream) XReadGroup(ctx context.Context, stream, group, consumer, start string, count int64, block time.Duration) ([]cache.StreamMessage, error) {
+	s.mu.Lock()
+	var messages []cache.StreamMessage
+	if start == "0" {
+		for _, entry := range s.entries {
+			if s.delivered[entry.ID] == consumer && int64(len(messages)) < count {
+				messages = append(messages, entry)
+			}
+		}
+	} else {
+		for s.next < len(s.entries) && int64(len(messages)) < count {
+			entry := s.entries[s.next]
+			s.next++
+			s.delivered[entry.ID] = consumer
+			s.idleSince[entry.ID] = time.Now()
+			s.deliveries[entry.ID]++
+			messages = append(messages, entry)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(messages) == 0 && start != "0" {
+		time.Sleep(block)
+	}
+	return messages, nil
+}
+
+func (s *fakeStream) XClaimStale(ctx context.Context, stream, group, consumer string, minIdle time.Duration, count int64) ([]cache.StreamMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var messages []cache.StreamMessage
+	for _, entry := range s.entries {
+		if _, ok := s.delivered[entry.ID]; !ok || time.Since(s.idleSince[entry.ID]) < minIdle {
+			continue
+		}
+		if int64(len(messages)) < count {
+			s.delivered[entry.ID] = consumer
+			s.idleSince[entry.ID] = time.Now()
+			s.deliveries[entry.ID]++
+			entry.Deliveries = s.deliveries[entry.ID]
+			messages = append(messages, entry)
+		}
+	}
+	return messages, nil
+}
+
+func (s *fakeStream) XAckDel(ctx context.Context, stream, group string, ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.delivered, id)
+		for i, entry := range s.entries {
+			if entry.ID == id {
+				s.entries = append(s.entries[:i], s.entries[i+1:]...)
+				if i < s.next {
+					s.next--
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (s *fakeStream) XLen(ctx context.Context, stream string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(s.entries)), nil
+}
+
+func TestRedisStreamClickQueue_WritesAndAcknowledges(t *testing.T) {
+	stream := newFakeStream()
+	writer := &recordingWriter{}
+	q, err := NewRedisStreamClickQueue(stream, writer, testIngestionConfig())
+	require.NoError(t, err)
+
+	for i := 0; i < 25; i++ {
+		click := &domain.Click{ShortURLID: 7, IPAddress: "10.0.0.1", Country: "US"}
+		require.NoError(t, q.Enqueue(context.Background(), click))
+	}
+
+	require.Eventually(t, func() bool { return writer.total() == 25 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, q.Close(context.Background()))
+
+	assert.Equal(t, uint(7), writer.batches[0][0].ShortURLID)
+	assert.Equal(t, "US", writer.batches[0][0].Country)
+
+	length, _ := stream.XLen(context.Background(), "clicks")
+	assert.Equal(t, int64(0), length)
+
+	stats := q.Stats()
+	assert.Equal(t, "redis", stats.Backend)
+	assert.Equal(t, int64(25), stats.Enqueued)
+	assert.Equal(t, int64(25), stats.Written)
+}
+
+func TestRedisStreamClickQueue_RejectsWhenBacklogFull(t *testing.T) {
+	stream := newFakeStream()
+	for i := 0; i < 3; i++ {
+		stream.XAdd(context.Background(), "clicks", map[string]interface{}{"click": "{}"})
+	}
+	// Pretend another consumer holds the backlog so this queue never drains it
+	stream.next = 3
+
+	cfg := testIngestionConfig()
+	cfg.QueueSize = 3
+	q, err := NewRedisStreamClickQueue(stream, &recordingWriter{}, cfg)
+	require.NoError(t, err)
+	defer q.Close(context.Background())
+
+	err = q.Enqueue(context.Background(), &domain.Click{ShortURLID: 1})
+	assert.Equal(t, domain.ErrClickQueueFull, err)
+	assert.Equal(t, int64(1), q.Stats().Dropped)
+}
+
+func TestRedisStreamClickQueue_ReplaysPendingEntries(t *testing.T) {
+	stream := newFakeStream()
+	hostname, _ := os.Hostname()
+
+	// An entry delivered to this host's first worker but never acknowledged
+	id, _ := stream.XAdd(context.Background(), "clicks", map[string]interface{}{"click": `{"short_url_id":3}`})
+	stream.next = 1
+	stream.delivered[id] = hostname + "-0"
+
+	writer := &recordingWriter{}
+	q, err := NewRedisStreamClickQueue(stream, writer, testIngestionConfig())
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return writer.total() == 1 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, q.Close(context.Background()))
+	assert.Equal(t, uint(3), writer.batches[0][0].ShortURLID)
+}
+
+func TestRedisStreamClickQueue_ClaimsStaleEntries(t *testing.T) {
+	stream := newFakeStream()
+
+	// An entry read by a worker on another host that never came back
+	id, _ := stream.XAdd(context.Background(), "clicks", map[string]interface{}{"click": `{"short_url_id":4}`})
+	stream.next = 1
+	stream.delivered[id] = "gone-host-0"
+	stream.idleSince[id] = time.Now().Add(-time.Hour)
+
+	cfg := testIngestionConfig()
+	cfg.ClaimIdle = 50 * time.Millisecond
+	writer := &recordingWriter{}
+	q, err := NewRedisStreamClickQueue(stream, writer, cfg)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return writer.total() == 1 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, q.Close(context.Background()))
+	assert.Equal(t, uint(4), writer.batches[0][0].ShortURLID)
+
+	length, _ := stream.XLen(context.Background(), "clicks")
+	assert.Equal(t, int64(0), length)
+}
+
+// rejectingWriter fails every batch holding a click for one short URL
+type rejectingWriter struct {
+	recordingWriter
+	reject uint
+}
+
+func (w *rejectingWriter) WriteBatch(ctx context.Context, clicks []*domain.Click) error {
+	for _, click := range clicks {
+		if click.ShortURLID == w.reject {
+			return errors.New("invalid input syntax for type inet")
+		}
+	}
+	return w.recordingWriter.WriteBatch(ctx, clicks)
+}
+
+func TestRedisStreamClickQueue_DeadLettersFailingEntries(t *testing.T) {
+	stream := newFakeStream()
+	for _, payload := range []string{`{"short_url_id":1}`, `{"short_url_id":9}`, `{"short_url_id":2}`} {
+		stream.XAdd(context.Background(), "clicks", map[string]interface{}{"click": payload})
+	}
+
+	cfg := testIngestionConfig()
+	cfg.ClaimIdle = 20 * time.Millisecond
+	cfg.MaxDeliveries = 2
+	writer := &rejectingWriter{reject: 9}
+	q, err := NewRedisStreamClickQueue(stream, writer, cfg)
+	require.NoError(t, err)
+
+	// The bad entry no longer holds back the ones delivered with it
+	require.Eventually(t, func() bool { return writer.total() == 2 }, time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool {
+		length, _ := stream.XLen(context.Background(), "clicks")
+		return length == 0
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, q.Close(context.Background()))
+
+	require.Len(t, stream.dead, 1)
+	assert.Equal(t, `{"short_url_id":9}`, stream.dead[0].Values["click"])
+	assert.Equal(t, "2-0", stream.dead[0].Values["source_id"])
+	assert.Contains(t, stream.dead[0].Values["error"], "inet")
+}
+
+func TestRedisStreamClickQueue_RejectsAfterClose(t *testing.T) {
+	q, err := NewRedisStreamClickQueue(newFakeStream(), &recordingWriter{}, testIngestionConfig())
+	require.NoError(t, err)
+	require.NoError(t, q.Close(context.Background()))
+
+	err = q.Enqueue(context.Background(), &domain.Click{ShortURLID: 1})
+	assert.Equal(t, domain.ErrClickQueueClosed, err)
+}
@@ -0,0 +1,156 @@
+package rollup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"url-shortener/internal/config"
+	"url-shortener/internal/core/ports"
+)
+
+const (
+	defaultInterval       = time.Minute
+	defaultLag            = 5 * time.Minute
+	defaultMaxHoursPerRun = 168
+)
+
+// aggregator periodically folds closed hours of clicks into the rollup tables. An
+// hour is closed once Lag has passed since it ended, which gives queued clicks time
+// to be written. Clicks stored after their hour was rolled up pull the watermark back
+// to that hour, so it is rolled up again on the next run.
+type aggregator struct {
+	repo ports.ClickRollupRepository
+	cfg  config.RollupConfig
+	now  func() time.Time
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func NewAggregator(repo ports.ClickRollupRepository, cfg config.RollupConfig) ports.ClickRollupAggregator {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.Lag < 0 {
+		cfg.Lag = defaultLag
+	}
+	if cfg.MaxHoursPerRun <= 0 {
+		cfg.MaxHoursPerRun = defaultMaxHoursPerRun
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &aggregator{
+		repo:   repo,
+		cfg:    cfg,
+		now:    time.Now,
+		ctx:    ctx,
+		cancel: cancel,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// RunOnce rolls up closed hours from the watermark onwards, at most MaxHoursPerRun
+// of them. Hours without clicks are skipped rather than written. The watermark is
+// read again after every hour since late clicks may have moved it back.
+func (a *aggregator) RunOnce(ctx context.Context) (int, error) {
+	closedUntil := a.now().UTC().Add(-a.cfg.Lag).Truncate(time.Hour)
+
+	processed := 0
+	for processed < a.cfg.MaxHoursPerRun {
+		watermark, err := a.repo.GetWatermark(ctx)
+		if err != nil {
+			return processed, err
+		}
+		if !watermark.Before(closedUntil) {
+			break
+		}
+
+		next, ok, err := a.repo.GetNextClickTime(ctx, watermark, closedUntil)
+		if err != nil {
+			return processed, err
+		}
+		if !ok {
+			// Nothing left to roll up; move straight to the last closed hour
+			return processed, a.repo.SetWatermark(ctx, closedUntil)
+		}
+
+		hour := next.UTC().Truncate(time.Hour)
+		if err := a.repo.RollupHour(ctx, hour); err != nil {
+			return processed, fmt.Errorf("failed to roll up %s: %w", hour.Format(time.RFC3339), err)
+		}
+		processed++
+	}
+
+	return processed, nil
+}
+
+// Start runs the aggregator in the background until Close is called
+func (a *aggregator) Start() {
+	a.startOnce.Do(func() {
+		go a.loop()
+	})
+}
+
+// Close stops the background loop, cancelling an in-flight run if ctx expires first
+func (a *aggregator) Close(ctx context.Context) error {
+	a.stopOnce.Do(func() {
+		close(a.stop)
+	})
+
+	started := true
+	a.startOnce.Do(func() {
+		started = false
+		close(a.done)
+	})
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-a.done:
+		a.cancel()
+		return nil
+	case <-ctx.Done():
+		a.cancel()
+		<-a.done
+		return fmt.Errorf("rollup aggregator did not stop in time: %w", ctx.Err())
+	}
+}
+
+func (a *aggregator) loop() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		processed, err := a.RunOnce(a.ctx)
+		if err != nil && a.ctx.Err() == nil {
+			log.Printf("Click rollup failed after %d hours: %v", processed, err)
+		}
+
+		// Keep going straight away while there is a backlog
+		if err == nil && processed == a.cfg.MaxHoursPerRun {
+			select {
+			case <-a.stop:
+				return
+			default:
+				continue
+			}
+		}
+
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package rollup
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"url-shortener/internal/config"
+)
+
+// fakeRepository keeps click times and rolled up hours in memory
+type fakeRepository struct {
+	mu        sync.Mutex
+	clicks    []time.Time
+	watermark time.Time
+	rolled    []time.Time
+	failAt    time.Time
+
+	// Called after each hour is rolled up, with the lock held
+	afterRollup func(hour time.Time)
+}
+
+func (r *fakeRepository) GetWatermark(ctx context.Context) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.watermark, nil
+}
+
+func (r *fakeRepository) SetWatermark(ctx context.Context, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.watermark = until
+	return nil
+}
+
+func (r *fakeRepository) GetNextClickTime(ctx context.Context, from, to time.Time) (time.Time, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sort.Slice(r.clicks, func(i, j int) bool { return r.clicks[i].Before(r.clicks[j]) })
+	for _, t := range r.clicks {
+		if !t.Before(from) && t.Before(to) {
+			return t, true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+func (r *fakeRepository) RollupHour(ctx context.Context, hour time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if hour.Equal(r.failAt) {
+		return errors.New("deadlock detected")
+	}
+	r.rolled = append(r.rolled, hour)
+	r.watermark = hour.Add(time.Hour)
+	if r.afterRollup != nil {
+		r.afterRollup(hour)
+	}
+	return nil
+}
+
+func (r *fakeRepository) rolledHours() []time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]time.Time(nil), r.rolled...)
+}
+
+var now = time.Date(2024, 3, 4, 12, 3, 0, 0, time.UTC)
+
+func newTestAggregator(repo *fakeRepository, cfg config.RollupConfig) *aggregator {
+	a := NewAggregator(repo, cfg).(*aggregator)
+	a.now = func() time.Time { return now }
+	return a
+}
+
+func TestRunOnce_RollsUpClosedHoursWithClicks(t *testing.T) {
+	repo := &fakeRepository{clicks: []time.Time{
+		now.Add(-50 * time.Hour),
+		now.Add(-50*time.Hour + 10*time.Minute),
+		now.Add(-3 * time.Hour),
+		now.Add(-2 * time.Minute), // still inside the lag
+	}}
+	a := newTestAggregator(repo, config.RollupConfig{Lag: 5 * time.Minute})
+
+	processed, err := a.RunOnce(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, processed)
+	assert.Equal(t, []time.Time{
+		time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC),
+	}, repo.rolledHours())
+	// 12:03 minus the lag leaves 11:00-12:00 as the last closed hour
+	assert.Equal(t, time.Date(2024, 3, 4, 11, 0, 0, 0, time.UTC), repo.watermark)
+}
+
+func TestRunOnce_RespectsMaxHoursPerRun(t *testing.T) {
+	repo := &fakeRepository{}
+	for i := 1; i <= 5; i++ {
+		repo.clicks = append(repo.clicks, now.Add(-time.Duration(i)*time.Hour))
+	}
+	a := newTestAggregator(repo, config.RollupConfig{Lag: time.Minute, MaxHoursPerRun: 3})
+
+	processed, err := a.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, processed)
+
+	processed, err = a.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, processed)
+	assert.Len(t, repo.rolledHours(), 5)
+}
+
+func TestRunOnce_StopsAtFailedHour(t *testing.T) {
+	repo := &fakeRepository{
+		clicks: []time.Time{now.Add(-5 * time.Hour), now.Add(-4 * time.Hour)},
+		failAt: now.Add(-4 * time.Hour).Truncate(time.Hour),
+	}
+	a := newTestAggregator(repo, config.RollupConfig{Lag: time.Minute})
+
+	processed, err := a.RunOnce(context.Background())
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, processed)
+	assert.Equal(t, now.Add(-4*time.Hour).Truncate(time.Hour), repo.watermark)
+}
+
+func TestRunOnce_RebuildsHoursOfLateClicks(t *testing.T) {
+	repo := &fakeRepository{clicks: []time.Time{now.Add(-5 * time.Hour), now.Add(-3 * time.Hour)}}
+	late := now.Add(-5*time.Hour + 30*time.Minute)
+	repo.afterRollup = func(hour time.Time) {
+		// A click for the first hour is stored while the run is under way
+		if hour.Equal(now.Add(-3 * time.Hour).Truncate(time.Hour)) {
+			repo.clicks = append(repo.clicks, late)
+			repo.watermark = late.Truncate(time.Hour)
+			repo.afterRollup = nil
+		}
+	}
+	a := newTestAggregator(repo, config.RollupConfig{Lag: time.Minute})
+
+	processed, err := a.RunOnce(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 4, processed)
+	assert.Equal(t, []time.Time{
+		now.Add(-5 * time.Hour).Truncate(time.Hour),
+		now.Add(-3 * time.Hour).Truncate(time.Hour),
+		now.Add(-5 * time.Hour).Truncate(time.Hour),
+		now.Add(-3 * time.Hour).Truncate(time.Hour),
+	}, repo.rolledHours())
+	assert.Equal(t, time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC), repo.watermark)
+}
+
+func TestRunOnce_NothingToDo(t *testing.T) {
+	repo := &fakeRepository{}
+	a := newTestAggregator(repo, config.RollupConfig{Lag: time.Minute})
+
+	processed, err := a.RunOnce(context.Background())
+
+	require.NoError(t, err)
+	assert.Zero(t, processed)
+	assert.Equal(t, time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC), repo.watermark)
+}
+
+func TestStartAndClose(t *testing.T) {
+	repo := &fakeRepository{clicks: []time.Time{now.Add(-2 * time.Hour)}}
+	a := newTestAggregator(repo, config.RollupConfig{Interval: time.Hour, Lag: time.Minute})
+
+	a.Start()
+	require.Eventually(t, func() bool { return len(repo.rolledHours()) == 1 }, time.Second, 5*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, a.Close(ctx))
+	assert.NoError(t, a.Close(ctx))
+}
+
+func TestCloseWithoutStart(t *testing.T) {
+	a := newTestAggregator(&fakeRepository{}, config.RollupConfig{})
+	assert.NoError(t, a.Close(context.Background()))
+}
@@ -0,0 +1,181 @@
+package useragent
+
+import (
+	"regexp"
+	"strings"
+
+	"url-shortener/internal/core/domain"
+	"url-shortener/internal/core/ports"
+)
+
+const unknown = "Unknown"
+
+// pattern maps a regular expression to a family name. The first capture group,
+// when present, holds the version.
+type pattern struct {
+	family string
+	re     *regexp.Regexp
+}
+
+func p(family, expr string) pattern {
+	return pattern{family: family, re: regexp.MustCompile(expr)}
+}
+
+// Crawlers, link preview fetchers and HTTP libraries. Checked before browsers because
+// many of them embed a browser token.
+var botPatterns = []pattern{
+	p("Googlebot", `Googlebot(?:-[A-Za-z]+)?/([\d.]+)`),
+	p("Bingbot", `bingbot/([\d.]+)`),
+	p("Yahoo Slurp", `Yahoo! Slurp`),
+	p("DuckDuckBot", `DuckDuckBot(?:-Https)?/([\d.]+)`),
+	p("Baiduspider", `Baiduspider(?:-[a-z]+)?/([\d.]+)`),
+	p("YandexBot", `YandexBot/([\d.]+)`),
+	p("Applebot", `Applebot/([\d.]+)`),
+	p("Facebook Crawler", `facebookexternalhit/([\d.]+)`),
+	p("Twitterbot", `Twitterbot/([\d.]+)`),
+	p("LinkedInBot", `LinkedInBot/([\d.]+)`),
+	p("Slackbot", `Slackbot(?:-LinkExpanding)?(?: ([\d.]+))?`),
+	p("Discordbot", `Discordbot/([\d.]+)`),
+	p("TelegramBot", `TelegramBot`),
+	p("WhatsApp", `^WhatsApp/([\d.]+)`),
+	p("Headless Chrome", `HeadlessChrome/([\d.]+)`),
+	p("curl", `^curl/([\d.]+)`),
+	p("Wget", `^Wget/([\d.]+)`),
+	p("Python Requests", `python-requests/([\d.]+)`),
+	p("Go HTTP Client", `Go-http-client/([\d.]+)`),
+	p("Bot", `(?i)bot\b|crawler|spider|scraper|preview`),
+}
+
+// Order matters: most browsers also claim to be Chrome and Safari
+var browserPatterns = []pattern{
+	p("Edge", `(?:Edg|EdgA|EdgiOS|Edge)/([\d.]+)`),
+	p("Opera Mini", `Opera Mini/([\d.]+)`),
+	p("Opera", `(?:OPR|OPT|OPiOS)/([\d.]+)`),
+	p("Opera", `Opera/.*Version/([\d.]+)`),
+	p("Samsung Internet", `SamsungBrowser/([\d.]+)`),
+	p("Yandex Browser", `YaBrowser/([\d.]+)`),
+	p("UC Browser", `UCBrowser/([\d.]+)`),
+	p("Vivaldi", `Vivaldi/([\d.]+)`),
+	p("Facebook", `FBAV/([\d.]+)`),
+	p("Instagram", `Instagram ([\d.]+)`),
+	p("Firefox", `(?:Firefox|FxiOS)/([\d.]+)`),
+	p("Android WebView", `; wv\).*Chrome/([\d.]+)`),
+	p("Chrome", `CriOS/([\d.]+)`),
+	p("Chromium", `Chromium/([\d.]+)`),
+	p("Chrome", `Chrome/([\d.]+)`),
+	p("Android Browser", `Android.*Version/([\d.]+).*Safari/`),
+	p("Safari", `Version/([\d.]+).*Safari/`),
+	p("Safari", `(?:iPhone|iPad|iPod).*AppleWebKit/`),
+	p("Internet Explorer", `MSIE ([\d.]+)`),
+	p("Internet Explorer", `Trident/.*rv:([\d.]+)`),
+}
+
+// Order matters: Windows Phone and Android UAs also mention other systems
+var osPatterns = []pattern{
+	p("Windows Phone", `Windows Phone(?: OS)? ([\d.]+)`),
+	p("Windows", `Windows NT ([\d.]+)`),
+	p("iOS", `(?:iPhone|CPU) OS ([\d_]+)`),
+	p("iOS", `iPhone|iPad|iPod`),
+	p("macOS", `Mac OS X ([\d_.]+)`),
+	p("macOS", `Macintosh`),
+	p("Chrome OS", `CrOS \S+ ([\d.]+)`),
+	p("KaiOS", `KAIOS/([\d.]+)`),
+	p("Tizen", `Tizen[ /]([\d.]+)`),
+	p("webOS", `(?:webOS|Web0S)(?:\.TV)?(?:[/ -]([\d.]+))?`),
+	p("Android", `Android(?:[ /]([\d.]+))?`),
+	p("BlackBerry", `BlackBerry|BB10`),
+	p("Roku", `Roku`),
+	p("PlayStation", `PlayStation`),
+	p("Linux", `Linux|X11`),
+}
+
+var (
+	tvPattern      = regexp.MustCompile(`(?i)smart-?tv|googletv|google tv|android tv|appletv|apple tv|crkey|roku|bravia|hbbtv|netcast|web0s|webos\.tv|\bAFT[A-Z]+\b|xbox|playstation|nintendo`)
+	tabletPattern  = regexp.MustCompile(`(?i)ipad|tablet|kindle|silk/|playbook|nexus (?:7|9|10)\b`)
+	phonePattern   = regexp.MustCompile(`(?i)mobile|opera mini|\bmobi\b`)
+	mobilePattern  = regexp.MustCompile(`(?i)mobile|iphone|ipod|android|windows phone|blackberry|bb10|opera mini|iemobile|kaios|\bmobi\b`)
+	desktopPattern = regexp.MustCompile(`(?i)windows nt|macintosh|mac os x|x11|linux|cros`)
+)
+
+// Windows NT kernel versions to marketing names
+var windowsVersions = map[string]string{
+	"10.0": "10",
+	"6.3":  "8.1",
+	"6.2":  "8",
+	"6.1":  "7",
+	"6.0":  "Vista",
+	"5.2":  "XP",
+	"5.1":  "XP",
+}
+
+type parser struct{}
+
+// NewParser returns a rule-based User-Agent parser. It holds no state and is safe
+// for concurrent use.
+func NewParser() ports.UserAgentParser {
+	return &parser{}
+}
+
+func (ps *parser) Parse(userAgent string) *domain.UserAgentInfo {
+	userAgent = strings.TrimSpace(userAgent)
+	info := &domain.UserAgentInfo{
+		DeviceType: domain.DeviceTypeUnknown,
+		Browser:    unknown,
+		OS:         unknown,
+	}
+	if userAgent == "" {
+		return info
+	}
+
+	info.OS, info.OSVersion = match(osPatterns, userAgent)
+	if info.OS == "Windows" {
+		if name, ok := windowsVersions[info.OSVersion]; ok {
+			info.OSVersion = name
+		}
+	}
+
+	if bot, version := match(botPatterns, userAgent); bot != unknown {
+		info.DeviceType = domain.DeviceTypeBot
+		info.Browser, info.BrowserVersion = bot, version
+		return info
+	}
+
+	info.Browser, info.BrowserVersion = match(browserPatterns, userAgent)
+	info.DeviceType = deviceType(userAgent, info.OS)
+	return info
+}
+
+func deviceType(userAgent, os string) string {
+	lower := strings.ToLower(userAgent)
+	switch {
+	case tvPattern.MatchString(userAgent):
+		return domain.DeviceTypeTV
+	case tabletPattern.MatchString(userAgent) && !strings.Contains(lower, "tablet pc"):
+		return domain.DeviceTypeTablet
+	// Android tablets leave "Mobile" out of the UA
+	case os == "Android" && !phonePattern.MatchString(userAgent):
+		return domain.DeviceTypeTablet
+	case mobilePattern.MatchString(userAgent):
+		return domain.DeviceTypeMobile
+	case desktopPattern.MatchString(userAgent):
+		return domain.DeviceTypeDesktop
+	default:
+		return domain.DeviceTypeUnknown
+	}
+}
+
+// match returns the family and version of the first matching pattern
+func match(patterns []pattern, userAgent string) (string, string) {
+	for _, pt := range patterns {
+		m := pt.re.FindStringSubmatch(userAgent)
+		if m == nil {
+			continue
+		}
+		version := ""
+		if len(m) > 1 {
+			version = strings.ReplaceAll(m[1], "_", ".")
+		}
+		return pt.family, version
+	}
+	return unknown, ""
+}
@@ -0,0 +1,305 @@
+package useragent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"url-shortener/internal/core/domain"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name           string
+		userAgent      string
+		device         string
+		browser        string
+		browserVersion string
+		os             string
+		osVersion      string
+	}{
+		// Desktop
+		{
+			name:      "Chrome on Windows 10",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.110 Safari/537.36",
+			device:    domain.DeviceTypeDesktop, browser: "Chrome", browserVersion: "120.0.6099.110", os: "Windows", osVersion: "10",
+		},
+		{
+			name:      "Edge on Windows 10",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			device:    domain.DeviceTypeDesktop, browser: "Edge", browserVersion: "120.0.2210.91", os: "Windows", osVersion: "10",
+		},
+		{
+			name:      "Legacy Edge",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/70.0.3538.102 Safari/537.36 Edge/18.19582",
+			device:    domain.DeviceTypeDesktop, browser: "Edge", browserVersion: "18.19582", os: "Windows", osVersion: "10",
+		},
+		{
+			name:      "Firefox on Windows 7",
+			userAgent: "Mozilla/5.0 (Windows NT 6.1; Win64; x64; rv:115.0) Gecko/20100101 Firefox/115.0",
+			device:    domain.DeviceTypeDesktop, browser: "Firefox", browserVersion: "115.0", os: "Windows", osVersion: "7",
+		},
+		{
+			name:      "Internet Explorer 11",
+			userAgent: "Mozilla/5.0 (Windows NT 6.3; Trident/7.0; rv:11.0) like Gecko",
+			device:    domain.DeviceTypeDesktop, browser: "Internet Explorer", browserVersion: "11.0", os: "Windows", osVersion: "8.1",
+		},
+		{
+			name:      "Internet Explorer 8 on XP with Tablet PC token",
+			userAgent: "Mozilla/4.0 (compatible; MSIE 8.0; Windows NT 5.1; Trident/4.0; Tablet PC 2.0)",
+			device:    domain.DeviceTypeDesktop, browser: "Internet Explorer", browserVersion: "8.0", os: "Windows", osVersion: "XP",
+		},
+		{
+			name:      "Safari on macOS",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15",
+			device:    domain.DeviceTypeDesktop, browser: "Safari", browserVersion: "17.2", os: "macOS", osVersion: "10.15.7",
+		},
+		{
+			name:      "Opera on macOS",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 OPR/105.0.0.0",
+			device:    domain.DeviceTypeDesktop, browser: "Opera", browserVersion: "105.0.0.0", os: "macOS", osVersion: "10.15.7",
+		},
+		{
+			name:      "Presto Opera",
+			userAgent: "Opera/9.80 (Windows NT 6.1; WOW64) Presto/2.12.388 Version/12.18",
+			device:    domain.DeviceTypeDesktop, browser: "Opera", browserVersion: "12.18", os: "Windows", osVersion: "7",
+		},
+		{
+			name:      "Vivaldi on Linux",
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Vivaldi/6.5.3206.48",
+			device:    domain.DeviceTypeDesktop, browser: "Vivaldi", browserVersion: "6.5.3206.48", os: "Linux", osVersion: "",
+		},
+		{
+			name:      "Firefox on Ubuntu",
+			userAgent: "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			device:    domain.DeviceTypeDesktop, browser: "Firefox", browserVersion: "121.0", os: "Linux", osVersion: "",
+		},
+		{
+			name:      "Chrome on Chrome OS",
+			userAgent: "Mozilla/5.0 (X11; CrOS x86_64 15633.69.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.6045.212 Safari/537.36",
+			device:    domain.DeviceTypeDesktop, browser: "Chrome", browserVersion: "119.0.6045.212", os: "Chrome OS", osVersion: "15633.69.0",
+		},
+		{
+			name:      "Yandex Browser",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 YaBrowser/23.11.0.0 Safari/537.36",
+			device:    domain.DeviceTypeDesktop, browser: "Yandex Browser", browserVersion: "23.11.0.0", os: "Windows", osVersion: "10",
+		},
+
+		// Mobile
+		{
+			name:      "Safari on iPhone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1.2 Mobile/15E148 Safari/604.1",
+			device:    domain.DeviceTypeMobile, browser: "Safari", browserVersion: "17.1.2", os: "iOS", osVersion: "17.1.2",
+		},
+		{
+			name:      "Chrome on iPhone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1",
+			device:    domain.DeviceTypeMobile, browser: "Chrome", browserVersion: "120.0.6099.119", os: "iOS", osVersion: "16.6",
+		},
+		{
+			name:      "Firefox on iPhone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) FxiOS/121.0 Mobile/15E148 Safari/605.1.15",
+			device:    domain.DeviceTypeMobile, browser: "Firefox", browserVersion: "121.0", os: "iOS", osVersion: "17.2",
+		},
+		{
+			name:      "Edge on iPhone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 EdgiOS/120.2210.126 Mobile/15E148 Safari/605.1.15",
+			device:    domain.DeviceTypeMobile, browser: "Edge", browserVersion: "120.2210.126", os: "iOS", osVersion: "17.2",
+		},
+		{
+			name:      "Instagram in-app browser",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 Instagram 309.0.1.18.114 (iPhone14,5; iOS 17_1; en_US; en; scale=3.00; 1170x2532; 537288532)",
+			device:    domain.DeviceTypeMobile, browser: "Instagram", browserVersion: "309.0.1.18.114", os: "iOS", osVersion: "17.1",
+		},
+		{
+			name:      "Facebook in-app browser",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 16_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 [FBAN/FBIOS;FBDV/iPhone13,2;FBMD/iPhone;FBSN/iOS;FBSV/16.5;FBSS/3;FBID/phone;FBLC/en_US;FBOP/5;FBAV/420.0.0.32.107]",
+			device:    domain.DeviceTypeMobile, browser: "Facebook", browserVersion: "420.0.0.32.107", os: "iOS", osVersion: "16.5",
+		},
+		{
+			name:      "iOS web view without Safari token",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 15_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148",
+			device:    domain.DeviceTypeMobile, browser: "Safari", browserVersion: "", os: "iOS", osVersion: "15.0",
+		},
+		{
+			name:      "Chrome on Android phone",
+			userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.144 Mobile Safari/537.36",
+			device:    domain.DeviceTypeMobile, browser: "Chrome", browserVersion: "120.0.6099.144", os: "Android", osVersion: "14",
+		},
+		{
+			name:      "Android WebView",
+			userAgent: "Mozilla/5.0 (Linux; Android 13; SM-S911B; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/119.0.6045.163 Mobile Safari/537.36",
+			device:    domain.DeviceTypeMobile, browser: "Android WebView", browserVersion: "119.0.6045.163", os: "Android", osVersion: "13",
+		},
+		{
+			name:      "Samsung Internet",
+			userAgent: "Mozilla/5.0 (Linux; Android 13; SAMSUNG SM-A536B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36",
+			device:    domain.DeviceTypeMobile, browser: "Samsung Internet", browserVersion: "23.0", os: "Android", osVersion: "13",
+		},
+		{
+			name:      "Firefox on Android",
+			userAgent: "Mozilla/5.0 (Android 14; Mobile; rv:121.0) Gecko/121.0 Firefox/121.0",
+			device:    domain.DeviceTypeMobile, browser: "Firefox", browserVersion: "121.0", os: "Android", osVersion: "14",
+		},
+		{
+			name:      "Opera Mini",
+			userAgent: "Opera/9.80 (Android; Opera Mini/36.2.2254/119.132; U; id) Presto/2.12.423 Version/12.16",
+			device:    domain.DeviceTypeMobile, browser: "Opera Mini", browserVersion: "36.2.2254", os: "Android", osVersion: "",
+		},
+		{
+			name:      "UC Browser",
+			userAgent: "Mozilla/5.0 (Linux; U; Android 10; en-US; RMX1911 Build/QKQ1.200209.002) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/78.0.3904.108 UCBrowser/13.4.0.1306 Mobile Safari/537.36",
+			device:    domain.DeviceTypeMobile, browser: "UC Browser", browserVersion: "13.4.0.1306", os: "Android", osVersion: "10",
+		},
+		{
+			name:      "Stock Android browser",
+			userAgent: "Mozilla/5.0 (Linux; U; Android 4.0.3; ko-kr; LG-L160L Build/IML74K) AppleWebkit/534.30 (KHTML, like Gecko) Version/4.0 Mobile Safari/534.30",
+			device:    domain.DeviceTypeMobile, browser: "Android Browser", browserVersion: "4.0", os: "Android", osVersion: "4.0.3",
+		},
+		{
+			name:      "Windows Phone",
+			userAgent: "Mozilla/5.0 (Windows Phone 10.0; Android 6.0.1; Microsoft; Lumia 950) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/52.0.2743.116 Mobile Safari/537.36 Edge/15.14977",
+			device:    domain.DeviceTypeMobile, browser: "Edge", browserVersion: "15.14977", os: "Windows Phone", osVersion: "10.0",
+		},
+		{
+			name:      "KaiOS feature phone",
+			userAgent: "Mozilla/5.0 (Mobile; LYF/F300B/LYF-F300B-001-01-15-130718-i; Android; rv:48.0) Gecko/48.0 Firefox/48.0 KAIOS/2.5",
+			device:    domain.DeviceTypeMobile, browser: "Firefox", browserVersion: "48.0", os: "KaiOS", osVersion: "2.5",
+		},
+		{
+			name:      "BlackBerry 10",
+			userAgent: "Mozilla/5.0 (BB10; Touch) AppleWebKit/537.35+ (KHTML, like Gecko) Version/10.3.3.3216 Mobile Safari/537.35+",
+			device:    domain.DeviceTypeMobile, browser: "Safari", browserVersion: "10.3.3.3216", os: "BlackBerry", osVersion: "",
+		},
+
+		// Tablet
+		{
+			name:      "Safari on iPad",
+			userAgent: "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1",
+			device:    domain.DeviceTypeTablet, browser: "Safari", browserVersion: "16.6", os: "iOS", osVersion: "16.6",
+		},
+		{
+			name:      "Chrome on Android tablet",
+			userAgent: "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.144 Safari/537.36",
+			device:    domain.DeviceTypeTablet, browser: "Chrome", browserVersion: "120.0.6099.144", os: "Android", osVersion: "13",
+		},
+		{
+			name:      "Firefox on Android tablet",
+			userAgent: "Mozilla/5.0 (Android 13; Tablet; rv:121.0) Gecko/121.0 Firefox/121.0",
+			device:    domain.DeviceTypeTablet, browser: "Firefox", browserVersion: "121.0", os: "Android", osVersion: "13",
+		},
+		{
+			name:      "Kindle Fire Silk",
+			userAgent: "Mozilla/5.0 (Linux; Android 9; KFTRWI) AppleWebKit/537.36 (KHTML, like Gecko) Silk/119.3.1 like Chrome/119.0.6045.193 Safari/537.36",
+			device:    domain.DeviceTypeTablet, browser: "Chrome", browserVersion: "119.0.6045.193", os: "Android", osVersion: "9",
+		},
+
+		// TV
+		{
+			name:      "Samsung Smart TV",
+			userAgent: "Mozilla/5.0 (SMART-TV; LINUX; Tizen 6.0) AppleWebKit/537.36 (KHTML, like Gecko) 76.0.3809.146/6.0 TV Safari/537.36",
+			device:    domain.DeviceTypeTV, browser: "Unknown", browserVersion: "", os: "Tizen", osVersion: "6.0",
+		},
+		{
+			name:      "LG webOS TV",
+			userAgent: "Mozilla/5.0 (Web0S; Linux/SmartTV) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/79.0.3945.79 Safari/537.36 WebAppManager",
+			device:    domain.DeviceTypeTV, browser: "Chrome", browserVersion: "79.0.3945.79", os: "webOS", osVersion: "",
+		},
+		{
+			name:      "Fire TV",
+			userAgent: "Mozilla/5.0 (Linux; Android 9; AFTMM Build/PS7233) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/108.0.5359.160 Mobile Safari/537.36",
+			device:    domain.DeviceTypeTV, browser: "Chrome", browserVersion: "108.0.5359.160", os: "Android", osVersion: "9",
+		},
+		{
+			name:      "Chromecast",
+			userAgent: "Mozilla/5.0 (X11; Linux armv7l) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/95.0.4638.74 Safari/537.36 CrKey/1.56.500000",
+			device:    domain.DeviceTypeTV, browser: "Chrome", browserVersion: "95.0.4638.74", os: "Linux", osVersion: "",
+		},
+		{
+			name:      "Xbox",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; Xbox; Xbox One) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edge/44.18363.8131",
+			device:    domain.DeviceTypeTV, browser: "Edge", browserVersion: "44.18363.8131", os: "Windows", osVersion: "10",
+		},
+
+		// Bots and tools
+		{
+			name:      "Googlebot",
+			userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			device:    domain.DeviceTypeBot, browser: "Googlebot", browserVersion: "2.1", os: "Unknown", osVersion: "",
+		},
+		{
+			name:      "Googlebot smartphone",
+			userAgent: "Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.6045.199 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			device:    domain.DeviceTypeBot, browser: "Googlebot", browserVersion: "2.1", os: "Android", osVersion: "6.0.1",
+		},
+		{
+			name:      "Bingbot",
+			userAgent: "Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)",
+			device:    domain.DeviceTypeBot, browser: "Bingbot", browserVersion: "2.0", os: "Unknown", osVersion: "",
+		},
+		{
+			name:      "Facebook link preview",
+			userAgent: "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)",
+			device:    domain.DeviceTypeBot, browser: "Facebook Crawler", browserVersion: "1.1", os: "Unknown", osVersion: "",
+		},
+		{
+			name:      "Slack link expander",
+			userAgent: "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+			device:    domain.DeviceTypeBot, browser: "Slackbot", browserVersion: "1.0", os: "Unknown", osVersion: "",
+		},
+		{
+			name:      "WhatsApp preview",
+			userAgent: "WhatsApp/2.23.20.0 A",
+			device:    domain.DeviceTypeBot, browser: "WhatsApp", browserVersion: "2.23.20.0", os: "Unknown", osVersion: "",
+		},
+		{
+			name:      "Headless Chrome",
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/120.0.6099.109 Safari/537.36",
+			device:    domain.DeviceTypeBot, browser: "Headless Chrome", browserVersion: "120.0.6099.109", os: "Linux", osVersion: "",
+		},
+		{
+			name:      "curl",
+			userAgent: "curl/8.4.0",
+			device:    domain.DeviceTypeBot, browser: "curl", browserVersion: "8.4.0", os: "Unknown", osVersion: "",
+		},
+		{
+			name:      "Go HTTP client",
+			userAgent: "Go-http-client/1.1",
+			device:    domain.DeviceTypeBot, browser: "Go HTTP Client", browserVersion: "1.1", os: "Unknown", osVersion: "",
+		},
+		{
+			name:      "Generic crawler",
+			userAgent: "Mozilla/5.0 (compatible; AhrefsBot/7.0; +http://ahrefs.com/robot/)",
+			device:    domain.DeviceTypeBot, browser: "Bot", browserVersion: "", os: "Unknown", osVersion: "",
+		},
+
+		// Unknown
+		{
+			name:      "Empty",
+			userAgent: "",
+			device:    domain.DeviceTypeUnknown, browser: "Unknown", browserVersion: "", os: "Unknown", osVersion: "",
+		},
+		{
+			name:      "Garbage",
+			userAgent: "something-weird",
+			device:    domain.DeviceTypeUnknown, browser: "Unknown", browserVersion: "", os: "Unknown", osVersion: "",
+		},
+	}
+
+	parser := NewParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := parser.Parse(tt.userAgent)
+			assert.Equal(t, tt.device, info.DeviceType, "device type")
+			assert.Equal(t, tt.browser, info.Browser, "browser")
+			assert.Equal(t, tt.browserVersion, info.BrowserVersion, "browser version")
+			assert.Equal(t, tt.os, info.OS, "os")
+			assert.Equal(t, tt.osVersion, info.OSVersion, "os version")
+		})
+	}
+}
+
+func TestParse_IsBot(t *testing.T) {
+	parser := NewParser()
+	assert.True(t, parser.Parse("Mozilla/5.0 (compatible; YandexBot/3.0; +http://yandex.com/bots)").IsBot())
+	assert.False(t, parser.Parse("Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0").IsBot())
+}